@@ -46,6 +46,11 @@ type Artifact struct {
 	// +optional
 	Checksum string `json:"checksum"`
 
+	// Digest is the digest of the file in the form of '<algorithm>:<checksum>'.
+	// +optional
+	// +kubebuilder:validation:Pattern="^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$"
+	Digest string `json:"digest,omitempty"`
+
 	// LastUpdateTime is the timestamp corresponding to the last update of the
 	// Artifact.
 	// +required
@@ -74,6 +79,15 @@ func (in *Artifact) HasChecksum(checksum string) bool {
 	return in.Checksum == checksum
 }
 
+// HasDigest returns if the given digest matches the current Digest of the
+// Artifact.
+func (in *Artifact) HasDigest(digest string) bool {
+	if in == nil {
+		return false
+	}
+	return in.Digest == digest
+}
+
 // ArtifactDir returns the artifact dir path in the form of
 // '<kind>/<namespace>/<name>'.
 func ArtifactDir(kind, namespace, name string) string {
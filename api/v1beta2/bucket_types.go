@@ -72,11 +72,20 @@ type BucketSpec struct {
 	// +optional
 	Region string `json:"region,omitempty"`
 
+	// Prefix to use for server-side filtering of files in the Bucket.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
 	// SecretRef specifies the Secret containing authentication credentials
 	// for the Bucket.
 	// +optional
 	SecretRef *meta.LocalObjectReference `json:"secretRef,omitempty"`
 
+	// Verification specifies the configuration to verify the detached
+	// signatures of the objects in the Bucket.
+	// +optional
+	Verification *BucketVerification `json:"verify,omitempty"`
+
 	// Interval at which to check the Endpoint for updates.
 	// +required
 	Interval metav1.Duration `json:"interval"`
@@ -104,6 +113,34 @@ type BucketSpec struct {
 	AccessFrom *acl.AccessFrom `json:"accessFrom,omitempty"`
 }
 
+// BucketVerification specifies the detached signature verification of the
+// objects in a Bucket.
+type BucketVerification struct {
+	// Provider specifies the technology used to sign the objects, currently
+	// ('cosign'). Every object is expected to have a sidecar object with the
+	// same key and a '.sig' suffix, containing the base64 encoded signature
+	// of the object as created with 'cosign sign-blob --key'.
+	// +kubebuilder:validation:Enum=cosign
+	// +kubebuilder:default:=cosign
+	Provider string `json:"provider"`
+
+	// SecretRef specifies the Secret containing the PEM encoded public keys
+	// of the trusted signers, or the PEM encoded root certificates of the
+	// issuers of their signing certificates.
+	// +required
+	SecretRef meta.LocalObjectReference `json:"secretRef"`
+
+	// MaxAge rejects signatures which were signed longer than the given
+	// duration ago, to guard against replayed objects. It requires the
+	// Secret to contain root certificates, and every signature object to
+	// have a sidecar object with the same key and a '.pem' suffix,
+	// containing the PEM encoded signing certificate as created with
+	// 'cosign sign-blob --output-certificate'. The start of the validity
+	// period of the certificate is used as the signing time.
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
 // BucketStatus records the observed state of a Bucket.
 type BucketStatus struct {
 	// ObservedGeneration is the last observed generation of the Bucket object.
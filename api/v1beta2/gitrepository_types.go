@@ -47,8 +47,10 @@ const (
 // GitRepositorySpec specifies the required configuration to produce an
 // Artifact for a Git repository.
 type GitRepositorySpec struct {
-	// URL specifies the Git repository URL, it can be an HTTP/S or SSH address.
-	// +kubebuilder:validation:Pattern="^(http|https|ssh)://"
+	// URL specifies the Git repository URL, it can be an HTTP/S or SSH address,
+	// or the file URL of a Git bundle within the bundle directory of the
+	// controller.
+	// +kubebuilder:validation:Pattern="^(http|https|ssh|file)://"
 	// +required
 	URL string `json:"url"`
 
@@ -100,7 +102,6 @@ type GitRepositorySpec struct {
 
 	// RecurseSubmodules enables the initialization of all submodules within
 	// the GitRepository as cloned from the URL, using their default settings.
-	// This option is available only when using the 'go-git' GitImplementation.
 	// +optional
 	RecurseSubmodules bool `json:"recurseSubmodules,omitempty"`
 
@@ -234,6 +235,10 @@ const (
 	// GitOperationFailedReason signals that a Git operation (e.g. clone,
 	// checkout, etc.) failed.
 	GitOperationFailedReason string = "GitOperationFailed"
+
+	// EmptyRepositoryReason signals that the remote Git repository exists,
+	// but does not have any references yet.
+	EmptyRepositoryReason string = "EmptyRepository"
 )
 
 // GetConditions returns the status conditions of the object.
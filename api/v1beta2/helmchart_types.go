@@ -74,6 +74,19 @@ type HelmChartSpec struct {
 	// +deprecated
 	ValuesFile string `json:"valuesFile,omitempty"`
 
+	// ValuesFrom holds references to resources containing chart values, which
+	// are merged in the order of this list on top of the values composed from
+	// ValuesFiles, or the chart's default values when no ValuesFiles are set.
+	// +optional
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+
+	// ValidateValuesSchema enables the validation of the values composed from
+	// ValuesFiles and ValuesFrom against the values.schema.json of the chart
+	// and its dependencies. The chart build fails when the values do not
+	// match the schema.
+	// +optional
+	ValidateValuesSchema bool `json:"validateValuesSchema,omitempty"`
+
 	// Suspend tells the controller to suspend the reconciliation of this
 	// source.
 	// +optional
@@ -112,6 +125,43 @@ type LocalHelmChartSourceReference struct {
 	Name string `json:"name"`
 }
 
+// ValuesReference contains a reference to a resource containing chart values,
+// and optionally the key they can be found at.
+type ValuesReference struct {
+	// Kind of the values referent, valid values are ('Secret', 'ConfigMap').
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	// +required
+	Kind string `json:"kind"`
+
+	// Name of the values referent. Should reside in the same namespace as the
+	// referring resource.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +required
+	Name string `json:"name"`
+
+	// ValuesKey is the data key where the values.yaml can be found at in the
+	// referred resource. Defaults to 'values.yaml'.
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[\-._a-zA-Z0-9]+$`
+	// +optional
+	ValuesKey string `json:"valuesKey,omitempty"`
+
+	// Optional marks this ValuesReference as optional. When set, a not found
+	// error for the values reference is ignored, but any ValuesKey or
+	// transient error will still result in a reconciliation failure.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// GetValuesKey returns the defined ValuesKey, or the default ('values.yaml').
+func (in ValuesReference) GetValuesKey() string {
+	if in.ValuesKey == "" {
+		return "values.yaml"
+	}
+	return in.ValuesKey
+}
+
 // HelmChartStatus records the observed state of the HelmChart.
 type HelmChartStatus struct {
 	// ObservedGeneration is the last observed generation of the HelmChart
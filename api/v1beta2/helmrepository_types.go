@@ -46,6 +46,12 @@ type HelmRepositorySpec struct {
 	// +required
 	URL string `json:"url"`
 
+	// Mirrors is a list of URLs of Helm repositories serving the same
+	// content as URL. They are tried in order when fetching the index or a
+	// chart from URL fails with a connection error or a 5xx status.
+	// +optional
+	Mirrors []string `json:"mirrors,omitempty"`
+
 	// SecretRef specifies the Secret containing authentication credentials
 	// for the HelmRepository.
 	// For HTTP/S basic auth the secret must contain 'username' and 'password'
@@ -16,7 +16,12 @@ limitations under the License.
 
 package controllers
 
-import sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+import (
+	"crypto/sha256"
+	"fmt"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+)
 
 type artifactSet []*sourcev1.Artifact
 
@@ -59,3 +64,17 @@ OUTER:
 
 	return false
 }
+
+// artifactFileName returns the file name with the given extension of the
+// artifact archived for the revision using the content configuration, e.g.
+// the ignore patterns or a checksum of all the configuration affecting the
+// content. A short hash of a non-empty content configuration is included in
+// the name, so that artifacts archived for the same revision with different
+// content do not collide.
+func artifactFileName(revision, contentConfig, ext string) string {
+	if contentConfig == "" {
+		return revision + ext
+	}
+	sum := sha256.Sum256([]byte(contentConfig))
+	return fmt.Sprintf("%s.%x%s", revision, sum[:4], ext)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"io"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+)
+
+// ArtifactStorage is a backend storing the content of artifacts.
+type ArtifactStorage interface {
+	// Put stores the io.Reader contents as the content of the given
+	// artifact. If successful, it sets the checksum, digest, size and last
+	// update time on the artifact.
+	Put(artifact *sourcev1.Artifact, reader io.Reader) error
+	// Get returns a reader for the content of the given artifact. The
+	// caller must close the returned reader.
+	Get(artifact sourcev1.Artifact) (io.ReadCloser, error)
+	// Delete removes the content of the given artifact. Deleting an
+	// artifact which does not exist is not an error.
+	Delete(artifact sourcev1.Artifact) error
+	// Exists returns if the content of the given artifact is present.
+	Exists(artifact sourcev1.Artifact) bool
+	// URL returns the URL at which the content of the given artifact is
+	// served.
+	URL(artifact sourcev1.Artifact) string
+}
+
+// LocalArtifactStorage is an ArtifactStorage which is backed by the local
+// filesystem. Next to the content of artifacts, it provides the operations
+// the reconcilers depend on to produce artifacts from local files, and to
+// serve them through the file server.
+type LocalArtifactStorage interface {
+	ArtifactStorage
+
+	// NewArtifactFor returns a new artifact for the given object, with the
+	// path and URL set.
+	NewArtifactFor(kind string, metadata metav1.Object, revision, fileName string) sourcev1.Artifact
+	// SetHostname replaces the host of the given URL with the hostname of
+	// the storage.
+	SetHostname(URL string) string
+	// MkdirAll creates the directory of the given artifact.
+	MkdirAll(artifact sourcev1.Artifact) error
+	// RemoveAll removes the directory of the given artifact, and returns
+	// the removed path.
+	RemoveAll(artifact sourcev1.Artifact) (string, error)
+	// GarbageCollect removes the artifacts next to the given artifact which
+	// are no longer retained, and returns the removed paths.
+	GarbageCollect(ctx context.Context, artifact sourcev1.Artifact, timeout time.Duration, opts ...GarbageCollectOption) ([]string, error)
+	// Archive stores a tarball of the given directory as the content of the
+	// given artifact.
+	Archive(artifact *sourcev1.Artifact, dir string, filter ArchiveFileFilter, opts ...ArchiveOption) error
+	// CopyFromPath stores the file at the given path as the content of the
+	// given artifact.
+	CopyFromPath(artifact *sourcev1.Artifact, path string) error
+	// CopyToPath extracts the (sub)path of the given artifact to the given
+	// path.
+	CopyToPath(artifact *sourcev1.Artifact, subPath, toPath string) error
+	// Symlink creates or updates the symlink with the given name in the
+	// directory of the artifact to point to it, and returns its URL.
+	Symlink(artifact sourcev1.Artifact, linkName string) (string, error)
+	// Lock acquires the lock of the given artifact.
+	Lock(artifact sourcev1.Artifact) (unlock func(), err error)
+	// LocalPath returns the local path of the given artifact.
+	LocalPath(artifact sourcev1.Artifact) string
+}
+
+var _ LocalArtifactStorage = &Storage{}
@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	intdigest "github.com/fluxcd/source-controller/internal/digest"
+)
+
+// memoryStorage is an ArtifactStorage keeping the content of artifacts in
+// memory.
+type memoryStorage struct {
+	mu      sync.Mutex
+	content map[string][]byte
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{content: map[string][]byte{}}
+}
+
+func (m *memoryStorage) Put(artifact *sourcev1.Artifact, reader io.Reader) error {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.content[artifact.Path] = b
+	m.mu.Unlock()
+
+	h := newHash()
+	h.Write(b)
+	size := int64(len(b))
+	artifact.Checksum = fmt.Sprintf("%x", h.Sum(nil))
+	artifact.Digest = intdigest.Canonical.FromBytes(b).String()
+	artifact.LastUpdateTime = metav1.Now()
+	artifact.Size = &size
+	return nil
+}
+
+func (m *memoryStorage) Get(artifact sourcev1.Artifact) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.content[artifact.Path]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (m *memoryStorage) Delete(artifact sourcev1.Artifact) error {
+	m.mu.Lock()
+	delete(m.content, artifact.Path)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryStorage) Exists(artifact sourcev1.Artifact) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.content[artifact.Path]
+	return ok
+}
+
+func (m *memoryStorage) URL(artifact sourcev1.Artifact) string {
+	return "memory://" + artifact.Path
+}
+
+func TestArtifactStorage(t *testing.T) {
+	local, err := NewStorage(t.TempDir(), "hostname", time.Minute, 2)
+	if err != nil {
+		t.Fatalf("error while bootstrapping storage: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		storage ArtifactStorage
+		wantURL string
+	}{
+		{
+			name:    "local",
+			storage: local,
+			wantURL: "http://hostname/gitrepository/default/podinfo/artifact.tar.gz",
+		},
+		{
+			name:    "memory",
+			storage: newMemoryStorage(),
+			wantURL: "memory://gitrepository/default/podinfo/artifact.tar.gz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			artifact := sourcev1.Artifact{
+				Path: "gitrepository/default/podinfo/artifact.tar.gz",
+			}
+			content := []byte("artifact content")
+			g.Expect(tt.storage.Exists(artifact)).To(BeFalse())
+			_, err := tt.storage.Get(artifact)
+			g.Expect(err).To(MatchError(fs.ErrNotExist))
+
+			g.Expect(tt.storage.Put(&artifact, bytes.NewReader(content))).To(Succeed())
+			g.Expect(artifact.Digest).To(Equal(intdigest.Canonical.FromBytes(content).String()))
+			g.Expect(artifact.Size).ToNot(BeNil())
+			g.Expect(*artifact.Size).To(BeEquivalentTo(len(content)))
+			g.Expect(tt.storage.Exists(artifact)).To(BeTrue())
+			g.Expect(tt.storage.URL(artifact)).To(Equal(tt.wantURL))
+
+			r, err := tt.storage.Get(artifact)
+			g.Expect(err).ToNot(HaveOccurred())
+			got, err := io.ReadAll(r)
+			g.Expect(r.Close()).To(Succeed())
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(content))
+
+			g.Expect(tt.storage.Delete(artifact)).To(Succeed())
+			g.Expect(tt.storage.Exists(artifact)).To(BeFalse())
+			_, err = tt.storage.Get(artifact)
+			g.Expect(err).To(MatchError(fs.ErrNotExist))
+			g.Expect(tt.storage.Delete(artifact)).To(Succeed())
+		})
+	}
+}
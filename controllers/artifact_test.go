@@ -18,6 +18,8 @@ package controllers
 
 import (
 	"testing"
+
+	. "github.com/onsi/gomega"
 )
 
 func Test_artifactSet_Diff(t *testing.T) {
@@ -122,3 +124,17 @@ func Test_artifactSet_Diff(t *testing.T) {
 		})
 	}
 }
+
+func Test_artifactFileName(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(artifactFileName("revision", "", ".tar.gz")).To(Equal("revision.tar.gz"))
+
+	txt := artifactFileName("revision", "!**.txt\n", ".tar.gz")
+	g.Expect(txt).To(MatchRegexp(`^revision\.[0-9a-f]{8}\.tar\.gz$`))
+	g.Expect(artifactFileName("revision", "!**.txt\n", ".tar.gz")).To(Equal(txt))
+
+	yaml := artifactFileName("revision", "!**.yaml\n", ".tar.gz")
+	g.Expect(yaml).To(MatchRegexp(`^revision\.[0-9a-f]{8}\.tar\.gz$`))
+	g.Expect(yaml).ToNot(Equal(txt))
+}
@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/fluxcd/source-controller/pkg/azure"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 	corev1 "k8s.io/api/core/v1"
@@ -47,13 +48,20 @@ import (
 	"github.com/fluxcd/pkg/runtime/conditions"
 	helper "github.com/fluxcd/pkg/runtime/controller"
 	"github.com/fluxcd/pkg/runtime/events"
+	"github.com/fluxcd/pkg/runtime/logger"
 	"github.com/fluxcd/pkg/runtime/patch"
 	"github.com/fluxcd/pkg/runtime/predicates"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	"github.com/fluxcd/source-controller/internal/cosign"
 	serror "github.com/fluxcd/source-controller/internal/error"
+	"github.com/fluxcd/source-controller/internal/fetch"
+	"github.com/fluxcd/source-controller/internal/limit"
+	"github.com/fluxcd/source-controller/internal/progress"
 	sreconcile "github.com/fluxcd/source-controller/internal/reconcile"
 	"github.com/fluxcd/source-controller/internal/reconcile/summarize"
+	"github.com/fluxcd/source-controller/internal/tracing"
+	"github.com/fluxcd/source-controller/internal/util"
 	"github.com/fluxcd/source-controller/pkg/gcp"
 	"github.com/fluxcd/source-controller/pkg/minio"
 	"github.com/fluxcd/source-controller/pkg/sourceignore"
@@ -71,6 +79,16 @@ import (
 // -> s > 100
 const maxConcurrentBucketFetches = 100
 
+// bucketSignatureSuffix is the suffix of the key of the sidecar object
+// holding the detached signature of an object, when the verification of
+// signatures is enabled for a Bucket.
+const bucketSignatureSuffix = ".sig"
+
+// bucketCertificateSuffix is the suffix of the key of the sidecar object
+// holding the signing certificate of a signature object, when the max age
+// of signatures is enforced for a Bucket.
+const bucketCertificateSuffix = ".pem"
+
 // bucketReadyCondition contains the information required to summarize a
 // v1beta2.Bucket Ready Condition.
 var bucketReadyCondition = summarize.Conditions{
@@ -80,6 +98,7 @@ var bucketReadyCondition = summarize.Conditions{
 		sourcev1.FetchFailedCondition,
 		sourcev1.ArtifactOutdatedCondition,
 		sourcev1.ArtifactInStorageCondition,
+		sourcev1.SourceVerifiedCondition,
 		meta.ReadyCondition,
 		meta.ReconcilingCondition,
 		meta.StalledCondition,
@@ -89,6 +108,7 @@ var bucketReadyCondition = summarize.Conditions{
 		sourcev1.FetchFailedCondition,
 		sourcev1.ArtifactOutdatedCondition,
 		sourcev1.ArtifactInStorageCondition,
+		sourcev1.SourceVerifiedCondition,
 		meta.StalledCondition,
 		meta.ReconcilingCondition,
 	},
@@ -118,8 +138,21 @@ type BucketReconciler struct {
 	kuberecorder.EventRecorder
 	helper.Metrics
 
-	Storage        *Storage
+	Storage        LocalArtifactStorage
 	ControllerName string
+
+	// ObjectCachePath is the path of the directory in which the objects of
+	// Buckets are cached between reconciliations. Caching is disabled when
+	// empty.
+	ObjectCachePath string
+
+	// ArtifactManifests defines if a manifest of the archived files is
+	// written alongside the artifact.
+	ArtifactManifests bool
+
+	// FetchRecorder records a fetch.Result for every fetch of the source,
+	// if set.
+	FetchRecorder fetch.Recorder
 }
 
 type BucketReconcilerOptions struct {
@@ -138,10 +171,10 @@ type BucketProvider interface {
 	// It returns the etag of the successfully fetched file, or any error.
 	FGetObject(ctx context.Context, bucketName, objectKey, targetPath string) (etag string, err error)
 	// VisitObjects iterates over the items in the provided object storage
-	// bucket, calling visit for every item.
+	// bucket, calling visit for every item with its size in bytes.
 	// If the underlying client or the visit callback returns an error,
 	// it returns early.
-	VisitObjects(ctx context.Context, bucketName string, visit func(key, etag string) error) error
+	VisitObjects(ctx context.Context, bucketName string, prefix string, visit func(key, etag string, size int64) error) error
 	// ObjectIsNotFound returns true if the given error indicates an object
 	// could not be found.
 	ObjectIsNotFound(error) bool
@@ -337,7 +370,7 @@ func (r *BucketReconciler) reconcile(ctx context.Context, obj *sourcev1.Bucket,
 	}
 
 	// Create temp working dir
-	tmpDir, err := os.MkdirTemp("", fmt.Sprintf("%s-%s-%s-", obj.Kind, obj.Namespace, obj.Name))
+	tmpDir, err := util.MkdirTemp(fmt.Sprintf("%s-%s-%s-", obj.Kind, obj.Namespace, obj.Name))
 	if err != nil {
 		e := &serror.Event{
 			Err:    fmt.Errorf("failed to create temporary working directory: %w", err),
@@ -431,7 +464,7 @@ func (r *BucketReconciler) reconcileStorage(ctx context.Context, obj *sourcev1.B
 	_ = r.garbageCollect(ctx, obj)
 
 	// Determine if the advertised artifact is still in storage
-	if artifact := obj.GetArtifact(); artifact != nil && !r.Storage.ArtifactExist(*artifact) {
+	if artifact := obj.GetArtifact(); artifact != nil && !r.Storage.Exists(*artifact) {
 		obj.Status.Artifact = nil
 		obj.Status.URL = ""
 		// Remove the condition as the artifact doesn't exist.
@@ -447,7 +480,7 @@ func (r *BucketReconciler) reconcileStorage(ctx context.Context, obj *sourcev1.B
 
 	// Always update URLs to ensure hostname is up-to-date
 	// TODO(hidde): we may want to send out an event only if we notice the URL has changed
-	r.Storage.SetArtifactURL(obj.GetArtifact())
+	obj.Status.Artifact.URL = r.Storage.URL(*obj.GetArtifact())
 	obj.Status.URL = r.Storage.SetHostname(obj.Status.URL)
 
 	return sreconcile.ResultSuccess, nil
@@ -458,7 +491,29 @@ func (r *BucketReconciler) reconcileStorage(ctx context.Context, obj *sourcev1.B
 // When a SecretRef is defined, it attempts to fetch the Secret before calling
 // the provider. If this fails, it records v1beta2.FetchFailedCondition=True on
 // the object and returns early.
-func (r *BucketReconciler) reconcileSource(ctx context.Context, obj *sourcev1.Bucket, index *etagIndex, dir string) (sreconcile.Result, error) {
+func (r *BucketReconciler) reconcileSource(ctx context.Context, obj *sourcev1.Bucket, index *etagIndex, dir string) (_ sreconcile.Result, retErr error) {
+	// Record the fetch of the source
+	start := time.Now()
+	defer func() {
+		result := fetch.Result{
+			Kind:      sourcev1.BucketKind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			URL:       fetch.RedactURL(fmt.Sprintf("%s/%s", obj.Spec.Endpoint, obj.Spec.BucketName)),
+		}
+		if retErr == nil {
+			if revision, err := index.Revision(); err == nil {
+				result.Revision = revision
+				result.Digest = revision
+				result.FromCache = obj.GetArtifact().HasRevision(revision)
+			}
+			if size, err := fetch.DirSize(dir); err == nil {
+				result.Size = size
+			}
+		}
+		fetch.Record(ctx, r.FetchRecorder, result, start, retErr)
+	}()
+
 	secret, err := r.getBucketSecret(ctx, obj)
 	if err != nil {
 		e := &serror.Event{Err: err, Reason: sourcev1.AuthenticationFailedReason}
@@ -476,7 +531,15 @@ func (r *BucketReconciler) reconcileSource(ctx context.Context, obj *sourcev1.Bu
 			conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Error())
 			return sreconcile.ResultEmpty, e
 		}
-		if provider, err = gcp.NewClient(ctx, secret); err != nil {
+		if gcp.HasHMACKeys(secret) {
+			// HMAC keys authenticate against the S3-compatible XML API
+			// served on the Endpoint.
+			if provider, err = minio.NewClient(obj, secret); err != nil {
+				e := &serror.Event{Err: err, Reason: "ClientError"}
+				conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Error())
+				return sreconcile.ResultEmpty, e
+			}
+		} else if provider, err = gcp.NewClient(ctx, secret); err != nil {
 			e := &serror.Event{Err: err, Reason: "ClientError"}
 			conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Error())
 			return sreconcile.ResultEmpty, e
@@ -538,18 +601,73 @@ func (r *BucketReconciler) reconcileSource(ctx context.Context, obj *sourcev1.Bu
 		}
 	}()
 
-	if !obj.GetArtifact().HasRevision(revision) {
-		if err = fetchIndexFiles(ctx, provider, obj, index, dir); err != nil {
+	// Fetch the objects of a new revision, or of the current revision if
+	// its signatures have not been verified yet.
+	if !obj.GetArtifact().HasRevision(revision) || needsVerification(obj) {
+		cache := r.objectCache(ctx, obj)
+		onProgress := func(p progress.Progress) {
+			ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("fetching objects from bucket",
+				"objects", p.ObjectsDone, "total", p.ObjectsTotal, "bytes", p.BytesTransferred)
+		}
+		if err = fetchIndexFiles(ctx, provider, obj, index, dir, cache, onProgress); err != nil {
 			e := &serror.Event{Err: err, Reason: sourcev1.BucketOperationFailedReason}
 			conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Error())
 			return sreconcile.ResultEmpty, e
 		}
+		if cache != nil {
+			if err = cache.Prune(index); err == nil {
+				err = cache.Save()
+			}
+			if err != nil {
+				ctrl.LoggerFrom(ctx).Error(err, "failed to update object cache")
+			}
+		}
+		if result, err := r.verifySignatures(ctx, obj, index, dir); err != nil {
+			return result, err
+		}
+	} else if obj.Spec.Verification == nil {
+		conditions.Delete(obj, sourcev1.SourceVerifiedCondition)
 	}
 
 	conditions.Delete(obj, sourcev1.FetchFailedCondition)
 	return sreconcile.ResultSuccess, nil
 }
 
+// needsVerification returns if a verification is specified on the object,
+// and the v1beta2.SourceVerifiedCondition is not True for its current
+// generation. As the signatures are verified for every new revision, this
+// is the case if the verification was added or changed since.
+func needsVerification(obj *sourcev1.Bucket) bool {
+	if obj.Spec.Verification == nil {
+		return false
+	}
+	if !conditions.IsTrue(obj, sourcev1.SourceVerifiedCondition) {
+		return true
+	}
+	return conditions.Get(obj, sourcev1.SourceVerifiedCondition).ObservedGeneration != obj.Generation
+}
+
+// objectCache returns the bucketObjectCache for the object, or nil if no
+// ObjectCachePath is configured or the cache could not be loaded.
+func (r *BucketReconciler) objectCache(ctx context.Context, obj *sourcev1.Bucket) *bucketObjectCache {
+	if r.ObjectCachePath == "" {
+		return nil
+	}
+	source := fmt.Sprintf("%s/%s/%s/%s", obj.Spec.Provider, obj.Spec.Endpoint, obj.Spec.BucketName, obj.Spec.Prefix)
+	cache, err := loadBucketObjectCache(r.objectCacheDir(obj), source)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to load object cache, continuing without")
+		return nil
+	}
+	return cache
+}
+
+// objectCacheDir returns the directory of the bucketObjectCache for the
+// object.
+func (r *BucketReconciler) objectCacheDir(obj *sourcev1.Bucket) string {
+	return filepath.Join(r.ObjectCachePath, obj.GetNamespace(), obj.GetName())
+}
+
 // reconcileArtifact archives a new Artifact to the Storage, if the current
 // (Status) data on the object does not match the given.
 //
@@ -570,7 +688,11 @@ func (r *BucketReconciler) reconcileArtifact(ctx context.Context, obj *sourcev1.
 	}
 
 	// Create artifact
-	artifact := r.Storage.NewArtifactFor(obj.Kind, obj, revision, fmt.Sprintf("%s.tar.gz", revision))
+	var filterConfig string
+	if obj.Spec.Ignore != nil {
+		filterConfig = *obj.Spec.Ignore
+	}
+	artifact := r.Storage.NewArtifactFor(obj.Kind, obj, revision, artifactFileName(revision, filterConfig, ".tar.gz"))
 
 	// Set the ArtifactInStorageCondition if there's no drift.
 	defer func() {
@@ -623,7 +745,11 @@ func (r *BucketReconciler) reconcileArtifact(ctx context.Context, obj *sourcev1.
 	defer unlock()
 
 	// Archive directory to storage
-	if err := r.Storage.Archive(&artifact, dir, nil); err != nil {
+	var archiveOpts []ArchiveOption
+	if r.ArtifactManifests {
+		archiveOpts = append(archiveOpts, WithManifest())
+	}
+	if err := r.Storage.Archive(&artifact, dir, nil, archiveOpts...); err != nil {
 		e := &serror.Event{
 			Err:    fmt.Errorf("unable to archive artifact to storage: %s", err),
 			Reason: sourcev1.ArchiveOperationFailedReason,
@@ -658,6 +784,13 @@ func (r *BucketReconciler) reconcileDelete(ctx context.Context, obj *sourcev1.Bu
 		return sreconcile.ResultEmpty, err
 	}
 
+	// Remove the object cache
+	if r.ObjectCachePath != "" {
+		if err := os.RemoveAll(r.objectCacheDir(obj)); err != nil {
+			ctrl.LoggerFrom(ctx).Error(err, "failed to remove object cache")
+		}
+	}
+
 	// Remove our finalizer from the list
 	controllerutil.RemoveFinalizer(obj, sourcev1.SourceFinalizer)
 
@@ -718,6 +851,176 @@ func (r *BucketReconciler) getBucketSecret(ctx context.Context, obj *sourcev1.Bu
 	return secret, nil
 }
 
+// verifySignatures verifies the detached signatures of the objects in the
+// index, which are expected to be fetched to dir, if a verification is
+// specified on the object. Every object must have a sidecar object with the
+// bucketSignatureSuffix, containing a signature valid for one of the public
+// keys in the referenced Secret. If the Secret contains root certificates,
+// the signatures are instead verified with the signing certificates issued
+// by them.
+// If a signature can not be verified or the verification fails, it records
+// v1beta2.SourceVerifiedCondition=False and returns.
+// When successful, it records v1beta2.SourceVerifiedCondition=True.
+// If no verification is specified on the object, the
+// v1beta2.SourceVerifiedCondition Condition is removed.
+func (r *BucketReconciler) verifySignatures(ctx context.Context, obj *sourcev1.Bucket, index *etagIndex, dir string) (sreconcile.Result, error) {
+	if obj.Spec.Verification == nil {
+		conditions.Delete(obj, sourcev1.SourceVerifiedCondition)
+		return sreconcile.ResultSuccess, nil
+	}
+
+	// Get secret with public keys
+	publicKeySecret := types.NamespacedName{
+		Namespace: obj.Namespace,
+		Name:      obj.Spec.Verification.SecretRef.Name,
+	}
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, publicKeySecret, secret); err != nil {
+		e := serror.NewGeneric(
+			fmt.Errorf("cosign public keys secret error: %w", err),
+			"VerificationError",
+		)
+		conditions.MarkFalse(obj, sourcev1.SourceVerifiedCondition, e.Reason, e.Err.Error())
+		return sreconcile.ResultEmpty, e
+	}
+	var publicKeys, roots [][]byte
+	for _, v := range secret.Data {
+		if cosign.IsCertificate(v) {
+			roots = append(roots, v)
+			continue
+		}
+		publicKeys = append(publicKeys, v)
+	}
+
+	var maxAge time.Duration
+	if obj.Spec.Verification.MaxAge != nil {
+		maxAge = obj.Spec.Verification.MaxAge.Duration
+	}
+	// The signing time of a signature can only be trusted if it is
+	// attested by the issuer of the signing certificate.
+	if maxAge > 0 && len(roots) == 0 {
+		e := serror.NewGeneric(
+			fmt.Errorf("cosign public keys secret '%s' contains no root certificates to verify the signing time with", publicKeySecret.String()),
+			"VerificationError",
+		)
+		conditions.MarkFalse(obj, sourcev1.SourceVerifiedCondition, e.Reason, e.Err.Error())
+		return sreconcile.ResultEmpty, e
+	}
+
+	_, span := tracing.Start(ctx, "bucket.verify")
+	err := verifyObjectSignatures(index, dir, publicKeys, roots, maxAge)
+	tracing.End(span, err)
+	if err != nil {
+		e := serror.NewGeneric(
+			fmt.Errorf("signature verification of objects in bucket '%s' failed: %w", obj.Spec.BucketName, err),
+			"InvalidObjectSignature",
+		)
+		conditions.MarkFalse(obj, sourcev1.SourceVerifiedCondition, e.Reason, e.Err.Error())
+		// Return error in the hope the objects or the secret change
+		return sreconcile.ResultEmpty, e
+	}
+
+	conditions.MarkTrue(obj, sourcev1.SourceVerifiedCondition, meta.SucceededReason,
+		"verified signatures of objects in bucket '%s'", obj.Spec.BucketName)
+	r.eventLogf(ctx, obj, events.EventTypeTrace, "VerifiedObjects",
+		"verified signatures of objects in bucket '%s'", obj.Spec.BucketName)
+	return sreconcile.ResultSuccess, nil
+}
+
+// verifyObjectSignatures verifies the signature of every object in the index
+// fetched to dir, with the signature read from the sidecar object with the
+// bucketSignatureSuffix. If roots are given, the signature is verified with
+// the signing certificate read from the sidecar object of the signature with
+// the bucketCertificateSuffix, which must be issued by one of the roots.
+// Otherwise, it is verified with the publicKeys. If maxAge is greater than
+// zero, the signing time attested by the certificate is verified to be
+// within maxAge. Objects are verified in lexical order, and the first
+// failure is returned.
+func verifyObjectSignatures(index *etagIndex, dir string, publicKeys, roots [][]byte, maxAge time.Duration) error {
+	all := make([]string, 0, index.Len())
+	for k := range index.Index() {
+		all = append(all, k)
+	}
+	sort.Strings(all)
+
+	// Sidecar objects are included in the artifact as well, and are
+	// rejected if they do not belong to an object.
+	keys := make([]string, 0, len(all))
+	for _, k := range all {
+		var object string
+		switch {
+		case strings.HasSuffix(k, bucketSignatureSuffix+bucketCertificateSuffix):
+			object = strings.TrimSuffix(k, bucketCertificateSuffix)
+		case strings.HasSuffix(k, bucketSignatureSuffix):
+			object = strings.TrimSuffix(k, bucketSignatureSuffix)
+		default:
+			keys = append(keys, k)
+			continue
+		}
+		if !index.Has(object) {
+			return fmt.Errorf("no object '%s' found for sidecar object '%s'", object, k)
+		}
+	}
+
+	for _, k := range keys {
+		sigKey := k + bucketSignatureSuffix
+		if !index.Has(sigKey) {
+			return fmt.Errorf("no signature object '%s' found for object '%s'", sigKey, k)
+		}
+		sig, err := os.ReadFile(filepath.Join(dir, sigKey))
+		if err != nil {
+			return fmt.Errorf("failed to read signature object '%s': %w", sigKey, err)
+		}
+		if len(roots) == 0 {
+			if err = verifyObjectSignature(filepath.Join(dir, k), sig, publicKeys); err != nil {
+				return fmt.Errorf("invalid signature for object '%s': %w", k, err)
+			}
+			continue
+		}
+
+		certKey := sigKey + bucketCertificateSuffix
+		if !index.Has(certKey) {
+			return fmt.Errorf("no certificate object '%s' found for signature '%s'", certKey, sigKey)
+		}
+		cert, err := os.ReadFile(filepath.Join(dir, certKey))
+		if err != nil {
+			return fmt.Errorf("failed to read certificate object '%s': %w", certKey, err)
+		}
+		signedAt, err := verifyObjectCertificate(filepath.Join(dir, k), sig, cert, roots)
+		if err != nil {
+			return fmt.Errorf("invalid signature for object '%s': %w", k, err)
+		}
+		if maxAge <= 0 {
+			continue
+		}
+		if err = cosign.VerifySigningTime(signedAt, maxAge, time.Now()); err != nil {
+			return fmt.Errorf("invalid signing time for object '%s': %w", k, err)
+		}
+	}
+	return nil
+}
+
+// verifyObjectSignature verifies the signature of the file at the given path.
+func verifyObjectSignature(path string, signature []byte, publicKeys [][]byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return cosign.VerifyBlob(f, signature, publicKeys...)
+}
+
+// verifyObjectCertificate verifies the signature of the file at the given
+// path with the signing certificate, and returns the signing time.
+func verifyObjectCertificate(path string, signature, certificate []byte, roots [][]byte) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	return cosign.VerifyBlobWithCertificate(f, signature, certificate, roots...)
+}
+
 // eventLogf records events, and logs at the same time.
 //
 // This log is different from the debug log in the EventRecorder, in the sense
@@ -745,9 +1048,11 @@ func (r *BucketReconciler) annotatedEventLogf(ctx context.Context,
 }
 
 // fetchEtagIndex fetches the current etagIndex for the in the obj specified
-// bucket using the given provider, while filtering them using .sourceignore
-// rules. After fetching an object, the etag value in the index is updated to
+// bucket using the given provider, while filtering them using the rules of
+// the sourceignore.IgnoreFiles. After fetching an object, the etag value in the index is updated to
 // the current value to ensure accuracy.
+// It returns a limit.ExceededError if the listed sizes of the objects exceed
+// limit.DefaultLimits, before any of them is downloaded.
 func fetchEtagIndex(ctx context.Context, provider BucketProvider, obj *sourcev1.Bucket, index *etagIndex, tempDir string) error {
 	ctxTimeout, cancel := context.WithTimeout(ctx, obj.Spec.Timeout.Duration)
 	defer cancel()
@@ -762,47 +1067,137 @@ func fetchEtagIndex(ctx context.Context, provider BucketProvider, obj *sourcev1.
 		return err
 	}
 
-	// Look for file with ignore rules first
-	path := filepath.Join(tempDir, sourceignore.IgnoreFile)
-	if _, err := provider.FGetObject(ctxTimeout, obj.Spec.BucketName, sourceignore.IgnoreFile, path); err != nil {
-		if !provider.ObjectIsNotFound(err) {
+	// Look for files with ignore rules first
+	var ps []gitignore.Pattern
+	for _, name := range sourceignore.IgnoreFiles {
+		path := filepath.Join(tempDir, name)
+		if _, err := provider.FGetObject(ctxTimeout, obj.Spec.BucketName, name, path); err != nil {
+			if !provider.ObjectIsNotFound(err) {
+				return err
+			}
+		}
+		rootps, err := sourceignore.ReadIgnoreFile(path, nil)
+		if err != nil {
 			return err
 		}
+		ps = append(ps, rootps...)
 	}
-	ps, err := sourceignore.ReadIgnoreFile(path, nil)
-	if err != nil {
-		return err
-	}
+
 	// In-spec patterns take precedence
+	var specps []gitignore.Pattern
 	if obj.Spec.Ignore != nil {
-		ps = append(ps, sourceignore.ReadPatterns(strings.NewReader(*obj.Spec.Ignore), nil)...)
+		specps = sourceignore.ReadPatterns(strings.NewReader(*obj.Spec.Ignore), nil)
 	}
-	matcher := sourceignore.NewMatcher(ps)
 
-	// Build up index
-	err = provider.VisitObjects(ctxTimeout, obj.Spec.BucketName, func(key, etag string) error {
-		if strings.HasSuffix(key, "/") || key == sourceignore.IgnoreFile {
+	// List the objects, while collecting the prefixes of the nested ignore
+	// files. The number of objects not ignored by the root and in-spec
+	// patterns is checked against the configured limit while listing, as
+	// the nested ignore files are only known afterwards.
+	maxFiles := limit.DefaultLimits.MaxFiles
+	rootMatcher := sourceignore.NewMatcher(sourceignore.DedupePatterns(append(append([]gitignore.Pattern{}, ps...), specps...)))
+	var listed int64
+	objects := make(map[string]string)
+	sizes := make(map[string]int64)
+	ignoreKeys := make(map[string]bool)
+	ignorePrefixes := make(map[string]bool)
+	err = provider.VisitObjects(ctxTimeout, obj.Spec.BucketName, obj.Spec.Prefix, func(key, etag string, size int64) error {
+		if strings.HasSuffix(key, "/") || sourceignore.IsIgnoreFile(key) {
 			return nil
 		}
-
-		if matcher.Match(strings.Split(key, "/"), false) {
+		if i := strings.LastIndex(key, "/"); i >= 0 && sourceignore.IsIgnoreFile(key[i+1:]) {
+			ignoreKeys[key] = true
+			ignorePrefixes[key[:i]] = true
 			return nil
 		}
-
-		index.Add(key, etag)
+		if !rootMatcher.Match(strings.Split(key, "/"), false) {
+			if listed++; maxFiles > 0 && listed > maxFiles {
+				return &limit.ExceededError{Limit: "files", Max: maxFiles}
+			}
+		}
+		objects[key] = etag
+		sizes[key] = size
 		return nil
 	})
 	if err != nil {
 		return fmt.Errorf("indexation of objects from bucket '%s' failed: %w", obj.Spec.BucketName, err)
 	}
+
+	// Load the nested ignore files in a deterministic order, parents before
+	// their children, so nested patterns override those of the parents.
+	// Within a prefix, the files are loaded in the order of the IgnoreFiles.
+	prefixes := make([]string, 0, len(ignorePrefixes))
+	for prefix := range ignorePrefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		di, dj := strings.Count(prefixes[i], "/"), strings.Count(prefixes[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	for _, prefix := range prefixes {
+		for _, name := range sourceignore.IgnoreFiles {
+			key := prefix + "/" + name
+			if !ignoreKeys[key] {
+				continue
+			}
+			path := filepath.Join(tempDir, key)
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				return err
+			}
+			if _, err := provider.FGetObject(ctxTimeout, obj.Spec.BucketName, key, path); err != nil {
+				if provider.ObjectIsNotFound(err) {
+					continue
+				}
+				return fmt.Errorf("failed to get '%s' ignore file: %w", key, err)
+			}
+			subps, err := sourceignore.ReadIgnoreFile(path, strings.Split(prefix, "/"))
+			if err != nil {
+				return err
+			}
+			ps = append(ps, subps...)
+		}
+	}
+
+	ps = append(ps, specps...)
+	matcher := sourceignore.NewMatcher(sourceignore.DedupePatterns(ps))
+
+	// Build up index, while ensuring the listed objects stay within the
+	// configured limits before any of them is downloaded
+	counter := limit.NewCounter(limit.DefaultLimits)
+	for key, etag := range objects {
+		if matcher.Match(strings.Split(key, "/"), false) {
+			continue
+		}
+
+		index.Add(key, etag)
+		if err := counter.AddFile(sizes[key]); err != nil {
+			return fmt.Errorf("indexation of objects from bucket '%s' failed: %w", obj.Spec.BucketName, err)
+		}
+	}
 	return nil
 }
 
 // fetchIndexFiles fetches the object files for the keys from the given etagIndex
 // using the given provider, and stores them into tempDir. It downloads in
 // parallel, but limited to the maxConcurrentBucketFetches.
+// Objects present in the given bucketObjectCache with an equal etag are
+// copied from the cache instead of being downloaded, while downloaded objects
+// are added to it. The cache may be nil.
+// The fetch is aborted with a limit.ExceededError as soon as the downloaded
+// objects exceed limit.DefaultLimits.
 // Given an index is provided, the bucket is assumed to exist.
-func fetchIndexFiles(ctx context.Context, provider BucketProvider, obj *sourcev1.Bucket, index *etagIndex, tempDir string) error {
+func fetchIndexFiles(ctx context.Context, provider BucketProvider, obj *sourcev1.Bucket, index *etagIndex, tempDir string, cache *bucketObjectCache, onProgress progress.Func) (err error) {
+	counter := limit.NewCounter(limit.DefaultLimits)
+	reporter := progress.NewReporter(index.Len(), progress.DefaultInterval, onProgress)
+	ctx, span := tracing.Start(ctx, "bucket.fetch",
+		tracing.HostKey.String(obj.Spec.Endpoint), tracing.TransportKey.String(obj.Spec.Provider))
+	defer func() {
+		span.SetAttributes(tracing.BytesKey.Int64(counter.Bytes()))
+		tracing.End(span, err)
+	}()
+
 	ctxTimeout, cancel := context.WithTimeout(ctx, obj.Spec.Timeout.Duration)
 	defer cancel()
 
@@ -811,7 +1206,7 @@ func fetchIndexFiles(ctx context.Context, provider BucketProvider, obj *sourcev1
 	//  - https://cloud.google.com/storage/quotas
 	//  - https://docs.aws.amazon.com/general/latest/gr/s3.html
 	// .. so, the limiting factor is this process keeping a small footprint.
-	group, groupCtx := errgroup.WithContext(ctx)
+	group, groupCtx := errgroup.WithContext(ctxTimeout)
 	group.Go(func() error {
 		sem := semaphore.NewWeighted(maxConcurrentBucketFetches)
 		for key, etag := range index.Index() {
@@ -823,19 +1218,38 @@ func fetchIndexFiles(ctx context.Context, provider BucketProvider, obj *sourcev1
 			group.Go(func() error {
 				defer sem.Release(1)
 				localPath := filepath.Join(tempDir, k)
-				etag, err := provider.FGetObject(ctxTimeout, obj.Spec.BucketName, k, localPath)
+				cached, err := cache.Get(k, t, localPath)
 				if err != nil {
-					if provider.ObjectIsNotFound(err) {
-						ctrl.LoggerFrom(ctx).Info(fmt.Sprintf("indexed object '%s' disappeared from '%s' bucket", k, obj.Spec.BucketName))
-						index.Delete(k)
-						return nil
+					return fmt.Errorf("failed to get '%s' object from cache: %w", k, err)
+				}
+				if !cached {
+					etag, err := provider.FGetObject(groupCtx, obj.Spec.BucketName, k, localPath)
+					if err != nil {
+						if provider.ObjectIsNotFound(err) {
+							ctrl.LoggerFrom(ctx).Info(fmt.Sprintf("indexed object '%s' disappeared from '%s' bucket", k, obj.Spec.BucketName))
+							index.Delete(k)
+							reporter.Add(0)
+							return nil
+						}
+						return fmt.Errorf("failed to get '%s' object: %w", k, err)
 					}
-					return fmt.Errorf("failed to get '%s' object: %w", k, err)
+					if t != etag {
+						index.Add(k, etag)
+					}
+					if err = cache.Put(k, etag, localPath); err != nil {
+						return fmt.Errorf("failed to cache '%s' object: %w", k, err)
+					}
+				}
+				fi, err := os.Stat(localPath)
+				if err != nil {
+					return fmt.Errorf("failed to determine size of '%s' object: %w", k, err)
 				}
-				if t != etag {
-					index.Add(k, etag)
+				var transferred int64
+				if !cached {
+					transferred = fi.Size()
 				}
-				return nil
+				reporter.Add(transferred)
+				return counter.AddFile(fi.Size())
 			})
 		}
 		return nil
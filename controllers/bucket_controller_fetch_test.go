@@ -18,9 +18,12 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -28,6 +31,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	"github.com/fluxcd/source-controller/internal/limit"
+	"github.com/fluxcd/source-controller/internal/progress"
 )
 
 type mockBucketObject struct {
@@ -46,7 +51,10 @@ func (m mockBucketClient) BucketExists(_ context.Context, name string) (bool, er
 	return name == m.bucketName, nil
 }
 
-func (m mockBucketClient) FGetObject(_ context.Context, bucket, obj, path string) (string, error) {
+func (m mockBucketClient) FGetObject(ctx context.Context, bucket, obj, path string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	if bucket != m.bucketName {
 		return "", fmt.Errorf("bucket does not exist")
 	}
@@ -64,13 +72,31 @@ func (m mockBucketClient) FGetObject(_ context.Context, bucket, obj, path string
 	return object.etag, nil
 }
 
+// countingBucketClient is a mockBucketClient which counts the number of
+// times each object is fetched.
+type countingBucketClient struct {
+	mockBucketClient
+	mu      *sync.Mutex
+	fetched map[string]int
+}
+
+func (c countingBucketClient) FGetObject(ctx context.Context, bucket, obj, path string) (string, error) {
+	c.mu.Lock()
+	c.fetched[obj]++
+	c.mu.Unlock()
+	return c.mockBucketClient.FGetObject(ctx, bucket, obj, path)
+}
+
 func (m mockBucketClient) ObjectIsNotFound(e error) bool {
 	return e == mockNotFound
 }
 
-func (m mockBucketClient) VisitObjects(_ context.Context, _ string, f func(key, etag string) error) error {
+func (m mockBucketClient) VisitObjects(_ context.Context, _ string, prefix string, f func(key, etag string, size int64) error) error {
 	for key, obj := range m.objects {
-		if err := f(key, obj.etag); err != nil {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if err := f(key, obj.etag, int64(len(obj.data))); err != nil {
 			return err
 		}
 	}
@@ -123,6 +149,72 @@ func Test_fetchEtagIndex(t *testing.T) {
 		assert.Equal(t, index.Len(), 3)
 	})
 
+	t.Run("aborts when exceeding file limit", func(t *testing.T) {
+		tmp := t.TempDir()
+
+		limits := limit.DefaultLimits
+		limit.DefaultLimits = limit.Limits{MaxFiles: 2}
+		defer func() { limit.DefaultLimits = limits }()
+
+		client := mockBucketClient{bucketName: bucketName}
+		client.addObject("foo.yaml", mockBucketObject{data: "foo.yaml", etag: "etag1"})
+		client.addObject("bar.yaml", mockBucketObject{data: "bar.yaml", etag: "etag2"})
+		client.addObject("baz.yaml", mockBucketObject{data: "baz.yaml", etag: "etag3"})
+
+		index := newEtagIndex()
+		err := fetchEtagIndex(context.TODO(), client, bucket.DeepCopy(), index, tmp)
+		var exceededErr *limit.ExceededError
+		assert.Assert(t, errors.As(err, &exceededErr))
+		assert.Equal(t, exceededErr.Limit, "files")
+	})
+
+	t.Run("aborts before downloading when exceeding size limit", func(t *testing.T) {
+		tmp := t.TempDir()
+
+		limits := limit.DefaultLimits
+		limit.DefaultLimits = limit.Limits{MaxBytes: 20}
+		defer func() { limit.DefaultLimits = limits }()
+
+		client := countingBucketClient{
+			mockBucketClient: mockBucketClient{bucketName: bucketName},
+			mu:               &sync.Mutex{},
+			fetched:          map[string]int{},
+		}
+		client.addObject("foo.yaml", mockBucketObject{data: "foo.yaml", etag: "etag1"})
+		client.addObject("bar.yaml", mockBucketObject{data: "bar.yaml", etag: "etag2"})
+		client.addObject("baz.yaml", mockBucketObject{data: "baz.yaml", etag: "etag3"})
+
+		index := newEtagIndex()
+		err := fetchEtagIndex(context.TODO(), client, bucket.DeepCopy(), index, tmp)
+		var exceededErr *limit.ExceededError
+		assert.Assert(t, errors.As(err, &exceededErr))
+		assert.Equal(t, exceededErr.Limit, "bytes")
+		for key := range client.objects {
+			assert.Equal(t, client.fetched[key], 0)
+		}
+	})
+
+	t.Run("does not count ignored objects towards file limit", func(t *testing.T) {
+		tmp := t.TempDir()
+
+		limits := limit.DefaultLimits
+		limit.DefaultLimits = limit.Limits{MaxFiles: 1}
+		defer func() { limit.DefaultLimits = limits }()
+
+		client := mockBucketClient{bucketName: bucketName}
+		client.addObject(".sourceignore", mockBucketObject{etag: "sourceignore1", data: `*.txt`})
+		client.addObject("foo.yaml", mockBucketObject{data: "foo.yaml", etag: "etag1"})
+		client.addObject("foo.txt", mockBucketObject{data: "foo.txt", etag: "etag2"})
+		client.addObject("bar.txt", mockBucketObject{data: "bar.txt", etag: "etag3"})
+
+		index := newEtagIndex()
+		err := fetchEtagIndex(context.TODO(), client, bucket.DeepCopy(), index, tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, index.Len(), 1)
+	})
+
 	t.Run("an error while bucket does not exist", func(t *testing.T) {
 		tmp := t.TempDir()
 
@@ -183,6 +275,59 @@ func Test_fetchEtagIndex(t *testing.T) {
 			t.Error(fmt.Errorf("expected 'foo.txt' index item to exist"))
 		}
 	})
+
+	t.Run("filters with nested .sourceignore rules", func(t *testing.T) {
+		tmp := t.TempDir()
+
+		client := mockBucketClient{bucketName: bucketName}
+		client.addObject(".sourceignore", mockBucketObject{etag: "sourceignore1", data: "*.txt\n*.txt"})
+		client.addObject("foo/.sourceignore", mockBucketObject{etag: "sourceignore2", data: "!keep.txt"})
+		client.addObject("foo/bar/.sourceignore", mockBucketObject{etag: "sourceignore3", data: "*.txt"})
+		client.addObject("foo.txt", mockBucketObject{etag: "etag1", data: "foo.txt"})
+		client.addObject("foo/keep.txt", mockBucketObject{etag: "etag2", data: "foo/keep.txt"})
+		client.addObject("foo/other.txt", mockBucketObject{etag: "etag3", data: "foo/other.txt"})
+		client.addObject("foo/bar/keep.txt", mockBucketObject{etag: "etag4", data: "foo/bar/keep.txt"})
+		client.addObject("foo/bar/baz.yaml", mockBucketObject{etag: "etag5", data: "foo/bar/baz.yaml"})
+
+		index := newEtagIndex()
+		err := fetchEtagIndex(context.TODO(), client, bucket.DeepCopy(), index, tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(filepath.Join(tmp, "foo", "bar", ".sourceignore")); err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, index.Len(), 2)
+		for _, key := range []string{"foo/keep.txt", "foo/bar/baz.yaml"} {
+			if ok := index.Has(key); !ok {
+				t.Error(fmt.Errorf("expected '%s' index item to exist", key))
+			}
+		}
+	})
+
+	t.Run("filters with prefix", func(t *testing.T) {
+		tmp := t.TempDir()
+
+		client := mockBucketClient{bucketName: bucketName}
+		client.addObject("foo/bar.yaml", mockBucketObject{etag: "etag1", data: "foo/bar.yaml"})
+		client.addObject("baz.yaml", mockBucketObject{etag: "etag2", data: "baz.yaml"})
+
+		bucket := bucket.DeepCopy()
+		bucket.Spec.Prefix = "foo/"
+
+		index := newEtagIndex()
+		err := fetchEtagIndex(context.TODO(), client, bucket, index, tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, index.Len(), 1)
+		if ok := index.Has("foo/bar.yaml"); !ok {
+			t.Error(fmt.Errorf("expected 'foo/bar.yaml' index item to exist"))
+		}
+	})
 }
 
 func Test_fetchFiles(t *testing.T) {
@@ -205,7 +350,7 @@ func Test_fetchFiles(t *testing.T) {
 
 		index := client.objectsToEtagIndex()
 
-		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp)
+		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp, nil, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -219,13 +364,54 @@ func Test_fetchFiles(t *testing.T) {
 		}
 	})
 
+	t.Run("reports progress", func(t *testing.T) {
+		tmp := t.TempDir()
+
+		interval := progress.DefaultInterval
+		progress.DefaultInterval = 0
+		defer func() { progress.DefaultInterval = interval }()
+
+		client := mockBucketClient{bucketName: bucketName}
+		var totalBytes int64
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("object-%d.yaml", i)
+			client.addObject(key, mockBucketObject{data: key, etag: fmt.Sprintf("etag%d", i)})
+			totalBytes += int64(len(key))
+		}
+		index := client.objectsToEtagIndex()
+
+		var (
+			mu      sync.Mutex
+			reports []progress.Progress
+		)
+		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp, nil, func(p progress.Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, p)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(reports), 20)
+		for i := 1; i < len(reports); i++ {
+			assert.Assert(t, reports[i].ObjectsDone > reports[i-1].ObjectsDone)
+			assert.Assert(t, reports[i].BytesTransferred >= reports[i-1].BytesTransferred)
+		}
+		assert.DeepEqual(t, reports[len(reports)-1], progress.Progress{
+			ObjectsDone:      20,
+			ObjectsTotal:     20,
+			BytesTransferred: totalBytes,
+		})
+	})
+
 	t.Run("an error while fetching returns an error for the whole procedure", func(t *testing.T) {
 		tmp := t.TempDir()
 
 		client := mockBucketClient{bucketName: bucketName, objects: map[string]mockBucketObject{}}
 		client.objects["error"] = mockBucketObject{}
 
-		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), client.objectsToEtagIndex(), tmp)
+		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), client.objectsToEtagIndex(), tmp, nil, nil)
 		if err == nil {
 			t.Fatal("expected error but got nil")
 		}
@@ -239,7 +425,7 @@ func Test_fetchFiles(t *testing.T) {
 
 		index := newEtagIndex()
 		index.Add("foo.yaml", "etag1")
-		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp)
+		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp, nil, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -258,7 +444,7 @@ func Test_fetchFiles(t *testing.T) {
 		// Does not exist on server
 		index.Add("bar.yaml", "etag2")
 
-		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp)
+		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp, nil, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -267,6 +453,90 @@ func Test_fetchFiles(t *testing.T) {
 		assert.Check(t, !index.Has("bar.yaml"))
 	})
 
+	t.Run("aborts when exceeding size limit", func(t *testing.T) {
+		tmp := t.TempDir()
+
+		limits := limit.DefaultLimits
+		limit.DefaultLimits = limit.Limits{MaxBytes: 25}
+		defer func() { limit.DefaultLimits = limits }()
+
+		client := mockBucketClient{bucketName: bucketName}
+		for i := 0; i < 2*maxConcurrentBucketFetches; i++ {
+			f := fmt.Sprintf("file-%03d", i)
+			client.addObject(f, mockBucketObject{etag: f, data: "0123456789"})
+		}
+		index := client.objectsToEtagIndex()
+
+		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp, nil, nil)
+		var exceededErr *limit.ExceededError
+		assert.Assert(t, errors.As(err, &exceededErr))
+		assert.Equal(t, exceededErr.Limit, "bytes")
+
+		entries, err := os.ReadDir(tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Check(t, len(entries) < index.Len())
+	})
+
+	t.Run("fetches only changed objects with cache", func(t *testing.T) {
+		cacheDir := t.TempDir()
+
+		client := countingBucketClient{
+			mockBucketClient: mockBucketClient{bucketName: bucketName},
+			mu:               &sync.Mutex{},
+			fetched:          map[string]int{},
+		}
+		client.addObject("foo.yaml", mockBucketObject{data: "foo.yaml", etag: "etag1"})
+		client.addObject("bar.yaml", mockBucketObject{data: "bar.yaml", etag: "etag2"})
+		client.addObject("baz.yaml", mockBucketObject{data: "baz.yaml", etag: "etag3"})
+
+		fetch := func() (string, *etagIndex) {
+			tmp := t.TempDir()
+			cache, err := loadBucketObjectCache(cacheDir, "bucket")
+			if err != nil {
+				t.Fatal(err)
+			}
+			index := client.objectsToEtagIndex()
+			if err = fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp, cache, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err = cache.Prune(index); err != nil {
+				t.Fatal(err)
+			}
+			if err = cache.Save(); err != nil {
+				t.Fatal(err)
+			}
+			return tmp, index
+		}
+
+		fetch()
+		assert.DeepEqual(t, client.fetched, map[string]int{"foo.yaml": 1, "bar.yaml": 1, "baz.yaml": 1})
+
+		// Change one object, and delete another
+		client.addObject("foo.yaml", mockBucketObject{data: "changed", etag: "etag4"})
+		delete(client.objects, "bar.yaml")
+
+		tmp, index := fetch()
+		assert.DeepEqual(t, client.fetched, map[string]int{"foo.yaml": 2, "bar.yaml": 1, "baz.yaml": 1})
+		assert.Equal(t, index.Len(), 2)
+
+		b, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, string(b), "changed")
+		b, err = os.ReadFile(filepath.Join(tmp, "baz.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, string(b), "baz.yaml")
+		_, err = os.Stat(filepath.Join(tmp, "bar.yaml"))
+		assert.Check(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(cacheDir, bucketObjectCacheObjectsDir, "bar.yaml"))
+		assert.Check(t, os.IsNotExist(err))
+	})
+
 	t.Run("can fetch more than maxConcurrentFetches", func(t *testing.T) {
 		// this will fail if, for example, the semaphore is not used correctly and blocks
 		tmp := t.TempDir()
@@ -278,7 +548,7 @@ func Test_fetchFiles(t *testing.T) {
 		}
 		index := client.objectsToEtagIndex()
 
-		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp)
+		err := fetchIndexFiles(context.TODO(), client, bucket.DeepCopy(), index, tmp, nil, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
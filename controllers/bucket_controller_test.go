@@ -18,8 +18,17 @@ package controllers
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/url"
 	"os"
@@ -43,6 +52,7 @@ import (
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	"github.com/fluxcd/source-controller/internal/fetch"
 	gcsmock "github.com/fluxcd/source-controller/internal/mock/gcs"
 	s3mock "github.com/fluxcd/source-controller/internal/mock/s3"
 	sreconcile "github.com/fluxcd/source-controller/internal/reconcile"
@@ -437,6 +447,52 @@ func TestBucketReconciler_reconcileSource_generic(t *testing.T) {
 				*conditions.TrueCondition(meta.ReconcilingCondition, "NewRevision", "new upstream revision '9fc2ddfc4a6f44e6c3efee40af36578b9e76d4d930eaf384b8435a0aa0bf7a0f'"),
 			},
 		},
+		{
+			name:       ".flux-ignore and .sourceignore",
+			bucketName: "dummy",
+			bucketObjects: []*s3mock.Object{
+				{
+					Key:          ".flux-ignore",
+					Content:      []byte("ignored/file.txt"),
+					ContentType:  "text/plain",
+					LastModified: time.Now(),
+				},
+				{
+					Key:          ".sourceignore",
+					Content:      []byte("ignored/other.txt"),
+					ContentType:  "text/plain",
+					LastModified: time.Now(),
+				},
+				{
+					Key:          "ignored/file.txt",
+					Content:      []byte("ignored/file.txt"),
+					ContentType:  "text/plain",
+					LastModified: time.Now(),
+				},
+				{
+					Key:          "ignored/other.txt",
+					Content:      []byte("ignored/other.txt"),
+					ContentType:  "text/plain",
+					LastModified: time.Now(),
+				},
+				{
+					Key:          "included/file.txt",
+					Content:      []byte("included/file.txt"),
+					ContentType:  "text/plain",
+					LastModified: time.Now(),
+				},
+			},
+			want: sreconcile.ResultSuccess,
+			assertIndex: &etagIndex{
+				index: map[string]string{
+					"included/file.txt": "5a4bc7048b3301f677fe15b8678be2f8",
+				},
+			},
+			assertConditions: []metav1.Condition{
+				*conditions.TrueCondition(sourcev1.ArtifactOutdatedCondition, "NewRevision", "new upstream revision '9fc2ddfc4a6f44e6c3efee40af36578b9e76d4d930eaf384b8435a0aa0bf7a0f'"),
+				*conditions.TrueCondition(meta.ReconcilingCondition, "NewRevision", "new upstream revision '9fc2ddfc4a6f44e6c3efee40af36578b9e76d4d930eaf384b8435a0aa0bf7a0f'"),
+			},
+		},
 		{
 			name:       "spec.ignore overrides .sourceignore",
 			bucketName: "dummy",
@@ -585,6 +641,404 @@ func TestBucketReconciler_reconcileSource_generic(t *testing.T) {
 	}
 }
 
+func TestBucketReconciler_reconcileSource_verification(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	content := []byte("artifact content")
+	digest := sha256.Sum256(content)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	g.Expect(err).ToNot(HaveOccurred())
+	signature := []byte(base64.StdEncoding.EncodeToString(sig))
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err = x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, &rootKey.PublicKey, rootKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	root, err := x509.ParseCertificate(der)
+	g.Expect(err).ToNot(HaveOccurred())
+	rootCertificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	// certificate returns a signing certificate for the key, issued by the
+	// root or self-signed.
+	certificate := func(notBefore time.Time, selfSigned bool) []byte {
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "signer"},
+			NotBefore:    notBefore,
+			NotAfter:     notBefore.Add(10 * time.Minute),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		}
+		parent, parentKey := root, rootKey
+		if selfSigned {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		g.Expect(err).ToNot(HaveOccurred())
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+
+	tests := []struct {
+		name           string
+		bucketObjects  []*s3mock.Object
+		noVerification bool
+		noRoots        bool
+		maxAge         time.Duration
+		wantErr        bool
+		wantCondition  *metav1.Condition
+	}{
+		{
+			name:    "correctly signed artifact",
+			noRoots: true,
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: content, ContentType: "application/gzip", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig", Content: signature, ContentType: "text/plain", LastModified: time.Now()},
+			},
+			wantCondition: conditions.TrueCondition(sourcev1.SourceVerifiedCondition, meta.SucceededReason, "verified signatures of objects in bucket 'signed'"),
+		},
+		{
+			name:    "tampered artifact",
+			noRoots: true,
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: []byte("tampered content"), ContentType: "application/gzip", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig", Content: signature, ContentType: "text/plain", LastModified: time.Now()},
+			},
+			wantErr:       true,
+			wantCondition: conditions.FalseCondition(sourcev1.SourceVerifiedCondition, "InvalidObjectSignature", "invalid signature for object 'artifact.tar.gz': signature does not match any of the public keys"),
+		},
+		{
+			name:    "artifact without signature",
+			noRoots: true,
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: content, ContentType: "application/gzip", LastModified: time.Now()},
+			},
+			wantErr:       true,
+			wantCondition: conditions.FalseCondition(sourcev1.SourceVerifiedCondition, "InvalidObjectSignature", "no signature object 'artifact.tar.gz.sig' found for object 'artifact.tar.gz'"),
+		},
+		{
+			name: "recently signed artifact",
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: content, ContentType: "application/gzip", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig", Content: signature, ContentType: "text/plain", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig.pem", Content: certificate(time.Now().Add(-time.Minute), false), ContentType: "text/plain", LastModified: time.Now()},
+			},
+			maxAge:        time.Hour,
+			wantCondition: conditions.TrueCondition(sourcev1.SourceVerifiedCondition, meta.SucceededReason, "verified signatures of objects in bucket 'signed'"),
+		},
+		{
+			name: "stale signature",
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: content, ContentType: "application/gzip", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig", Content: signature, ContentType: "text/plain", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig.pem", Content: certificate(time.Now().Add(-2*time.Hour), false), ContentType: "text/plain", LastModified: time.Now()},
+			},
+			maxAge:        time.Hour,
+			wantErr:       true,
+			wantCondition: conditions.FalseCondition(sourcev1.SourceVerifiedCondition, "InvalidObjectSignature", "invalid signing time for object 'artifact.tar.gz': signature signed at"),
+		},
+		{
+			name: "signature without certificate",
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: content, ContentType: "application/gzip", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig", Content: signature, ContentType: "text/plain", LastModified: time.Now()},
+			},
+			maxAge:        time.Hour,
+			wantErr:       true,
+			wantCondition: conditions.FalseCondition(sourcev1.SourceVerifiedCondition, "InvalidObjectSignature", "no certificate object 'artifact.tar.gz.sig.pem' found for signature 'artifact.tar.gz.sig'"),
+		},
+		{
+			name:    "signature without object",
+			noRoots: true,
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: content, ContentType: "application/gzip", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig", Content: signature, ContentType: "text/plain", LastModified: time.Now()},
+				{Key: "unsigned.tar.gz.sig", Content: signature, ContentType: "text/plain", LastModified: time.Now()},
+			},
+			wantErr:       true,
+			wantCondition: conditions.FalseCondition(sourcev1.SourceVerifiedCondition, "InvalidObjectSignature", "no object 'unsigned.tar.gz' found for sidecar object 'unsigned.tar.gz.sig'"),
+		},
+		{
+			name: "replayed signature with self-signed certificate",
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: content, ContentType: "application/gzip", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig", Content: signature, ContentType: "text/plain", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig.pem", Content: certificate(time.Now().Add(-time.Minute), true), ContentType: "text/plain", LastModified: time.Now()},
+			},
+			maxAge:        time.Hour,
+			wantErr:       true,
+			wantCondition: conditions.FalseCondition(sourcev1.SourceVerifiedCondition, "InvalidObjectSignature", "invalid signature for object 'artifact.tar.gz': failed to verify certificate"),
+		},
+		{
+			name:    "max age without root certificates",
+			noRoots: true,
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: content, ContentType: "application/gzip", LastModified: time.Now()},
+				{Key: "artifact.tar.gz.sig", Content: signature, ContentType: "text/plain", LastModified: time.Now()},
+			},
+			maxAge:        time.Hour,
+			wantErr:       true,
+			wantCondition: conditions.FalseCondition(sourcev1.SourceVerifiedCondition, "VerificationError", "contains no root certificates"),
+		},
+		{
+			name: "verification disabled",
+			bucketObjects: []*s3mock.Object{
+				{Key: "artifact.tar.gz", Content: []byte("tampered content"), ContentType: "application/gzip", LastModified: time.Now()},
+			},
+			noVerification: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			server := s3mock.NewServer("signed")
+			server.Objects = tt.bucketObjects
+			server.Start()
+			defer server.Stop()
+			u, err := url.Parse(server.HTTPAddress())
+			g.Expect(err).NotTo(HaveOccurred())
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cosign-keys",
+				},
+				Data: map[string][]byte{
+					"cosign.pub": publicKey,
+				},
+			}
+			if !tt.noRoots {
+				secret.Data["ca.crt"] = rootCertificate
+			}
+			r := &BucketReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Client:        fakeclient.NewClientBuilder().WithScheme(testEnv.Scheme()).WithObjects(secret).Build(),
+				Storage:       testStorage,
+			}
+
+			obj := &sourcev1.Bucket{
+				TypeMeta: metav1.TypeMeta{
+					Kind: sourcev1.BucketKind,
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-bucket",
+				},
+				Spec: sourcev1.BucketSpec{
+					BucketName: "signed",
+					Endpoint:   u.Host,
+					Insecure:   true,
+					Timeout:    &metav1.Duration{Duration: timeout},
+				},
+			}
+			if !tt.noVerification {
+				obj.Spec.Verification = &sourcev1.BucketVerification{
+					Provider:  "cosign",
+					SecretRef: meta.LocalObjectReference{Name: secret.Name},
+				}
+				if tt.maxAge > 0 {
+					obj.Spec.Verification.MaxAge = &metav1.Duration{Duration: tt.maxAge}
+				}
+			}
+
+			_, err = r.reconcileSource(context.TODO(), obj, newEtagIndex(), t.TempDir())
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+
+			got := conditions.Get(obj, sourcev1.SourceVerifiedCondition)
+			if tt.wantCondition == nil {
+				g.Expect(got).To(BeNil())
+				return
+			}
+			g.Expect(got).ToNot(BeNil())
+			g.Expect(got.Status).To(Equal(tt.wantCondition.Status))
+			g.Expect(got.Reason).To(Equal(tt.wantCondition.Reason))
+			g.Expect(got.Message).To(ContainSubstring(tt.wantCondition.Message))
+		})
+	}
+}
+
+func TestBucketReconciler_reconcileSource_verifyCurrentRevision(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	server := s3mock.NewServer("unsigned")
+	server.Objects = []*s3mock.Object{
+		{Key: "artifact.tar.gz", Content: []byte("artifact content"), ContentType: "application/gzip", LastModified: time.Now()},
+	}
+	server.Start()
+	defer server.Stop()
+	u, err := url.Parse(server.HTTPAddress())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cosign-keys",
+		},
+		Data: map[string][]byte{
+			"cosign.pub": publicKey,
+		},
+	}
+	r := &BucketReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Client:        fakeclient.NewClientBuilder().WithScheme(testEnv.Scheme()).WithObjects(secret).Build(),
+		Storage:       testStorage,
+	}
+	obj := &sourcev1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-bucket",
+			Generation: 1,
+		},
+		Spec: sourcev1.BucketSpec{
+			BucketName: "unsigned",
+			Endpoint:   u.Host,
+			Insecure:   true,
+			Timeout:    &metav1.Duration{Duration: timeout},
+		},
+	}
+
+	// Fetch the current revision without verification.
+	index := newEtagIndex()
+	_, err = r.reconcileSource(context.TODO(), obj, index, t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	revision, err := index.Revision()
+	g.Expect(err).ToNot(HaveOccurred())
+	obj.Status.Artifact = &sourcev1.Artifact{Revision: revision}
+
+	// Enabling the verification verifies the objects of the current
+	// revision.
+	obj.Generation = 2
+	obj.Spec.Verification = &sourcev1.BucketVerification{
+		Provider:  "cosign",
+		SecretRef: meta.LocalObjectReference{Name: secret.Name},
+	}
+	_, err = r.reconcileSource(context.TODO(), obj, newEtagIndex(), t.TempDir())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(conditions.IsFalse(obj, sourcev1.SourceVerifiedCondition)).To(BeTrue())
+	g.Expect(conditions.GetMessage(obj, sourcev1.SourceVerifiedCondition)).To(ContainSubstring("no signature object 'artifact.tar.gz.sig' found"))
+}
+
+func TestBucketReconciler_reconcileSource_fetchResult(t *testing.T) {
+	revision := "b4c2a60ce44b67f5b659a95ce4e4cc9e2a86baf13afb72bd397c5384cbc0e479"
+
+	tests := []struct {
+		name          string
+		bucketName    string
+		beforeFunc    func(obj *sourcev1.Bucket)
+		wantRevision  string
+		wantFromCache bool
+		wantSize      int64
+		wantError     bool
+	}{
+		{
+			name:         "fetches the bucket",
+			bucketName:   "dummy",
+			wantRevision: revision,
+			wantSize:     int64(len("test")),
+		},
+		{
+			name:       "reuses the artifact of an unchanged bucket",
+			bucketName: "dummy",
+			beforeFunc: func(obj *sourcev1.Bucket) {
+				obj.Status.Artifact = &sourcev1.Artifact{
+					Revision: revision,
+				}
+			},
+			wantRevision:  revision,
+			wantFromCache: true,
+		},
+		{
+			name:       "records the error of a failed fetch",
+			bucketName: "dummy",
+			beforeFunc: func(obj *sourcev1.Bucket) {
+				obj.Spec.BucketName = "invalid"
+			},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var results []fetch.Result
+			r := &BucketReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Client:        fakeclient.NewClientBuilder().WithScheme(testEnv.Scheme()).Build(),
+				Storage:       testStorage,
+				FetchRecorder: fetch.RecorderFunc(func(_ context.Context, result fetch.Result) {
+					results = append(results, result)
+				}),
+			}
+
+			server := s3mock.NewServer(tt.bucketName)
+			server.Objects = []*s3mock.Object{
+				{
+					Key:          "test.txt",
+					Content:      []byte("test"),
+					ContentType:  "text/plain",
+					LastModified: time.Now(),
+				},
+			}
+			server.Start()
+			defer server.Stop()
+
+			u, err := url.Parse(server.HTTPAddress())
+			g.Expect(err).NotTo(HaveOccurred())
+
+			obj := &sourcev1.Bucket{
+				TypeMeta: metav1.TypeMeta{
+					Kind: sourcev1.BucketKind,
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-bucket",
+					Namespace: "default",
+				},
+				Spec: sourcev1.BucketSpec{
+					BucketName: tt.bucketName,
+					Endpoint:   u.Host,
+					Insecure:   true,
+					Timeout:    &metav1.Duration{Duration: timeout},
+				},
+			}
+			if tt.beforeFunc != nil {
+				tt.beforeFunc(obj)
+			}
+
+			_, _ = r.reconcileSource(context.TODO(), obj, newEtagIndex(), t.TempDir())
+
+			g.Expect(results).To(HaveLen(1))
+			result := results[0]
+			g.Expect(result.Kind).To(Equal(sourcev1.BucketKind))
+			g.Expect(result.Namespace).To(Equal("default"))
+			g.Expect(result.Name).To(Equal("test-bucket"))
+			g.Expect(result.URL).To(Equal(u.Host + "/" + obj.Spec.BucketName))
+			g.Expect(result.Duration.Duration).To(BeNumerically(">", 0))
+			g.Expect(result.Revision).To(Equal(tt.wantRevision))
+			g.Expect(result.Digest).To(Equal(tt.wantRevision))
+			g.Expect(result.FromCache).To(Equal(tt.wantFromCache))
+			g.Expect(result.Size).To(Equal(tt.wantSize))
+			g.Expect(result.Error != "").To(Equal(tt.wantError))
+		})
+	}
+}
+
 func TestBucketReconciler_reconcileSource_gcs(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -0,0 +1,216 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+const (
+	// bucketObjectCacheIndexFile is the name of the file in which the
+	// bucketObjectCache stores its index.
+	bucketObjectCacheIndexFile = "index.json"
+	// bucketObjectCacheObjectsDir is the name of the directory in which the
+	// bucketObjectCache stores the objects.
+	bucketObjectCacheObjectsDir = "objects"
+)
+
+// bucketObjectCache is an on-disk cache of the objects of a Bucket, which
+// allows skipping the download of objects which have not changed since the
+// previous fetch by comparing their etag.
+type bucketObjectCache struct {
+	dir string
+
+	mu    sync.Mutex
+	index bucketObjectCacheIndex
+}
+
+// bucketObjectCacheIndex is the persisted index of a bucketObjectCache.
+type bucketObjectCacheIndex struct {
+	// Source identifies the Bucket the objects were fetched from. The cache is
+	// invalidated when it changes.
+	Source string `json:"source"`
+	// Objects holds the etag of the cached objects, indexed by their key.
+	Objects map[string]string `json:"objects"`
+}
+
+// loadBucketObjectCache loads the bucketObjectCache from the given directory
+// for the given source identifier. If the directory does not contain a cache
+// for the source, an empty cache is returned.
+func loadBucketObjectCache(dir, source string) (*bucketObjectCache, error) {
+	c := &bucketObjectCache{
+		dir: dir,
+		index: bucketObjectCacheIndex{
+			Source:  source,
+			Objects: make(map[string]string),
+		},
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, bucketObjectCacheIndexFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read object cache index: %w", err)
+	}
+	var index bucketObjectCacheIndex
+	if err = json.Unmarshal(b, &index); err != nil || index.Source != source || index.Objects == nil {
+		// Start over with an empty cache
+		if err = os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("failed to reset object cache: %w", err)
+		}
+		return c, nil
+	}
+	c.index = index
+	return c, nil
+}
+
+// Get copies the cached object with the given key to path, if the cached
+// etag equals the given etag. It returns true if the object was copied.
+func (c *bucketObjectCache) Get(key, etag, path string) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+	c.mu.Lock()
+	cached, ok := c.index.Objects[key]
+	c.mu.Unlock()
+	if !ok || etag == "" || cached != etag {
+		return false, nil
+	}
+
+	src, err := c.objectPath(key)
+	if err != nil {
+		return false, err
+	}
+	if err = copyObjectFile(src, path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Put copies the object at path into the cache for the given key and etag.
+func (c *bucketObjectCache) Put(key, etag, path string) error {
+	if c == nil {
+		return nil
+	}
+	dst, err := c.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err = copyObjectFile(path, dst); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.index.Objects[key] = etag
+	c.mu.Unlock()
+	return nil
+}
+
+// Prune removes the objects from the cache which are not in the given
+// etagIndex, e.g. because they were deleted from the Bucket.
+func (c *bucketObjectCache) Prune(index *etagIndex) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.index.Objects {
+		if index.Has(key) {
+			continue
+		}
+		p, err := c.objectPath(key)
+		if err != nil {
+			return err
+		}
+		if err = os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove '%s' object from cache: %w", key, err)
+		}
+		delete(c.index.Objects, key)
+	}
+	return nil
+}
+
+// Save persists the index of the cache.
+func (c *bucketObjectCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	b, err := json.Marshal(c.index)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, bucketObjectCacheIndexFile+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, bucketObjectCacheIndexFile))
+}
+
+// objectPath returns the secure path of the object with the given key in the
+// cache.
+func (c *bucketObjectCache) objectPath(key string) (string, error) {
+	return securejoin.SecureJoin(filepath.Join(c.dir, bucketObjectCacheObjectsDir), key)
+}
+
+// copyObjectFile copies the regular file at src to dst, creating any missing
+// parent directories of dst.
+func copyObjectFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
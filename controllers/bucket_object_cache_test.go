@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_bucketObjectCache(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(t.TempDir(), "object")
+	g.Expect(os.WriteFile(src, []byte("data"), 0o600)).To(Succeed())
+
+	cache, err := loadBucketObjectCache(dir, "source")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cache.Put("path/to/object", "etag1", src)).To(Succeed())
+	g.Expect(cache.Save()).To(Succeed())
+
+	t.Run("get with equal etag", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cache, err := loadBucketObjectCache(dir, "source")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		dst := filepath.Join(t.TempDir(), "path/to/object")
+		ok, err := cache.Get("path/to/object", "etag1", dst)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(os.ReadFile(dst)).To(Equal([]byte("data")))
+	})
+
+	t.Run("get with changed etag", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cache, err := loadBucketObjectCache(dir, "source")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		dst := filepath.Join(t.TempDir(), "object")
+		ok, err := cache.Get("path/to/object", "etag2", dst)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(dst).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("illegal key", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cache, err := loadBucketObjectCache(dir, "source")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(cache.Put("../../escape", "etag", src)).To(Succeed())
+		g.Expect(filepath.Join(dir, "..", "escape")).ToNot(BeAnExistingFile())
+		g.Expect(filepath.Join(dir, bucketObjectCacheObjectsDir, "escape")).To(BeAnExistingFile())
+	})
+
+	t.Run("changed source resets cache", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cache, err := loadBucketObjectCache(dir, "other")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		ok, err := cache.Get("path/to/object", "etag1", filepath.Join(t.TempDir(), "object"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(filepath.Join(dir, bucketObjectCacheObjectsDir)).ToNot(BeADirectory())
+	})
+}
+
+func Test_bucketObjectCache_Prune(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(t.TempDir(), "object")
+	g.Expect(os.WriteFile(src, []byte("data"), 0o600)).To(Succeed())
+
+	cache, err := loadBucketObjectCache(dir, "source")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cache.Put("keep", "etag1", src)).To(Succeed())
+	g.Expect(cache.Put("delete", "etag2", src)).To(Succeed())
+
+	index := newEtagIndex()
+	index.Add("keep", "etag1")
+	g.Expect(cache.Prune(index)).To(Succeed())
+
+	g.Expect(cache.index.Objects).To(Equal(map[string]string{"keep": "etag1"}))
+	g.Expect(filepath.Join(dir, bucketObjectCacheObjectsDir, "keep")).To(BeAnExistingFile())
+	g.Expect(filepath.Join(dir, bucketObjectCacheObjectsDir, "delete")).ToNot(BeAnExistingFile())
+}
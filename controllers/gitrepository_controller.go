@@ -52,8 +52,13 @@ import (
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	serror "github.com/fluxcd/source-controller/internal/error"
 	"github.com/fluxcd/source-controller/internal/features"
+	"github.com/fluxcd/source-controller/internal/fetch"
+	sourcefs "github.com/fluxcd/source-controller/internal/fs"
+	"github.com/fluxcd/source-controller/internal/limit"
 	sreconcile "github.com/fluxcd/source-controller/internal/reconcile"
 	"github.com/fluxcd/source-controller/internal/reconcile/summarize"
+	"github.com/fluxcd/source-controller/internal/tracing"
+	"github.com/fluxcd/source-controller/internal/useragent"
 	"github.com/fluxcd/source-controller/internal/util"
 	"github.com/fluxcd/source-controller/pkg/git"
 	"github.com/fluxcd/source-controller/pkg/git/libgit2/managed"
@@ -114,9 +119,41 @@ type GitRepositoryReconciler struct {
 	kuberecorder.EventRecorder
 	helper.Metrics
 
-	Storage        *Storage
+	Storage        LocalArtifactStorage
 	ControllerName string
 
+	// CloneCachePath is the path of the directory in which the clones of
+	// GitRepositories using the go-git implementation are kept between
+	// reconciliations, to only fetch new objects on the next checkout.
+	// Clones are not kept when empty.
+	CloneCachePath string
+
+	// PreserveSymlinks defines if relative symbolic links within the
+	// repository are archived as symbolic links, instead of being ignored.
+	PreserveSymlinks bool
+
+	// MaxFileSize is the max size in bytes of a file in the artifact, zero
+	// means unlimited. Larger files are skipped if SkipLargeFiles is set,
+	// otherwise archiving fails.
+	MaxFileSize    int64
+	SkipLargeFiles bool
+
+	// ArtifactManifests defines if a manifest of the archived files is
+	// written alongside the artifact.
+	ArtifactManifests bool
+
+	// TimeSource selects the commit timestamp which drives time-based
+	// logic, such as ordering tags with an equal SemVer version.
+	TimeSource git.TimeSource
+
+	// FetchMaxDuration caps the duration of a Git checkout, independent of
+	// the timeout of the object. Zero means unlimited.
+	FetchMaxDuration time.Duration
+
+	// FetchRecorder records a fetch.Result for every fetch of the source,
+	// if set.
+	FetchRecorder fetch.Recorder
+
 	requeueDependency time.Duration
 	features          map[string]bool
 }
@@ -374,7 +411,7 @@ func (r *GitRepositoryReconciler) reconcileStorage(ctx context.Context,
 	_ = r.garbageCollect(ctx, obj)
 
 	// Determine if the advertised artifact is still in storage
-	if artifact := obj.GetArtifact(); artifact != nil && !r.Storage.ArtifactExist(*artifact) {
+	if artifact := obj.GetArtifact(); artifact != nil && !r.Storage.Exists(*artifact) {
 		obj.Status.Artifact = nil
 		obj.Status.URL = ""
 		// Remove the condition as the artifact doesn't exist.
@@ -390,7 +427,7 @@ func (r *GitRepositoryReconciler) reconcileStorage(ctx context.Context,
 
 	// Always update URLs to ensure hostname is up-to-date
 	// TODO(hidde): we may want to send out an event only if we notice the URL has changed
-	r.Storage.SetArtifactURL(obj.GetArtifact())
+	obj.Status.Artifact.URL = r.Storage.URL(*obj.GetArtifact())
 	obj.Status.URL = r.Storage.SetHostname(obj.Status.URL)
 
 	return sreconcile.ResultSuccess, nil
@@ -427,7 +464,27 @@ func (r *GitRepositoryReconciler) reconcileStorage(ctx context.Context,
 // related configurations have changed since last reconciliation. If there's a
 // change, it short-circuits the whole reconciliation with an early return.
 func (r *GitRepositoryReconciler) reconcileSource(ctx context.Context,
-	obj *sourcev1.GitRepository, commit *git.Commit, includes *artifactSet, dir string) (sreconcile.Result, error) {
+	obj *sourcev1.GitRepository, commit *git.Commit, includes *artifactSet, dir string) (_ sreconcile.Result, retErr error) {
+	// Record the fetch of the source
+	start := time.Now()
+	defer func() {
+		result := fetch.Result{
+			Kind:      sourcev1.GitRepositoryKind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			URL:       fetch.RedactURL(obj.Spec.URL),
+		}
+		if len(commit.Hash) > 0 {
+			result.Revision = commit.String()
+			result.Digest = commit.Hash.String()
+			result.FromCache = !git.IsConcreteCommit(*commit)
+			if size, err := fetch.DirSize(dir, ".git"); err == nil {
+				result.Size = size
+			}
+		}
+		fetch.Record(ctx, r.FetchRecorder, result, start, retErr)
+	}()
+
 	// Configure authentication strategy to access the source
 	var authOpts *git.AuthOptions
 	var err error
@@ -463,6 +520,7 @@ func (r *GitRepositoryReconciler) reconcileSource(ctx context.Context,
 		// Return error as the contents of the secret may change
 		return sreconcile.ResultEmpty, e
 	}
+	authOpts.UserAgent = useragent.Get()
 
 	// Fetch the included artifact metadata.
 	artifacts, err := r.fetchIncludes(ctx, obj)
@@ -557,12 +615,13 @@ func (r *GitRepositoryReconciler) reconcileSource(ctx context.Context,
 func (r *GitRepositoryReconciler) reconcileArtifact(ctx context.Context,
 	obj *sourcev1.GitRepository, commit *git.Commit, includes *artifactSet, dir string) (sreconcile.Result, error) {
 
-	// Create potential new artifact with current available metadata
-	artifact := r.Storage.NewArtifactFor(obj.Kind, obj.GetObjectMeta(), commit.String(), fmt.Sprintf("%s.tar.gz", commit.Hash.String()))
-
 	// Calculate the content config checksum.
 	ccc := r.calculateContentConfigChecksum(obj, includes)
 
+	// Create potential new artifact with current available metadata
+	artifact := r.Storage.NewArtifactFor(obj.Kind, obj.GetObjectMeta(), commit.String(),
+		artifactFileName(commit.Hash.String(), ccc, ".tar.gz"))
+
 	// Set the ArtifactInStorageCondition if there's no drift.
 	defer func() {
 		if obj.GetArtifact().HasRevision(artifact.Revision) &&
@@ -631,7 +690,18 @@ func (r *GitRepositoryReconciler) reconcileArtifact(ctx context.Context,
 	}
 
 	// Archive directory to storage
-	if err := r.Storage.Archive(&artifact, dir, SourceIgnoreFilter(ps, ignoreDomain)); err != nil {
+	var archiveOpts []ArchiveOption
+	if r.PreserveSymlinks {
+		archiveOpts = append(archiveOpts, WithPreservedSymlinks())
+	}
+	var report ArchiveReport
+	if r.MaxFileSize > 0 {
+		archiveOpts = append(archiveOpts, WithMaxFileSize(r.MaxFileSize, r.SkipLargeFiles), WithArchiveReport(&report))
+	}
+	if r.ArtifactManifests {
+		archiveOpts = append(archiveOpts, WithManifest())
+	}
+	if err := r.Storage.Archive(&artifact, dir, SourceIgnoreFilter(ps, ignoreDomain), archiveOpts...); err != nil {
 		e := serror.NewGeneric(
 			fmt.Errorf("unable to archive artifact to storage: %w", err),
 			sourcev1.ArchiveOperationFailedReason,
@@ -639,6 +709,11 @@ func (r *GitRepositoryReconciler) reconcileArtifact(ctx context.Context,
 		conditions.MarkTrue(obj, sourcev1.StorageOperationFailedCondition, e.Reason, e.Err.Error())
 		return sreconcile.ResultEmpty, e
 	}
+	if len(report.SkippedFiles) > 0 {
+		r.eventLogf(ctx, obj, corev1.EventTypeWarning, "ArtifactFilesSkipped",
+			"skipped %d file(s) exceeding the max file size of %d bytes: %s",
+			len(report.SkippedFiles), r.MaxFileSize, strings.Join(report.SkippedFiles, ", "))
+	}
 
 	// Record it on the object
 	obj.Status.Artifact = artifact.DeepCopy()
@@ -713,7 +788,7 @@ func (r *GitRepositoryReconciler) reconcileInclude(ctx context.Context,
 func (r *GitRepositoryReconciler) gitCheckout(ctx context.Context,
 	obj *sourcev1.GitRepository, authOpts *git.AuthOptions, dir string, optimized bool) (*git.Commit, error) {
 	// Configure checkout strategy.
-	checkoutOpts := git.CheckoutOptions{RecurseSubmodules: obj.Spec.RecurseSubmodules}
+	checkoutOpts := git.CheckoutOptions{RecurseSubmodules: obj.Spec.RecurseSubmodules, TimeSource: r.TimeSource}
 	if ref := obj.Spec.Reference; ref != nil {
 		checkoutOpts.Branch = ref.Branch
 		checkoutOpts.Commit = ref.Commit
@@ -730,6 +805,16 @@ func (r *GitRepositoryReconciler) gitCheckout(ctx context.Context,
 		}
 	}
 
+	// Git bundles are unbundled by the go-git implementation only
+	if git.IsBundleURL(obj.Spec.URL) && obj.Spec.GitImplementation != sourcev1.GoGitImplementation {
+		e := &serror.Stalling{
+			Err:    fmt.Errorf("git repository URL '%s' is a Git bundle, which is only supported by the '%s' Git implementation", obj.Spec.URL, sourcev1.GoGitImplementation),
+			Reason: sourcev1.URLInvalidReason,
+		}
+		conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Err.Error())
+		return nil, e
+	}
+
 	gitCtx, cancel := context.WithTimeout(ctx, obj.Spec.Timeout.Duration)
 	defer cancel()
 
@@ -765,8 +850,58 @@ func (r *GitRepositoryReconciler) gitCheckout(ctx context.Context,
 		}
 	}
 
-	commit, err := checkoutStrategy.Checkout(gitCtx, dir, obj.Spec.URL, authOpts)
+	// Check out into the kept clone of the object if any, to only fetch new
+	// objects into it.
+	checkoutDir := dir
+	if r.cloneCacheEnabled(obj) {
+		checkoutDir = r.cloneCacheDir(obj)
+		if err = os.MkdirAll(checkoutDir, 0o700); err != nil {
+			e := serror.NewGeneric(
+				fmt.Errorf("failed to create clone cache directory: %w", err),
+				sourcev1.DirCreationFailedReason,
+			)
+			conditions.MarkTrue(obj, sourcev1.StorageOperationFailedCondition, e.Reason, e.Err.Error())
+			return nil, e
+		}
+	}
+
+	// Abort the checkout as soon as the checked out files exceed the
+	// configured limits. The .git directory is not counted toward the max
+	// files, but its size is counted toward the max bytes, to bound the
+	// fetched pack data. This is best-effort, as the directory is polled
+	// while the checkout writes to it
+	var result *git.CheckoutResult
+	err = fetch.WithMaxDuration(gitCtx, r.FetchMaxDuration, func(ctx context.Context) (err error) {
+		ctx, stop := limit.WatchDir(ctx, checkoutDir, limit.DefaultLimits, limit.DefaultWatchInterval, ".git")
+		result, err = git.CheckoutWithResult(ctx, checkoutStrategy, checkoutDir, obj.Spec.URL, authOpts)
+		if exceededErr := stop(); exceededErr != nil {
+			return exceededErr
+		}
+		return err
+	})
 	if err != nil {
+		var exceededErr *limit.ExceededError
+		if errors.As(err, &exceededErr) {
+			e := serror.NewGeneric(
+				fmt.Errorf("failed to verify size of checkout: %w", err),
+				sourcev1.GitOperationFailedReason,
+			)
+			conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Err.Error())
+			return nil, e
+		}
+
+		// An empty repository is expected to be pushed to eventually, wait
+		// for it instead of treating it as a failure.
+		var emptyErr *git.EmptyRepositoryError
+		if errors.As(err, &emptyErr) {
+			e := serror.NewWaiting(
+				fmt.Errorf("remote repository '%s' is empty, waiting for the first push", obj.Spec.URL),
+				sourcev1.EmptyRepositoryReason,
+			)
+			e.RequeueAfter = obj.GetRequeueAfter()
+			conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Err.Error())
+			return nil, e
+		}
 		e := serror.NewGeneric(
 			fmt.Errorf("failed to checkout and determine revision: %w", err),
 			sourcev1.GitOperationFailedReason,
@@ -774,7 +909,48 @@ func (r *GitRepositoryReconciler) gitCheckout(ctx context.Context,
 		conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Err.Error())
 		return nil, e
 	}
-	return commit, nil
+
+	// Confirm the checked out files stay within the configured limits, as
+	// they may have been exceeded after the last check of the watch
+	if err = limit.CheckDir(checkoutDir, limit.DefaultLimits, ".git"); err != nil {
+		e := serror.NewGeneric(
+			fmt.Errorf("failed to verify size of checkout: %w", err),
+			sourcev1.GitOperationFailedReason,
+		)
+		conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Err.Error())
+		return nil, e
+	}
+
+	// Copy the worktree of the kept clone to the working directory, which
+	// also holds the includes.
+	if checkoutDir != dir && git.IsConcreteCommit(*result.Commit) {
+		if err = sourcefs.CopyDirContents(checkoutDir, dir, ".git"); err != nil {
+			e := serror.NewGeneric(
+				fmt.Errorf("failed to copy checkout from clone cache: %w", err),
+				"CopyFailure",
+			)
+			conditions.MarkTrue(obj, sourcev1.StorageOperationFailedCondition, e.Reason, e.Err.Error())
+			return nil, e
+		}
+	}
+	ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("git checkout finished",
+		"ref", result.ResolvedRef, "noop", result.NoOp, "duration", result.Stats.Duration.String(),
+		"receivedObjects", result.Stats.ReceivedObjects, "receivedBytes", result.Stats.ReceivedBytes)
+	return result.Commit, nil
+}
+
+// cloneCacheEnabled returns if the clone of the object is kept in the
+// CloneCachePath. Only go-git clones of remote repositories are kept, as
+// libgit2 does not reuse an existing clone, and Git bundles are local.
+func (r *GitRepositoryReconciler) cloneCacheEnabled(obj *sourcev1.GitRepository) bool {
+	return r.CloneCachePath != "" &&
+		obj.Spec.GitImplementation == sourcev1.GoGitImplementation &&
+		!git.IsBundleURL(obj.Spec.URL)
+}
+
+// cloneCacheDir returns the directory of the kept clone of the object.
+func (r *GitRepositoryReconciler) cloneCacheDir(obj *sourcev1.GitRepository) string {
+	return filepath.Join(r.CloneCachePath, obj.GetNamespace(), obj.GetName())
 }
 
 // fetchIncludes fetches artifact metadata of all the included repos.
@@ -881,7 +1057,10 @@ func (r *GitRepositoryReconciler) verifyCommitSignature(ctx context.Context, obj
 		keyRings = append(keyRings, string(v))
 	}
 	// Verify commit with GPG data from secret
-	if _, err := commit.Verify(keyRings...); err != nil {
+	_, span := tracing.Start(ctx, "git.commit.verify")
+	_, err := commit.Verify(keyRings...)
+	tracing.End(span, err)
+	if err != nil {
 		e := serror.NewGeneric(
 			fmt.Errorf("signature verification of commit '%s' failed: %w", commit.Hash.String(), err),
 			"InvalidCommitSignature",
@@ -908,6 +1087,13 @@ func (r *GitRepositoryReconciler) reconcileDelete(ctx context.Context, obj *sour
 		return sreconcile.ResultEmpty, err
 	}
 
+	// Remove the kept clone
+	if r.CloneCachePath != "" {
+		if err := os.RemoveAll(r.cloneCacheDir(obj)); err != nil {
+			ctrl.LoggerFrom(ctx).Error(err, "failed to remove clone cache")
+		}
+	}
+
 	// Remove our finalizer from the list
 	controllerutil.RemoveFinalizer(obj, sourcev1.SourceFinalizer)
 
@@ -59,6 +59,8 @@ import (
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	serror "github.com/fluxcd/source-controller/internal/error"
 	"github.com/fluxcd/source-controller/internal/features"
+	"github.com/fluxcd/source-controller/internal/fetch"
+	"github.com/fluxcd/source-controller/internal/limit"
 	sreconcile "github.com/fluxcd/source-controller/internal/reconcile"
 	"github.com/fluxcd/source-controller/internal/reconcile/summarize"
 	"github.com/fluxcd/source-controller/pkg/git"
@@ -757,6 +759,236 @@ func TestGitRepositoryReconciler_reconcileSource_checkoutStrategy(t *testing.T)
 	}
 }
 
+func TestGitRepositoryReconciler_reconcileSource_fetchResult(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(server.Root())
+	server.AutoCreate()
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	repoPath := "/test.git"
+	localRepo, err := initGitRepo(server, "testdata/git/repository", git.DefaultBranch, repoPath)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	headRef, err := localRepo.Head()
+	g.Expect(err).NotTo(HaveOccurred())
+	revision := git.DefaultBranch + "/" + headRef.Hash().String()
+	size, err := fetch.DirSize("testdata/git/repository")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name          string
+		url           string
+		beforeFunc    func(obj *sourcev1.GitRepository)
+		wantRevision  string
+		wantFromCache bool
+		wantSize      int64
+		wantError     bool
+	}{
+		{
+			name:         "fetches the repository",
+			url:          server.HTTPAddress() + repoPath,
+			wantRevision: revision,
+			wantSize:     size,
+		},
+		{
+			name: "reuses the artifact of an unchanged repository",
+			url:  server.HTTPAddress() + repoPath,
+			beforeFunc: func(obj *sourcev1.GitRepository) {
+				obj.Status = sourcev1.GitRepositoryStatus{
+					Artifact: &sourcev1.Artifact{
+						Revision: revision,
+						Path:     randStringRunes(10),
+					},
+					ContentConfigChecksum: emptyContentConfigChecksum,
+				}
+			},
+			wantRevision:  revision,
+			wantFromCache: true,
+		},
+		{
+			name:      "records the error of a failed fetch",
+			url:       server.HTTPAddress() + "/missing.git",
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var results []fetch.Result
+			r := &GitRepositoryReconciler{
+				Client:        fakeclient.NewClientBuilder().WithScheme(runtime.NewScheme()).Build(),
+				EventRecorder: record.NewFakeRecorder(32),
+				Storage:       testStorage,
+				features:      features.FeatureGates(),
+				FetchRecorder: fetch.RecorderFunc(func(_ context.Context, result fetch.Result) {
+					results = append(results, result)
+				}),
+			}
+
+			obj := &sourcev1.GitRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "fetch-result",
+					Namespace: "default",
+				},
+				Spec: sourcev1.GitRepositorySpec{
+					Interval:          metav1.Duration{Duration: interval},
+					Timeout:           &metav1.Duration{Duration: timeout},
+					URL:               tt.url,
+					GitImplementation: sourcev1.GoGitImplementation,
+				},
+			}
+			if tt.beforeFunc != nil {
+				tt.beforeFunc(obj)
+			}
+
+			var commit git.Commit
+			var includes artifactSet
+			_, _ = r.reconcileSource(ctx, obj, &commit, &includes, t.TempDir())
+
+			g.Expect(results).To(HaveLen(1))
+			result := results[0]
+			g.Expect(result.Kind).To(Equal(sourcev1.GitRepositoryKind))
+			g.Expect(result.Namespace).To(Equal("default"))
+			g.Expect(result.Name).To(Equal("fetch-result"))
+			g.Expect(result.URL).To(Equal(tt.url))
+			g.Expect(result.Duration.Duration).To(BeNumerically(">", 0))
+			g.Expect(result.Revision).To(Equal(tt.wantRevision))
+			g.Expect(result.FromCache).To(Equal(tt.wantFromCache))
+			g.Expect(result.Size).To(Equal(tt.wantSize))
+			if tt.wantError {
+				g.Expect(result.Error).ToNot(BeEmpty())
+			} else {
+				g.Expect(result.Error).To(BeEmpty())
+				g.Expect(result.Digest).To(Equal(headRef.Hash().String()))
+			}
+		})
+	}
+}
+
+func TestGitRepositoryReconciler_reconcileSource_cloneCache(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(server.Root())
+	server.AutoCreate()
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	repoPath := "/test.git"
+	_, err = initGitRepo(server, "testdata/git/repository", git.DefaultBranch, repoPath)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	r := &GitRepositoryReconciler{
+		Client:         fakeclient.NewClientBuilder().WithScheme(runtime.NewScheme()).Build(),
+		EventRecorder:  record.NewFakeRecorder(32),
+		Storage:        testStorage,
+		CloneCachePath: t.TempDir(),
+		features:       features.FeatureGates(),
+	}
+
+	obj := &sourcev1.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "clone-cache",
+			Namespace: "default",
+		},
+		Spec: sourcev1.GitRepositorySpec{
+			Interval:          metav1.Duration{Duration: interval},
+			Timeout:           &metav1.Duration{Duration: timeout},
+			URL:               server.HTTPAddress() + repoPath,
+			GitImplementation: sourcev1.GoGitImplementation,
+		},
+	}
+	cloneDir := filepath.Join(r.CloneCachePath, obj.Namespace, obj.Name)
+
+	var commit git.Commit
+	var includes artifactSet
+	dir := t.TempDir()
+	got, err := r.reconcileSource(ctx, obj, &commit, &includes, dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal(sreconcile.ResultSuccess))
+	g.Expect(filepath.Join(cloneDir, ".git")).To(BeADirectory())
+	g.Expect(filepath.Join(dir, ".git")).ToNot(BeAnExistingFile())
+	g.Expect(filepath.Join(dir, "foo.txt")).To(BeARegularFile())
+
+	// The kept clone is fetched into, instead of cloned again
+	marker := filepath.Join(cloneDir, ".git", "marker")
+	g.Expect(os.WriteFile(marker, nil, 0o644)).To(Succeed())
+
+	dir = t.TempDir()
+	got, err = r.reconcileSource(ctx, obj, &commit, &includes, dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal(sreconcile.ResultSuccess))
+	g.Expect(marker).To(BeARegularFile())
+	g.Expect(filepath.Join(dir, "foo.txt")).To(BeARegularFile())
+
+	// The kept clone is removed with the object
+	now := metav1.Now()
+	obj.DeletionTimestamp = &now
+	_, err = r.reconcileDelete(ctx, obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cloneDir).ToNot(BeAnExistingFile())
+}
+
+func TestGitRepositoryReconciler_reconcileSource_sizeLimit(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(server.Root())
+	server.AutoCreate()
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	repoPath := "/test.git"
+	_, err = initGitRepo(server, "testdata/git/repository", git.DefaultBranch, repoPath)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	limits := limit.DefaultLimits
+	limit.DefaultLimits = limit.Limits{MaxFiles: 1}
+	defer func() { limit.DefaultLimits = limits }()
+
+	for _, i := range []string{sourcev1.GoGitImplementation, sourcev1.LibGit2Implementation} {
+		t.Run(i, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := &GitRepositoryReconciler{
+				Client:        fakeclient.NewClientBuilder().WithScheme(runtime.NewScheme()).Build(),
+				EventRecorder: record.NewFakeRecorder(32),
+				Storage:       testStorage,
+				features:      features.FeatureGates(),
+			}
+			obj := &sourcev1.GitRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "size-limit",
+					Namespace: "default",
+				},
+				Spec: sourcev1.GitRepositorySpec{
+					Interval:          metav1.Duration{Duration: interval},
+					Timeout:           &metav1.Duration{Duration: timeout},
+					URL:               server.HTTPAddress() + repoPath,
+					GitImplementation: i,
+				},
+			}
+
+			var commit git.Commit
+			var includes artifactSet
+			got, err := r.reconcileSource(ctx, obj, &commit, &includes, t.TempDir())
+			g.Expect(got).To(Equal(sreconcile.ResultEmpty))
+			var exceededErr *limit.ExceededError
+			g.Expect(errors.As(err, &exceededErr)).To(BeTrue())
+			g.Expect(exceededErr.Limit).To(Equal("files"))
+			g.Expect(conditions.IsTrue(obj, sourcev1.FetchFailedCondition)).To(BeTrue())
+		})
+	}
+}
+
 func TestGitRepositoryReconciler_reconcileArtifact(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -829,6 +1061,27 @@ func TestGitRepositoryReconciler_reconcileArtifact(t *testing.T) {
 			afterFunc: func(t *WithT, obj *sourcev1.GitRepository) {
 				t.Expect(obj.GetArtifact()).ToNot(BeNil())
 				t.Expect(obj.GetArtifact().Checksum).To(Equal("11f7f007dce5619bd79e6c57688261058d09f5271e802463ac39f2b9ead7cabd"))
+				t.Expect(obj.GetArtifact().Path).To(HaveSuffix(artifactFileName("revision", obj.Status.ContentConfigChecksum, ".tar.gz")))
+				t.Expect(obj.GetArtifact().Path).ToNot(HaveSuffix("/revision.tar.gz"))
+			},
+			want: sreconcile.ResultSuccess,
+			assertConditions: []metav1.Condition{
+				*conditions.TrueCondition(sourcev1.ArtifactInStorageCondition, meta.SucceededReason, "stored artifact for revision 'main/revision'"),
+			},
+		},
+		{
+			name:     "Included artifacts are taken into account in the file name",
+			dir:      "testdata/git/repository",
+			includes: artifactSet{&sourcev1.Artifact{Revision: "main/revision", Checksum: "some-checksum"}},
+			beforeFunc: func(obj *sourcev1.GitRepository) {
+				obj.Spec.Interval = metav1.Duration{Duration: interval}
+			},
+			afterFunc: func(t *WithT, obj *sourcev1.GitRepository) {
+				t.Expect(obj.GetArtifact()).ToNot(BeNil())
+				withoutIncludes := (&GitRepositoryReconciler{}).calculateContentConfigChecksum(obj, nil)
+				t.Expect(obj.Status.ContentConfigChecksum).ToNot(Equal(withoutIncludes))
+				t.Expect(obj.GetArtifact().Path).To(HaveSuffix(artifactFileName("revision", obj.Status.ContentConfigChecksum, ".tar.gz")))
+				t.Expect(obj.GetArtifact().Path).ToNot(HaveSuffix(artifactFileName("revision", withoutIncludes, ".tar.gz")))
 			},
 			want: sreconcile.ResultSuccess,
 			assertConditions: []metav1.Condition{
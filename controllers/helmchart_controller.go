@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -25,7 +26,6 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	helmgetter "helm.sh/helm/v3/pkg/getter"
@@ -55,18 +55,21 @@ import (
 	"github.com/fluxcd/pkg/runtime/events"
 	"github.com/fluxcd/pkg/runtime/patch"
 	"github.com/fluxcd/pkg/runtime/predicates"
-	"github.com/fluxcd/pkg/untar"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	"github.com/fluxcd/source-controller/internal/cache"
 	serror "github.com/fluxcd/source-controller/internal/error"
+	"github.com/fluxcd/source-controller/internal/fetch"
 	"github.com/fluxcd/source-controller/internal/helm/chart"
 	"github.com/fluxcd/source-controller/internal/helm/getter"
 	"github.com/fluxcd/source-controller/internal/helm/registry"
 	"github.com/fluxcd/source-controller/internal/helm/repository"
 	sreconcile "github.com/fluxcd/source-controller/internal/reconcile"
 	"github.com/fluxcd/source-controller/internal/reconcile/summarize"
+	sourcetar "github.com/fluxcd/source-controller/internal/tar"
+	"github.com/fluxcd/source-controller/internal/useragent"
 	"github.com/fluxcd/source-controller/internal/util"
+	"github.com/fluxcd/source-controller/pkg/git"
 )
 
 // helmChartReadyCondition contains all the conditions information
@@ -113,6 +116,7 @@ var helmChartFailConditions = []string{
 // +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=helmcharts/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=helmcharts/finalizers,verbs=get;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 
 // HelmChartReconciler reconciles a HelmChart object
 type HelmChartReconciler struct {
@@ -121,13 +125,17 @@ type HelmChartReconciler struct {
 	helper.Metrics
 
 	RegistryClientGenerator RegistryClientGeneratorFunc
-	Storage                 *Storage
+	Storage                 LocalArtifactStorage
 	Getters                 helmgetter.Providers
 	ControllerName          string
 
 	Cache *cache.Cache
 	TTL   time.Duration
 	*cache.CacheRecorder
+
+	// FetchRecorder records a fetch.Result for every fetch of the source,
+	// if set.
+	FetchRecorder fetch.Recorder
 }
 
 func (r *HelmChartReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -347,7 +355,7 @@ func (r *HelmChartReconciler) reconcileStorage(ctx context.Context, obj *sourcev
 	_ = r.garbageCollect(ctx, obj)
 
 	// Determine if the advertised artifact is still in storage
-	if artifact := obj.GetArtifact(); artifact != nil && !r.Storage.ArtifactExist(*artifact) {
+	if artifact := obj.GetArtifact(); artifact != nil && !r.Storage.Exists(*artifact) {
 		obj.Status.Artifact = nil
 		obj.Status.URL = ""
 		// Remove the condition as the artifact doesn't exist.
@@ -363,13 +371,33 @@ func (r *HelmChartReconciler) reconcileStorage(ctx context.Context, obj *sourcev
 
 	// Always update URLs to ensure hostname is up-to-date
 	// TODO(hidde): we may want to send out an event only if we notice the URL has changed
-	r.Storage.SetArtifactURL(obj.GetArtifact())
+	obj.Status.Artifact.URL = r.Storage.URL(*obj.GetArtifact())
 	obj.Status.URL = r.Storage.SetHostname(obj.Status.URL)
 
 	return sreconcile.ResultSuccess, nil
 }
 
 func (r *HelmChartReconciler) reconcileSource(ctx context.Context, obj *sourcev1.HelmChart, build *chart.Build) (_ sreconcile.Result, retErr error) {
+	// Record the fetch of the chart
+	var sourceURL string
+	start := time.Now()
+	defer func() {
+		result := fetch.Result{
+			Kind:      sourcev1.HelmChartKind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			URL:       fetch.RedactURL(sourceURL),
+		}
+		if retErr == nil && build.Complete() {
+			result.Revision = build.Version
+			result.FromCache = obj.GetArtifact() != nil && build.Path == r.Storage.LocalPath(*obj.GetArtifact())
+			if fi, err := os.Stat(build.Path); err == nil {
+				result.Size = fi.Size()
+			}
+		}
+		fetch.Record(ctx, r.FetchRecorder, result, start, retErr)
+	}()
+
 	// Retrieve the source
 	s, err := r.getSource(ctx, obj)
 	if err != nil {
@@ -391,7 +419,7 @@ func (r *HelmChartReconciler) reconcileSource(ctx context.Context, obj *sourcev1
 	}
 
 	// Assert source has an artifact
-	if s.GetArtifact() == nil || !r.Storage.ArtifactExist(*s.GetArtifact()) {
+	if s.GetArtifact() == nil || !r.Storage.Exists(*s.GetArtifact()) {
 		if helmRepo, ok := s.(*sourcev1.HelmRepository); !ok || !helmreg.IsOCI(helmRepo.Spec.URL) {
 			conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, "NoSourceArtifact",
 				"no artifact available for %s source '%s'", obj.Spec.SourceRef.Kind, obj.Spec.SourceRef.Name)
@@ -440,8 +468,10 @@ func (r *HelmChartReconciler) reconcileSource(ctx context.Context, obj *sourcev1
 	// Perform the build for the chart source type
 	switch typedSource := s.(type) {
 	case *sourcev1.HelmRepository:
+		sourceURL = typedSource.Spec.URL
 		return r.buildFromHelmRepository(ctx, obj, typedSource, build)
 	case *sourcev1.GitRepository, *sourcev1.Bucket:
+		sourceURL = typedSource.GetArtifact().URL
 		return r.buildFromTarballArtifact(ctx, obj, *typedSource.GetArtifact(), build)
 	default:
 		// Ending up here should generally not be possible
@@ -468,8 +498,17 @@ func (r *HelmChartReconciler) buildFromHelmRepository(ctx context.Context, obj *
 		helmgetter.WithURL(normalizedURL),
 		helmgetter.WithTimeout(repo.Spec.Timeout.Duration),
 		helmgetter.WithPassCredentialsAll(repo.Spec.PassCredentials),
+		helmgetter.WithUserAgent(useragent.Get()),
+	}
+	secret, err := r.getHelmRepositorySecret(ctx, repo)
+	if err != nil && repo.Spec.Type == sourcev1.HelmRepositoryTypeOCI && apierrs.IsNotFound(err) {
+		// Public OCI registries do not require credentials, attempt an
+		// anonymous pull and only fail if the registry requires them.
+		r.eventLogf(ctx, obj, events.EventTypeTrace, sourcev1.AuthenticationFailedReason,
+			"secret '%s' not found, attempting anonymous pull", repo.Spec.SecretRef.Name)
+		secret, err = nil, nil
 	}
-	if secret, err := r.getHelmRepositorySecret(ctx, repo); secret != nil || err != nil {
+	if secret != nil || err != nil {
 		if err != nil {
 			e := &serror.Event{
 				Err:    fmt.Errorf("failed to get secret '%s': %w", repo.Spec.SecretRef.Name, err),
@@ -552,31 +591,25 @@ func (r *HelmChartReconciler) buildFromHelmRepository(ctx context.Context, obj *
 		}
 
 		// Tell the chart repository to use the OCI client with the configured getter
-		clientOpts = append(clientOpts, helmgetter.WithRegistryClient(registryClient))
-		ociChartRepo, err := repository.NewOCIChartRepository(normalizedURL, repository.WithOCIGetter(r.Getters), repository.WithOCIGetterOptions(clientOpts), repository.WithOCIRegistryClient(registryClient))
+		clientOpts = append(clientOpts, helmgetter.WithRegistryClient(registryClient.Client))
+		// If login options are configured, the chart repository uses them to
+		// login to the registry once an anonymous request is rejected as
+		// unauthorized. The OCIGetter will later retrieve the stored
+		// credentials to pull the chart.
+		ociChartRepo, err := repository.NewOCIChartRepository(normalizedURL, repository.WithOCIGetter(r.Getters),
+			repository.WithOCIGetterOptions(clientOpts), repository.WithOCIRegistryClient(registryClient),
+			repository.WithOCILoginOptions(loginOpts...))
 		if err != nil {
 			return chartRepoConfigErrorReturn(err, obj)
 		}
 		chartRepo = ociChartRepo
-
-		// If login options are configured, use them to login to the registry
-		// The OCIGetter will later retrieve the stored credentials to pull the chart
-		if loginOpts != nil {
-			err = ociChartRepo.Login(loginOpts...)
-			if err != nil {
-				e := &serror.Event{
-					Err:    fmt.Errorf("failed to login to OCI registry: %w", err),
-					Reason: sourcev1.AuthenticationFailedReason,
-				}
-				conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Err.Error())
-				return sreconcile.ResultEmpty, e
-			}
-		}
 	default:
 		httpChartRepo, err := repository.NewChartRepository(normalizedURL, r.Storage.LocalPath(*repo.GetArtifact()), r.Getters, tlsConfig, clientOpts,
 			repository.WithMemoryCache(r.Storage.LocalPath(*repo.GetArtifact()), r.Cache, r.TTL, func(event string) {
 				r.IncCacheEvents(event, obj.Name, obj.Namespace)
-			}))
+			}),
+			repository.WithMirrors(repo.Spec.Mirrors...),
+			repository.WithOCIDownloader(r.ociChartDownloader(repo.Spec.Timeout.Duration)))
 		if err != nil {
 			return chartRepoConfigErrorReturn(err, obj)
 		}
@@ -607,8 +640,9 @@ func (r *HelmChartReconciler) buildFromHelmRepository(ctx context.Context, obj *
 	// Construct the chart builder with scoped configuration
 	cb := chart.NewRemoteBuilder(chartRepo)
 	opts := chart.BuildOptions{
-		ValuesFiles: obj.GetValuesFiles(),
-		Force:       obj.Generation != obj.Status.ObservedGeneration,
+		ValuesFiles:          obj.GetValuesFiles(),
+		ValidateValuesSchema: obj.Spec.ValidateValuesSchema,
+		Force:                obj.Generation != obj.Status.ObservedGeneration,
 	}
 	if artifact := obj.GetArtifact(); artifact != nil {
 		opts.CachedChart = r.Storage.LocalPath(*artifact)
@@ -619,6 +653,9 @@ func (r *HelmChartReconciler) buildFromHelmRepository(ctx context.Context, obj *
 	if len(opts.GetValuesFiles()) > 0 {
 		opts.VersionMetadata = strconv.FormatInt(obj.Generation, 10)
 	}
+	if err := r.configureValuesFrom(ctx, obj, &opts); err != nil {
+		return sreconcile.ResultEmpty, err
+	}
 
 	// Build the chart
 	ref := chart.RemoteReference{Name: obj.Spec.Chart, Version: obj.Spec.Version}
@@ -661,7 +698,7 @@ func (r *HelmChartReconciler) buildFromTarballArtifact(ctx context.Context, obj
 	}
 
 	// Open the tarball artifact file and untar files into working directory
-	f, err := os.Open(r.Storage.LocalPath(source))
+	f, err := r.Storage.Get(source)
 	if err != nil {
 		e := &serror.Event{
 			Err:    fmt.Errorf("failed to open source artifact: %w", err),
@@ -670,7 +707,7 @@ func (r *HelmChartReconciler) buildFromTarballArtifact(ctx context.Context, obj
 		conditions.MarkTrue(obj, sourcev1.FetchFailedCondition, e.Reason, e.Err.Error())
 		return sreconcile.ResultEmpty, e
 	}
-	if _, err = untar.Untar(f, sourceDir); err != nil {
+	if err = sourcetar.Untar(f, sourceDir); err != nil {
 		_ = f.Close()
 		return sreconcile.ResultEmpty, &serror.Event{
 			Err:    fmt.Errorf("artifact untar error: %w", err),
@@ -698,8 +735,9 @@ func (r *HelmChartReconciler) buildFromTarballArtifact(ctx context.Context, obj
 
 	// Configure builder options, including any previously cached chart
 	opts := chart.BuildOptions{
-		ValuesFiles: obj.GetValuesFiles(),
-		Force:       obj.Generation != obj.Status.ObservedGeneration,
+		ValuesFiles:          obj.GetValuesFiles(),
+		ValidateValuesSchema: obj.Spec.ValidateValuesSchema,
+		Force:                obj.Generation != obj.Status.ObservedGeneration,
 	}
 	if artifact := obj.Status.Artifact; artifact != nil {
 		opts.CachedChart = r.Storage.LocalPath(*artifact)
@@ -709,10 +747,11 @@ func (r *HelmChartReconciler) buildFromTarballArtifact(ctx context.Context, obj
 	if obj.Spec.ReconcileStrategy == sourcev1.ReconcileStrategyRevision {
 		rev := source.Revision
 		if obj.Spec.SourceRef.Kind == sourcev1.GitRepositoryKind {
-			// Split the reference by the `/` delimiter which may be present,
-			// and take the last entry which contains the SHA.
-			split := strings.Split(source.Revision, "/")
-			rev = split[len(split)-1]
+			// Take the SHA from the revision, which may be prefixed with
+			// a reference.
+			if _, sha, err := git.ParseRevision(source.Revision); err == nil {
+				rev = sha
+			}
 		}
 		if kind := obj.Spec.SourceRef.Kind; kind == sourcev1.GitRepositoryKind || kind == sourcev1.BucketKind {
 			// The SemVer from the metadata is at times used in e.g. the label metadata for a resource
@@ -737,6 +776,9 @@ func (r *HelmChartReconciler) buildFromTarballArtifact(ctx context.Context, obj
 		}
 		opts.VersionMetadata += strconv.FormatInt(obj.Generation, 10)
 	}
+	if err := r.configureValuesFrom(ctx, obj, &opts); err != nil {
+		return sreconcile.ResultEmpty, err
+	}
 
 	// Build chart
 	cb := chart.NewLocalBuilder(dm)
@@ -949,8 +991,15 @@ func (r *HelmChartReconciler) namespacedChartRepositoryCallback(ctx context.Cont
 			helmgetter.WithURL(normalizedURL),
 			helmgetter.WithTimeout(repo.Spec.Timeout.Duration),
 			helmgetter.WithPassCredentialsAll(repo.Spec.PassCredentials),
+			helmgetter.WithUserAgent(useragent.Get()),
+		}
+		secret, err := r.getHelmRepositorySecret(ctx, repo)
+		if err != nil && helmreg.IsOCI(normalizedURL) && apierrs.IsNotFound(err) {
+			// Public OCI registries do not require credentials, attempt an
+			// anonymous pull and only fail if the registry requires them.
+			secret, err = nil, nil
 		}
-		if secret, err := r.getHelmRepositorySecret(ctx, repo); secret != nil || err != nil {
+		if secret != nil || err != nil {
 			if err != nil {
 				return nil, err
 			}
@@ -983,11 +1032,12 @@ func (r *HelmChartReconciler) namespacedChartRepositoryCallback(ctx context.Cont
 
 			var errs []error
 			// Tell the chart repository to use the OCI client with the configured getter
-			clientOpts = append(clientOpts, helmgetter.WithRegistryClient(registryClient))
+			clientOpts = append(clientOpts, helmgetter.WithRegistryClient(registryClient.Client))
 			ociChartRepo, err := repository.NewOCIChartRepository(normalizedURL, repository.WithOCIGetter(r.Getters),
 				repository.WithOCIGetterOptions(clientOpts),
 				repository.WithOCIRegistryClient(registryClient),
-				repository.WithCredentialsFile(credentialsFile))
+				repository.WithCredentialsFile(credentialsFile),
+				repository.WithOCILoginOptions(loginOpts...))
 			if err != nil {
 				errs = append(errs, fmt.Errorf("failed to create OCI chart repository for HelmRepository '%s': %w", repo.Name, err))
 				// clean up the credentialsFile
@@ -999,21 +1049,11 @@ func (r *HelmChartReconciler) namespacedChartRepositoryCallback(ctx context.Cont
 				return nil, kerrors.NewAggregate(errs)
 			}
 
-			// If login options are configured, use them to login to the registry
-			// The OCIGetter will later retrieve the stored credentials to pull the chart
-			if loginOpts != nil {
-				err = ociChartRepo.Login(loginOpts...)
-				if err != nil {
-					errs = append(errs, fmt.Errorf("failed to login to OCI chart repository for HelmRepository '%s': %w", repo.Name, err))
-					// clean up the credentialsFile
-					errs = append(errs, ociChartRepo.Clear())
-					return nil, kerrors.NewAggregate(errs)
-				}
-			}
-
 			chartRepo = ociChartRepo
 		} else {
-			httpChartRepo, err := repository.NewChartRepository(normalizedURL, "", r.Getters, tlsConfig, clientOpts)
+			httpChartRepo, err := repository.NewChartRepository(normalizedURL, "", r.Getters, tlsConfig, clientOpts,
+				repository.WithMirrors(repo.Spec.Mirrors...),
+				repository.WithOCIDownloader(r.ociChartDownloader(repo.Spec.Timeout.Duration)))
 			if err != nil {
 				return nil, err
 			}
@@ -1035,6 +1075,27 @@ func (r *HelmChartReconciler) namespacedChartRepositoryCallback(ctx context.Cont
 	}
 }
 
+// ociChartDownloader returns a repository.OCIDownloaderFunc which constructs
+// an anonymous repository.OCIChartRepository for the registry of a chart URL
+// in the index of a HelmRepository of the default type. The credentials of
+// the HelmRepository are not used, as they are scoped to its URL.
+func (r *HelmChartReconciler) ociChartDownloader(timeout time.Duration) repository.OCIDownloaderFunc {
+	return func(u *url.URL) (repository.Downloader, error) {
+		registryClient, _, err := r.RegistryClientGenerator(false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct Helm client: %w", err)
+		}
+		clientOpts := []helmgetter.Option{
+			helmgetter.WithTimeout(timeout),
+			helmgetter.WithUserAgent(useragent.Get()),
+			helmgetter.WithRegistryClient(registryClient.Client),
+		}
+		return repository.NewOCIChartRepository(fmt.Sprintf("%s://%s", helmreg.OCIScheme, u.Host),
+			repository.WithOCIGetter(r.Getters), repository.WithOCIGetterOptions(clientOpts),
+			repository.WithOCIRegistryClient(registryClient))
+	}
+}
+
 func (r *HelmChartReconciler) resolveDependencyRepository(ctx context.Context, url string, namespace string) (*sourcev1.HelmRepository, error) {
 	listOpts := []client.ListOption{
 		client.InNamespace(namespace),
@@ -1068,6 +1129,81 @@ func (r *HelmChartReconciler) getHelmRepositorySecret(ctx context.Context, repos
 	return &secret, nil
 }
 
+// configureValuesFrom resolves the HelmChartSpec.ValuesFrom references of
+// the object and sets them on the BuildOptions. A checksum of the resolved
+// values is added to the VersionMetadata, to ensure changes to the data of
+// the references can be noticed by the Artifact consumer.
+// As the references are not watched, a failure to resolve them results in
+// a BuildError with a reason which is retried.
+func (r *HelmChartReconciler) configureValuesFrom(ctx context.Context, obj *sourcev1.HelmChart, opts *chart.BuildOptions) error {
+	sources, err := r.getValuesFrom(ctx, obj)
+	if err != nil {
+		return &chart.BuildError{Reason: chart.ErrValuesReference, Err: err}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	opts.ValuesFrom = sources
+
+	h := sha256.New()
+	for _, s := range sources {
+		h.Write([]byte(s.Origin))
+		h.Write(s.Data)
+	}
+	if opts.VersionMetadata != "" {
+		opts.VersionMetadata += "."
+	}
+	opts.VersionMetadata += fmt.Sprintf("%x", h.Sum(nil))[0:12]
+	return nil
+}
+
+// getValuesFrom returns the data of the HelmChartSpec.ValuesFrom references
+// of the object as a list of chart.ValuesSource, in the order they are
+// specified. References marked as optional are skipped when they do not exist.
+func (r *HelmChartReconciler) getValuesFrom(ctx context.Context, obj *sourcev1.HelmChart) ([]chart.ValuesSource, error) {
+	var sources []chart.ValuesSource
+	for _, ref := range obj.Spec.ValuesFrom {
+		name := types.NamespacedName{
+			Namespace: obj.GetNamespace(),
+			Name:      ref.Name,
+		}
+		origin := fmt.Sprintf("%s/%s", ref.Kind, name)
+
+		var (
+			data  []byte
+			found bool
+			err   error
+		)
+		switch ref.Kind {
+		case "ConfigMap":
+			var cm corev1.ConfigMap
+			if err = r.Client.Get(ctx, name, &cm); err == nil {
+				var v string
+				v, found = cm.Data[ref.GetValuesKey()]
+				data = []byte(v)
+			}
+		case "Secret":
+			var secret corev1.Secret
+			if err = r.Client.Get(ctx, name, &secret); err == nil {
+				data, found = secret.Data[ref.GetValuesKey()]
+			}
+		default:
+			return nil, fmt.Errorf("unsupported values reference kind '%s'", ref.Kind)
+		}
+		if err != nil {
+			if apierrs.IsNotFound(err) && ref.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("could not get values from %s: %w", origin, err)
+		}
+		if !found {
+			return nil, fmt.Errorf("missing key '%s' in %s", ref.GetValuesKey(), origin)
+		}
+		sources = append(sources, chart.ValuesSource{Origin: origin, Data: data})
+	}
+	return sources, nil
+}
+
 func (r *HelmChartReconciler) indexHelmRepositoryByURL(o client.Object) []string {
 	repo, ok := o.(*sourcev1.HelmRepository)
 	if !ok {
@@ -1208,7 +1344,7 @@ func observeChartBuild(obj *sourcev1.HelmChart, build *chart.Build, err error) {
 		}
 
 		switch buildErr.Reason {
-		case chart.ErrChartMetadataPatch, chart.ErrValuesFilesMerge, chart.ErrDependencyBuild, chart.ErrChartPackage:
+		case chart.ErrChartMetadataPatch, chart.ErrValuesFilesMerge, chart.ErrValuesSchema, chart.ErrDependencyBuild, chart.ErrChartPackage:
 			conditions.Delete(obj, sourcev1.FetchFailedCondition)
 			conditions.MarkTrue(obj, sourcev1.BuildFailedCondition, buildErr.Reason.Reason, buildErr.Error())
 		default:
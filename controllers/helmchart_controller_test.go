@@ -53,6 +53,7 @@ import (
 	"github.com/fluxcd/pkg/testserver"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	serror "github.com/fluxcd/source-controller/internal/error"
+	"github.com/fluxcd/source-controller/internal/fetch"
 	"github.com/fluxcd/source-controller/internal/helm/chart"
 	"github.com/fluxcd/source-controller/internal/helm/registry"
 	sreconcile "github.com/fluxcd/source-controller/internal/reconcile"
@@ -515,6 +516,102 @@ func TestHelmChartReconciler_reconcileSource(t *testing.T) {
 	}
 }
 
+func TestHelmChartReconciler_reconcileSource_fetchResult(t *testing.T) {
+	g := NewWithT(t)
+
+	storage, err := NewStorage(t.TempDir(), "example.com", retentionTTL, retentionRecords)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	gitArtifact := &sourcev1.Artifact{
+		Revision: "mock-ref/abcdefg12345678",
+		Path:     "mock.tgz",
+	}
+	g.Expect(storage.Archive(gitArtifact, "testdata/charts", nil)).To(Succeed())
+	storage.SetArtifactURL(gitArtifact)
+
+	tests := []struct {
+		name         string
+		sourceName   string
+		wantRevision string
+		wantURL      string
+		wantError    bool
+	}{
+		{
+			name:         "builds the chart",
+			sourceName:   "gitrepository",
+			wantRevision: "0.1.0",
+			wantURL:      gitArtifact.URL,
+		},
+		{
+			name:       "records the error of a failed build",
+			sourceName: "unavailable",
+			wantError:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var results []fetch.Result
+			r := &HelmChartReconciler{
+				Client: fake.NewClientBuilder().WithRuntimeObjects(&sourcev1.GitRepository{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "gitrepository",
+						Namespace: "default",
+					},
+					Status: sourcev1.GitRepositoryStatus{
+						Artifact: gitArtifact,
+					},
+				}).Build(),
+				EventRecorder: record.NewFakeRecorder(32),
+				Storage:       storage,
+				FetchRecorder: fetch.RecorderFunc(func(_ context.Context, result fetch.Result) {
+					results = append(results, result)
+				}),
+			}
+
+			obj := &sourcev1.HelmChart{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "chart",
+					Namespace: "default",
+				},
+				Spec: sourcev1.HelmChartSpec{
+					Chart: "testdata/charts/helmchart-0.1.0.tgz",
+					SourceRef: sourcev1.LocalHelmChartSourceReference{
+						Name: tt.sourceName,
+						Kind: sourcev1.GitRepositoryKind,
+					},
+				},
+			}
+
+			var b chart.Build
+			defer func() {
+				if b.Path != "" {
+					g.Expect(os.Remove(b.Path)).To(Succeed())
+				}
+			}()
+			_, _ = r.reconcileSource(context.TODO(), obj, &b)
+
+			g.Expect(results).To(HaveLen(1))
+			result := results[0]
+			g.Expect(result.Kind).To(Equal(sourcev1.HelmChartKind))
+			g.Expect(result.Namespace).To(Equal("default"))
+			g.Expect(result.Name).To(Equal("chart"))
+			g.Expect(result.URL).To(Equal(tt.wantURL))
+			g.Expect(result.Duration.Duration).To(BeNumerically(">", 0))
+			g.Expect(result.Revision).To(Equal(tt.wantRevision))
+			g.Expect(result.FromCache).To(BeFalse())
+			if tt.wantError {
+				g.Expect(result.Error).ToNot(BeEmpty())
+				g.Expect(result.Size).To(BeZero())
+			} else {
+				g.Expect(result.Error).To(BeEmpty())
+				g.Expect(result.Size).To(BeNumerically(">", 0))
+			}
+		})
+	}
+}
+
 func TestHelmChartReconciler_buildFromHelmRepository(t *testing.T) {
 	g := NewWithT(t)
 
@@ -926,20 +1023,18 @@ func TestHelmChartReconciler_buildFromOCIHelmRepository(t *testing.T) {
 			},
 		},
 		{
-			name: "Event on unsuccessful secret retrieval",
-			beforeFunc: func(_ *sourcev1.HelmChart, repository *sourcev1.HelmRepository) {
+			name: "Attempts anonymous pull on missing secret",
+			beforeFunc: func(obj *sourcev1.HelmChart, repository *sourcev1.HelmRepository) {
+				obj.Spec.Chart = "invalid"
 				repository.Spec.SecretRef = &meta.LocalObjectReference{
 					Name: "invalid",
 				}
 			},
 			want:    sreconcile.ResultEmpty,
-			wantErr: &serror.Event{Err: errors.New("failed to get secret 'invalid'")},
+			wantErr: &chart.BuildError{Err: errors.New("failed to get chart version for remote reference")},
 			assertFunc: func(g *WithT, obj *sourcev1.HelmChart, build chart.Build) {
 				g.Expect(build.Complete()).To(BeFalse())
-
-				g.Expect(obj.Status.Conditions).To(conditions.MatchConditions([]metav1.Condition{
-					*conditions.TrueCondition(sourcev1.FetchFailedCondition, sourcev1.AuthenticationFailedReason, "failed to get secret 'invalid'"),
-				}))
+				g.Expect(conditions.IsTrue(obj, sourcev1.FetchFailedCondition)).To(BeFalse())
 			},
 		},
 		{
@@ -1439,6 +1534,142 @@ func TestHelmChartReconciler_getHelmRepositorySecret(t *testing.T) {
 	}
 }
 
+func TestHelmChartReconciler_getValuesFrom(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "values",
+			Namespace: "foo",
+		},
+		Data: map[string]string{
+			"values.yaml": "replicaCount: 2",
+			"custom.yaml": "replicaCount: 3",
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "values",
+			Namespace: "foo",
+		},
+		Data: map[string][]byte{
+			"values.yaml": []byte("replicaCount: 4"),
+		},
+	}
+	clientBuilder := fake.NewClientBuilder()
+	clientBuilder.WithObjects(cm, secret)
+
+	r := &HelmChartReconciler{
+		Client: clientBuilder.Build(),
+	}
+
+	tests := []struct {
+		name       string
+		valuesFrom []sourcev1.ValuesReference
+		want       []chart.ValuesSource
+		wantErr    string
+	}{
+		{
+			name: "resolves in order",
+			valuesFrom: []sourcev1.ValuesReference{
+				{Kind: "Secret", Name: "values"},
+				{Kind: "ConfigMap", Name: "values", ValuesKey: "custom.yaml"},
+			},
+			want: []chart.ValuesSource{
+				{Origin: "Secret/foo/values", Data: []byte("replicaCount: 4")},
+				{Origin: "ConfigMap/foo/values", Data: []byte("replicaCount: 3")},
+			},
+		},
+		{
+			name: "skips optional missing reference",
+			valuesFrom: []sourcev1.ValuesReference{
+				{Kind: "ConfigMap", Name: "missing", Optional: true},
+				{Kind: "ConfigMap", Name: "values"},
+			},
+			want: []chart.ValuesSource{
+				{Origin: "ConfigMap/foo/values", Data: []byte("replicaCount: 2")},
+			},
+		},
+		{
+			name: "error on missing reference",
+			valuesFrom: []sourcev1.ValuesReference{
+				{Kind: "Secret", Name: "missing"},
+			},
+			wantErr: "could not get values from Secret/foo/missing",
+		},
+		{
+			name: "error on missing key",
+			valuesFrom: []sourcev1.ValuesReference{
+				{Kind: "ConfigMap", Name: "values", ValuesKey: "missing.yaml", Optional: true},
+			},
+			wantErr: "missing key 'missing.yaml' in ConfigMap/foo/values",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			obj := &sourcev1.HelmChart{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "chart",
+					Namespace: "foo",
+				},
+				Spec: sourcev1.HelmChartSpec{
+					ValuesFrom: tt.valuesFrom,
+				},
+			}
+			got, err := r.getValuesFrom(context.TODO(), obj)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestHelmChartReconciler_configureValuesFrom(t *testing.T) {
+	g := NewWithT(t)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "values",
+			Namespace: "foo",
+		},
+		Data: map[string]string{
+			"values.yaml": "replicaCount: 2",
+		},
+	}
+	r := &HelmChartReconciler{
+		Client: fake.NewClientBuilder().WithObjects(cm).Build(),
+	}
+	obj := &sourcev1.HelmChart{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "chart",
+			Namespace: "foo",
+		},
+		Spec: sourcev1.HelmChartSpec{
+			ValuesFrom: []sourcev1.ValuesReference{
+				{Kind: "ConfigMap", Name: "values"},
+				{Kind: "Secret", Name: "missing"},
+			},
+		},
+	}
+
+	// A reference which does not exist yet is retried, as it is not watched.
+	var opts chart.BuildOptions
+	err := r.configureValuesFrom(context.TODO(), obj, &opts)
+	var buildErr *chart.BuildError
+	g.Expect(errors.As(err, &buildErr)).To(BeTrue())
+	g.Expect(buildErr.Reason).To(Equal(chart.ErrValuesReference))
+	g.Expect(chart.IsPersistentBuildErrorReason(buildErr.Reason)).To(BeFalse())
+
+	obj.Spec.ValuesFrom = obj.Spec.ValuesFrom[:1]
+	g.Expect(r.configureValuesFrom(context.TODO(), obj, &opts)).To(Succeed())
+	g.Expect(opts.ValuesFrom).To(HaveLen(1))
+	g.Expect(opts.VersionMetadata).ToNot(BeEmpty())
+}
+
 func TestHelmChartReconciler_getSource(t *testing.T) {
 	mocks := []client.Object{
 		&sourcev1.HelmRepository{
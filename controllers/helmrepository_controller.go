@@ -22,6 +22,8 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/docker/go-units"
@@ -47,11 +49,13 @@ import (
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	serror "github.com/fluxcd/source-controller/internal/error"
+	"github.com/fluxcd/source-controller/internal/fetch"
 	"github.com/fluxcd/source-controller/internal/helm/getter"
 	"github.com/fluxcd/source-controller/internal/helm/repository"
 	intpredicates "github.com/fluxcd/source-controller/internal/predicates"
 	sreconcile "github.com/fluxcd/source-controller/internal/reconcile"
 	"github.com/fluxcd/source-controller/internal/reconcile/summarize"
+	"github.com/fluxcd/source-controller/internal/useragent"
 )
 
 // helmRepositoryReadyCondition contains the information required to summarize a
@@ -103,8 +107,25 @@ type HelmRepositoryReconciler struct {
 	helper.Metrics
 
 	Getters        helmgetter.Providers
-	Storage        *Storage
+	Storage        LocalArtifactStorage
 	ControllerName string
+
+	// FetchRecorder records a fetch.Result for every fetch of the source,
+	// if set.
+	FetchRecorder fetch.Recorder
+
+	// indexValidators holds the indexValidators of the last downloaded index
+	// per object, to perform conditional index downloads.
+	indexValidators sync.Map
+}
+
+// indexValidators contains the ETag and Last-Modified validators returned by
+// the remote for an index with a checksum, downloaded from a URL.
+type indexValidators struct {
+	url          string
+	checksum     string
+	etag         string
+	lastModified string
 }
 
 type HelmRepositoryReconcilerOptions struct {
@@ -316,7 +337,7 @@ func (r *HelmRepositoryReconciler) reconcileStorage(ctx context.Context, obj *so
 	_ = r.garbageCollect(ctx, obj)
 
 	// Determine if the advertised artifact is still in storage
-	if artifact := obj.GetArtifact(); artifact != nil && !r.Storage.ArtifactExist(*artifact) {
+	if artifact := obj.GetArtifact(); artifact != nil && !r.Storage.Exists(*artifact) {
 		obj.Status.Artifact = nil
 		obj.Status.URL = ""
 		// Remove the condition as the artifact doesn't exist.
@@ -332,7 +353,7 @@ func (r *HelmRepositoryReconciler) reconcileStorage(ctx context.Context, obj *so
 
 	// Always update URLs to ensure hostname is up-to-date
 	// TODO(hidde): we may want to send out an event only if we notice the URL has changed
-	r.Storage.SetArtifactURL(obj.GetArtifact())
+	obj.Status.Artifact.URL = r.Storage.URL(*obj.GetArtifact())
 	obj.Status.URL = r.Storage.SetHostname(obj.Status.URL)
 
 	return sreconcile.ResultSuccess, nil
@@ -346,7 +367,33 @@ func (r *HelmRepositoryReconciler) reconcileStorage(ctx context.Context, obj *so
 // If successful and the index is valid, any previous
 // v1beta2.FetchFailedCondition is removed, and the repository.ChartRepository
 // pointer is set to the newly fetched index.
-func (r *HelmRepositoryReconciler) reconcileSource(ctx context.Context, obj *sourcev1.HelmRepository, artifact *sourcev1.Artifact, chartRepo *repository.ChartRepository) (sreconcile.Result, error) {
+func (r *HelmRepositoryReconciler) reconcileSource(ctx context.Context, obj *sourcev1.HelmRepository, artifact *sourcev1.Artifact, chartRepo *repository.ChartRepository) (_ sreconcile.Result, retErr error) {
+	// Record the fetch of the source
+	var (
+		checksum  string
+		fromCache bool
+	)
+	start := time.Now()
+	defer func() {
+		result := fetch.Result{
+			Kind:      sourcev1.HelmRepositoryKind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			URL:       fetch.RedactURL(obj.Spec.URL),
+		}
+		if retErr == nil {
+			result.Revision = artifact.Revision
+			result.Digest = checksum
+			result.FromCache = fromCache
+			if chartRepo.CachePath != "" {
+				if fi, err := os.Stat(chartRepo.CachePath); err == nil {
+					result.Size = fi.Size()
+				}
+			}
+		}
+		fetch.Record(ctx, r.FetchRecorder, result, start, retErr)
+	}()
+
 	var tlsConfig *tls.Config
 
 	// Configure Helm client to access repository
@@ -354,6 +401,13 @@ func (r *HelmRepositoryReconciler) reconcileSource(ctx context.Context, obj *sou
 		helmgetter.WithTimeout(obj.Spec.Timeout.Duration),
 		helmgetter.WithURL(obj.Spec.URL),
 		helmgetter.WithPassCredentialsAll(obj.Spec.PassCredentials),
+		helmgetter.WithUserAgent(useragent.Get()),
+	}
+	chartRepoOpts := []repository.ChartRepositoryOption{
+		repository.WithTimeout(obj.Spec.Timeout.Duration),
+		repository.WithPassCredentialsAll(obj.Spec.PassCredentials),
+		repository.WithUserAgent(useragent.Get()),
+		repository.WithMirrors(obj.Spec.Mirrors...),
 	}
 
 	// Configure any authentication related options
@@ -385,6 +439,8 @@ func (r *HelmRepositoryReconciler) reconcileSource(ctx context.Context, obj *sou
 			return sreconcile.ResultEmpty, e
 		}
 		clientOpts = append(clientOpts, opts...)
+		chartRepoOpts = append(chartRepoOpts,
+			repository.WithBasicAuth(string(secret.Data["username"]), string(secret.Data["password"])))
 
 		tlsConfig, err = getter.TLSClientConfigFromSecret(secret, obj.Spec.URL)
 		if err != nil {
@@ -399,7 +455,7 @@ func (r *HelmRepositoryReconciler) reconcileSource(ctx context.Context, obj *sou
 	}
 
 	// Construct Helm chart repository with options and download index
-	newChartRepo, err := repository.NewChartRepository(obj.Spec.URL, "", r.Getters, tlsConfig, clientOpts)
+	newChartRepo, err := repository.NewChartRepository(obj.Spec.URL, "", r.Getters, tlsConfig, clientOpts, chartRepoOpts...)
 	if err != nil {
 		switch err.(type) {
 		case *url.Error:
@@ -419,8 +475,29 @@ func (r *HelmRepositoryReconciler) reconcileSource(ctx context.Context, obj *sou
 		}
 	}
 
-	// Fetch the repository index from remote.
-	checksum, err := newChartRepo.CacheIndex()
+	// Fetch the repository index from remote, conditionally if the validators
+	// of the index of the current Artifact are known.
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}.String()
+	var etag, lastModified string
+	if v, ok := r.indexValidators.Load(key); ok {
+		if v := v.(indexValidators); v.url == obj.Spec.URL && obj.GetArtifact().HasChecksum(v.checksum) {
+			etag, lastModified = v.etag, v.lastModified
+		}
+	}
+	checksum, err = newChartRepo.CacheIndexIfModified(etag, lastModified)
+	if newChartRepo.ServedURL != "" && newChartRepo.ServedURL != obj.Spec.URL {
+		r.eventLogf(ctx, obj, corev1.EventTypeNormal, "MirrorFallback",
+			"Helm repository index served from mirror '%s'", newChartRepo.ServedURL)
+	}
+	if errors.Is(err, repository.ErrIndexNotModified) {
+		// The remote index has not changed since the stored Artifact was
+		// produced, reuse it without downloading or parsing the index.
+		*chartRepo = *newChartRepo
+		*artifact = *obj.GetArtifact()
+		checksum, fromCache = artifact.Checksum, true
+		conditions.Delete(obj, sourcev1.FetchFailedCondition)
+		return sreconcile.ResultSuccess, nil
+	}
 	if err != nil {
 		e := &serror.Event{
 			Err:    fmt.Errorf("failed to fetch Helm repository index: %w", err),
@@ -431,12 +508,23 @@ func (r *HelmRepositoryReconciler) reconcileSource(ctx context.Context, obj *sou
 		return sreconcile.ResultEmpty, e
 	}
 	*chartRepo = *newChartRepo
+	if newChartRepo.ETag != "" || newChartRepo.LastModified != "" {
+		r.indexValidators.Store(key, indexValidators{
+			url:          obj.Spec.URL,
+			checksum:     checksum,
+			etag:         newChartRepo.ETag,
+			lastModified: newChartRepo.LastModified,
+		})
+	} else {
+		r.indexValidators.Delete(key)
+	}
 
 	// Short-circuit based on the fetched index being an exact match to the
 	// stored Artifact. This prevents having to unmarshal the YAML to calculate
 	// the (stable) revision, which is a memory expensive operation.
 	if obj.GetArtifact().HasChecksum(checksum) {
 		*artifact = *obj.GetArtifact()
+		fromCache = true
 		conditions.Delete(obj, sourcev1.FetchFailedCondition)
 		return sreconcile.ResultSuccess, nil
 	}
@@ -558,6 +646,9 @@ func (r *HelmRepositoryReconciler) reconcileDelete(ctx context.Context, obj *sou
 		return sreconcile.ResultEmpty, err
 	}
 
+	// Forget the validators of the index
+	r.indexValidators.Delete(types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}.String())
+
 	// Remove our finalizer from the list if we are deleting the object
 	if !obj.DeletionTimestamp.IsZero() {
 		controllerutil.RemoveFinalizer(obj, sourcev1.SourceFinalizer)
@@ -48,6 +48,7 @@ import (
 
 	"github.com/fluxcd/source-controller/api/v1beta2"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	"github.com/fluxcd/source-controller/internal/fetch"
 	"github.com/fluxcd/source-controller/internal/helm/registry"
 	"github.com/fluxcd/source-controller/internal/helm/repository"
 	"github.com/fluxcd/source-controller/internal/object"
@@ -78,13 +79,17 @@ type HelmRepositoryOCIReconciler struct {
 	Getters                 helmgetter.Providers
 	ControllerName          string
 	RegistryClientGenerator RegistryClientGeneratorFunc
+
+	// FetchRecorder records a fetch.Result for every reconciliation of the
+	// registry, if set.
+	FetchRecorder fetch.Recorder
 }
 
 // RegistryClientGeneratorFunc is a function that returns a registry client
 // and an optional file name.
 // The file is used to store the registry client credentials.
 // The caller is responsible for deleting the file.
-type RegistryClientGeneratorFunc func(isLogin bool) (*helmreg.Client, string, error)
+type RegistryClientGeneratorFunc func(isLogin bool) (*registry.Client, string, error)
 
 func (r *HelmRepositoryOCIReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return r.SetupWithManagerAndOptions(mgr, HelmRepositoryReconcilerOptions{})
@@ -203,6 +208,19 @@ func (r *HelmRepositoryOCIReconciler) Reconcile(ctx context.Context, req ctrl.Re
 // block at the very end to summarize the conditions to be in a consistent
 // state.
 func (r *HelmRepositoryOCIReconciler) reconcile(ctx context.Context, obj *v1beta2.HelmRepository) (result ctrl.Result, retErr error) {
+	// Record the access of the registry. The charts are fetched by the
+	// HelmChart reconciler, hence no revision or size is recorded. This is
+	// deferred first, to record the error as summarized below.
+	start := time.Now()
+	defer func() {
+		fetch.Record(ctx, r.FetchRecorder, fetch.Result{
+			Kind:      sourcev1.HelmRepositoryKind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			URL:       fetch.RedactURL(obj.Spec.URL),
+		}, start, retErr)
+	}()
+
 	oldObj := obj.DeepCopy()
 
 	defer func() {
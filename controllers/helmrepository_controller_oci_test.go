@@ -17,6 +17,7 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"testing"
@@ -30,8 +31,12 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	kstatus "sigs.k8s.io/cli-utils/pkg/kstatus/status"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/source-controller/internal/fetch"
 )
 
 func TestHelmRepositoryOCIReconciler_Reconcile(t *testing.T) {
@@ -161,3 +166,40 @@ func TestHelmRepositoryOCIReconciler_Reconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestHelmRepositoryOCIReconciler_reconcile_fetchResult(t *testing.T) {
+	g := NewWithT(t)
+
+	var results []fetch.Result
+	r := &HelmRepositoryOCIReconciler{
+		Client:        fakeclient.NewClientBuilder().WithScheme(testEnv.GetScheme()).Build(),
+		EventRecorder: record.NewFakeRecorder(32),
+		FetchRecorder: fetch.RecorderFunc(func(_ context.Context, result fetch.Result) {
+			results = append(results, result)
+		}),
+	}
+
+	obj := &sourcev1.HelmRepository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fetch-result",
+			Namespace: "default",
+		},
+		Spec: sourcev1.HelmRepositorySpec{
+			URL:       "oci://registry.example.com/charts",
+			Type:      sourcev1.HelmRepositoryTypeOCI,
+			SecretRef: &meta.LocalObjectReference{Name: "missing"},
+		},
+	}
+
+	_, err := r.reconcile(context.TODO(), obj)
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(results).To(HaveLen(1))
+	result := results[0]
+	g.Expect(result.Kind).To(Equal(sourcev1.HelmRepositoryKind))
+	g.Expect(result.Namespace).To(Equal("default"))
+	g.Expect(result.Name).To(Equal("fetch-result"))
+	g.Expect(result.URL).To(Equal("oci://registry.example.com/charts"))
+	g.Expect(result.Duration.Duration).To(BeNumerically(">", 0))
+	g.Expect(result.Error).To(ContainSubstring("failed to get secret"))
+}
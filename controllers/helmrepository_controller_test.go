@@ -45,6 +45,7 @@ import (
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	"github.com/fluxcd/source-controller/internal/fetch"
 	"github.com/fluxcd/source-controller/internal/helm/getter"
 	"github.com/fluxcd/source-controller/internal/helm/repository"
 	sreconcile "github.com/fluxcd/source-controller/internal/reconcile"
@@ -641,6 +642,94 @@ func TestHelmRepositoryReconciler_reconcileSource(t *testing.T) {
 	}
 }
 
+func TestHelmRepositoryReconciler_reconcileSource_fetchResult(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := helmtestserver.NewTempHelmServer()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	g.Expect(server.PackageChart("testdata/charts/helmchart")).To(Succeed())
+	g.Expect(server.GenerateIndex()).To(Succeed())
+
+	server.Start()
+	defer server.Stop()
+
+	var results []fetch.Result
+	r := &HelmRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Client:        fakeclient.NewClientBuilder().WithScheme(testEnv.GetScheme()).Build(),
+		Storage:       testStorage,
+		Getters:       testGetters,
+		FetchRecorder: fetch.RecorderFunc(func(_ context.Context, result fetch.Result) {
+			results = append(results, result)
+		}),
+	}
+
+	obj := &sourcev1.HelmRepository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fetch-result",
+			Namespace: "default",
+		},
+		Spec: sourcev1.HelmRepositorySpec{
+			URL:      server.URL(),
+			Interval: metav1.Duration{Duration: interval},
+			Timeout:  &metav1.Duration{Duration: timeout},
+		},
+	}
+
+	assertCommon := func(result fetch.Result, url string) {
+		g.Expect(result.Kind).To(Equal(sourcev1.HelmRepositoryKind))
+		g.Expect(result.Namespace).To(Equal("default"))
+		g.Expect(result.Name).To(Equal("fetch-result"))
+		g.Expect(result.URL).To(Equal(url))
+		g.Expect(result.Duration.Duration).To(BeNumerically(">", 0))
+	}
+
+	// Fetch the index.
+	var chartRepo repository.ChartRepository
+	var artifact sourcev1.Artifact
+	_, err = r.reconcileSource(context.TODO(), obj, &artifact, &chartRepo)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.Remove(chartRepo.CachePath)
+
+	g.Expect(results).To(HaveLen(1))
+	assertCommon(results[0], server.URL())
+	g.Expect(results[0].Revision).To(Equal(chartRepo.Checksum))
+	g.Expect(results[0].Digest).ToNot(BeEmpty())
+	g.Expect(results[0].Size).To(BeNumerically(">", 0))
+	g.Expect(results[0].FromCache).To(BeFalse())
+	g.Expect(results[0].Error).To(BeEmpty())
+
+	// Fetch the same index again, with a stored artifact for it.
+	obj.Status.Artifact = &sourcev1.Artifact{
+		Revision: results[0].Revision,
+		Checksum: results[0].Digest,
+	}
+	chartRepo, artifact = repository.ChartRepository{}, sourcev1.Artifact{}
+	_, err = r.reconcileSource(context.TODO(), obj, &artifact, &chartRepo)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.Remove(chartRepo.CachePath)
+
+	g.Expect(results).To(HaveLen(2))
+	assertCommon(results[1], server.URL())
+	g.Expect(results[1].Revision).To(Equal(results[0].Revision))
+	g.Expect(results[1].Digest).To(Equal(results[0].Digest))
+	g.Expect(results[1].FromCache).To(BeTrue())
+	g.Expect(results[1].Error).To(BeEmpty())
+
+	// Fail to fetch an index.
+	obj.Spec.URL = server.URL() + "/missing"
+	chartRepo, artifact = repository.ChartRepository{}, sourcev1.Artifact{}
+	_, err = r.reconcileSource(context.TODO(), obj, &artifact, &chartRepo)
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(results).To(HaveLen(3))
+	assertCommon(results[2], server.URL()+"/missing")
+	g.Expect(results[2].Revision).To(BeEmpty())
+	g.Expect(results[2].Error).ToNot(BeEmpty())
+}
+
 func TestHelmRepositoryReconciler_reconcileArtifact(t *testing.T) {
 	tests := []struct {
 		name             string
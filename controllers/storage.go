@@ -21,6 +21,7 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"hash"
 	"io"
@@ -34,18 +35,30 @@ import (
 
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/fluxcd/pkg/lockedfile"
-	"github.com/fluxcd/pkg/untar"
 	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	intdigest "github.com/fluxcd/source-controller/internal/digest"
 	sourcefs "github.com/fluxcd/source-controller/internal/fs"
+	sourcetar "github.com/fluxcd/source-controller/internal/tar"
+	"github.com/fluxcd/source-controller/internal/util"
 	"github.com/fluxcd/source-controller/pkg/sourceignore"
 )
 
 const GarbageCountLimit = 1000
 
+const (
+	// lockFileSuffix is the suffix of the lock file of an artifact.
+	lockFileSuffix = ".lock"
+	// tempFileSuffix is the suffix of the temporary files artifacts are
+	// written to before being renamed to their artifact path.
+	tempFileSuffix = ".tmp"
+	// manifestFileSuffix is the suffix of the file manifest of an artifact.
+	manifestFileSuffix = ".manifest.json"
+)
+
 // Storage manages artifacts
 type Storage struct {
 	// BasePath is the local directory path where the source artifacts are stored.
@@ -92,11 +105,19 @@ func (s Storage) SetArtifactURL(artifact *sourcev1.Artifact) {
 	if artifact.Path == "" {
 		return
 	}
+	artifact.URL = s.URL(*artifact)
+}
+
+// URL returns the URL at which the given v1beta1.Artifact is served by the file server.
+func (s Storage) URL(artifact sourcev1.Artifact) string {
+	if artifact.Path == "" {
+		return ""
+	}
 	format := "http://%s/%s"
 	if strings.HasPrefix(s.Hostname, "http://") || strings.HasPrefix(s.Hostname, "https://") {
 		format = "%s/%s"
 	}
-	artifact.URL = fmt.Sprintf(format, s.Hostname, strings.TrimLeft(artifact.Path, "/"))
+	return fmt.Sprintf(format, s.Hostname, strings.TrimLeft(artifact.Path, "/"))
 }
 
 // SetHostname sets the hostname of the given URL string to the current Storage.Hostname and returns the result.
@@ -139,7 +160,8 @@ func (s *Storage) RemoveAllButCurrent(artifact sourcev1.Artifact) ([]string, err
 			return nil
 		}
 
-		if path != localPath && !info.IsDir() && info.Mode()&os.ModeSymlink != os.ModeSymlink {
+		if path != localPath && path != localPath+manifestFileSuffix &&
+			!info.IsDir() && info.Mode()&os.ModeSymlink != os.ModeSymlink {
 			if err := os.Remove(path); err != nil {
 				errors = append(errors, info.Name())
 			} else {
@@ -165,6 +187,9 @@ func (s *Storage) getGarbageFiles(artifact sourcev1.Artifact, totalCountLimit, m
 	localPath := s.LocalPath(artifact)
 	dir := filepath.Dir(localPath)
 	garbageFiles := []string{}
+	// otherGarbageFiles contain the lock and temporary files to be collected,
+	// which do not count towards the retained items.
+	otherGarbageFiles := []string{}
 	filesWithCreatedTs := make(map[time.Time]string)
 	// sortedPaths contain all files sorted according to their created ts.
 	sortedPaths := []string{}
@@ -191,6 +216,24 @@ func (s *Storage) getGarbageFiles(artifact sourcev1.Artifact, totalCountLimit, m
 		// with the provided TTL. Delete if the difference is greater than the TTL.
 		expired := diff > ttl
 		if !info.IsDir() && info.Mode()&os.ModeSymlink != os.ModeSymlink {
+			// Lock files are in use by concurrent writers, and are removed with
+			// the artifact they lock, as are manifest files. Lock and manifest
+			// files of which the artifact no longer exists are collected once
+			// expired.
+			if artifactPath, ok := trimSidecarSuffix(path); ok {
+				if _, err := os.Lstat(artifactPath); os.IsNotExist(err) && expired {
+					otherGarbageFiles = append(otherGarbageFiles, path)
+				}
+				return nil
+			}
+			// Temporary files are written to by concurrent writers, and are
+			// only collected once expired.
+			if strings.HasSuffix(path, tempFileSuffix) {
+				if expired {
+					otherGarbageFiles = append(otherGarbageFiles, path)
+				}
+				return nil
+			}
 			if path != localPath && expired {
 				garbageFiles = append(garbageFiles, path)
 			}
@@ -208,7 +251,7 @@ func (s *Storage) getGarbageFiles(artifact sourcev1.Artifact, totalCountLimit, m
 	// We already collected enough garbage files to satisfy the no. of max
 	// items that are supposed to be retained, so exit early.
 	if totalFiles-len(garbageFiles) < maxItemsToBeRetained {
-		return garbageFiles, nil
+		return append(garbageFiles, otherGarbageFiles...), nil
 	}
 
 	// sort all timestamps in an ascending order.
@@ -242,34 +285,81 @@ func (s *Storage) getGarbageFiles(artifact sourcev1.Artifact, totalCountLimit, m
 		}
 	}
 
-	return garbageFiles, nil
+	return append(garbageFiles, otherGarbageFiles...), nil
+}
+
+// gcOptions are the options of Storage.GarbageCollect.
+type gcOptions struct {
+	retentionTTL     time.Duration
+	retentionRecords int
+	dryRun           bool
+}
+
+// GarbageCollectOption configures Storage.GarbageCollect.
+type GarbageCollectOption func(o *gcOptions)
+
+// WithRetention configures Storage.GarbageCollect to retain artifacts which
+// are not older than the given TTL, with a maximum of the given number of
+// records, instead of the retention configured for the Storage.
+func WithRetention(ttl time.Duration, records int) GarbageCollectOption {
+	return func(o *gcOptions) {
+		o.retentionTTL = ttl
+		o.retentionRecords = records
+	}
+}
+
+// WithDryRun configures Storage.GarbageCollect to return the files which
+// would be garbage collected, without removing them.
+func WithDryRun() GarbageCollectOption {
+	return func(o *gcOptions) {
+		o.dryRun = true
+	}
 }
 
 // GarbageCollect removes all garabge files in the artifact dir according to the provided
-// retention options.
-func (s *Storage) GarbageCollect(ctx context.Context, artifact sourcev1.Artifact, timeout time.Duration) ([]string, error) {
-	delFilesChan := make(chan []string)
-	errChan := make(chan error)
+// retention options. The given artifact is never removed, as it is the current artifact of
+// the source.
+//
+// It is safe to run concurrently with writers of artifacts. Artifacts are removed while
+// holding their lock, and are retained if they were written to while collecting the garbage
+// files. Temporary files of in-progress writes are only removed once older than the TTL.
+func (s *Storage) GarbageCollect(ctx context.Context, artifact sourcev1.Artifact, timeout time.Duration, opts ...GarbageCollectOption) ([]string, error) {
+	o := &gcOptions{
+		retentionTTL:     s.ArtifactRetentionTTL,
+		retentionRecords: s.ArtifactRetentionRecords,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	delFilesChan := make(chan []string, 1)
+	errChan := make(chan error, 1)
 	// Abort if it takes more than the provided timeout duration.
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	go func() {
-		garbageFiles, err := s.getGarbageFiles(artifact, GarbageCountLimit, s.ArtifactRetentionRecords, s.ArtifactRetentionTTL)
+		start := time.Now()
+		garbageFiles, err := s.getGarbageFiles(artifact, GarbageCountLimit, o.retentionRecords, o.retentionTTL)
 		if err != nil {
 			errChan <- err
 			return
 		}
+		if o.dryRun {
+			delFilesChan <- garbageFiles
+			return
+		}
 		var errors []error
 		var deleted []string
-		if len(garbageFiles) > 0 {
-			for _, file := range garbageFiles {
-				err := os.Remove(file)
-				if err != nil {
-					errors = append(errors, err)
-				} else {
-					deleted = append(deleted, file)
-				}
+		for _, file := range garbageFiles {
+			if ctx.Err() != nil {
+				return
+			}
+			removed, err := removeGarbageFile(file, start)
+			if err != nil {
+				errors = append(errors, err)
+			} else if removed {
+				deleted = append(deleted, file)
 			}
 		}
 		if len(errors) > 0 {
@@ -291,6 +381,91 @@ func (s *Storage) GarbageCollect(ctx context.Context, artifact sourcev1.Artifact
 	}
 }
 
+// removeGarbageFile removes the given garbage file while holding its lock,
+// unless it has been modified since the given time. Removing an artifact
+// removes its lock and manifest files as well. It returns if the file was
+// removed.
+func removeGarbageFile(path string, since time.Time) (bool, error) {
+	if strings.HasSuffix(path, tempFileSuffix) {
+		return removeFile(path)
+	}
+
+	artifactPath, sidecar := trimSidecarSuffix(path)
+	if !sidecar {
+		artifactPath = path
+	}
+	lockFile := artifactPath + lockFileSuffix
+	unlock, err := lockedfile.MutexAt(lockFile).Lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if sidecar {
+		// Retain the lock or manifest file if its artifact has been written
+		// in the meantime.
+		if _, err := os.Lstat(artifactPath); !os.IsNotExist(err) {
+			return false, nil
+		}
+		removed, err := removeFile(path)
+		if err != nil || lockFile == path {
+			return removed, err
+		}
+		_, err = removeFile(lockFile)
+		return removed, err
+	}
+
+	fi, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if fi.ModTime().After(since) {
+		return false, nil
+	}
+	removed, err := removeFile(path)
+	if err != nil || !removed {
+		return removed, err
+	}
+	if _, err = removeFile(path + manifestFileSuffix); err != nil {
+		return true, err
+	}
+	_, err = removeFile(lockFile)
+	return true, err
+}
+
+// trimSidecarSuffix returns the path of the artifact the lock or manifest
+// file at the given path belongs to. It returns false if the path is not a
+// lock or manifest file.
+func trimSidecarSuffix(path string) (string, bool) {
+	for _, suffix := range []string{lockFileSuffix, manifestFileSuffix} {
+		if strings.HasSuffix(path, suffix) {
+			return strings.TrimSuffix(path, suffix), true
+		}
+	}
+	return "", false
+}
+
+// removeFile removes the file at the given path, and returns if it existed.
+func removeFile(path string) (bool, error) {
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// createTempFile creates a temporary file in the directory of the given
+// path, to be renamed to the path once written.
+func createTempFile(path string) (*os.File, error) {
+	dir, file := filepath.Split(path)
+	return os.CreateTemp(dir, file+".*"+tempFileSuffix)
+}
+
 func stringInSlice(a string, list []string) bool {
 	for _, b := range list {
 		if b == a {
@@ -309,6 +484,41 @@ func (s *Storage) ArtifactExist(artifact sourcev1.Artifact) bool {
 	return fi.Mode().IsRegular()
 }
 
+// Exists returns a boolean indicating whether the v1beta1.Artifact exists in storage and is a regular file.
+func (s *Storage) Exists(artifact sourcev1.Artifact) bool {
+	return s.ArtifactExist(artifact)
+}
+
+// Put atomically copies the io.Reader contents to the v1beta1.Artifact path, creating the artifact base dir if needed.
+// If successful, it sets the checksum, digest and last update time on the artifact.
+func (s *Storage) Put(artifact *sourcev1.Artifact, reader io.Reader) error {
+	if err := s.MkdirAll(*artifact); err != nil {
+		return err
+	}
+	return s.Copy(artifact, reader)
+}
+
+// Get opens the file of the given v1beta1.Artifact for reading.
+func (s *Storage) Get(artifact sourcev1.Artifact) (io.ReadCloser, error) {
+	localPath := s.LocalPath(artifact)
+	if localPath == "" {
+		return nil, fmt.Errorf("invalid artifact path '%s': %w", artifact.Path, fs.ErrNotExist)
+	}
+	return os.Open(localPath)
+}
+
+// Delete removes the file of the given v1beta1.Artifact, if it exists.
+func (s *Storage) Delete(artifact sourcev1.Artifact) error {
+	localPath := s.LocalPath(artifact)
+	if localPath == "" {
+		return nil
+	}
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // ArchiveFileFilter must return true if a file should not be included in the archive after inspecting the given path
 // and/or os.FileInfo.
 type ArchiveFileFilter func(p string, fi os.FileInfo) bool
@@ -327,17 +537,116 @@ func SourceIgnoreFilter(ps []gitignore.Pattern, domain []string) ArchiveFileFilt
 	}
 }
 
+// archiveOptions are the options of Storage.Archive.
+type archiveOptions struct {
+	preserveSymlinks bool
+	maxFileSize      int64
+	skipLargeFiles   bool
+	report           *ArchiveReport
+	manifest         bool
+}
+
+// ArchiveReport reports on the files which were not archived by
+// Storage.Archive.
+type ArchiveReport struct {
+	// SkippedFiles are the paths relative to the archived directory of the
+	// files which were skipped because they exceeded the max file size.
+	SkippedFiles []string
+}
+
+// FileTooLargeError is returned by Storage.Archive when a file exceeds the
+// max file size, and large files are not skipped.
+type FileTooLargeError struct {
+	// Path of the file relative to the archived directory.
+	Path string
+	// Size of the file in bytes.
+	Size int64
+	// MaxSize is the max file size in bytes.
+	MaxSize int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("file '%s' size %d exceeds max file size %d", e.Path, e.Size, e.MaxSize)
+}
+
+// ArchiveOption configures Storage.Archive.
+type ArchiveOption func(o *archiveOptions)
+
+// WithPreservedSymlinks configures Storage.Archive to archive symbolic links
+// as symlink entries instead of ignoring them. The target of a symbolic link
+// must be relative and within the archived directory, otherwise archiving
+// fails.
+func WithPreservedSymlinks() ArchiveOption {
+	return func(o *archiveOptions) {
+		o.preserveSymlinks = true
+	}
+}
+
+// WithMaxFileSize configures Storage.Archive to not archive regular files
+// larger than the given size in bytes. If skip is true, such files are
+// skipped and recorded in the ArchiveReport given using WithArchiveReport,
+// otherwise archiving fails with a FileTooLargeError. A size of zero or
+// less disables the limit.
+func WithMaxFileSize(size int64, skip bool) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.maxFileSize = size
+		o.skipLargeFiles = skip
+	}
+}
+
+// WithArchiveReport configures Storage.Archive to record the files it did
+// not archive in the given ArchiveReport.
+func WithArchiveReport(report *ArchiveReport) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.report = report
+	}
+}
+
+// WithManifest configures Storage.Archive to write an ArtifactManifest of
+// the archived files alongside the artifact, which can be retrieved using
+// Storage.Manifest.
+func WithManifest() ArchiveOption {
+	return func(o *archiveOptions) {
+		o.manifest = true
+	}
+}
+
+// ArtifactManifest lists the regular files in an artifact archive, which
+// allows inspecting its contents without extracting it.
+type ArtifactManifest struct {
+	// Files in the archive, sorted by path.
+	Files []ArtifactManifestFile `json:"files"`
+}
+
+// ArtifactManifestFile is a regular file in an artifact archive.
+type ArtifactManifestFile struct {
+	// Path of the file in the archive, separated by slashes.
+	Path string `json:"path"`
+	// Size of the file in bytes.
+	Size int64 `json:"size"`
+	// Digest of the contents of the file.
+	Digest string `json:"digest"`
+}
+
 // Archive atomically archives the given directory as a tarball to the given v1beta1.Artifact path, excluding
 // directories and any ArchiveFileFilter matches. While archiving, any environment specific data (for example,
-// the user and group name) is stripped from file headers.
-// If successful, it sets the checksum and last update time on the artifact.
-func (s *Storage) Archive(artifact *sourcev1.Artifact, dir string, filter ArchiveFileFilter) (err error) {
+// the user and group name) is stripped from file headers. Symbolic links are ignored, unless
+// WithPreservedSymlinks is given, and files larger than the size given using WithMaxFileSize are
+// rejected or skipped. If WithManifest is given, an ArtifactManifest of the archived files is written
+// alongside the artifact.
+// If successful, it sets the checksum, digest and last update time on the artifact.
+func (s *Storage) Archive(artifact *sourcev1.Artifact, dir string, filter ArchiveFileFilter, opts ...ArchiveOption) (err error) {
 	if f, err := os.Stat(dir); os.IsNotExist(err) || !f.IsDir() {
 		return fmt.Errorf("invalid dir path: %s", dir)
 	}
 
+	o := &archiveOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	localPath := s.LocalPath(*artifact)
-	tf, err := os.CreateTemp(filepath.Split(localPath))
+	tf, err := createTempFile(localPath)
 	if err != nil {
 		return err
 	}
@@ -349,18 +658,22 @@ func (s *Storage) Archive(artifact *sourcev1.Artifact, dir string, filter Archiv
 	}()
 
 	h := newHash()
+	d := intdigest.Canonical.Digester()
 	sz := &writeCounter{}
-	mw := io.MultiWriter(h, tf, sz)
+	mw := io.MultiWriter(h, d.Hash(), tf, sz)
 
 	gw := gzip.NewWriter(mw)
 	tw := tar.NewWriter(gw)
+	var manifest ArtifactManifest
 	if err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Ignore anything that is not a file or directories e.g. symlinks
-		if m := fi.Mode(); !(m.IsRegular() || m.IsDir()) {
+		// Ignore anything that is not a file or directories e.g. symlinks,
+		// unless symlinks are preserved
+		isSymlink := fi.Mode()&os.ModeSymlink != 0
+		if m := fi.Mode(); !(m.IsRegular() || m.IsDir() || (isSymlink && o.preserveSymlinks)) {
 			return nil
 		}
 
@@ -369,10 +682,6 @@ func (s *Storage) Archive(artifact *sourcev1.Artifact, dir string, filter Archiv
 			return nil
 		}
 
-		header, err := tar.FileInfoHeader(fi, p)
-		if err != nil {
-			return err
-		}
 		// The name needs to be modified to maintain directory structure
 		// as tar.FileInfoHeader only has access to the base name of the file.
 		// Ref: https://golang.org/src/archive/tar/common.go?#L626
@@ -383,6 +692,31 @@ func (s *Storage) Archive(artifact *sourcev1.Artifact, dir string, filter Archiv
 				return err
 			}
 		}
+
+		if o.maxFileSize > 0 && fi.Mode().IsRegular() && fi.Size() > o.maxFileSize {
+			if !o.skipLargeFiles {
+				return &FileTooLargeError{Path: filepath.ToSlash(relFilePath), Size: fi.Size(), MaxSize: o.maxFileSize}
+			}
+			if o.report != nil {
+				o.report.SkippedFiles = append(o.report.SkippedFiles, filepath.ToSlash(relFilePath))
+			}
+			return nil
+		}
+
+		var link string
+		if isSymlink {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+			if err = sourcetar.ValidateSymlink(filepath.ToSlash(relFilePath), link); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
 		header.Name = relFilePath
 
 		// We want to remove any environment specific data as well, this
@@ -407,10 +741,23 @@ func (s *Storage) Archive(artifact *sourcev1.Artifact, dir string, filter Archiv
 			f.Close()
 			return err
 		}
-		if _, err := io.Copy(tw, f); err != nil {
+		var w io.Writer = tw
+		fd := intdigest.Canonical.Digester()
+		if o.manifest {
+			w = io.MultiWriter(tw, fd.Hash())
+		}
+		n, err := io.Copy(w, f)
+		if err != nil {
 			f.Close()
 			return err
 		}
+		if o.manifest {
+			manifest.Files = append(manifest.Files, ArtifactManifestFile{
+				Path:   filepath.ToSlash(relFilePath),
+				Size:   n,
+				Digest: fd.Digest().String(),
+			})
+		}
 		return f.Close()
 	}); err != nil {
 		tw.Close()
@@ -436,22 +783,169 @@ func (s *Storage) Archive(artifact *sourcev1.Artifact, dir string, filter Archiv
 		return err
 	}
 
+	// The manifest is written before the artifact, so that the artifact
+	// does not exist without it. A manifest of a previous artifact at the
+	// same path is removed.
+	if o.manifest {
+		sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+		if err := writeManifest(localPath+manifestFileSuffix, &manifest); err != nil {
+			return err
+		}
+	} else if _, err := removeFile(localPath + manifestFileSuffix); err != nil {
+		return err
+	}
+
 	if err := sourcefs.RenameWithFallback(tmpName, localPath); err != nil {
 		return err
 	}
 
 	artifact.Checksum = fmt.Sprintf("%x", h.Sum(nil))
+	artifact.Digest = d.Digest().String()
 	artifact.LastUpdateTime = metav1.Now()
 	artifact.Size = &sz.written
 
 	return nil
 }
 
+// writeManifest atomically writes the given ArtifactManifest as JSON to the
+// given path.
+func writeManifest(path string, manifest *ArtifactManifest) (err error) {
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode artifact manifest: %w", err)
+	}
+	tf, err := createTempFile(path)
+	if err != nil {
+		return err
+	}
+	tmpName := tf.Name()
+	defer func() {
+		if err != nil {
+			os.Remove(tmpName)
+		}
+	}()
+	if _, err = tf.Write(b); err != nil {
+		tf.Close()
+		return err
+	}
+	if err = tf.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpName, 0o600); err != nil {
+		return err
+	}
+	return sourcefs.RenameWithFallback(tmpName, path)
+}
+
+// Manifest returns the ArtifactManifest of the files in the given artifact,
+// as written by Storage.Archive when given WithManifest.
+func (s *Storage) Manifest(artifact sourcev1.Artifact) (*ArtifactManifest, error) {
+	b, err := os.ReadFile(s.LocalPath(artifact) + manifestFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &ArtifactManifest{}
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode artifact manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// ArtifactDelta is the difference between the contents of two artifacts, as
+// returned by Storage.Delta.
+type ArtifactDelta struct {
+	// Changed is true if the contents of the artifacts differ.
+	Changed bool
+	// Paths of the files and symlinks which were added, removed or
+	// modified, sorted and separated by slashes.
+	Paths []string
+}
+
+// Delta compares the contents of the current artifact against the contents
+// of the previous artifact, and returns the paths which changed. Unlike
+// comparing revisions, this detects changes to the contents while the
+// revision stays the same, e.g. due to a changed ignore filter or updated
+// submodules. If previous is nil, or does not exist in storage, all paths
+// in the current artifact are reported as changed.
+func (s *Storage) Delta(previous *sourcev1.Artifact, current sourcev1.Artifact) (*ArtifactDelta, error) {
+	// Archives are reproducible, identical digests mean identical contents.
+	if previous != nil && previous.Digest != "" && previous.Digest == current.Digest {
+		return &ArtifactDelta{}, nil
+	}
+
+	currentEntries, err := archiveEntryDigests(s.LocalPath(current))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current artifact '%s': %w", current.Path, err)
+	}
+	previousEntries := map[string]string{}
+	if previous != nil && s.ArtifactExist(*previous) {
+		if previousEntries, err = archiveEntryDigests(s.LocalPath(*previous)); err != nil {
+			return nil, fmt.Errorf("failed to read previous artifact '%s': %w", previous.Path, err)
+		}
+	}
+
+	delta := &ArtifactDelta{}
+	for p, d := range currentEntries {
+		if previousEntries[p] != d {
+			delta.Paths = append(delta.Paths, p)
+		}
+	}
+	for p := range previousEntries {
+		if _, ok := currentEntries[p]; !ok {
+			delta.Paths = append(delta.Paths, p)
+		}
+	}
+	sort.Strings(delta.Paths)
+	delta.Changed = len(delta.Paths) > 0
+	return delta, nil
+}
+
+// archiveEntryDigests returns a digest of the mode and contents of every
+// regular file, and of the target of every symlink in the tarball at the
+// given path, indexed by their slash separated path.
+func archiveEntryDigests(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	entries := map[string]string{}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.ToSlash(header.Name)
+		switch header.Typeflag {
+		case tar.TypeReg:
+			d := intdigest.Canonical.Digester()
+			if _, err := io.Copy(d.Hash(), tr); err != nil {
+				return nil, err
+			}
+			entries[name] = fmt.Sprintf("%o:%s", header.Mode, d.Digest())
+		case tar.TypeSymlink:
+			entries[name] = "symlink:" + header.Linkname
+		}
+	}
+	return entries, nil
+}
+
 // AtomicWriteFile atomically writes the io.Reader contents to the v1beta1.Artifact path.
-// If successful, it sets the checksum and last update time on the artifact.
+// If successful, it sets the checksum, digest and last update time on the artifact.
 func (s *Storage) AtomicWriteFile(artifact *sourcev1.Artifact, reader io.Reader, mode os.FileMode) (err error) {
 	localPath := s.LocalPath(*artifact)
-	tf, err := os.CreateTemp(filepath.Split(localPath))
+	tf, err := createTempFile(localPath)
 	if err != nil {
 		return err
 	}
@@ -463,8 +957,9 @@ func (s *Storage) AtomicWriteFile(artifact *sourcev1.Artifact, reader io.Reader,
 	}()
 
 	h := newHash()
+	d := intdigest.Canonical.Digester()
 	sz := &writeCounter{}
-	mw := io.MultiWriter(h, tf, sz)
+	mw := io.MultiWriter(h, d.Hash(), tf, sz)
 
 	if _, err := io.Copy(mw, reader); err != nil {
 		tf.Close()
@@ -483,6 +978,7 @@ func (s *Storage) AtomicWriteFile(artifact *sourcev1.Artifact, reader io.Reader,
 	}
 
 	artifact.Checksum = fmt.Sprintf("%x", h.Sum(nil))
+	artifact.Digest = d.Digest().String()
 	artifact.LastUpdateTime = metav1.Now()
 	artifact.Size = &sz.written
 
@@ -490,10 +986,10 @@ func (s *Storage) AtomicWriteFile(artifact *sourcev1.Artifact, reader io.Reader,
 }
 
 // Copy atomically copies the io.Reader contents to the v1beta1.Artifact path.
-// If successful, it sets the checksum and last update time on the artifact.
+// If successful, it sets the checksum, digest and last update time on the artifact.
 func (s *Storage) Copy(artifact *sourcev1.Artifact, reader io.Reader) (err error) {
 	localPath := s.LocalPath(*artifact)
-	tf, err := os.CreateTemp(filepath.Split(localPath))
+	tf, err := createTempFile(localPath)
 	if err != nil {
 		return err
 	}
@@ -505,8 +1001,9 @@ func (s *Storage) Copy(artifact *sourcev1.Artifact, reader io.Reader) (err error
 	}()
 
 	h := newHash()
+	d := intdigest.Canonical.Digester()
 	sz := &writeCounter{}
-	mw := io.MultiWriter(h, tf, sz)
+	mw := io.MultiWriter(h, d.Hash(), tf, sz)
 
 	if _, err := io.Copy(mw, reader); err != nil {
 		tf.Close()
@@ -521,6 +1018,7 @@ func (s *Storage) Copy(artifact *sourcev1.Artifact, reader io.Reader) (err error
 	}
 
 	artifact.Checksum = fmt.Sprintf("%x", h.Sum(nil))
+	artifact.Digest = d.Digest().String()
 	artifact.LastUpdateTime = metav1.Now()
 	artifact.Size = &sz.written
 
@@ -528,7 +1026,7 @@ func (s *Storage) Copy(artifact *sourcev1.Artifact, reader io.Reader) (err error
 }
 
 // CopyFromPath atomically copies the contents of the given path to the path of the v1beta1.Artifact.
-// If successful, the checksum and last update time on the artifact is set.
+// If successful, the checksum, digest and last update time on the artifact is set.
 func (s *Storage) CopyFromPath(artifact *sourcev1.Artifact, path string) (err error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -546,15 +1044,14 @@ func (s *Storage) CopyFromPath(artifact *sourcev1.Artifact, path string) (err er
 // CopyToPath copies the contents in the (sub)path of the given artifact to the given path.
 func (s *Storage) CopyToPath(artifact *sourcev1.Artifact, subPath, toPath string) error {
 	// create a tmp directory to store artifact
-	tmp, err := os.MkdirTemp("", "flux-include-")
+	tmp, err := util.MkdirTemp("flux-include-")
 	if err != nil {
 		return err
 	}
 	defer os.RemoveAll(tmp)
 
 	// read artifact file content
-	localPath := s.LocalPath(*artifact)
-	f, err := os.Open(localPath)
+	f, err := s.Get(*artifact)
 	if err != nil {
 		return err
 	}
@@ -562,7 +1059,7 @@ func (s *Storage) CopyToPath(artifact *sourcev1.Artifact, subPath, toPath string
 
 	// untar the artifact
 	untarPath := filepath.Join(tmp, "unpack")
-	if _, err = untar.Untar(f, untarPath); err != nil {
+	if err = sourcetar.Untar(f, untarPath); err != nil {
 		return err
 	}
 
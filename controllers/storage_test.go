@@ -18,8 +18,10 @@ package controllers
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -31,8 +33,10 @@ import (
 
 	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	intdigest "github.com/fluxcd/source-controller/internal/digest"
 )
 
 func TestStorageConstructor(t *testing.T) {
@@ -259,6 +263,287 @@ func TestStorage_Archive(t *testing.T) {
 	}
 }
 
+func TestStorage_Archive_symlinks(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		opts         []ArchiveOption
+		wantErr      string
+		wantExcluded bool
+	}{
+		{
+			name:   "preserves relative symlink",
+			target: "dir/file",
+			opts:   []ArchiveOption{WithPreservedSymlinks()},
+		},
+		{
+			name:    "rejects absolute symlink",
+			target:  "/etc/passwd",
+			opts:    []ArchiveOption{WithPreservedSymlinks()},
+			wantErr: "symlink 'link' has an absolute target '/etc/passwd'",
+		},
+		{
+			name:    "rejects escaping symlink",
+			target:  "../outside",
+			opts:    []ArchiveOption{WithPreservedSymlinks()},
+			wantErr: "symlink 'link' has a target '../outside' outside of the archive",
+		},
+		{
+			name:         "ignores symlinks by default",
+			target:       "/etc/passwd",
+			wantExcluded: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			storage, err := NewStorage(t.TempDir(), "hostname", time.Minute, 2)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			dir := t.TempDir()
+			g.Expect(os.MkdirAll(filepath.Join(dir, "dir"), 0o750)).To(Succeed())
+			g.Expect(os.WriteFile(filepath.Join(dir, "dir", "file"), []byte("file"), 0o640)).To(Succeed())
+			g.Expect(os.Symlink(tt.target, filepath.Join(dir, "link"))).To(Succeed())
+
+			artifact := sourcev1.Artifact{
+				Path: filepath.Join(randStringRunes(10), randStringRunes(10), randStringRunes(10)+".tar.gz"),
+			}
+			g.Expect(storage.MkdirAll(artifact)).To(Succeed())
+
+			err = storage.Archive(&artifact, dir, nil, tt.opts...)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			out := filepath.Join(t.TempDir(), "out")
+			g.Expect(storage.CopyToPath(&artifact, "", out)).To(Succeed())
+			g.Expect(os.ReadFile(filepath.Join(out, "dir", "file"))).To(BeEquivalentTo("file"))
+			if tt.wantExcluded {
+				_, err = os.Lstat(filepath.Join(out, "link"))
+				g.Expect(os.IsNotExist(err)).To(BeTrue())
+				return
+			}
+			g.Expect(os.Readlink(filepath.Join(out, "link"))).To(Equal(tt.target))
+			g.Expect(os.ReadFile(filepath.Join(out, "link"))).To(BeEquivalentTo("file"))
+		})
+	}
+}
+
+func TestStorage_Archive_maxFileSize(t *testing.T) {
+	files := map[string][]byte{
+		"small.yaml":       []byte("small"),
+		"exact.yaml":       []byte("0123456789"),
+		"large.bin":        bytes.Repeat([]byte("a"), 11),
+		"nested/large.bin": bytes.Repeat([]byte("b"), 100),
+	}
+
+	tests := []struct {
+		name        string
+		opts        []ArchiveOption
+		wantErr     string
+		wantFiles   []string
+		wantSkipped []string
+	}{
+		{
+			name:        "skips large files",
+			opts:        []ArchiveOption{WithMaxFileSize(10, true)},
+			wantFiles:   []string{"small.yaml", "exact.yaml", "!large.bin", "!nested/large.bin"},
+			wantSkipped: []string{"large.bin", "nested/large.bin"},
+		},
+		{
+			name:    "errors on large files",
+			opts:    []ArchiveOption{WithMaxFileSize(10, false)},
+			wantErr: "file 'large.bin' size 11 exceeds max file size 10",
+		},
+		{
+			name:      "no limit",
+			opts:      []ArchiveOption{WithMaxFileSize(0, true)},
+			wantFiles: []string{"small.yaml", "exact.yaml", "large.bin", "nested/large.bin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			storage, err := NewStorage(t.TempDir(), "hostname", time.Minute, 2)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			dir := t.TempDir()
+			for name, b := range files {
+				g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o750)).To(Succeed())
+				g.Expect(os.WriteFile(filepath.Join(dir, name), b, 0o640)).To(Succeed())
+			}
+
+			artifact := sourcev1.Artifact{
+				Path: filepath.Join(randStringRunes(10), randStringRunes(10), randStringRunes(10)+".tar.gz"),
+			}
+			g.Expect(storage.MkdirAll(artifact)).To(Succeed())
+
+			var report ArchiveReport
+			err = storage.Archive(&artifact, dir, nil, append(tt.opts, WithArchiveReport(&report))...)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(Equal(tt.wantErr))
+				var sizeErr *FileTooLargeError
+				g.Expect(errors.As(err, &sizeErr)).To(BeTrue())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(report.SkippedFiles).To(Equal(tt.wantSkipped))
+
+			for _, name := range tt.wantFiles {
+				mustExist := !strings.HasPrefix(name, "!")
+				name = strings.TrimPrefix(name, "!")
+				size, exist, err := walkTar(storage.LocalPath(artifact), name, false)
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(exist).To(Equal(mustExist), name)
+				if mustExist {
+					g.Expect(size).To(Equal(int64(len(files[name]))))
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_Archive_manifest(t *testing.T) {
+	g := NewWithT(t)
+
+	storage, err := NewStorage(t.TempDir(), "hostname", time.Minute, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	files := map[string][]byte{
+		"b.yaml":          []byte("b"),
+		"a.yaml":          []byte("aa"),
+		"nested/c.yaml":   []byte("ccc"),
+		"nested/d/e.yaml": nil,
+	}
+	dir := t.TempDir()
+	for name, b := range files {
+		g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o750)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, name), b, 0o640)).To(Succeed())
+	}
+	g.Expect(os.Symlink("a.yaml", filepath.Join(dir, "link.yaml"))).To(Succeed())
+
+	artifact := sourcev1.Artifact{
+		Path: filepath.Join(randStringRunes(10), randStringRunes(10), randStringRunes(10)+".tar.gz"),
+	}
+	g.Expect(storage.MkdirAll(artifact)).To(Succeed())
+	g.Expect(storage.Archive(&artifact, dir, nil, WithManifest())).To(Succeed())
+
+	manifest, err := storage.Manifest(artifact)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(manifest.Files).To(HaveLen(len(files)))
+	var paths []string
+	for _, f := range manifest.Files {
+		paths = append(paths, f.Path)
+		g.Expect(f.Size).To(Equal(int64(len(files[f.Path]))), f.Path)
+		g.Expect(f.Digest).To(Equal(digest.SHA256.FromBytes(files[f.Path]).String()), f.Path)
+
+		size, exist, err := walkTar(storage.LocalPath(artifact), f.Path, false)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(exist).To(BeTrue(), f.Path)
+		g.Expect(size).To(Equal(f.Size), f.Path)
+	}
+	g.Expect(paths).To(Equal([]string{"a.yaml", "b.yaml", "nested/c.yaml", "nested/d/e.yaml"}))
+
+	// Archiving without the option removes the stale manifest.
+	g.Expect(storage.Archive(&artifact, dir, nil)).To(Succeed())
+	_, err = storage.Manifest(artifact)
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+}
+
+func TestStorage_Delta(t *testing.T) {
+	g := NewWithT(t)
+
+	storage, err := NewStorage(t.TempDir(), "hostname", time.Minute, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	archive := func(files map[string]string) sourcev1.Artifact {
+		dir := t.TempDir()
+		for name, content := range files {
+			g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o750)).To(Succeed())
+			g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o640)).To(Succeed())
+		}
+		artifact := sourcev1.Artifact{
+			Path: filepath.Join(randStringRunes(10), randStringRunes(10), randStringRunes(10)+".tar.gz"),
+		}
+		g.Expect(storage.MkdirAll(artifact)).To(Succeed())
+		g.Expect(storage.Archive(&artifact, dir, nil)).To(Succeed())
+		return artifact
+	}
+
+	files := map[string]string{
+		"a.yaml":        "a",
+		"b.yaml":        "b",
+		"nested/c.yaml": "c",
+	}
+	previous := archive(files)
+
+	tests := []struct {
+		name        string
+		previous    *sourcev1.Artifact
+		files       map[string]string
+		wantChanged bool
+		wantPaths   []string
+	}{
+		{
+			name:     "identical contents",
+			previous: &previous,
+			files:    files,
+		},
+		{
+			name:     "one file modified",
+			previous: &previous,
+			files: map[string]string{
+				"a.yaml":        "a",
+				"b.yaml":        "b",
+				"nested/c.yaml": "changed",
+			},
+			wantChanged: true,
+			wantPaths:   []string{"nested/c.yaml"},
+		},
+		{
+			name:     "files added and removed",
+			previous: &previous,
+			files: map[string]string{
+				"a.yaml":        "a",
+				"nested/c.yaml": "c",
+				"nested/d.yaml": "d",
+			},
+			wantChanged: true,
+			wantPaths:   []string{"b.yaml", "nested/d.yaml"},
+		},
+		{
+			name:        "without previous artifact",
+			files:       files,
+			wantChanged: true,
+			wantPaths:   []string{"a.yaml", "b.yaml", "nested/c.yaml"},
+		},
+		{
+			name:        "previous artifact does not exist",
+			previous:    &sourcev1.Artifact{Path: "does/not/exist.tar.gz", Digest: "sha256:foo"},
+			files:       files,
+			wantChanged: true,
+			wantPaths:   []string{"a.yaml", "b.yaml", "nested/c.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			current := archive(tt.files)
+			delta, err := storage.Delta(tt.previous, current)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(delta.Changed).To(Equal(tt.wantChanged))
+			g.Expect(delta.Paths).To(Equal(tt.wantPaths))
+		})
+	}
+}
+
 func TestStorageRemoveAllButCurrent(t *testing.T) {
 	t.Run("bad directory in archive", func(t *testing.T) {
 		dir := t.TempDir()
@@ -289,6 +574,7 @@ func TestStorageRemoveAllButCurrent(t *testing.T) {
 		g.Expect(os.MkdirAll(artifactDir, 0o750)).NotTo(HaveOccurred())
 		current := []string{
 			path.Join(artifactDir, "artifact1.tar.gz"),
+			path.Join(artifactDir, "artifact1.tar.gz.manifest.json"),
 		}
 		wantDeleted := []string{
 			path.Join(artifactDir, "file1.txt"),
@@ -448,6 +734,57 @@ func TestStorageCopyFromPath(t *testing.T) {
 	}
 }
 
+func TestStorage_Copy_Digest(t *testing.T) {
+	content := []byte("that's all folks!")
+
+	tests := []struct {
+		algo       digest.Algorithm
+		wantDigest string
+	}{
+		{
+			algo:       intdigest.SHA256,
+			wantDigest: "sha256:afa7955ec925a359a4f8597b2786b673d86835dabe90f5d41628a8933500dc38",
+		},
+		{
+			algo:       intdigest.SHA512,
+			wantDigest: "sha512:d6ebe13c516432188b963b9f767c7fe785cee0c1526523c8028e27c53b3f4a35d7404aed41c2d27149a1df18a92c77ceabf767286bfe361c32b4513901335371",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algo.String(), func(t *testing.T) {
+			g := NewWithT(t)
+
+			oldCanonical := intdigest.Canonical
+			intdigest.Canonical = tt.algo
+			defer func() {
+				intdigest.Canonical = oldCanonical
+			}()
+
+			storage, err := NewStorage(t.TempDir(), "hostname", time.Minute, 2)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			artifact := sourcev1.Artifact{
+				Path: filepath.Join(randStringRunes(10), randStringRunes(10), randStringRunes(10)),
+			}
+			g.Expect(storage.MkdirAll(artifact)).To(Succeed())
+			g.Expect(storage.Copy(&artifact, bytes.NewReader(content))).To(Succeed())
+
+			g.Expect(artifact.Checksum).To(Equal(storage.Checksum(bytes.NewReader(content))))
+			g.Expect(artifact.Digest).To(Equal(tt.wantDigest))
+
+			d, err := digest.Parse(artifact.Digest)
+			g.Expect(err).ToNot(HaveOccurred())
+			f, err := os.Open(storage.LocalPath(artifact))
+			g.Expect(err).ToNot(HaveOccurred())
+			defer f.Close()
+			v := d.Verifier()
+			_, err = io.Copy(v, f)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(v.Verified()).To(BeTrue())
+		})
+	}
+}
+
 func TestStorage_getGarbageFiles(t *testing.T) {
 	artifactFolder := path.Join("foo", "bar")
 	tests := []struct {
@@ -658,3 +995,159 @@ func TestStorage_GarbageCollect(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_GarbageCollect_options(t *testing.T) {
+	artifactFolder := path.Join("foo", "bar")
+	tests := []struct {
+		name        string
+		opts        []GarbageCollectOption
+		wantDeleted []string
+		wantGone    []string
+		wantExist   []string
+	}{
+		{
+			name: "storage retention",
+			wantDeleted: []string{
+				"artifact1.tar.gz", "artifact2.tar.gz", "artifact3.tar.gz", "artifact4.tar.gz",
+				"orphan.tar.gz.lock", "orphan.tar.gz.manifest.json", "artifact4.tar.gz.123.tmp",
+			},
+			wantGone: []string{"artifact1.tar.gz.lock", "artifact1.tar.gz.manifest.json"},
+			wantExist: []string{
+				"artifact5.tar.gz", "artifact5.tar.gz.lock", "artifact5.tar.gz.manifest.json", "artifact6.tar.gz.456.tmp",
+			},
+		},
+		{
+			name:        "retention records",
+			opts:        []GarbageCollectOption{WithRetention(6*time.Hour, 2)},
+			wantDeleted: []string{"artifact1.tar.gz", "artifact2.tar.gz", "artifact3.tar.gz"},
+			wantGone:    []string{"artifact1.tar.gz.lock", "artifact1.tar.gz.manifest.json"},
+			wantExist: []string{
+				"artifact4.tar.gz", "artifact5.tar.gz", "artifact5.tar.gz.lock", "artifact5.tar.gz.manifest.json",
+				"orphan.tar.gz.lock", "orphan.tar.gz.manifest.json",
+				"artifact4.tar.gz.123.tmp", "artifact6.tar.gz.456.tmp",
+			},
+		},
+		{
+			name:        "retention TTL",
+			opts:        []GarbageCollectOption{WithRetention(150*time.Minute, 10)},
+			wantDeleted: []string{"artifact1.tar.gz", "orphan.tar.gz.lock", "orphan.tar.gz.manifest.json"},
+			wantGone:    []string{"artifact1.tar.gz.lock", "artifact1.tar.gz.manifest.json"},
+			wantExist: []string{
+				"artifact2.tar.gz", "artifact3.tar.gz", "artifact4.tar.gz", "artifact5.tar.gz",
+				"artifact5.tar.gz.lock", "artifact5.tar.gz.manifest.json", "artifact4.tar.gz.123.tmp", "artifact6.tar.gz.456.tmp",
+			},
+		},
+		{
+			name: "dry run",
+			opts: []GarbageCollectOption{WithDryRun()},
+			wantDeleted: []string{
+				"artifact1.tar.gz", "artifact2.tar.gz", "artifact3.tar.gz", "artifact4.tar.gz",
+				"orphan.tar.gz.lock", "orphan.tar.gz.manifest.json", "artifact4.tar.gz.123.tmp",
+			},
+			wantExist: []string{
+				"artifact1.tar.gz", "artifact2.tar.gz", "artifact3.tar.gz", "artifact4.tar.gz", "artifact5.tar.gz",
+				"artifact1.tar.gz.lock", "artifact5.tar.gz.lock", "orphan.tar.gz.lock",
+				"artifact1.tar.gz.manifest.json", "artifact5.tar.gz.manifest.json", "orphan.tar.gz.manifest.json",
+				"artifact4.tar.gz.123.tmp", "artifact6.tar.gz.456.tmp",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			dir := t.TempDir()
+
+			s, err := NewStorage(dir, "hostname", time.Minute*30, 2)
+			g.Expect(err).ToNot(HaveOccurred(), "failed to create new storage")
+
+			g.Expect(os.MkdirAll(path.Join(dir, artifactFolder), 0o750)).To(Succeed())
+			now := time.Now()
+			files := []struct {
+				name string
+				age  time.Duration
+			}{
+				{name: "artifact1.tar.gz", age: 5 * time.Hour},
+				{name: "artifact1.tar.gz.lock", age: 5 * time.Hour},
+				{name: "artifact1.tar.gz.manifest.json", age: 5 * time.Hour},
+				{name: "artifact2.tar.gz", age: 2 * time.Hour},
+				{name: "artifact3.tar.gz", age: 90 * time.Minute},
+				{name: "artifact4.tar.gz", age: 45 * time.Minute},
+				{name: "artifact4.tar.gz.123.tmp", age: time.Hour},
+				{name: "artifact5.tar.gz", age: time.Minute},
+				{name: "artifact5.tar.gz.lock", age: 5 * time.Hour},
+				{name: "artifact5.tar.gz.manifest.json", age: 5 * time.Hour},
+				{name: "artifact6.tar.gz.456.tmp", age: time.Second},
+				{name: "orphan.tar.gz.lock", age: 5 * time.Hour},
+				{name: "orphan.tar.gz.manifest.json", age: 5 * time.Hour},
+			}
+			for _, f := range files {
+				p := path.Join(dir, artifactFolder, f.name)
+				g.Expect(os.WriteFile(p, nil, 0o600)).To(Succeed())
+				g.Expect(os.Chtimes(p, now.Add(-f.age), now.Add(-f.age))).To(Succeed())
+			}
+
+			artifact := sourcev1.Artifact{
+				Path: path.Join(artifactFolder, "artifact5.tar.gz"),
+			}
+			deletedPaths, err := s.GarbageCollect(context.TODO(), artifact, time.Second*5, tt.opts...)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var wantDeleted []string
+			for _, f := range tt.wantDeleted {
+				wantDeleted = append(wantDeleted, path.Join(dir, artifactFolder, f))
+			}
+			g.Expect(deletedPaths).To(ConsistOf(wantDeleted))
+			for _, f := range tt.wantExist {
+				g.Expect(path.Join(dir, artifactFolder, f)).To(BeAnExistingFile())
+			}
+			for _, f := range tt.wantGone {
+				g.Expect(path.Join(dir, artifactFolder, f)).ToNot(BeAnExistingFile())
+			}
+			for _, f := range deletedPaths {
+				if !stringInSlice(filepath.Base(f), tt.wantExist) {
+					g.Expect(f).ToNot(BeAnExistingFile())
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_GarbageCollect_concurrentWriter(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+
+	s, err := NewStorage(dir, "hostname", time.Minute, 1)
+	g.Expect(err).ToNot(HaveOccurred(), "failed to create new storage")
+
+	current := sourcev1.Artifact{Path: path.Join("foo", "bar", "current.tar.gz")}
+	g.Expect(s.Put(&current, strings.NewReader("current"))).To(Succeed())
+	old := sourcev1.Artifact{Path: path.Join("foo", "bar", "old.tar.gz")}
+	g.Expect(s.Put(&old, strings.NewReader("old"))).To(Succeed())
+	past := time.Now().Add(-time.Hour)
+	g.Expect(os.Chtimes(s.LocalPath(old), past, past)).To(Succeed())
+
+	// Hold the lock of the garbage artifact while it is rewritten.
+	unlock, err := s.Lock(old)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	type result struct {
+		deleted []string
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		deleted, err := s.GarbageCollect(context.TODO(), current, time.Second*5)
+		done <- result{deleted: deleted, err: err}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	g.Expect(s.Copy(&old, strings.NewReader("rewritten"))).To(Succeed())
+	unlock()
+
+	res := <-done
+	g.Expect(res.err).ToNot(HaveOccurred())
+	g.Expect(res.deleted).To(BeEmpty())
+	g.Expect(s.LocalPath(old)).To(BeAnExistingFile())
+	g.Expect(s.LocalPath(current)).To(BeAnExistingFile())
+}
@@ -17,6 +17,8 @@ import (
 	"runtime"
 	"sync"
 	"time"
+
+	"k8s.io/utils/clock"
 )
 
 // Cache is a thread-safe in-memory key/value store.
@@ -39,6 +41,9 @@ type cache struct {
 	MaxItems int
 	mu       sync.RWMutex
 	janitor  *janitor
+
+	// Clock is used to determine the expiration of items.
+	Clock clock.PassiveClock
 }
 
 // ItemCount returns the number of items in the cache.
@@ -53,7 +58,7 @@ func (c *cache) ItemCount() int {
 func (c *cache) set(key string, value interface{}, expiration time.Duration) {
 	var e int64
 	if expiration > 0 {
-		e = time.Now().Add(expiration).UnixNano()
+		e = c.Clock.Now().Add(expiration).UnixNano()
 	}
 
 	c.Items[key] = Item{
@@ -115,7 +120,7 @@ func (c *cache) Get(key string) (interface{}, bool) {
 		return nil, false
 	}
 	if item.Expiration > 0 {
-		if item.Expiration < time.Now().UnixNano() {
+		if item.Expiration < c.Clock.Now().UnixNano() {
 			c.mu.RUnlock()
 			return nil, false
 		}
@@ -149,7 +154,7 @@ func (c *cache) HasExpired(key string) bool {
 		return true
 	}
 	if item.Expiration > 0 {
-		if item.Expiration < time.Now().UnixNano() {
+		if item.Expiration < c.Clock.Now().UnixNano() {
 			c.mu.RUnlock()
 			return true
 		}
@@ -167,7 +172,7 @@ func (c *cache) SetExpiration(key string, expiration time.Duration) {
 		c.mu.Unlock()
 		return
 	}
-	item.Expiration = time.Now().Add(expiration).UnixNano()
+	item.Expiration = c.Clock.Now().Add(expiration).UnixNano()
 	c.mu.Unlock()
 }
 
@@ -182,20 +187,20 @@ func (c *cache) GetExpiration(key string) time.Duration {
 		return 0
 	}
 	if item.Expiration > 0 {
-		if item.Expiration < time.Now().UnixNano() {
+		if item.Expiration < c.Clock.Now().UnixNano() {
 			c.mu.RUnlock()
 			return 0
 		}
 	}
 	c.mu.RUnlock()
-	return time.Duration(item.Expiration - time.Now().UnixNano())
+	return time.Duration(item.Expiration - c.Clock.Now().UnixNano())
 }
 
 // DeleteExpired deletes all expired items from the cache.
 func (c *cache) DeleteExpired() {
 	c.mu.Lock()
 	for k, v := range c.Items {
-		if v.Expiration > 0 && v.Expiration < time.Now().UnixNano() {
+		if v.Expiration > 0 && v.Expiration < c.Clock.Now().UnixNano() {
 			delete(c.Items, k)
 		}
 	}
@@ -229,6 +234,7 @@ func New(maxItems int, interval time.Duration) *Cache {
 	c := &cache{
 		Items:    make(map[string]Item),
 		MaxItems: maxItems,
+		Clock:    clock.RealClock{},
 		janitor: &janitor{
 			interval: interval,
 			stop:     make(chan bool),
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
 )
 
 func TestCache(t *testing.T) {
@@ -85,3 +86,31 @@ func TestCache(t *testing.T) {
 	g.Expect(found).To(BeFalse())
 	g.Expect(item).To(BeNil())
 }
+
+func TestCache_expiration(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeClock := clocktesting.NewFakeClock(time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC))
+	cache := New(3, 0)
+	cache.Clock = fakeClock
+
+	g.Expect(cache.Add("short", "value", time.Minute)).To(Succeed())
+	g.Expect(cache.Add("long", "value", time.Hour)).To(Succeed())
+	g.Expect(cache.Add("forever", "value", 0)).To(Succeed())
+	g.Expect(cache.GetExpiration("short")).To(Equal(time.Minute))
+
+	fakeClock.Step(time.Minute + time.Second)
+	_, found := cache.Get("short")
+	g.Expect(found).To(BeFalse())
+	g.Expect(cache.HasExpired("short")).To(BeTrue())
+	g.Expect(cache.GetExpiration("short")).To(BeZero())
+	g.Expect(cache.GetExpiration("long")).To(Equal(59*time.Minute - time.Second))
+	_, found = cache.Get("long")
+	g.Expect(found).To(BeTrue())
+
+	fakeClock.Step(time.Hour)
+	cache.DeleteExpired()
+	g.Expect(cache.ItemCount()).To(Equal(1))
+	_, found = cache.Get("forever")
+	g.Expect(found).To(BeTrue())
+}
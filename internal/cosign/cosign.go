@@ -0,0 +1,233 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cosign verifies detached signatures of blobs, as created with
+// 'cosign sign-blob --key', using the public key of the signing key pair.
+// Signatures created with a short-lived signing certificate, as written by
+// 'cosign sign-blob --output-certificate', can be verified using the root
+// certificates of the issuer, which also allows verifying their freshness.
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrSignatureMismatch is returned when a signature could not be verified
+// with any of the public keys.
+var ErrSignatureMismatch = errors.New("signature does not match any of the public keys")
+
+// StaleSignatureError is returned when the signing time of a signature is
+// older than the max age.
+type StaleSignatureError struct {
+	// SignedAt is the signing time of the signature.
+	SignedAt time.Time
+	// MaxAge is the max allowed age of the signature.
+	MaxAge time.Duration
+}
+
+func (e *StaleSignatureError) Error() string {
+	return fmt.Sprintf("signature signed at %s is older than max age %s", e.SignedAt.UTC().Format(time.RFC3339), e.MaxAge)
+}
+
+// VerifyBlob verifies the base64 encoded signature of the blob read from r
+// with the given PEM encoded public keys. It returns nil if the signature is
+// valid for any of the keys, ErrSignatureMismatch if it is not valid for any
+// key, or an error if the signature or a key can not be parsed.
+// ECDSA and RSA (PKCS #1 v1.5) public keys are supported.
+func VerifyBlob(r io.Reader, signature []byte, publicKeys ...[]byte) error {
+	if len(publicKeys) == 0 {
+		return errors.New("no public keys to verify signature with")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	digest, err := digestBlob(r)
+	if err != nil {
+		return err
+	}
+
+	for i, k := range publicKeys {
+		pub, err := LoadPublicKey(k)
+		if err != nil {
+			return fmt.Errorf("invalid public key at index %d: %w", i, err)
+		}
+		if verifyDigest(pub, digest, sig) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+// LoadPublicKey parses the given PEM encoded PKIX public key. It returns an
+// error if the key is not an ECDSA or RSA public key.
+func LoadPublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch pub.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// VerifyBlobWithCertificate verifies the base64 encoded signature of the
+// blob read from r with the public key of the given PEM encoded signing
+// certificate, and returns the signing time. The certificate must be issued
+// for code signing by one of the given PEM encoded root certificates, either
+// directly or through intermediate certificates which follow the signing
+// certificate in the PEM data. It returns ErrSignatureMismatch if the
+// signature was not created with the key of the certificate.
+//
+// The signing time is the start of the validity period of the certificate.
+// The signature can not have been created before, as the key pair of the
+// certificate did not exist yet. As signing certificates are short-lived,
+// the certificate chain is verified at the signing time.
+func VerifyBlobWithCertificate(r io.Reader, signature, certificate []byte, roots ...[]byte) (time.Time, error) {
+	if len(roots) == 0 {
+		return time.Time{}, errors.New("no root certificates to verify certificate with")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	chain, err := parseCertificates(certificate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	if len(chain) == 0 {
+		return time.Time{}, errors.New("no PEM block found in certificate")
+	}
+	cert := chain[0]
+
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   cert.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	for i, root := range roots {
+		certs, err := parseCertificates(root)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid root certificate at index %d: %w", i, err)
+		}
+		if len(certs) == 0 {
+			return time.Time{}, fmt.Errorf("invalid root certificate at index %d: no PEM block found", i)
+		}
+		for _, c := range certs {
+			opts.Roots.AddCert(c)
+		}
+	}
+	for _, c := range chain[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if _, err = cert.Verify(opts); err != nil {
+		return time.Time{}, fmt.Errorf("failed to verify certificate: %w", err)
+	}
+
+	switch cert.PublicKey.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return time.Time{}, fmt.Errorf("unsupported certificate public key type %T", cert.PublicKey)
+	}
+	digest, err := digestBlob(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !verifyDigest(cert.PublicKey, digest, sig) {
+		return time.Time{}, ErrSignatureMismatch
+	}
+	return cert.NotBefore, nil
+}
+
+// VerifySigningTime verifies the given signing time is within maxAge of
+// now, which guards against replaying old signed blobs. It returns a
+// StaleSignatureError if the signature is too old.
+func VerifySigningTime(signedAt time.Time, maxAge time.Duration, now time.Time) error {
+	if now.Sub(signedAt) > maxAge {
+		return &StaleSignatureError{SignedAt: signedAt, MaxAge: maxAge}
+	}
+	return nil
+}
+
+// IsCertificate returns if the given data is a PEM encoded certificate, as
+// opposed to a public key.
+func IsCertificate(data []byte) bool {
+	block, _ := pem.Decode(data)
+	return block != nil && block.Type == "CERTIFICATE"
+}
+
+// digestBlob returns the SHA-256 digest of the blob read from r.
+func digestBlob(r io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("failed to calculate digest: %w", err)
+	}
+	return h.Sum(nil), nil
+}
+
+// verifyDigest returns if sig is a valid signature of digest for the given
+// ECDSA or RSA (PKCS #1 v1.5) public key.
+func verifyDigest(pub crypto.PublicKey, digest, sig []byte) bool {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(pub, digest, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig) == nil
+	default:
+		return false
+	}
+}
+
+// parseCertificates parses the PEM encoded certificates in data.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
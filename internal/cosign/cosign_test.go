@@ -0,0 +1,374 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestVerifyBlob(t *testing.T) {
+	blob := []byte("artifact content")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(blob)
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		blob       []byte
+		signature  []byte
+		publicKeys [][]byte
+		wantErr    error
+		wantErrStr string
+	}{
+		{
+			name:       "valid ECDSA signature",
+			blob:       blob,
+			signature:  encodeSignature(ecSig),
+			publicKeys: [][]byte{encodePublicKey(t, &ecKey.PublicKey)},
+		},
+		{
+			name:       "valid RSA signature",
+			blob:       blob,
+			signature:  encodeSignature(rsaSig),
+			publicKeys: [][]byte{encodePublicKey(t, &rsaKey.PublicKey)},
+		},
+		{
+			name:       "valid signature for one of the keys",
+			blob:       blob,
+			signature:  encodeSignature(ecSig),
+			publicKeys: [][]byte{encodePublicKey(t, &otherKey.PublicKey), encodePublicKey(t, &ecKey.PublicKey)},
+		},
+		{
+			name:       "tampered blob",
+			blob:       []byte("tampered content"),
+			signature:  encodeSignature(ecSig),
+			publicKeys: [][]byte{encodePublicKey(t, &ecKey.PublicKey)},
+			wantErr:    ErrSignatureMismatch,
+		},
+		{
+			name:       "other key",
+			blob:       blob,
+			signature:  encodeSignature(ecSig),
+			publicKeys: [][]byte{encodePublicKey(t, &otherKey.PublicKey)},
+			wantErr:    ErrSignatureMismatch,
+		},
+		{
+			name:       "malformed signature",
+			blob:       blob,
+			signature:  []byte("not base64!"),
+			publicKeys: [][]byte{encodePublicKey(t, &ecKey.PublicKey)},
+			wantErrStr: "failed to decode signature",
+		},
+		{
+			name:       "unsupported public key",
+			blob:       blob,
+			signature:  encodeSignature(ecSig),
+			publicKeys: [][]byte{encodePublicKey(t, edPub)},
+			wantErrStr: "unsupported public key type",
+		},
+		{
+			name:       "no public keys",
+			blob:       blob,
+			signature:  encodeSignature(ecSig),
+			wantErrStr: "no public keys",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := VerifyBlob(bytes.NewReader(tt.blob), tt.signature, tt.publicKeys...)
+			switch {
+			case tt.wantErr != nil:
+				g.Expect(errors.Is(err, tt.wantErr)).To(BeTrue())
+			case tt.wantErrStr != "":
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErrStr))
+			default:
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestVerifyBlobWithCertificate(t *testing.T) {
+	blob := []byte("artifact content")
+	signedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := createCertificate(t, rootKey, nil, nil, signedAt.Add(-time.Hour), true)
+	otherRootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoot := createCertificate(t, otherRootKey, nil, nil, signedAt.Add(-time.Hour), true)
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate := createCertificate(t, intermediateKey, root, rootKey, signedAt.Add(-time.Hour), true)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(blob)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := createCertificate(t, key, root, rootKey, signedAt, false)
+	intermediateCert := createCertificate(t, key, intermediate, intermediateKey, signedAt, false)
+	selfSignedCert := createCertificate(t, key, nil, nil, signedAt, false)
+	otherKeyCert := createCertificate(t, otherKey, root, rootKey, signedAt, false)
+
+	tests := []struct {
+		name        string
+		blob        []byte
+		certificate []byte
+		roots       [][]byte
+		wantErr     error
+		wantErrStr  string
+	}{
+		{
+			name:        "valid signature",
+			blob:        blob,
+			certificate: encodeCertificates(cert),
+			roots:       [][]byte{encodeCertificates(otherRoot), encodeCertificates(root)},
+		},
+		{
+			name:        "valid signature with intermediate",
+			blob:        blob,
+			certificate: encodeCertificates(intermediateCert, intermediate),
+			roots:       [][]byte{encodeCertificates(root)},
+		},
+		{
+			name:        "tampered blob",
+			blob:        []byte("tampered content"),
+			certificate: encodeCertificates(cert),
+			roots:       [][]byte{encodeCertificates(root)},
+			wantErr:     ErrSignatureMismatch,
+		},
+		{
+			name:        "certificate of other key",
+			blob:        blob,
+			certificate: encodeCertificates(otherKeyCert),
+			roots:       [][]byte{encodeCertificates(root)},
+			wantErr:     ErrSignatureMismatch,
+		},
+		{
+			name:        "self-signed certificate",
+			blob:        blob,
+			certificate: encodeCertificates(selfSignedCert),
+			roots:       [][]byte{encodeCertificates(root)},
+			wantErrStr:  "failed to verify certificate",
+		},
+		{
+			name:        "certificate of untrusted root",
+			blob:        blob,
+			certificate: encodeCertificates(cert),
+			roots:       [][]byte{encodeCertificates(otherRoot)},
+			wantErrStr:  "failed to verify certificate",
+		},
+		{
+			name:        "invalid certificate",
+			blob:        blob,
+			certificate: []byte("invalid"),
+			roots:       [][]byte{encodeCertificates(root)},
+			wantErrStr:  "no PEM block found",
+		},
+		{
+			name:        "no root certificates",
+			blob:        blob,
+			certificate: encodeCertificates(cert),
+			wantErrStr:  "no root certificates",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := VerifyBlobWithCertificate(bytes.NewReader(tt.blob), encodeSignature(sig), tt.certificate, tt.roots...)
+			switch {
+			case tt.wantErr != nil:
+				g.Expect(errors.Is(err, tt.wantErr)).To(BeTrue())
+			case tt.wantErrStr != "":
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErrStr))
+			default:
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(got).To(BeTemporally("==", signedAt))
+			}
+		})
+	}
+}
+
+func TestVerifySigningTime(t *testing.T) {
+	now := time.Now()
+	const maxAge = time.Hour
+
+	tests := []struct {
+		name      string
+		signedAt  time.Time
+		wantStale bool
+	}{
+		{
+			name:     "recent signature",
+			signedAt: now.Add(-time.Minute),
+		},
+		{
+			name:      "aged signature",
+			signedAt:  now.Add(-2 * maxAge),
+			wantStale: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := VerifySigningTime(tt.signedAt, maxAge, now)
+			if tt.wantStale {
+				var staleErr *StaleSignatureError
+				g.Expect(errors.As(err, &staleErr)).To(BeTrue())
+				g.Expect(staleErr.MaxAge).To(Equal(maxAge))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
+
+func TestIsCertificate(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(IsCertificate(encodeCertificates(createCertificate(t, key, nil, nil, time.Now(), true)))).To(BeTrue())
+	g.Expect(IsCertificate(encodePublicKey(t, key.Public()))).To(BeFalse())
+	g.Expect(IsCertificate([]byte("invalid"))).To(BeFalse())
+}
+
+func encodeSignature(sig []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+func encodePublicKey(t *testing.T, pub crypto.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// createCertificate creates a certificate for the given key, issued by
+// the parent with its key, or self-signed if parent is nil. Leaf
+// certificates are valid for code signing for ten minutes.
+func createCertificate(t *testing.T, key *ecdsa.PrivateKey, parent *x509.Certificate, parentKey *ecdsa.PrivateKey,
+	notBefore time.Time, isCA bool) *x509.Certificate {
+	t.Helper()
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "signer"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(10 * time.Minute),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		BasicConstraintsValid: true,
+	}
+	if isCA {
+		tmpl.Subject.CommonName = "ca"
+		tmpl.NotAfter = notBefore.Add(24 * time.Hour)
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		tmpl.ExtKeyUsage = nil
+		tmpl.IsCA = true
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func encodeCertificates(certs ...*x509.Certificate) []byte {
+	var b []byte
+	for _, c := range certs {
+		b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	return b
+}
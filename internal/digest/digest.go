@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package digest
+
+import (
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	SHA256 = digest.SHA256
+	SHA512 = digest.SHA512
+)
+
+var (
+	// Canonical is the primary digest algorithm used to calculate the digest
+	// of an Artifact. It can be changed to any of the supported algorithms
+	// during startup.
+	Canonical = SHA256
+)
+
+// supported contains the digest algorithms which can be selected as
+// Canonical.
+var supported = []digest.Algorithm{SHA256, SHA512}
+
+// AlgorithmForName returns the digest algorithm for the given name, or an
+// error if the algorithm is not supported.
+func AlgorithmForName(name string) (digest.Algorithm, error) {
+	a := digest.Algorithm(name)
+	for _, s := range supported {
+		if a == s && a.Available() {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", digest.ErrDigestUnsupported, name)
+}
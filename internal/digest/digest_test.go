@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package digest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestAlgorithmForName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    digest.Algorithm
+		wantErr error
+	}{
+		{
+			name: "sha256",
+			want: SHA256,
+		},
+		{
+			name: "sha512",
+			want: SHA512,
+		},
+		{
+			name:    "sha384",
+			wantErr: digest.ErrDigestUnsupported,
+		},
+		{
+			name:    "md5",
+			wantErr: digest.ErrDigestUnsupported,
+		},
+		{
+			name:    "",
+			wantErr: digest.ErrDigestUnsupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := AlgorithmForName(tt.name)
+			if tt.wantErr != nil {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(errors.Is(err, tt.wantErr)).To(BeTrue())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	content := []byte("that's all folks!")
+
+	tests := []struct {
+		algo digest.Algorithm
+		want digest.Digest
+	}{
+		{
+			algo: SHA256,
+			want: "sha256:afa7955ec925a359a4f8597b2786b673d86835dabe90f5d41628a8933500dc38",
+		},
+		{
+			algo: SHA512,
+			want: "sha512:d6ebe13c516432188b963b9f767c7fe785cee0c1526523c8028e27c53b3f4a35d7404aed41c2d27149a1df18a92c77ceabf767286bfe361c32b4513901335371",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algo.String(), func(t *testing.T) {
+			g := NewWithT(t)
+
+			d, err := tt.algo.FromReader(bytes.NewReader(content))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(d).To(Equal(tt.want))
+
+			parsed, err := digest.Parse(d.String())
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(parsed.Algorithm()).To(Equal(tt.algo))
+			g.Expect(parsed.Encoded()).To(Equal(d.Encoded()))
+
+			v := parsed.Verifier()
+			_, err = v.Write(content)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(v.Verified()).To(BeTrue())
+
+			v = parsed.Verifier()
+			_, err = v.Write([]byte("that's not all folks!"))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(v.Verified()).To(BeFalse())
+		})
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxDurationError is returned by WithMaxDuration if the fetch did not
+// complete within the max duration.
+type MaxDurationError struct {
+	MaxDuration time.Duration
+	Err         error
+}
+
+// Error returns the error string.
+func (e *MaxDurationError) Error() string {
+	return fmt.Sprintf("fetch exceeded max duration of %s: %s", e.MaxDuration, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *MaxDurationError) Unwrap() error {
+	return e.Err
+}
+
+// WithMaxDuration calls fn with a context derived from ctx, which is
+// canceled once maxDuration elapsed. This caps the duration of the fetch
+// performed by fn, even if the deadline of ctx is later. If fn fails after
+// the max duration elapsed, the error is returned as a MaxDurationError.
+// Errors caused by the cancellation or deadline of ctx itself are returned
+// as is. A maxDuration of zero or less disables the cap.
+func WithMaxDuration(ctx context.Context, maxDuration time.Duration, fn func(ctx context.Context) error) error {
+	if maxDuration <= 0 {
+		return fn(ctx)
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, maxDuration)
+	defer cancel()
+
+	err := fn(fetchCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(fetchCtx.Err(), context.DeadlineExceeded) {
+		return &MaxDurationError{MaxDuration: maxDuration, Err: err}
+	}
+	return err
+}
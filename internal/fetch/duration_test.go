@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWithMaxDuration(t *testing.T) {
+	// An artificially slow server, which responds after the delay given by
+	// the request, or once the request is canceled.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay, err := time.ParseDuration(r.URL.Query().Get("delay"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		select {
+		case <-time.After(delay):
+			_, _ = w.Write([]byte("source"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	fetch := func(delay string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?delay="+delay, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			return resp.Body.Close()
+		}
+	}
+
+	tests := []struct {
+		name            string
+		timeout         time.Duration
+		maxDuration     time.Duration
+		delay           string
+		wantErr         bool
+		wantMaxDuration bool
+	}{
+		{
+			name:        "fetch within max duration",
+			maxDuration: 5 * time.Second,
+			delay:       "0s",
+		},
+		{
+			name:            "fetch exceeding max duration",
+			timeout:         time.Minute,
+			maxDuration:     100 * time.Millisecond,
+			delay:           "10s",
+			wantErr:         true,
+			wantMaxDuration: true,
+		},
+		{
+			name:        "fetch exceeding context deadline",
+			timeout:     100 * time.Millisecond,
+			maxDuration: time.Minute,
+			delay:       "10s",
+			wantErr:     true,
+		},
+		{
+			name:    "max duration disabled",
+			timeout: time.Minute,
+			delay:   "200ms",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			err := WithMaxDuration(ctx, tt.maxDuration, fetch(tt.delay))
+			if !tt.wantErr {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+			g.Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+
+			var maxErr *MaxDurationError
+			g.Expect(errors.As(err, &maxErr)).To(Equal(tt.wantMaxDuration))
+			if tt.wantMaxDuration {
+				g.Expect(maxErr.MaxDuration).To(Equal(tt.maxDuration))
+				g.Expect(err.Error()).To(HavePrefix("fetch exceeded max duration of 100ms: "))
+			}
+		})
+	}
+}
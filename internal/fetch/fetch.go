@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fetch provides a uniform, JSON-serializable snapshot of the fetch
+// of a source, shared by all kinds of sources to report on fetches.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/fluxcd/pkg/runtime/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	serror "github.com/fluxcd/source-controller/internal/error"
+)
+
+// Result is a snapshot of the fetch of a source. Fields which are not known
+// for a kind of source are omitted.
+type Result struct {
+	// Kind of the source object, e.g. GitRepository.
+	Kind string `json:"kind"`
+	// Namespace of the source object.
+	Namespace string `json:"namespace"`
+	// Name of the source object.
+	Name string `json:"name"`
+	// URL the source was fetched from, without any password, see RedactURL.
+	URL string `json:"url"`
+	// Revision of the fetched source.
+	Revision string `json:"revision,omitempty"`
+	// Digest of the fetched source, e.g. the commit SHA of a Git repository.
+	Digest string `json:"digest,omitempty"`
+	// Size of the fetched source in bytes.
+	Size int64 `json:"size,omitempty"`
+	// Duration of the fetch.
+	Duration metav1.Duration `json:"duration"`
+	// FromCache is true if the source was not downloaded again, because it
+	// did not change since it was last fetched.
+	FromCache bool `json:"fromCache"`
+	// Error of the fetch, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder records the Results of fetches.
+type Recorder interface {
+	RecordFetch(ctx context.Context, result Result)
+}
+
+// RecorderFunc is a function implementing Recorder.
+type RecorderFunc func(ctx context.Context, result Result)
+
+// RecordFetch calls f(ctx, result).
+func (f RecorderFunc) RecordFetch(ctx context.Context, result Result) {
+	f(ctx, result)
+}
+
+// LogRecorder is a Recorder logging the Results with the logger from the
+// context, at debug level.
+type LogRecorder struct{}
+
+// RecordFetch logs the Result.
+func (LogRecorder) RecordFetch(ctx context.Context, result Result) {
+	ctrl.LoggerFrom(ctx).V(logger.DebugLevel).Info("source fetched", "fetch", result)
+}
+
+// Record sets the Duration since start and the Error of the Result, and
+// records it with the Recorder. An error which is ignored because the fetch
+// was a no-op is not recorded as an Error. It is a no-op if the Recorder is
+// nil.
+func Record(ctx context.Context, recorder Recorder, result Result, start time.Time, err error) {
+	if recorder == nil {
+		return
+	}
+	result.Duration = metav1.Duration{Duration: time.Since(start)}
+	if ge := (&serror.Generic{}); err != nil && !(errors.As(err, &ge) && ge.Ignore) {
+		result.Error = err.Error()
+	}
+	recorder.RecordFetch(ctx, result)
+}
+
+// RedactURL returns the given URL with any password replaced by "xxxxx", as
+// URLs may contain basic auth credentials. A URL which can not be parsed is
+// returned as is.
+func RedactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return parsed.Redacted()
+}
+
+// DirSize returns the total size of the regular files in the given
+// directory, to set as the Size of a Result. Directories with a name in
+// skip, like the .git directory of a clone, are not counted.
+func DirSize(dir string, skip ...string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			for _, s := range skip {
+				if p != dir && d.Name() == s {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += fi.Size()
+		return nil
+	})
+	return size, err
+}
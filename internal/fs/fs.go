@@ -116,6 +116,39 @@ func CopyDir(src, dst string) error {
 	return nil
 }
 
+// CopyDirContents copies the entries of the src directory into the existing
+// dst directory, except for the entries with one of the excluded names.
+// Existing entries of dst with the same name must not be directories.
+func CopyDirContents(src, dst string, exclude ...string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("cannot read directory %s: %w", src, err)
+	}
+
+entries:
+	for _, entry := range entries {
+		for _, name := range exclude {
+			if entry.Name() == name {
+				continue entries
+			}
+		}
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		if entry.IsDir() {
+			if err = CopyDir(srcPath, dstPath); err != nil {
+				return fmt.Errorf("copying directory failed: %w", err)
+			}
+		} else {
+			if err = copyFile(srcPath, dstPath); err != nil {
+				return fmt.Errorf("copying file failed: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
 // copyFile copies the contents of the file named src to the file named
 // by dst. The file will be created if it does not already exist. If the
 // destination file exists, all its contents will be replaced by the contents
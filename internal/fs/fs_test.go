@@ -131,6 +131,42 @@ func TestCopyDir(t *testing.T) {
 	}
 }
 
+func TestCopyDirContents(t *testing.T) {
+	dir := t.TempDir()
+
+	srcdir := filepath.Join(dir, "src")
+	for _, p := range []string{"myfile", filepath.Join("subdir", "file"), filepath.Join(".git", "HEAD")} {
+		fn := filepath.Join(srcdir, p)
+		if err := os.MkdirAll(filepath.Dir(fn), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(p), 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	destdir := filepath.Join(dir, "dest")
+	if err := os.MkdirAll(destdir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyDirContents(srcdir, destdir, ".git"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"myfile", filepath.Join("subdir", "file")} {
+		got, err := os.ReadFile(filepath.Join(destdir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != p {
+			t.Fatalf("expected: %s, got: %s", p, string(got))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destdir, ".git")); !os.IsNotExist(err) {
+		t.Fatalf("expected excluded .git not to be copied, got: %v", err)
+	}
+}
+
 func TestCopyDirFail_SrcInaccessible(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		// XXX: setting permissions works differently in
@@ -19,15 +19,16 @@ package chart
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 
 	helmchart "helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
 
 	"github.com/fluxcd/source-controller/internal/fs"
+	"github.com/fluxcd/source-controller/internal/util"
 )
 
 // Reference holds information to locate a chart.
@@ -105,7 +106,17 @@ type BuildOptions struct {
 	VersionMetadata string
 	// ValuesFiles can be set to a list of relative paths, used to compose
 	// and overwrite an alternative default "values.yaml" for the chart.
+	// The files are deep-merged in order, with values from later files
+	// taking precedence. A null value deletes the key from the result.
 	ValuesFiles []string
+	// ValuesFrom can be set to a list of ValuesSource, which are merged in
+	// order on top of the values composed from ValuesFiles, or the chart's
+	// default values if no ValuesFiles are set.
+	ValuesFrom []ValuesSource
+	// ValidateValuesSchema can be set to validate the values composed from
+	// ValuesFiles and ValuesFrom against the values.schema.json of the chart
+	// and its dependencies, if any.
+	ValidateValuesSchema bool
 	// CachedChart can be set to the absolute path of a chart stored on
 	// the local filesystem, and is used for simple validation by metadata
 	// comparisons.
@@ -115,6 +126,16 @@ type BuildOptions struct {
 	Force bool
 }
 
+// ValuesSource holds YAML encoded chart values originating from outside of
+// the chart, for example from a ConfigMap or Secret.
+type ValuesSource struct {
+	// Origin describes where the values originate from, and is used to
+	// identify the source in error messages.
+	Origin string
+	// Data holds the YAML encoded values.
+	Data []byte
+}
+
 // GetValuesFiles returns BuildOptions.ValuesFiles, except if it equals
 // "values.yaml", which returns nil.
 func (o BuildOptions) GetValuesFiles() []string {
@@ -197,18 +218,68 @@ func (b *Build) String() string {
 
 // packageToPath attempts to package the given chart to the out filepath.
 func packageToPath(chart *helmchart.Chart, out string) error {
-	o, err := os.MkdirTemp("", "chart-build-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary directory for chart: %w", err)
+	return util.WithTempDir("chart-build-*", func(o string) error {
+		p, err := chartutil.Save(chart, o)
+		if err != nil {
+			return fmt.Errorf("failed to package chart: %w", err)
+		}
+		if err = fs.RenameWithFallback(p, out); err != nil {
+			return fmt.Errorf("failed to write chart to file: %w", err)
+		}
+		return nil
+	})
+}
+
+// mergeValues deep-merges values map b into a copy of a, and returns the
+// result. Nested maps are merged, while any other value in b (including
+// lists) replaces the value in a. A nil value in b deletes the key from the
+// result.
+func mergeValues(a, b map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		out[k] = v
 	}
-	defer os.RemoveAll(o)
+	for k, v := range b {
+		if v == nil {
+			delete(out, k)
+			continue
+		}
+		if v, ok := v.(map[string]interface{}); ok {
+			if bv, ok := out[k]; ok {
+				if bv, ok := bv.(map[string]interface{}); ok {
+					out[k] = mergeValues(bv, v)
+					continue
+				}
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
 
-	p, err := chartutil.Save(chart, o)
+// validateValuesSchema validates the default values of the given chart,
+// coalesced with the default values of its dependencies, against the values
+// schema of the chart and its dependencies. The returned error lists the
+// paths of the values violating the schema.
+func validateValuesSchema(chart *helmchart.Chart) error {
+	values, err := chartutil.CoalesceValues(chart, chart.Values)
 	if err != nil {
-		return fmt.Errorf("failed to package chart: %w", err)
+		return fmt.Errorf("failed to coalesce chart values: %w", err)
 	}
-	if err = fs.RenameWithFallback(p, out); err != nil {
-		return fmt.Errorf("failed to write chart to file: %w", err)
+	return chartutil.ValidateAgainstSchema(chart, values)
+}
+
+// mergeValuesSources merges the values of the given sources in order into
+// base. It returns the merge result, or an error including the origin of the
+// source which could not be unmarshaled.
+func mergeValuesSources(base map[string]interface{}, sources []ValuesSource) (map[string]interface{}, error) {
+	mergedValues := mergeValues(nil, base)
+	for _, s := range sources {
+		values := make(map[string]interface{})
+		if err := yaml.Unmarshal(s.Data, &values); err != nil {
+			return nil, fmt.Errorf("unmarshaling values from %s failed: %w", s.Origin, err)
+		}
+		mergedValues = mergeValues(mergedValues, values)
 	}
-	return nil
+	return mergedValues, nil
 }
@@ -26,8 +26,6 @@ import (
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"sigs.k8s.io/yaml"
 
-	"github.com/fluxcd/pkg/runtime/transform"
-
 	"github.com/fluxcd/source-controller/internal/helm/chart/secureloader"
 )
 
@@ -53,8 +51,9 @@ func NewLocalBuilder(dm *DependencyManager) Builder {
 // version (including BuildOptions.VersionMetadata modifications) differs from
 // the current BuildOptions.CachedChart.
 //
-// BuildOptions.ValuesFiles changes are in this case not taken into account,
-// and BuildOptions.Force should be used to enforce a rebuild.
+// BuildOptions.ValuesFiles and BuildOptions.ValuesFrom changes are in this
+// case not taken into account, and BuildOptions.Force should be used to
+// enforce a rebuild.
 //
 // If the LocalReference.Path refers to an already packaged chart, and no
 // packaging is required due to BuildOptions modifying the chart,
@@ -107,7 +106,8 @@ func (b *localChartBuilder) Build(ctx context.Context, ref Reference, p string,
 	}
 
 	isChartDir := pathIsDir(securePath)
-	requiresPackaging := isChartDir || opts.VersionMetadata != "" || len(opts.GetValuesFiles()) != 0
+	requiresPackaging := isChartDir || opts.VersionMetadata != "" || len(opts.GetValuesFiles()) != 0 ||
+		len(opts.ValuesFrom) != 0
 
 	// If all the following is true, we do not need to package the chart:
 	// - Chart name from cached chart matches resolved name
@@ -158,6 +158,17 @@ func (b *localChartBuilder) Build(ctx context.Context, ref Reference, p string,
 	// Set earlier resolved version (with metadata)
 	loadedChart.Metadata.Version = result.Version
 
+	// Merge values from other sources on top of the chart values, if any
+	if len(opts.ValuesFrom) > 0 {
+		base := mergedValues
+		if base == nil {
+			base = loadedChart.Values
+		}
+		if mergedValues, err = mergeValuesSources(base, opts.ValuesFrom); err != nil {
+			return result, &BuildError{Reason: ErrValuesFilesMerge, Err: err}
+		}
+	}
+
 	// Overwrite default values with merged values, if any
 	if ok, err = OverwriteChartDefaultValues(loadedChart, mergedValues); ok || err != nil {
 		if err != nil {
@@ -177,6 +188,13 @@ func (b *localChartBuilder) Build(ctx context.Context, ref Reference, p string,
 		}
 	}
 
+	// Validate the merged values against the values schema, if instructed
+	if opts.ValidateValuesSchema && (len(opts.GetValuesFiles()) > 0 || len(opts.ValuesFrom) > 0) {
+		if err = validateValuesSchema(loadedChart); err != nil {
+			return result, &BuildError{Reason: ErrValuesSchema, Err: err}
+		}
+	}
+
 	// Package the chart
 	if err = packageToPath(loadedChart, p); err != nil {
 		return result, &BuildError{Reason: ErrChartPackage, Err: err}
@@ -209,7 +227,7 @@ func mergeFileValues(baseDir string, paths []string) (map[string]interface{}, er
 		if err != nil {
 			return nil, fmt.Errorf("unmarshaling values from '%s' failed: %w", p, err)
 		}
-		mergedValues = transform.MergeMaps(mergedValues, values)
+		mergedValues = mergeValues(mergedValues, values)
 	}
 	return mergedValues, nil
 }
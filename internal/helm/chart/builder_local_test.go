@@ -143,6 +143,31 @@ fullnameOverride: "full-foo-name-override"`),
 			wantVersion:  "0.1.0",
 			wantPackaged: true,
 		},
+		{
+			name:      "with values files and values from",
+			reference: LocalReference{Path: "../testdata/charts/helmchart"},
+			buildOpts: BuildOptions{
+				ValuesFiles: []string{"custom-values1.yaml"},
+				ValuesFrom: []ValuesSource{
+					{Origin: "ConfigMap/default/values", Data: []byte(`replicaCount: 20`)},
+					{Origin: "Secret/default/values", Data: []byte(`fullnameOverride: "full-foo-name-override"`)},
+				},
+			},
+			valuesFiles: []helmchart.File{
+				{
+					Name: "custom-values1.yaml",
+					Data: []byte(`replicaCount: 11
+nameOverride: "foo-name-override"`),
+				},
+			},
+			wantValues: chartutil.Values{
+				"replicaCount":     float64(20),
+				"nameOverride":     "foo-name-override",
+				"fullnameOverride": "full-foo-name-override",
+			},
+			wantVersion:  "0.1.0",
+			wantPackaged: true,
+		},
 		{
 			name:      "chart with dependencies",
 			reference: LocalReference{Path: "../testdata/charts/helmchartwithdeps"},
@@ -30,11 +30,10 @@ import (
 	"helm.sh/helm/v3/pkg/repo"
 	"sigs.k8s.io/yaml"
 
-	"github.com/fluxcd/pkg/runtime/transform"
-
 	"github.com/fluxcd/source-controller/internal/fs"
 	"github.com/fluxcd/source-controller/internal/helm/chart/secureloader"
 	"github.com/fluxcd/source-controller/internal/helm/repository"
+	"github.com/fluxcd/source-controller/internal/util"
 )
 
 type remoteChartBuilder struct {
@@ -57,13 +56,14 @@ func NewRemoteBuilder(repository repository.Downloader) Builder {
 // The latest version for the RemoteReference.Version is determined in the
 // repository.ChartRepository, only downloading it if the version (including
 // BuildOptions.VersionMetadata) differs from the current BuildOptions.CachedChart.
-// BuildOptions.ValuesFiles changes are in this case not taken into account,
-// and BuildOptions.Force should be used to enforce a rebuild.
+// BuildOptions.ValuesFiles and BuildOptions.ValuesFrom changes are in this
+// case not taken into account, and BuildOptions.Force should be used to
+// enforce a rebuild.
 //
 // After downloading the chart, it is only packaged if required due to BuildOptions
 // modifying the chart, otherwise the exact data as retrieved from the repository
 // is written to p, after validating it to be a chart.
-func (b *remoteChartBuilder) Build(_ context.Context, ref Reference, p string, opts BuildOptions) (*Build, error) {
+func (b *remoteChartBuilder) Build(ctx context.Context, ref Reference, p string, opts BuildOptions) (*Build, error) {
 	remoteRef, ok := ref.(RemoteReference)
 	if !ok {
 		err := fmt.Errorf("expected remote chart reference")
@@ -74,7 +74,7 @@ func (b *remoteChartBuilder) Build(_ context.Context, ref Reference, p string, o
 		return nil, &BuildError{Reason: ErrChartReference, Err: err}
 	}
 
-	res, result, err := b.downloadFromRepository(b.remote, remoteRef, opts)
+	res, result, err := b.downloadFromRepository(ctx, b.remote, remoteRef, opts)
 	if err != nil {
 		return nil, &BuildError{Reason: ErrChartPull, Err: err}
 	}
@@ -82,7 +82,7 @@ func (b *remoteChartBuilder) Build(_ context.Context, ref Reference, p string, o
 		return result, nil
 	}
 
-	requiresPackaging := len(opts.GetValuesFiles()) != 0 || opts.VersionMetadata != ""
+	requiresPackaging := len(opts.GetValuesFiles()) != 0 || len(opts.ValuesFrom) != 0 || opts.VersionMetadata != ""
 
 	// Use literal chart copy from remote if no custom values files options are
 	// set or version metadata isn't set.
@@ -107,6 +107,16 @@ func (b *remoteChartBuilder) Build(_ context.Context, ref Reference, p string, o
 		err = fmt.Errorf("failed to merge chart values: %w", err)
 		return result, &BuildError{Reason: ErrValuesFilesMerge, Err: err}
 	}
+	// Merge values from other sources on top of the chart values, if any
+	if len(opts.ValuesFrom) > 0 {
+		base := mergedValues
+		if len(opts.ValuesFiles) == 0 {
+			base = chart.Values
+		}
+		if mergedValues, err = mergeValuesSources(base, opts.ValuesFrom); err != nil {
+			return result, &BuildError{Reason: ErrValuesFilesMerge, Err: err}
+		}
+	}
 	// Overwrite default values with merged values, if any
 	if ok, err = OverwriteChartDefaultValues(chart, mergedValues); ok || err != nil {
 		if err != nil {
@@ -115,6 +125,13 @@ func (b *remoteChartBuilder) Build(_ context.Context, ref Reference, p string, o
 		result.ValuesFiles = opts.GetValuesFiles()
 	}
 
+	// Validate the merged values against the values schema, if instructed
+	if opts.ValidateValuesSchema && (len(opts.GetValuesFiles()) > 0 || len(opts.ValuesFrom) > 0) {
+		if err = validateValuesSchema(chart); err != nil {
+			return nil, &BuildError{Reason: ErrValuesSchema, Err: err}
+		}
+	}
+
 	// Package the chart with the custom values
 	if err = packageToPath(chart, p); err != nil {
 		return nil, &BuildError{Reason: ErrChartPackage, Err: err}
@@ -124,7 +141,7 @@ func (b *remoteChartBuilder) Build(_ context.Context, ref Reference, p string, o
 	return result, nil
 }
 
-func (b *remoteChartBuilder) downloadFromRepository(remote repository.Downloader, remoteRef RemoteReference, opts BuildOptions) (*bytes.Buffer, *Build, error) {
+func (b *remoteChartBuilder) downloadFromRepository(ctx context.Context, remote repository.Downloader, remoteRef RemoteReference, opts BuildOptions) (*bytes.Buffer, *Build, error) {
 	// Get the current version for the RemoteReference
 	cv, err := remote.GetChartVersion(remoteRef.Name, remoteRef.Version)
 	if err != nil {
@@ -142,7 +159,7 @@ func (b *remoteChartBuilder) downloadFromRepository(remote repository.Downloader
 	}
 
 	// Download the package for the resolved version
-	res, err := remote.DownloadChart(cv)
+	res, err := repository.DownloadChart(ctx, remote, cv)
 	if err != nil {
 		err = fmt.Errorf("failed to download chart for remote reference: %w", err)
 		return nil, nil, &BuildError{Reason: ErrChartPull, Err: err}
@@ -167,7 +184,7 @@ func generateBuildResult(cv *repo.ChartVersion, opts BuildOptions) (*Build, bool
 		result.Version = ver.String()
 	}
 
-	requiresPackaging := len(opts.GetValuesFiles()) != 0 || opts.VersionMetadata != ""
+	requiresPackaging := len(opts.GetValuesFiles()) != 0 || len(opts.ValuesFrom) != 0 || opts.VersionMetadata != ""
 
 	// If all the following is true, we do not need to download and/or build the chart:
 	// - Chart name from cached chart matches resolved name
@@ -210,7 +227,7 @@ func mergeChartValues(chart *helmchart.Chart, paths []string) (map[string]interf
 	for _, p := range paths {
 		cfn := filepath.Clean(p)
 		if cfn == chartutil.ValuesfileName {
-			mergedValues = transform.MergeMaps(mergedValues, chart.Values)
+			mergedValues = mergeValues(mergedValues, chart.Values)
 			continue
 		}
 		var b []byte
@@ -227,7 +244,7 @@ func mergeChartValues(chart *helmchart.Chart, paths []string) (map[string]interf
 		if err := yaml.Unmarshal(b, &values); err != nil {
 			return nil, fmt.Errorf("unmarshaling values from '%s' failed: %w", p, err)
 		}
-		mergedValues = transform.MergeMaps(mergedValues, values)
+		mergedValues = mergeValues(mergedValues, values)
 	}
 	return mergedValues, nil
 }
@@ -235,7 +252,7 @@ func mergeChartValues(chart *helmchart.Chart, paths []string) (map[string]interf
 // validatePackageAndWriteToPath atomically writes the packaged chart from reader
 // to out while validating it by loading the chart metadata from the archive.
 func validatePackageAndWriteToPath(reader io.Reader, out string) error {
-	tmpFile, err := os.CreateTemp("", filepath.Base(out))
+	tmpFile, err := util.CreateTemp(filepath.Base(out))
 	if err != nil {
 		return fmt.Errorf("failed to create temporary file for chart: %w", err)
 	}
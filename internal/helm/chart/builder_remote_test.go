@@ -50,6 +50,11 @@ func (m *mockRegistryClient) Tags(url string) ([]string, error) {
 	return nil, fmt.Errorf("no tags found for %s", url)
 }
 
+func (m *mockRegistryClient) ConfigMediaType(ref string) (string, error) {
+	m.requestedURL = ref
+	return registry.ConfigMediaType, nil
+}
+
 func (m *mockRegistryClient) Login(url string, opts ...registry.LoginOption) error {
 	m.requestedURL = url
 	return nil
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+	helmchart "helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chartutil"
 
 	"github.com/fluxcd/source-controller/internal/helm/chart/secureloader"
@@ -235,6 +236,207 @@ func Test_packageToPath(t *testing.T) {
 	g.Expect(err).ToNot(HaveOccurred())
 }
 
+func Test_mergeValues(t *testing.T) {
+	tests := []struct {
+		name string
+		a    map[string]interface{}
+		b    map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "nested merge",
+			a: map[string]interface{}{
+				"image": map[string]interface{}{
+					"repository": "nginx",
+					"tag":        "1.21",
+				},
+				"replicas": 1,
+			},
+			b: map[string]interface{}{
+				"image": map[string]interface{}{
+					"tag": "1.22",
+				},
+			},
+			want: map[string]interface{}{
+				"image": map[string]interface{}{
+					"repository": "nginx",
+					"tag":        "1.22",
+				},
+				"replicas": 1,
+			},
+		},
+		{
+			name: "list replacement",
+			a: map[string]interface{}{
+				"args": []interface{}{"--foo", "--bar"},
+			},
+			b: map[string]interface{}{
+				"args": []interface{}{"--baz"},
+			},
+			want: map[string]interface{}{
+				"args": []interface{}{"--baz"},
+			},
+		},
+		{
+			name: "null deletes key",
+			a: map[string]interface{}{
+				"image": map[string]interface{}{
+					"repository": "nginx",
+					"tag":        "1.21",
+				},
+				"replicas": 1,
+			},
+			b: map[string]interface{}{
+				"image": map[string]interface{}{
+					"tag": nil,
+				},
+				"replicas": nil,
+			},
+			want: map[string]interface{}{
+				"image": map[string]interface{}{
+					"repository": "nginx",
+				},
+			},
+		},
+		{
+			name: "map overwrites flat value",
+			a: map[string]interface{}{
+				"service": "ClusterIP",
+			},
+			b: map[string]interface{}{
+				"service": map[string]interface{}{
+					"type": "NodePort",
+				},
+			},
+			want: map[string]interface{}{
+				"service": map[string]interface{}{
+					"type": "NodePort",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(mergeValues(tt.a, tt.b)).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_mergeValuesSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    map[string]interface{}
+		sources []ValuesSource
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "merges in order",
+			base: map[string]interface{}{
+				"replicaCount": 1,
+				"image":        map[string]interface{}{"tag": "1.0.0"},
+			},
+			sources: []ValuesSource{
+				{Origin: "ConfigMap/default/first", Data: []byte("replicaCount: 2\nimage:\n  tag: 2.0.0\n")},
+				{Origin: "Secret/default/second", Data: []byte("replicaCount: 3\n")},
+			},
+			want: map[string]interface{}{
+				"replicaCount": float64(3),
+				"image":        map[string]interface{}{"tag": "2.0.0"},
+			},
+		},
+		{
+			name: "no sources returns base",
+			base: map[string]interface{}{"replicaCount": 1},
+			want: map[string]interface{}{"replicaCount": 1},
+		},
+		{
+			name: "malformed source",
+			base: map[string]interface{}{"replicaCount": 1},
+			sources: []ValuesSource{
+				{Origin: "ConfigMap/default/valid", Data: []byte("replicaCount: 2")},
+				{Origin: "Secret/default/invalid", Data: []byte("replicaCount: [")},
+			},
+			wantErr: "unmarshaling values from Secret/default/invalid failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := mergeValuesSources(tt.base, tt.sources)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(got).To(BeNil())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_validateValuesSchema(t *testing.T) {
+	schema := []byte(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["replicaCount"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1}
+  }
+}`)
+
+	tests := []struct {
+		name    string
+		schema  []byte
+		values  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:   "valid merged values",
+			schema: schema,
+			values: map[string]interface{}{"replicaCount": float64(3)},
+		},
+		{
+			name:    "invalid merged values",
+			schema:  schema,
+			values:  map[string]interface{}{"replicaCount": "three"},
+			wantErr: "replicaCount: Invalid type",
+		},
+		{
+			name:    "missing required value",
+			schema:  schema,
+			values:  map[string]interface{}{},
+			wantErr: "replicaCount is required",
+		},
+		{
+			name:   "chart without schema",
+			values: map[string]interface{}{"replicaCount": "three"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			chart := &helmchart.Chart{
+				Metadata: &helmchart.Metadata{Name: "schema", Version: "0.1.0", APIVersion: helmchart.APIVersionV2},
+				Schema:   tt.schema,
+				Values:   tt.values,
+			}
+			err := validateValuesSchema(chart)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
+
 func tmpFile(prefix, suffix string) string {
 	randBytes := make([]byte, 16)
 	rand.Read(randBytes)
@@ -30,6 +30,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"k8s.io/apimachinery/pkg/util/errors"
 
 	"github.com/fluxcd/source-controller/internal/helm/chart/secureloader"
@@ -137,9 +138,11 @@ type chartWithLock struct {
 }
 
 // build adds the given list of deps to the chart with the configured number of
-// concurrent workers. If the chart.Chart references a local dependency but no
-// LocalReference is given, or any dependency could not be added, an error
-// is returned. The first error it encounters cancels all other workers.
+// concurrent workers. Dependencies referring to the same chart, version and
+// repository (e.g. under different aliases) are only added once.
+// If the chart.Chart references a local dependency but no LocalReference is
+// given, or any dependency could not be added, an error is returned. The
+// first error it encounters cancels all other workers.
 func (dm *DependencyManager) build(ctx context.Context, ref Reference, c *helmchart.Chart, deps map[string]*helmchart.Dependency) error {
 	current := dm.concurrent
 	if current <= 0 {
@@ -150,8 +153,14 @@ func (dm *DependencyManager) build(ctx context.Context, ref Reference, c *helmch
 	group.Go(func() error {
 		sem := semaphore.NewWeighted(current)
 		c := &chartWithLock{Chart: c}
+		seen := make(map[string]struct{}, len(deps))
 		for name, dep := range deps {
 			name, dep := name, dep
+			key := dep.Repository + "/" + dep.Name + "@" + dep.Version
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
 			if err := sem.Acquire(groupCtx, 1); err != nil {
 				return err
 			}
@@ -168,7 +177,7 @@ func (dm *DependencyManager) build(ctx context.Context, ref Reference, c *helmch
 					}
 					return
 				}
-				if err = dm.addRemoteDependency(c, dep); err != nil {
+				if err = dm.addRemoteDependency(groupCtx, c, dep); err != nil {
 					err = fmt.Errorf("failed to add remote dependency '%s': %w", name, err)
 				}
 				return
@@ -225,7 +234,7 @@ func (dm *DependencyManager) addLocalDependency(ref LocalReference, c *chartWith
 // addRemoteDependency attempts to resolve and add the given remote chart.Dependency
 // to the chart. It locks the chartWithLock before the downloaded dependency is
 // added to the chart.
-func (dm *DependencyManager) addRemoteDependency(chart *chartWithLock, dep *helmchart.Dependency) error {
+func (dm *DependencyManager) addRemoteDependency(ctx context.Context, chart *chartWithLock, dep *helmchart.Dependency) error {
 	repo, err := dm.resolveRepository(dep.Repository)
 	if err != nil {
 		return err
@@ -235,7 +244,7 @@ func (dm *DependencyManager) addRemoteDependency(chart *chartWithLock, dep *helm
 	if err != nil {
 		return fmt.Errorf("failed to get chart '%s' version '%s' from '%s': %w", dep.Name, dep.Version, dep.Repository, err)
 	}
-	res, err := repo.DownloadChart(ver)
+	res, err := repository.DownloadChart(ctx, repo, ver)
 	if err != nil {
 		return fmt.Errorf("chart download of version '%s' failed: %w", ver.Version, err)
 	}
@@ -292,6 +301,11 @@ func (dm *DependencyManager) secureLocalChartPath(ref LocalReference, dep *helmc
 // collectMissing returns a map with dependencies from reqs that are missing
 // from current, indexed by their alias or name. All dependencies of a chart
 // are present if len of returned map == 0.
+//
+// Helm renders an aliased dependency from a copy of the chart stored under
+// its original name, an aliased dependency is therefore not missing when a
+// chart with the dependency name and a version matching its constraint is
+// present.
 func collectMissing(current []*helmchart.Chart, reqs []*helmchart.Dependency) map[string]*helmchart.Dependency {
 	// If the number of dependencies equals the number of requested
 	// dependencies, there are no missing dependencies
@@ -312,6 +326,12 @@ func collectMissing(current []*helmchart.Chart, reqs []*helmchart.Dependency) ma
 		for _, existing := range current {
 			if existing.Name() == name {
 				found = true
+				break
+			}
+			if dep.Alias != "" && existing.Name() == dep.Name && existing.Metadata != nil &&
+				chartutil.IsCompatibleRange(dep.Version, existing.Metadata.Version) {
+				found = true
+				break
 			}
 		}
 		if found {
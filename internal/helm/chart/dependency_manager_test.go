@@ -21,6 +21,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -32,6 +34,7 @@ import (
 	helmgetter "helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/registry"
 	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
 
 	"github.com/fluxcd/source-controller/internal/helm/chart/secureloader"
 	"github.com/fluxcd/source-controller/internal/helm/repository"
@@ -54,6 +57,10 @@ func (m *mockTagsGetter) Tags(requestURL string) ([]string, error) {
 	return nil, fmt.Errorf("no tags found for %s with requestURL %s", name, requestURL)
 }
 
+func (m *mockTagsGetter) ConfigMediaType(_ string) (string, error) {
+	return registry.ConfigMediaType, nil
+}
+
 func (m *mockTagsGetter) Login(_ string, _ ...registry.LoginOption) error {
 	return nil
 }
@@ -165,6 +172,112 @@ func TestDependencyManager_Build(t *testing.T) {
 	}
 }
 
+func TestDependencyManager_Build_repositoryStub(t *testing.T) {
+	g := NewWithT(t)
+
+	subchart, err := os.ReadFile("./../testdata/charts/helmchart-0.1.0.tgz")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var index []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			_, _ = w.Write(index)
+		case "/helmchart-0.1.0.tgz":
+			_, _ = w.Write(subchart)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	idx := repo.NewIndexFile()
+	g.Expect(idx.MustAdd(&helmchart.Metadata{
+		APIVersion: helmchart.APIVersionV2,
+		Name:       chartName,
+		Version:    chartVersion,
+	}, "helmchart-0.1.0.tgz", server.URL, "")).To(Succeed())
+	index, err = yaml.Marshal(idx)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	providers := helmgetter.Providers{
+		helmgetter.Provider{
+			Schemes: []string{"http"},
+			New:     helmgetter.NewHTTPGetter,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		deps     []*helmchart.Dependency
+		want     int
+		wantDeps []string
+		wantErr  string
+	}{
+		{
+			name: "resolves dependency matching constraint",
+			deps: []*helmchart.Dependency{
+				{Name: chartName, Version: "~0.1.0", Repository: server.URL},
+			},
+			want:     1,
+			wantDeps: []string{chartName},
+		},
+		{
+			name: "resolves aliased dependencies once",
+			deps: []*helmchart.Dependency{
+				{Name: chartName, Version: ">=0.1.0", Repository: server.URL},
+				{Name: chartName, Version: ">=0.1.0", Repository: server.URL, Alias: "aliased"},
+			},
+			want:     2,
+			wantDeps: []string{chartName},
+		},
+		{
+			name: "no version matching constraint",
+			deps: []*helmchart.Dependency{
+				{Name: chartName, Version: "^1.0.0", Repository: server.URL},
+			},
+			wantErr: "no 'helmchart' chart with version matching '^1.0.0' found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := &helmchart.Chart{
+				Metadata: &helmchart.Metadata{
+					APIVersion:   helmchart.APIVersionV2,
+					Name:         "parent",
+					Version:      "1.0.0",
+					Dependencies: tt.deps,
+				},
+			}
+
+			dm := NewDependencyManager(
+				WithDownloaderCallback(func(url string) (repository.Downloader, error) {
+					return repository.NewChartRepository(url, "", providers, nil, nil)
+				}),
+			)
+			defer dm.Clear()
+
+			got, err := dm.Build(context.TODO(), LocalReference{}, c)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+
+			var names []string
+			for _, d := range c.Dependencies() {
+				names = append(names, d.Name())
+				g.Expect(d.Metadata.Version).To(Equal(chartVersion))
+			}
+			g.Expect(names).To(ConsistOf(tt.wantDeps))
+		})
+	}
+}
+
 func build(t *testing.T, mockRepo repository.Downloader) {
 	tests := []struct {
 		name                       string
@@ -543,7 +656,7 @@ func TestDependencyManager_addRemoteDependency(t *testing.T) {
 				downloaders: tt.downloaders,
 			}
 			chart := &helmchart.Chart{}
-			err := dm.addRemoteDependency(&chartWithLock{Chart: chart}, tt.dep)
+			err := dm.addRemoteDependency(context.TODO(), &chartWithLock{Chart: chart}, tt.dep)
 			if tt.wantErr != "" {
 				g.Expect(err).To(HaveOccurred())
 				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
@@ -673,7 +786,7 @@ func TestDependencyManager_addRemoteOCIDependency(t *testing.T) {
 				downloaders: tt.downloaders,
 			}
 			chart := &helmchart.Chart{}
-			err := dm.addRemoteDependency(&chartWithLock{Chart: chart}, tt.dep)
+			err := dm.addRemoteDependency(context.TODO(), &chartWithLock{Chart: chart}, tt.dep)
 			if tt.wantErr != "" {
 				g.Expect(err).To(HaveOccurred())
 				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
@@ -895,6 +1008,40 @@ func Test_collectMissing(t *testing.T) {
 				chartName + "-alias": {Name: chartName, Alias: chartName + "-alias"},
 			},
 		},
+		{
+			name: "alias satisfied by chart with matching version",
+			current: []*helmchart.Chart{
+				{
+					Metadata: &helmchart.Metadata{
+						Name:    chartName,
+						Version: chartVersion,
+					},
+				},
+			},
+			reqs: []*helmchart.Dependency{
+				{Name: chartName, Version: chartVersion},
+				{Name: chartName, Version: "~" + chartVersion, Alias: chartName + "-alias"},
+			},
+			want: nil,
+		},
+		{
+			name: "alias not satisfied by chart with other version",
+			current: []*helmchart.Chart{
+				{
+					Metadata: &helmchart.Metadata{
+						Name:    chartName,
+						Version: chartVersion,
+					},
+				},
+			},
+			reqs: []*helmchart.Dependency{
+				{Name: chartName, Version: chartVersion},
+				{Name: chartName, Version: "0.2.0", Alias: chartName + "-alias"},
+			},
+			want: map[string]*helmchart.Dependency{
+				chartName + "-alias": {Name: chartName, Version: "0.2.0", Alias: chartName + "-alias"},
+			},
+		},
 		{
 			name: "all current",
 			current: []*helmchart.Chart{
@@ -81,6 +81,8 @@ var (
 	ErrChartPull          = BuildErrorReason{Reason: "ChartPullError", Summary: "chart pull error"}
 	ErrChartMetadataPatch = BuildErrorReason{Reason: "MetadataPatchError", Summary: "chart metadata patch error"}
 	ErrValuesFilesMerge   = BuildErrorReason{Reason: "ValuesFilesError", Summary: "values files merge error"}
+	ErrValuesSchema       = BuildErrorReason{Reason: "ValuesSchemaError", Summary: "values schema validation error"}
+	ErrValuesReference    = BuildErrorReason{Reason: "ValuesReferenceError", Summary: "values reference error"}
 	ErrDependencyBuild    = BuildErrorReason{Reason: "DependencyBuildError", Summary: "dependency build error"}
 	ErrChartPackage       = BuildErrorReason{Reason: "ChartPackageError", Summary: "chart package error"}
 	ErrUnknown            = BuildErrorReason{Reason: "Unknown", Summary: "unknown build error"}
@@ -94,6 +94,20 @@ func LoadChartMetadata(chartPath string) (meta *helmchart.Metadata, err error) {
 	return
 }
 
+// ReadChartMetadata reads the chart.Metadata from the "Chart.yaml" file in the directory or archive at the given
+// chartPath, without loading the other files of the chart. It takes "requirements.yaml" files into account, and
+// validates the result, which makes it suitable for listing and validating charts without building them.
+func ReadChartMetadata(chartPath string) (*helmchart.Metadata, error) {
+	meta, err := LoadChartMetadata(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if err = meta.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid '%s' in chart '%s': %w", chartutil.ChartfileName, chartPath, err)
+	}
+	return meta, nil
+}
+
 // LoadChartMetadataFromDir loads the chart.Metadata from the "Chart.yaml" file in the directory at the given path.
 // It takes "requirements.yaml" files into account, and is therefore compatible with the chart.APIVersionV1 format.
 func LoadChartMetadataFromDir(dir string) (*helmchart.Metadata, error) {
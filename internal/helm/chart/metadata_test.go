@@ -130,6 +130,82 @@ func TestOverwriteChartDefaultValues(t *testing.T) {
 	}
 }
 
+func TestReadChartMetadata(t *testing.T) {
+	g := NewWithT(t)
+
+	malformedDir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(malformedDir, chartutil.ChartfileName), []byte("name: [malformed"), 0o640)).To(Succeed())
+	invalidDir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(invalidDir, chartutil.ChartfileName), []byte("apiVersion: v2\nname: invalid\n"), 0o640)).To(Succeed())
+
+	tests := []struct {
+		name                string
+		path                string
+		wantName            string
+		wantVersion         string
+		wantAppVersion      string
+		wantDependencyCount int
+		wantErr             string
+	}{
+		{
+			name:           "Reads from dir",
+			path:           "../testdata/charts/helmchart",
+			wantName:       chartName,
+			wantVersion:    chartVersion,
+			wantAppVersion: "1.16.0",
+		},
+		{
+			name:                "Reads from v1 dir including requirements.yaml",
+			path:                "../testdata/charts/helmchartwithdeps-v1",
+			wantName:            chartNameV1,
+			wantVersion:         chartVersionV1,
+			wantAppVersion:      "1.0",
+			wantDependencyCount: 1,
+		},
+		{
+			name:           "Reads from archive",
+			path:           helmPackageFile,
+			wantName:       chartName,
+			wantVersion:    chartVersion,
+			wantAppVersion: "1.16.0",
+		},
+		{
+			name:    "Error on malformed Chart.yaml",
+			path:    malformedDir,
+			wantErr: "cannot load 'Chart.yaml'",
+		},
+		{
+			name:    "Error on invalid Chart.yaml",
+			path:    invalidDir,
+			wantErr: "invalid 'Chart.yaml' in chart",
+		},
+		{
+			name:    "Error on not found",
+			path:    "../testdata/invalid.tgz",
+			wantErr: "no such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := ReadChartMetadata(tt.path)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(got).To(BeNil())
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got.Name).To(Equal(tt.wantName))
+			g.Expect(got.Version).To(Equal(tt.wantVersion))
+			g.Expect(got.AppVersion).To(Equal(tt.wantAppVersion))
+			g.Expect(got.Dependencies).To(HaveLen(tt.wantDependencyCount))
+		})
+	}
+}
+
 func TestLoadChartMetadataFromDir(t *testing.T) {
 	g := NewWithT(t)
 
@@ -26,4 +26,7 @@ var (
 	// MaxChartFileSize is the max allowed file size in bytes of any arbitrary
 	// file originating from a chart.
 	MaxChartFileSize int64 = 5 << 20
+	// MaxManifestSize is the max allowed size in bytes of an OCI manifest,
+	// or any other metadata response of an OCI registry.
+	MaxManifestSize int64 = 4 << 20
 )
@@ -17,17 +17,236 @@ limitations under the License.
 package registry
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"sort"
+	"strings"
 
+	"github.com/Masterminds/semver/v3"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"helm.sh/helm/v3/pkg/helmpath"
 	"helm.sh/helm/v3/pkg/registry"
 	"k8s.io/apimachinery/pkg/util/errors"
+	dockerauth "oras.land/oras-go/pkg/auth/docker"
+	orasregistry "oras.land/oras-go/pkg/registry"
+	registryremote "oras.land/oras-go/pkg/registry/remote"
+	registryauth "oras.land/oras-go/pkg/registry/remote/auth"
+
+	"github.com/fluxcd/source-controller/internal/helm"
+	"github.com/fluxcd/source-controller/internal/transport"
+	"github.com/fluxcd/source-controller/internal/useragent"
 )
 
+// Client is a Helm registry client which lists the tags of a repository
+// using an HTTP client that limits the size of the responses to
+// helm.MaxManifestSize.
+// The Helm registry client does not allow configuring the HTTP client it
+// uses, and performs any other request using http.DefaultClient.
+type Client struct {
+	*registry.Client
+
+	credentialsFile string
+	httpClient      *http.Client
+}
+
+// StatusError is returned by Client.Tags when the registry responds with an
+// HTTP error status.
+type StatusError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Err is the underlying error returned by the ORAS client.
+	Err error
+}
+
+// Error returns the error string of the underlying error.
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// NewClient returns a new Client which reads its credentials from the given
+// file, or from the default Helm registry configuration if empty.
+func NewClient(credentialsFile string, opts ...registry.ClientOption) (*Client, error) {
+	opts = append(opts, registry.ClientOptWriter(io.Discard))
+	if credentialsFile != "" {
+		opts = append(opts, registry.ClientOptCredentialsFile(credentialsFile))
+	}
+	rClient, err := registry.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Client:          rClient,
+		credentialsFile: credentialsFile,
+		httpClient:      NewHTTPClient(helm.MaxManifestSize),
+	}, nil
+}
+
+// NewHTTPClient returns an HTTP client which limits the body of the
+// responses to limit bytes, except for blob requests.
+func NewHTTPClient(limit int64) *http.Client {
+	return &http.Client{
+		Transport: transport.LimitResponses(limit, IsBlobRequest)(http.DefaultTransport.(*http.Transport).Clone()),
+	}
+}
+
+// Tags returns the SemVer compliant tags of the given repository, sorted
+// from the highest to the lowest version.
+func (c *Client) Tags(ref string) ([]string, error) {
+	parsedRef, err := orasregistry.ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := &statusRecorder{next: c.httpClient.Transport}
+	repository := registryremote.Repository{
+		Reference: parsedRef,
+		Client: &registryauth.Client{
+			Client:     &http.Client{Transport: recorder},
+			Header:     http.Header{"User-Agent": {useragent.Get()}},
+			Credential: c.credential,
+		},
+	}
+
+	var registryTags []string
+	for {
+		registryTags, err = orasregistry.Tags(context.Background(), &repository)
+		if err != nil {
+			// Fallback to a plain HTTP request
+			if !repository.PlainHTTP && strings.Contains(err.Error(), "server gave HTTP response") {
+				repository.PlainHTTP = true
+				continue
+			}
+			if recorder.statusCode >= http.StatusBadRequest {
+				return nil, &StatusError{StatusCode: recorder.statusCode, Err: err}
+			}
+			return nil, err
+		}
+		break
+	}
+
+	var versions []*semver.Version
+	for _, tag := range registryTags {
+		// Helm replaces the plus (+) of a version with an underscore (_)
+		// in tags, see https://github.com/helm/helm/issues/10166
+		v, err := semver.StrictNewVersion(strings.ReplaceAll(tag, "_", "+"))
+		if err == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+
+	tags := make([]string, len(versions))
+	for i, v := range versions {
+		tags[i] = v.String()
+	}
+	return tags, nil
+}
+
+// ConfigMediaType returns the media type of the config referenced by the
+// manifest of the OCI artifact with the given reference.
+func (c *Client) ConfigMediaType(ref string) (string, error) {
+	parsedRef, err := orasregistry.ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+
+	client := &registryauth.Client{
+		Client:     c.httpClient,
+		Header:     http.Header{"User-Agent": {useragent.Get()}},
+		Credential: c.credential,
+	}
+	ctx := registryauth.AppendScopes(context.Background(),
+		registryauth.ScopeRepository(parsedRef.Repository, registryauth.ActionPull))
+
+	scheme := "https"
+	for {
+		u := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, parsedRef.Host(), parsedRef.Repository, parsedRef.ReferenceOrDefault())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Accept", ocispec.MediaTypeImageManifest)
+		resp, err := client.Do(req)
+		if err != nil {
+			// Fallback to a plain HTTP request
+			if scheme == "https" && strings.Contains(err.Error(), "server gave HTTP response") {
+				scheme = "http"
+				continue
+			}
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", &StatusError{
+				StatusCode: resp.StatusCode,
+				Err:        fmt.Errorf("GET %q: unexpected status code %d", u, resp.StatusCode),
+			}
+		}
+		var manifest ocispec.Manifest
+		if err = json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+			return "", fmt.Errorf("failed to decode manifest of '%s': %w", ref, err)
+		}
+		return manifest.Config.MediaType, nil
+	}
+}
+
+// statusRecorder is an http.RoundTripper which records the status code of
+// the last response, as the ORAS client does not return it in a typed error.
+type statusRecorder struct {
+	next       http.RoundTripper
+	statusCode int
+}
+
+// RoundTrip performs the request with the next http.RoundTripper, and
+// records the status code of the response.
+func (r *statusRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err == nil {
+		r.statusCode = resp.StatusCode
+	}
+	return resp, err
+}
+
+// credential returns the credential stored for the given registry. The
+// credentials file is read on every call, as it is written to by Login.
+func (c *Client) credential(_ context.Context, reg string) (registryauth.Credential, error) {
+	credentialsFile := c.credentialsFile
+	if credentialsFile == "" {
+		credentialsFile = helmpath.ConfigPath(registry.CredentialsFileBasename)
+	}
+	authClient, err := dockerauth.NewClientWithDockerFallback(credentialsFile)
+	if err != nil {
+		return registryauth.EmptyCredential, err
+	}
+	dockerClient, ok := authClient.(*dockerauth.Client)
+	if !ok {
+		return registryauth.EmptyCredential, fmt.Errorf("unable to obtain docker client")
+	}
+	username, password, err := dockerClient.Credential(reg)
+	if err != nil {
+		return registryauth.EmptyCredential, fmt.Errorf("unable to retrieve credentials: %w", err)
+	}
+	// A blank username with a password is a bearer token
+	if username == "" && password != "" {
+		return registryauth.Credential{RefreshToken: password}, nil
+	}
+	return registryauth.Credential{Username: username, Password: password}, nil
+}
+
 // ClientGenerator generates a registry client and a temporary credential file.
 // The client is meant to be used for a single reconciliation.
 // The file is meant to be used for a single reconciliation and deleted after.
-func ClientGenerator(isLogin bool) (*registry.Client, string, error) {
+func ClientGenerator(isLogin bool) (*Client, string, error) {
 	if isLogin {
 		// create a temporary file to store the credentials
 		// this is needed because otherwise the credentials are stored in ~/.docker/config.json.
@@ -37,7 +256,7 @@ func ClientGenerator(isLogin bool) (*registry.Client, string, error) {
 		}
 
 		var errs []error
-		rClient, err := registry.NewClient(registry.ClientOptWriter(io.Discard), registry.ClientOptCredentialsFile(credentialsFile.Name()))
+		rClient, err := NewClient(credentialsFile.Name())
 		if err != nil {
 			errs = append(errs, err)
 			// attempt to delete the temporary file
@@ -52,9 +271,17 @@ func ClientGenerator(isLogin bool) (*registry.Client, string, error) {
 		return rClient, credentialsFile.Name(), nil
 	}
 
-	rClient, err := registry.NewClient(registry.ClientOptWriter(io.Discard))
+	rClient, err := NewClient("")
 	if err != nil {
 		return nil, "", err
 	}
 	return rClient, "", nil
 }
+
+// IsBlobRequest returns if the given request fetches a blob from an OCI
+// registry, e.g. the layer of a chart, as opposed to a manifest or other
+// metadata. Blobs are streamed to disk, and are not limited in size by
+// helm.MaxManifestSize.
+func IsBlobRequest(req *http.Request) bool {
+	return strings.Contains(req.URL.Path, "/blobs/")
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/pkg/gittestserver"
+	extgogit "github.com/go-git/go-git/v5"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/internal/helm"
+	"github.com/fluxcd/source-controller/internal/transport"
+)
+
+func TestClient_Tags(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "sorted SemVer tags",
+			body: `{"name":"charts/podinfo","tags":["6.0.0","latest","6.1.0_build.1","6.1.0"]}`,
+			want: []string{"6.1.0+build.1", "6.1.0", "6.0.0"},
+		},
+		{
+			name:    "response exceeding limit",
+			body:    `{"name":"charts/podinfo","tags":["` + strings.Repeat("6.0.0", 1024) + `"]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v2/charts/podinfo/tags/list" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			c, err := NewClient(filepath.Join(t.TempDir(), "config.json"))
+			g.Expect(err).ToNot(HaveOccurred())
+			c.httpClient = NewHTTPClient(1024)
+
+			tags, err := c.Tags(strings.TrimPrefix(server.URL, "http://") + "/charts/podinfo")
+			if tt.wantErr {
+				var tooLarge *transport.ResponseTooLargeError
+				g.Expect(errors.As(err, &tooLarge)).To(BeTrue(), "unexpected error: %v", err)
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(tags).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestClient_Tags_statusError(t *testing.T) {
+	for _, code := range []int{http.StatusUnauthorized, http.StatusTooManyRequests} {
+		t.Run(http.StatusText(code), func(t *testing.T) {
+			g := NewWithT(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(code)
+			}))
+			defer server.Close()
+
+			c, err := NewClient(filepath.Join(t.TempDir(), "config.json"))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			_, err = c.Tags(strings.TrimPrefix(server.URL, "http://") + "/charts/podinfo")
+			var statusErr *StatusError
+			g.Expect(errors.As(err, &statusErr)).To(BeTrue(), "unexpected error: %v", err)
+			g.Expect(statusErr.StatusCode).To(Equal(code))
+		})
+	}
+}
+
+func TestClient_ConfigMediaType(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/charts/podinfo/manifests/6.1.0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		fmt.Fprint(w, `{"schemaVersion":2,"config":{"mediaType":"application/vnd.cncf.flux.config.v1+json"},"layers":[]}`)
+	}))
+	defer server.Close()
+
+	c, err := NewClient(filepath.Join(t.TempDir(), "config.json"))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	mediaType, err := c.ConfigMediaType(host + "/charts/podinfo:6.1.0")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mediaType).To(Equal("application/vnd.cncf.flux.config.v1+json"))
+
+	_, err = c.ConfigMediaType(host + "/charts/podinfo:6.0.0")
+	var statusErr *StatusError
+	g.Expect(errors.As(err, &statusErr)).To(BeTrue(), "unexpected error: %v", err)
+	g.Expect(statusErr.StatusCode).To(Equal(http.StatusNotFound))
+}
+
+// TestClientGenerator_gitClone guards against the response size limit of
+// the registry client leaking into http.DefaultClient, which is used by
+// go-git for HTTP(S) clones.
+func TestClientGenerator_gitClone(t *testing.T) {
+	g := NewWithT(t)
+
+	limit := helm.MaxManifestSize
+	helm.MaxManifestSize = 1 << 20
+	defer func() { helm.MaxManifestSize = limit }()
+
+	_, _, err := ClientGenerator(false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	// Random data does not compress, which results in a packfile larger
+	// than the limit.
+	fixture := t.TempDir()
+	data := make([]byte, 2*helm.MaxManifestSize)
+	_, err = rand.Read(data)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(os.WriteFile(filepath.Join(fixture, "data"), data, 0o644)).To(Succeed())
+	g.Expect(server.InitRepo(fixture, "main", "large.git")).To(Succeed())
+
+	_, err = extgogit.PlainClone(t.TempDir(), false, &extgogit.CloneOptions{
+		URL: server.HTTPAddress() + "/large.git",
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func TestIsBlobRequest(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://registry.example.com/v2/charts/podinfo/blobs/sha256:abc", want: true},
+		{url: "https://registry.example.com/v2/charts/podinfo/manifests/6.1.0"},
+		{url: "https://registry.example.com/v2/charts/podinfo/tags/list"},
+		{url: "https://auth.example.com/token?scope=repository:charts/podinfo:pull"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			g := NewWithT(t)
+
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(IsBlobRequest(req)).To(Equal(tt.want))
+		})
+	}
+}
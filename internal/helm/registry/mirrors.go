@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// defaultHost is the name of the hosts directory of which the mirrors
+// apply to registries without a directory of their own.
+const defaultHost = "_default"
+
+// Mirrors maps OCI registry hosts to the hosts of the mirrors serving the
+// same content, in order of preference.
+type Mirrors map[string][]string
+
+// DefaultMirrors are the registry mirrors used to pull Helm charts from OCI
+// registries.
+var DefaultMirrors Mirrors
+
+// Lookup returns the mirrors for the given registry host. If no mirrors are
+// configured for the host, the mirrors of the "_default" host are returned.
+func (m Mirrors) Lookup(host string) []string {
+	if mirrors, ok := m[host]; ok {
+		return mirrors
+	}
+	return m[defaultHost]
+}
+
+// LoadMirrors loads the registry mirrors from a containerd-style registry
+// hosts directory, in which the mirrors of a registry are configured in a
+// '<dir>/<registry host>/hosts.toml' file:
+//
+//	server = "https://registry.example.com"
+//
+//	[host."https://mirror.example.com"]
+//	  capabilities = ["pull", "resolve"]
+//
+// The mirrors are the host tables of the file, in order of appearance.
+// Mirrors without the "pull" capability are ignored, other keys are not
+// interpreted.
+func LoadMirrors(dir string) (Mirrors, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry hosts directory: %w", err)
+	}
+	m := Mirrors{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		p := filepath.Join(dir, e.Name(), "hosts.toml")
+		f, err := os.Open(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		mirrors, err := parseHostsFile(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse '%s': %w", p, err)
+		}
+		if len(mirrors) > 0 {
+			m[e.Name()] = mirrors
+		}
+	}
+	return m, nil
+}
+
+// hostsFile is the subset of a containerd-style hosts.toml file which is
+// interpreted.
+type hostsFile struct {
+	Host map[string]hostConfig `toml:"host"`
+}
+
+// hostConfig is the configuration of a mirror in a hosts.toml file.
+type hostConfig struct {
+	// Capabilities of the mirror, all capabilities when nil.
+	Capabilities []string `toml:"capabilities"`
+}
+
+// parseHostsFile returns the hosts of the mirrors with the "pull" capability
+// from the given hosts.toml file.
+func parseHostsFile(r io.Reader) ([]string, error) {
+	var file hostsFile
+	md, err := toml.NewDecoder(r).Decode(&file)
+	if err != nil {
+		return nil, err
+	}
+
+	// The keys of the metadata are in order of appearance, unlike the
+	// decoded map.
+	var hosts []string
+	for _, key := range md.Keys() {
+		if len(key) != 2 || key[0] != "host" {
+			continue
+		}
+		name := key[1]
+		u, err := url.Parse(name)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid mirror URL '%s'", name)
+		}
+		// Mirrors are pullable unless their capabilities say otherwise.
+		if caps := file.Host[name].Capabilities; caps != nil && !containsString(caps, "pull") {
+			continue
+		}
+		hosts = append(hosts, u.Host)
+	}
+	return hosts, nil
+}
+
+// containsString returns if the slice contains the string.
+func containsString(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLoadMirrors(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"registry.example.com/hosts.toml": `server = "https://registry.example.com"
+
+# Mirrors in order of preference
+[host."https://mirror-a.example.com"]
+  capabilities = ["pull", "resolve"]
+
+[host."https://mirror-b.example.com:5000/v2"]
+  capabilities = ["resolve", "push"]
+
+[host.'http://mirror-c.example.com']
+  skip_verify = true
+
+[host."https://mirror-d.example.com"]
+  capabilities = [
+    "resolve",
+    "pull",
+  ]
+  [host."https://mirror-d.example.com".header]
+    x-custom = ["pull"]
+
+[host."https://mirror-e.example.com"]
+  capabilities = [
+    "resolve",
+  ]
+  [host."https://mirror-e.example.com".header]
+    x-custom = "[host.\"https://mirror-f.example.com\"]"
+`,
+		"_default/hosts.toml":        "[host.\"https://mirror.internal\"]\n",
+		"ghcr.io/hosts.toml":         "server = \"https://ghcr.io\"\n",
+		"docker.io/README.md":        "no hosts file",
+		"not-a-dir.example.com.toml": "[host.\"https://ignored.example.com\"]\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		g.Expect(os.MkdirAll(filepath.Dir(p), 0o700)).To(Succeed())
+		g.Expect(os.WriteFile(p, []byte(content), 0o600)).To(Succeed())
+	}
+
+	m, err := LoadMirrors(dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(m).To(Equal(Mirrors{
+		"registry.example.com": {"mirror-a.example.com", "mirror-c.example.com", "mirror-d.example.com"},
+		"_default":             {"mirror.internal"},
+	}))
+	g.Expect(m.Lookup("registry.example.com")).To(Equal([]string{"mirror-a.example.com", "mirror-c.example.com", "mirror-d.example.com"}))
+	g.Expect(m.Lookup("quay.io")).To(Equal([]string{"mirror.internal"}))
+
+	var empty Mirrors
+	g.Expect(empty.Lookup("quay.io")).To(BeEmpty())
+}
+
+func TestLoadMirrors_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "invalid table header",
+			content: "[host.\"https://mirror.example.com\"\n",
+			wantErr: "expected '.' or ']' to end table name",
+		},
+		{
+			name:    "invalid capabilities",
+			content: "[host.\"https://mirror.example.com\"]\ncapabilities = \"pull\"\n",
+			wantErr: "capabilities",
+		},
+		{
+			name:    "invalid mirror URL",
+			content: "\n[host.\"mirror.example.com\"]\n",
+			wantErr: "invalid mirror URL 'mirror.example.com'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			p := filepath.Join(dir, "registry.example.com", "hosts.toml")
+			g.Expect(os.MkdirAll(filepath.Dir(p), 0o700)).To(Succeed())
+			g.Expect(os.WriteFile(p, []byte(tt.content), 0o600)).To(Succeed())
+
+			_, err := LoadMirrors(dir)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+		})
+	}
+
+	g := NewWithT(t)
+	_, err := LoadMirrors(filepath.Join(t.TempDir(), "missing"))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(strings.Contains(err.Error(), "failed to read registry hosts directory")).To(BeTrue())
+}
@@ -24,9 +24,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -34,6 +37,7 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
 	"helm.sh/helm/v3/pkg/repo"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"sigs.k8s.io/yaml"
@@ -43,10 +47,19 @@ import (
 	"github.com/fluxcd/source-controller/internal/cache"
 	"github.com/fluxcd/source-controller/internal/helm"
 	"github.com/fluxcd/source-controller/internal/transport"
+	"github.com/fluxcd/source-controller/internal/util"
 )
 
 var ErrNoChartIndex = errors.New("no chart index")
 
+// ErrChartDigestMismatch is returned when the digest of a downloaded chart
+// does not match the digest of the chart version in the index.
+var ErrChartDigestMismatch = errors.New("chart digest mismatch")
+
+// ErrIndexNotModified is returned when a conditional download of the chart
+// repository index is answered with 304 Not Modified.
+var ErrIndexNotModified = errors.New("index not modified")
+
 // ChartRepository represents a Helm chart repository, and the configuration
 // required to download the chart index and charts from the repository.
 // All methods are thread safe unless defined otherwise.
@@ -54,6 +67,13 @@ type ChartRepository struct {
 	// URL the ChartRepository's index.yaml can be found at,
 	// without the index.yaml suffix.
 	URL string
+	// Mirrors are the URLs of chart repositories serving the same content
+	// as URL, without the index.yaml suffix. They are tried in order when
+	// a download from URL fails with a connection error or a 5xx status.
+	Mirrors []string
+	// ServedURL is the URL or mirror the last successful download of the
+	// index or a chart was served from.
+	ServedURL string
 	// Client to use while downloading the Index or a chart from the URL.
 	Client getter.Getter
 	// Options to configure the Client with while downloading the Index
@@ -70,9 +90,28 @@ type ChartRepository struct {
 	// index bytes. This is different from the checksum of the CachePath, which
 	// may contain unordered entries.
 	Checksum string
+	// ETag contains the entity tag of the index as returned by the remote
+	// on the last (conditional) download, if any.
+	ETag string
+	// LastModified contains the last modification time of the index as
+	// returned by the remote on the last (conditional) download, if any.
+	LastModified string
 
 	tlsConfig *tls.Config
 
+	// ociDownloader returns the Downloader for charts of which the URL in
+	// the index refers to an OCI registry.
+	ociDownloader OCIDownloaderFunc
+
+	// username, password, passCredentialsAll, userAgent and timeout mirror
+	// the getter.Options of the conditional index downloads, which are not
+	// performed using the getter.Getter.
+	username           string
+	password           string
+	passCredentialsAll bool
+	userAgent          string
+	timeout            time.Duration
+
 	*sync.RWMutex
 
 	cacheInfo
@@ -115,6 +154,76 @@ func WithMemoryCache(key string, c *cache.Cache, ttl time.Duration, rec RecordMe
 	}
 }
 
+// WithBasicAuth returns a ChartRepositoryOption that configures the username
+// and password used to authenticate conditional index downloads. This is
+// required in addition to a basic auth getter.Option, as the conditional
+// downloads are not performed using the getter.Getter.
+func WithBasicAuth(username, password string) ChartRepositoryOption {
+	return func(r *ChartRepository) error {
+		r.username = username
+		r.password = password
+		return nil
+	}
+}
+
+// WithPassCredentialsAll returns a ChartRepositoryOption that configures
+// whether the basic auth credentials of conditional index downloads are
+// passed to other hosts than the one of the URL, mirroring
+// getter.WithPassCredentialsAll.
+func WithPassCredentialsAll(pass bool) ChartRepositoryOption {
+	return func(r *ChartRepository) error {
+		r.passCredentialsAll = pass
+		return nil
+	}
+}
+
+// WithUserAgent returns a ChartRepositoryOption that configures the
+// User-Agent of conditional index downloads, mirroring getter.WithUserAgent.
+func WithUserAgent(userAgent string) ChartRepositoryOption {
+	return func(r *ChartRepository) error {
+		r.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithTimeout returns a ChartRepositoryOption that configures the timeout of
+// conditional index downloads.
+func WithTimeout(timeout time.Duration) ChartRepositoryOption {
+	return func(r *ChartRepository) error {
+		r.timeout = timeout
+		return nil
+	}
+}
+
+// WithMirrors returns a ChartRepositoryOption that configures the mirrors
+// to fall back to when a download from the repository URL fails.
+func WithMirrors(urls ...string) ChartRepositoryOption {
+	return func(r *ChartRepository) error {
+		for _, u := range urls {
+			if _, err := url.Parse(u); err != nil {
+				return fmt.Errorf("invalid mirror URL '%s': %w", u, err)
+			}
+		}
+		r.Mirrors = urls
+		return nil
+	}
+}
+
+// OCIDownloaderFunc returns the Downloader for the chart at the given
+// oci:// URL.
+type OCIDownloaderFunc func(chartURL *url.URL) (Downloader, error)
+
+// WithOCIDownloader returns a ChartRepositoryOption that configures the
+// function returning the Downloader for index entries of which the chart
+// URL refers to an OCI registry. Without it, downloading such a chart
+// fails.
+func WithOCIDownloader(fn OCIDownloaderFunc) ChartRepositoryOption {
+	return func(r *ChartRepository) error {
+		r.ociDownloader = fn
+		return nil
+	}
+}
+
 // NewChartRepository constructs and returns a new ChartRepository with
 // the ChartRepository.Client configured to the getter.Getter for the
 // repository URL scheme. It returns an error on URL parsing failures,
@@ -243,7 +352,22 @@ func (r *ChartRepository) GetChartVersion(name, ver string) (*repo.ChartVersion,
 // DownloadChart confirms the given repo.ChartVersion has a downloadable URL,
 // and then attempts to download the chart using the Client and Options of the
 // ChartRepository. It returns a bytes.Buffer containing the chart data.
+// Charts of which the URL refers to an OCI registry are downloaded using the
+// Downloader configured with WithOCIDownloader.
+// If the repo.ChartVersion has a digest, the chart data is verified against
+// it, and an ErrChartDigestMismatch error is returned if it does not match.
 func (r *ChartRepository) DownloadChart(chart *repo.ChartVersion) (*bytes.Buffer, error) {
+	res, err := r.downloadChartVersion(chart)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyChartDigest(chart, res.Bytes()); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *ChartRepository) downloadChartVersion(chart *repo.ChartVersion) (*bytes.Buffer, error) {
 	if len(chart.URLs) == 0 {
 		return nil, fmt.Errorf("chart '%s' has no downloadable URLs", chart.Name)
 	}
@@ -258,20 +382,80 @@ func (r *ChartRepository) DownloadChart(chart *repo.ChartVersion) (*bytes.Buffer
 		return nil, err
 	}
 
-	// Prepend the chart repository base URL if the URL is relative
-	if !u.IsAbs() {
-		repoURL, err := url.Parse(r.URL)
-		if err != nil {
-			err = fmt.Errorf("invalid chart repository URL format '%s': %w", r.URL, err)
-			return nil, err
+	if u.Scheme == registry.OCIScheme {
+		return r.downloadOCIChart(chart, u)
+	}
+
+	// Absolute URLs outside the repository can not be served by a mirror
+	repoPrefix := strings.TrimSuffix(r.URL, "/") + "/"
+	if u.IsAbs() && !strings.HasPrefix(ref, repoPrefix) {
+		return r.downloadChart(u)
+	}
+
+	var res *bytes.Buffer
+	err = r.withFailover(func(baseURL string) error {
+		var err error
+		cu := u
+		switch {
+		case u.IsAbs() && baseURL != r.URL:
+			// Rewrite the chart URL to the mirror, retaining its query
+			var rel, mirrorURL *url.URL
+			if rel, err = url.Parse(strings.TrimPrefix(ref, repoPrefix)); err != nil {
+				return fmt.Errorf("invalid chart URL format '%s': %w", ref, err)
+			}
+			if mirrorURL, err = url.Parse(strings.TrimSuffix(baseURL, "/") + "/"); err != nil {
+				return fmt.Errorf("invalid chart repository URL format '%s': %w", baseURL, err)
+			}
+			cu = mirrorURL.ResolveReference(rel)
+			cu.RawQuery = u.RawQuery
+		case !u.IsAbs():
+			// Prepend the chart repository base URL if the URL is relative
+			var repoURL *url.URL
+			if repoURL, err = url.Parse(baseURL); err != nil {
+				return fmt.Errorf("invalid chart repository URL format '%s': %w", baseURL, err)
+			}
+			q := repoURL.Query()
+			// Trailing slash is required for ResolveReference to work
+			repoURL.Path = strings.TrimSuffix(repoURL.Path, "/") + "/"
+			cu = repoURL.ResolveReference(u)
+			cu.RawQuery = q.Encode()
 		}
-		q := repoURL.Query()
-		// Trailing slash is required for ResolveReference to work
-		repoURL.Path = strings.TrimSuffix(repoURL.Path, "/") + "/"
-		u = repoURL.ResolveReference(u)
-		u.RawQuery = q.Encode()
+
+		res, err = r.downloadChart(cu)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return res, nil
+}
 
+// downloadOCIChart downloads the chart of which the URL refers to an OCI
+// registry, using the Downloader returned by the configured
+// OCIDownloaderFunc.
+func (r *ChartRepository) downloadOCIChart(chart *repo.ChartVersion, u *url.URL) (_ *bytes.Buffer, err error) {
+	if r.ociDownloader == nil {
+		return nil, fmt.Errorf("chart '%s' version '%s' refers to OCI registry '%s', which is not supported by the chart repository",
+			chart.Name, chart.Version, u.Host)
+	}
+	d, err := r.ociDownloader(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct OCI chart repository for '%s': %w", u.Host, err)
+	}
+	defer func() {
+		if clearErr := d.Clear(); clearErr != nil && err == nil {
+			err = clearErr
+		}
+	}()
+
+	// Only pass on the OCI URL, the other URLs of the entry can not be
+	// served by the registry.
+	ociChart := *chart
+	ociChart.URLs = []string{u.String()}
+	return d.DownloadChart(&ociChart)
+}
+
+func (r *ChartRepository) downloadChart(u *url.URL) (*bytes.Buffer, error) {
 	t := transport.NewOrIdle(r.tlsConfig)
 	clientOpts := append(r.Options, getter.WithTransport(t))
 	defer transport.Release(t)
@@ -279,6 +463,28 @@ func (r *ChartRepository) DownloadChart(chart *repo.ChartVersion) (*bytes.Buffer
 	return r.Client.Get(u.String(), clientOpts...)
 }
 
+// verifyChartDigest verifies the given chart data against the digest of the
+// repo.ChartVersion, if set. The digest is expected to be a SHA256 checksum
+// in hex, with or without a "sha256:" prefix.
+func verifyChartDigest(chart *repo.ChartVersion, b []byte) error {
+	if chart.Digest == "" {
+		return nil
+	}
+	expected := strings.ToLower(strings.TrimSpace(chart.Digest))
+	if i := strings.Index(expected, ":"); i >= 0 {
+		if algo := expected[:i]; algo != "sha256" {
+			return fmt.Errorf("unsupported digest algorithm '%s' of chart '%s' version '%s'", algo, chart.Name, chart.Version)
+		}
+		expected = expected[i+1:]
+	}
+	sum := sha256.Sum256(b)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return fmt.Errorf("%w: chart '%s' version '%s' has digest 'sha256:%s', expected '%s'",
+			ErrChartDigestMismatch, chart.Name, chart.Version, actual, chart.Digest)
+	}
+	return nil
+}
+
 // LoadIndexFromBytes loads Index from the given bytes.
 // It returns a repo.ErrNoAPIVersion error if the API version is not set
 func (r *ChartRepository) LoadIndexFromBytes(b []byte) error {
@@ -323,7 +529,7 @@ func (r *ChartRepository) LoadFromFile(path string) error {
 // The caller is expected to handle the garbage collection of CachePath, and to
 // load the Index separately using LoadFromCache if required.
 func (r *ChartRepository) CacheIndex() (string, error) {
-	f, err := os.CreateTemp("", "chart-index-*.yaml")
+	f, err := util.CreateTemp("chart-index-*.yaml")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp file to cache index to: %w", err)
 	}
@@ -347,6 +553,39 @@ func (r *ChartRepository) CacheIndex() (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// CacheIndexIfModified is like CacheIndex, but performs a conditional
+// download of the index using DownloadIndexIfModified with the given ETag
+// and Last-Modified validators.
+// If the remote index has not been modified, it returns ErrIndexNotModified
+// and the CachePath is not set.
+func (r *ChartRepository) CacheIndexIfModified(etag, lastModified string) (string, error) {
+	f, err := util.CreateTemp("chart-index-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file to cache index to: %w", err)
+	}
+
+	h := sha256.New()
+	mw := io.MultiWriter(f, h)
+	if err = r.DownloadIndexIfModified(mw, etag, lastModified); err != nil {
+		f.Close()
+		os.RemoveAll(f.Name())
+		if errors.Is(err, ErrIndexNotModified) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to cache index to temporary file: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		os.RemoveAll(f.Name())
+		return "", fmt.Errorf("failed to close cached index file '%s': %w", f.Name(), err)
+	}
+
+	r.Lock()
+	r.CachePath = f.Name()
+	r.Cached = true
+	r.Unlock()
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // CacheIndexInMemory attempts to cache the index in memory.
 // It returns an error if it fails.
 // The cache key have to be safe in multi-tenancy environments,
@@ -427,8 +666,14 @@ func (r *ChartRepository) LoadFromCache() error {
 // DownloadIndex attempts to download the chart repository index using
 // the Client and set Options, and writes the index to the given io.Writer.
 // It returns an url.Error if the URL failed to parse.
-func (r *ChartRepository) DownloadIndex(w io.Writer) (err error) {
-	u, err := url.Parse(r.URL)
+func (r *ChartRepository) DownloadIndex(w io.Writer) error {
+	return r.withFailover(func(baseURL string) error {
+		return r.downloadIndex(w, baseURL)
+	})
+}
+
+func (r *ChartRepository) downloadIndex(w io.Writer, baseURL string) (err error) {
+	u, err := url.Parse(baseURL)
 	if err != nil {
 		return err
 	}
@@ -450,6 +695,148 @@ func (r *ChartRepository) DownloadIndex(w io.Writer) (err error) {
 	return nil
 }
 
+// DownloadIndexIfModified attempts to download the chart repository index
+// using a conditional request with If-None-Match and If-Modified-Since headers
+// for the given non-empty ETag and Last-Modified validators, and writes the
+// index to the given io.Writer. It records the validators of the response
+// in ETag and LastModified.
+// If the remote responds with 304 Not Modified, it returns
+// ErrIndexNotModified. For repositories with a URL scheme other than HTTP(S),
+// it falls back to DownloadIndex.
+func (r *ChartRepository) DownloadIndexIfModified(w io.Writer, etag, lastModified string) error {
+	return r.withFailover(func(baseURL string) error {
+		return r.downloadIndexIfModified(w, baseURL, etag, lastModified)
+	})
+}
+
+func (r *ChartRepository) downloadIndexIfModified(w io.Writer, baseURL, etag, lastModified string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return r.downloadIndex(w, baseURL)
+	}
+	u.RawPath = path.Join(u.RawPath, "index.yaml")
+	u.Path = path.Join(u.Path, "index.yaml")
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	if r.userAgent != "" {
+		req.Header.Set("User-Agent", r.userAgent)
+	}
+	// Like the getter.Getter, only pass the credentials to mirrors on
+	// another host if configured to.
+	if r.username != "" && r.password != "" && (r.passCredentialsAll || sameHost(r.URL, baseURL)) {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	clientOpts := transport.ClientOptions{
+		TLSConfig:       r.tlsConfig,
+		Timeout:         r.timeout,
+		MaxResponseSize: helm.MaxIndexSize,
+	}
+	if len(r.Mirrors) > 0 {
+		// Fail over to the mirrors right away instead.
+		clientOpts.RetryMax = -1
+	}
+	client, release := transport.NewClient(clientOpts)
+	defer release()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return ErrIndexNotModified
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("failed to fetch %s : %s", u.String(), resp.Status)
+	}
+
+	// Buffer the index, so that nothing is written to w if the body can not
+	// be read completely and the download is retried from a mirror.
+	var buf bytes.Buffer
+	if _, err = io.Copy(&buf, resp.Body); err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, &buf); err != nil {
+		return err
+	}
+
+	r.Lock()
+	r.ETag = resp.Header.Get("ETag")
+	r.LastModified = resp.Header.Get("Last-Modified")
+	r.Unlock()
+	return nil
+}
+
+// sameHost returns if the given URLs have the same scheme and host,
+// including the port.
+func sameHost(u1, u2 string) bool {
+	p1, err := url.Parse(u1)
+	if err != nil {
+		return false
+	}
+	p2, err := url.Parse(u2)
+	if err != nil {
+		return false
+	}
+	return p1.Scheme == p2.Scheme && p1.Host == p2.Host
+}
+
+// serverErrRegexp matches the 5xx status of the errors returned by the
+// Helm HTTP getter and DownloadIndexIfModified, which are formatted as
+// "failed to fetch <URL> : <status>".
+var serverErrRegexp = regexp.MustCompile(` : 5\d\d\b`)
+
+// withFailover calls fn with the URL of the repository, and with each of
+// the Mirrors in order for as long as fn fails with an error for which
+// isFailoverErr returns true. It records the URL of the first successful
+// call in ServedURL. If all calls fail, the errors are returned as an
+// aggregate.
+func (r *ChartRepository) withFailover(fn func(baseURL string) error) error {
+	var errs []error
+	for _, u := range append([]string{r.URL}, r.Mirrors...) {
+		err := fn(u)
+		if err == nil {
+			if r.RWMutex != nil {
+				r.Lock()
+				defer r.Unlock()
+			}
+			r.ServedURL = u
+			return nil
+		}
+		if len(r.Mirrors) == 0 || !isFailoverErr(err) {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("'%s': %w", u, err))
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// isFailoverErr returns true if the given error is a connection error, or
+// reports a 5xx status returned by the remote.
+func isFailoverErr(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Op != "parse"
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || serverErrRegexp.MatchString(err.Error())
+}
+
 // HasIndex returns true if the Index is not nil.
 func (r *ChartRepository) HasIndex() bool {
 	r.RLock()
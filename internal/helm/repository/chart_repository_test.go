@@ -19,14 +19,19 @@ package repository
 import (
 	"bytes"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/fluxcd/source-controller/internal/helm"
+	"github.com/fluxcd/source-controller/internal/transport"
 	. "github.com/onsi/gomega"
 	"helm.sh/helm/v3/pkg/chart"
 	helmgetter "helm.sh/helm/v3/pkg/getter"
@@ -201,8 +206,10 @@ func TestChartRepository_DownloadChart(t *testing.T) {
 		name         string
 		url          string
 		chartVersion *repo.ChartVersion
+		response     []byte
 		wantURL      string
 		wantErr      bool
+		wantErrIs    error
 	}{
 		{
 			name: "relative URL",
@@ -213,6 +220,51 @@ func TestChartRepository_DownloadChart(t *testing.T) {
 			},
 			wantURL: "https://example.com/charts/foo-1.0.0.tgz",
 		},
+		{
+			name: "sha256 prefixed digest",
+			url:  "https://example.com",
+			chartVersion: &repo.ChartVersion{
+				Metadata: &chart.Metadata{Name: "chart", Version: "1.0.0"},
+				URLs:     []string{"charts/foo-1.0.0.tgz"},
+				Digest:   "sha256:cc57fc1903e444cf6a726490b43b27ee9f87facc037f86872201847c565b45fb",
+			},
+			response: []byte("chart"),
+			wantURL:  "https://example.com/charts/foo-1.0.0.tgz",
+		},
+		{
+			name: "bare digest",
+			url:  "https://example.com",
+			chartVersion: &repo.ChartVersion{
+				Metadata: &chart.Metadata{Name: "chart", Version: "1.0.0"},
+				URLs:     []string{"charts/foo-1.0.0.tgz"},
+				Digest:   "CC57FC1903E444CF6A726490B43B27EE9F87FACC037F86872201847C565B45FB",
+			},
+			response: []byte("chart"),
+			wantURL:  "https://example.com/charts/foo-1.0.0.tgz",
+		},
+		{
+			name: "corrupted download",
+			url:  "https://example.com",
+			chartVersion: &repo.ChartVersion{
+				Metadata: &chart.Metadata{Name: "chart", Version: "1.0.0"},
+				URLs:     []string{"charts/foo-1.0.0.tgz"},
+				Digest:   "sha256:cc57fc1903e444cf6a726490b43b27ee9f87facc037f86872201847c565b45fb",
+			},
+			response:  []byte("corrupted chart"),
+			wantErr:   true,
+			wantErrIs: ErrChartDigestMismatch,
+		},
+		{
+			name: "unsupported digest algorithm",
+			url:  "https://example.com",
+			chartVersion: &repo.ChartVersion{
+				Metadata: &chart.Metadata{Name: "chart", Version: "1.0.0"},
+				URLs:     []string{"charts/foo-1.0.0.tgz"},
+				Digest:   "md5:a3c3a3c2b6d2d9c7f0a1d1e76c9d8fb5",
+			},
+			response: []byte("chart"),
+			wantErr:  true,
+		},
 		{
 			name:         "no chart URL",
 			chartVersion: &repo.ChartVersion{Metadata: &chart.Metadata{Name: "chart"}},
@@ -233,7 +285,7 @@ func TestChartRepository_DownloadChart(t *testing.T) {
 			g := NewWithT(t)
 			t.Parallel()
 
-			mg := mockGetter{}
+			mg := mockGetter{Response: tt.response}
 			r := &ChartRepository{
 				URL:    tt.url,
 				Client: &mg,
@@ -241,6 +293,9 @@ func TestChartRepository_DownloadChart(t *testing.T) {
 			res, err := r.DownloadChart(tt.chartVersion)
 			if tt.wantErr {
 				g.Expect(err).To(HaveOccurred())
+				if tt.wantErrIs != nil {
+					g.Expect(errors.Is(err, tt.wantErrIs)).To(BeTrue())
+				}
 				g.Expect(res).To(BeNil())
 				return
 			}
@@ -251,6 +306,93 @@ func TestChartRepository_DownloadChart(t *testing.T) {
 	}
 }
 
+func TestChartRepository_DownloadChart_OCI(t *testing.T) {
+	index := []byte(`apiVersion: v1
+entries:
+  podinfo:
+  - name: podinfo
+    version: 1.0.0
+    urls:
+    - oci://ghcr.io/org/charts/podinfo:1.0.0
+  nginx:
+  - name: nginx
+    version: 1.0.0
+    urls:
+    - charts/nginx-1.0.0.tgz
+`)
+
+	tests := []struct {
+		name          string
+		chart         string
+		withOCI       bool
+		wantHTTPURL   string
+		wantOCIURL    string
+		wantOCIChart  string
+		wantErrSubstr string
+	}{
+		{
+			name:         "OCI chart URL is pulled from registry",
+			chart:        "podinfo",
+			withOCI:      true,
+			wantOCIURL:   "oci://ghcr.io/org/charts/podinfo:1.0.0",
+			wantOCIChart: "ghcr.io/org/charts/podinfo:1.0.0",
+		},
+		{
+			name:        "HTTP chart URL is pulled from repository",
+			chart:       "nginx",
+			withOCI:     true,
+			wantHTTPURL: "https://example.com/charts/nginx-1.0.0.tgz",
+		},
+		{
+			name:          "OCI chart URL without OCI downloader",
+			chart:         "podinfo",
+			wantErrSubstr: "refers to OCI registry 'ghcr.io'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			httpGetter := &mockGetter{Response: []byte("http chart")}
+			ociGetter := &OCIMockGetter{Response: []byte("oci chart")}
+			var ociURL string
+
+			r := newChartRepository()
+			r.URL = "https://example.com"
+			r.Client = httpGetter
+			if tt.withOCI {
+				g.Expect(WithOCIDownloader(func(u *url.URL) (Downloader, error) {
+					ociURL = u.String()
+					return NewOCIChartRepository("oci://"+u.Host, WithOCIRegistryClient(&mockRegistryClient{}),
+						func(r *OCIChartRepository) error {
+							r.Client = ociGetter
+							return nil
+						})
+				})(r)).To(Succeed())
+			}
+			g.Expect(r.LoadIndexFromBytes(index)).To(Succeed())
+
+			cv, err := r.GetChartVersion(tt.chart, "1.0.0")
+			g.Expect(err).ToNot(HaveOccurred())
+
+			res, err := r.DownloadChart(cv)
+			if tt.wantErrSubstr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErrSubstr))
+				g.Expect(httpGetter.LastCalledURL).To(BeEmpty())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(httpGetter.LastCalledURL).To(Equal(tt.wantHTTPURL))
+			g.Expect(ociURL).To(Equal(tt.wantOCIURL))
+			g.Expect(ociGetter.LastCalledURL).To(Equal(tt.wantOCIChart))
+			if tt.wantOCIChart != "" {
+				g.Expect(res.String()).To(Equal("oci chart"))
+			}
+		})
+	}
+}
+
 func TestChartRepository_DownloadIndex(t *testing.T) {
 	g := NewWithT(t)
 
@@ -425,6 +567,432 @@ func TestChartRepository_CacheIndex(t *testing.T) {
 	g.Expect(sum).To(BeEquivalentTo(expectSum))
 }
 
+func TestChartRepository_CacheIndexIfModified(t *testing.T) {
+	b, err := os.ReadFile(chartmuseumTestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		etag         = `"index-etag"`
+		lastModified = "Mon, 02 May 2022 10:00:00 GMT"
+	)
+
+	providers := helmgetter.Providers{
+		helmgetter.Provider{
+			Schemes: []string{"http"},
+			New:     helmgetter.NewHTTPGetter,
+		},
+	}
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/index.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = w.Write(b)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name             string
+		etag             string
+		lastModified     string
+		username         string
+		wantNotModified  bool
+		wantErr          string
+		wantETag         string
+		wantLastModified string
+	}{
+		{
+			name:             "downloads without validators",
+			username:         "user",
+			wantETag:         etag,
+			wantLastModified: lastModified,
+		},
+		{
+			name:             "downloads with stale ETag",
+			etag:             `"stale"`,
+			username:         "user",
+			wantETag:         etag,
+			wantLastModified: lastModified,
+		},
+		{
+			name:            "not modified with matching ETag",
+			etag:            etag,
+			username:        "user",
+			wantNotModified: true,
+		},
+		{
+			name:            "not modified with matching Last-Modified",
+			lastModified:    lastModified,
+			username:        "user",
+			wantNotModified: true,
+		},
+		{
+			name:    "error on unauthorized",
+			wantErr: "401 Unauthorized",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var opts []ChartRepositoryOption
+			if tt.username != "" {
+				opts = append(opts, WithBasicAuth(tt.username, "pass"))
+			}
+			r, err := NewChartRepository(server.URL, "", providers, nil, nil, opts...)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			sum, err := r.CacheIndexIfModified(tt.etag, tt.lastModified)
+			if tt.wantNotModified {
+				g.Expect(err).To(MatchError(ErrIndexNotModified))
+				g.Expect(sum).To(BeEmpty())
+				g.Expect(r.CachePath).To(BeEmpty())
+				g.Expect(r.HasIndex()).To(BeFalse())
+				return
+			}
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(r.CachePath).To(BeEmpty())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			defer r.RemoveCache()
+
+			g.Expect(sum).To(Equal(fmt.Sprintf("%x", sha256.Sum256(b))))
+			g.Expect(r.CachePath).To(BeARegularFile())
+			g.Expect(r.ETag).To(Equal(tt.wantETag))
+			g.Expect(r.LastModified).To(Equal(tt.wantLastModified))
+		})
+	}
+	g := NewWithT(t)
+	g.Expect(requests).To(Equal(len(tests)))
+}
+
+func TestChartRepository_DownloadIndexIfModified_maxSize(t *testing.T) {
+	maxIndexSize := helm.MaxIndexSize
+	helm.MaxIndexSize = 1024
+	defer func() { helm.MaxIndexSize = maxIndexSize }()
+
+	providers := helmgetter.Providers{
+		helmgetter.Provider{
+			Schemes: []string{"http"},
+			New:     helmgetter.NewHTTPGetter,
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("stream") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(4096))
+		}
+		// Stream an unbounded body, until the client stops reading.
+		chunk := []byte("entries:\n")
+		for i := 0; i < 4096/len(chunk)+1; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "content length exceeds limit"},
+		{name: "streamed body exceeds limit", query: "?stream=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r, err := NewChartRepository(server.URL+tt.query, "", providers, nil, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var buf bytes.Buffer
+			err = r.DownloadIndexIfModified(&buf, "", "")
+			var tooLargeErr *transport.ResponseTooLargeError
+			g.Expect(errors.As(err, &tooLargeErr)).To(BeTrue(), "expected ResponseTooLargeError, got: %v", err)
+			g.Expect(tooLargeErr.Limit).To(Equal(helm.MaxIndexSize))
+			g.Expect(buf.Len()).To(BeZero())
+		})
+	}
+}
+
+func TestChartRepository_DownloadIndexIfModified_getterOptions(t *testing.T) {
+	b, err := os.ReadFile(chartmuseumTestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer origin.Close()
+
+	var userAgent string
+	var withAuth bool
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		_, _, withAuth = r.BasicAuth()
+		_, _ = w.Write(b)
+	}))
+	defer mirror.Close()
+
+	providers := helmgetter.Providers{
+		helmgetter.Provider{
+			Schemes: []string{"http"},
+			New:     helmgetter.NewHTTPGetter,
+		},
+	}
+
+	tests := []struct {
+		name               string
+		passCredentialsAll bool
+		wantAuth           bool
+	}{
+		{
+			name: "does not pass credentials to mirror",
+		},
+		{
+			name:               "passes credentials to mirror",
+			passCredentialsAll: true,
+			wantAuth:           true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r, err := NewChartRepository(origin.URL, "", providers, nil, nil,
+				WithBasicAuth("user", "pass"),
+				WithPassCredentialsAll(tt.passCredentialsAll),
+				WithUserAgent("source-controller/test"),
+				WithMirrors(mirror.URL))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var buf bytes.Buffer
+			g.Expect(r.DownloadIndexIfModified(&buf, "", "")).To(Succeed())
+			g.Expect(buf.Bytes()).To(Equal(b))
+			g.Expect(r.ServedURL).To(Equal(mirror.URL))
+			g.Expect(userAgent).To(Equal("source-controller/test"))
+			g.Expect(withAuth).To(Equal(tt.wantAuth))
+		})
+	}
+}
+
+func TestChartRepository_DownloadIndexIfModified_retry(t *testing.T) {
+	g := NewWithT(t)
+
+	retryWaitMin := transport.RetryWaitMin
+	transport.RetryWaitMin = time.Millisecond
+	defer func() { transport.RetryWaitMin = retryWaitMin }()
+
+	b, err := os.ReadFile(chartmuseumTestFile)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	defer server.Close()
+
+	providers := helmgetter.Providers{
+		helmgetter.Provider{
+			Schemes: []string{"http"},
+			New:     helmgetter.NewHTTPGetter,
+		},
+	}
+	r, err := NewChartRepository(server.URL, "", providers, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var buf bytes.Buffer
+	g.Expect(r.DownloadIndexIfModified(&buf, "", "")).To(Succeed())
+	g.Expect(buf.Bytes()).To(Equal(b))
+	g.Expect(requests).To(Equal(2))
+}
+
+func TestChartRepository_Mirrors(t *testing.T) {
+	b, err := os.ReadFile(chartmuseumTestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chartBytes := []byte("chart")
+
+	providers := helmgetter.Providers{
+		helmgetter.Provider{
+			Schemes: []string{"http"},
+			New:     helmgetter.NewHTTPGetter,
+		},
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer notFound.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	var mirrorRequests []string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorRequests = append(mirrorRequests, r.URL.Path)
+		switch r.URL.Path {
+		case "/index.yaml":
+			_, _ = w.Write(b)
+		case "/charts/foo-1.0.0.tgz":
+			_, _ = w.Write(chartBytes)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mirror.Close()
+
+	tests := []struct {
+		name          string
+		url           string
+		mirrors       []string
+		chartURL      string
+		wantErr       string
+		wantServedURL string
+	}{
+		{
+			name:          "fails over on 5xx",
+			url:           failing.URL,
+			mirrors:       []string{mirror.URL},
+			chartURL:      "charts/foo-1.0.0.tgz",
+			wantServedURL: mirror.URL,
+		},
+		{
+			name:          "fails over on connection error",
+			url:           closed.URL,
+			mirrors:       []string{mirror.URL},
+			chartURL:      "charts/foo-1.0.0.tgz",
+			wantServedURL: mirror.URL,
+		},
+		{
+			name:          "fails over to next mirror",
+			url:           failing.URL,
+			mirrors:       []string{closed.URL, mirror.URL},
+			chartURL:      "charts/foo-1.0.0.tgz",
+			wantServedURL: mirror.URL,
+		},
+		{
+			name:          "rewrites absolute chart URL to mirror",
+			url:           failing.URL,
+			mirrors:       []string{mirror.URL},
+			chartURL:      failing.URL + "/charts/foo-1.0.0.tgz",
+			wantServedURL: mirror.URL,
+		},
+		{
+			name:     "does not fail over on 4xx",
+			url:      notFound.URL,
+			mirrors:  []string{mirror.URL},
+			chartURL: "charts/foo-1.0.0.tgz",
+			wantErr:  "404 Not Found",
+		},
+		{
+			name:     "all mirrors failing",
+			url:      failing.URL,
+			mirrors:  []string{closed.URL},
+			chartURL: "charts/foo-1.0.0.tgz",
+			wantErr:  "503 Service Unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mirrorRequests = nil
+
+			r, err := NewChartRepository(tt.url, "", providers, nil, nil, WithMirrors(tt.mirrors...))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			index := bytes.NewBuffer([]byte{})
+			err = r.DownloadIndex(index)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(mirrorRequests).To(BeEmpty())
+				g.Expect(r.ServedURL).To(BeEmpty())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(index.Bytes()).To(Equal(b))
+			g.Expect(r.ServedURL).To(Equal(tt.wantServedURL))
+
+			index.Reset()
+			r.ServedURL = ""
+			g.Expect(r.DownloadIndexIfModified(index, "", "")).To(Succeed())
+			g.Expect(index.Bytes()).To(Equal(b))
+			g.Expect(r.ServedURL).To(Equal(tt.wantServedURL))
+
+			r.ServedURL = ""
+			res, err := r.DownloadChart(&repo.ChartVersion{
+				Metadata: &chart.Metadata{Name: "foo"},
+				URLs:     []string{tt.chartURL},
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(res.Bytes()).To(Equal(chartBytes))
+			g.Expect(r.ServedURL).To(Equal(tt.wantServedURL))
+
+			g.Expect(mirrorRequests).To(Equal([]string{"/index.yaml", "/index.yaml", "/charts/foo-1.0.0.tgz"}))
+		})
+	}
+}
+
+func TestChartRepository_UserAgent(t *testing.T) {
+	g := NewWithT(t)
+
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.UserAgent())
+		_, _ = w.Write([]byte("chart"))
+	}))
+	defer server.Close()
+
+	providers := helmgetter.Providers{
+		helmgetter.Provider{
+			Schemes: []string{"http"},
+			New:     helmgetter.NewHTTPGetter,
+		},
+	}
+	r, err := NewChartRepository(server.URL, "", providers, nil, []helmgetter.Option{
+		helmgetter.WithUserAgent("source-controller/v0.25.0 (team-a)"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = r.DownloadChart(&repo.ChartVersion{
+		Metadata: &chart.Metadata{Name: "foo"},
+		URLs:     []string{"charts/foo-1.0.0.tgz"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(userAgents).To(Equal([]string{"source-controller/v0.25.0 (team-a)"}))
+}
+
 func TestChartRepository_StrategicallyLoadIndex(t *testing.T) {
 	g := NewWithT(t)
 
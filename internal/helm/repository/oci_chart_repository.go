@@ -19,12 +19,18 @@ package repository
 import (
 	"bytes"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/getter"
@@ -32,10 +38,37 @@ import (
 	"helm.sh/helm/v3/pkg/repo"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/containerd/containerd/remotes/docker"
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
 	"github.com/fluxcd/pkg/version"
+	sourceregistry "github.com/fluxcd/source-controller/internal/helm/registry"
 	"github.com/fluxcd/source-controller/internal/transport"
 )
 
+// ErrUnauthorized is returned when the OCI registry rejects a request as
+// unauthorized, and no credentials are configured to login with.
+var ErrUnauthorized = errors.New("registry requires authentication")
+
+// NotHelmChartError is returned when a pulled OCI artifact is not a Helm
+// chart, as its config media type is not registry.ConfigMediaType.
+type NotHelmChartError struct {
+	// Ref is the reference of the pulled OCI artifact.
+	Ref string
+	// Err is the underlying error returned by the registry client.
+	Err error
+}
+
+// Error returns the error string, including the expected config media type.
+func (e *NotHelmChartError) Error() string {
+	return fmt.Sprintf("OCI artifact '%s' is not a Helm chart: expected config media type '%s': %s",
+		e.Ref, registry.ConfigMediaType, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *NotHelmChartError) Unwrap() error {
+	return e.Err
+}
+
 // RegistryClient is an interface for interacting with OCI registries
 // It is used by the OCIChartRepository to retrieve chart versions
 // from OCI registries
@@ -43,6 +76,7 @@ type RegistryClient interface {
 	Login(host string, opts ...registry.LoginOption) error
 	Logout(host string, opts ...registry.LogoutOption) error
 	Tags(url string) ([]string, error)
+	ConfigMediaType(ref string) (string, error)
 }
 
 // OCIChartRepository represents a Helm chart repository, and the configuration
@@ -56,6 +90,10 @@ type OCIChartRepository struct {
 	// Options to configure the Client with while downloading tags
 	// or a chart from the URL.
 	Options []getter.Option
+	// Mirrors are the hosts of registry mirrors serving the same content
+	// as the registry of the URL. They are tried in order before the
+	// registry of the URL, which is used as fallback when all mirrors fail.
+	Mirrors []string
 
 	tlsConfig *tls.Config
 
@@ -63,6 +101,12 @@ type OCIChartRepository struct {
 	RegistryClient RegistryClient
 	// credentialsFile is a temporary credentials file to use while downloading tags or charts from a registry.
 	credentialsFile string
+
+	// loginOpts are the options to login with when the registry rejects an
+	// anonymous request as unauthorized.
+	loginOpts []registry.LoginOption
+	// loggedIn is set to 1 once a Login succeeded.
+	loggedIn int32
 }
 
 // OCIChartRepositoryOption is a function that can be passed to NewOCIChartRepository
@@ -105,10 +149,33 @@ func WithCredentialsFile(credentialsFile string) OCIChartRepositoryOption {
 	}
 }
 
+// WithOCILoginOptions returns a ChartRepositoryOption that will set the options
+// to login with when the registry rejects an anonymous request as
+// unauthorized. This allows pulling from public registries without
+// credentials, while only requiring them for private registries.
+func WithOCILoginOptions(opts ...registry.LoginOption) OCIChartRepositoryOption {
+	return func(r *OCIChartRepository) error {
+		r.loginOpts = opts
+		return nil
+	}
+}
+
+// WithOCIMirrors returns a ChartRepositoryOption that will set the hosts of
+// the registry mirrors, overriding the mirrors configured for the registry
+// in registry.DefaultMirrors.
+func WithOCIMirrors(mirrors ...string) OCIChartRepositoryOption {
+	return func(r *OCIChartRepository) error {
+		r.Mirrors = mirrors
+		return nil
+	}
+}
+
 // NewOCIChartRepository constructs and returns a new ChartRepository with
 // the ChartRepository.Client configured to the getter.Getter for the
 // repository URL scheme. It returns an error on URL parsing failures.
 // It assumes that the url scheme has been validated to be an OCI scheme.
+// The Mirrors default to the mirrors configured for the registry host in
+// registry.DefaultMirrors.
 func NewOCIChartRepository(repositoryURL string, chartRepoOpts ...OCIChartRepositoryOption) (*OCIChartRepository, error) {
 	u, err := url.Parse(repositoryURL)
 	if err != nil {
@@ -117,6 +184,7 @@ func NewOCIChartRepository(repositoryURL string, chartRepoOpts ...OCIChartReposi
 
 	r := &OCIChartRepository{}
 	r.URL = *u
+	r.Mirrors = sourceregistry.DefaultMirrors.Lookup(u.Host)
 	for _, opt := range chartRepoOpts {
 		if err := opt(r); err != nil {
 			return nil, err
@@ -137,7 +205,7 @@ func (r *OCIChartRepository) GetChartVersion(name, ver string) (*repo.ChartVersi
 	cpURL.Path = path.Join(cpURL.Path, name)
 	cvs, err := r.getTags(cpURL.String())
 	if err != nil {
-		return nil, fmt.Errorf("could not get tags for %q: %s", name, err)
+		return nil, fmt.Errorf("could not get tags for %q: %w", name, err)
 	}
 
 	if len(cvs) == 0 {
@@ -162,9 +230,13 @@ func (r *OCIChartRepository) GetChartVersion(name, ver string) (*repo.ChartVersi
 // It assumes that the ref has been validated to be an OCI reference.
 func (r *OCIChartRepository) getTags(ref string) ([]string, error) {
 	// Retrieve list of repository tags
-	tags, err := r.RegistryClient.Tags(strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme)))
+	var tags []string
+	err := r.withMirrorFallback(strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme)), func(ref string) (err error) {
+		tags, err = r.RegistryClient.Tags(ref)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch tags for %q: %s", ref, err)
+		return nil, fmt.Errorf("could not fetch tags for %q: %w", ref, err)
 	}
 	if len(tags) == 0 {
 		return nil, fmt.Errorf("unable to locate any tags in provided repository: %s", ref)
@@ -177,6 +249,9 @@ func (r *OCIChartRepository) getTags(ref string) ([]string, error) {
 // and then attempts to download the chart using the Client and Options of the
 // ChartRepository. It returns a bytes.Buffer containing the chart data.
 // In case of an OCI hosted chart, this function assumes that the chartVersion url is valid.
+// The chart is downloaded from the Mirrors with the same repository and tag or
+// digest as the chart URL, and is verified against the digest of the
+// repo.ChartVersion if set.
 func (r *OCIChartRepository) DownloadChart(chart *repo.ChartVersion) (*bytes.Buffer, error) {
 	if len(chart.URLs) == 0 {
 		return nil, fmt.Errorf("chart '%s' has no downloadable URLs", chart.Name)
@@ -194,7 +269,113 @@ func (r *OCIChartRepository) DownloadChart(chart *repo.ChartVersion) (*bytes.Buf
 	defer transport.Release(t)
 
 	// trim the oci scheme prefix if needed
-	return r.Client.Get(strings.TrimPrefix(u.String(), fmt.Sprintf("%s://", registry.OCIScheme)), clientOpts...)
+	var b *bytes.Buffer
+	err = r.withMirrorFallback(strings.TrimPrefix(u.String(), fmt.Sprintf("%s://", registry.OCIScheme)), func(ref string) (err error) {
+		b, err = r.Client.Get(ref, clientOpts...)
+		if r.isNotHelmChartErr(ref, err) {
+			return &NotHelmChartError{Ref: ref, Err: err}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyChartDigest(chart, b.Bytes()); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// withMirrorFallback calls fn with the given reference rewritten to each of
+// the Mirrors in order, until a call succeeds. If all calls fail, or no
+// Mirrors are configured, fn is called with the reference itself with
+// withLoginFallback. Only the registry host of the reference is rewritten,
+// the repository and tag or digest are retained.
+func (r *OCIChartRepository) withMirrorFallback(ref string, fn func(ref string) error) error {
+	host := r.URL.Host
+	if len(r.Mirrors) > 0 && host != "" && strings.HasPrefix(ref, host+"/") {
+		for _, mirror := range r.Mirrors {
+			if err := withRateLimitRetry(func() error {
+				return fn(mirror + strings.TrimPrefix(ref, host))
+			}); err == nil {
+				return nil
+			}
+		}
+	}
+	return r.withLoginFallback(func() error {
+		return fn(ref)
+	})
+}
+
+// withLoginFallback calls fn, and if the registry rejects the request as
+// unauthorized, attempts to login with the configured login options and
+// calls fn again. If no login options are configured, or the repository is
+// already logged in, an error wrapping ErrUnauthorized is returned.
+func (r *OCIChartRepository) withLoginFallback(fn func() error) error {
+	err := withRateLimitRetry(fn)
+	if err == nil || !isUnauthorizedErr(err) {
+		return err
+	}
+
+	if atomic.LoadInt32(&r.loggedIn) == 1 || len(r.loginOpts) == 0 {
+		return fmt.Errorf("%w: %s", ErrUnauthorized, err)
+	}
+	if err = r.Login(r.loginOpts...); err != nil {
+		return fmt.Errorf("failed to login to registry: %w", err)
+	}
+	return withRateLimitRetry(fn)
+}
+
+// withRateLimitRetry calls fn, and retries it up to
+// transport.RetryAfterMaxRetries times while the registry rejects the
+// request as rate limited. As the Helm registry client does not expose the
+// Retry-After header of the response, it waits for
+// transport.RetryAfterFallbackDelay before each retry.
+func withRateLimitRetry(fn func() error) error {
+	err := fn()
+	for retries := 0; err != nil && isTooManyRequestsErr(err) && retries < transport.RetryAfterMaxRetries; retries++ {
+		time.Sleep(transport.RetryAfterFallbackDelay)
+		err = fn()
+	}
+	return err
+}
+
+// isTooManyRequestsErr returns if the error returned by the registry
+// indicates the request was rejected due to rate limiting.
+func isTooManyRequestsErr(err error) bool {
+	return registryStatusCode(err) == http.StatusTooManyRequests
+}
+
+// isUnauthorizedErr returns if the error returned by the registry indicates
+// the request was rejected due to missing or invalid credentials.
+func isUnauthorizedErr(err error) bool {
+	return errors.Is(err, docker.ErrInvalidAuthorization) || registryStatusCode(err) == http.StatusUnauthorized
+}
+
+// unexpectedStatusRegexp matches the HTTP status of the untyped errors
+// returned by the containerd resolver and fetcher used by the Helm registry
+// client to pull charts, e.g. "failed with status code <url>: 429 Too Many
+// Requests".
+var unexpectedStatusRegexp = regexp.MustCompile(`status code \S+: (\d{3}) `)
+
+// registryStatusCode returns the HTTP status code of the registry response
+// the given error was returned for, or 0 if unknown.
+func registryStatusCode(err error) int {
+	var statusErr *sourceregistry.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	var unexpectedErr remoteerrors.ErrUnexpectedStatus
+	if errors.As(err, &unexpectedErr) {
+		return unexpectedErr.StatusCode
+	}
+	// The containerd resolver and fetcher do not return a typed error for
+	// the status of manifest and blob requests.
+	if m := unexpectedStatusRegexp.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code
+	}
+	return 0
 }
 
 // Login attempts to login to the OCI registry.
@@ -204,6 +385,7 @@ func (r *OCIChartRepository) Login(opts ...registry.LoginOption) error {
 	if err != nil {
 		return err
 	}
+	atomic.StoreInt32(&r.loggedIn, 1)
 	return nil
 }
 
@@ -281,3 +463,25 @@ func getLastMatchingVersionOrConstraint(cvs []string, ver string) (string, error
 
 	return matchingVersions[0].Original(), nil
 }
+
+// isNotHelmChartErr returns true if the given error was returned by the
+// registry client because the OCI artifact with the given reference does
+// not reference a config with registry.ConfigMediaType. The registry client
+// filters the descriptors of the manifest on the Helm media types, which
+// results in either a missing config or too few descriptors for non-chart
+// artifacts. As too few descriptors are also reported for Helm charts
+// without a chart layer, the config media type is then looked up.
+func (r *OCIChartRepository) isNotHelmChartErr(ref string, err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	if strings.Contains(msg, fmt.Sprintf("could not load config with mediatype %s", registry.ConfigMediaType)) {
+		return true
+	}
+	if !strings.Contains(msg, "manifest does not contain minimum number of descriptors") || r.RegistryClient == nil {
+		return false
+	}
+	mediaType, mErr := r.RegistryClient.ConfigMediaType(ref)
+	return mErr == nil && mediaType != registry.ConfigMediaType
+}
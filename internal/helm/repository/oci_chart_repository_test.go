@@ -18,17 +18,25 @@ package repository
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"path"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/containerd/containerd/remotes/docker"
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
 	. "github.com/onsi/gomega"
 	"helm.sh/helm/v3/pkg/chart"
 	helmgetter "helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/registry"
 	"helm.sh/helm/v3/pkg/repo"
+
+	sourceregistry "github.com/fluxcd/source-controller/internal/helm/registry"
+	"github.com/fluxcd/source-controller/internal/transport"
 )
 
 type OCIMockGetter struct {
@@ -43,8 +51,9 @@ func (g *OCIMockGetter) Get(u string, _ ...helmgetter.Option) (*bytes.Buffer, er
 }
 
 type mockRegistryClient struct {
-	tags          []string
-	LastCalledURL string
+	tags            []string
+	configMediaType string
+	LastCalledURL   string
 }
 
 func (m *mockRegistryClient) Tags(urlStr string) ([]string, error) {
@@ -52,6 +61,14 @@ func (m *mockRegistryClient) Tags(urlStr string) ([]string, error) {
 	return m.tags, nil
 }
 
+func (m *mockRegistryClient) ConfigMediaType(ref string) (string, error) {
+	m.LastCalledURL = ref
+	if m.configMediaType == "" {
+		return registry.ConfigMediaType, nil
+	}
+	return m.configMediaType, nil
+}
+
 func (m *mockRegistryClient) Login(url string, opts ...registry.LoginOption) error {
 	m.LastCalledURL = url
 	return nil
@@ -262,3 +279,451 @@ func TestOCIChartRepository_DownloadChart(t *testing.T) {
 		})
 	}
 }
+
+type errMockGetter struct {
+	err error
+}
+
+func (g *errMockGetter) Get(_ string, _ ...helmgetter.Option) (*bytes.Buffer, error) {
+	return nil, g.err
+}
+
+func TestOCIChartRepository_DownloadChart_notHelmChart(t *testing.T) {
+	chartVersion := &repo.ChartVersion{
+		Metadata: &chart.Metadata{Name: "chart"},
+		URLs:     []string{"oci://localhost:5000/my_repo/podinfo:1.0.0"},
+	}
+
+	tests := []struct {
+		name            string
+		getErr          error
+		configMediaType string
+		wantNotChart    bool
+		wantErrContain  string
+	}{
+		{
+			name:           "artifact with other config media type",
+			getErr:         fmt.Errorf("could not load config with mediatype %s", registry.ConfigMediaType),
+			wantNotChart:   true,
+			wantErrContain: "OCI artifact 'localhost:5000/my_repo/podinfo:1.0.0' is not a Helm chart",
+		},
+		{
+			name:            "artifact without Helm descriptors",
+			getErr:          errors.New("manifest does not contain minimum number of descriptors (2), descriptors found: 0"),
+			configMediaType: "application/vnd.cncf.flux.config.v1+json",
+			wantNotChart:    true,
+			wantErrContain:  registry.ConfigMediaType,
+		},
+		{
+			name:            "chart without chart layer",
+			getErr:          errors.New("manifest does not contain minimum number of descriptors (2), descriptors found: 1"),
+			configMediaType: registry.ConfigMediaType,
+			wantErrContain:  "manifest does not contain minimum number of descriptors",
+		},
+		{
+			name:           "other error",
+			getErr:         errors.New("connection refused"),
+			wantErrContain: "connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			u, err := url.Parse("oci://localhost:5000/my_repo")
+			g.Expect(err).ToNot(HaveOccurred())
+			registryClient := &mockRegistryClient{configMediaType: tt.configMediaType}
+			r := OCIChartRepository{
+				Client:         &errMockGetter{err: tt.getErr},
+				RegistryClient: registryClient,
+				URL:            *u,
+			}
+
+			res, err := r.DownloadChart(chartVersion)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(res).To(BeNil())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErrContain))
+
+			var notChartErr *NotHelmChartError
+			g.Expect(errors.As(err, &notChartErr)).To(Equal(tt.wantNotChart))
+			if tt.wantNotChart {
+				g.Expect(errors.Is(err, tt.getErr)).To(BeTrue())
+			}
+			if tt.configMediaType != "" {
+				g.Expect(registryClient.LastCalledURL).To(Equal("localhost:5000/my_repo/podinfo:1.0.0"))
+			}
+		})
+	}
+}
+
+type authRegistryClient struct {
+	tags        []string
+	requireAuth bool
+	loginErr    error
+	loginCalls  int
+	loggedIn    bool
+}
+
+func (m *authRegistryClient) Tags(_ string) ([]string, error) {
+	if m.requireAuth && !m.loggedIn {
+		return nil, &sourceregistry.StatusError{
+			StatusCode: http.StatusUnauthorized,
+			Err:        fmt.Errorf("GET https://localhost:5000/v2/my_repo/podinfo/tags/list: unexpected status code 401: unauthorized: authentication required"),
+		}
+	}
+	return m.tags, nil
+}
+
+func (m *authRegistryClient) ConfigMediaType(_ string) (string, error) {
+	return registry.ConfigMediaType, nil
+}
+
+func (m *authRegistryClient) Login(_ string, _ ...registry.LoginOption) error {
+	m.loginCalls++
+	if m.loginErr != nil {
+		return m.loginErr
+	}
+	m.loggedIn = true
+	return nil
+}
+
+func (m *authRegistryClient) Logout(_ string, _ ...registry.LogoutOption) error {
+	m.loggedIn = false
+	return nil
+}
+
+type authMockGetter struct {
+	client *authRegistryClient
+}
+
+func (g *authMockGetter) Get(_ string, _ ...helmgetter.Option) (*bytes.Buffer, error) {
+	if g.client.requireAuth && !g.client.loggedIn {
+		return nil, fmt.Errorf("pulling from host localhost:5000 failed with status code https://localhost:5000/v2/my_repo/podinfo/manifests/1.0.0: 401 Unauthorized")
+	}
+	return bytes.NewBufferString("chart"), nil
+}
+
+func TestOCIChartRepository_LoginFallback(t *testing.T) {
+	tests := []struct {
+		name           string
+		requireAuth    bool
+		loginOpts      []registry.LoginOption
+		loginErr       error
+		wantLoginCalls int
+		wantErr        string
+		wantUnauth     bool
+	}{
+		{
+			name: "public registry without credentials",
+		},
+		{
+			name:      "public registry does not login with credentials",
+			loginOpts: []registry.LoginOption{registry.LoginOptBasicAuth("user", "pass")},
+		},
+		{
+			name:           "private registry logs in with credentials",
+			requireAuth:    true,
+			loginOpts:      []registry.LoginOption{registry.LoginOptBasicAuth("user", "pass")},
+			wantLoginCalls: 1,
+		},
+		{
+			name:        "private registry without credentials",
+			requireAuth: true,
+			wantErr:     "registry requires authentication",
+			wantUnauth:  true,
+		},
+		{
+			name:           "private registry with failing login",
+			requireAuth:    true,
+			loginOpts:      []registry.LoginOption{registry.LoginOptBasicAuth("user", "wrong")},
+			loginErr:       fmt.Errorf("login failed"),
+			wantLoginCalls: 1,
+			wantErr:        "failed to login to registry: login failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			client := &authRegistryClient{
+				tags:        []string{"0.1.0", "1.0.0"},
+				requireAuth: tt.requireAuth,
+				loginErr:    tt.loginErr,
+			}
+			r, err := NewOCIChartRepository("oci://localhost:5000/my_repo",
+				WithOCIRegistryClient(client), WithOCILoginOptions(tt.loginOpts...))
+			g.Expect(err).ToNot(HaveOccurred())
+			r.Client = &authMockGetter{client: client}
+
+			cv, err := r.GetChartVersion("podinfo", "")
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(errors.Is(err, ErrUnauthorized)).To(Equal(tt.wantUnauth))
+				g.Expect(client.loginCalls).To(Equal(tt.wantLoginCalls))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cv.Metadata.Version).To(Equal("1.0.0"))
+
+			b, err := r.DownloadChart(cv)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(b.String()).To(Equal("chart"))
+			g.Expect(client.loginCalls).To(Equal(tt.wantLoginCalls))
+		})
+	}
+}
+
+type rateLimitedRegistryClient struct {
+	authRegistryClient
+	rateLimited int
+	calls       int
+}
+
+func (m *rateLimitedRegistryClient) Tags(url string) ([]string, error) {
+	m.calls++
+	if m.calls <= m.rateLimited {
+		return nil, &sourceregistry.StatusError{
+			StatusCode: http.StatusTooManyRequests,
+			Err:        fmt.Errorf("GET https://localhost:5000/v2/my_repo/podinfo/tags/list: unexpected status code 429: toomanyrequests: rate limit exceeded"),
+		}
+	}
+	return m.authRegistryClient.Tags(url)
+}
+
+func TestOCIChartRepository_RateLimitRetry(t *testing.T) {
+	delay := transport.RetryAfterFallbackDelay
+	transport.RetryAfterFallbackDelay = 10 * time.Millisecond
+	defer func() { transport.RetryAfterFallbackDelay = delay }()
+
+	tests := []struct {
+		name        string
+		rateLimited int
+		wantCalls   int
+		wantErr     string
+	}{
+		{
+			name:      "not rate limited",
+			wantCalls: 1,
+		},
+		{
+			name:        "retries rate limited request",
+			rateLimited: 2,
+			wantCalls:   3,
+		},
+		{
+			name:        "gives up after max retries",
+			rateLimited: transport.RetryAfterMaxRetries + 1,
+			wantCalls:   transport.RetryAfterMaxRetries + 1,
+			wantErr:     "429",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			client := &rateLimitedRegistryClient{
+				authRegistryClient: authRegistryClient{tags: []string{"0.1.0", "1.0.0"}},
+				rateLimited:        tt.rateLimited,
+			}
+			r, err := NewOCIChartRepository("oci://localhost:5000/my_repo", WithOCIRegistryClient(client))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			cv, err := r.GetChartVersion("podinfo", "")
+			g.Expect(client.calls).To(Equal(tt.wantCalls))
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cv.Metadata.Version).To(Equal("1.0.0"))
+		})
+	}
+}
+
+// mirrorGetter serves the given responses by host, and fails with a
+// connection error for any other host.
+type mirrorGetter struct {
+	responses map[string][]byte
+	calls     []string
+}
+
+func (g *mirrorGetter) Get(u string, _ ...helmgetter.Option) (*bytes.Buffer, error) {
+	g.calls = append(g.calls, u)
+	host := strings.SplitN(u, "/", 2)[0]
+	if b, ok := g.responses[host]; ok {
+		return bytes.NewBuffer(b), nil
+	}
+	return nil, fmt.Errorf("dial tcp: lookup %s: no such host", host)
+}
+
+type mirrorRegistryClient struct {
+	mockRegistryClient
+	hosts map[string]bool
+	calls []string
+}
+
+func (m *mirrorRegistryClient) Tags(u string) ([]string, error) {
+	m.calls = append(m.calls, u)
+	if host := strings.SplitN(u, "/", 2)[0]; !m.hosts[host] {
+		return nil, fmt.Errorf("dial tcp: lookup %s: no such host", host)
+	}
+	return []string{"1.0.0", "1.1.0"}, nil
+}
+
+func TestOCIChartRepository_mirrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		mirrors     []string
+		responses   map[string][]byte
+		digest      string
+		wantCalls   []string
+		wantErr     string
+		wantContent []byte
+	}{
+		{
+			name:      "mirror serves content with origin unreachable",
+			mirrors:   []string{"mirror.internal:5000"},
+			responses: map[string][]byte{"mirror.internal:5000": []byte("chart")},
+			digest:    "sha256:cc57fc1903e444cf6a726490b43b27ee9f87facc037f86872201847c565b45fb",
+			wantCalls: []string{
+				"mirror.internal:5000/charts/podinfo:1.1.0",
+			},
+			wantContent: []byte("chart"),
+		},
+		{
+			name:    "falls back to next mirror",
+			mirrors: []string{"unreachable.internal", "mirror.internal:5000"},
+			responses: map[string][]byte{
+				"mirror.internal:5000": []byte("chart"),
+			},
+			wantCalls: []string{
+				"unreachable.internal/charts/podinfo:1.1.0",
+				"mirror.internal:5000/charts/podinfo:1.1.0",
+			},
+			wantContent: []byte("chart"),
+		},
+		{
+			name:    "falls back to origin",
+			mirrors: []string{"unreachable.internal"},
+			responses: map[string][]byte{
+				"registry.example.com": []byte("chart"),
+			},
+			wantCalls: []string{
+				"unreachable.internal/charts/podinfo:1.1.0",
+				"registry.example.com/charts/podinfo:1.1.0",
+			},
+			wantContent: []byte("chart"),
+		},
+		{
+			name:    "digest is verified against content served by mirror",
+			mirrors: []string{"mirror.internal:5000"},
+			responses: map[string][]byte{
+				"mirror.internal:5000": []byte("tampered chart"),
+			},
+			digest: "sha256:cc57fc1903e444cf6a726490b43b27ee9f87facc037f86872201847c565b45fb",
+			wantCalls: []string{
+				"mirror.internal:5000/charts/podinfo:1.1.0",
+			},
+			wantErr: "chart digest mismatch",
+		},
+		{
+			name:    "all unreachable",
+			mirrors: []string{"unreachable.internal"},
+			wantCalls: []string{
+				"unreachable.internal/charts/podinfo:1.1.0",
+				"registry.example.com/charts/podinfo:1.1.0",
+			},
+			wantErr: "lookup registry.example.com: no such host",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			hosts := map[string]bool{}
+			for host := range tt.responses {
+				hosts[host] = true
+			}
+			rc := &mirrorRegistryClient{hosts: hosts}
+			mg := &mirrorGetter{responses: tt.responses}
+			r, err := NewOCIChartRepository("oci://registry.example.com/charts",
+				WithOCIRegistryClient(rc), WithOCIMirrors(tt.mirrors...))
+			g.Expect(err).ToNot(HaveOccurred())
+			r.Client = mg
+
+			cv, err := r.GetChartVersion("podinfo", "1.1.x")
+			if tt.wantErr != "" && len(tt.responses) == 0 {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cv.URLs).To(Equal([]string{"oci://registry.example.com/charts/podinfo:1.1.0"}))
+			var wantTagsCalls []string
+			for _, c := range tt.wantCalls {
+				wantTagsCalls = append(wantTagsCalls, strings.TrimSuffix(c, ":1.1.0"))
+			}
+			g.Expect(rc.calls).To(Equal(wantTagsCalls))
+
+			cv.Digest = tt.digest
+			b, err := r.DownloadChart(cv)
+			g.Expect(mg.calls).To(Equal(tt.wantCalls))
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(b.Bytes()).To(Equal(tt.wantContent))
+		})
+	}
+}
+
+func Test_registryStatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "registry client status error",
+			err:  fmt.Errorf("wrapped: %w", &sourceregistry.StatusError{StatusCode: http.StatusTooManyRequests, Err: errors.New("rate limited")}),
+			want: http.StatusTooManyRequests,
+		},
+		{
+			name: "containerd unexpected status error",
+			err:  fmt.Errorf("failed to fetch oauth token: %w", remoteerrors.ErrUnexpectedStatus{StatusCode: http.StatusUnauthorized}),
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "containerd resolver status",
+			err:  errors.New("pulling from host ghcr.io failed with status code https://ghcr.io/v2/charts/podinfo/manifests/6.1.0: 429 Too Many Requests"),
+			want: http.StatusTooManyRequests,
+		},
+		{
+			name: "containerd fetcher status",
+			err:  errors.New("unexpected status code https://ghcr.io/v2/charts/podinfo/blobs/sha256:abc: 401 Unauthorized"),
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "status code in reference",
+			err:  errors.New("failed to pull ghcr.io/charts/podinfo@sha256:401429abc: content not found"),
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(registryStatusCode(tt.err)).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_isUnauthorizedErr(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(isUnauthorizedErr(fmt.Errorf("pull access denied: %w", docker.ErrInvalidAuthorization))).To(BeTrue())
+	g.Expect(isUnauthorizedErr(errors.New("chart 'unauthorized-401' not found"))).To(BeFalse())
+}
@@ -18,8 +18,13 @@ package repository
 
 import (
 	"bytes"
+	"context"
+	"net/url"
 
+	"go.opentelemetry.io/otel/attribute"
 	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/fluxcd/source-controller/internal/tracing"
 )
 
 // Downloader is used to download a chart from a remote Helm repository or OCI Helm repository.
@@ -33,3 +38,23 @@ type Downloader interface {
 	// and calling garbage collector to remove unused files.
 	Clear() error
 }
+
+// DownloadChart downloads the given chart version using the Downloader
+// within a "helm.chart.pull" tracing span.
+func DownloadChart(ctx context.Context, d Downloader, chart *repo.ChartVersion) (_ *bytes.Buffer, err error) {
+	var attrs []attribute.KeyValue
+	if len(chart.URLs) > 0 {
+		if u, err := url.Parse(chart.URLs[0]); err == nil {
+			attrs = append(attrs, tracing.HostKey.String(u.Host), tracing.TransportKey.String(u.Scheme))
+		}
+	}
+	_, span := tracing.Start(ctx, "helm.chart.pull", attrs...)
+	defer func() { tracing.End(span, err) }()
+
+	b, err := d.DownloadChart(chart)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(tracing.BytesKey.Int(b.Len()))
+	return b, nil
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package limit provides size limits for fetched sources, protecting the
+// controller from exhausting memory or disk on runaway sources.
+package limit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLimits holds the process-wide Limits applied while fetching sources.
+// It does not impose any limits by default, and is expected to be configured
+// during startup.
+var DefaultLimits Limits
+
+// DefaultWatchInterval is the interval at which WatchDir checks a directory
+// against the Limits.
+var DefaultWatchInterval = 250 * time.Millisecond
+
+// Limits holds the maximum size of a fetched source.
+// A value of zero or less disables the respective limit.
+type Limits struct {
+	// MaxBytes is the maximum total size in bytes of all files.
+	MaxBytes int64
+	// MaxFiles is the maximum number of files.
+	MaxFiles int64
+}
+
+// Enabled returns if any of the limits is set.
+func (l Limits) Enabled() bool {
+	return l.MaxBytes > 0 || l.MaxFiles > 0
+}
+
+// ExceededError is returned when a fetched source exceeds one of the Limits.
+type ExceededError struct {
+	// Limit is the name of the exceeded limit, either "bytes" or "files".
+	Limit string
+	// Max is the configured maximum for the Limit.
+	Max int64
+}
+
+// Error returns the error string.
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("size limit exceeded: source has more than %d %s", e.Max, e.Limit)
+}
+
+// Counter accumulates the size of a source while it is being fetched, and
+// reports an ExceededError as soon as one of the Limits is crossed.
+// It is safe for concurrent use.
+type Counter struct {
+	limits Limits
+	bytes  int64
+	files  int64
+}
+
+// NewCounter returns a new Counter for the given Limits.
+func NewCounter(l Limits) *Counter {
+	return &Counter{limits: l}
+}
+
+// AddFile records a file of the given size. It returns an ExceededError if
+// the total number of files or bytes exceeds the Limits.
+func (c *Counter) AddFile(size int64) error {
+	if c == nil {
+		return nil
+	}
+	files := atomic.AddInt64(&c.files, 1)
+	if c.limits.MaxFiles > 0 && files > c.limits.MaxFiles {
+		return &ExceededError{Limit: "files", Max: c.limits.MaxFiles}
+	}
+	return c.AddBytes(size)
+}
+
+// AddBytes records the given number of bytes, without counting a file. It
+// returns an ExceededError if the total number of bytes exceeds the Limits.
+func (c *Counter) AddBytes(size int64) error {
+	if c == nil {
+		return nil
+	}
+	bytes := atomic.AddInt64(&c.bytes, size)
+	if c.limits.MaxBytes > 0 && bytes > c.limits.MaxBytes {
+		return &ExceededError{Limit: "bytes", Max: c.limits.MaxBytes}
+	}
+	return nil
+}
+
+// Bytes returns the total size of the recorded files.
+func (c *Counter) Bytes() int64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&c.bytes)
+}
+
+// CheckDir walks the given directory, and returns an ExceededError as soon
+// as the regular files in it exceed the Limits. Files in directories with a
+// name in skip, like the .git directory of a clone, are not counted toward
+// MaxFiles, but their size is counted toward MaxBytes to bound the fetched
+// data.
+func CheckDir(dir string, l Limits, skip ...string) error {
+	if !l.Enabled() {
+		return nil
+	}
+	c := NewCounter(l)
+	var skipped []string
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			for _, s := range skip {
+				if d.Name() == s {
+					skipped = append(skipped, p+string(filepath.Separator))
+					break
+				}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		for _, s := range skipped {
+			if strings.HasPrefix(p, s) {
+				return c.AddBytes(fi.Size())
+			}
+		}
+		return c.AddFile(fi.Size())
+	})
+}
+
+// WatchDir checks the given directory against the Limits at the given
+// interval, while a source is being fetched into it. The returned context is
+// canceled as soon as the Limits are exceeded, aborting the fetch. The
+// returned stop function ends the watch, and returns the ExceededError if
+// the Limits were exceeded. Directories with a name in skip are taken into
+// account like by CheckDir.
+//
+// The limits are enforced on a best-effort basis: as the directory is only
+// checked at the interval, up to the data written during one interval may
+// exceed the Limits before the fetch is aborted.
+func WatchDir(ctx context.Context, dir string, l Limits, interval time.Duration, skip ...string) (context.Context, func() error) {
+	if !l.Enabled() {
+		return ctx, func() error { return nil }
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	var exceeded error
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Other errors are ignored, as files may disappear while
+				// the directory is walked.
+				var exceededErr *ExceededError
+				if err := CheckDir(dir, l, skip...); errors.As(err, &exceededErr) {
+					exceeded = err
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() error {
+		once.Do(func() { close(done) })
+		<-stopped
+		cancel()
+		return exceeded
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package limit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCounter_AddFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		limits    Limits
+		sizes     []int64
+		wantLimit string
+	}{
+		{
+			name:   "within limits",
+			limits: Limits{MaxBytes: 10, MaxFiles: 2},
+			sizes:  []int64{5, 5},
+		},
+		{
+			name:      "exceeds bytes",
+			limits:    Limits{MaxBytes: 10},
+			sizes:     []int64{5, 6},
+			wantLimit: "bytes",
+		},
+		{
+			name:      "exceeds files",
+			limits:    Limits{MaxFiles: 2},
+			sizes:     []int64{1, 1, 1},
+			wantLimit: "files",
+		},
+		{
+			name:  "no limits",
+			sizes: []int64{1 << 40, 1 << 40},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := NewCounter(tt.limits)
+			var err error
+			for _, s := range tt.sizes {
+				if err = c.AddFile(s); err != nil {
+					break
+				}
+			}
+			if tt.wantLimit == "" {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			var exceededErr *ExceededError
+			g.Expect(errors.As(err, &exceededErr)).To(BeTrue())
+			g.Expect(exceededErr.Limit).To(Equal(tt.wantLimit))
+		})
+	}
+}
+
+func TestCheckDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"a", "b", ".git/objects/c"} {
+		p := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("12345"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		limits  Limits
+		skip    []string
+		wantErr bool
+	}{
+		{
+			name:   "within limits",
+			limits: Limits{MaxBytes: 15, MaxFiles: 3},
+		},
+		{
+			name:    "exceeds files",
+			limits:  Limits{MaxFiles: 2},
+			wantErr: true,
+		},
+		{
+			name:   "skips directory files",
+			limits: Limits{MaxBytes: 15, MaxFiles: 2},
+			skip:   []string{".git"},
+		},
+		{
+			name:    "counts bytes of skipped directory",
+			limits:  Limits{MaxBytes: 14, MaxFiles: 2},
+			skip:    []string{".git"},
+			wantErr: true,
+		},
+		{
+			name:    "exceeds bytes",
+			limits:  Limits{MaxBytes: 14},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := CheckDir(dir, tt.limits, tt.skip...)
+			if tt.wantErr {
+				var exceededErr *ExceededError
+				g.Expect(errors.As(err, &exceededErr)).To(BeTrue())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
+
+func TestWatchDir(t *testing.T) {
+	t.Run("cancels when exceeding limits", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		ctx, stop := WatchDir(context.TODO(), dir, Limits{MaxFiles: 2}, 10*time.Millisecond, ".git")
+		for i := 0; i < 3; i++ {
+			g.Expect(os.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%d", i)), nil, 0o600)).To(Succeed())
+		}
+
+		g.Eventually(ctx.Done(), time.Second).Should(BeClosed())
+		var exceededErr *ExceededError
+		g.Expect(errors.As(stop(), &exceededErr)).To(BeTrue())
+		g.Expect(exceededErr.Limit).To(Equal("files"))
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.MkdirAll(filepath.Join(dir, ".git"), 0o700)).To(Succeed())
+		for i := 0; i < 3; i++ {
+			g.Expect(os.WriteFile(filepath.Join(dir, ".git", fmt.Sprintf("file-%d", i)), nil, 0o600)).To(Succeed())
+		}
+
+		ctx, stop := WatchDir(context.TODO(), dir, Limits{MaxFiles: 2}, 10*time.Millisecond, ".git")
+		time.Sleep(50 * time.Millisecond)
+		g.Expect(ctx.Err()).ToNot(HaveOccurred())
+		g.Expect(stop()).To(Succeed())
+		g.Expect(ctx.Err()).To(HaveOccurred())
+	})
+
+	t.Run("cancels when skipped directory exceeds bytes", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.MkdirAll(filepath.Join(dir, ".git", "objects", "pack"), 0o700)).To(Succeed())
+		ctx, stop := WatchDir(context.TODO(), dir, Limits{MaxBytes: 10}, 10*time.Millisecond, ".git")
+		g.Expect(os.WriteFile(filepath.Join(dir, ".git", "objects", "pack", "pack-1.pack"), make([]byte, 11), 0o600)).To(Succeed())
+
+		g.Eventually(ctx.Done(), time.Second).Should(BeClosed())
+		var exceededErr *ExceededError
+		g.Expect(errors.As(stop(), &exceededErr)).To(BeTrue())
+		g.Expect(exceededErr.Limit).To(Equal("bytes"))
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ctx, stop := WatchDir(context.TODO(), t.TempDir(), Limits{}, 10*time.Millisecond)
+		g.Expect(stop()).To(Succeed())
+		g.Expect(ctx.Err()).ToNot(HaveOccurred())
+	})
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package s3
 
 import (
+	"bytes"
 	"crypto/md5"
 	"crypto/tls"
 	"fmt"
@@ -45,6 +46,14 @@ type Server struct {
 
 	BucketName string
 	Objects    []*Object
+	// TruncateResponse is called for every object request, and truncates
+	// the response body to half its length if it returns true, to simulate
+	// a connection failure.
+	TruncateResponse func(r *http.Request) bool
+	// AccessKey is the access key requests must be signed with, if set.
+	// Requests without a signature for the AccessKey are rejected as
+	// forbidden.
+	AccessKey string
 }
 
 func NewServer(bucketName string) *Server {
@@ -75,6 +84,16 @@ func (s *Server) HTTPAddress() string {
 }
 
 func (s *Server) handler(w http.ResponseWriter, r *http.Request) {
+	if s.AccessKey != "" && !strings.Contains(r.Header.Get("Authorization"), "Credential="+s.AccessKey+"/") {
+		w.Header().Add("Content-Type", "application/xml")
+		w.WriteHeader(403)
+		w.Write([]byte(`
+<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>
+		`))
+		return
+	}
+
 	key := path.Base(r.URL.Path)
 
 	switch key {
@@ -141,17 +160,22 @@ func (s *Server) handler(w http.ResponseWriter, r *http.Request) {
 		etag := md5.Sum(found.Content)
 		lastModified := strings.Replace(found.LastModified.UTC().Format(time.RFC1123), "UTC", "GMT", 1)
 
+		// ServeContent handles HEAD, Range and conditional requests, and
+		// sets the Content-Length.
 		w.Header().Add("Content-Type", found.ContentType)
 		w.Header().Add("Last-Modified", lastModified)
 		w.Header().Add("ETag", fmt.Sprintf("\"%x\"", etag))
-		w.Header().Add("Content-Length", fmt.Sprintf("%d", len(found.Content)))
-
-		if r.Method == http.MethodHead {
-			w.WriteHeader(200)
+		if s.TruncateResponse == nil || !s.TruncateResponse(r) {
+			http.ServeContent(w, r, key, found.LastModified, bytes.NewReader(found.Content))
 			return
 		}
 
-		w.WriteHeader(200)
-		w.Write(found.Content)
+		rec := httptest.NewRecorder()
+		http.ServeContent(rec, r, key, found.LastModified, bytes.NewReader(found.Content))
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes()[:rec.Body.Len()/2])
 	}
 }
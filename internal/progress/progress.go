@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package progress provides concurrency safe progress reporting for fetches
+// of sources consisting of many objects.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultInterval is the default minimum interval between two reports of a
+// Reporter.
+var DefaultInterval = 5 * time.Second
+
+// Progress is a snapshot of the progress of a fetch.
+type Progress struct {
+	// ObjectsDone is the number of objects which have been fetched.
+	ObjectsDone int
+	// ObjectsTotal is the total number of objects to fetch.
+	ObjectsTotal int
+	// BytesTransferred is the number of bytes transferred so far.
+	BytesTransferred int64
+}
+
+// Done returns if all objects have been fetched.
+func (p Progress) Done() bool {
+	return p.ObjectsDone >= p.ObjectsTotal
+}
+
+// Func is called with the Progress of a fetch.
+type Func func(Progress)
+
+// Reporter records the progress of a fetch, and reports it to a Func at most
+// once per interval. The final Progress, with all objects done, is always
+// reported. Calls to the Func are serialized, and observe monotonically
+// increasing Progress.
+// It is safe for concurrent use, and a nil Reporter ignores all calls.
+type Reporter struct {
+	mu       sync.Mutex
+	fn       Func
+	interval time.Duration
+	last     time.Time
+	progress Progress
+}
+
+// NewReporter returns a Reporter for a fetch of the given total number of
+// objects, which calls fn at most once per interval. It returns nil if fn is
+// nil.
+func NewReporter(total int, interval time.Duration, fn Func) *Reporter {
+	if fn == nil {
+		return nil
+	}
+	return &Reporter{
+		fn:       fn,
+		interval: interval,
+		last:     time.Now(),
+		progress: Progress{ObjectsTotal: total},
+	}
+}
+
+// Add records an object as done, with the given number of transferred bytes.
+// It reports the Progress if the interval since the last report has elapsed,
+// or if all objects are done.
+func (r *Reporter) Add(bytes int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.progress.ObjectsDone++
+	r.progress.BytesTransferred += bytes
+	if now := time.Now(); r.progress.Done() || now.Sub(r.last) >= r.interval {
+		r.last = now
+		r.fn(r.progress)
+	}
+}
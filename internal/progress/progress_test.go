@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package progress
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestReporter_Add(t *testing.T) {
+	g := NewWithT(t)
+
+	var reports []Progress
+	r := NewReporter(50, 0, func(p Progress) {
+		reports = append(reports, p)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Add(10)
+		}()
+	}
+	wg.Wait()
+
+	g.Expect(reports).To(HaveLen(50))
+	for i := 1; i < len(reports); i++ {
+		g.Expect(reports[i].ObjectsDone).To(BeNumerically(">", reports[i-1].ObjectsDone))
+		g.Expect(reports[i].BytesTransferred).To(BeNumerically(">=", reports[i-1].BytesTransferred))
+	}
+	g.Expect(reports[len(reports)-1]).To(Equal(Progress{ObjectsDone: 50, ObjectsTotal: 50, BytesTransferred: 500}))
+}
+
+func TestReporter_Add_throttled(t *testing.T) {
+	g := NewWithT(t)
+
+	var reports []Progress
+	r := NewReporter(3, time.Hour, func(p Progress) {
+		reports = append(reports, p)
+	})
+	r.Add(1)
+	r.Add(2)
+	g.Expect(reports).To(BeEmpty())
+
+	r.Add(3)
+	g.Expect(reports).To(Equal([]Progress{{ObjectsDone: 3, ObjectsTotal: 3, BytesTransferred: 6}}))
+}
+
+func TestReporter_nil(t *testing.T) {
+	g := NewWithT(t)
+
+	r := NewReporter(1, 0, nil)
+	g.Expect(r).To(BeNil())
+	r.Add(1)
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tar
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+var (
+	// DefaultMaxUntarSize is the default max total size in bytes of the
+	// regular files extracted by Untar.
+	DefaultMaxUntarSize int64 = 2 << 30
+	// DefaultMaxEntries is the default max number of entries of a tar file
+	// extracted by Untar.
+	DefaultMaxEntries = 100000
+	// DefaultMaxRatio is the default max ratio between the decompressed and
+	// the compressed size of a tar file extracted by Untar.
+	DefaultMaxRatio int64 = 200
+)
+
+// ratioThreshold is the decompressed size in bytes from which the
+// decompression ratio is enforced, as small or highly repetitive tar files
+// legitimately exceed a reasonable ratio.
+const ratioThreshold = 1 << 20
+
+// untarOptions are the options of Untar.
+type untarOptions struct {
+	maxSize    int64
+	maxEntries int
+	maxRatio   int64
+}
+
+// Option configures Untar and ReadFile.
+type Option func(o *untarOptions)
+
+// WithMaxUntarSize configures Untar to fail when the total size in bytes of
+// the extracted regular files exceeds the given size, instead of
+// DefaultMaxUntarSize. A size of zero or less disables the limit.
+func WithMaxUntarSize(size int64) Option {
+	return func(o *untarOptions) {
+		o.maxSize = size
+	}
+}
+
+// WithMaxEntries configures Untar to fail when the tar file contains more
+// than the given number of entries, instead of DefaultMaxEntries. A number
+// of zero or less disables the limit.
+func WithMaxEntries(n int) Option {
+	return func(o *untarOptions) {
+		o.maxEntries = n
+	}
+}
+
+// WithMaxRatio configures Untar to fail when the decompressed size of the
+// tar file exceeds the compressed size by more than the given ratio,
+// instead of DefaultMaxRatio. A ratio of zero or less disables the limit.
+func WithMaxRatio(ratio int64) Option {
+	return func(o *untarOptions) {
+		o.maxRatio = ratio
+	}
+}
+
+// Untar reads the gzip-compressed tar file from r and writes it into dir.
+// Regular files, directories and symbolic links which pass ValidateSymlink
+// are extracted, any other entry is skipped. Entries with an absolute path
+// or a path outside of dir result in an error. Entries are written to paths
+// securely joined with dir, so that they can not be written outside of it
+// through a previously extracted symbolic link.
+//
+// To protect against decompression bombs, extraction fails when the total
+// size of the regular files, the number of entries or the decompression
+// ratio exceed the limits configured by the given options.
+func Untar(r io.Reader, dir string, opts ...Option) error {
+	o := newUntarOptions(opts)
+	tr, err := newTarReader(r, o)
+	if err != nil {
+		return err
+	}
+
+	var entries int
+	var size int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("tar error: %w", err)
+		}
+		if entries++; o.maxEntries > 0 && entries > o.maxEntries {
+			return fmt.Errorf("tar contains more than %d entries", o.maxEntries)
+		}
+		if !validRelPath(header.Name) {
+			return fmt.Errorf("tar contained invalid name '%s'", header.Name)
+		}
+		name := path.Clean(header.Name)
+
+		parent, err := securejoin.SecureJoin(dir, path.Dir(name))
+		if err != nil {
+			return err
+		}
+		abs := filepath.Join(parent, path.Base(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(abs, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if size += header.Size; o.maxSize > 0 && size > o.maxSize {
+				return fmt.Errorf("tar size exceeds the max size of %d bytes", o.maxSize)
+			}
+			if err = os.MkdirAll(parent, 0o755); err != nil {
+				return err
+			}
+			if err = removeSymlink(abs); err != nil {
+				return err
+			}
+			if err = writeFile(abs, tr, header.FileInfo().Mode().Perm(), header.Size); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// Symlinks with a target outside of dir are skipped. The target
+			// is validated against the resolved location of the symlink, as
+			// the name may traverse symlinks created by earlier entries.
+			rel, err := filepath.Rel(dir, parent)
+			if err != nil {
+				return err
+			}
+			if ValidateSymlink(path.Join(filepath.ToSlash(rel), path.Base(name)), header.Linkname) != nil {
+				continue
+			}
+			if err = os.MkdirAll(parent, 0o755); err != nil {
+				return err
+			}
+			if err = removeSymlink(abs); err != nil {
+				return err
+			}
+			// The target is cleaned, so that it can not traverse a
+			// symlink before leaving its directory through "..".
+			if err = os.Symlink(filepath.FromSlash(path.Clean(header.Linkname)), abs); err != nil {
+				return err
+			}
+		default:
+			// Other entries, like hard links and device files, are skipped.
+		}
+	}
+}
+
+// ReadFile reads the gzip-compressed tar file from r, like an artifact or an
+// OCI artifact layer, and returns the content of the first regular file at
+// name, a slash separated path relative to the root of the archive. The tar
+// file is streamed, and no entry is extracted. Entries with an absolute path
+// or a path outside of the root result in an error, and so does a name
+// which is not a valid relative path. If there is no entry at name, the
+// returned error wraps os.ErrNotExist.
+//
+// The size of the file, the number of entries read and the decompression
+// ratio are limited like for Untar.
+func ReadFile(r io.Reader, name string, opts ...Option) ([]byte, error) {
+	if !validRelPath(name) {
+		return nil, fmt.Errorf("invalid name '%s'", name)
+	}
+	name = path.Clean(name)
+
+	o := newUntarOptions(opts)
+	tr, err := newTarReader(r, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries int
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("file '%s' not found in tar: %w", name, os.ErrNotExist)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tar error: %w", err)
+		}
+		if entries++; o.maxEntries > 0 && entries > o.maxEntries {
+			return nil, fmt.Errorf("tar contains more than %d entries", o.maxEntries)
+		}
+		if !validRelPath(header.Name) {
+			return nil, fmt.Errorf("tar contained invalid name '%s'", header.Name)
+		}
+		if path.Clean(header.Name) != name {
+			continue
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("'%s' is not a regular file", name)
+		}
+		if o.maxSize > 0 && header.Size > o.maxSize {
+			return nil, fmt.Errorf("tar size exceeds the max size of %d bytes", o.maxSize)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", name, err)
+		}
+		return b, nil
+	}
+}
+
+// newUntarOptions returns the untarOptions configured by the given options,
+// with the default limits.
+func newUntarOptions(opts []Option) *untarOptions {
+	o := &untarOptions{
+		maxSize:    DefaultMaxUntarSize,
+		maxEntries: DefaultMaxEntries,
+		maxRatio:   DefaultMaxRatio,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// newTarReader returns a tar.Reader for the gzip-compressed tar file read
+// from r, failing reads once the decompression ratio exceeds the max ratio
+// of the given options.
+func newTarReader(r io.Reader, o *untarOptions) (*tar.Reader, error) {
+	cr := &countingReader{r: r}
+	zr, err := gzip.NewReader(cr)
+	if err != nil {
+		return nil, fmt.Errorf("requires gzip-compressed body: %w", err)
+	}
+	return tar.NewReader(&ratioReader{r: zr, compressed: cr, maxRatio: o.maxRatio}), nil
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ratioReader reads the decompressed data from r, and fails once the
+// decompressed size exceeds ratioThreshold and the compressed size read
+// from compressed by more than maxRatio.
+type ratioReader struct {
+	r          io.Reader
+	compressed *countingReader
+	maxRatio   int64
+	n          int64
+}
+
+func (rr *ratioReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.n += int64(n)
+	if rr.maxRatio > 0 && rr.n > ratioThreshold && rr.n > rr.maxRatio*rr.compressed.n {
+		return n, fmt.Errorf("decompression ratio exceeds the max ratio of %d", rr.maxRatio)
+	}
+	return n, err
+}
+
+// ValidateSymlink returns an error if the target of the symbolic link at
+// name, a slash separated path relative to the root of an archive, is
+// absolute or points outside of the root.
+func ValidateSymlink(name, target string) error {
+	if target == "" {
+		return fmt.Errorf("symlink '%s' has an empty target", name)
+	}
+	if path.IsAbs(target) || filepath.IsAbs(target) || strings.Contains(target, `\`) {
+		return fmt.Errorf("symlink '%s' has an absolute target '%s'", name, target)
+	}
+	if resolved := path.Join(path.Dir(name), target); resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return fmt.Errorf("symlink '%s' has a target '%s' outside of the archive", name, target)
+	}
+	return nil
+}
+
+// removeSymlink removes the symbolic link at the given path, if any, so that
+// it is replaced instead of followed when writing to the path.
+func removeSymlink(p string) error {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(p)
+	}
+	return nil
+}
+
+func writeFile(p string, r io.Reader, perm os.FileMode, size int64) error {
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing to %s: %w", p, err)
+	}
+	if n != size {
+		return fmt.Errorf("only wrote %d bytes to %s; expected %d", n, p, size)
+	}
+	return nil
+}
+
+func validRelPath(p string) bool {
+	if p == "" || strings.Contains(p, `\`) || strings.HasPrefix(p, "/") || strings.Contains(p, "../") ||
+		p == ".." || strings.HasSuffix(p, "/..") {
+		return false
+	}
+	return true
+}
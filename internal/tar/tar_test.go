@@ -0,0 +1,363 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+type entry struct {
+	name     string
+	typeflag byte
+	linkname string
+	content  string
+}
+
+func createTarball(t *testing.T, entries []entry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		header := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     0o644,
+			Size:     int64(len(e.content)),
+		}
+		if e.typeflag == tar.TypeDir {
+			header.Mode = 0o755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestUntar(t *testing.T) {
+	tests := []struct {
+		name         string
+		entries      []entry
+		opts         []Option
+		wantFiles    map[string]string
+		wantSymlinks map[string]string
+		wantMissing  []string
+		wantErr      string
+	}{
+		{
+			name: "files, directories and relative symlinks",
+			entries: []entry{
+				{name: "dir", typeflag: tar.TypeDir},
+				{name: "dir/file", typeflag: tar.TypeReg, content: "file"},
+				{name: "link", typeflag: tar.TypeSymlink, linkname: "dir/file"},
+				{name: "dir/parent", typeflag: tar.TypeSymlink, linkname: "../link"},
+			},
+			wantFiles:    map[string]string{"dir/file": "file", "link": "file", "dir/parent": "file"},
+			wantSymlinks: map[string]string{"link": "dir/file", "dir/parent": "../link"},
+		},
+		{
+			name: "file written through symlink stays within dir",
+			entries: []entry{
+				{name: "sub", typeflag: tar.TypeDir},
+				{name: "link", typeflag: tar.TypeSymlink, linkname: "sub"},
+				{name: "link/file", typeflag: tar.TypeReg, content: "file"},
+			},
+			wantFiles: map[string]string{"sub/file": "file"},
+		},
+		{
+			name: "file replaces symlink",
+			entries: []entry{
+				{name: "file", typeflag: tar.TypeReg, content: "target"},
+				{name: "link", typeflag: tar.TypeSymlink, linkname: "file"},
+				{name: "link", typeflag: tar.TypeReg, content: "link"},
+			},
+			wantFiles: map[string]string{"file": "target", "link": "link"},
+		},
+		{
+			name: "absolute symlink is skipped",
+			entries: []entry{
+				{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+				{name: "file", typeflag: tar.TypeReg, content: "file"},
+			},
+			wantFiles:   map[string]string{"file": "file"},
+			wantMissing: []string{"link"},
+		},
+		{
+			name: "escaping symlink is skipped",
+			entries: []entry{
+				{name: "dir/link", typeflag: tar.TypeSymlink, linkname: "../../outside"},
+				{name: "dir/link/file", typeflag: tar.TypeReg, content: "file"},
+			},
+			wantFiles:   map[string]string{"dir/link/file": "file"},
+			wantMissing: []string{"outside"},
+		},
+		{
+			name: "escaping symlink through symlinked parent is skipped",
+			entries: []entry{
+				{name: "d", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "d/s", typeflag: tar.TypeSymlink, linkname: "../outside"},
+			},
+			wantSymlinks: map[string]string{"d": "."},
+			wantMissing:  []string{"s", "outside"},
+		},
+		{
+			name: "symlink target is cleaned",
+			entries: []entry{
+				{name: "x", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "a", typeflag: tar.TypeSymlink, linkname: "b/.."},
+				{name: "b", typeflag: tar.TypeSymlink, linkname: "x"},
+			},
+			wantSymlinks: map[string]string{"x": ".", "a": ".", "b": "x"},
+		},
+		{
+			name: "hard links and special files are skipped",
+			entries: []entry{
+				{name: "hardlink", typeflag: tar.TypeLink, linkname: "/etc/passwd"},
+				{name: "fifo", typeflag: tar.TypeFifo},
+				{name: "char", typeflag: tar.TypeChar},
+				{name: "block", typeflag: tar.TypeBlock},
+				{name: "file", typeflag: tar.TypeReg, content: "file"},
+			},
+			wantFiles:   map[string]string{"file": "file"},
+			wantMissing: []string{"hardlink", "fifo", "char", "block"},
+		},
+		{
+			name: "path traversal",
+			entries: []entry{
+				{name: "../file", typeflag: tar.TypeReg, content: "file"},
+			},
+			wantErr: "tar contained invalid name '../file'",
+		},
+		{
+			name: "nested path traversal",
+			entries: []entry{
+				{name: "dir/../../file", typeflag: tar.TypeReg, content: "file"},
+			},
+			wantErr: "tar contained invalid name 'dir/../../file'",
+		},
+		{
+			name: "absolute path",
+			entries: []entry{
+				{name: "/file", typeflag: tar.TypeReg, content: "file"},
+			},
+			wantErr: "tar contained invalid name '/file'",
+		},
+		{
+			name: "max size",
+			entries: []entry{
+				{name: "file1", typeflag: tar.TypeReg, content: "12345"},
+				{name: "file2", typeflag: tar.TypeReg, content: "123456"},
+			},
+			opts:    []Option{WithMaxUntarSize(10)},
+			wantErr: "tar size exceeds the max size of 10 bytes",
+		},
+		{
+			name: "max entries",
+			entries: []entry{
+				{name: "dir", typeflag: tar.TypeDir},
+				{name: "dir/file1", typeflag: tar.TypeReg},
+				{name: "dir/file2", typeflag: tar.TypeReg},
+			},
+			opts:    []Option{WithMaxEntries(2)},
+			wantErr: "tar contains more than 2 entries",
+		},
+		{
+			name: "decompression ratio",
+			entries: []entry{
+				{name: "file", typeflag: tar.TypeReg, content: strings.Repeat("0", 10<<20)},
+			},
+			wantErr: "decompression ratio exceeds the max ratio of 200",
+		},
+		{
+			name: "decompression ratio disabled",
+			entries: []entry{
+				{name: "file", typeflag: tar.TypeReg, content: strings.Repeat("0", 10<<20)},
+			},
+			opts:      []Option{WithMaxRatio(0)},
+			wantFiles: map[string]string{"file": strings.Repeat("0", 10<<20)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			root := t.TempDir()
+			dir := filepath.Join(root, "dir")
+			err := Untar(createTarball(t, tt.entries), dir, tt.opts...)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(filepath.Join(root, "file")).ToNot(BeAnExistingFile())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			for name, content := range tt.wantFiles {
+				g.Expect(os.ReadFile(filepath.Join(dir, name))).To(BeEquivalentTo(content))
+			}
+			for name, target := range tt.wantSymlinks {
+				g.Expect(os.Readlink(filepath.Join(dir, name))).To(Equal(target))
+			}
+			for _, name := range tt.wantMissing {
+				_, err := os.Lstat(filepath.Join(dir, name))
+				g.Expect(os.IsNotExist(err)).To(BeTrue(), "expected %s to not exist", name)
+				_, err = os.Lstat(filepath.Join(root, name))
+				g.Expect(os.IsNotExist(err)).To(BeTrue(), "expected %s to not exist", name)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	entries := []entry{
+		{name: "dir", typeflag: tar.TypeDir},
+		{name: "dir/file", typeflag: tar.TypeReg, content: "file"},
+		{name: "./manifest.yaml", typeflag: tar.TypeReg, content: "manifest"},
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "dir/file"},
+		{name: "dir/file", typeflag: tar.TypeReg, content: "duplicate"},
+	}
+
+	tests := []struct {
+		name         string
+		entries      []entry
+		file         string
+		opts         []Option
+		want         string
+		wantErr      string
+		wantNotExist bool
+	}{
+		{
+			name:    "file in directory",
+			entries: entries,
+			file:    "dir/file",
+			want:    "file",
+		},
+		{
+			name:    "file with cleaned path",
+			entries: entries,
+			file:    "manifest.yaml",
+			want:    "manifest",
+		},
+		{
+			name:         "missing file",
+			entries:      entries,
+			file:         "dir/missing",
+			wantErr:      "file 'dir/missing' not found in tar",
+			wantNotExist: true,
+		},
+		{
+			name:    "symlink",
+			entries: entries,
+			file:    "link",
+			wantErr: "'link' is not a regular file",
+		},
+		{
+			name:    "path traversal in name",
+			entries: entries,
+			file:    "../file",
+			wantErr: "invalid name '../file'",
+		},
+		{
+			name: "path traversal in tar",
+			entries: []entry{
+				{name: "dir/../../file", typeflag: tar.TypeReg, content: "file"},
+			},
+			file:    "file",
+			wantErr: "tar contained invalid name 'dir/../../file'",
+		},
+		{
+			name:    "max size",
+			entries: entries,
+			file:    "manifest.yaml",
+			opts:    []Option{WithMaxUntarSize(4)},
+			wantErr: "tar size exceeds the max size of 4 bytes",
+		},
+		{
+			name:    "max entries",
+			entries: entries,
+			file:    "link",
+			opts:    []Option{WithMaxEntries(2)},
+			wantErr: "tar contains more than 2 entries",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := ReadFile(createTarball(t, tt.entries), tt.file, tt.opts...)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(errors.Is(err, os.ErrNotExist)).To(Equal(tt.wantNotExist))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(got)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestValidateSymlink(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		wantErr bool
+	}{
+		{name: "link", target: "file"},
+		{name: "dir/link", target: "../file"},
+		{name: "dir/sub/link", target: "../../dir/./file"},
+		{name: "link", target: "", wantErr: true},
+		{name: "link", target: "/file", wantErr: true},
+		{name: "link", target: "../file", wantErr: true},
+		{name: "dir/link", target: "../../file", wantErr: true},
+		{name: "dir/link", target: "sub/../../..", wantErr: true},
+		{name: "link", target: `..\file`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"->"+tt.target, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := ValidateSymlink(tt.name, tt.target)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
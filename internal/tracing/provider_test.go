@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestEnabled(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	g.Expect(Enabled()).To(BeFalse())
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/v1/traces")
+	g.Expect(Enabled()).To(BeTrue())
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	var exported int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/traces" {
+			atomic.AddInt32(&exported, 1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+
+	tp, err := NewTracerProvider(context.TODO(), "source-controller", "v0.0.0")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, span := tp.Tracer(TracerName).Start(context.TODO(), "test")
+	End(span, nil)
+	g.Expect(tp.Shutdown(context.TODO())).To(Succeed())
+	g.Expect(atomic.LoadInt32(&exported)).To(BeEquivalentTo(1))
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing emits OpenTelemetry spans around the operations fetching
+// and verifying sources. Spans are recorded by the global TracerProvider,
+// which is a no-op unless one is configured.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer the spans are created with.
+const TracerName = "github.com/fluxcd/source-controller"
+
+// Attribute keys of the spans.
+const (
+	// HostKey is the host name of the URL the operation is performed
+	// against.
+	HostKey = attribute.Key("source.host")
+	// TransportKey is the transport, or provider, used for the operation.
+	TransportKey = attribute.Key("source.transport")
+	// BytesKey is the number of bytes fetched by the operation.
+	BytesKey = attribute.Key("source.bytes")
+	// OutcomeKey is the outcome of the operation, see Outcome.
+	OutcomeKey = attribute.Key("source.outcome")
+)
+
+// Outcome values of the OutcomeKey attribute.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// Start starts a span with the given name and attributes as a child of the
+// span in the context, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the outcome of the operation of the given span, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.SetAttributes(OutcomeKey.String(OutcomeError))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(OutcomeKey.String(OutcomeSuccess))
+	}
+	span.End()
+}
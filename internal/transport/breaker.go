@@ -0,0 +1,265 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/utils/clock"
+)
+
+// DefaultCircuitBreaker is the CircuitBreaker guarding the requests of the
+// pooled transports. If nil, no circuit breaker is used.
+var DefaultCircuitBreaker *CircuitBreaker
+
+// CircuitState is the state of the circuit of a host.
+type CircuitState int
+
+const (
+	// CircuitClosed allows all requests to the host.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests to the host until the cooldown has
+	// passed.
+	CircuitOpen
+	// CircuitHalfOpen allows a single probe request to the host, of which
+	// the outcome closes or reopens the circuit.
+	CircuitHalfOpen
+)
+
+// String returns the name of the CircuitState.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitOpenError is returned for requests to a host of which the circuit
+// is open, or of which the half-open circuit is already being probed.
+type CircuitOpenError struct {
+	Host string
+	// Until is the time at which the circuit becomes half-open, or zero
+	// while it is being probed.
+	Until time.Time
+}
+
+// Error returns the error string.
+func (e *CircuitOpenError) Error() string {
+	if e.Until.IsZero() {
+		return fmt.Sprintf("circuit breaker for host '%s' is open", e.Host)
+	}
+	return fmt.Sprintf("circuit breaker for host '%s' is open until %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+// circuit is the state of a single host.
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// CircuitBreaker tracks the consecutive failures of requests per host.
+// After Threshold consecutive failures, the circuit of the host opens and
+// requests are rejected with a CircuitOpenError for the Cooldown. After
+// the Cooldown, the circuit is half-open, and a single request is allowed
+// to probe the host. A successful probe closes the circuit, a failed one
+// opens it again.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive failures after which the
+	// circuit opens.
+	Threshold int
+	// Cooldown is the duration the circuit stays open before a probe
+	// request is allowed.
+	Cooldown time.Duration
+
+	// Clock is used to determine the end of the Cooldown.
+	Clock clock.PassiveClock
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewCircuitBreaker returns a CircuitBreaker opening the circuit of a host
+// after threshold consecutive failures, for the cooldown duration.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		Clock:     clock.RealClock{},
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// Allow returns a CircuitOpenError if a request to the host is not
+// allowed. Allowed requests must report their outcome using Success or
+// Failure.
+func (b *CircuitBreaker) Allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[host]
+	if !ok {
+		return nil
+	}
+	b.update(c)
+	switch c.state {
+	case CircuitOpen:
+		return &CircuitOpenError{Host: host, Until: c.openedAt.Add(b.Cooldown)}
+	case CircuitHalfOpen:
+		if c.probing {
+			return &CircuitOpenError{Host: host}
+		}
+		c.probing = true
+	}
+	return nil
+}
+
+// Success records a successful request to the host, which closes its
+// circuit.
+func (b *CircuitBreaker) Success(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.circuits, host)
+}
+
+// Failure records a failed request to the host. The circuit opens when
+// the Threshold of consecutive failures is reached, or when the request
+// was the probe of a half-open circuit.
+func (b *CircuitBreaker) Failure(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{}
+		b.circuits[host] = c
+	}
+	b.update(c)
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= b.Threshold {
+		c.state = CircuitOpen
+		c.openedAt = b.Clock.Now()
+		c.probing = false
+	}
+}
+
+// State returns the CircuitState of the host.
+func (b *CircuitBreaker) State(host string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[host]
+	if !ok {
+		return CircuitClosed
+	}
+	b.update(c)
+	return c.state
+}
+
+// States returns the CircuitState of all hosts with failures. Hosts which
+// are not included are closed.
+func (b *CircuitBreaker) States() map[string]CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	states := make(map[string]CircuitState, len(b.circuits))
+	for host, c := range b.circuits {
+		b.update(c)
+		states[host] = c.state
+	}
+	return states
+}
+
+// update transitions an open circuit of which the cooldown has passed to
+// half-open.
+func (b *CircuitBreaker) update(c *circuit) {
+	if c.state == CircuitOpen && !b.Clock.Now().Before(c.openedAt.Add(b.Cooldown)) {
+		c.state = CircuitHalfOpen
+		c.probing = false
+	}
+}
+
+// roundTrip performs the request using the given http.RoundTripper, if
+// allowed by the CircuitBreaker. Transport errors and 5xx responses are
+// recorded as failures, unless the request context is done.
+func (b *CircuitBreaker) roundTrip(rt http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if b == nil {
+		return rt.RoundTrip(req)
+	}
+
+	host := req.URL.Host
+	if err := b.Allow(host); err != nil {
+		return nil, err
+	}
+	resp, err := rt.RoundTrip(req)
+	switch {
+	case req.Context().Err() != nil:
+		// The outcome says nothing about the host, release the probe.
+		b.mu.Lock()
+		if c, ok := b.circuits[host]; ok {
+			c.probing = false
+		}
+		b.mu.Unlock()
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		b.Failure(host)
+	default:
+		b.Success(host)
+	}
+	return resp, err
+}
+
+// circuitStateDesc describes the circuit breaker state metric.
+var circuitStateDesc = prometheus.NewDesc(
+	"gotk_transport_circuit_breaker_state",
+	"The state of the circuit breaker of a host, 0 is closed, 1 is open, 2 is half-open. Closed circuits without failures are not reported.",
+	[]string{"host"}, nil,
+)
+
+// circuitBreakerCollector is a prometheus.Collector reporting the
+// CircuitState of the hosts of a CircuitBreaker.
+type circuitBreakerCollector struct {
+	breaker *CircuitBreaker
+}
+
+func (c circuitBreakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- circuitStateDesc
+}
+
+func (c circuitBreakerCollector) Collect(ch chan<- prometheus.Metric) {
+	for host, state := range c.breaker.States() {
+		ch <- prometheus.MustNewConstMetric(circuitStateDesc, prometheus.GaugeValue, float64(state), host)
+	}
+}
+
+// Collectors returns the metrics.Collector objects for the CircuitBreaker.
+func (b *CircuitBreaker) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		circuitBreakerCollector{breaker: b},
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func Test_CircuitBreaker(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clocktesting.NewFakeClock(now)
+	b := NewCircuitBreaker(3, time.Minute)
+	b.Clock = fakeClock
+
+	const host = "example.com"
+	expectState := func(want CircuitState) {
+		t.Helper()
+		if got := b.State(host); got != want {
+			t.Fatalf("got state %s, want %s", got, want)
+		}
+	}
+	expectAllowed := func(want bool) {
+		t.Helper()
+		err := b.Allow(host)
+		if want && err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var openErr *CircuitOpenError
+		if !want && !errors.As(err, &openErr) {
+			t.Fatalf("got error %v, want CircuitOpenError", err)
+		}
+	}
+
+	// Failures below the threshold keep the circuit closed, and are reset
+	// by a success.
+	for i := 0; i < 2; i++ {
+		expectAllowed(true)
+		b.Failure(host)
+	}
+	expectState(CircuitClosed)
+	expectAllowed(true)
+	b.Success(host)
+	for i := 0; i < 2; i++ {
+		expectAllowed(true)
+		b.Failure(host)
+	}
+	expectState(CircuitClosed)
+
+	// Reaching the threshold opens the circuit.
+	expectAllowed(true)
+	b.Failure(host)
+	expectState(CircuitOpen)
+	expectAllowed(false)
+	if got := b.Allow(host).(*CircuitOpenError).Until; !got.Equal(now.Add(time.Minute)) {
+		t.Errorf("got open until %s, want %s", got, now.Add(time.Minute))
+	}
+	if err := b.Allow("other.example.com"); err != nil {
+		t.Errorf("unexpected error for other host: %v", err)
+	}
+
+	// After the cooldown, a single probe is allowed, of which the failure
+	// opens the circuit again.
+	fakeClock.Step(time.Minute)
+	expectState(CircuitHalfOpen)
+	expectAllowed(true)
+	expectAllowed(false)
+	b.Failure(host)
+	expectState(CircuitOpen)
+	fakeClock.Step(time.Minute - time.Second)
+	expectAllowed(false)
+
+	// A successful probe closes the circuit.
+	fakeClock.Step(time.Second)
+	expectAllowed(true)
+	b.Success(host)
+	expectState(CircuitClosed)
+	expectAllowed(true)
+	expectAllowed(true)
+
+	if states := b.States(); len(states) != 0 {
+		t.Errorf("got states %v, want none", states)
+	}
+}
+
+func Test_CircuitBreaker_transport(t *testing.T) {
+	var (
+		requests int32
+		healthy  int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("index"))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	b := NewCircuitBreaker(2, time.Minute)
+	b.Clock = fakeClock
+	DefaultCircuitBreaker = b
+	defer func() { DefaultCircuitBreaker = nil }()
+
+	tr := NewOrIdle(nil)
+	defer Release(tr)
+	client := &http.Client{Transport: tr}
+
+	get := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	// Trip the breaker.
+	for i := 0; i < 2; i++ {
+		resp, err := get()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+		}
+	}
+	if got := b.State(u.Host); got != CircuitOpen {
+		t.Fatalf("got state %s, want %s", got, CircuitOpen)
+	}
+
+	// Requests are rejected without reaching the server.
+	_, err = get()
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("got error %v, want CircuitOpenError", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+
+	// After the cooldown, the recovered server is probed and the circuit
+	// closes.
+	atomic.StoreInt32(&healthy, 1)
+	fakeClock.Step(time.Minute)
+	resp, err := get()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := b.State(u.Host); got != CircuitClosed {
+		t.Errorf("got state %s, want %s", got, CircuitClosed)
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+var (
+	// RetryMax is the default maximum number of retries of a request which
+	// failed with a transient error, used by NewClient.
+	RetryMax = 3
+	// RetryWaitMin is the default duration to wait before the first retry
+	// of a request which failed with a transient error, used by NewClient.
+	// The wait doubles with every retry.
+	RetryWaitMin = 1 * time.Second
+	// RetryWaitMax is the default maximum duration to wait before a retry
+	// of a request which failed with a transient error, used by NewClient.
+	RetryWaitMax = 30 * time.Second
+)
+
+// ClientOptions configures the http.Client returned by NewClient.
+type ClientOptions struct {
+	// TLSConfig is the TLS configuration of the transport.
+	TLSConfig *tls.Config
+
+	// Proxy returns the proxy to use for a request, defaults to
+	// http.ProxyFromEnvironment.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// Timeout is the time limit for a request of the client, including
+	// retries and reading the response body. Zero means no timeout.
+	Timeout time.Duration
+
+	// UserAgent is set as the User-Agent of requests which do not have one.
+	UserAgent string
+
+	// MaxResponseSize limits the size of the response bodies, zero or less
+	// disables the limit.
+	MaxResponseSize int64
+
+	// RetryMax is the maximum number of retries of a request which failed
+	// with a transient error, defaults to RetryMax. A negative value
+	// disables retries.
+	RetryMax int
+
+	// RetryWaitMin and RetryWaitMax bound the duration to wait before a
+	// retry, and default to RetryWaitMin and RetryWaitMax.
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+}
+
+// NewClient returns an http.Client configured with the given ClientOptions,
+// which sends its requests using a transport from the TransportPool.
+// Requests with an idempotent method are retried with an exponential
+// backoff when they fail with a connection error or a 5xx status, on top
+// of the retries of rate limited requests and the DefaultRedirectPolicy
+// enforced by the pooled transports.
+//
+// The returned release func returns the transport to the pool, and must be
+// called once the client is no longer used.
+func NewClient(opts ClientOptions) (*http.Client, func()) {
+	t := NewOrIdle(opts.TLSConfig)
+	if opts.Proxy != nil {
+		t.Proxy = opts.Proxy
+	}
+
+	retry := &retryRoundTripper{
+		next:    t,
+		max:     opts.RetryMax,
+		waitMin: opts.RetryWaitMin,
+		waitMax: opts.RetryWaitMax,
+		clock:   clock.RealClock{},
+	}
+	if retry.max == 0 {
+		retry.max = RetryMax
+	}
+	if retry.waitMin <= 0 {
+		retry.waitMin = RetryWaitMin
+	}
+	if retry.waitMax <= 0 {
+		retry.waitMax = RetryWaitMax
+	}
+
+	var rt http.RoundTripper = retry
+	if opts.MaxResponseSize > 0 {
+		rt = LimitResponses(opts.MaxResponseSize, nil)(rt)
+	}
+	if opts.UserAgent != "" {
+		rt = &userAgentRoundTripper{next: rt, userAgent: opts.UserAgent}
+	}
+
+	client := &http.Client{
+		Transport:     rt,
+		Timeout:       opts.Timeout,
+		CheckRedirect: DefaultRedirectPolicy.CheckRedirect,
+	}
+	return client, func() { _ = Release(t) }
+}
+
+// userAgentRoundTripper sets the User-Agent of requests which do not have
+// one.
+type userAgentRoundTripper struct {
+	next      http.RoundTripper
+	userAgent string
+}
+
+func (rt *userAgentRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", rt.userAgent)
+	}
+	return rt.next.RoundTrip(req)
+}
+
+// retryRoundTripper retries requests with an idempotent method which failed
+// with a transient error, waiting for an exponential backoff between
+// waitMin and waitMax before each retry. The wait is bounded by the
+// deadline of the request context.
+type retryRoundTripper struct {
+	next    http.RoundTripper
+	max     int
+	waitMin time.Duration
+	waitMax time.Duration
+	clock   clock.Clock
+}
+
+func (rt *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	wait := rt.waitMin
+	for retries := 0; ; retries++ {
+		r := req
+		if retries > 0 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+
+		resp, err := rt.next.RoundTrip(r)
+		if retries >= rt.max || !isIdempotent(req) || !isReplayable(req) || !isTransient(resp, err) {
+			return resp, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(rt.clock.Now()) < wait {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Ensure the body is fully processed and closed, for increased
+			// likelihood of connection reuse.
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		timer := rt.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C():
+		}
+		if wait *= 2; wait > rt.waitMax {
+			wait = rt.waitMax
+		}
+	}
+}
+
+// isIdempotent returns if the method of the request is idempotent, and can
+// safely be retried.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// isTransient returns if the given response or error of a request indicates
+// a transient failure, which may succeed when retried.
+func isTransient(resp *http.Response, err error) bool {
+	if err == nil {
+		switch resp.StatusCode {
+		case http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var (
+		circuitErr     *CircuitOpenError
+		redirectErr    *RedirectPolicyError
+		redirectsErr   *TooManyRedirectsError
+		tooLargeErr    *ResponseTooLargeError
+		dnsErr         *net.DNSError
+		unknownAuthErr x509.UnknownAuthorityError
+		hostnameErr    x509.HostnameError
+		certErr        x509.CertificateInvalidError
+		recordErr      tls.RecordHeaderError
+	)
+	switch {
+	case errors.As(err, &circuitErr), errors.As(err, &redirectErr),
+		errors.As(err, &redirectsErr), errors.As(err, &tooLargeErr):
+		return false
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return false
+	case errors.As(err, &unknownAuthErr), errors.As(err, &hostnameErr),
+		errors.As(err, &certErr), errors.As(err, &recordErr):
+		// TLS verification failures do not resolve themselves.
+		return false
+	}
+	return true
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_NewClient_retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		failures     int32
+		status       int
+		retryMax     int
+		wantStatus   int
+		wantRequests int32
+	}{
+		{
+			name:         "retries 5xx",
+			method:       http.MethodGet,
+			failures:     2,
+			status:       http.StatusServiceUnavailable,
+			wantStatus:   http.StatusOK,
+			wantRequests: 3,
+		},
+		{
+			name:         "exceeding max retries",
+			method:       http.MethodGet,
+			failures:     5,
+			status:       http.StatusBadGateway,
+			retryMax:     2,
+			wantStatus:   http.StatusBadGateway,
+			wantRequests: 3,
+		},
+		{
+			name:         "retries disabled",
+			method:       http.MethodGet,
+			failures:     1,
+			status:       http.StatusServiceUnavailable,
+			retryMax:     -1,
+			wantStatus:   http.StatusServiceUnavailable,
+			wantRequests: 1,
+		},
+		{
+			name:         "does not retry 4xx",
+			method:       http.MethodGet,
+			failures:     1,
+			status:       http.StatusNotFound,
+			wantStatus:   http.StatusNotFound,
+			wantRequests: 1,
+		},
+		{
+			name:         "does not retry non-idempotent method",
+			method:       http.MethodPost,
+			failures:     1,
+			status:       http.StatusServiceUnavailable,
+			wantStatus:   http.StatusServiceUnavailable,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				_, _ = w.Write([]byte("index"))
+			}))
+			defer server.Close()
+
+			client, release := NewClient(ClientOptions{
+				RetryMax:     tt.retryMax,
+				RetryWaitMin: time.Millisecond,
+				RetryWaitMax: 5 * time.Millisecond,
+			})
+			defer release()
+
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader("body"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := atomic.LoadInt32(&requests); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func Test_NewClient_retryConnectionError(t *testing.T) {
+	var requests int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// Drop the connection without a response.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		_, _ = w.Write([]byte("index"))
+	}))
+	server.Start()
+	defer server.Close()
+
+	client, release := NewClient(ClientOptions{RetryWaitMin: time.Millisecond})
+	defer release()
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func Test_NewClient_TLS(t *testing.T) {
+	// newServer returns a TLS server and a counter of the connections made
+	// to it. Every test uses its own server, as the pooled transports keep
+	// their idle connections.
+	newServer := func(t *testing.T) (*httptest.Server, *int32) {
+		var conns int32
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("index"))
+		}))
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt32(&conns, 1)
+			}
+		}
+		// Silence the TLS handshake errors of untrusted clients.
+		server.Config.ErrorLog = log.New(io.Discard, "", 0)
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server, &conns
+	}
+
+	t.Run("trusted CA", func(t *testing.T) {
+		server, _ := newServer(t)
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+
+		client, release := NewClient(ClientOptions{
+			TLSConfig:    &tls.Config{RootCAs: pool},
+			RetryWaitMin: time.Millisecond,
+		})
+		defer release()
+
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+
+	t.Run("untrusted CA is not retried", func(t *testing.T) {
+		server, conns := newServer(t)
+
+		client, release := NewClient(ClientOptions{RetryWaitMin: time.Millisecond})
+		defer release()
+
+		_, err := client.Get(server.URL)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		var unknownAuthErr x509.UnknownAuthorityError
+		if !errors.As(err, &unknownAuthErr) {
+			t.Errorf("got error %v, want x509.UnknownAuthorityError", err)
+		}
+		if got := atomic.LoadInt32(conns); got != 1 {
+			t.Errorf("got %d connections, want 1", got)
+		}
+	})
+}
+
+func Test_NewClient_options(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		_, _ = w.Write([]byte(strings.Repeat("x", 2048)))
+	}))
+	defer server.Close()
+
+	client, release := NewClient(ClientOptions{
+		UserAgent:       "source-controller/v0.25.0",
+		MaxResponseSize: 1024,
+	})
+	defer release()
+
+	_, err := client.Get(server.URL)
+	var tooLargeErr *ResponseTooLargeError
+	if !errors.As(err, &tooLargeErr) {
+		t.Fatalf("got error %v, want ResponseTooLargeError", err)
+	}
+	if userAgent != "source-controller/v0.25.0" {
+		t.Errorf("got User-Agent %q, want %q", userAgent, "source-controller/v0.25.0")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "custom")
+	resp, err := client.Do(req)
+	if err == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if userAgent != "custom" {
+		t.Errorf("got User-Agent %q, want %q", userAgent, "custom")
+	}
+}
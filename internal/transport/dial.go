@@ -0,0 +1,277 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+var (
+	// DefaultResolver is the Resolver used by the Dialer of the pooled
+	// transports to look up the addresses of hosts.
+	DefaultResolver Resolver = net.DefaultResolver
+	// DefaultFallbackDelay is the duration to wait for a connection to an
+	// address of the primary address family to succeed, before racing a
+	// connection to an address of the other family.
+	DefaultFallbackDelay = 300 * time.Millisecond
+)
+
+// Resolver looks up the IP addresses of a host. It is implemented by
+// net.Resolver.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Dialer connects to the addresses of a host using the "Happy Eyeballs"
+// algorithm (RFC 8305). The resolved addresses are partitioned by address
+// family, of which the family of the first address is the primary one.
+// When a connection to the primary addresses does not succeed within the
+// FallbackDelay, connections to the addresses of the other family are
+// attempted in parallel, and the first established connection is returned.
+//
+// Contrary to net.Dialer, it allows the addresses to be looked up by a
+// custom Resolver.
+type Dialer struct {
+	// Resolver looks up the addresses of the host. When nil, the
+	// DefaultResolver is used.
+	Resolver Resolver
+	// FallbackDelay is the duration to wait before attempting the
+	// fallback addresses. When zero, the DefaultFallbackDelay is used,
+	// when negative, the fallback is disabled.
+	FallbackDelay time.Duration
+	// Timeout is the maximum duration for a dial to complete, including
+	// the address lookup.
+	Timeout time.Duration
+	// KeepAlive is the keep-alive period of the established connection.
+	KeepAlive time.Duration
+
+	// dial connects to a single IP address, for testing purposes.
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// dialResult is the outcome of a connection attempt to a group of
+// addresses.
+type dialResult struct {
+	conn    net.Conn
+	err     error
+	primary bool
+}
+
+// DialContext connects to the address on the named network, which must be
+// "tcp", "tcp4" or "tcp6".
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported network '%s'", network)
+	}
+
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return d.dialSingle(ctx, network, address)
+	}
+
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	primaries, fallbacks := partitionAddrs(filterAddrs(addrs, network), port)
+	if len(primaries) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+
+	delay := d.FallbackDelay
+	if delay == 0 {
+		delay = DefaultFallbackDelay
+	}
+	if len(fallbacks) == 0 || delay < 0 {
+		return d.dialSerial(ctx, network, primaries)
+	}
+	return d.dialParallel(ctx, network, primaries, fallbacks, delay)
+}
+
+// dialParallel races the connection attempts to the primary and fallback
+// addresses, of which the latter start after the given delay or as soon as
+// the primaries failed. It returns the first established connection, or
+// the error of the primaries if all failed.
+func (d *Dialer) dialParallel(ctx context.Context, network string, primaries, fallbacks []string, delay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult)
+	race := func(primary bool, addrs []string) {
+		conn, err := d.dialSerial(ctx, network, addrs)
+		select {
+		case results <- dialResult{conn: conn, err: err, primary: primary}:
+		case <-ctx.Done():
+			// The race was decided, close the connection of the loser.
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+
+	go race(true, primaries)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var primaryErr, fallbackErr error
+	fallbackStarted := false
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				go race(false, fallbacks)
+			}
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryErr = res.err
+			} else {
+				fallbackErr = res.err
+			}
+			if primaryErr != nil && fallbackErr != nil {
+				return nil, primaryErr
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				timer.Stop()
+				go race(false, fallbacks)
+			}
+		}
+	}
+}
+
+// dialSerial connects to the given addresses in order, and returns the
+// first established connection, or the first error if all failed. Like
+// net.Dialer, the remaining time until the deadline of the context is split
+// among the remaining addresses, so a single unresponsive address can not
+// consume all of it.
+func (d *Dialer) dialSerial(ctx context.Context, network string, addrs []string) (net.Conn, error) {
+	var firstErr error
+	for i, addr := range addrs {
+		if err := ctx.Err(); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			break
+		}
+		conn, err := d.dialPartial(ctx, network, addr, len(addrs)-i)
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// dialPartial connects to the address within its share of the remaining
+// time until the deadline of the context, of which the given number of
+// addresses remain to be dialed.
+func (d *Dialer) dialPartial(ctx context.Context, network, address string, remaining int) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		partial, err := partialDeadline(time.Now(), deadline, remaining)
+		if err != nil {
+			return nil, err
+		}
+		if partial.Before(deadline) {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, partial)
+			defer cancel()
+		}
+	}
+	return d.dialSingle(ctx, network, address)
+}
+
+func (d *Dialer) dialSingle(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.dial != nil {
+		return d.dial(ctx, network, address)
+	}
+	nd := &net.Dialer{KeepAlive: d.KeepAlive}
+	return nd.DialContext(ctx, network, address)
+}
+
+// filterAddrs returns the addresses which can be dialed on the named
+// network.
+func filterAddrs(addrs []net.IPAddr, network string) []net.IPAddr {
+	var filtered []net.IPAddr
+	for _, addr := range addrs {
+		isV4 := addr.IP.To4() != nil
+		if (network == "tcp4" && !isV4) || (network == "tcp6" && isV4) {
+			continue
+		}
+		filtered = append(filtered, addr)
+	}
+	return filtered
+}
+
+// partitionAddrs divides the addresses into the primaries of the address
+// family of the first address, and the fallbacks of the other family,
+// joined with the given port.
+func partitionAddrs(addrs []net.IPAddr, port string) (primaries, fallbacks []string) {
+	for i, addr := range addrs {
+		hostPort := net.JoinHostPort(addr.String(), port)
+		if i == 0 || (addr.IP.To4() != nil) == (addrs[0].IP.To4() != nil) {
+			primaries = append(primaries, hostPort)
+			continue
+		}
+		fallbacks = append(fallbacks, hostPort)
+	}
+	return
+}
+
+// partialDeadline returns the deadline to use for a single address, when
+// multiple addresses are pending, as by the net package.
+func partialDeadline(now, deadline time.Time, addrsRemaining int) (time.Time, error) {
+	timeRemaining := deadline.Sub(now)
+	if timeRemaining <= 0 {
+		return time.Time{}, context.DeadlineExceeded
+	}
+	// Tentatively allocate equal time to each remaining address.
+	timeout := timeRemaining / time.Duration(addrsRemaining)
+	// If the time per address is too short, steal from the end of the list.
+	const saneMinimum = 2 * time.Second
+	if timeout < saneMinimum {
+		if timeRemaining < saneMinimum {
+			timeout = timeRemaining
+		} else {
+			timeout = saneMinimum
+		}
+	}
+	return now.Add(timeout), nil
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// staticResolver resolves any host to the configured addresses.
+type staticResolver struct {
+	addrs []string
+	err   error
+}
+
+func (r *staticResolver) LookupIPAddr(_ context.Context, _ string) ([]net.IPAddr, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var addrs []net.IPAddr
+	for _, a := range r.addrs {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(a)})
+	}
+	return addrs, nil
+}
+
+func Test_Dialer_unreachableIPv6(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	d := &Dialer{
+		// 100::1 is part of the discard-only prefix (RFC 6666), which
+		// either black holes or fails to route.
+		Resolver:      &staticResolver{addrs: []string{"100::1", "127.0.0.1"}},
+		FallbackDelay: 100 * time.Millisecond,
+		Timeout:       30 * time.Second,
+	}
+
+	start := time.Now()
+	conn, err := d.DialContext(context.TODO(), "tcp", net.JoinHostPort("example.com", port))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("got connection after %s, want less than %s", elapsed, 2*time.Second)
+	}
+	if got := conn.RemoteAddr().String(); got != l.Addr().String() {
+		t.Errorf("got connection to %s, want %s", got, l.Addr())
+	}
+}
+
+func Test_Dialer_DialContext(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		network      string
+		resolver     *staticResolver
+		hang         []string
+		fail         []string
+		wantAddr     string
+		wantErr      string
+		wantMinDelay time.Duration
+		wantMaxDelay time.Duration
+	}{
+		{
+			name:         "primary succeeds",
+			resolver:     &staticResolver{addrs: []string{"2001:db8::1", "192.0.2.1"}},
+			wantAddr:     "[2001:db8::1]:443",
+			wantMaxDelay: delay,
+		},
+		{
+			name:         "primary hangs",
+			resolver:     &staticResolver{addrs: []string{"2001:db8::1", "192.0.2.1"}},
+			hang:         []string{"[2001:db8::1]:443"},
+			wantAddr:     "192.0.2.1:443",
+			wantMinDelay: delay,
+		},
+		{
+			name:         "primary fails",
+			resolver:     &staticResolver{addrs: []string{"2001:db8::1", "192.0.2.1"}},
+			fail:         []string{"[2001:db8::1]:443"},
+			wantAddr:     "192.0.2.1:443",
+			wantMaxDelay: delay,
+		},
+		{
+			name:     "primaries are dialed in order",
+			resolver: &staticResolver{addrs: []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}},
+			fail:     []string{"192.0.2.1:443"},
+			hang:     []string{"[2001:db8::1]:443"},
+			wantAddr: "192.0.2.2:443",
+		},
+		{
+			name:     "all fail",
+			resolver: &staticResolver{addrs: []string{"2001:db8::1", "192.0.2.1"}},
+			fail:     []string{"[2001:db8::1]:443", "192.0.2.1:443"},
+			wantErr:  "dial [2001:db8::1]:443 failed",
+		},
+		{
+			name:     "tcp4 network",
+			network:  "tcp4",
+			resolver: &staticResolver{addrs: []string{"2001:db8::1", "192.0.2.1"}},
+			wantAddr: "192.0.2.1:443",
+		},
+		{
+			name:     "no suitable address",
+			network:  "tcp6",
+			resolver: &staticResolver{addrs: []string{"192.0.2.1"}},
+			wantErr:  "no suitable address found",
+		},
+		{
+			name:     "resolver error",
+			resolver: &staticResolver{err: errors.New("no such host")},
+			wantErr:  "no such host",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var dialed []string
+			d := &Dialer{
+				Resolver:      tt.resolver,
+				FallbackDelay: delay,
+				Timeout:       5 * time.Second,
+				dial: func(ctx context.Context, _, address string) (net.Conn, error) {
+					mu.Lock()
+					dialed = append(dialed, address)
+					mu.Unlock()
+					for _, a := range tt.hang {
+						if a == address {
+							<-ctx.Done()
+							return nil, ctx.Err()
+						}
+					}
+					for _, a := range tt.fail {
+						if a == address {
+							return nil, errors.New("dial " + address + " failed")
+						}
+					}
+					return &fakeConn{addr: address}, nil
+				},
+			}
+
+			network := tt.network
+			if network == "" {
+				network = "tcp"
+			}
+			start := time.Now()
+			conn, err := d.DialContext(context.TODO(), network, "example.com:443")
+			elapsed := time.Since(start)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v (dialed %v)", err, dialed)
+			}
+			if got := conn.RemoteAddr().String(); got != tt.wantAddr {
+				t.Errorf("got connection to %s, want %s", got, tt.wantAddr)
+			}
+			if elapsed < tt.wantMinDelay {
+				t.Errorf("got connection after %s, want at least %s", elapsed, tt.wantMinDelay)
+			}
+			if tt.wantMaxDelay > 0 && elapsed >= tt.wantMaxDelay {
+				t.Errorf("got connection after %s, want less than %s", elapsed, tt.wantMaxDelay)
+			}
+		})
+	}
+}
+
+func Test_Dialer_partialDeadline(t *testing.T) {
+	var mu sync.Mutex
+	deadlines := map[string]time.Duration{}
+	d := &Dialer{
+		Resolver: &staticResolver{addrs: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"}},
+		Timeout:  20 * time.Second,
+		dial: func(ctx context.Context, _, address string) (net.Conn, error) {
+			deadline, _ := ctx.Deadline()
+			mu.Lock()
+			deadlines[address] = time.Until(deadline)
+			mu.Unlock()
+			if address != "192.0.2.4:443" {
+				return nil, errors.New("dial " + address + " failed")
+			}
+			return &fakeConn{addr: address}, nil
+		},
+	}
+
+	conn, err := d.DialContext(context.TODO(), "tcp", "example.com:443")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn.Close()
+
+	// The first address gets a quarter of the timeout, the last one the
+	// remaining time.
+	if got := deadlines["192.0.2.1:443"]; got > 5*time.Second || got < 4*time.Second {
+		t.Errorf("got deadline in %s for the first address, want about %s", got, 5*time.Second)
+	}
+	if got := deadlines["192.0.2.4:443"]; got < 19*time.Second {
+		t.Errorf("got deadline in %s for the last address, want about %s", got, 20*time.Second)
+	}
+}
+
+func Test_partialDeadline(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		deadline  time.Time
+		remaining int
+		want      time.Time
+		wantErr   bool
+	}{
+		{name: "single address", deadline: now.Add(10 * time.Second), remaining: 1, want: now.Add(10 * time.Second)},
+		{name: "split among addresses", deadline: now.Add(10 * time.Second), remaining: 2, want: now.Add(5 * time.Second)},
+		{name: "sane minimum", deadline: now.Add(10 * time.Second), remaining: 10, want: now.Add(2 * time.Second)},
+		{name: "less than sane minimum left", deadline: now.Add(time.Second), remaining: 2, want: now.Add(time.Second)},
+		{name: "deadline exceeded", deadline: now.Add(-time.Second), remaining: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := partialDeadline(now, tt.deadline, tt.remaining)
+			if tt.wantErr {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Dialer_transport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("index"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	resolver := DefaultResolver
+	DefaultResolver = &staticResolver{addrs: []string{"127.0.0.1"}}
+	defer func() { DefaultResolver = resolver }()
+
+	tr := NewOrIdle(nil)
+	defer Release(tr)
+	tr.Proxy = nil
+	defer func() { tr.Proxy = http.ProxyFromEnvironment }()
+
+	resp, err := (&http.Client{Transport: tr}).Get("http://charts.example.com:" + port + "/index.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "index" {
+		t.Errorf("got body %q, want %q", b, "index")
+	}
+}
+
+// fakeConn is a net.Conn of which only the remote address is functional.
+type fakeConn struct {
+	net.Conn
+	addr string
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr {
+	addr, _ := net.ResolveTCPAddr("tcp", c.addr)
+	return addr
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ResponseTooLargeError is returned when the body of a response exceeds the
+// max allowed size.
+type ResponseTooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+// Error returns the error string.
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body of '%s' exceeds '%d' bytes limit", e.URL, e.Limit)
+}
+
+// LimitResponseBody limits the body of the given response to limit bytes.
+// If the Content-Length of the response exceeds the limit, the body is
+// closed and a ResponseTooLargeError is returned. Otherwise, the body is
+// replaced by a reader which fails with a ResponseTooLargeError once more
+// than limit bytes are read, which guards against servers streaming an
+// unbounded body. A limit of zero or less disables the limit.
+func LimitResponseBody(resp *http.Response, limit int64) error {
+	if limit <= 0 || resp == nil || resp.Body == nil {
+		return nil
+	}
+	var u string
+	if resp.Request != nil && resp.Request.URL != nil {
+		u = resp.Request.URL.Redacted()
+	}
+	if resp.ContentLength > limit {
+		resp.Body.Close()
+		return &ResponseTooLargeError{URL: u, Limit: limit}
+	}
+	resp.Body = &limitedBody{
+		ReadCloser: resp.Body,
+		limit:      limit,
+		err:        &ResponseTooLargeError{URL: u, Limit: limit},
+	}
+	return nil
+}
+
+// limitedBody fails with err once more than limit bytes are read from the
+// ReadCloser.
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+	err   error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.read > b.limit {
+		return 0, b.err
+	}
+	// Read at most one byte past the limit, to tell a body of exactly the
+	// limit apart from a larger one.
+	if max := b.limit + 1 - b.read; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n - int(b.read-b.limit), b.err
+	}
+	return n, err
+}
+
+// LimitResponses returns a WrapperFunc which limits the body of the
+// responses to limit bytes using LimitResponseBody. Requests for which skip
+// returns true, and the redirects which originate from them, are not
+// limited. This allows limiting metadata responses while large downloads
+// are streamed to disk.
+func LimitResponses(limit int64, skip func(req *http.Request) bool) WrapperFunc {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &limitRoundTripper{next: next, limit: limit, skip: skip}
+	}
+}
+
+type limitRoundTripper struct {
+	next  http.RoundTripper
+	limit int64
+	skip  func(req *http.Request) bool
+}
+
+func (rt *limitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil || (rt.skip != nil && rt.skip(originalRequest(req))) {
+		return resp, err
+	}
+	if err := LimitResponseBody(resp, rt.limit); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// originalRequest returns the request which led to the given request
+// through redirects, or the request itself if it is not a redirect.
+func originalRequest(req *http.Request) *http.Request {
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	return req
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_LimitResponses(t *testing.T) {
+	const limit = 1024
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect/blobs/sha256:abc" {
+			http.Redirect(w, r, "/storage/abc", http.StatusTemporaryRedirect)
+			return
+		}
+		size, err := strconv.Atoi(r.URL.Query().Get("size"))
+		if err != nil {
+			size = 4 * limit
+		}
+		if r.URL.Query().Get("stream") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+		}
+		// Stream the body in chunks, without announcing its length when
+		// streaming.
+		chunk := []byte(strings.Repeat("x", 128))
+		for written := 0; written < size; written += len(chunk) {
+			if size-written < len(chunk) {
+				chunk = chunk[:size-written]
+			}
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}))
+	defer server.Close()
+
+	isBlob := func(req *http.Request) bool {
+		return strings.Contains(req.URL.Path, "/blobs/")
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		wantSize     int
+		wantTooLarge bool
+	}{
+		{name: "within limit", path: "/v2/chart/manifests/1.0.0?size=512", wantSize: 512},
+		{name: "exactly the limit", path: "/v2/chart/manifests/1.0.0?size=1024&stream=1", wantSize: limit},
+		{name: "content length exceeds limit", path: "/v2/chart/manifests/1.0.0", wantTooLarge: true},
+		{name: "streamed body exceeds limit", path: "/index.yaml?stream=1", wantTooLarge: true},
+		{name: "skipped request", path: "/v2/chart/blobs/sha256:abc?stream=1", wantSize: 4 * limit},
+		{name: "redirect of skipped request", path: "/redirect/blobs/sha256:abc", wantSize: 4 * limit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: LimitResponses(limit, isBlob)(http.DefaultTransport)}
+			resp, err := client.Get(server.URL + tt.path)
+			var b []byte
+			if err == nil {
+				b, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+
+			var tooLargeErr *ResponseTooLargeError
+			if tt.wantTooLarge {
+				if !errors.As(err, &tooLargeErr) {
+					t.Fatalf("expected ResponseTooLargeError, got: %v", err)
+				}
+				if tooLargeErr.Limit != limit {
+					t.Errorf("expected limit %d, got %d", limit, tooLargeErr.Limit)
+				}
+				if len(b) > limit {
+					t.Errorf("expected at most %d bytes to be read, got %d", limit, len(b))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(b) != tt.wantSize {
+				t.Errorf("expected %d bytes, got %d", tt.wantSize, len(b))
+			}
+		})
+	}
+}
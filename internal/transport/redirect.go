@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// DefaultMaxRedirects is the max number of redirects followed for a request
+// when the RedirectPolicy does not configure MaxRedirects.
+const DefaultMaxRedirects = 10
+
+// DefaultRedirectPolicy is the RedirectPolicy applied to the redirects
+// followed by clients of the pooled transports. It allows all redirects up
+// to DefaultMaxRedirects by default.
+var DefaultRedirectPolicy = &RedirectPolicy{}
+
+// RedirectPolicy restricts the targets of redirects, to prevent a server
+// from directing clients to internal endpoints (SSRF).
+type RedirectPolicy struct {
+	// DenyPrivate rejects redirects to hosts which are, or resolve to, a
+	// loopback, private, link-local or unspecified IP address.
+	DenyPrivate bool
+	// Resolver looks up the addresses of the redirect host. When nil, the
+	// DefaultResolver is used.
+	Resolver Resolver
+	// MaxRedirects is the max number of redirects followed for a request.
+	// When zero or less, DefaultMaxRedirects is used. Clients with their own
+	// limit, like the http.Client, may stop following redirects earlier.
+	MaxRedirects int
+}
+
+// RedirectPolicyError is returned when a redirect is rejected by the
+// RedirectPolicy.
+type RedirectPolicyError struct {
+	URL    string
+	Reason string
+}
+
+// Error returns the error string.
+func (e *RedirectPolicyError) Error() string {
+	return fmt.Sprintf("redirect to '%s' is not allowed: %s", e.URL, e.Reason)
+}
+
+// TooManyRedirectsError is returned when a request is redirected more than
+// the max number of times allowed by the RedirectPolicy.
+type TooManyRedirectsError struct {
+	URL string
+	Max int
+}
+
+// Error returns the error string.
+func (e *TooManyRedirectsError) Error() string {
+	return fmt.Sprintf("too many redirects: stopped after %d redirects at '%s'", e.Max, e.URL)
+}
+
+// CheckCount returns a TooManyRedirectsError if another redirect to the
+// given URL exceeds the max number of redirects, given the number of
+// redirects already followed for the request.
+func (p *RedirectPolicy) CheckCount(u *url.URL, redirects int) error {
+	max := DefaultMaxRedirects
+	if p != nil && p.MaxRedirects > 0 {
+		max = p.MaxRedirects
+	}
+	if redirects >= max {
+		return &TooManyRedirectsError{URL: u.Redacted(), Max: max}
+	}
+	return nil
+}
+
+// Check returns a RedirectPolicyError if a redirect to the given URL is
+// not allowed.
+func (p *RedirectPolicy) Check(ctx context.Context, u *url.URL) error {
+	if p == nil || !p.DenyPrivate {
+		return nil
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if IsPrivateIP(ip) {
+			return &RedirectPolicyError{URL: u.Redacted(), Reason: "host is a private IP address"}
+		}
+		return nil
+	}
+	resolver := p.Resolver
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve redirect host '%s': %w", host, err)
+	}
+	for _, addr := range addrs {
+		if IsPrivateIP(addr.IP) {
+			return &RedirectPolicyError{
+				URL:    u.Redacted(),
+				Reason: fmt.Sprintf("host resolves to private IP address '%s'", addr.IP),
+			}
+		}
+	}
+	return nil
+}
+
+// CheckRedirect checks the redirect request and the number of requests
+// already made against the RedirectPolicy. It can be used as the
+// CheckRedirect function of an http.Client.
+func (p *RedirectPolicy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if err := p.CheckCount(req.URL, len(via)-1); err != nil {
+		return err
+	}
+	return p.Check(req.Context(), req.URL)
+}
+
+// redirectCount returns the number of redirects which led to the given
+// request, including the redirect to the request itself, by walking the
+// responses which caused the redirects.
+func redirectCount(req *http.Request) int {
+	var n int
+	for r := req; r != nil && r.Response != nil; r = r.Response.Request {
+		n++
+	}
+	return n
+}
+
+// IsPrivateIP returns if the given IP address is a loopback, private,
+// link-local or unspecified address.
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_RedirectPolicy(t *testing.T) {
+	var targetRequests int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&targetRequests, 1)
+		_, _ = w.Write([]byte("internal"))
+	}))
+	defer target.Close()
+	targetURL, err := url.Parse(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		policy       *RedirectPolicy
+		location     string
+		wantRejected bool
+	}{
+		{
+			name:     "without policy",
+			location: target.URL,
+		},
+		{
+			name:     "allowing private networks",
+			policy:   &RedirectPolicy{},
+			location: target.URL,
+		},
+		{
+			name:         "to loopback address",
+			policy:       &RedirectPolicy{DenyPrivate: true},
+			location:     target.URL,
+			wantRejected: true,
+		},
+		{
+			name: "to host resolving to private address",
+			policy: &RedirectPolicy{
+				DenyPrivate: true,
+				Resolver:    &staticResolver{addrs: []string{"203.0.113.10", "10.0.0.1"}},
+			},
+			location:     "http://internal.example.com:" + targetURL.Port() + "/",
+			wantRejected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&targetRequests, 0)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, tt.location, http.StatusFound)
+			}))
+			defer server.Close()
+
+			DefaultRedirectPolicy = tt.policy
+			defer func() { DefaultRedirectPolicy = &RedirectPolicy{} }()
+
+			tr := NewOrIdle(nil)
+			defer Release(tr)
+
+			resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+			if tt.wantRejected {
+				var policyErr *RedirectPolicyError
+				if !errors.As(err, &policyErr) {
+					t.Fatalf("got error %v, want RedirectPolicyError", err)
+				}
+				if got := atomic.LoadInt32(&targetRequests); got != 0 {
+					t.Errorf("got %d requests to redirect target, want 0", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+			if got := atomic.LoadInt32(&targetRequests); got != 1 {
+				t.Errorf("got %d requests to redirect target, want 1", got)
+			}
+		})
+	}
+}
+
+func Test_RedirectPolicy_MaxRedirects(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Redirect(w, r, r.URL.String(), http.StatusFound)
+	}))
+	defer server.Close()
+
+	DefaultRedirectPolicy = &RedirectPolicy{MaxRedirects: 3}
+	defer func() { DefaultRedirectPolicy = &RedirectPolicy{} }()
+
+	tr := NewOrIdle(nil)
+	defer Release(tr)
+
+	_, err := (&http.Client{Transport: tr}).Get(server.URL + "/loop")
+	var redirectsErr *TooManyRedirectsError
+	if !errors.As(err, &redirectsErr) {
+		t.Fatalf("got error %v, want TooManyRedirectsError", err)
+	}
+	if redirectsErr.Max != 3 {
+		t.Errorf("got max %d, want 3", redirectsErr.Max)
+	}
+	// The initial request, followed by three redirects.
+	if got := atomic.LoadInt32(&requests); got != 4 {
+		t.Errorf("got %d requests, want 4", got)
+	}
+}
+
+func Test_RedirectPolicy_CheckRedirect_count(t *testing.T) {
+	u, err := url.Parse("https://example.com/index.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &http.Request{URL: u}
+
+	tests := []struct {
+		name         string
+		policy       *RedirectPolicy
+		via          int
+		wantRejected bool
+	}{
+		{name: "first redirect", policy: &RedirectPolicy{MaxRedirects: 2}, via: 1},
+		{name: "last redirect", policy: &RedirectPolicy{MaxRedirects: 2}, via: 2},
+		{name: "exceeding redirect", policy: &RedirectPolicy{MaxRedirects: 2}, via: 3, wantRejected: true},
+		{name: "default max", policy: &RedirectPolicy{}, via: DefaultMaxRedirects},
+		{name: "exceeding default max", policy: &RedirectPolicy{}, via: DefaultMaxRedirects + 1, wantRejected: true},
+		{name: "without policy", via: DefaultMaxRedirects + 1, wantRejected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.CheckRedirect(req, make([]*http.Request, tt.via))
+			var redirectsErr *TooManyRedirectsError
+			if got := errors.As(err, &redirectsErr); got != tt.wantRejected {
+				t.Errorf("got error %v, want rejected %v", err, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func Test_RedirectPolicy_Check(t *testing.T) {
+	policy := &RedirectPolicy{DenyPrivate: true, Resolver: &staticResolver{addrs: []string{"2001:db8::1"}}}
+
+	tests := []struct {
+		url          string
+		wantRejected bool
+	}{
+		{url: "https://example.com/index.yaml"},
+		{url: "https://203.0.113.10/index.yaml"},
+		{url: "http://127.0.0.1:8080/", wantRejected: true},
+		{url: "http://[::1]/", wantRejected: true},
+		{url: "http://169.254.169.254/latest/meta-data", wantRejected: true},
+		{url: "http://192.168.0.1/", wantRejected: true},
+		{url: "http://0.0.0.0/", wantRejected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = policy.CheckRedirect(&http.Request{URL: u}, nil)
+			var policyErr *RedirectPolicyError
+			if got := errors.As(err, &policyErr); got != tt.wantRejected {
+				t.Errorf("got error %v, want rejected %t", err, tt.wantRejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+var (
+	// RetryAfterMaxWait is the maximum duration to wait for a single retry
+	// of a rate limited request. Responses asking to retry after a longer
+	// duration are returned as is.
+	RetryAfterMaxWait = 30 * time.Second
+	// RetryAfterMaxRetries is the maximum number of retries of a rate
+	// limited request.
+	RetryAfterMaxRetries = 3
+	// RetryAfterFallbackDelay is the duration to wait before retrying a rate
+	// limited request of which the Retry-After header is not available.
+	RetryAfterFallbackDelay = 5 * time.Second
+)
+
+// retryAfterKey marks the context of requests which are performed by the
+// retryAfterRoundTripper.
+type retryAfterKey struct{}
+
+// retryAfterRoundTripper retries requests which the server rate limited
+// with a 429 Too Many Requests status and a Retry-After header, after
+// waiting for the requested duration. The wait is bounded by
+// RetryAfterMaxWait and the deadline of the request context. Each attempt
+// is guarded by the DefaultCircuitBreaker.
+//
+// Redirect requests, of which the Response is set by the http.Client, are
+// checked against the DefaultRedirectPolicy before they are sent, including
+// the number of redirects which led to them.
+//
+// It is registered as the alternate round tripper for the HTTP(S) schemes
+// of the pooled transports. This makes it transparent to the Helm getters,
+// which only accept a *http.Transport.
+type retryAfterRoundTripper struct {
+	transport *http.Transport
+	clock     clock.Clock
+}
+
+// registerRetryAfter registers a retryAfterRoundTripper for the HTTP(S)
+// schemes of the given transport.
+func registerRetryAfter(t *http.Transport) {
+	rt := &retryAfterRoundTripper{transport: t, clock: clock.RealClock{}}
+	t.RegisterProtocol("http", rt)
+	t.RegisterProtocol("https", rt)
+}
+
+func (rt *retryAfterRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests performed by us are handled by the transport itself.
+	if req.Context().Value(retryAfterKey{}) != nil {
+		return nil, http.ErrSkipAltProtocol
+	}
+
+	if req.Response != nil {
+		if err := DefaultRedirectPolicy.CheckCount(req.URL, redirectCount(req)-1); err != nil {
+			return nil, err
+		}
+		if err := DefaultRedirectPolicy.Check(req.Context(), req.URL); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := context.WithValue(req.Context(), retryAfterKey{}, true)
+	for retries := 0; ; retries++ {
+		r := req.Clone(ctx)
+		if retries > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+
+		resp, err := DefaultCircuitBreaker.roundTrip(rt.next(), r)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests ||
+			retries >= RetryAfterMaxRetries || !isReplayable(req) {
+			return resp, err
+		}
+		wait, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), rt.clock.Now())
+		if !ok || wait > RetryAfterMaxWait {
+			return resp, nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(rt.clock.Now()) < wait {
+			return resp, nil
+		}
+
+		// Ensure the body is fully processed and closed, for increased
+		// likelihood of connection reuse.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		timer := rt.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+// next returns the http.RoundTripper which sends the requests, which is the
+// transport wrapped with the DefaultWrapper, if set.
+func (rt *retryAfterRoundTripper) next() http.RoundTripper {
+	if DefaultWrapper != nil {
+		return DefaultWrapper(rt.transport)
+	}
+	return rt.transport
+}
+
+// isReplayable returns if the body of the request can be sent again.
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, in either the
+// delay-seconds or the HTTP-date form, and returns the duration to wait
+// from now. It returns false if the value can not be parsed.
+func ParseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if seconds < 0 || seconds > int64(math.MaxInt64/time.Second) {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func Test_RetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryAfter   func() string
+		timeout      time.Duration
+		wantStatus   int
+		wantRequests int32
+		wantMinDelay time.Duration
+	}{
+		{
+			name:         "delay-seconds",
+			retryAfter:   func() string { return "1" },
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			wantMinDelay: time.Second,
+		},
+		{
+			name: "HTTP-date",
+			retryAfter: func() string {
+				return time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat)
+			},
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			wantMinDelay: time.Second,
+		},
+		{
+			name:         "exceeding max wait",
+			retryAfter:   func() string { return "3600" },
+			wantStatus:   http.StatusTooManyRequests,
+			wantRequests: 1,
+		},
+		{
+			name:         "exceeding context deadline",
+			retryAfter:   func() string { return "5" },
+			timeout:      2 * time.Second,
+			wantStatus:   http.StatusTooManyRequests,
+			wantRequests: 1,
+		},
+		{
+			name:         "without Retry-After",
+			retryAfter:   func() string { return "" },
+			wantStatus:   http.StatusTooManyRequests,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) == 1 {
+					if v := tt.retryAfter(); v != "" {
+						w.Header().Set("Retry-After", v)
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				_, _ = w.Write([]byte("index"))
+			}))
+			defer server.Close()
+
+			tr := NewOrIdle(nil)
+			defer Release(tr)
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			resp, err := (&http.Client{Transport: tr}).Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+			elapsed := time.Since(start)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := atomic.LoadInt32(&requests); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+			if elapsed < tt.wantMinDelay {
+				t.Errorf("got response after %s, want at least %s", elapsed, tt.wantMinDelay)
+			}
+			if tt.wantStatus == http.StatusOK {
+				b, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != "index" {
+					t.Errorf("got body %q, want %q", b, "index")
+				}
+			}
+		})
+	}
+}
+
+func Test_RetryAfter_clock(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "20")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("index"))
+	}))
+	defer server.Close()
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	tr := &http.Transport{}
+	rt := &retryAfterRoundTripper{transport: tr, clock: fakeClock}
+	tr.RegisterProtocol("http", rt)
+	defer tr.CloseIdleConnections()
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+		done <- result{resp, err}
+	}()
+
+	// Wait for the round tripper to back off, and advance the clock past
+	// the requested delay.
+	deadline := time.Now().Add(5 * time.Second)
+	for !fakeClock.HasWaiters() {
+		if time.Now().After(deadline) {
+			t.Fatal("round tripper did not back off")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	fakeClock.Step(19 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("request retried before the requested delay")
+	case <-time.After(50 * time.Millisecond):
+	}
+	fakeClock.Step(time.Second)
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatalf("unexpected error: %v", r.err)
+		}
+		defer r.resp.Body.Close()
+		if r.resp.StatusCode != http.StatusOK {
+			t.Errorf("got status %d, want %d", r.resp.StatusCode, http.StatusOK)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not retried after advancing the clock")
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func Test_ParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{value: "120", want: 2 * time.Minute, wantOk: true},
+		{value: " 0 ", want: 0, wantOk: true},
+		{value: "Wed, 01 Jun 2022 12:00:30 GMT", want: 30 * time.Second, wantOk: true},
+		{value: "Wed, 01 Jun 2022 11:59:00 GMT", want: 0, wantOk: true},
+		{value: ""},
+		{value: "-1"},
+		{value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("ParseRetryAfter(%q) = (%s, %t), want (%s, %t)", tt.value, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
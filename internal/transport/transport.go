@@ -19,12 +19,24 @@ package transport
 import (
 	"crypto/tls"
 	"fmt"
-	"net"
 	"net/http"
 	"sync"
 	"time"
 )
 
+// WrapperFunc wraps the http.RoundTripper which sends a request, e.g. to
+// instrument it.
+type WrapperFunc func(next http.RoundTripper) http.RoundTripper
+
+// DefaultWrapper, when set, wraps the http.Transport of the pooled
+// transports, which carries the TLS configuration of the request. Requests
+// reach it with the credentials set by their client, and after the
+// DefaultRedirectPolicy check. This allows instrumenting the requests of
+// the Helm getters and the managed Git HTTP transport, or replacing the
+// transport with a test double. It must be set before any request is
+// performed.
+var DefaultWrapper WrapperFunc
+
 // TransportPool is a progressive and non-blocking pool
 // for http.Transport objects, optimised for Gargabe Collection
 // and without a hard limit on number of objects created.
@@ -53,7 +65,7 @@ type TransportPool struct {
 
 var pool = &sync.Pool{
 	New: func() interface{} {
-		return &http.Transport{
+		t := &http.Transport{
 			DisableCompression: true,
 			Proxy:              http.ProxyFromEnvironment,
 
@@ -67,14 +79,25 @@ var pool = &sync.Pool{
 			// transport to be garbage collected.
 			IdleConnTimeout: 60 * time.Second,
 
-			// use safe defaults based off http.DefaultTransport
-			DialContext: (&net.Dialer{
+			// use safe defaults based off http.DefaultTransport, while
+			// racing IPv4 and IPv6 addresses resolved by the
+			// DefaultResolver
+			DialContext: (&Dialer{
 				Timeout:   30 * time.Second,
 				KeepAlive: 30 * time.Second,
 			}).DialContext,
 			TLSHandshakeTimeout:   10 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
+
+			// Attempt HTTP/2 despite the custom dialer, for TLS
+			// configurations offering "h2" through ALPN. Servers
+			// which do not support it negotiate HTTP/1.1 instead.
+			ForceAttemptHTTP2: true,
 		}
+		// Retry rate limited requests, as the Helm getters do not
+		// allow wrapping the transport.
+		registerRetryAfter(t)
+		return t
 	},
 }
 
@@ -82,6 +105,7 @@ var pool = &sync.Pool{
 // If none is found, creates a new Transport instead.
 //
 // tlsConfig can optionally set the TLSClientConfig for the transport.
+// HTTP/2 is only negotiated if its NextProtos include "h2".
 func NewOrIdle(tlsConfig *tls.Config) *http.Transport {
 	t := pool.Get().(*http.Transport)
 	t.TLSClientConfig = tlsConfig
@@ -97,6 +121,11 @@ func Release(transport *http.Transport) error {
 	}
 
 	transport.TLSClientConfig = nil
+	transport.Proxy = http.ProxyFromEnvironment
+	// Idle connections were established with the released TLS
+	// configuration, e.g. authenticated with a client certificate, and must
+	// not be reused by the next user of the transport.
+	transport.CloseIdleConnections()
 
 	pool.Put(transport)
 	return nil
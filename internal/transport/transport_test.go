@@ -18,6 +18,10 @@ package transport
 
 import (
 	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 )
 
@@ -56,3 +60,180 @@ func Test_TransportReuse(t *testing.T) {
 		t.Errorf("wanted error message: 'cannot release nil transport' got: %q", err.Error())
 	}
 }
+
+func Test_ReleaseClosesIdleConnections(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+	get := func(tr *http.Transport) {
+		t.Helper()
+		resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	tr := NewOrIdle(tlsConfig.Clone())
+	get(tr)
+	get(tr)
+	if got := atomic.LoadInt32(&conns); got != 1 {
+		t.Fatalf("expected idle connection to be reused, got %d connections", got)
+	}
+
+	if err := Release(tr); err != nil {
+		t.Fatalf("error releasing transport: %v", err)
+	}
+	tr.TLSClientConfig = tlsConfig.Clone()
+	get(tr)
+	if got := atomic.LoadInt32(&conns); got != 2 {
+		t.Errorf("expected idle connection to be closed on release, got %d connections", got)
+	}
+	Release(tr)
+}
+
+func Test_TransportALPN(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverH2   bool
+		nextProtos []string
+		wantProto  string
+	}{
+		{
+			name:       "negotiates h2",
+			serverH2:   true,
+			nextProtos: []string{"h2", "http/1.1"},
+			wantProto:  "HTTP/2.0",
+		},
+		{
+			name:       "falls back to HTTP/1.1 if server does not support h2",
+			nextProtos: []string{"h2", "http/1.1"},
+			wantProto:  "HTTP/1.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotProto string
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotProto = r.Proto
+			}))
+			server.EnableHTTP2 = tt.serverH2
+			server.StartTLS()
+			defer server.Close()
+
+			tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+			tlsConfig.NextProtos = tt.nextProtos
+			tr := NewOrIdle(tlsConfig)
+			defer Release(tr)
+
+			resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			tr.CloseIdleConnections()
+
+			if resp.Proto != tt.wantProto {
+				t.Errorf("got response protocol %q, want %q", resp.Proto, tt.wantProto)
+			}
+			if gotProto != tt.wantProto {
+				t.Errorf("got request protocol %q, want %q", gotProto, tt.wantProto)
+			}
+		})
+	}
+}
+
+type recordingRoundTripper struct {
+	next     http.RoundTripper
+	requests []string
+}
+
+func (rt *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests = append(rt.requests, req.Method+" "+req.URL.Path+" "+req.Header.Get("Authorization"))
+	return rt.next.RoundTrip(req)
+}
+
+func Test_DefaultWrapper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/index.yaml", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("index"))
+	}))
+	defer server.Close()
+
+	recorder := &recordingRoundTripper{}
+	defer func(w WrapperFunc) { DefaultWrapper = w }(DefaultWrapper)
+	DefaultWrapper = func(next http.RoundTripper) http.RoundTripper {
+		recorder.next = next
+		return recorder
+	}
+
+	tr := NewOrIdle(nil)
+	defer Release(tr)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/redirect", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("user", "pass")
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	want := []string{"GET /redirect Basic dXNlcjpwYXNz", "GET /index.yaml Basic dXNlcjpwYXNz"}
+	if len(recorder.requests) != len(want) {
+		t.Fatalf("got requests %q, want %q", recorder.requests, want)
+	}
+	for i := range want {
+		if recorder.requests[i] != want[i] {
+			t.Errorf("got request %q, want %q", recorder.requests[i], want[i])
+		}
+	}
+}
+
+type fakeRoundTripper func(req *http.Request) (*http.Response, error)
+
+func (f fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func Test_DefaultWrapper_testDouble(t *testing.T) {
+	defer func(w WrapperFunc) { DefaultWrapper = w }(DefaultWrapper)
+	DefaultWrapper = func(http.RoundTripper) http.RoundTripper {
+		return fakeRoundTripper(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusTeapot,
+				Body:       http.NoBody,
+				Request:    req,
+			}, nil
+		})
+	}
+
+	tr := NewOrIdle(nil)
+	defer Release(tr)
+
+	// The host does not resolve, the request never leaves the process.
+	resp, err := (&http.Client{Transport: tr}).Get("https://charts.invalid/index.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusTeapot)
+	}
+}
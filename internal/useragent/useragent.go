@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package useragent
+
+import (
+	"runtime/debug"
+)
+
+// Name is the product name used in the User-Agent.
+const Name = "source-controller"
+
+// version is the version of the controller, set at build time with
+// -ldflags "-X github.com/fluxcd/source-controller/internal/useragent.version=<version>".
+var version string
+
+// Tenant is an optional tag added as a comment to the User-Agent returned
+// by Get, to identify the controller instance in multi-tenant setups.
+var Tenant string
+
+// Get returns the User-Agent of the controller, containing its version and
+// the Tenant tag if set, e.g. "source-controller/v0.25.0 (team-a)".
+func Get() string {
+	return Format(Tenant)
+}
+
+// Format returns the User-Agent of the controller, with the given tenant
+// tag added as a comment if not empty.
+func Format(tenant string) string {
+	ua := Name + "/" + Version()
+	if tenant != "" {
+		ua += " (" + tenant + ")"
+	}
+	return ua
+}
+
+// Version returns the version of the controller set at build time, or the
+// version of the controller module from the build information of the
+// binary, or "dev" if neither is available.
+func Version() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
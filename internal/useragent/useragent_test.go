@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package useragent
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		tenant string
+		want   string
+	}{
+		{
+			name: "without tenant",
+			want: Name + "/" + Version(),
+		},
+		{
+			name:   "with tenant",
+			tenant: "team-a",
+			want:   Name + "/" + Version() + " (team-a)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(Format(tt.tenant)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(tenant string) { Tenant = tenant }(Tenant)
+	Tenant = "team-b"
+	g.Expect(Get()).To(Equal(Format("team-b")))
+	g.Expect(Version()).ToNot(BeEmpty())
+}
+
+func TestVersion(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(v string) { version = v }(version)
+	version = "v0.25.0"
+	g.Expect(Version()).To(Equal("v0.25.0"))
+	g.Expect(Format("")).To(Equal(Name + "/v0.25.0"))
+}
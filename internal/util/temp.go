@@ -27,19 +27,58 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// WorkDir is the directory in which temporary files and directories are
+// created for checkouts, archive staging and extraction. If empty,
+// os.TempDir is used.
+var WorkDir string
+
+// TempDir returns WorkDir, or os.TempDir if WorkDir is empty.
+func TempDir() string {
+	if WorkDir != "" {
+		return WorkDir
+	}
+	return os.TempDir()
+}
+
+// MkdirTemp creates a new temporary directory in TempDir, see
+// os.MkdirTemp.
+func MkdirTemp(pattern string) (string, error) {
+	return os.MkdirTemp(TempDir(), pattern)
+}
+
+// CreateTemp creates a new temporary file in TempDir, see os.CreateTemp.
+func CreateTemp(pattern string) (*os.File, error) {
+	return os.CreateTemp(TempDir(), pattern)
+}
+
+// WithTempDir creates a new temporary directory in TempDir, and calls fn
+// with its path. The directory is removed after fn returns, or panics.
+func WithTempDir(pattern string, fn func(dir string) error) error {
+	dir, err := MkdirTemp(pattern)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	return fn(dir)
+}
+
 // TempDirForObj creates a new temporary directory in the directory dir
 // in the format of 'Kind-Namespace-Name-*', and returns the
 // pathname of the new directory.
+// If the given dir is empty, TempDir is used as a default.
 func TempDirForObj(dir string, obj client.Object) (string, error) {
+	if dir == "" {
+		dir = TempDir()
+	}
 	return os.MkdirTemp(dir, pattern(obj))
 }
 
 // TempPathForObj creates a temporary file path in the format of
 // '<dir>/Kind-Namespace-Name-<random bytes><suffix>'.
-// If the given dir is empty, os.TempDir is used as a default.
+// If the given dir is empty, TempDir is used as a default.
 func TempPathForObj(dir, suffix string, obj client.Object) string {
 	if dir == "" {
-		dir = os.TempDir()
+		dir = TempDir()
 	}
 	randBytes := make([]byte, 16)
 	rand.Read(randBytes)
@@ -17,6 +17,7 @@ limitations under the License.
 package util
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -42,6 +43,93 @@ func TestTempDirForObj(t *testing.T) {
 	g.Expect(got2).To(ContainSubstring(got))
 }
 
+func TestTempDirForObj_WorkDir(t *testing.T) {
+	g := NewWithT(t)
+
+	workDir := t.TempDir()
+	defer func(dir string) { WorkDir = dir }(WorkDir)
+	WorkDir = workDir
+
+	got, err := TempDirForObj("", mockObj())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(BeADirectory())
+	g.Expect(filepath.Dir(got)).To(Equal(workDir))
+	g.Expect(os.RemoveAll(got)).To(Succeed())
+
+	g.Expect(TempPathForObj("", ".tgz", mockObj())).To(HavePrefix(filepath.Join(workDir, "secret-default-foo-")))
+}
+
+func TestMkdirTemp(t *testing.T) {
+	g := NewWithT(t)
+
+	workDir := t.TempDir()
+	defer func(dir string) { WorkDir = dir }(WorkDir)
+	WorkDir = workDir
+
+	dir, err := MkdirTemp("dir-*")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(filepath.Dir(dir)).To(Equal(workDir))
+	g.Expect(dir).To(BeADirectory())
+
+	f, err := CreateTemp("file-*")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(f.Close()).To(Succeed())
+	g.Expect(filepath.Dir(f.Name())).To(Equal(workDir))
+	g.Expect(f.Name()).To(BeARegularFile())
+}
+
+func TestWithTempDir(t *testing.T) {
+	workDir := t.TempDir()
+	defer func(dir string) { WorkDir = dir }(WorkDir)
+	WorkDir = workDir
+
+	t.Run("removes directory after return", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var got string
+		wantErr := errors.New("failed")
+		err := WithTempDir("dir-*", func(dir string) error {
+			got = dir
+			g.Expect(filepath.Dir(dir)).To(Equal(workDir))
+			g.Expect(os.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0o600)).To(Succeed())
+			return wantErr
+		})
+		g.Expect(err).To(Equal(wantErr))
+		g.Expect(got).ToNot(BeEmpty())
+		g.Expect(got).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("removes directory after panic", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var got string
+		g.Expect(func() {
+			_ = WithTempDir("dir-*", func(dir string) error {
+				got = dir
+				g.Expect(os.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0o600)).To(Succeed())
+				panic("checkout failed")
+			})
+		}).To(Panic())
+		g.Expect(got).ToNot(BeEmpty())
+		g.Expect(got).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("returns error for non-existing work directory", func(t *testing.T) {
+		g := NewWithT(t)
+
+		WorkDir = filepath.Join(workDir, "does-not-exist")
+		defer func() { WorkDir = workDir }()
+
+		called := false
+		err := WithTempDir("dir-*", func(string) error {
+			called = true
+			return nil
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(called).To(BeFalse())
+	})
+}
+
 func TestTempPathForObj(t *testing.T) {
 	tests := []struct {
 		name   string
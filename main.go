@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -26,12 +27,14 @@ import (
 
 	"github.com/go-logr/logr"
 	flag "github.com/spf13/pflag"
+	"go.opentelemetry.io/otel"
 	"helm.sh/helm/v3/pkg/getter"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/fluxcd/pkg/runtime/client"
 	helper "github.com/fluxcd/pkg/runtime/controller"
@@ -47,9 +50,18 @@ import (
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	"github.com/fluxcd/source-controller/controllers"
 	"github.com/fluxcd/source-controller/internal/cache"
+	intdigest "github.com/fluxcd/source-controller/internal/digest"
+	"github.com/fluxcd/source-controller/internal/fetch"
 	"github.com/fluxcd/source-controller/internal/helm"
+	"github.com/fluxcd/source-controller/internal/limit"
+	"github.com/fluxcd/source-controller/internal/tracing"
+	"github.com/fluxcd/source-controller/internal/transport"
+	"github.com/fluxcd/source-controller/internal/useragent"
+	"github.com/fluxcd/source-controller/internal/util"
 	"github.com/fluxcd/source-controller/pkg/git"
 	"github.com/fluxcd/source-controller/pkg/git/libgit2/managed"
+	"github.com/fluxcd/source-controller/pkg/minio"
+	"github.com/fluxcd/source-controller/pkg/sourceignore"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -91,6 +103,7 @@ func main() {
 		helmIndexLimit           int64
 		helmChartLimit           int64
 		helmChartFileLimit       int64
+		helmManifestLimit        int64
 		clientOptions            client.Options
 		logOptions               logger.Options
 		leaderElectionOptions    leaderelection.Options
@@ -99,8 +112,28 @@ func main() {
 		helmCacheMaxSize         int
 		helmCacheTTL             string
 		helmCachePurgeInterval   string
+		helmRegistryHostsDir     string
 		artifactRetentionTTL     time.Duration
 		artifactRetentionRecords int
+		gitHostMaxConcurrent     int
+		gitHostQPS               float64
+		gitAllowedHosts          []string
+		gitDeniedHosts           []string
+		gitDenyPrivateHosts      bool
+		gitTimeSource            string
+		gitFetchMaxDuration      time.Duration
+		artifactDigestAlgo       string
+		artifactPreserveSymlinks bool
+		artifactMaxFileSize      int64
+		artifactSkipLargeFiles   bool
+		artifactManifests        bool
+		sourceMaxSize            int64
+		sourceMaxFiles           int64
+		bucketObjectCachePath    string
+		gitCloneCachePath        string
+		workDir                  string
+		circuitBreakerThreshold  int
+		circuitBreakerCooldown   time.Duration
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-addr", envOrDefault("METRICS_ADDR", ":8080"),
@@ -123,6 +156,8 @@ func main() {
 		"The max allowed size in bytes of a Helm chart file.")
 	flag.Int64Var(&helmChartFileLimit, "helm-chart-file-max-size", helm.MaxChartFileSize,
 		"The max allowed size in bytes of a file in a Helm chart.")
+	flag.Int64Var(&helmManifestLimit, "helm-oci-manifest-max-size", helm.MaxManifestSize,
+		"The max allowed size in bytes of a tag list or other metadata response of an OCI registry.")
 	flag.DurationVar(&requeueDependency, "requeue-dependency", 30*time.Second,
 		"The interval at which failing dependencies are reevaluated.")
 	flag.IntVar(&helmCacheMaxSize, "helm-cache-max-size", 0,
@@ -131,14 +166,74 @@ func main() {
 		"The TTL of an index in the cache. Valid time units are ns, us (or µs), ms, s, m, h.")
 	flag.StringVar(&helmCachePurgeInterval, "helm-cache-purge-interval", "1m",
 		"The interval at which the cache is purged. Valid time units are ns, us (or µs), ms, s, m, h.")
+	flag.StringVar(&helmRegistryHostsDir, "helm-registry-hosts-dir", "",
+		"The path to a containerd-style registry hosts directory, configuring the mirrors to pull Helm charts from OCI registries through.")
 	flag.StringSliceVar(&git.KexAlgos, "ssh-kex-algos", []string{},
 		"The list of key exchange algorithms to use for ssh connections, arranged from most preferred to the least.")
 	flag.StringSliceVar(&git.HostKeyAlgos, "ssh-hostkey-algos", []string{},
 		"The list of hostkey algorithms to use for ssh connections, arranged from most preferred to the least.")
+	flag.IntVar(&gitHostMaxConcurrent, "git-host-max-concurrent", 0,
+		"The maximum number of concurrent Git operations per remote host, zero means unlimited.")
+	flag.Float64Var(&gitHostQPS, "git-host-qps", 0,
+		"The maximum number of Git operations started per second per remote host, zero means unlimited.")
+	flag.StringSliceVar(&gitAllowedHosts, "git-allowed-hosts", []string{},
+		"The glob patterns of the hosts Git operations are allowed to connect to, e.g. '*.example.com'. When empty, all hosts which are not denied are allowed.")
+	flag.StringSliceVar(&gitDeniedHosts, "git-denied-hosts", []string{},
+		"The glob patterns of the hosts Git operations are not allowed to connect to, taking precedence over the allowed hosts.")
+	flag.BoolVar(&gitDenyPrivateHosts, "git-deny-private-hosts", false,
+		"Deny Git operations against hosts which are, or resolve to, a loopback, private or link-local IP address.")
+	flag.StringVar(&gitTimeSource, "git-time-source", string(git.TimeSourceCommit),
+		"The commit timestamp which drives time-based logic such as the ordering of tags with an equal SemVer version, valid values are ('commit', 'author').")
+	flag.DurationVar(&gitFetchMaxDuration, "git-fetch-max-duration", 0,
+		"The max duration of a Git checkout, capping the timeout of GitRepositories, zero means unlimited.")
+	flag.StringVar(&git.DefaultCredentialHelper, "git-credential-helper", "",
+		"The absolute path to a git credential helper used to obtain the credentials of HTTP(S) Git repositories without a password, which opt in to it through their Secret.")
+	flag.StringVar(&git.DefaultBundleDir, "git-bundle-dir", "",
+		"The absolute path to the directory from which Git bundles may be checked out using 'file://' URLs. When empty, Git bundles are not allowed.")
+	flag.StringSliceVar(&git.DefaultRedirectTrustedHosts, "git-redirect-trusted-hosts", []string{},
+		"The glob patterns of the hosts to which the credentials of HTTP(S) Git repositories are re-sent when redirected to a different host.")
+	flag.Int64Var(&sourceMaxSize, "source-max-size", 0,
+		"The max allowed total size in bytes of the files fetched from a Git repository, including its .git directory, or Bucket, zero means unlimited. For Git repositories the limit is enforced on a best-effort basis while fetching.")
+	flag.Int64Var(&sourceMaxFiles, "source-max-files", 0,
+		"The max allowed number of files fetched from a Git repository, excluding its .git directory, or Bucket, zero means unlimited. For Git repositories the limit is enforced on a best-effort basis while fetching.")
+	flag.StringSliceVar(&sourceignore.IgnoreFiles, "source-ignore-files", sourceignore.IgnoreFiles,
+		"The names of the ignore files read from Git repositories and Buckets, in order of increasing precedence.")
+	flag.StringVar(&bucketObjectCachePath, "bucket-object-cache-path", "",
+		"The local path at which Bucket objects are cached between reconciliations, an empty value disables the cache.")
+	flag.StringVar(&gitCloneCachePath, "git-clone-cache-path", "",
+		"The local path at which go-git clones of GitRepositories are kept between reconciliations, an empty value disables keeping them.")
 	flag.DurationVar(&artifactRetentionTTL, "artifact-retention-ttl", 60*time.Second,
 		"The duration of time that artifacts will be kept in storage before being garbage collected.")
 	flag.IntVar(&artifactRetentionRecords, "artifact-retention-records", 2,
 		"The maximum number of artifacts to be kept in storage after a garbage collection.")
+	flag.BoolVar(&artifactPreserveSymlinks, "artifact-preserve-symlinks", false,
+		"Archive relative symbolic links within Git repositories as symbolic links, instead of ignoring them.")
+	flag.Int64Var(&artifactMaxFileSize, "artifact-max-file-size", 0,
+		"The max allowed size in bytes of a file in a Git repository artifact, zero means unlimited.")
+	flag.BoolVar(&artifactSkipLargeFiles, "artifact-skip-large-files", false,
+		"Skip files exceeding the artifact max file size instead of failing to archive the Git repository.")
+	flag.BoolVar(&artifactManifests, "artifact-manifests", false,
+		"Write a manifest of the paths, sizes and digests of the archived files alongside Git repository and Bucket artifacts.")
+	flag.StringVar(&artifactDigestAlgo, "artifact-digest-algo", intdigest.Canonical.String(),
+		"The algorithm to use to calculate the digest of artifacts, valid values are ('sha256', 'sha512').")
+	flag.DurationVar(&transport.DefaultFallbackDelay, "dial-fallback-delay", transport.DefaultFallbackDelay,
+		"The delay before racing a connection to the other IP address family of a dual-stack host, a negative value disables the fallback.")
+	flag.Int64Var(&minio.ChunkThreshold, "bucket-chunk-threshold", 0,
+		"The size in bytes above which objects of S3 compatible buckets are downloaded in chunks using range requests, zero disables chunked downloads.")
+	flag.Int64Var(&minio.ChunkSize, "bucket-chunk-size", minio.ChunkSize,
+		"The size in bytes of the chunks of chunked bucket object downloads.")
+	flag.BoolVar(&transport.DefaultRedirectPolicy.DenyPrivate, "deny-private-redirects", false,
+		"Reject HTTP redirects to hosts which are, or resolve to, a loopback, private or link-local IP address.")
+	flag.IntVar(&transport.DefaultRedirectPolicy.MaxRedirects, "max-redirects", transport.DefaultMaxRedirects,
+		"The max number of HTTP redirects followed for a request of a Helm repository, Helm chart or HTTP(S) Git repository.")
+	flag.IntVar(&circuitBreakerThreshold, "circuit-breaker-threshold", 0,
+		"The number of consecutive failed HTTP requests to a host after which further requests to the host are rejected for the cooldown, zero disables the circuit breaker.")
+	flag.DurationVar(&circuitBreakerCooldown, "circuit-breaker-cooldown", 30*time.Second,
+		"The duration for which requests to a host are rejected after the circuit breaker threshold is reached, before a single request is allowed to probe the host.")
+	flag.StringVar(&useragent.Tenant, "user-agent-tenant", "",
+		"The tenant tag added to the User-Agent of requests to Git and Helm repositories, to identify the controller in multi-tenant setups.")
+	flag.StringVar(&workDir, "workdir", envOrDefault("WORKDIR", ""),
+		"The directory in which temporary files are created for checkouts and artifact building, defaults to the OS temporary directory.")
 
 	clientOptions.BindFlags(flag.CommandLine)
 	logOptions.BindFlags(flag.CommandLine)
@@ -158,10 +253,91 @@ func main() {
 		os.Exit(1)
 	}
 
+	algo, err := intdigest.AlgorithmForName(artifactDigestAlgo)
+	if err != nil {
+		setupLog.Error(err, "unable to configure canonical digest algorithm")
+		os.Exit(1)
+	}
+	intdigest.Canonical = algo
+
 	// Set upper bound file size limits Helm
 	helm.MaxIndexSize = helmIndexLimit
 	helm.MaxChartSize = helmChartLimit
 	helm.MaxChartFileSize = helmChartFileLimit
+	helm.MaxManifestSize = helmManifestLimit
+
+	// Set the registry mirrors for Helm OCI repositories
+	if helmRegistryHostsDir != "" {
+		mirrors, err := registry.LoadMirrors(helmRegistryHostsDir)
+		if err != nil {
+			setupLog.Error(err, "unable to load registry mirrors", "path", helmRegistryHostsDir)
+			os.Exit(1)
+		}
+		registry.DefaultMirrors = mirrors
+	}
+
+	// Set per host limits for Git operations
+	git.DefaultHostLimiter = git.NewHostLimiter(gitHostMaxConcurrent, gitHostQPS)
+
+	// Set the hosts Git operations are allowed to connect to
+	git.DefaultHostPolicy, err = git.NewHostPolicy(gitAllowedHosts, gitDeniedHosts, gitDenyPrivateHosts)
+	if err != nil {
+		setupLog.Error(err, "unable to configure git host policy")
+		os.Exit(1)
+	}
+
+	timeSource, err := git.ParseTimeSource(gitTimeSource)
+	if err != nil {
+		setupLog.Error(err, "unable to configure git time source")
+		os.Exit(1)
+	}
+
+	if git.DefaultCredentialHelper != "" && !filepath.IsAbs(git.DefaultCredentialHelper) {
+		setupLog.Error(fmt.Errorf("path must be absolute"), "invalid git credential helper", "path", git.DefaultCredentialHelper)
+		os.Exit(1)
+	}
+	if git.DefaultBundleDir != "" && !filepath.IsAbs(git.DefaultBundleDir) {
+		setupLog.Error(fmt.Errorf("path must be absolute"), "invalid git bundle directory", "path", git.DefaultBundleDir)
+		os.Exit(1)
+	}
+
+	// Set the circuit breaker for HTTP requests of the pooled transports
+	if circuitBreakerThreshold > 0 {
+		transport.DefaultCircuitBreaker = transport.NewCircuitBreaker(circuitBreakerThreshold, circuitBreakerCooldown)
+		metrics.Registry.MustRegister(transport.DefaultCircuitBreaker.Collectors()...)
+	}
+
+	// Set size limits for fetched sources
+	limit.DefaultLimits = limit.Limits{MaxBytes: sourceMaxSize, MaxFiles: sourceMaxFiles}
+
+	// Export the spans of the source operations, if an OTLP endpoint is
+	// configured
+	ctx := ctrl.SetupSignalHandler()
+	if tracing.Enabled() {
+		tp, err := tracing.NewTracerProvider(ctx, controllerName, useragent.Version())
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		otel.SetTracerProvider(tp)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tp.Shutdown(shutdownCtx); err != nil {
+				setupLog.Error(err, "unable to flush traces")
+			}
+		}()
+		setupLog.Info("exporting traces over OTLP")
+	}
+
+	// Set the directory for temporary files
+	if workDir != "" {
+		if err := os.MkdirAll(workDir, 0o700); err != nil {
+			setupLog.Error(err, "unable to create working directory", "path", workDir)
+			os.Exit(1)
+		}
+		util.WorkDir = workDir
+	}
 
 	watchNamespace := ""
 	if !watchAllNamespaces {
@@ -205,11 +381,19 @@ func main() {
 	storage := mustInitStorage(storagePath, storageAdvAddr, artifactRetentionTTL, artifactRetentionRecords, setupLog)
 
 	if err = (&controllers.GitRepositoryReconciler{
-		Client:         mgr.GetClient(),
-		EventRecorder:  eventRecorder,
-		Metrics:        metricsH,
-		Storage:        storage,
-		ControllerName: controllerName,
+		Client:            mgr.GetClient(),
+		EventRecorder:     eventRecorder,
+		Metrics:           metricsH,
+		Storage:           storage,
+		ControllerName:    controllerName,
+		CloneCachePath:    gitCloneCachePath,
+		PreserveSymlinks:  artifactPreserveSymlinks,
+		MaxFileSize:       artifactMaxFileSize,
+		SkipLargeFiles:    artifactSkipLargeFiles,
+		ArtifactManifests: artifactManifests,
+		TimeSource:        timeSource,
+		FetchMaxDuration:  gitFetchMaxDuration,
+		FetchRecorder:     fetch.LogRecorder{},
 	}).SetupWithManagerAndOptions(mgr, controllers.GitRepositoryReconcilerOptions{
 		MaxConcurrentReconciles:   concurrent,
 		DependencyRequeueInterval: requeueDependency,
@@ -225,6 +409,7 @@ func main() {
 		Storage:        storage,
 		Getters:        getters,
 		ControllerName: controllerName,
+		FetchRecorder:  fetch.LogRecorder{},
 	}).SetupWithManagerAndOptions(mgr, controllers.HelmRepositoryReconcilerOptions{
 		MaxConcurrentReconciles: concurrent,
 		RateLimiter:             helper.GetRateLimiter(rateLimiterOptions),
@@ -240,6 +425,7 @@ func main() {
 		Getters:                 getters,
 		ControllerName:          controllerName,
 		RegistryClientGenerator: registry.ClientGenerator,
+		FetchRecorder:           fetch.LogRecorder{},
 	}).SetupWithManagerAndOptions(mgr, controllers.HelmRepositoryReconcilerOptions{
 		MaxConcurrentReconciles: concurrent,
 		RateLimiter:             helper.GetRateLimiter(rateLimiterOptions),
@@ -279,6 +465,7 @@ func main() {
 		Cache:                   c,
 		TTL:                     ttl,
 		CacheRecorder:           cacheRecorder,
+		FetchRecorder:           fetch.LogRecorder{},
 	}).SetupWithManagerAndOptions(mgr, controllers.HelmChartReconcilerOptions{
 		MaxConcurrentReconciles: concurrent,
 		RateLimiter:             helper.GetRateLimiter(rateLimiterOptions),
@@ -287,11 +474,14 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&controllers.BucketReconciler{
-		Client:         mgr.GetClient(),
-		EventRecorder:  eventRecorder,
-		Metrics:        metricsH,
-		Storage:        storage,
-		ControllerName: controllerName,
+		Client:            mgr.GetClient(),
+		EventRecorder:     eventRecorder,
+		Metrics:           metricsH,
+		Storage:           storage,
+		ControllerName:    controllerName,
+		ObjectCachePath:   bucketObjectCachePath,
+		ArtifactManifests: artifactManifests,
+		FetchRecorder:     fetch.LogRecorder{},
 	}).SetupWithManagerAndOptions(mgr, controllers.BucketReconcilerOptions{
 		MaxConcurrentReconciles: concurrent,
 		RateLimiter:             helper.GetRateLimiter(rateLimiterOptions),
@@ -311,7 +501,17 @@ func main() {
 	}()
 
 	if enabled, _ := features.Enabled(features.GitManagedTransport); enabled {
-		managed.InitManagedTransport()
+		if err := managed.InitManagedTransport(); err != nil {
+			setupLog.Error(err, "unable to initialize managed transport")
+			os.Exit(1)
+		}
+		if err := managed.Ready(); err != nil {
+			setupLog.Error(err, "managed transport is not ready")
+			os.Exit(1)
+		}
+		info := managed.BuildInfo()
+		setupLog.Info("initialized managed transport", "libgit2", info.Version,
+			"threads", info.Threads, "https", info.HTTPS, "ssh", info.SSH)
 	} else {
 		if optimize, _ := feathelper.Enabled(features.OptimizedGitClones); optimize {
 			features.Disable(features.OptimizedGitClones)
@@ -322,7 +522,7 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifact
+
+import (
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"io"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// MismatchError is returned by VerifyArtifact when the digest of the
+// content does not match the expected digest.
+type MismatchError struct {
+	// Expected is the expected digest.
+	Expected string
+	// Actual is the digest calculated over the content.
+	Actual string
+}
+
+// Error returns the error string.
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("digest mismatch: expected '%s', got '%s'", e.Expected, e.Actual)
+}
+
+// VerifyArtifact reads all the content from the given io.Reader, and verifies
+// its digest against the expected digest in the form of
+// '<algorithm>:<checksum>', using the algorithm from the prefix.
+// It returns a MismatchError if the digests are not equal, or an error
+// wrapping digest.ErrDigestUnsupported if the algorithm is not supported.
+func VerifyArtifact(r io.Reader, expected string) error {
+	d, err := digest.Parse(expected)
+	if err != nil {
+		return fmt.Errorf("failed to parse expected digest '%s': %w", expected, err)
+	}
+
+	h := d.Algorithm().Hash()
+	if _, err := io.Copy(h, r); err != nil {
+		return fmt.Errorf("failed to calculate digest: %w", err)
+	}
+	actual := digest.NewDigest(d.Algorithm(), h)
+
+	if subtle.ConstantTimeCompare([]byte(actual.Encoded()), []byte(d.Encoded())) != 1 {
+		return &MismatchError{Expected: d.String(), Actual: actual.String()}
+	}
+	return nil
+}
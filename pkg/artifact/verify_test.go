@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifact
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestVerifyArtifact(t *testing.T) {
+	content := "that's all folks!"
+
+	tests := []struct {
+		name         string
+		content      string
+		expected     string
+		wantErr      string
+		wantMismatch bool
+		wantErrIs    error
+	}{
+		{
+			name:     "matching sha256 digest",
+			content:  content,
+			expected: "sha256:afa7955ec925a359a4f8597b2786b673d86835dabe90f5d41628a8933500dc38",
+		},
+		{
+			name:     "matching sha512 digest",
+			content:  content,
+			expected: "sha512:d6ebe13c516432188b963b9f767c7fe785cee0c1526523c8028e27c53b3f4a35d7404aed41c2d27149a1df18a92c77ceabf767286bfe361c32b4513901335371",
+		},
+		{
+			name:         "mismatching digest",
+			content:      "that's not all folks!",
+			expected:     "sha256:afa7955ec925a359a4f8597b2786b673d86835dabe90f5d41628a8933500dc38",
+			wantErr:      "digest mismatch",
+			wantMismatch: true,
+		},
+		{
+			name:      "unsupported algorithm",
+			content:   content,
+			expected:  "md5:ee87dc70f6ff8fce2d5aabd3a8a1e2a0",
+			wantErr:   "unsupported digest algorithm",
+			wantErrIs: digest.ErrDigestUnsupported,
+		},
+		{
+			name:      "invalid format",
+			content:   content,
+			expected:  "afa7955ec925a359a4f8597b2786b673d86835dabe90f5d41628a8933500dc38",
+			wantErr:   "invalid checksum digest format",
+			wantErrIs: digest.ErrDigestInvalidFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := VerifyArtifact(strings.NewReader(tt.content), tt.expected)
+			if tt.wantErr == "" {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+
+			var mismatchErr *MismatchError
+			g.Expect(errors.As(err, &mismatchErr)).To(Equal(tt.wantMismatch))
+			if tt.wantMismatch {
+				g.Expect(mismatchErr.Expected).To(Equal(tt.expected))
+			}
+			if tt.wantErrIs != nil {
+				g.Expect(errors.Is(err, tt.wantErrIs)).To(BeTrue())
+			}
+		})
+	}
+}
@@ -52,6 +52,7 @@ const (
 	clientCertificateSendChainField = "clientCertificateSendChain"
 	authorityHostField              = "authorityHost"
 	accountKeyField                 = "accountKey"
+	sasKeyField                     = "sasKey"
 )
 
 // BlobClient is a minimal Azure Blob client for fetching objects.
@@ -74,8 +75,10 @@ type BlobClient struct {
 //  - azblob.SharedKeyCredential when an `accountKey` field is found.
 //    The account name is extracted from the endpoint specified on the Bucket
 //    object.
-//  - azidentity.ChainedTokenCredential with azidentity.EnvironmentCredential
-//    and azidentity.ManagedIdentityCredential.
+//  - A client without credentials for the endpoint with the Shared Access
+//    Signature token from the `sasKey` field added to its query, when found.
+//  - azidentity.ChainedTokenCredential with a workload identity credential,
+//    azidentity.EnvironmentCredential and azidentity.ManagedIdentityCredential.
 //
 // If no credentials are found, and the azidentity.ChainedTokenCredential can
 // not be established. A simple client without credentials is returned.
@@ -104,6 +107,16 @@ func NewClient(obj *sourcev1.Bucket, secret *corev1.Secret) (c *BlobClient, err
 			c.ServiceClient, err = azblob.NewServiceClientWithSharedKey(obj.Spec.Endpoint, cred, &azblob.ClientOptions{})
 			return
 		}
+
+		// Fallback to Shared Access Signature token.
+		var sasURL string
+		if sasURL, err = sasURLFromSecret(obj.Spec.Endpoint, secret); err != nil {
+			return
+		}
+		if sasURL != "" {
+			c.ServiceClient, err = azblob.NewServiceClientWithNoCredential(sasURL, nil)
+			return
+		}
 	}
 
 	// Compose token chain based on environment.
@@ -151,10 +164,13 @@ func ValidateSecret(secret *corev1.Secret) error {
 	if _, hasAuthorityHost := secret.Data[authorityHostField]; hasAuthorityHost {
 		valid = true
 	}
+	if _, hasSASKey := secret.Data[sasKeyField]; hasSASKey {
+		valid = true
+	}
 
 	if !valid {
-		return fmt.Errorf("invalid '%s' secret data: requires a '%s' or '%s' field, a combination of '%s', '%s' and '%s', or '%s', '%s' and '%s'",
-			secret.Name, clientIDField, accountKeyField, tenantIDField, clientIDField, clientSecretField, tenantIDField, clientIDField, clientCertificateField)
+		return fmt.Errorf("invalid '%s' secret data: requires a '%s', '%s' or '%s' field, a combination of '%s', '%s' and '%s', or '%s', '%s' and '%s'",
+			secret.Name, clientIDField, accountKeyField, sasKeyField, tenantIDField, clientIDField, clientSecretField, tenantIDField, clientIDField, clientCertificateField)
 	}
 	return nil
 }
@@ -241,18 +257,27 @@ func (c *BlobClient) FGetObject(ctx context.Context, bucketName, objectName, loc
 }
 
 // VisitObjects iterates over the items in the provided object storage
-// bucket, calling visit for every item.
+// bucket, calling visit for every item with the given prefix, with its size
+// in bytes.
 // If the underlying client or the visit callback returns an error,
 // it returns early.
-func (c *BlobClient) VisitObjects(ctx context.Context, bucketName string, visit func(path, etag string) error) error {
+func (c *BlobClient) VisitObjects(ctx context.Context, bucketName string, prefix string, visit func(path, etag string, size int64) error) error {
 	container := c.ServiceClient.NewContainerClient(bucketName)
 
-	items := container.ListBlobsFlat(&azblob.ContainerListBlobFlatSegmentOptions{})
+	opts := &azblob.ContainerListBlobFlatSegmentOptions{}
+	if prefix != "" {
+		opts.Prefix = &prefix
+	}
+	items := container.ListBlobsFlat(opts)
 	for items.NextPage(ctx) {
 		resp := items.PageResponse()
 
 		for _, blob := range resp.ContainerListBlobFlatSegmentResult.Segment.BlobItems {
-			if err := visit(*blob.Name, fmt.Sprintf("%x", *blob.Properties.Etag)); err != nil {
+			var size int64
+			if blob.Properties.ContentLength != nil {
+				size = *blob.Properties.ContentLength
+			}
+			if err := visit(*blob.Name, fmt.Sprintf("%x", *blob.Properties.Etag), size); err != nil {
 				err = fmt.Errorf("listing objects from bucket '%s' failed: %w", bucketName, err)
 				return err
 			}
@@ -343,10 +368,40 @@ func sharedCredentialFromSecret(endpoint string, secret *corev1.Secret) (*azblob
 	return nil, nil
 }
 
+// sasURLFromSecret attempts to return the endpoint with the Shared Access
+// Signature token from the `sasKey` field of the given Secret merged into its
+// query. It returns an empty string if the Secret does not contain the field.
+func sasURLFromSecret(endpoint string, secret *corev1.Secret) (string, error) {
+	sasKey, hasSASKey := secret.Data[sasKeyField]
+	if !hasSASKey {
+		return "", nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse endpoint for SAS token from '%s' Secret: %w", secret.Name, err)
+	}
+	values, err := url.ParseQuery(strings.TrimPrefix(string(sasKey), "?"))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse SAS token from '%s' Secret: %w", secret.Name, err)
+	}
+	if len(values) == 0 {
+		return "", fmt.Errorf("invalid SAS token in '%s' Secret: no query parameters found", secret.Name)
+	}
+	query := u.Query()
+	for k, v := range values {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
 // chainCredentialWithSecret tries to create a set of tokens, and returns an
 // azidentity.ChainedTokenCredential if at least one of the following tokens was
 // successfully created:
 //
+// - workloadIdentityCredential with `authorityHost` from Secret, if provided,
+//   when the Workload Identity environment variables are found.
 // - azidentity.EnvironmentCredential with `authorityHost` from Secret, if
 //   provided.
 // - azidentity.ManagedIdentityCredential with Client ID from AZURE_CLIENT_ID
@@ -364,6 +419,10 @@ func chainCredentialWithSecret(secret *corev1.Secret) (azcore.TokenCredential, e
 		}
 	}
 
+	if token := workloadIdentityCredentialFromEnv(string(credOpts.AuthorityHost)); token != nil {
+		creds = append(creds, token)
+	}
+
 	if token, _ := azidentity.NewEnvironmentCredential(credOpts); token != nil {
 		creds = append(creds, token)
 	}
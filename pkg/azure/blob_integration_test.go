@@ -219,7 +219,7 @@ func TestBlobClient_VisitObjects(t *testing.T) {
 	// Visit objects.
 	ctx, timeout = context.WithTimeout(context.Background(), testTimeout)
 	defer timeout()
-	got := client.VisitObjects(ctx, testContainer, func(path, etag string) error {
+	got := client.VisitObjects(ctx, testContainer, "", func(path, etag string, _ int64) error {
 		visits[path] = etag
 		return nil
 	})
@@ -257,7 +257,7 @@ func TestBlobClient_VisitObjects_CallbackErr(t *testing.T) {
 	ctx, timeout = context.WithTimeout(context.Background(), testTimeout)
 	defer timeout()
 	mockErr := fmt.Errorf("mock")
-	err = client.VisitObjects(ctx, testContainer, func(path, etag string) error {
+	err = client.VisitObjects(ctx, testContainer, "", func(path, etag string, _ int64) error {
 		return mockErr
 	})
 	g.Expect(err).To(HaveOccurred())
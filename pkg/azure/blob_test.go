@@ -18,6 +18,8 @@ package azure
 
 import (
 	"bytes"
+	"context"
+	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -25,13 +27,22 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 )
 
 func TestValidateSecret(t *testing.T) {
@@ -76,6 +87,14 @@ func TestValidateSecret(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "valid SAS Secret",
+			secret: &corev1.Secret{
+				Data: map[string][]byte{
+					sasKeyField: []byte("?sv=2020-08-04&ss=b&sig=signature"),
+				},
+			},
+		},
 		{
 			name: "valid AuthorityHost Secret",
 			secret: &corev1.Secret{
@@ -292,6 +311,69 @@ func Test_sharedCredentialFromSecret(t *testing.T) {
 	}
 }
 
+func Test_sasURLFromSecret(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		secret   *corev1.Secret
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "SAS token with question mark",
+			endpoint: endpointURL("foo"),
+			secret: &corev1.Secret{
+				Data: map[string][]byte{
+					sasKeyField: []byte("?sv=2020-08-04&ss=b&sig=signature"),
+				},
+			},
+			want: "https://foo.blob.core.windows.net?sig=signature&ss=b&sv=2020-08-04",
+		},
+		{
+			name:     "SAS token merged with endpoint query",
+			endpoint: endpointURL("foo") + "/?foo=bar",
+			secret: &corev1.Secret{
+				Data: map[string][]byte{
+					sasKeyField: []byte("sv=2020-08-04&sig=signature"),
+				},
+			},
+			want: "https://foo.blob.core.windows.net/?foo=bar&sig=signature&sv=2020-08-04",
+		},
+		{
+			name:     "empty SAS token",
+			endpoint: endpointURL("foo"),
+			secret: &corev1.Secret{
+				Data: map[string][]byte{
+					sasKeyField: []byte(""),
+				},
+			},
+			wantErr: "no query parameters found",
+		},
+		{
+			name:     "no SAS token",
+			endpoint: endpointURL("foo"),
+			secret: &corev1.Secret{
+				Data: map[string][]byte{},
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := sasURLFromSecret(tt.endpoint, tt.secret)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
 func Test_chainCredentialWithSecret(t *testing.T) {
 	g := NewWithT(t)
 
@@ -339,6 +421,164 @@ func Test_extractAccountNameFromEndpoint1(t *testing.T) {
 	}
 }
 
+// stubSASSecret is a Secret with a SAS token accepted by newBlobStubServer.
+var stubSASSecret = &corev1.Secret{
+	Data: map[string][]byte{
+		sasKeyField: []byte("?sv=2020-08-04&ss=b&srt=co&sp=rl&sig=stub"),
+	},
+}
+
+// newBlobStubServer returns a httptest.Server which serves the given blobs
+// in the given container in the same way as the Azurite emulator, for the
+// "devstoreaccount1" account. Requests without the SAS token of
+// stubSASSecret are rejected.
+func newBlobStubServer(containerName string, blobs map[string]string) *httptest.Server {
+	lastModified := time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	etag := func(name string) string {
+		return fmt.Sprintf("0x8DA%X", md5.Sum([]byte(blobs[name])))[:17]
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "stub" {
+			w.Header().Set("x-ms-error-code", string(azblob.StorageErrorCodeAuthenticationFailed))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+		if len(parts) < 2 || parts[0] != "devstoreaccount1" || parts[1] != containerName {
+			w.Header().Set("x-ms-error-code", string(azblob.StorageErrorCodeContainerNotFound))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		// List blobs in container.
+		if len(parts) == 2 && r.URL.Query().Get("comp") == "list" {
+			prefix := r.URL.Query().Get("prefix")
+			var names []string
+			for name := range blobs {
+				if strings.HasPrefix(name, prefix) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+
+			var b strings.Builder
+			b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+			fmt.Fprintf(&b, `<EnumerationResults ServiceEndpoint="http://%s/devstoreaccount1" ContainerName="%s">`, r.Host, containerName)
+			fmt.Fprintf(&b, `<Prefix>%s</Prefix><Blobs>`, prefix)
+			for _, name := range names {
+				fmt.Fprintf(&b, `<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified><Etag>%s</Etag><Content-Length>%d</Content-Length><BlobType>BlockBlob</BlobType></Properties></Blob>`,
+					name, lastModified, etag(name), len(blobs[name]))
+			}
+			b.WriteString(`</Blobs><NextMarker /></EnumerationResults>`)
+
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(b.String()))
+			return
+		}
+
+		// Download blob.
+		if len(parts) == 3 && r.Method == http.MethodGet {
+			data, ok := blobs[parts[2]]
+			if !ok {
+				w.Header().Set("x-ms-error-code", string(azblob.StorageErrorCodeBlobNotFound))
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("ETag", etag(parts[2]))
+			w.Header().Set("Last-Modified", lastModified)
+			w.Header().Set("x-ms-blob-type", "BlockBlob")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(data))
+			return
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+}
+
+func TestBlobClient_VisitObjects_stub(t *testing.T) {
+	blobs := map[string]string{
+		"test.yaml":          "test: file",
+		"dir/test.yaml":      "test: dir/file",
+		"dir/nested/t2.yaml": "test: dir/nested/file",
+	}
+	server := newBlobStubServer("container", blobs)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{
+			name: "without prefix",
+			want: []string{"dir/nested/t2.yaml", "dir/test.yaml", "test.yaml"},
+		},
+		{
+			name:   "with prefix",
+			prefix: "dir/",
+			want:   []string{"dir/nested/t2.yaml", "dir/test.yaml"},
+		},
+		{
+			name:   "with prefix without matches",
+			prefix: "other/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			client, err := NewClient(&sourcev1.Bucket{
+				Spec: sourcev1.BucketSpec{Endpoint: server.URL + "/devstoreaccount1"},
+			}, stubSASSecret)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var got []string
+			err = client.VisitObjects(context.TODO(), "container", tt.prefix, func(path, etag string, size int64) error {
+				g.Expect(etag).ToNot(BeEmpty())
+				g.Expect(size).To(BeEquivalentTo(len(blobs[path])))
+				got = append(got, path)
+				return nil
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestBlobClient_FGetObject_stub(t *testing.T) {
+	blobs := map[string]string{
+		"dir/test.yaml": "test: dir/file",
+	}
+	server := newBlobStubServer("container", blobs)
+	defer server.Close()
+
+	g := NewWithT(t)
+
+	client, err := NewClient(&sourcev1.Bucket{
+		Spec: sourcev1.BucketSpec{Endpoint: server.URL + "/devstoreaccount1"},
+	}, stubSASSecret)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	localPath := filepath.Join(t.TempDir(), "dir", "test.yaml")
+	etag, err := client.FGetObject(context.TODO(), "container", "dir/test.yaml", localPath)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(etag).ToNot(BeEmpty())
+
+	data, err := os.ReadFile(localPath)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(Equal(blobs["dir/test.yaml"]))
+
+	_, err = client.FGetObject(context.TODO(), "container", "missing.yaml", filepath.Join(t.TempDir(), "missing.yaml"))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(client.ObjectIsNotFound(err)).To(BeTrue())
+}
+
 func endpointURL(accountName string) string {
 	return fmt.Sprintf("https://%s.blob.core.windows.net", accountName)
 }
@@ -0,0 +1,155 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// defaultAuthorityHost is the Azure Active Directory authority host used
+	// when none is configured.
+	defaultAuthorityHost = "https://login.microsoftonline.com/"
+
+	// Environment variables injected by the Azure Workload Identity webhook.
+	federatedTokenFileEnv = "AZURE_FEDERATED_TOKEN_FILE"
+	clientIDEnv           = "AZURE_CLIENT_ID"
+	tenantIDEnv           = "AZURE_TENANT_ID"
+	authorityHostEnv      = "AZURE_AUTHORITY_HOST"
+
+	// tokenRefreshSkew is the duration before the expiry of a token at which
+	// a new token is requested.
+	tokenRefreshSkew = 2 * time.Minute
+)
+
+// workloadIdentityCredential is an azcore.TokenCredential which exchanges a
+// federated service account token for an Azure Active Directory access
+// token, as used by Azure Workload Identity.
+type workloadIdentityCredential struct {
+	authorityHost string
+	tenantID      string
+	clientID      string
+	tokenFile     string
+	httpClient    *http.Client
+	clock         clock.PassiveClock
+
+	mu     sync.Mutex
+	tokens map[string]*azcore.AccessToken
+}
+
+// workloadIdentityCredentialFromEnv returns a workloadIdentityCredential
+// configured using the environment variables set by the Azure Workload
+// Identity webhook, or nil if any of them is missing. The given authority
+// host takes precedence over the one from the environment.
+func workloadIdentityCredentialFromEnv(authorityHost string) *workloadIdentityCredential {
+	tokenFile, clientID, tenantID := os.Getenv(federatedTokenFileEnv), os.Getenv(clientIDEnv), os.Getenv(tenantIDEnv)
+	if tokenFile == "" || clientID == "" || tenantID == "" {
+		return nil
+	}
+	if authorityHost == "" {
+		authorityHost = os.Getenv(authorityHostEnv)
+	}
+	if authorityHost == "" {
+		authorityHost = defaultAuthorityHost
+	}
+	return &workloadIdentityCredential{
+		authorityHost: authorityHost,
+		tenantID:      tenantID,
+		clientID:      clientID,
+		tokenFile:     tokenFile,
+		httpClient:    http.DefaultClient,
+		clock:         clock.RealClock{},
+		tokens:        make(map[string]*azcore.AccessToken),
+	}
+}
+
+// GetToken returns an access token for the scopes of the given options,
+// reusing a previously obtained token until it is about to expire.
+func (c *workloadIdentityCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (*azcore.AccessToken, error) {
+	scope := strings.Join(opts.Scopes, " ")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t, ok := c.tokens[scope]; ok && c.clock.Now().Add(tokenRefreshSkew).Before(t.ExpiresOn) {
+		return t, nil
+	}
+	t, err := c.requestToken(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	c.tokens[scope] = t
+	return t, nil
+}
+
+// requestToken exchanges the federated token for an access token for the
+// given scope.
+func (c *workloadIdentityCredential) requestToken(ctx context.Context, scope string) (*azcore.AccessToken, error) {
+	assertion, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read federated token: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+	form.Set("client_id", c.clientID)
+	form.Set("scope", scope)
+
+	endpoint := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(c.authorityHost, "/"), url.PathEscape(c.tenantID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request workload identity token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode workload identity token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return nil, fmt.Errorf("failed to request workload identity token (status %d): %s: %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	return &azcore.AccessToken{
+		Token:     body.AccessToken,
+		ExpiresOn: c.clock.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func Test_workloadIdentityCredentialFromEnv(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		authorityHost string
+		wantNil       bool
+		wantAuthority string
+	}{
+		{
+			name: "all variables set",
+			env: map[string]string{
+				federatedTokenFileEnv: "/var/run/secrets/token",
+				clientIDEnv:           "client-id",
+				tenantIDEnv:           "tenant-id",
+			},
+			wantAuthority: defaultAuthorityHost,
+		},
+		{
+			name: "authority host from environment",
+			env: map[string]string{
+				federatedTokenFileEnv: "/var/run/secrets/token",
+				clientIDEnv:           "client-id",
+				tenantIDEnv:           "tenant-id",
+				authorityHostEnv:      "https://env.example.com/",
+			},
+			wantAuthority: "https://env.example.com/",
+		},
+		{
+			name: "authority host argument takes precedence",
+			env: map[string]string{
+				federatedTokenFileEnv: "/var/run/secrets/token",
+				clientIDEnv:           "client-id",
+				tenantIDEnv:           "tenant-id",
+				authorityHostEnv:      "https://env.example.com/",
+			},
+			authorityHost: "https://secret.example.com/",
+			wantAuthority: "https://secret.example.com/",
+		},
+		{
+			name: "missing token file",
+			env: map[string]string{
+				clientIDEnv: "client-id",
+				tenantIDEnv: "tenant-id",
+			},
+			wantNil: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			for _, k := range []string{federatedTokenFileEnv, clientIDEnv, tenantIDEnv, authorityHostEnv} {
+				t.Setenv(k, tt.env[k])
+			}
+
+			got := workloadIdentityCredentialFromEnv(tt.authorityHost)
+			if tt.wantNil {
+				g.Expect(got).To(BeNil())
+				return
+			}
+			g.Expect(got).ToNot(BeNil())
+			g.Expect(got.authorityHost).To(Equal(tt.wantAuthority))
+		})
+	}
+}
+
+func Test_workloadIdentityCredential_GetToken(t *testing.T) {
+	g := NewWithT(t)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	g.Expect(os.WriteFile(tokenFile, []byte("federated-token\n"), 0o600)).To(Succeed())
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/tenant-id/oauth2/v2.0/token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = r.ParseForm()
+		if r.Form.Get("client_assertion") != "federated-token" || r.Form.Get("client_id") != "client-id" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"invalid assertion"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"token-for-%s","expires_in":3600}`, r.Form.Get("scope"))
+	}))
+	defer server.Close()
+
+	t.Setenv(federatedTokenFileEnv, tokenFile)
+	t.Setenv(clientIDEnv, "client-id")
+	t.Setenv(tenantIDEnv, "tenant-id")
+	cred := workloadIdentityCredentialFromEnv(server.URL + "/")
+	g.Expect(cred).ToNot(BeNil())
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	cred.clock = fakeClock
+
+	opts := policy.TokenRequestOptions{Scopes: []string{"https://storage.azure.com/.default"}}
+	token, err := cred.GetToken(context.TODO(), opts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token.Token).To(Equal("token-for-https://storage.azure.com/.default"))
+
+	// Cached token is reused.
+	_, err = cred.GetToken(context.TODO(), opts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(requests).To(Equal(1))
+
+	// Cached token is reused until it is about to expire.
+	fakeClock.Step(time.Hour - tokenRefreshSkew - time.Second)
+	_, err = cred.GetToken(context.TODO(), opts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(requests).To(Equal(1))
+	fakeClock.Step(time.Second)
+	_, err = cred.GetToken(context.TODO(), opts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(requests).To(Equal(2))
+
+	// Invalid assertion results in an error.
+	g.Expect(os.WriteFile(tokenFile, []byte("other-token"), 0o600)).To(Succeed())
+	_, err = cred.GetToken(context.TODO(), policy.TokenRequestOptions{Scopes: []string{"other"}})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("invalid assertion"))
+}
@@ -69,15 +69,35 @@ func NewClient(ctx context.Context, secret *corev1.Secret) (*GCSClient, error) {
 }
 
 // ValidateSecret validates the credential secret. The provided Secret may
-// be nil.
+// be nil. The Secret must either contain a 'serviceaccount', or HMAC keys
+// as 'accesskey' and 'secretkey'.
 func ValidateSecret(secret *corev1.Secret) error {
 	if secret == nil {
 		return nil
 	}
-	if _, exists := secret.Data["serviceaccount"]; !exists {
-		return fmt.Errorf("invalid '%s' secret data: required fields 'serviceaccount'", secret.Name)
+	if _, exists := secret.Data["serviceaccount"]; exists {
+		return nil
 	}
-	return nil
+	if HasHMACKeys(secret) {
+		return nil
+	}
+	return fmt.Errorf("invalid '%s' secret data: required fields 'serviceaccount', or 'accesskey' and 'secretkey'", secret.Name)
+}
+
+// HasHMACKeys returns if the provided Secret contains an HMAC 'accesskey'
+// and 'secretkey', without a 'serviceaccount'. HMAC keys authenticate
+// against the S3-compatible XML API of Google Cloud Storage, or a gateway
+// serving the same API.
+func HasHMACKeys(secret *corev1.Secret) bool {
+	if secret == nil {
+		return false
+	}
+	if _, ok := secret.Data["serviceaccount"]; ok {
+		return false
+	}
+	_, hasAccessKey := secret.Data["accesskey"]
+	_, hasSecretKey := secret.Data["secretkey"]
+	return hasAccessKey && hasSecretKey
 }
 
 // BucketExists returns if an object storage bucket with the provided name
@@ -162,11 +182,14 @@ func (c *GCSClient) FGetObject(ctx context.Context, bucketName, objectName, loca
 }
 
 // VisitObjects iterates over the items in the provided object storage
-// bucket, calling visit for every item.
+// bucket, calling visit for every item with the given prefix, with its size
+// in bytes.
 // If the underlying client or the visit callback returns an error,
 // it returns early.
-func (c *GCSClient) VisitObjects(ctx context.Context, bucketName string, visit func(path, etag string) error) error {
-	items := c.Client.Bucket(bucketName).Objects(ctx, nil)
+func (c *GCSClient) VisitObjects(ctx context.Context, bucketName string, prefix string, visit func(path, etag string, size int64) error) error {
+	items := c.Client.Bucket(bucketName).Objects(ctx, &gcpstorage.Query{
+		Prefix: prefix,
+	})
 	for {
 		object, err := items.Next()
 		if err == IteratorDone {
@@ -176,7 +199,7 @@ func (c *GCSClient) VisitObjects(ctx context.Context, bucketName string, visit f
 			err = fmt.Errorf("listing objects from bucket '%s' failed: %w", bucketName, err)
 			return err
 		}
-		if err = visit(object.Name, object.Etag); err != nil {
+		if err = visit(object.Name, object.Etag, object.Size); err != nil {
 			return err
 		}
 	}
@@ -73,6 +73,17 @@ var (
 		},
 		Type: "Opaque",
 	}
+	hmacSecret = corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "gcp-hmac-secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"accesskey": []byte("GOOG1EXAMPLEACCESSKEY"),
+			"secretkey": []byte("example-secret-key"),
+		},
+		Type: "Opaque",
+	}
 )
 
 func TestMain(m *testing.M) {
@@ -169,14 +180,17 @@ func TestVisitObjects(t *testing.T) {
 	}
 	keys := []string{}
 	etags := []string{}
-	err := gcpClient.VisitObjects(context.Background(), bucketName, func(key, etag string) error {
+	sizes := []int64{}
+	err := gcpClient.VisitObjects(context.Background(), bucketName, "", func(key, etag string, size int64) error {
 		keys = append(keys, key)
 		etags = append(etags, etag)
+		sizes = append(sizes, size)
 		return nil
 	})
 	assert.NilError(t, err)
 	assert.DeepEqual(t, keys, []string{objectName})
 	assert.DeepEqual(t, etags, []string{objectEtag})
+	assert.DeepEqual(t, sizes, []int64{1 << 20})
 }
 
 func TestVisitObjectsErr(t *testing.T) {
@@ -184,7 +198,7 @@ func TestVisitObjectsErr(t *testing.T) {
 		Client: client,
 	}
 	badBucketName := "bad-bucket"
-	err := gcpClient.VisitObjects(context.Background(), badBucketName, func(key, etag string) error {
+	err := gcpClient.VisitObjects(context.Background(), badBucketName, "", func(key, etag string, _ int64) error {
 		return nil
 	})
 	assert.Error(t, err, fmt.Sprintf("listing objects from bucket '%s' failed: storage: bucket doesn't exist", badBucketName))
@@ -195,7 +209,7 @@ func TestVisitObjectsCallbackErr(t *testing.T) {
 		Client: client,
 	}
 	mockErr := fmt.Errorf("mock")
-	err := gcpClient.VisitObjects(context.Background(), bucketName, func(key, etag string) error {
+	err := gcpClient.VisitObjects(context.Background(), bucketName, "", func(key, etag string, _ int64) error {
 		return mockErr
 	})
 	assert.Error(t, err, mockErr.Error())
@@ -250,11 +264,23 @@ func TestValidateSecret(t *testing.T) {
 			name:   "valid secret",
 			secret: secret.DeepCopy(),
 		},
+		{
+			name:   "valid HMAC secret",
+			secret: hmacSecret.DeepCopy(),
+		},
 		{
 			name:   "invalid secret",
 			secret: badSecret.DeepCopy(),
 			error:  true,
 		},
+		{
+			name: "HMAC secret without secret key",
+			secret: &corev1.Secret{
+				ObjectMeta: v1.ObjectMeta{Name: "gcp-hmac-secret"},
+				Data:       map[string][]byte{"accesskey": []byte("GOOG1EXAMPLEACCESSKEY")},
+			},
+			error: true,
+		},
 	}
 	for _, testCase := range testCases {
 		tt := testCase
@@ -262,7 +288,7 @@ func TestValidateSecret(t *testing.T) {
 			t.Parallel()
 			err := ValidateSecret(tt.secret)
 			if tt.error {
-				assert.Error(t, err, fmt.Sprintf("invalid '%v' secret data: required fields 'serviceaccount'", tt.secret.Name))
+				assert.Error(t, err, fmt.Sprintf("invalid '%v' secret data: required fields 'serviceaccount', or 'accesskey' and 'secretkey'", tt.secret.Name))
 			} else {
 				assert.NilError(t, err)
 			}
@@ -270,6 +296,17 @@ func TestValidateSecret(t *testing.T) {
 	}
 }
 
+func TestHasHMACKeys(t *testing.T) {
+	withServiceAccount := hmacSecret.DeepCopy()
+	withServiceAccount.Data["serviceaccount"] = secret.Data["serviceaccount"]
+
+	assert.Check(t, HasHMACKeys(hmacSecret.DeepCopy()))
+	assert.Check(t, !HasHMACKeys(withServiceAccount))
+	assert.Check(t, !HasHMACKeys(secret.DeepCopy()))
+	assert.Check(t, !HasHMACKeys(badSecret.DeepCopy()))
+	assert.Check(t, !HasHMACKeys(nil))
+}
+
 func newTestServer(handler func(w http.ResponseWriter, r *http.Request)) (*http.Client, func()) {
 	ts := httptest.NewTLSServer(http.HandlerFunc(handler))
 	tlsConf := &tls.Config{InsecureSkipVerify: true}
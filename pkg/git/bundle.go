@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultBundleDir is the directory Git bundles may be checked out from
+// using a "file://" URL. If empty, bundle URLs are rejected.
+var DefaultBundleDir string
+
+const (
+	bundleV2Signature = "# v2 git bundle"
+	bundleV3Signature = "# v3 git bundle"
+	bundleExtension   = ".bundle"
+)
+
+// BundleReference is a reference contained in a Git bundle.
+type BundleReference struct {
+	Name string
+	Hash string
+}
+
+// BundleHeader is the header of a Git bundle, which precedes the packfile
+// with the objects of the bundle.
+type BundleHeader struct {
+	// Prerequisites are the commits the packfile is based on, which must
+	// exist in the repository the bundle is unbundled into.
+	Prerequisites []string
+	// References are the references contained in the bundle, in order of
+	// appearance.
+	References []BundleReference
+}
+
+// IsBundleURL returns if the given URL points to a local Git bundle, i.e.
+// is a "file://" URL with a path ending in ".bundle".
+func IsBundleURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, string(File)) && strings.HasSuffix(u.Path, bundleExtension)
+}
+
+// BundlePath returns the local path of the Git bundle the given URL points
+// to. It returns an error if the URL is not a bundle URL, or if the path is
+// not within DefaultBundleDir.
+func BundlePath(rawURL string) (string, error) {
+	if !IsBundleURL(rawURL) {
+		return "", fmt.Errorf("URL '%s' is not a Git bundle URL", rawURL)
+	}
+	if DefaultBundleDir == "" {
+		return "", fmt.Errorf("checkout of Git bundle '%s' is not allowed: no bundle directory configured", rawURL)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse bundle URL: %w", err)
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("bundle URL '%s' must not have a host", rawURL)
+	}
+	dir, err := filepath.Abs(DefaultBundleDir)
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Clean(filepath.FromSlash(u.Path))
+	if rel, err := filepath.Rel(dir, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("bundle '%s' is not within the bundle directory '%s'", p, dir)
+	}
+	return p, nil
+}
+
+// ReadBundleHeader reads the header of a v2 or v3 Git bundle from the given
+// reader, which is left positioned at the start of the packfile.
+func ReadBundleHeader(r *bufio.Reader) (*BundleHeader, error) {
+	line, err := readBundleLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line != bundleV2Signature && line != bundleV3Signature {
+		return nil, fmt.Errorf("invalid Git bundle: unsupported signature '%s'", line)
+	}
+
+	header := &BundleHeader{}
+	for {
+		line, err = readBundleLine(r)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case line == "":
+			if len(header.References) == 0 {
+				return nil, fmt.Errorf("invalid Git bundle: no references")
+			}
+			return header, nil
+		case strings.HasPrefix(line, "@"):
+			// Capabilities are only allowed by v3 bundles, and precede the
+			// prerequisites and references.
+			if len(header.Prerequisites) > 0 || len(header.References) > 0 {
+				return nil, fmt.Errorf("invalid Git bundle: unexpected capability '%s'", line)
+			}
+			switch capability := strings.TrimPrefix(line, "@"); capability {
+			case "object-format=sha1":
+			default:
+				return nil, fmt.Errorf("unsupported Git bundle capability '%s'", capability)
+			}
+		case strings.HasPrefix(line, "-"):
+			// The hash of a prerequisite may be followed by a comment.
+			hash := strings.SplitN(strings.TrimPrefix(line, "-"), " ", 2)[0]
+			if !isBundleHash(hash) {
+				return nil, fmt.Errorf("invalid Git bundle: malformed prerequisite '%s'", line)
+			}
+			header.Prerequisites = append(header.Prerequisites, hash)
+		default:
+			fields := strings.SplitN(line, " ", 2)
+			if len(fields) != 2 || !isBundleHash(fields[0]) || fields[1] == "" {
+				return nil, fmt.Errorf("invalid Git bundle: malformed reference '%s'", line)
+			}
+			header.References = append(header.References, BundleReference{Name: fields[1], Hash: fields[0]})
+		}
+	}
+}
+
+// VerifyPrerequisites returns an error if any of the Prerequisites is not
+// satisfied, as determined by the given function.
+func (h *BundleHeader) VerifyPrerequisites(has func(hash string) bool) error {
+	var missing []string
+	for _, p := range h.Prerequisites {
+		if !has(p) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("bundle prerequisites are not satisfied: missing commits '%s'", strings.Join(missing, "', '"))
+	}
+	return nil
+}
+
+func readBundleLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF {
+		return "", fmt.Errorf("invalid Git bundle: unexpected end of header")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read Git bundle header: %w", err)
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func isBundleHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestReadBundleHeader(t *testing.T) {
+	const (
+		hash1 = "1c3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e"
+		hash2 = "2d4f6a8c0e2a4c6e8a0c2e4a6c8e0a2c4e6a8c0e"
+	)
+
+	tests := []struct {
+		name    string
+		bundle  string
+		want    *BundleHeader
+		wantErr string
+	}{
+		{
+			name:   "v2 bundle",
+			bundle: "# v2 git bundle\n" + hash1 + " refs/heads/main\n" + hash2 + " refs/tags/v1.0.0\n\nPACK",
+			want: &BundleHeader{
+				References: []BundleReference{
+					{Name: "refs/heads/main", Hash: hash1},
+					{Name: "refs/tags/v1.0.0", Hash: hash2},
+				},
+			},
+		},
+		{
+			name:   "v2 bundle with prerequisites",
+			bundle: "# v2 git bundle\n-" + hash2 + " Some commit\n-" + hash1 + "\n" + hash1 + " HEAD\n\nPACK",
+			want: &BundleHeader{
+				Prerequisites: []string{hash2, hash1},
+				References:    []BundleReference{{Name: "HEAD", Hash: hash1}},
+			},
+		},
+		{
+			name:   "v3 bundle",
+			bundle: "# v3 git bundle\n@object-format=sha1\n" + hash1 + " refs/heads/main\n\nPACK",
+			want: &BundleHeader{
+				References: []BundleReference{{Name: "refs/heads/main", Hash: hash1}},
+			},
+		},
+		{
+			name:    "unsupported capability",
+			bundle:  "# v3 git bundle\n@filter=blob:none\n" + hash1 + " refs/heads/main\n\nPACK",
+			wantErr: "unsupported Git bundle capability 'filter=blob:none'",
+		},
+		{
+			name:    "unsupported signature",
+			bundle:  "# v4 git bundle\n" + hash1 + " refs/heads/main\n\nPACK",
+			wantErr: "invalid Git bundle: unsupported signature '# v4 git bundle'",
+		},
+		{
+			name:    "malformed reference",
+			bundle:  "# v2 git bundle\nabc refs/heads/main\n\nPACK",
+			wantErr: "invalid Git bundle: malformed reference 'abc refs/heads/main'",
+		},
+		{
+			name:    "malformed prerequisite",
+			bundle:  "# v2 git bundle\n-xyz\n" + hash1 + " refs/heads/main\n\nPACK",
+			wantErr: "invalid Git bundle: malformed prerequisite '-xyz'",
+		},
+		{
+			name:    "no references",
+			bundle:  "# v2 git bundle\n\nPACK",
+			wantErr: "invalid Git bundle: no references",
+		},
+		{
+			name:    "truncated header",
+			bundle:  "# v2 git bundle\n" + hash1 + " refs/heads/main\n",
+			wantErr: "invalid Git bundle: unexpected end of header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := bufio.NewReader(strings.NewReader(tt.bundle))
+			got, err := ReadBundleHeader(r)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(Equal(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+
+			// The reader is left at the start of the packfile.
+			rest, err := io.ReadAll(r)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(rest)).To(Equal("PACK"))
+		})
+	}
+}
+
+func TestBundleHeader_VerifyPrerequisites(t *testing.T) {
+	g := NewWithT(t)
+
+	h := &BundleHeader{Prerequisites: []string{"a", "b", "c"}}
+	has := func(hash string) bool { return hash == "b" }
+	g.Expect(h.VerifyPrerequisites(has)).To(MatchError("bundle prerequisites are not satisfied: missing commits 'a', 'c'"))
+	g.Expect((&BundleHeader{}).VerifyPrerequisites(has)).To(Succeed())
+}
+
+func TestIsBundleURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "file:///bundles/repo.bundle", want: true},
+		{url: "FILE:///bundles/repo.bundle", want: true},
+		{url: "file:///bundles/repo.git", want: false},
+		{url: "https://example.com/repo.bundle", want: false},
+		{url: "ssh://git@example.com/repo.bundle", want: false},
+		{url: "/bundles/repo.bundle", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(IsBundleURL(tt.url)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestBundlePath(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name      string
+		bundleDir string
+		url       string
+		want      string
+		wantErr   string
+	}{
+		{
+			name:      "within bundle directory",
+			bundleDir: dir,
+			url:       "file://" + filepath.ToSlash(filepath.Join(dir, "nested", "repo.bundle")),
+			want:      filepath.Join(dir, "nested", "repo.bundle"),
+		},
+		{
+			name:      "localhost",
+			bundleDir: dir,
+			url:       "file://localhost" + filepath.ToSlash(filepath.Join(dir, "repo.bundle")),
+			want:      filepath.Join(dir, "repo.bundle"),
+		},
+		{
+			name:    "no bundle directory",
+			url:     "file://" + filepath.ToSlash(filepath.Join(dir, "repo.bundle")),
+			wantErr: "no bundle directory configured",
+		},
+		{
+			name:      "outside bundle directory",
+			bundleDir: filepath.Join(dir, "bundles"),
+			url:       "file://" + filepath.ToSlash(filepath.Join(dir, "bundles", "..", "repo.bundle")),
+			wantErr:   "is not within the bundle directory",
+		},
+		{
+			name:      "remote host",
+			bundleDir: dir,
+			url:       "file://example.com/repo.bundle",
+			wantErr:   "must not have a host",
+		},
+		{
+			name:      "not a bundle",
+			bundleDir: dir,
+			url:       "https://example.com/repo.bundle",
+			wantErr:   "is not a Git bundle URL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			defer func(dir string) { DefaultBundleDir = dir }(DefaultBundleDir)
+			DefaultBundleDir = tt.bundleDir
+
+			got, err := BundlePath(tt.url)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
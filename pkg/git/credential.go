@@ -0,0 +1,155 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultCredentialHelper is the path to the git credential helper set as
+// the CredentialHelper of the AuthOptions constructed from a Secret which
+// opts in to it with a 'credentialHelper' value of "true". If empty, no
+// credential helper is used.
+var DefaultCredentialHelper string
+
+// Credential is a username and password returned by a git credential
+// helper.
+type Credential struct {
+	Username string
+	Password string
+}
+
+// RunCredentialHelper obtains the credential for the given URL from the git
+// credential helper at the absolute path helper, using the "get" action of
+// the git credential helper protocol. The helper is not looked up in the
+// PATH, and is not prefixed with "git-credential-" as git does.
+func RunCredentialHelper(ctx context.Context, helper, rawURL string) (*Credential, error) {
+	if !filepath.IsAbs(helper) {
+		return nil, fmt.Errorf("credential helper path '%s' must be absolute", helper)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL for credential helper: %w", err)
+	}
+
+	var stdin bytes.Buffer
+	fmt.Fprintf(&stdin, "protocol=%s\n", u.Scheme)
+	fmt.Fprintf(&stdin, "host=%s\n", u.Host)
+	if p := strings.TrimPrefix(u.Path, "/"); p != "" {
+		fmt.Fprintf(&stdin, "path=%s\n", p)
+	}
+	stdin.WriteString("\n")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, helper, "get")
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("credential helper '%s' failed: %w: %s", helper, err, msg)
+		}
+		return nil, fmt.Errorf("credential helper '%s' failed: %w", helper, err)
+	}
+	return parseCredential(&stdout)
+}
+
+// parseCredential parses the output of a git credential helper, which
+// consists of key=value attributes on separate lines, terminated by either
+// a blank line or the end of the output. Unknown attributes are ignored.
+func parseCredential(b *bytes.Buffer) (*Credential, error) {
+	cred := &Credential{}
+	scanner := bufio.NewScanner(b)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		i := strings.Index(line, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid credential helper output: line is not in key=value format")
+		}
+		switch key, value := line[:i], line[i+1:]; key {
+		case "username":
+			cred.Username = value
+		case "password":
+			cred.Password = value
+		case "quit":
+			if value == "1" || value == "true" {
+				return nil, fmt.Errorf("credential helper requested to quit")
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read credential helper output: %w", err)
+	}
+	return cred, nil
+}
+
+// CredentialHelperCheckoutStrategy returns a CheckoutStrategy which obtains
+// the username and password for HTTP(S) URLs from the CredentialHelper of
+// the AuthOptions before delegating the checkout to the given
+// CheckoutStrategy. The helper is not invoked if the AuthOptions do not
+// configure a CredentialHelper, or already contain a password.
+func CredentialHelperCheckoutStrategy(s CheckoutStrategy) CheckoutStrategy {
+	return &credentialHelperCheckoutStrategy{strategy: s}
+}
+
+type credentialHelperCheckoutStrategy struct {
+	strategy CheckoutStrategy
+}
+
+func (c *credentialHelperCheckoutStrategy) Checkout(ctx context.Context, path, url string, opts *AuthOptions) (*Commit, error) {
+	return CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+func (c *credentialHelperCheckoutStrategy) CheckoutWithResult(ctx context.Context, path, url string, opts *AuthOptions) (*CheckoutResult, error) {
+	authOpts, err := credentialHelperAuthOptions(ctx, url, opts)
+	if err != nil {
+		return nil, err
+	}
+	return CheckoutWithResult(ctx, c.strategy, path, url, authOpts)
+}
+
+// credentialHelperAuthOptions returns a copy of the given AuthOptions with
+// the username and password for the HTTP(S) URL obtained from their
+// CredentialHelper. The AuthOptions are returned as is if they do not
+// configure a CredentialHelper, or already contain a password.
+func credentialHelperAuthOptions(ctx context.Context, url string, opts *AuthOptions) (*AuthOptions, error) {
+	if opts == nil || opts.CredentialHelper == "" || opts.Password != "" ||
+		(opts.Transport != HTTP && opts.Transport != HTTPS) {
+		return opts, nil
+	}
+
+	cred, err := RunCredentialHelper(ctx, opts.CredentialHelper, url)
+	if err != nil {
+		return nil, err
+	}
+	authOpts := *opts
+	if cred.Username != "" {
+		authOpts.Username = cred.Username
+	}
+	authOpts.Password = cred.Password
+	return &authOpts, nil
+}
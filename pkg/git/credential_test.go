@@ -0,0 +1,208 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// writeCredentialHelper writes a stub credential helper script which writes
+// its arguments and input to the returned request file, and prints the given
+// output.
+func writeCredentialHelper(t *testing.T, output string, exitCode int) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	helper := filepath.Join(dir, "git-credential-stub")
+	request := filepath.Join(dir, "request")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" > " + request + "\n" +
+		"cat >> " + request + "\n" +
+		"printf '%s' '" + output + "'\n" +
+		"echo 'stub failure' >&2\n" +
+		"exit " + strconv.Itoa(exitCode) + "\n"
+	if err := os.WriteFile(helper, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return helper, request
+}
+
+func TestRunCredentialHelper(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		output      string
+		exitCode    int
+		wantRequest string
+		want        *Credential
+		wantErr     string
+	}{
+		{
+			name:        "username and password",
+			url:         "https://example.com:8443/org/repo.git",
+			output:      "username=jane\npassword=s3cr=t\n",
+			wantRequest: "get\nprotocol=https\nhost=example.com:8443\npath=org/repo.git\n\n",
+			want:        &Credential{Username: "jane", Password: "s3cr=t"},
+		},
+		{
+			name:        "password only with unknown attributes",
+			url:         "http://example.com",
+			output:      "protocol=http\nhost=example.com\npassword=token\n",
+			wantRequest: "get\nprotocol=http\nhost=example.com\n\n",
+			want:        &Credential{Password: "token"},
+		},
+		{
+			name:        "attributes after blank line are ignored",
+			url:         "https://example.com/org/repo",
+			output:      "password=token\n\npassword=ignored\n",
+			wantRequest: "get\nprotocol=https\nhost=example.com\npath=org/repo\n\n",
+			want:        &Credential{Password: "token"},
+		},
+		{
+			name:    "invalid output",
+			url:     "https://example.com/org/repo",
+			output:  "password\n",
+			wantErr: "invalid credential helper output: line is not in key=value format",
+		},
+		{
+			name:    "quit",
+			url:     "https://example.com/org/repo",
+			output:  "quit=1\n",
+			wantErr: "credential helper requested to quit",
+		},
+		{
+			name:     "non-zero exit code",
+			url:      "https://example.com/org/repo",
+			exitCode: 1,
+			wantErr:  "exit status 1: stub failure",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			helper, request := writeCredentialHelper(t, tt.output, tt.exitCode)
+			got, err := RunCredentialHelper(context.TODO(), helper, tt.url)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(got).To(BeNil())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+			g.Expect(os.ReadFile(request)).To(BeEquivalentTo(tt.wantRequest))
+		})
+	}
+}
+
+func TestRunCredentialHelper_relativePath(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := RunCredentialHelper(context.TODO(), "git-credential-stub", "https://example.com/org/repo")
+	g.Expect(err).To(MatchError("credential helper path 'git-credential-stub' must be absolute"))
+}
+
+func Test_parseCredential(t *testing.T) {
+	g := NewWithT(t)
+
+	got, err := parseCredential(bytes.NewBufferString("username=jane\r\npassword=\r\n"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal(&Credential{Username: "jane"}))
+
+	_, err = parseCredential(bytes.NewBufferString("=value\n"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+type authOptionsCheckoutStrategy struct {
+	opts *AuthOptions
+}
+
+func (s *authOptionsCheckoutStrategy) Checkout(_ context.Context, _, _ string, opts *AuthOptions) (*Commit, error) {
+	s.opts = opts
+	return &Commit{}, nil
+}
+
+func TestCredentialHelperCheckoutStrategy(t *testing.T) {
+	helper, _ := writeCredentialHelper(t, "username=jane\npassword=token\n", 0)
+
+	tests := []struct {
+		name string
+		opts *AuthOptions
+		want *AuthOptions
+	}{
+		{
+			name: "HTTPS without password",
+			opts: &AuthOptions{Transport: HTTPS, Username: "git", CredentialHelper: helper},
+			want: &AuthOptions{Transport: HTTPS, Username: "jane", Password: "token", CredentialHelper: helper},
+		},
+		{
+			name: "HTTPS with password",
+			opts: &AuthOptions{Transport: HTTPS, Username: "john", Password: "secret", CredentialHelper: helper},
+			want: &AuthOptions{Transport: HTTPS, Username: "john", Password: "secret", CredentialHelper: helper},
+		},
+		{
+			name: "SSH",
+			opts: &AuthOptions{Transport: SSH, Username: "git", CredentialHelper: helper},
+			want: &AuthOptions{Transport: SSH, Username: "git", CredentialHelper: helper},
+		},
+		{
+			name: "without credential helper",
+			opts: &AuthOptions{Transport: HTTPS},
+			want: &AuthOptions{Transport: HTTPS},
+		},
+		{
+			name: "nil options",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var orig *AuthOptions
+			if tt.opts != nil {
+				o := *tt.opts
+				orig = &o
+			}
+
+			s := &authOptionsCheckoutStrategy{}
+			_, err := CredentialHelperCheckoutStrategy(s).Checkout(context.TODO(), t.TempDir(), "https://example.com/org/repo", tt.opts)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(s.opts).To(Equal(tt.want))
+			// The given options must not be mutated.
+			g.Expect(tt.opts).To(Equal(orig))
+		})
+	}
+}
+
+func TestCredentialHelperCheckoutStrategy_helperError(t *testing.T) {
+	g := NewWithT(t)
+
+	helper, _ := writeCredentialHelper(t, "", 1)
+	s := &authOptionsCheckoutStrategy{}
+	_, err := CredentialHelperCheckoutStrategy(s).Checkout(context.TODO(), t.TempDir(), "https://example.com/org/repo",
+		&AuthOptions{Transport: HTTPS, CredentialHelper: helper})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(s.opts).To(BeNil())
+}
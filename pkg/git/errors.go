@@ -0,0 +1,259 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	xknownhosts "golang.org/x/crypto/ssh/knownhosts"
+)
+
+// AuthenticationError is returned when the remote rejects the provided
+// credentials, or requires credentials while none were provided.
+type AuthenticationError struct {
+	// URL of the remote.
+	URL string
+	// Err is the underlying cause.
+	Err error
+}
+
+func (e *AuthenticationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AuthenticationError) Unwrap() error {
+	return e.Err
+}
+
+// HostKeyMismatchError is returned when the host key of an SSH server can
+// not be verified against the known_hosts, either because the host is
+// unknown or because its key changed.
+type HostKeyMismatchError struct {
+	// Host of the SSH server.
+	Host string
+	// Err is the underlying cause.
+	Err error
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HostKeyMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// RepositoryNotFoundError is returned when the remote does not have a
+// repository at the URL.
+type RepositoryNotFoundError struct {
+	// URL of the remote.
+	URL string
+	// Err is the underlying cause.
+	Err error
+}
+
+func (e *RepositoryNotFoundError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RepositoryNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// EmptyRepositoryError is returned when the remote repository exists, but
+// does not have any references, e.g. because nothing has been pushed to it
+// yet.
+type EmptyRepositoryError struct {
+	// URL of the remote.
+	URL string
+	// Err is the underlying cause, may be nil.
+	Err error
+}
+
+func (e *EmptyRepositoryError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("remote repository '%s' is empty", e.URL)
+}
+
+func (e *EmptyRepositoryError) Unwrap() error {
+	return e.Err
+}
+
+// ReferenceNotFoundError is returned when a branch, tag or commit can not
+// be found in the repository.
+type ReferenceNotFoundError struct {
+	// Reference which could not be found, may be empty if unknown.
+	Reference string
+	// Err is the underlying cause.
+	Err error
+}
+
+func (e *ReferenceNotFoundError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReferenceNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// CommitNotInBranchError is returned when a commit is required to be
+// reachable from the tip of a branch, while it is not.
+type CommitNotInBranchError struct {
+	// Commit which is not reachable from the Branch.
+	Commit string
+	// Branch the Commit is required to be reachable from.
+	Branch string
+}
+
+func (e *CommitNotInBranchError) Error() string {
+	return fmt.Sprintf("commit '%s' is not reachable from branch '%s'", e.Commit, e.Branch)
+}
+
+// ShallowHistoryError is returned when an operation requires history which
+// is missing from a shallow clone.
+type ShallowHistoryError struct {
+	// Operation which requires the history.
+	Operation string
+	// Err is the underlying cause.
+	Err error
+}
+
+func (e *ShallowHistoryError) Error() string {
+	return fmt.Sprintf("%s requires the full history, but the clone is shallow: %s", e.Operation, e.Err)
+}
+
+func (e *ShallowHistoryError) Unwrap() error {
+	return e.Err
+}
+
+// Messages of errors which are formatted into another error by
+// golang.org/x/crypto/ssh before being returned by the SSH transport of
+// either implementation, and can therefore not be recognised by their type.
+var (
+	authenticationErrMessages = []string{
+		"ssh: unable to authenticate",
+	}
+	hostKeyMismatchErrMessages = []string{
+		"hostkey could not be verified",
+		"hostkey verification aborted",
+		"no entries in known_hosts match host",
+		"knownhosts: key mismatch",
+		"knownhosts: key is unknown",
+		"does not match known_hosts",
+	}
+)
+
+// ClassifyError wraps the given error returned by an operation on the
+// remote at url into an AuthenticationError or HostKeyMismatchError if it
+// can be recognised as such, or returns it as is. Errors which already are
+// an AuthenticationError, HostKeyMismatchError, RepositoryNotFoundError,
+// EmptyRepositoryError or ReferenceNotFoundError are returned as is.
+// ref is the reference the operation was performed for, and may be empty.
+//
+// ClassifyError does not know about the errors of a Git implementation,
+// implementations are expected to wrap those into the typed errors before
+// calling it.
+func ClassifyError(url, ref string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if IsClassified(err) {
+		return err
+	}
+
+	var keyErr *xknownhosts.KeyError
+	switch {
+	case containsAny(err.Error(), authenticationErrMessages):
+		return &AuthenticationError{URL: url, Err: err}
+	case errors.As(err, &keyErr), containsAny(err.Error(), hostKeyMismatchErrMessages):
+		return &HostKeyMismatchError{Host: hostFromURL(url), Err: err}
+	}
+	return err
+}
+
+// IsClassified returns true if the given error is an AuthenticationError,
+// HostKeyMismatchError, RepositoryNotFoundError, EmptyRepositoryError or
+// ReferenceNotFoundError.
+func IsClassified(err error) bool {
+	var (
+		authErr    *AuthenticationError
+		hostKeyErr *HostKeyMismatchError
+		repoErr    *RepositoryNotFoundError
+		emptyErr   *EmptyRepositoryError
+		refErr     *ReferenceNotFoundError
+	)
+	return errors.As(err, &authErr) || errors.As(err, &hostKeyErr) || errors.As(err, &repoErr) ||
+		errors.As(err, &emptyErr) || errors.As(err, &refErr)
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostFromURL returns the host of the given URL, supporting SCP-like
+// addresses. If the host can not be determined, the URL is returned.
+func hostFromURL(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	if host, _, ok := SplitSCPURL(rawURL); ok {
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+		return host
+	}
+	return rawURL
+}
+
+// SplitSCPURL splits an SCP-like address, e.g. git@example.com:org/repo.git,
+// into the user and host before the colon, and the path after it. It
+// returns false if rawURL is not an SCP-like address.
+func SplitSCPURL(rawURL string) (userHost, path string, ok bool) {
+	if strings.Contains(rawURL, "://") {
+		return "", "", false
+	}
+	i := strings.Index(rawURL, ":")
+	if i <= 0 || strings.Contains(rawURL[:i], "/") {
+		return "", "", false
+	}
+	return rawURL[:i], rawURL[i+1:], true
+}
+
+// hostnameFromURL returns the lower-cased host name of the given URL, which
+// may be an SCP-like address, without the port.
+func hostnameFromURL(rawURL string) (string, error) {
+	host := hostFromURL(rawURL)
+	if host == "" || host == rawURL {
+		return "", fmt.Errorf("failed to determine host of URL '%s'", rawURL)
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")), nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		ref     string
+		err     error
+		wantErr interface{}
+	}{
+		{
+			name: "ssh authentication message",
+			url:  "ssh://git@example.com/org/repo",
+			err:  errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey], no supported methods remain"),
+			wantErr: &AuthenticationError{
+				URL: "ssh://git@example.com/org/repo",
+				Err: errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey], no supported methods remain"),
+			},
+		},
+		{
+			name:    "implementation message",
+			url:     "https://example.com/org/repo",
+			err:     errors.New("unhandled HTTP error 404 Not Found"),
+			wantErr: errors.New("unhandled HTTP error 404 Not Found"),
+		},
+		{
+			name:    "host key message",
+			url:     "ssh://git@example.com:2222/org/repo",
+			err:     errors.New("ssh: handshake failed: hostkey could not be verified"),
+			wantErr: &HostKeyMismatchError{Host: "example.com:2222", Err: errors.New("ssh: handshake failed: hostkey could not be verified")},
+		},
+		{
+			name:    "host key message for SCP-like address",
+			url:     "git@example.com:org/repo",
+			err:     errors.New("ssh: handshake failed: knownhosts: key mismatch"),
+			wantErr: &HostKeyMismatchError{Host: "example.com", Err: errors.New("ssh: handshake failed: knownhosts: key mismatch")},
+		},
+		{
+			name:    "already classified",
+			url:     "https://example.com/org/repo",
+			err:     fmt.Errorf("wrapped: %w", &RepositoryNotFoundError{URL: "https://example.com/other", Err: errors.New("not found")}),
+			wantErr: fmt.Errorf("wrapped: %w", &RepositoryNotFoundError{URL: "https://example.com/other", Err: errors.New("not found")}),
+		},
+		{
+			name:    "unknown error",
+			url:     "https://example.com/org/repo",
+			err:     errors.New("connection reset by peer"),
+			wantErr: errors.New("connection reset by peer"),
+		},
+		{
+			name: "nil error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := ClassifyError(tt.url, tt.ref, tt.err)
+			if tt.wantErr == nil {
+				g.Expect(err).To(BeNil())
+				return
+			}
+			g.Expect(err).To(Equal(tt.wantErr))
+			g.Expect(err.Error()).To(Equal(tt.err.Error()))
+			g.Expect(errors.Is(err, tt.err)).To(BeTrue())
+		})
+	}
+}
+
+func TestClassifyError_errorsAs(t *testing.T) {
+	g := NewWithT(t)
+
+	cause := errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none], no supported methods remain")
+	err := fmt.Errorf("unable to clone: %w", ClassifyError("ssh://git@example.com/org/repo", "", cause))
+
+	var authErr *AuthenticationError
+	g.Expect(errors.As(err, &authErr)).To(BeTrue())
+	g.Expect(authErr.URL).To(Equal("ssh://git@example.com/org/repo"))
+	g.Expect(errors.Is(err, cause)).To(BeTrue())
+
+	var repoErr *RepositoryNotFoundError
+	g.Expect(errors.As(err, &repoErr)).To(BeFalse())
+}
+
+func Test_hostnameFromURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{url: "https://EXAMPLE.com/org/repo", want: "example.com"},
+		{url: "https://example.com:8443/org/repo", want: "example.com"},
+		{url: "ssh://git@[::1]:2222/org/repo", want: "::1"},
+		{url: "git@GitHub.com:org/repo.git", want: "github.com"},
+		{url: "not a url", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := hostnameFromURL(tt.url)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestSplitSCPURL(t *testing.T) {
+	tests := []struct {
+		url          string
+		wantUserHost string
+		wantPath     string
+		wantOK       bool
+	}{
+		{url: "git@example.com:org/repo.git", wantUserHost: "git@example.com", wantPath: "org/repo.git", wantOK: true},
+		{url: "example.com:/srv/repo", wantUserHost: "example.com", wantPath: "/srv/repo", wantOK: true},
+		{url: "ssh://git@example.com:2222/org/repo", wantOK: false},
+		{url: "./dir:file", wantOK: false},
+		{url: "not a url", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			g := NewWithT(t)
+
+			userHost, path, ok := SplitSCPURL(tt.url)
+			g.Expect(ok).To(Equal(tt.wantOK))
+			g.Expect(userHost).To(Equal(tt.wantUserHost))
+			g.Expect(path).To(Equal(tt.wantPath))
+		})
+	}
+}
@@ -41,6 +41,83 @@ type Signature struct {
 	When  time.Time
 }
 
+// TimeSource selects the timestamp of a commit which drives time-based
+// logic, such as the ordering of tags with an equal SemVer version.
+type TimeSource string
+
+const (
+	// TimeSourceCommit selects the time the commit was committed at.
+	TimeSourceCommit TimeSource = "commit"
+	// TimeSourceAuthor selects the time the commit was originally authored
+	// at, which is preserved when the history is rebased.
+	TimeSourceAuthor TimeSource = "author"
+)
+
+// ParseTimeSource parses the given TimeSource, an empty string results in
+// TimeSourceCommit.
+func ParseTimeSource(s string) (TimeSource, error) {
+	switch t := TimeSource(s); t {
+	case "":
+		return TimeSourceCommit, nil
+	case TimeSourceCommit, TimeSourceAuthor:
+		return t, nil
+	default:
+		return "", fmt.Errorf("invalid time source '%s', must be one of: %s, %s", s, TimeSourceCommit, TimeSourceAuthor)
+	}
+}
+
+// TagKind selects the kind of tags which are considered when resolving a
+// SemVer expression.
+type TagKind string
+
+const (
+	// TagKindAny considers both annotated and lightweight tags.
+	TagKindAny TagKind = "any"
+	// TagKindAnnotated only considers annotated tags, which carry metadata
+	// such as the tagger and an optional signature.
+	TagKindAnnotated TagKind = "annotated"
+	// TagKindLightweight only considers lightweight tags, which are plain
+	// references to a commit.
+	TagKindLightweight TagKind = "lightweight"
+)
+
+// ParseTagKind parses the given TagKind, an empty string results in
+// TagKindAny.
+func ParseTagKind(s string) (TagKind, error) {
+	switch k := TagKind(s); k {
+	case "":
+		return TagKindAny, nil
+	case TagKindAny, TagKindAnnotated, TagKindLightweight:
+		return k, nil
+	default:
+		return "", fmt.Errorf("invalid tag kind '%s', must be one of: %s, %s, %s", s, TagKindAny, TagKindAnnotated, TagKindLightweight)
+	}
+}
+
+// Matches returns if a tag, which is annotated or lightweight, is of the
+// TagKind. An empty TagKind matches any tag, an unknown TagKind none.
+func (k TagKind) Matches(annotated bool) bool {
+	switch k {
+	case "", TagKindAny:
+		return true
+	case TagKindAnnotated:
+		return annotated
+	case TagKindLightweight:
+		return !annotated
+	default:
+		return false
+	}
+}
+
+// SignatureTime returns the time of the author or committer Signature
+// selected by the TimeSource, defaulting to the committer.
+func SignatureTime(source TimeSource, author, committer Signature) time.Time {
+	if source == TimeSourceAuthor {
+		return author.When
+	}
+	return committer.When
+}
+
 type Commit struct {
 	// Hash is the SHA1 hash of the commit.
 	Hash Hash
@@ -58,6 +135,9 @@ type Commit struct {
 	Encoded []byte
 	// Message is the commit message, contains arbitrary text.
 	Message string
+	// TagVerification is the result of the verification of the signature
+	// of the checked out tag, if requested.
+	TagVerification *TagVerification
 }
 
 // String returns a string representation of the Commit, composed
@@ -65,10 +145,11 @@ type Commit struct {
 // For example: 'tag-1/a0c14dc8580a23f79bc654faa79c4f62b46c2c22',
 // for a "tag-1" tag.
 func (c *Commit) String() string {
+	var ref string
 	if short := strings.SplitAfterN(c.Reference, "/", 3); len(short) == 3 {
-		return fmt.Sprintf("%s/%s", short[2], c.Hash)
+		ref = short[2]
 	}
-	return fmt.Sprintf("HEAD/%s", c.Hash)
+	return FormatRevision(ref, c.Hash.String())
 }
 
 // Verify the Signature of the commit with the given key rings.
@@ -93,6 +174,24 @@ func (c *Commit) Verify(keyRing ...string) (string, error) {
 	return "", fmt.Errorf("failed to verify commit with any of the given key rings")
 }
 
+// AuthorTime returns the time the commit was originally authored at.
+func (c *Commit) AuthorTime() time.Time {
+	return c.Author.When
+}
+
+// CommitTime returns the time the commit was committed at, which differs
+// from the AuthorTime for commits which were amended, rebased or applied
+// by someone other than the author.
+func (c *Commit) CommitTime() time.Time {
+	return c.Committer.When
+}
+
+// Time returns the AuthorTime or CommitTime of the commit as selected by
+// the given TimeSource, defaulting to the CommitTime.
+func (c *Commit) Time(source TimeSource) time.Time {
+	return SignatureTime(source, c.Author, c.Committer)
+}
+
 // ShortMessage returns the first 50 characters of a commit subject.
 func (c *Commit) ShortMessage() string {
 	subject := strings.Split(c.Message, "\n")[0]
@@ -107,6 +206,80 @@ type CheckoutStrategy interface {
 	Checkout(ctx context.Context, path, url string, config *AuthOptions) (*Commit, error)
 }
 
+// TransportMetrics are statistics of the transfer from the remote during a
+// checkout. Not all Implementations report the received objects and bytes.
+type TransportMetrics struct {
+	// Duration of the checkout, including the transfer.
+	Duration time.Duration
+	// ReceivedObjects is the number of objects received from the remote.
+	ReceivedObjects uint
+	// ReceivedBytes is the number of bytes received from the remote.
+	ReceivedBytes uint
+}
+
+// CheckoutResult is the result of a checkout.
+type CheckoutResult struct {
+	// Commit which was checked out, which is a partial commit if NoOp is
+	// true.
+	Commit *Commit
+	// ResolvedRef is the reference the Commit was resolved from, e.g.
+	// "refs/tags/v1.0.0", or empty when checking out a commit by its hash.
+	ResolvedRef string
+	// NoOp is true if the checkout was skipped, as the remote revision
+	// matched the LastRevision of the CheckoutOptions.
+	NoOp bool
+	// Stats of the transfer from the remote.
+	Stats TransportMetrics
+}
+
+// ResultCheckoutStrategy is a CheckoutStrategy which can return a
+// CheckoutResult, of which Checkout is a thin wrapper returning the Commit.
+type ResultCheckoutStrategy interface {
+	CheckoutStrategy
+	CheckoutWithResult(ctx context.Context, path, url string, config *AuthOptions) (*CheckoutResult, error)
+}
+
+// CheckoutWithResult performs the checkout using the given CheckoutStrategy.
+// For strategies which do not implement ResultCheckoutStrategy, the
+// CheckoutResult is derived from the returned Commit.
+func CheckoutWithResult(ctx context.Context, s CheckoutStrategy, path, url string, config *AuthOptions) (*CheckoutResult, error) {
+	if rs, ok := s.(ResultCheckoutStrategy); ok {
+		return rs.CheckoutWithResult(ctx, path, url, config)
+	}
+	start := time.Now()
+	commit, err := s.Checkout(ctx, path, url, config)
+	if err != nil {
+		return nil, err
+	}
+	result := NewCheckoutResult(commit)
+	result.Stats.Duration = time.Since(start)
+	return result, nil
+}
+
+// NewCheckoutResult returns a CheckoutResult for the given Commit. The
+// checkout is considered a NoOp if the Commit is not a concrete commit.
+func NewCheckoutResult(commit *Commit) *CheckoutResult {
+	result := &CheckoutResult{Commit: commit}
+	if commit != nil {
+		result.ResolvedRef = commit.Reference
+		result.NoOp = !IsConcreteCommit(*commit)
+	}
+	return result
+}
+
+// CheckoutResultCommit returns the Commit of the given CheckoutResult, or
+// the given error. It allows a ResultCheckoutStrategy to implement Checkout
+// as a thin wrapper around CheckoutWithResult.
+func CheckoutResultCommit(result *CheckoutResult, err error) (*Commit, error) {
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
+	}
+	return result.Commit, nil
+}
+
 // IsConcreteCommit returns if a given commit is a concrete commit. Concrete
 // commits have most of commit metadata and commit content. In contrast, a
 // partial commit may only have some metadata and no commit content.
@@ -17,6 +17,8 @@ limitations under the License.
 package git
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -265,6 +267,110 @@ of the commit`,
 	}
 }
 
+func TestCommit_Time(t *testing.T) {
+	authored := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	committed := authored.Add(36 * time.Hour)
+	c := &Commit{
+		Author:    Signature{Name: "Jane Doe", When: authored},
+		Committer: Signature{Name: "John Doe", When: committed},
+	}
+
+	tests := []struct {
+		source TimeSource
+		want   time.Time
+	}{
+		{source: TimeSourceCommit, want: committed},
+		{source: TimeSourceAuthor, want: authored},
+		{source: "", want: committed},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.source), func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(c.Time(tt.source)).To(Equal(tt.want))
+		})
+	}
+
+	g := NewWithT(t)
+	g.Expect(c.AuthorTime()).To(Equal(authored))
+	g.Expect(c.CommitTime()).To(Equal(committed))
+}
+
+func TestParseTimeSource(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    TimeSource
+		wantErr bool
+	}{
+		{input: "commit", want: TimeSourceCommit},
+		{input: "author", want: TimeSourceAuthor},
+		{input: "", want: TimeSourceCommit},
+		{input: "tagger", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := ParseTimeSource(tt.input)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestParseTagKind(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    TagKind
+		wantErr bool
+	}{
+		{input: "any", want: TagKindAny},
+		{input: "annotated", want: TagKindAnnotated},
+		{input: "lightweight", want: TagKindLightweight},
+		{input: "", want: TagKindAny},
+		{input: "signed", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := ParseTagKind(tt.input)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestTagKind_Matches(t *testing.T) {
+	tests := []struct {
+		kind            TagKind
+		wantAnnotated   bool
+		wantLightweight bool
+	}{
+		{kind: TagKindAny, wantAnnotated: true, wantLightweight: true},
+		{kind: TagKindAnnotated, wantAnnotated: true, wantLightweight: false},
+		{kind: TagKindLightweight, wantAnnotated: false, wantLightweight: true},
+		{kind: "", wantAnnotated: true, wantLightweight: true},
+		{kind: "invalid", wantAnnotated: false, wantLightweight: false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(tt.kind.Matches(true)).To(Equal(tt.wantAnnotated))
+			g.Expect(tt.kind.Matches(false)).To(Equal(tt.wantLightweight))
+		})
+	}
+}
+
 func TestIsConcreteCommit(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -302,3 +408,75 @@ func TestIsConcreteCommit(t *testing.T) {
 		})
 	}
 }
+
+type resultCheckoutStrategy struct {
+	result *CheckoutResult
+}
+
+func (s *resultCheckoutStrategy) Checkout(ctx context.Context, path, url string, opts *AuthOptions) (*Commit, error) {
+	return CheckoutResultCommit(s.CheckoutWithResult(ctx, path, url, opts))
+}
+
+func (s *resultCheckoutStrategy) CheckoutWithResult(_ context.Context, _, _ string, _ *AuthOptions) (*CheckoutResult, error) {
+	return s.result, nil
+}
+
+func TestCheckoutWithResult(t *testing.T) {
+	concrete := &Commit{Hash: Hash("abc123"), Reference: "refs/heads/main", Encoded: []byte("encoded")}
+	partial := &Commit{Hash: Hash("abc123"), Reference: "refs/heads/main"}
+	stats := TransportMetrics{ReceivedObjects: 3, ReceivedBytes: 1024}
+
+	tests := []struct {
+		name     string
+		strategy CheckoutStrategy
+		want     *CheckoutResult
+		wantErr  string
+	}{
+		{
+			name:     "concrete commit",
+			strategy: &mockCheckoutStrategy{commit: concrete},
+			want:     &CheckoutResult{Commit: concrete, ResolvedRef: "refs/heads/main"},
+		},
+		{
+			name:     "partial commit is a no-op",
+			strategy: &mockCheckoutStrategy{commit: partial},
+			want:     &CheckoutResult{Commit: partial, ResolvedRef: "refs/heads/main", NoOp: true},
+		},
+		{
+			name:     "checkout error",
+			strategy: &mockCheckoutStrategy{err: errors.New("checkout failed")},
+			wantErr:  "checkout failed",
+		},
+		{
+			name:     "result of result checkout strategy",
+			strategy: &resultCheckoutStrategy{result: &CheckoutResult{Commit: concrete, ResolvedRef: "refs/tags/v1.0.0", Stats: stats}},
+			want:     &CheckoutResult{Commit: concrete, ResolvedRef: "refs/tags/v1.0.0", Stats: stats},
+		},
+		{
+			name: "result passed through decorating strategies",
+			strategy: LimitCheckoutStrategy(
+				&resultCheckoutStrategy{result: &CheckoutResult{Commit: partial, NoOp: true, Stats: stats}}, nil),
+			want: &CheckoutResult{Commit: partial, NoOp: true, Stats: stats},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := CheckoutWithResult(context.TODO(), tt.strategy, "/tmp/checkout", "https://example.com", nil)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+				g.Expect(got).To(BeNil())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			// The duration of strategies not returning a result is measured.
+			got.Stats.Duration = 0
+			g.Expect(got).To(Equal(tt.want))
+
+			commit, err := tt.strategy.Checkout(context.TODO(), "/tmp/checkout", "https://example.com", nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(commit).To(Equal(tt.want.Commit))
+		})
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+)
+
+// GitAttributesFile is the name of the file defining the attributes of the
+// paths in a Git tree.
+const GitAttributesFile = ".gitattributes"
+
+// AttributeState is the state of an Attribute for a path.
+type AttributeState string
+
+const (
+	// AttributeSet is the state of an attribute given by its name, e.g.
+	// "text".
+	AttributeSet AttributeState = "set"
+	// AttributeUnset is the state of an attribute prefixed with a dash,
+	// e.g. "-text".
+	AttributeUnset AttributeState = "unset"
+	// AttributeUnspecified is the state of an attribute prefixed with an
+	// exclamation mark, e.g. "!text".
+	AttributeUnspecified AttributeState = "unspecified"
+	// AttributeValue is the state of an attribute with a value, e.g.
+	// "filter=lfs".
+	AttributeValue AttributeState = "value"
+)
+
+// Attribute is a Git attribute assigned to the paths matching an
+// AttributePattern.
+type Attribute struct {
+	Name  string
+	State AttributeState
+	// Value is the value of an attribute with the AttributeValue state.
+	Value string
+}
+
+// AttributePattern is a line of a .gitattributes file, assigning the
+// Attributes to the paths matching the Pattern, or defining a macro.
+type AttributePattern struct {
+	// Dir is the directory of the .gitattributes file, relative to the root
+	// of the tree and separated by forward slashes. It is empty for the
+	// file in the root of the tree.
+	Dir string
+	// Pattern is the path pattern, or the name of the macro.
+	Pattern string
+	// Macro is true if the line defines a macro, which is only allowed in
+	// the root of the tree.
+	Macro      bool
+	Attributes []Attribute
+}
+
+// Match returns if the given path, relative to the root of the tree and
+// separated by forward slashes, matches the Pattern. Macros never match.
+func (p AttributePattern) Match(path string) bool {
+	if p.Macro {
+		return false
+	}
+	var domain []string
+	if p.Dir != "" {
+		domain = strings.Split(p.Dir, "/")
+	}
+	return gitattributes.ParsePattern(p.Pattern, domain).Match(strings.Split(path, "/"))
+}
+
+// Attributes are the AttributePatterns of a tree, in increasing order of
+// priority.
+type Attributes []AttributePattern
+
+// binaryMacro is the "binary" macro built into Git.
+var binaryMacro = AttributePattern{
+	Pattern: "binary",
+	Macro:   true,
+	Attributes: []Attribute{
+		{Name: "diff", State: AttributeUnset},
+		{Name: "merge", State: AttributeUnset},
+		{Name: "text", State: AttributeUnset},
+	},
+}
+
+// Lookup returns the Attributes assigned to the given path, relative to the
+// root of the tree and separated by forward slashes. When multiple patterns
+// match the path, the attributes of the pattern with the highest priority
+// take precedence. Set macros are expanded to the attributes they define.
+// Unspecified attributes are returned with the AttributeUnspecified state.
+func (a Attributes) Lookup(path string) map[string]Attribute {
+	macros := map[string]AttributePattern{binaryMacro.Pattern: binaryMacro}
+	for _, p := range a {
+		if p.Macro {
+			macros[p.Pattern] = p
+		}
+	}
+
+	result := make(map[string]Attribute)
+	for _, p := range a {
+		if !p.Match(path) {
+			continue
+		}
+		for _, attr := range p.Attributes {
+			if m, ok := macros[attr.Name]; ok && attr.State == AttributeSet {
+				for _, mattr := range m.Attributes {
+					result[mattr.Name] = mattr
+				}
+			}
+			result[attr.Name] = attr
+		}
+	}
+	return result
+}
+
+// IsLFS returns if the given path is stored using Git LFS, i.e. if the
+// "filter" attribute of the path is set to "lfs".
+func (a Attributes) IsLFS(path string) bool {
+	attr, ok := a.Lookup(path)["filter"]
+	return ok && attr.State == AttributeValue && attr.Value == "lfs"
+}
+
+// ParseAttributes parses the given content of the .gitattributes file in
+// the given directory of the tree, relative to the root of the tree and
+// separated by forward slashes. Macros can only be defined in the root of
+// the tree.
+func ParseAttributes(b []byte, dir string) (Attributes, error) {
+	var domain []string
+	if dir != "" {
+		domain = strings.Split(dir, "/")
+	}
+	matches, err := gitattributes.ReadAttributes(bytes.NewReader(b), domain, dir == "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path.Join(dir, GitAttributesFile), err)
+	}
+
+	attrs := make(Attributes, 0, len(matches))
+	for _, m := range matches {
+		p := AttributePattern{
+			Dir:        dir,
+			Pattern:    m.Name,
+			Macro:      m.Pattern == nil,
+			Attributes: make([]Attribute, 0, len(m.Attributes)),
+		}
+		for _, attr := range m.Attributes {
+			a := Attribute{Name: attr.Name()}
+			switch {
+			case attr.IsSet():
+				a.State = AttributeSet
+			case attr.IsUnset():
+				a.State = AttributeUnset
+			case attr.IsUnspecified():
+				a.State = AttributeUnspecified
+			case attr.IsValueSet():
+				a.State = AttributeValue
+				a.Value = attr.Value()
+			}
+			p.Attributes = append(p.Attributes, a)
+		}
+		attrs = append(attrs, p)
+	}
+	return attrs, nil
+}
+
+// ReadAttributes reads the .gitattributes files of the tree checked out at
+// the given path. The files in subdirectories take precedence over the
+// files in their parent directories. The .git directory is skipped.
+func ReadAttributes(root string) (Attributes, error) {
+	var attrs Attributes
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != GitAttributesFile || !d.Type().IsRegular() {
+			return nil
+		}
+
+		dir, err := filepath.Rel(root, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		dir = filepath.ToSlash(dir)
+		if dir == "." {
+			dir = ""
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path.Join(dir, GitAttributesFile), err)
+		}
+		fileAttrs, err := ParseAttributes(b, dir)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, fileAttrs...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure the patterns of parent directories precede those of their
+	// subdirectories, regardless of the walk order.
+	sort.SliceStable(attrs, func(i, j int) bool {
+		return dirDepth(attrs[i].Dir) < dirDepth(attrs[j].Dir)
+	})
+	return attrs, nil
+}
+
+// dirDepth returns the number of path elements of the given directory.
+func dirDepth(dir string) int {
+	if dir == "" {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const testGitAttributes = `# Default line endings
+* text=auto
+
+*.png binary
+*.psd filter=lfs diff=lfs merge=lfs -text
+"assets/**/*.bin" filter=lfs !diff
+docs/*.md linguist-documentation
+[attr]generated -diff linguist-generated=true
+zz_generated.*.go generated
+`
+
+func TestParseAttributes(t *testing.T) {
+	g := NewWithT(t)
+
+	attrs, err := ParseAttributes([]byte(testGitAttributes), "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(attrs).To(Equal(Attributes{
+		{Pattern: "*", Attributes: []Attribute{{Name: "text", State: AttributeValue, Value: "auto"}}},
+		{Pattern: "*.png", Attributes: []Attribute{{Name: "binary", State: AttributeSet}}},
+		{Pattern: "*.psd", Attributes: []Attribute{
+			{Name: "filter", State: AttributeValue, Value: "lfs"},
+			{Name: "diff", State: AttributeValue, Value: "lfs"},
+			{Name: "merge", State: AttributeValue, Value: "lfs"},
+			{Name: "text", State: AttributeUnset},
+		}},
+		{Pattern: "assets/**/*.bin", Attributes: []Attribute{
+			{Name: "filter", State: AttributeValue, Value: "lfs"},
+			{Name: "diff", State: AttributeUnspecified},
+		}},
+		{Pattern: "docs/*.md", Attributes: []Attribute{{Name: "linguist-documentation", State: AttributeSet}}},
+		{Pattern: "generated", Macro: true, Attributes: []Attribute{
+			{Name: "diff", State: AttributeUnset},
+			{Name: "linguist-generated", State: AttributeValue, Value: "true"},
+		}},
+		{Pattern: "zz_generated.*.go", Attributes: []Attribute{{Name: "generated", State: AttributeSet}}},
+	}))
+}
+
+func TestParseAttributes_macroInSubdirectory(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := ParseAttributes([]byte("[attr]generated -diff\n"), "sub")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("sub/.gitattributes"))
+}
+
+func TestAttributes_Lookup(t *testing.T) {
+	attrs, err := ParseAttributes([]byte(testGitAttributes), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		want    map[string]Attribute
+		wantLFS bool
+	}{
+		{
+			path: "README.md",
+			want: map[string]Attribute{
+				"text": {Name: "text", State: AttributeValue, Value: "auto"},
+			},
+		},
+		{
+			path: "img/logo.png",
+			want: map[string]Attribute{
+				"text":   {Name: "text", State: AttributeUnset},
+				"diff":   {Name: "diff", State: AttributeUnset},
+				"merge":  {Name: "merge", State: AttributeUnset},
+				"binary": {Name: "binary", State: AttributeSet},
+			},
+		},
+		{
+			path: "design/cover.psd",
+			want: map[string]Attribute{
+				"text":   {Name: "text", State: AttributeUnset},
+				"filter": {Name: "filter", State: AttributeValue, Value: "lfs"},
+				"diff":   {Name: "diff", State: AttributeValue, Value: "lfs"},
+				"merge":  {Name: "merge", State: AttributeValue, Value: "lfs"},
+			},
+			wantLFS: true,
+		},
+		{
+			path: "assets/models/large/model.bin",
+			want: map[string]Attribute{
+				"text":   {Name: "text", State: AttributeValue, Value: "auto"},
+				"filter": {Name: "filter", State: AttributeValue, Value: "lfs"},
+				"diff":   {Name: "diff", State: AttributeUnspecified},
+			},
+			wantLFS: true,
+		},
+		{
+			path: "docs/nested/guide.md",
+			want: map[string]Attribute{
+				"text": {Name: "text", State: AttributeValue, Value: "auto"},
+			},
+		},
+		{
+			path: "api/zz_generated.deepcopy.go",
+			want: map[string]Attribute{
+				"text":               {Name: "text", State: AttributeValue, Value: "auto"},
+				"diff":               {Name: "diff", State: AttributeUnset},
+				"linguist-generated": {Name: "linguist-generated", State: AttributeValue, Value: "true"},
+				"generated":          {Name: "generated", State: AttributeSet},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(attrs.Lookup(tt.path)).To(Equal(tt.want))
+			g.Expect(attrs.IsLFS(tt.path)).To(Equal(tt.wantLFS))
+		})
+	}
+}
+
+func TestReadAttributes(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	files := map[string]string{
+		".gitattributes":            "*.bin filter=lfs\n",
+		"-first/.gitattributes":     "*.bin -filter\n",
+		"vendor/.gitattributes":     "*.bin -filter\n",
+		"vendor/a/b/.gitattributes": "*.bin filter=lfs\n",
+		".git/.gitattributes":       "* -text\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		g.Expect(os.MkdirAll(filepath.Dir(p), 0o700)).To(Succeed())
+		g.Expect(os.WriteFile(p, []byte(content), 0o600)).To(Succeed())
+	}
+
+	attrs, err := ReadAttributes(root)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(attrs).To(HaveLen(4))
+	g.Expect(attrs[0].Dir).To(Equal(""))
+	g.Expect(attrs[3].Dir).To(Equal("vendor/a/b"))
+
+	g.Expect(attrs.IsLFS("data.bin")).To(BeTrue())
+	g.Expect(attrs.IsLFS("-first/data.bin")).To(BeFalse())
+	g.Expect(attrs.IsLFS("vendor/data.bin")).To(BeFalse())
+	g.Expect(attrs.IsLFS("vendor/a/data.bin")).To(BeFalse())
+	g.Expect(attrs.IsLFS("vendor/a/b/c/data.bin")).To(BeTrue())
+
+	attrs, err = ReadAttributes(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(attrs).To(BeEmpty())
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// plainClone clones the repository at the URL of the given options into
+// path. Git bundle URLs are cloned from the local bundle, without network
+// access. If refSpecs are given, they are fetched instead of the default
+// refs of the options. A repository previously cloned from the URL into path
+// is reused by fetching the reference into it, and only replaced with a fresh
+// clone if it can not be reused, e.g. because it is corrupt or the URL
+// changed.
+func plainClone(ctx context.Context, path string, o *extgogit.CloneOptions, refSpecs []config.RefSpec) (*extgogit.Repository, error) {
+	if git.IsBundleURL(o.URL) {
+		if len(refSpecs) > 0 {
+			return nil, fmt.Errorf("refspecs are not supported for Git bundles")
+		}
+		// A bundle is unbundled again, as it is already local.
+		if _, err := os.Stat(filepath.Join(path, extgogit.GitDirName)); err == nil {
+			if err = removeContents(path); err != nil {
+				return nil, fmt.Errorf("failed to remove existing clone: %w", err)
+			}
+		}
+		return cloneBundle(ctx, path, o)
+	}
+
+	repo, err := fetchClone(ctx, path, o, refSpecs)
+	switch {
+	case err == nil:
+		return repo, nil
+	case errors.Is(err, extgogit.ErrRepositoryNotExists):
+	case errors.Is(err, errCloneNotReusable):
+		if err = removeContents(path); err != nil {
+			return nil, fmt.Errorf("failed to remove existing clone: %w", err)
+		}
+	default:
+		return nil, err
+	}
+	if len(refSpecs) > 0 {
+		return fetchRefSpecsClone(ctx, path, o, refSpecs)
+	}
+	return extgogit.PlainCloneContext(ctx, path, false, o)
+}
+
+// cloneBundle clones the Git bundle at the URL of the given options into
+// path, honoring the ReferenceName, NoCheckout and Tags of the options.
+// All branches in the bundle are stored as remote branches, as a bundle is
+// read as a whole. Submodules can not be recursed, as they are not part of
+// the bundle.
+func cloneBundle(ctx context.Context, path string, o *extgogit.CloneOptions) (*extgogit.Repository, error) {
+	if o.RecurseSubmodules != extgogit.NoRecurseSubmodules {
+		return nil, fmt.Errorf("recursing submodules is not supported for Git bundles")
+	}
+	bundlePath, err := git.BundlePath(o.URL)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Git bundle: %w", err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	header, err := git.ReadBundleHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := extgogit.PlainInit(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize repository: %w", err)
+	}
+	if err = header.VerifyPrerequisites(func(hash string) bool {
+		return repo.Storer.HasEncodedObject(plumbing.NewHash(hash)) == nil
+	}); err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err = packfile.UpdateObjectStorage(repo.Storer, r); err != nil {
+		return nil, fmt.Errorf("failed to unbundle objects: %w", err)
+	}
+	if _, err = repo.CreateRemote(&config.RemoteConfig{Name: o.RemoteName, URLs: []string{o.URL}}); err != nil {
+		return nil, fmt.Errorf("failed to configure remote: %w", err)
+	}
+
+	var head *plumbing.Reference
+	for _, br := range header.References {
+		name := plumbing.ReferenceName(br.Name)
+		hash := plumbing.NewHash(br.Hash)
+		var ref *plumbing.Reference
+		switch {
+		case name.IsBranch():
+			ref = plumbing.NewHashReference(plumbing.NewRemoteReferenceName(o.RemoteName, name.Short()), hash)
+		case name.IsTag() && (o.Tags != extgogit.NoTags || name == o.ReferenceName):
+			ref = plumbing.NewHashReference(name, hash)
+		}
+		if ref != nil {
+			if err = repo.Storer.SetReference(ref); err != nil {
+				return nil, fmt.Errorf("failed to store reference '%s': %w", name, err)
+			}
+		}
+		if (o.ReferenceName == "" && name == plumbing.HEAD) || (o.ReferenceName != "" && name == o.ReferenceName) {
+			head = plumbing.NewHashReference(name, hash)
+		}
+	}
+	if head == nil {
+		if o.ReferenceName != "" {
+			return nil, fmt.Errorf("couldn't find remote ref '%s' in Git bundle: %w", o.ReferenceName, plumbing.ErrReferenceNotFound)
+		}
+		// Without a HEAD, there is nothing to check out.
+		return repo, nil
+	}
+
+	commit, err := peelToCommit(repo, head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if head.Name().IsBranch() {
+		branch := plumbing.NewHashReference(head.Name(), commit)
+		if err = repo.Storer.SetReference(branch); err != nil {
+			return nil, fmt.Errorf("failed to store reference '%s': %w", branch.Name(), err)
+		}
+		err = repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch.Name()))
+	} else {
+		err = repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, commit))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store HEAD: %w", err)
+	}
+	if o.NoCheckout {
+		return repo, nil
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Git worktree: %w", err)
+	}
+	if err = w.Reset(&extgogit.ResetOptions{Commit: commit, Mode: extgogit.HardReset}); err != nil {
+		return nil, fmt.Errorf("failed to checkout '%s': %w", head.Name().Short(), err)
+	}
+	return repo, nil
+}
+
+// bundleReferences returns the references in the Git bundle at the given
+// URL.
+func bundleReferences(url string) ([]*plumbing.Reference, error) {
+	bundlePath, err := git.BundlePath(url)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Git bundle: %w", err)
+	}
+	defer f.Close()
+	header, err := git.ReadBundleHeader(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]*plumbing.Reference, 0, len(header.References))
+	for _, br := range header.References {
+		refs = append(refs, plumbing.NewHashReference(plumbing.ReferenceName(br.Name), plumbing.NewHash(br.Hash)))
+	}
+	return refs, nil
+}
+
+// peelToCommit returns the hash of the commit the given object hash points
+// to, dereferencing annotated tags.
+func peelToCommit(repo *extgogit.Repository, hash plumbing.Hash) (plumbing.Hash, error) {
+	tag, err := repo.TagObject(hash)
+	if err == plumbing.ErrObjectNotFound {
+		return hash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to resolve object '%s': %w", hash, err)
+	}
+	cc, err := tag.Commit()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to resolve commit of tag '%s': %w", tag.Name, err)
+	}
+	return cc.Hash, nil
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+func TestCheckout_bundle(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t)
+	g.Expect(err).ToNot(HaveOccurred())
+	firstCommit, err := commitFile(repo, "file", "init", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, firstCommit, true, "v1.0.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(createBranch(repo, "feature")).To(Succeed())
+	featureCommit, err := commitFile(repo, "file", "feature", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	bundleDir := t.TempDir()
+	bundle := filepath.Join(bundleDir, "repo.bundle")
+	writeBundle(t, repo, bundle, nil)
+	bundleURL := "file://" + filepath.ToSlash(bundle)
+
+	defer func(dir string) { git.DefaultBundleDir = dir }(git.DefaultBundleDir)
+	git.DefaultBundleDir = bundleDir
+
+	tests := []struct {
+		name        string
+		strategy    git.CheckoutStrategy
+		wantCommit  string
+		wantContent string
+		wantErr     string
+	}{
+		{
+			name:        "branch",
+			strategy:    &CheckoutBranch{Branch: "master"},
+			wantCommit:  "master/" + firstCommit.String(),
+			wantContent: "init",
+		},
+		{
+			name:        "other branch",
+			strategy:    &CheckoutBranch{Branch: "feature"},
+			wantCommit:  "feature/" + featureCommit.String(),
+			wantContent: "feature",
+		},
+		{
+			name:        "annotated tag",
+			strategy:    &CheckoutTag{Tag: "v1.0.0"},
+			wantCommit:  "v1.0.0/" + firstCommit.String(),
+			wantContent: "init",
+		},
+		{
+			name:        "commit in branch",
+			strategy:    &CheckoutCommit{Branch: "feature", Commit: firstCommit.String(), RequireInBranch: true},
+			wantCommit:  "feature/" + firstCommit.String(),
+			wantContent: "init",
+		},
+		{
+			name:        "semver",
+			strategy:    &CheckoutSemVer{SemVer: ">=1.0.0"},
+			wantCommit:  "v1.0.0/" + firstCommit.String(),
+			wantContent: "init",
+		},
+		{
+			name:     "missing branch",
+			strategy: &CheckoutBranch{Branch: "missing"},
+			wantErr:  "couldn't find remote ref 'refs/heads/missing' in Git bundle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			cc, err := tt.strategy.Checkout(context.TODO(), dir, bundleURL, &git.AuthOptions{Transport: git.File})
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				var refErr *git.ReferenceNotFoundError
+				g.Expect(errors.As(err, &refErr)).To(BeTrue())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.String()).To(Equal(tt.wantCommit))
+			g.Expect(filepath.Join(dir, "file")).To(BeARegularFile())
+			b, err := os.ReadFile(filepath.Join(dir, "file"))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(b)).To(Equal(tt.wantContent))
+		})
+	}
+}
+
+func TestCheckout_bundleLastRevision(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t)
+	g.Expect(err).ToNot(HaveOccurred())
+	commit, err := commitFile(repo, "file", "init", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	bundleDir := t.TempDir()
+	bundle := filepath.Join(bundleDir, "repo.bundle")
+	writeBundle(t, repo, bundle, nil)
+
+	defer func(dir string) { git.DefaultBundleDir = dir }(git.DefaultBundleDir)
+	git.DefaultBundleDir = bundleDir
+
+	dir := t.TempDir()
+	c := &CheckoutBranch{Branch: "master", LastRevision: "master/" + commit.String()}
+	cc, err := c.Checkout(context.TODO(), dir, "file://"+filepath.ToSlash(bundle), &git.AuthOptions{Transport: git.File})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(git.IsConcreteCommit(*cc)).To(BeFalse())
+	g.Expect(filepath.Join(dir, ".git")).ToNot(BeADirectory())
+}
+
+func TestCheckout_bundlePrerequisites(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t)
+	g.Expect(err).ToNot(HaveOccurred())
+	firstCommit, err := commitFile(repo, "file", "init", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "file", "second", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	bundleDir := t.TempDir()
+	bundle := filepath.Join(bundleDir, "repo.bundle")
+	writeBundle(t, repo, bundle, []plumbing.Hash{firstCommit})
+
+	defer func(dir string) { git.DefaultBundleDir = dir }(git.DefaultBundleDir)
+	git.DefaultBundleDir = bundleDir
+
+	c := &CheckoutBranch{Branch: "master"}
+	_, err = c.Checkout(context.TODO(), t.TempDir(), "file://"+filepath.ToSlash(bundle), &git.AuthOptions{Transport: git.File})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("bundle prerequisites are not satisfied: missing commits '" + firstCommit.String() + "'"))
+}
+
+func TestCheckout_bundleOutsideDir(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "file", "init", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	bundle := filepath.Join(t.TempDir(), "repo.bundle")
+	writeBundle(t, repo, bundle, nil)
+
+	defer func(dir string) { git.DefaultBundleDir = dir }(git.DefaultBundleDir)
+	git.DefaultBundleDir = t.TempDir()
+
+	c := &CheckoutBranch{Branch: "master"}
+	_, err = c.Checkout(context.TODO(), t.TempDir(), "file://"+filepath.ToSlash(bundle), &git.AuthOptions{Transport: git.File})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("is not within the bundle directory"))
+}
+
+// writeBundle writes a v2 Git bundle with the branches and tags of the
+// given repository to path, as "git bundle create" does. The objects of the
+// given prerequisites are excluded from the bundle.
+func writeBundle(t *testing.T, repo *extgogit.Repository, path string, prerequisites []plumbing.Hash) {
+	t.Helper()
+	g := NewWithT(t)
+
+	excluded := map[plumbing.Hash]bool{}
+	for _, p := range prerequisites {
+		cc, err := repo.CommitObject(p)
+		g.Expect(err).ToNot(HaveOccurred())
+		tree, err := cc.Tree()
+		g.Expect(err).ToNot(HaveOccurred())
+		excluded[cc.Hash] = true
+		excluded[tree.Hash] = true
+		for _, e := range tree.Entries {
+			excluded[e.Hash] = true
+		}
+	}
+	var hashes []plumbing.Hash
+	iter, err := repo.Storer.IterEncodedObjects(plumbing.AnyObject)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(iter.ForEach(func(o plumbing.EncodedObject) error {
+		if !excluded[o.Hash()] {
+			hashes = append(hashes, o.Hash())
+		}
+		return nil
+	})).To(Succeed())
+
+	f, err := os.Create(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+	fmt.Fprintln(f, "# v2 git bundle")
+	for _, p := range prerequisites {
+		fmt.Fprintf(f, "-%s\n", p)
+	}
+	refs, err := repo.References()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			fmt.Fprintf(f, "%s %s\n", ref.Hash(), ref.Name())
+		}
+		return nil
+	})).To(Succeed())
+	fmt.Fprintln(f)
+	_, err = packfile.NewEncoder(f, repo.Storer, false).Encode(hashes, 10)
+	g.Expect(err).ToNot(HaveOccurred())
+}
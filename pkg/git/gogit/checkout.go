@@ -22,7 +22,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
@@ -34,7 +34,6 @@ import (
 	"github.com/go-git/go-git/v5/storage/memory"
 
 	"github.com/fluxcd/pkg/gitutil"
-	"github.com/fluxcd/pkg/version"
 
 	"github.com/fluxcd/source-controller/pkg/git"
 )
@@ -44,17 +43,42 @@ import (
 func CheckoutStrategyForOptions(_ context.Context, opts git.CheckoutOptions) git.CheckoutStrategy {
 	switch {
 	case opts.Commit != "":
-		return &CheckoutCommit{Branch: opts.Branch, Commit: opts.Commit, RecurseSubmodules: opts.RecurseSubmodules}
+		return &CheckoutCommit{
+			Branch:            opts.Branch,
+			Commit:            opts.Commit,
+			RecurseSubmodules: opts.RecurseSubmodules,
+			RequireInBranch:   opts.RequireCommitInBranch,
+			RefSpecs:          opts.RefSpecs,
+		}
 	case opts.SemVer != "":
-		return &CheckoutSemVer{SemVer: opts.SemVer, RecurseSubmodules: opts.RecurseSubmodules}
+		return &CheckoutSemVer{
+			SemVer:            opts.SemVer,
+			TagFilter:         opts.TagFilter,
+			TagKind:           opts.TagKind,
+			RecurseSubmodules: opts.RecurseSubmodules,
+			TimeSource:        opts.TimeSource,
+			RefSpecs:          opts.RefSpecs,
+		}
 	case opts.Tag != "":
-		return &CheckoutTag{Tag: opts.Tag, RecurseSubmodules: opts.RecurseSubmodules, LastRevision: opts.LastRevision}
+		return &CheckoutTag{
+			Tag:               opts.Tag,
+			RecurseSubmodules: opts.RecurseSubmodules,
+			LastRevision:      opts.LastRevision,
+			KeyRings:          opts.TagKeyRings,
+			RequireSignature:  opts.RequireTagSignature,
+			RefSpecs:          opts.RefSpecs,
+		}
 	default:
 		branch := opts.Branch
 		if branch == "" {
 			branch = git.DefaultBranch
 		}
-		return &CheckoutBranch{Branch: branch, RecurseSubmodules: opts.RecurseSubmodules, LastRevision: opts.LastRevision}
+		return &CheckoutBranch{
+			Branch:            branch,
+			RecurseSubmodules: opts.RecurseSubmodules,
+			LastRevision:      opts.LastRevision,
+			RefSpecs:          opts.RefSpecs,
+		}
 	}
 }
 
@@ -62,9 +86,23 @@ type CheckoutBranch struct {
 	Branch            string
 	RecurseSubmodules bool
 	LastRevision      string
+	// RefSpecs are fetched instead of the default refs of the checkout,
+	// see git.CheckoutOptions.RefSpecs.
+	RefSpecs []string
 }
 
 func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutBranch) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(func() (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutBranch) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
 	authMethod, err := transportAuth(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct auth method with options: %w", err)
@@ -80,24 +118,20 @@ func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *g
 
 		if currentRevision != "" && currentRevision == c.LastRevision {
 			// Construct a partial commit with the existing information.
-			// Split the revision and take the last part as the hash.
 			// Example revision: main/43d7eb9c49cdd49b2494efd481aea1166fc22b67
-			var hash git.Hash
-			ss := strings.Split(currentRevision, "/")
-			if len(ss) > 1 {
-				hash = git.Hash(ss[len(ss)-1])
-			} else {
-				hash = git.Hash(ss[0])
+			_, hash, err := git.ParseRevision(currentRevision)
+			if err != nil {
+				return nil, err
 			}
 			c := &git.Commit{
-				Hash:      hash,
+				Hash:      git.Hash(hash),
 				Reference: plumbing.NewBranchReferenceName(c.Branch).String(),
 			}
 			return c, nil
 		}
 	}
 
-	repo, err := extgogit.PlainCloneContext(ctx, path, false, &extgogit.CloneOptions{
+	repo, err := plainClone(ctx, path, &extgogit.CloneOptions{
 		URL:               url,
 		Auth:              authMethod,
 		RemoteName:        git.DefaultOrigin,
@@ -109,9 +143,9 @@ func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *g
 		Progress:          nil,
 		Tags:              extgogit.NoTags,
 		CABundle:          caBundle(opts),
-	})
+	}, refSpecs(c.RefSpecs))
 	if err != nil {
-		return nil, fmt.Errorf("unable to clone '%s': %w", url, gitutil.GoGitError(err))
+		return nil, fmt.Errorf("unable to clone '%s': %w", url, classifyError(url, c.Branch, gitutil.GoGitError(err)))
 	}
 	head, err := repo.Head()
 	if err != nil {
@@ -125,6 +159,14 @@ func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *g
 }
 
 func getLastRevision(ctx context.Context, url string, ref plumbing.ReferenceName, opts *git.AuthOptions, authMethod transport.AuthMethod) (string, error) {
+	if git.IsBundleURL(url) {
+		refs, err := bundleReferences(url)
+		if err != nil {
+			return "", err
+		}
+		return filterRefs(refs, ref), nil
+	}
+
 	config := &config.RemoteConfig{
 		Name: git.DefaultOrigin,
 		URLs: []string{url},
@@ -138,7 +180,7 @@ func getLastRevision(ctx context.Context, url string, ref plumbing.ReferenceName
 	}
 	refs, err := rem.ListContext(ctx, listOpts)
 	if err != nil {
-		return "", fmt.Errorf("unable to list remote for '%s': %w", url, err)
+		return "", fmt.Errorf("unable to list remote for '%s': %w", url, classifyError(url, ref.Short(), err))
 	}
 
 	currentRevision := filterRefs(refs, ref)
@@ -149,9 +191,29 @@ type CheckoutTag struct {
 	Tag               string
 	RecurseSubmodules bool
 	LastRevision      string
+	// KeyRings are the armored PGP key rings and SSH public keys to verify
+	// the signature of the Tag with, see git.VerifyTag.
+	KeyRings []string
+	// RequireSignature fails the checkout if the signature of the Tag is
+	// missing or invalid.
+	RequireSignature bool
+	// RefSpecs are fetched instead of the default refs of the checkout,
+	// see git.CheckoutOptions.RefSpecs.
+	RefSpecs []string
 }
 
 func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutTag) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(func() (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutTag) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
 	authMethod, err := transportAuth(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct auth method with options: %w", err)
@@ -166,23 +228,19 @@ func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.
 
 		if currentRevision != "" && currentRevision == c.LastRevision {
 			// Construct a partial commit with the existing information.
-			// Split the revision and take the last part as the hash.
 			// Example revision: 6.1.4/bf09377bfd5d3bcac1e895fa8ce52dc76695c060
-			var hash git.Hash
-			ss := strings.Split(currentRevision, "/")
-			if len(ss) > 1 {
-				hash = git.Hash(ss[len(ss)-1])
-			} else {
-				hash = git.Hash(ss[0])
+			_, hash, err := git.ParseRevision(currentRevision)
+			if err != nil {
+				return nil, err
 			}
 			c := &git.Commit{
-				Hash:      hash,
+				Hash:      git.Hash(hash),
 				Reference: ref.String(),
 			}
 			return c, nil
 		}
 	}
-	repo, err := extgogit.PlainCloneContext(ctx, path, false, &extgogit.CloneOptions{
+	repo, err := plainClone(ctx, path, &extgogit.CloneOptions{
 		URL:               url,
 		Auth:              authMethod,
 		RemoteName:        git.DefaultOrigin,
@@ -194,9 +252,9 @@ func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.
 		Progress:          nil,
 		Tags:              extgogit.NoTags,
 		CABundle:          caBundle(opts),
-	})
+	}, refSpecs(c.RefSpecs))
 	if err != nil {
-		return nil, fmt.Errorf("unable to clone '%s': %w", url, gitutil.GoGitError(err))
+		return nil, fmt.Errorf("unable to clone '%s': %w", url, classifyError(url, c.Tag, gitutil.GoGitError(err)))
 	}
 	head, err := repo.Head()
 	if err != nil {
@@ -206,16 +264,72 @@ func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve commit object for HEAD '%s': %w", head.Hash(), err)
 	}
-	return buildCommitWithRef(cc, ref)
+	commit, err := buildCommitWithRef(cc, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.KeyRings) > 0 || c.RequireSignature {
+		raw, err := rawTagObject(repo, ref)
+		if err != nil {
+			return nil, err
+		}
+		if commit.TagVerification, err = git.VerifyTag(c.Tag, raw, c.RequireSignature, c.KeyRings...); err != nil {
+			return nil, err
+		}
+	}
+	return commit, nil
+}
+
+// rawTagObject returns the raw content of the tag object the given tag
+// reference points to, or nil for a lightweight tag.
+func rawTagObject(repo *extgogit.Repository, ref plumbing.ReferenceName) ([]byte, error) {
+	r, err := repo.Reference(ref, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tag '%s': %w", ref.Short(), err)
+	}
+	obj, err := repo.Storer.EncodedObject(plumbing.TagObject, r.Hash())
+	if err == plumbing.ErrObjectNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tag object for '%s': %w", ref.Short(), err)
+	}
+	reader, err := obj.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tag object for '%s': %w", ref.Short(), err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
 }
 
 type CheckoutCommit struct {
 	Branch            string
 	Commit            string
 	RecurseSubmodules bool
+	// RequireInBranch defines if the Commit must be reachable from the tip
+	// of the Branch.
+	RequireInBranch bool
+	// RefSpecs are fetched instead of the default refs of the checkout,
+	// see git.CheckoutOptions.RefSpecs.
+	RefSpecs []string
 }
 
 func (c *CheckoutCommit) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutCommit) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(func() (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutCommit) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	if c.RequireInBranch && c.Branch == "" {
+		return nil, fmt.Errorf("a branch is required to verify commit '%s' is reachable from it", c.Commit)
+	}
+
 	authMethod, err := transportAuth(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct auth method with options: %w", err)
@@ -232,12 +346,15 @@ func (c *CheckoutCommit) Checkout(ctx context.Context, path, url string, opts *g
 		CABundle:          caBundle(opts),
 	}
 	if c.Branch != "" {
-		cloneOpts.SingleBranch = true
+		// All branches are fetched when the commit is required to be
+		// reachable from the branch, to tell commits which are not apart
+		// from commits which do not exist.
+		cloneOpts.SingleBranch = !c.RequireInBranch
 		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(c.Branch)
 	}
-	repo, err := extgogit.PlainCloneContext(ctx, path, false, cloneOpts)
+	repo, err := plainClone(ctx, path, cloneOpts, refSpecs(c.RefSpecs))
 	if err != nil {
-		return nil, fmt.Errorf("unable to clone '%s': %w", url, gitutil.GoGitError(err))
+		return nil, fmt.Errorf("unable to clone '%s': %w", url, classifyError(url, c.Branch, gitutil.GoGitError(err)))
 	}
 	w, err := repo.Worktree()
 	if err != nil {
@@ -245,7 +362,12 @@ func (c *CheckoutCommit) Checkout(ctx context.Context, path, url string, opts *g
 	}
 	cc, err := repo.CommitObject(plumbing.NewHash(c.Commit))
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve commit object for '%s': %w", c.Commit, err)
+		return nil, fmt.Errorf("failed to resolve commit object for '%s': %w", c.Commit, classifyError(url, c.Commit, err))
+	}
+	if c.RequireInBranch {
+		if err = commitInBranch(repo, cc, c.Branch); err != nil {
+			return nil, err
+		}
 	}
 	err = w.Checkout(&extgogit.CheckoutOptions{
 		Hash:  cc.Hash,
@@ -257,23 +379,247 @@ func (c *CheckoutCommit) Checkout(ctx context.Context, path, url string, opts *g
 	return buildCommitWithRef(cc, cloneOpts.ReferenceName)
 }
 
+// commitInBranch returns a git.CommitNotInBranchError if the given commit
+// is not reachable from the tip of the remote branch.
+func commitInBranch(repo *extgogit.Repository, cc *object.Commit, branch string) error {
+	ref, err := repo.Reference(plumbing.NewRemoteReferenceName(git.DefaultOrigin, branch), true)
+	if err != nil {
+		return fmt.Errorf("unable to resolve branch '%s': %w", branch, err)
+	}
+	tip, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return fmt.Errorf("unable to resolve commit object for branch '%s': %w", branch, err)
+	}
+	// IsAncestor also considers the tip itself to be reachable.
+	ok, err := cc.IsAncestor(tip)
+	if err != nil {
+		err = shallowHistoryError(repo, "verifying the commit is reachable from the branch", err)
+		return fmt.Errorf("unable to verify commit '%s' is reachable from branch '%s': %w", cc.Hash, branch, err)
+	}
+	if !ok {
+		return &git.CommitNotInBranchError{Commit: cc.Hash.String(), Branch: branch}
+	}
+	return nil
+}
+
+type CheckoutMergeBase struct {
+	BranchA string
+	BranchB string
+}
+
+func (c *CheckoutMergeBase) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutMergeBase) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(func() (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutMergeBase) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	authMethod, err := transportAuth(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct auth method with options: %w", err)
+	}
+
+	repo, err := plainClone(ctx, path, &extgogit.CloneOptions{
+		URL:           url,
+		Auth:          authMethod,
+		RemoteName:    git.DefaultOrigin,
+		ReferenceName: plumbing.NewBranchReferenceName(c.BranchA),
+		SingleBranch:  true,
+		NoCheckout:    true,
+		Progress:      nil,
+		Tags:          extgogit.NoTags,
+		CABundle:      caBundle(opts),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to clone '%s': %w", url, classifyError(url, c.BranchA, gitutil.GoGitError(err)))
+	}
+	// A bundle clone already contains all branches.
+	if !git.IsBundleURL(url) {
+		err = repo.FetchContext(ctx, &extgogit.FetchOptions{
+			RemoteName: git.DefaultOrigin,
+			RefSpecs: []config.RefSpec{
+				config.RefSpec(fmt.Sprintf("+refs/heads/%[1]s:refs/remotes/%[2]s/%[1]s", c.BranchB, git.DefaultOrigin)),
+			},
+			Auth:     authMethod,
+			Tags:     extgogit.NoTags,
+			CABundle: caBundle(opts),
+		})
+		if err != nil && err != extgogit.NoErrAlreadyUpToDate {
+			return nil, fmt.Errorf("unable to fetch branch '%s' from '%s': %w", c.BranchB, url, classifyError(url, c.BranchB, gitutil.GoGitError(err)))
+		}
+	}
+
+	var heads []*object.Commit
+	for _, branch := range []string{c.BranchA, c.BranchB} {
+		ref, err := repo.Reference(plumbing.NewRemoteReferenceName(git.DefaultOrigin, branch), true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve branch '%s': %w", branch, classifyError(url, branch, err))
+		}
+		cc, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve commit object for branch '%s': %w", branch, err)
+		}
+		heads = append(heads, cc)
+	}
+
+	bases, err := heads[0].MergeBase(heads[1])
+	if err != nil {
+		err = shallowHistoryError(repo, "computing the merge-base", err)
+		return nil, fmt.Errorf("failed to compute merge-base of branches '%s' and '%s': %w", c.BranchA, c.BranchB, err)
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("branches '%s' and '%s' share no history: no merge-base found", c.BranchA, c.BranchB)
+	}
+	// With criss-cross merges there can be multiple best common ancestors,
+	// pick one deterministically.
+	sort.Slice(bases, func(i, j int) bool {
+		return bases[i].Hash.String() < bases[j].Hash.String()
+	})
+	base := bases[0]
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Git worktree: %w", err)
+	}
+	if err = w.Checkout(&extgogit.CheckoutOptions{
+		Hash:  base.Hash,
+		Force: true,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to checkout merge-base commit '%s': %w", base.Hash, err)
+	}
+	return buildCommitWithRef(base, "")
+}
+
+// CheckoutBranchAt checks out the last commit of the Branch at or before the
+// Time, similar to git's <branch>@{<date>} but based on the history of the
+// branch instead of the reflog. The first-parent history of the branch is
+// walked from its tip, up to the first commit of which the time selected by
+// the TimeSource is not after the Time.
+type CheckoutBranchAt struct {
+	Branch string
+	Time   time.Time
+	// TimeSource selects the timestamp of the commits compared to the Time,
+	// defaults to git.TimeSourceCommit.
+	TimeSource git.TimeSource
+}
+
+func (c *CheckoutBranchAt) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutBranchAt) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(func() (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutBranchAt) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	authMethod, err := transportAuth(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct auth method with options: %w", err)
+	}
+
+	// The full history of the branch is fetched to be able to walk it.
+	ref := plumbing.NewBranchReferenceName(c.Branch)
+	repo, err := plainClone(ctx, path, &extgogit.CloneOptions{
+		URL:           url,
+		Auth:          authMethod,
+		RemoteName:    git.DefaultOrigin,
+		ReferenceName: ref,
+		SingleBranch:  true,
+		NoCheckout:    true,
+		Progress:      nil,
+		Tags:          extgogit.NoTags,
+		CABundle:      caBundle(opts),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to clone '%s': %w", url, classifyError(url, c.Branch, gitutil.GoGitError(err)))
+	}
+	head, err := repo.Reference(plumbing.NewRemoteReferenceName(git.DefaultOrigin, c.Branch), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve branch '%s': %w", c.Branch, classifyError(url, c.Branch, err))
+	}
+	cc, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve commit object for branch '%s': %w", c.Branch, err)
+	}
+	for git.SignatureTime(c.TimeSource, buildSignature(cc.Author), buildSignature(cc.Committer)).After(c.Time) {
+		if cc.NumParents() == 0 {
+			return nil, fmt.Errorf("no commit on branch '%s' at or before %s", c.Branch, c.Time.UTC().Format(time.RFC3339))
+		}
+		parent, err := cc.Parent(0)
+		if err != nil {
+			err = shallowHistoryError(repo, "walking the branch history", err)
+			return nil, fmt.Errorf("failed to resolve parent of commit '%s': %w", cc.Hash, err)
+		}
+		cc = parent
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Git worktree: %w", err)
+	}
+	if err = w.Checkout(&extgogit.CheckoutOptions{
+		Hash:  cc.Hash,
+		Force: true,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to checkout commit '%s': %w", cc.Hash, err)
+	}
+	return buildCommitWithRef(cc, ref)
+}
+
 type CheckoutSemVer struct {
-	SemVer            string
+	SemVer    string
+	TagFilter string
+	// TagKind selects the kind of tags which are considered, defaults to
+	// git.TagKindAny.
+	TagKind           git.TagKind
 	RecurseSubmodules bool
+	// TimeSource selects the timestamp of the tagged commits which orders
+	// tags with an equal version, defaults to git.TimeSourceCommit.
+	TimeSource git.TimeSource
+	// RefSpecs are fetched instead of the default refs of the checkout,
+	// see git.CheckoutOptions.RefSpecs.
+	RefSpecs []string
 }
 
 func (c *CheckoutSemVer) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutSemVer) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(func() (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutSemVer) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
 	verConstraint, err := semver.NewConstraint(c.SemVer)
 	if err != nil {
 		return nil, fmt.Errorf("semver parse error: %w", err)
 	}
+	tagFilter, err := git.CompileTagFilter(c.TagFilter)
+	if err != nil {
+		return nil, err
+	}
+	tagKind, err := git.ParseTagKind(string(c.TagKind))
+	if err != nil {
+		return nil, err
+	}
 
 	authMethod, err := transportAuth(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct auth method with options: %w", err)
 	}
 
-	repo, err := extgogit.PlainCloneContext(ctx, path, false, &extgogit.CloneOptions{
+	repo, err := plainClone(ctx, path, &extgogit.CloneOptions{
 		URL:               url,
 		Auth:              authMethod,
 		RemoteName:        git.DefaultOrigin,
@@ -283,69 +629,56 @@ func (c *CheckoutSemVer) Checkout(ctx context.Context, path, url string, opts *g
 		Progress:          nil,
 		Tags:              extgogit.AllTags,
 		CABundle:          caBundle(opts),
-	})
+	}, refSpecs(c.RefSpecs))
 	if err != nil {
-		return nil, fmt.Errorf("unable to clone '%s': %w", url, gitutil.GoGitError(err))
+		return nil, fmt.Errorf("unable to clone '%s': %w", url, classifyError(url, "", gitutil.GoGitError(err)))
 	}
 
 	repoTags, err := repo.Tags()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list tags: %w", err)
 	}
-
-	tags := make(map[string]string)
-	tagTimestamps := make(map[string]time.Time)
+	var tags []string
 	if err = repoTags.ForEach(func(t *plumbing.Reference) error {
-		revision := plumbing.Revision(t.Name().String())
-		hash, err := repo.ResolveRevision(revision)
-		if err != nil {
-			return fmt.Errorf("unable to resolve tag revision: %w", err)
+		// Annotated tags refer to a tag object, lightweight tags directly
+		// to a commit.
+		_, err := repo.TagObject(t.Hash())
+		if err != nil && err != plumbing.ErrObjectNotFound {
+			return fmt.Errorf("unable to resolve tag '%s': %w", t.Name().Short(), err)
 		}
-		commit, err := repo.CommitObject(*hash)
-		if err != nil {
-			return fmt.Errorf("unable to resolve commit of a tag revision: %w", err)
+		if tagKind.Matches(err == nil) {
+			tags = append(tags, t.Name().Short())
 		}
-		tagTimestamps[t.Name().Short()] = commit.Committer.When
-
-		tags[t.Name().Short()] = t.Strings()[1]
 		return nil
 	}); err != nil {
 		return nil, err
 	}
 
-	var matchedVersions semver.Collection
-	for tag := range tags {
-		v, err := version.ParseVersion(tag)
+	// The object storage of the repository is not safe for concurrent use.
+	var mu sync.Mutex
+	t, err := git.LatestSemVerTag(tags, verConstraint, tagFilter, func(name string) (time.Time, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		hash, err := repo.ResolveRevision(plumbing.Revision(plumbing.NewTagReferenceName(name)))
 		if err != nil {
-			continue
+			return time.Time{}, fmt.Errorf("unable to resolve tag revision: %w", err)
 		}
-		if !verConstraint.Check(v) {
-			continue
+		commit, err := repo.CommitObject(*hash)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to resolve commit of a tag revision: %w", err)
 		}
-		matchedVersions = append(matchedVersions, v)
+		return git.SignatureTime(c.TimeSource, buildSignature(commit.Author), buildSignature(commit.Committer)), nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	if len(matchedVersions) == 0 {
+	if t == "" {
+		if tagFilter != nil {
+			return nil, fmt.Errorf("no match found for semver: %s with tag filter: %s", c.SemVer, c.TagFilter)
+		}
 		return nil, fmt.Errorf("no match found for semver: %s", c.SemVer)
 	}
 
-	// Sort versions
-	sort.SliceStable(matchedVersions, func(i, j int) bool {
-		left := matchedVersions[i]
-		right := matchedVersions[j]
-
-		if !left.Equal(right) {
-			return left.LessThan(right)
-		}
-
-		// Having tag target timestamps at our disposal, we further try to sort
-		// versions into a chronological order. This is especially important for
-		// versions that differ only by build metadata, because it is not considered
-		// a part of the comparable version in Semver
-		return tagTimestamps[left.Original()].Before(tagTimestamps[right.Original()])
-	})
-	v := matchedVersions[len(matchedVersions)-1]
-	t := v.Original()
-
 	w, err := repo.Worktree()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open Git worktree: %w", err)
@@ -369,6 +702,20 @@ func (c *CheckoutSemVer) Checkout(ctx context.Context, path, url string, opts *g
 	return buildCommitWithRef(cc, ref)
 }
 
+// checkoutWithResult returns the git.CheckoutResult of the given checkout
+// function. The transfer statistics are limited to the duration, as go-git
+// does not report the received objects.
+func checkoutWithResult(checkout func() (*git.Commit, error)) (*git.CheckoutResult, error) {
+	start := time.Now()
+	commit, err := checkout()
+	if err != nil {
+		return nil, err
+	}
+	result := git.NewCheckoutResult(commit)
+	result.Stats.Duration = time.Since(start)
+	return result, nil
+}
+
 func buildCommitWithRef(c *object.Commit, ref plumbing.ReferenceName) (*git.Commit, error) {
 	if c == nil {
 		return nil, errors.New("failed to construct commit: no object")
@@ -416,7 +763,7 @@ func recurseSubmodules(recurse bool) extgogit.SubmoduleRescursivity {
 func filterRefs(refs []*plumbing.Reference, currentRef plumbing.ReferenceName) string {
 	for _, ref := range refs {
 		if ref.Name().String() == currentRef.String() {
-			return fmt.Sprintf("%s/%s", currentRef.Short(), ref.Hash().String())
+			return git.FormatRevision(currentRef.Short(), ref.Hash().String())
 		}
 	}
 
@@ -27,6 +27,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/fluxcd/gitkit"
 	"github.com/fluxcd/pkg/gittestserver"
 	"github.com/fluxcd/pkg/ssh"
@@ -136,6 +139,56 @@ func TestCheckoutBranch_Checkout(t *testing.T) {
 	}
 }
 
+func TestCheckoutBranch_CheckoutWithResult(t *testing.T) {
+	repo, path, err := initRepo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstCommit, err := commitFile(repo, "branch", "init", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		lastRevision string
+		wantNoOp     bool
+	}{
+		{
+			name:     "without LastRevision",
+			wantNoOp: false,
+		},
+		{
+			name:         "LastRevision matches",
+			lastRevision: fmt.Sprintf("master/%s", firstCommit.String()),
+			wantNoOp:     true,
+		},
+		{
+			name:         "LastRevision does not match",
+			lastRevision: "master/0123456789012345678901234567890123456789",
+			wantNoOp:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			branch := CheckoutBranch{
+				Branch:       "master",
+				LastRevision: tt.lastRevision,
+			}
+			result, err := branch.CheckoutWithResult(context.TODO(), t.TempDir(), path, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.NoOp).To(Equal(tt.wantNoOp))
+			g.Expect(result.ResolvedRef).To(Equal("refs/heads/master"))
+			g.Expect(result.Commit.Hash.String()).To(Equal(firstCommit.String()))
+			g.Expect(git.IsConcreteCommit(*result.Commit)).To(Equal(!tt.wantNoOp))
+			g.Expect(result.Stats.Duration).To(BeNumerically(">", 0))
+		})
+	}
+}
+
 func TestCheckoutTag_Checkout(t *testing.T) {
 	type testTag struct {
 		name      string
@@ -246,6 +299,116 @@ func TestCheckoutTag_Checkout(t *testing.T) {
 	}
 }
 
+func TestCheckoutTag_Checkout_verifySignature(t *testing.T) {
+	g := NewWithT(t)
+
+	entity, err := openpgp.NewEntity("Jane Doe", "", "jane@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	g.Expect(err).ToNot(HaveOccurred())
+	keyRing := armoredPublicKey(t, entity)
+	other, err := openpgp.NewEntity("John Doe", "", "john@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	g.Expect(err).ToNot(HaveOccurred())
+	otherKeyRing := armoredPublicKey(t, other)
+
+	repo, path, err := initRepo(t)
+	g.Expect(err).ToNot(HaveOccurred())
+	h, err := commitFile(repo, "tag", "content", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = repo.CreateTag("signed", h, &extgogit.CreateTagOptions{
+		Tagger:  mockSignature(time.Now()),
+		Message: "Signed tag",
+		SignKey: entity,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, h, true, "annotated", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, h, false, "lightweight", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		tag        string
+		keyRings   []string
+		required   bool
+		wantStatus git.TagVerificationStatus
+		wantErr    string
+	}{
+		{
+			name:       "signed tag",
+			tag:        "signed",
+			keyRings:   []string{otherKeyRing, keyRing},
+			required:   true,
+			wantStatus: git.TagSignatureValid,
+		},
+		{
+			name:       "signed tag with mismatched key",
+			tag:        "signed",
+			keyRings:   []string{otherKeyRing},
+			wantStatus: git.TagSignatureInvalid,
+		},
+		{
+			name:     "required signed tag with mismatched key",
+			tag:      "signed",
+			keyRings: []string{otherKeyRing},
+			required: true,
+			wantErr:  "signature verification of tag 'signed' failed",
+		},
+		{
+			name:       "unsigned annotated tag",
+			tag:        "annotated",
+			keyRings:   []string{keyRing},
+			wantStatus: git.TagSignatureMissing,
+		},
+		{
+			name:       "lightweight tag",
+			tag:        "lightweight",
+			keyRings:   []string{keyRing},
+			wantStatus: git.TagSignatureMissing,
+		},
+		{
+			name:     "required signature of lightweight tag",
+			tag:      "lightweight",
+			keyRings: []string{keyRing},
+			required: true,
+			wantErr:  "tag 'lightweight' does not have a signature",
+		},
+		{
+			name: "without verification",
+			tag:  "signed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			checkoutTag := CheckoutTag{
+				Tag:              tt.tag,
+				KeyRings:         tt.keyRings,
+				RequireSignature: tt.required,
+			}
+			cc, err := checkoutTag.Checkout(context.TODO(), t.TempDir(), path, nil)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				var verr *git.TagVerificationError
+				g.Expect(errors.As(err, &verr)).To(BeTrue())
+				g.Expect(cc).To(BeNil())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Hash.String()).To(Equal(h.String()))
+			if tt.wantStatus == "" {
+				g.Expect(cc.TagVerification).To(BeNil())
+				return
+			}
+			g.Expect(cc.TagVerification).ToNot(BeNil())
+			g.Expect(cc.TagVerification.Status).To(Equal(tt.wantStatus))
+			if tt.wantStatus == git.TagSignatureValid {
+				g.Expect(cc.TagVerification.KeyID).To(Equal(entity.PrimaryKey.KeyIdString()))
+			}
+		})
+	}
+}
+
 func TestCheckoutCommit_Checkout(t *testing.T) {
 	repo, path, err := initRepo(t)
 	if err != nil {
@@ -325,6 +488,264 @@ func TestCheckoutCommit_Checkout(t *testing.T) {
 	}
 }
 
+func TestCheckoutCommit_Checkout_requireInBranch(t *testing.T) {
+	repo, path, err := initRepo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstCommit, err := commitFile(repo, "commit", "init", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = createBranch(repo, "other-branch"); err != nil {
+		t.Fatal(err)
+	}
+	otherCommit, err := commitFile(repo, "commit", "other", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		commit      string
+		branch      string
+		expectError error
+	}{
+		{
+			name:   "Commit in branch",
+			commit: firstCommit.String(),
+			branch: "master",
+		},
+		{
+			name:   "Commit at tip of branch",
+			commit: otherCommit.String(),
+			branch: "other-branch",
+		},
+		{
+			name:        "Commit in other branch",
+			commit:      otherCommit.String(),
+			branch:      "master",
+			expectError: &git.CommitNotInBranchError{Commit: otherCommit.String(), Branch: "master"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			commit := CheckoutCommit{
+				Commit:          tt.commit,
+				Branch:          tt.branch,
+				RequireInBranch: true,
+			}
+
+			cc, err := commit.Checkout(context.TODO(), t.TempDir(), path, nil)
+			if tt.expectError != nil {
+				g.Expect(err).To(Equal(tt.expectError))
+				g.Expect(cc).To(BeNil())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Hash.String()).To(Equal(tt.commit))
+		})
+	}
+}
+
+func TestCheckoutCommit_Checkout_requireInBranchWithoutBranch(t *testing.T) {
+	g := NewWithT(t)
+
+	commit := CheckoutCommit{Commit: "a-commit", RequireInBranch: true}
+	_, err := commit.Checkout(context.TODO(), t.TempDir(), "https://example.com/org/repo", nil)
+	g.Expect(err).To(MatchError("a branch is required to verify commit 'a-commit' is reachable from it"))
+}
+
+func TestCheckoutMergeBase_Checkout(t *testing.T) {
+	repo, path, err := initRepo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = commitFile(repo, "commit", "init", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	baseCommit, err := commitFile(repo, "commit", "base", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = createBranch(repo, "feature"); err != nil {
+		t.Fatal(err)
+	}
+	featureCommit, err := commitFile(repo, "commit", "feature", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = checkoutBranch(repo, "master"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = commitFile(repo, "commit", "master", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("orphan"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = commitFile(repo, "commit", "orphan", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		branchA      string
+		branchB      string
+		expectCommit string
+		expectFile   string
+		expectError  string
+	}{
+		{
+			name:         "Diverged branches",
+			branchA:      "master",
+			branchB:      "feature",
+			expectCommit: "HEAD/" + baseCommit.String(),
+			expectFile:   "base",
+		},
+		{
+			name:         "Same branch",
+			branchA:      "feature",
+			branchB:      "feature",
+			expectCommit: "HEAD/" + featureCommit.String(),
+			expectFile:   "feature",
+		},
+		{
+			name:        "Unrelated histories",
+			branchA:     "master",
+			branchB:     "orphan",
+			expectError: "branches 'master' and 'orphan' share no history: no merge-base found",
+		},
+		{
+			name:        "Non existing branch",
+			branchA:     "master",
+			branchB:     "invalid",
+			expectError: "unable to fetch branch 'invalid'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mergeBase := CheckoutMergeBase{
+				BranchA: tt.branchA,
+				BranchB: tt.branchB,
+			}
+
+			tmpDir := t.TempDir()
+
+			cc, err := mergeBase.Checkout(context.TODO(), tmpDir, path, nil)
+			if tt.expectError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.expectError))
+				g.Expect(cc).To(BeNil())
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc).ToNot(BeNil())
+			g.Expect(cc.String()).To(Equal(tt.expectCommit))
+			g.Expect(os.ReadFile(filepath.Join(tmpDir, "commit"))).To(BeEquivalentTo(tt.expectFile))
+		})
+	}
+}
+
+func TestCheckoutBranchAt_Checkout(t *testing.T) {
+	repo, path, err := initRepo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	var commits []plumbing.Hash
+	for i, content := range []string{"first", "second", "third"} {
+		cc, err := commitFile(repo, "commit", content, start.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, cc)
+	}
+
+	tests := []struct {
+		name         string
+		branch       string
+		time         time.Time
+		expectCommit string
+		expectFile   string
+		expectError  string
+	}{
+		{
+			name:         "Time of a commit",
+			branch:       "master",
+			time:         start.Add(time.Hour),
+			expectCommit: "master/" + commits[1].String(),
+			expectFile:   "second",
+		},
+		{
+			name:         "Time between commits",
+			branch:       "master",
+			time:         start.Add(90 * time.Minute),
+			expectCommit: "master/" + commits[1].String(),
+			expectFile:   "second",
+		},
+		{
+			name:         "Time after the tip",
+			branch:       "master",
+			time:         start.Add(24 * time.Hour),
+			expectCommit: "master/" + commits[2].String(),
+			expectFile:   "third",
+		},
+		{
+			name:         "Time in other time zone",
+			branch:       "master",
+			time:         start.In(time.FixedZone("UTC+2", 2*60*60)),
+			expectCommit: "master/" + commits[0].String(),
+			expectFile:   "first",
+		},
+		{
+			name:        "Time before the first commit",
+			branch:      "master",
+			time:        start.Add(-time.Minute),
+			expectError: "no commit on branch 'master' at or before 2022-06-01T11:59:00Z",
+		},
+		{
+			name:        "Non existing branch",
+			branch:      "invalid",
+			time:        start,
+			expectError: "unable to clone",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			branchAt := CheckoutBranchAt{
+				Branch: tt.branch,
+				Time:   tt.time,
+			}
+
+			tmpDir := t.TempDir()
+
+			cc, err := branchAt.Checkout(context.TODO(), tmpDir, path, nil)
+			if tt.expectError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.expectError))
+				g.Expect(cc).To(BeNil())
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc).ToNot(BeNil())
+			g.Expect(cc.String()).To(Equal(tt.expectCommit))
+			g.Expect(os.ReadFile(filepath.Join(tmpDir, "commit"))).To(BeEquivalentTo(tt.expectFile))
+		})
+	}
+}
+
 func TestCheckoutTagSemVer_Checkout(t *testing.T) {
 	now := time.Now()
 
@@ -366,6 +787,7 @@ func TestCheckoutTagSemVer_Checkout(t *testing.T) {
 	tests := []struct {
 		name       string
 		constraint string
+		tagFilter  string
 		expectErr  error
 		expectTag  string
 	}{
@@ -384,6 +806,30 @@ func TestCheckoutTagSemVer_Checkout(t *testing.T) {
 			constraint: ">=1.0.0",
 			expectErr:  errors.New("no match found for semver: >=1.0.0"),
 		},
+		{
+			name:       "Only considers tags matching filter",
+			constraint: ">=0.0.1",
+			tagFilter:  "^v",
+			expectTag:  "v0.1.0+build-3",
+		},
+		{
+			name:       "Only considers tags matching filter with metadata",
+			constraint: "<0.2.0",
+			tagFilter:  `\+build-[12]$`,
+			expectTag:  "v0.1.0+build-2",
+		},
+		{
+			name:       "Errors without match for filter",
+			constraint: ">=0.2.0",
+			tagFilter:  "^v",
+			expectErr:  errors.New("no match found for semver: >=0.2.0 with tag filter: ^v"),
+		},
+		{
+			name:       "Errors on invalid filter",
+			constraint: ">=0.0.1",
+			tagFilter:  "(",
+			expectErr:  errors.New("invalid tag filter '(': error parsing regexp: missing closing ): `(`"),
+		},
 	}
 
 	repo, path, err := initRepo(t)
@@ -409,13 +855,14 @@ func TestCheckoutTagSemVer_Checkout(t *testing.T) {
 			g := NewWithT(t)
 
 			semVer := CheckoutSemVer{
-				SemVer: tt.constraint,
+				SemVer:    tt.constraint,
+				TagFilter: tt.tagFilter,
 			}
 			tmpDir := t.TempDir()
 
 			cc, err := semVer.Checkout(context.TODO(), tmpDir, path, nil)
 			if tt.expectErr != nil {
-				g.Expect(err).To(Equal(tt.expectErr))
+				g.Expect(err).To(MatchError(tt.expectErr.Error()))
 				g.Expect(cc).To(BeNil())
 				return
 			}
@@ -430,19 +877,207 @@ func TestCheckoutTagSemVer_Checkout(t *testing.T) {
 
 // Test_KeyTypes assures support for the different types of keys
 // for SSH Authentication supported by Flux.
+func TestCheckoutSemVer_Checkout_timeSource(t *testing.T) {
+	now := time.Now()
+
+	repo, path, err := initRepo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The commit of the rebased tag was authored before, but committed
+	// after the commit of the other tag.
+	tags := []struct {
+		tag        string
+		authorTime time.Time
+		commitTime time.Time
+	}{
+		{tag: "v1.0.0+rebased", authorTime: now, commitTime: now.Add(2 * time.Hour)},
+		{tag: "v1.0.0+other", authorTime: now.Add(time.Hour), commitTime: now.Add(90 * time.Minute)},
+	}
+	for _, tt := range tags {
+		wt, err := repo.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := wt.Filesystem.Create("tag")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(tt.tag)); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if _, err = wt.Add("tag"); err != nil {
+			t.Fatal(err)
+		}
+		h, err := wt.Commit("Adding: "+tt.tag, &extgogit.CommitOptions{
+			Author:    mockSignature(tt.authorTime),
+			Committer: mockSignature(tt.commitTime),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tag(repo, h, false, tt.tag, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		source    git.TimeSource
+		expectTag string
+	}{
+		{source: git.TimeSourceCommit, expectTag: "v1.0.0+rebased"},
+		{source: git.TimeSourceAuthor, expectTag: "v1.0.0+other"},
+		{source: "", expectTag: "v1.0.0+rebased"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.source), func(t *testing.T) {
+			g := NewWithT(t)
+
+			semVer := CheckoutSemVer{
+				SemVer:     "1.0.0",
+				TimeSource: tt.source,
+			}
+			tmpDir := t.TempDir()
+
+			cc, err := semVer.Checkout(context.TODO(), tmpDir, path, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Reference).To(Equal("refs/tags/" + tt.expectTag))
+			g.Expect(os.ReadFile(filepath.Join(tmpDir, "tag"))).To(BeEquivalentTo(tt.expectTag))
+			g.Expect(cc.AuthorTime()).ToNot(Equal(cc.CommitTime()))
+		})
+	}
+}
+
+func TestCheckoutSemVer_Checkout_tagKind(t *testing.T) {
+	now := time.Now()
+
+	repo, path, err := initRepo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := []struct {
+		tag       string
+		annotated bool
+	}{
+		{tag: "v1.0.0", annotated: true},
+		{tag: "v1.1.0", annotated: false},
+		{tag: "v1.2.0", annotated: true},
+		{tag: "v1.3.0", annotated: false},
+	}
+	for i, tt := range tags {
+		h, err := commitFile(repo, "tag", tt.tag, now.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tag(repo, h, tt.annotated, tt.tag, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		kind      git.TagKind
+		expectTag string
+	}{
+		{kind: git.TagKindAny, expectTag: "v1.3.0"},
+		{kind: git.TagKindAnnotated, expectTag: "v1.2.0"},
+		{kind: git.TagKindLightweight, expectTag: "v1.3.0"},
+		{kind: "", expectTag: "v1.3.0"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			g := NewWithT(t)
+
+			semVer := CheckoutSemVer{
+				SemVer:  "*",
+				TagKind: tt.kind,
+			}
+			tmpDir := t.TempDir()
+
+			cc, err := semVer.Checkout(context.TODO(), tmpDir, path, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Reference).To(Equal("refs/tags/" + tt.expectTag))
+			g.Expect(os.ReadFile(filepath.Join(tmpDir, "tag"))).To(BeEquivalentTo(tt.expectTag))
+		})
+	}
+
+	t.Run("no matching tag", func(t *testing.T) {
+		g := NewWithT(t)
+
+		semVer := CheckoutSemVer{
+			SemVer:  ">=1.3.0",
+			TagKind: git.TagKindAnnotated,
+		}
+		_, err := semVer.Checkout(context.TODO(), t.TempDir(), path, nil)
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("invalid tag kind", func(t *testing.T) {
+		g := NewWithT(t)
+
+		semVer := CheckoutSemVer{
+			SemVer:  "*",
+			TagKind: "invalid",
+		}
+		_, err := semVer.Checkout(context.TODO(), t.TempDir(), path, nil)
+		g.Expect(err).To(MatchError(ContainSubstring("invalid tag kind 'invalid'")))
+	})
+}
+
+func TestCheckout_emptyRepository(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	repoPath := "empty.git"
+	_, err = extgogit.PlainInit(filepath.Join(server.Root(), repoPath), true)
+	g.Expect(err).ToNot(HaveOccurred())
+	repoURL := server.HTTPAddress() + "/" + repoPath
+
+	tests := []struct {
+		name     string
+		strategy git.CheckoutStrategy
+	}{
+		{name: "branch", strategy: &CheckoutBranch{Branch: "master"}},
+		{name: "branch with last revision", strategy: &CheckoutBranch{Branch: "master", LastRevision: "master/0123456789012345678901234567890123456789"}},
+		{name: "tag", strategy: &CheckoutTag{Tag: "v1.0.0"}},
+		{name: "commit", strategy: &CheckoutCommit{Commit: "0123456789012345678901234567890123456789"}},
+		{name: "semver", strategy: &CheckoutSemVer{SemVer: "*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			_, err := tt.strategy.Checkout(context.TODO(), t.TempDir(), repoURL, nil)
+			g.Expect(err).To(HaveOccurred())
+
+			var emptyErr *git.EmptyRepositoryError
+			g.Expect(errors.As(err, &emptyErr)).To(BeTrue(), "expected EmptyRepositoryError, got: %v", err)
+			g.Expect(emptyErr.URL).To(Equal(repoURL))
+		})
+	}
+}
+
 func Test_KeyTypes(t *testing.T) {
 	tests := []struct {
-		name       string
-		keyType    ssh.KeyPairType
-		authorized bool
-		wantErr    string
+		name        string
+		keyType     ssh.KeyPairType
+		authorized  bool
+		wantAuthErr bool
 	}{
 		{name: "RSA 4096", keyType: ssh.RSA_4096, authorized: true},
 		{name: "ECDSA P256", keyType: ssh.ECDSA_P256, authorized: true},
 		{name: "ECDSA P384", keyType: ssh.ECDSA_P384, authorized: true},
 		{name: "ECDSA P521", keyType: ssh.ECDSA_P521, authorized: true},
 		{name: "ED25519", keyType: ssh.ED25519, authorized: true},
-		{name: "unauthorized key", keyType: ssh.RSA_4096, wantErr: "unable to authenticate, attempted methods [none publickey], no supported methods remain"},
+		{name: "unauthorized key", keyType: ssh.RSA_4096, wantAuthErr: true},
 	}
 
 	serverRootDir := t.TempDir()
@@ -519,7 +1154,7 @@ func Test_KeyTypes(t *testing.T) {
 			// Checkout the repo.
 			commit, err := branchCheckoutStrat.Checkout(ctx, tmpDir, repoURL, authOpts)
 
-			if tt.wantErr == "" {
+			if !tt.wantAuthErr {
 				g.Expect(err).ToNot(HaveOccurred())
 				g.Expect(commit).ToNot(BeNil())
 
@@ -529,7 +1164,9 @@ func Test_KeyTypes(t *testing.T) {
 				g.Expect(d).To(HaveLen(2)) // .git and foo.txt
 			} else {
 				g.Expect(err).To(HaveOccurred())
-				g.Expect(err.Error()).Should(ContainSubstring(tt.wantErr))
+				var authErr *git.AuthenticationError
+				g.Expect(errors.As(err, &authErr)).To(BeTrue())
+				g.Expect(authErr.URL).ToNot(BeEmpty())
 			}
 		})
 	}
@@ -850,6 +1487,16 @@ func createBranch(repo *extgogit.Repository, branch string) error {
 	})
 }
 
+func checkoutBranch(repo *extgogit.Repository, branch string) error {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Checkout(&extgogit.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+	})
+}
+
 func commitFile(repo *extgogit.Repository, path, content string, time time.Time) (plumbing.Hash, error) {
 	wt, err := repo.Worktree()
 	if err != nil {
@@ -886,6 +1533,24 @@ func tag(repo *extgogit.Repository, commit plumbing.Hash, annotated bool, tag st
 	return repo.CreateTag(tag, commit, opts)
 }
 
+// armoredPublicKey returns the armored public key of the given entity.
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+
+	var b strings.Builder
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
 func mockSignature(time time.Time) *object.Signature {
 	return &object.Signature{
 		Name:  "Jane Doe",
@@ -0,0 +1,252 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// errCloneNotReusable is returned by fetchClone if the repository at the
+// path can not be updated to the clone options.
+var errCloneNotReusable = errors.New("existing clone can not be reused")
+
+// fetchClone updates the repository previously cloned into path to the
+// given clone options, by fetching the (missing) objects of the reference
+// from the remote and resetting the worktree to it. If refSpecs are given,
+// they are fetched instead of the default refs of the options. It returns
+// extgogit.ErrRepositoryNotExists if there is no repository at the path, and
+// an error wrapping errCloneNotReusable if the repository is of another URL,
+// is corrupt or can not be updated to the options. Errors fetching from the
+// remote are returned as is, as a fresh clone would fail the same way.
+func fetchClone(ctx context.Context, path string, o *extgogit.CloneOptions, refSpecs []config.RefSpec) (*extgogit.Repository, error) {
+	repo, err := extgogit.PlainOpen(path)
+	if err != nil {
+		if errors.Is(err, extgogit.ErrRepositoryNotExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s", errCloneNotReusable, err)
+	}
+	if o.RecurseSubmodules != extgogit.NoRecurseSubmodules {
+		return nil, fmt.Errorf("%w: submodules are not updated", errCloneNotReusable)
+	}
+	remote, err := repo.Remote(o.RemoteName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errCloneNotReusable, err)
+	}
+	if urls := remote.Config().URLs; len(urls) != 1 || urls[0] != o.URL {
+		return nil, fmt.Errorf("%w: remote URL changed", errCloneNotReusable)
+	}
+	// go-git does not unshallow a clone when fetching without a depth, the
+	// clone is recreated instead to obtain the full history.
+	if o.Depth == 0 {
+		shallow, err := isShallow(repo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errCloneNotReusable, err)
+		}
+		if shallow {
+			return nil, fmt.Errorf("%w: shallow clone does not contain the full history", errCloneNotReusable)
+		}
+	}
+	if err = fetchRefs(ctx, repo, o, refSpecs); err != nil {
+		return nil, err
+	}
+	if err = resetToReference(repo, o); err != nil {
+		return nil, fmt.Errorf("%w: %s", errCloneNotReusable, err)
+	}
+	return repo, nil
+}
+
+// isShallow returns if the repository is a shallow clone.
+func isShallow(repo *extgogit.Repository) (bool, error) {
+	shallows, err := repo.Storer.Shallow()
+	if err != nil {
+		return false, err
+	}
+	return len(shallows) > 0, nil
+}
+
+// shallowHistoryError returns a git.ShallowHistoryError for the operation if
+// err is caused by an object missing from a shallow repository, or err
+// otherwise.
+func shallowHistoryError(repo *extgogit.Repository, operation string, err error) error {
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return err
+	}
+	if shallow, _ := isShallow(repo); shallow {
+		return &git.ShallowHistoryError{Operation: operation, Err: err}
+	}
+	return err
+}
+
+// fetchRefSpecsClone initializes a repository at path, and fetches the
+// refSpecs and the reference of the clone options from the remote into it.
+// It is used instead of a clone to fetch refs other than the ones a clone
+// fetches.
+func fetchRefSpecsClone(ctx context.Context, path string, o *extgogit.CloneOptions, refSpecs []config.RefSpec) (*extgogit.Repository, error) {
+	if o.RecurseSubmodules != extgogit.NoRecurseSubmodules {
+		return nil, fmt.Errorf("recursing submodules is not supported in combination with refspecs")
+	}
+	repo, err := extgogit.PlainInit(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize repository: %w", err)
+	}
+	if _, err = repo.CreateRemote(&config.RemoteConfig{Name: o.RemoteName, URLs: []string{o.URL}, Fetch: refSpecs}); err != nil {
+		return nil, fmt.Errorf("failed to configure remote: %w", err)
+	}
+	if err = fetchReference(ctx, repo, o, refSpecs); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// fetchReference fetches the reference of the clone options into the
+// repository, and points HEAD to it like a clone would. If refSpecs are given,
+// they are fetched in addition to the reference instead of the default refs
+// of the options.
+func fetchReference(ctx context.Context, repo *extgogit.Repository, o *extgogit.CloneOptions, refSpecs []config.RefSpec) error {
+	if err := fetchRefs(ctx, repo, o, refSpecs); err != nil {
+		return err
+	}
+	return resetToReference(repo, o)
+}
+
+// fetchRefs fetches the reference of the clone options from the remote into
+// the repository, with the refSpecs or the default refs of the options.
+func fetchRefs(ctx context.Context, repo *extgogit.Repository, o *extgogit.CloneOptions, refSpecs []config.RefSpec) error {
+	custom := len(refSpecs) > 0
+	tags := o.Tags
+	if custom {
+		refSpecs = append([]config.RefSpec{}, refSpecs...)
+		tags = extgogit.NoTags
+	} else if !o.SingleBranch || o.ReferenceName == "" {
+		refSpecs = append(refSpecs, config.RefSpec(fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", o.RemoteName)))
+	}
+	switch {
+	case o.ReferenceName.IsBranch() && (o.SingleBranch || custom):
+		refSpecs = append(refSpecs, config.RefSpec(fmt.Sprintf("+%s:refs/remotes/%s/%s", o.ReferenceName, o.RemoteName, o.ReferenceName.Short())))
+	case o.ReferenceName.IsTag():
+		refSpecs = append(refSpecs, config.RefSpec(fmt.Sprintf("+%[1]s:%[1]s", o.ReferenceName)))
+	}
+	err := repo.FetchContext(ctx, &extgogit.FetchOptions{
+		RemoteName: o.RemoteName,
+		RefSpecs:   refSpecs,
+		Depth:      o.Depth,
+		Auth:       o.Auth,
+		Progress:   o.Progress,
+		Tags:       tags,
+		Force:      true,
+		CABundle:   o.CABundle,
+	})
+	if err != nil && !errors.Is(err, extgogit.NoErrAlreadyUpToDate) {
+		return err
+	}
+	return nil
+}
+
+// resetToReference points HEAD to the fetched reference of the clone options
+// like a clone would, and resets the worktree to it.
+func resetToReference(repo *extgogit.Repository, o *extgogit.CloneOptions) error {
+	var head *plumbing.Reference
+	switch {
+	case o.ReferenceName.IsBranch():
+		remoteRef, err := repo.Reference(plumbing.NewRemoteReferenceName(o.RemoteName, o.ReferenceName.Short()), true)
+		if err != nil {
+			return err
+		}
+		branch := plumbing.NewHashReference(o.ReferenceName, remoteRef.Hash())
+		if err = repo.Storer.SetReference(branch); err != nil {
+			return err
+		}
+		head = plumbing.NewSymbolicReference(plumbing.HEAD, branch.Name())
+	case o.ReferenceName.IsTag():
+		tagRef, err := repo.Reference(o.ReferenceName, true)
+		if err != nil {
+			return err
+		}
+		commit, err := peelToCommit(repo, tagRef.Hash())
+		if err != nil {
+			return err
+		}
+		head = plumbing.NewHashReference(plumbing.HEAD, commit)
+	case o.ReferenceName == "":
+		// HEAD keeps pointing to the default branch of a previous clone,
+		// which is only checked out if it can be resolved.
+	default:
+		return fmt.Errorf("unsupported reference '%s'", o.ReferenceName)
+	}
+	if head != nil {
+		if err := repo.Storer.SetReference(head); err != nil {
+			return err
+		}
+	}
+	if o.NoCheckout {
+		return nil
+	}
+
+	resolved, err := repo.Head()
+	if err != nil {
+		if o.ReferenceName == "" && errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil
+		}
+		return err
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err = w.Reset(&extgogit.ResetOptions{Commit: resolved.Hash(), Mode: extgogit.HardReset}); err != nil {
+		return err
+	}
+	return w.Clean(&extgogit.CleanOptions{Dir: true})
+}
+
+// refSpecs returns the given refspecs as config.RefSpec.
+func refSpecs(specs []string) []config.RefSpec {
+	if len(specs) == 0 {
+		return nil
+	}
+	rs := make([]config.RefSpec, 0, len(specs))
+	for _, spec := range specs {
+		rs = append(rs, config.RefSpec(spec))
+	}
+	return rs
+}
+
+// removeContents removes the contents of the directory at path, but not the
+// directory itself.
+func removeContents(path string) error {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(path, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// reuseMarker is written into the Git directory of a clone, to tell a reused
+// clone apart from a fresh one.
+const reuseMarker = "reuse-marker"
+
+func TestPlainClone_reuse(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "file", "init", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	path := t.TempDir()
+	branch := &CheckoutBranch{Branch: git.DefaultBranch}
+	_, err = branch.Checkout(context.TODO(), path, repoPath, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(os.WriteFile(filepath.Join(path, extgogit.GitDirName, reuseMarker), nil, 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(path, "untracked"), nil, 0o644)).To(Succeed())
+
+	t.Run("fetches into existing clone", func(t *testing.T) {
+		g := NewWithT(t)
+
+		second, err := commitFile(repo, "file", "second", time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		cc, err := branch.Checkout(context.TODO(), path, repoPath, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cc.Hash.String()).To(Equal(second.String()))
+		g.Expect(filepath.Join(path, extgogit.GitDirName, reuseMarker)).To(BeARegularFile())
+		g.Expect(os.ReadFile(filepath.Join(path, "file"))).To(BeEquivalentTo("second"))
+		g.Expect(filepath.Join(path, "untracked")).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("fetches tag into existing clone", func(t *testing.T) {
+		g := NewWithT(t)
+
+		third, err := commitFile(repo, "file", "third", time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = tag(repo, third, true, "v1.0.0", time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		checkoutTag := &CheckoutTag{Tag: "v1.0.0"}
+		cc, err := checkoutTag.Checkout(context.TODO(), path, repoPath, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cc.Hash.String()).To(Equal(third.String()))
+		g.Expect(filepath.Join(path, extgogit.GitDirName, reuseMarker)).To(BeARegularFile())
+		g.Expect(os.ReadFile(filepath.Join(path, "file"))).To(BeEquivalentTo("third"))
+	})
+
+	t.Run("clones again if the URL changed", func(t *testing.T) {
+		g := NewWithT(t)
+
+		other, otherPath, err := initRepo(t)
+		g.Expect(err).ToNot(HaveOccurred())
+		otherCommit, err := commitFile(other, "file", "other", time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		dir := t.TempDir()
+		_, err = branch.Checkout(context.TODO(), dir, repoPath, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(os.WriteFile(filepath.Join(dir, extgogit.GitDirName, reuseMarker), nil, 0o644)).To(Succeed())
+
+		cc, err := branch.Checkout(context.TODO(), dir, otherPath, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cc.Hash.String()).To(Equal(otherCommit.String()))
+		g.Expect(filepath.Join(dir, extgogit.GitDirName, reuseMarker)).ToNot(BeAnExistingFile())
+		g.Expect(os.ReadFile(filepath.Join(dir, "file"))).To(BeEquivalentTo("other"))
+	})
+
+	t.Run("clones again if the clone is corrupt", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		_, err = branch.Checkout(context.TODO(), dir, repoPath, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(os.WriteFile(filepath.Join(dir, extgogit.GitDirName, reuseMarker), nil, 0o644)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, extgogit.GitDirName, "config"), []byte("[invalid"), 0o644)).To(Succeed())
+
+		head, err := repo.Head()
+		g.Expect(err).ToNot(HaveOccurred())
+
+		cc, err := branch.Checkout(context.TODO(), dir, repoPath, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cc.Hash.String()).To(Equal(head.Hash().String()))
+		g.Expect(filepath.Join(dir, extgogit.GitDirName, reuseMarker)).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("keeps the clone if the fetch fails", func(t *testing.T) {
+		g := NewWithT(t)
+
+		missing := &CheckoutBranch{Branch: "missing"}
+		_, err := missing.Checkout(context.TODO(), path, repoPath, nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(filepath.Join(path, extgogit.GitDirName, reuseMarker)).To(BeARegularFile())
+		g.Expect(os.ReadFile(filepath.Join(path, "file"))).To(BeEquivalentTo("third"))
+	})
+}
+
+func TestPlainClone_refSpecs(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t)
+	g.Expect(err).ToNot(HaveOccurred())
+	first, err := commitFile(repo, "file", "init", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, first, false, "v0.1.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, b := range []string{"release/v1", "feature"} {
+		g.Expect(createBranch(repo, b)).To(Succeed())
+		_, err = commitFile(repo, "file", b, time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	g.Expect(checkoutBranch(repo, git.DefaultBranch)).To(Succeed())
+
+	path := t.TempDir()
+	branch := &CheckoutBranch{
+		Branch:   git.DefaultBranch,
+		RefSpecs: []string{"+refs/heads/release/*:refs/remotes/origin/release/*"},
+	}
+	cc, err := branch.Checkout(context.TODO(), path, repoPath, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(first.String()))
+	g.Expect(os.ReadFile(filepath.Join(path, "file"))).To(BeEquivalentTo("init"))
+
+	clone, err := extgogit.PlainOpen(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = clone.Reference(plumbing.NewRemoteReferenceName("origin", "release/v1"), false)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = clone.Reference(plumbing.NewRemoteReferenceName("origin", "feature"), false)
+	g.Expect(err).To(Equal(plumbing.ErrReferenceNotFound))
+	_, err = clone.Reference(plumbing.NewTagReferenceName("v0.1.0"), false)
+	g.Expect(err).To(Equal(plumbing.ErrReferenceNotFound))
+
+	t.Run("fetches into existing clone", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(os.WriteFile(filepath.Join(path, extgogit.GitDirName, reuseMarker), nil, 0o644)).To(Succeed())
+		second, err := commitFile(repo, "file", "second", time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		cc, err := branch.Checkout(context.TODO(), path, repoPath, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cc.Hash.String()).To(Equal(second.String()))
+		g.Expect(filepath.Join(path, extgogit.GitDirName, reuseMarker)).To(BeARegularFile())
+		g.Expect(os.ReadFile(filepath.Join(path, "file"))).To(BeEquivalentTo("second"))
+	})
+
+	t.Run("errors on submodules", func(t *testing.T) {
+		g := NewWithT(t)
+
+		branch := &CheckoutBranch{
+			Branch:            git.DefaultBranch,
+			RecurseSubmodules: true,
+			RefSpecs:          branch.RefSpecs,
+		}
+		_, err := branch.Checkout(context.TODO(), t.TempDir(), repoPath, nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("recursing submodules is not supported in combination with refspecs"))
+	})
+}
+
+func TestPlainClone_unshallow(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t)
+	g.Expect(err).ToNot(HaveOccurred())
+	base, err := commitFile(repo, "file", "base", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(createBranch(repo, "feature")).To(Succeed())
+	feature, err := commitFile(repo, "file", "feature", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(checkoutBranch(repo, git.DefaultBranch)).To(Succeed())
+	_, err = commitFile(repo, "file", "second", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "file", "third", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	shallowClone := func(t *testing.T, path string) {
+		g := NewWithT(t)
+
+		for _, b := range []string{git.DefaultBranch, "feature"} {
+			_, err := (&CheckoutBranch{Branch: b}).Checkout(context.TODO(), path, repoPath, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+		clone, err := extgogit.PlainOpen(path)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(isShallow(clone)).To(BeTrue())
+	}
+
+	t.Run("does not reuse shallow clone for full history", func(t *testing.T) {
+		g := NewWithT(t)
+
+		path := t.TempDir()
+		shallowClone(t, path)
+
+		_, err := fetchClone(context.TODO(), path, &extgogit.CloneOptions{
+			URL:           repoPath,
+			RemoteName:    git.DefaultOrigin,
+			ReferenceName: plumbing.NewBranchReferenceName(git.DefaultBranch),
+			SingleBranch:  true,
+		}, nil)
+		g.Expect(errors.Is(err, errCloneNotReusable)).To(BeTrue())
+		g.Expect(err.Error()).To(ContainSubstring("shallow clone does not contain the full history"))
+	})
+
+	t.Run("recreates shallow clone for merge-base", func(t *testing.T) {
+		g := NewWithT(t)
+
+		path := t.TempDir()
+		shallowClone(t, path)
+
+		mergeBase := &CheckoutMergeBase{BranchA: git.DefaultBranch, BranchB: "feature"}
+		cc, err := mergeBase.Checkout(context.TODO(), path, repoPath, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cc.Hash.String()).To(Equal(base.String()))
+		g.Expect(os.ReadFile(filepath.Join(path, "file"))).To(BeEquivalentTo("base"))
+
+		clone, err := extgogit.PlainOpen(path)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(isShallow(clone)).To(BeFalse())
+	})
+
+	t.Run("errors on missing history in shallow clone", func(t *testing.T) {
+		g := NewWithT(t)
+
+		path := t.TempDir()
+		shallowClone(t, path)
+
+		clone, err := extgogit.PlainOpen(path)
+		g.Expect(err).ToNot(HaveOccurred())
+		cc, err := clone.CommitObject(feature)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		err = commitInBranch(clone, cc, git.DefaultBranch)
+		var shallowErr *git.ShallowHistoryError
+		g.Expect(errors.As(err, &shallowErr)).To(BeTrue())
+		g.Expect(err.Error()).To(ContainSubstring("requires the full history, but the clone is shallow"))
+	})
+}
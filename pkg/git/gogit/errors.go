@@ -0,0 +1,55 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"errors"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// classifyError wraps the given go-git error returned by an operation on
+// the remote at url into the typed error of the git package it can be
+// recognised as, or returns git.ClassifyError for it. ref is the reference
+// the operation was performed for, and may be empty.
+func classifyError(url, ref string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if err := git.ClassifyError(url, ref, err); git.IsClassified(err) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrInvalidAuthMethod):
+		return &git.AuthenticationError{URL: url, Err: err}
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		return &git.RepositoryNotFoundError{URL: url, Err: err}
+	case errors.Is(err, transport.ErrEmptyRemoteRepository):
+		return &git.EmptyRepositoryError{URL: url, Err: err}
+	case errors.Is(err, plumbing.ErrReferenceNotFound), errors.Is(err, plumbing.ErrObjectNotFound),
+		errors.Is(err, extgogit.NoMatchingRefSpecError{}):
+		return &git.ReferenceNotFoundError{Reference: ref, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+func Test_classifyError(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		ref     string
+		err     error
+		wantErr interface{}
+	}{
+		{
+			name:    "authentication required",
+			url:     "https://example.com/org/repo",
+			err:     transport.ErrAuthenticationRequired,
+			wantErr: &git.AuthenticationError{URL: "https://example.com/org/repo", Err: transport.ErrAuthenticationRequired},
+		},
+		{
+			name:    "authorization failed",
+			url:     "https://example.com/org/repo",
+			err:     fmt.Errorf("unable to list: %w", transport.ErrAuthorizationFailed),
+			wantErr: &git.AuthenticationError{URL: "https://example.com/org/repo", Err: fmt.Errorf("unable to list: %w", transport.ErrAuthorizationFailed)},
+		},
+		{
+			name:    "repository not found",
+			url:     "https://example.com/org/repo",
+			err:     transport.ErrRepositoryNotFound,
+			wantErr: &git.RepositoryNotFoundError{URL: "https://example.com/org/repo", Err: transport.ErrRepositoryNotFound},
+		},
+		{
+			name:    "empty remote repository",
+			url:     "https://example.com/org/repo",
+			err:     fmt.Errorf("unable to clone: %w", transport.ErrEmptyRemoteRepository),
+			wantErr: &git.EmptyRepositoryError{URL: "https://example.com/org/repo", Err: fmt.Errorf("unable to clone: %w", transport.ErrEmptyRemoteRepository)},
+		},
+		{
+			name:    "reference not found",
+			url:     "https://example.com/org/repo",
+			ref:     "main",
+			err:     plumbing.ErrReferenceNotFound,
+			wantErr: &git.ReferenceNotFoundError{Reference: "main", Err: plumbing.ErrReferenceNotFound},
+		},
+		{
+			name:    "object not found",
+			url:     "https://example.com/org/repo",
+			ref:     "6b4b2a0",
+			err:     fmt.Errorf("unable to resolve commit: %w", plumbing.ErrObjectNotFound),
+			wantErr: &git.ReferenceNotFoundError{Reference: "6b4b2a0", Err: fmt.Errorf("unable to resolve commit: %w", plumbing.ErrObjectNotFound)},
+		},
+		{
+			name: "ssh authentication message",
+			url:  "ssh://git@example.com/org/repo",
+			err:  errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey], no supported methods remain"),
+			wantErr: &git.AuthenticationError{
+				URL: "ssh://git@example.com/org/repo",
+				Err: errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey], no supported methods remain"),
+			},
+		},
+		{
+			name:    "proxy status message",
+			url:     "https://example.com/org/repo",
+			err:     errors.New("proxyconnect tcp: 404 Not Found"),
+			wantErr: errors.New("proxyconnect tcp: 404 Not Found"),
+		},
+		{
+			name: "nil error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := classifyError(tt.url, tt.ref, tt.err)
+			if tt.wantErr == nil {
+				g.Expect(err).To(BeNil())
+				return
+			}
+			g.Expect(err).To(Equal(tt.wantErr))
+			g.Expect(errors.Is(err, tt.err)).To(BeTrue())
+		})
+	}
+}
@@ -21,3 +21,10 @@ import "github.com/fluxcd/source-controller/pkg/git"
 const (
 	Implementation git.Implementation = "go-git"
 )
+
+func init() {
+	git.DefaultAheadBehind = AheadBehind
+	git.DefaultChangedFiles = ChangedFiles
+	git.DefaultReadNotes = ReadNotes
+	git.DefaultListRefs = ListRefs
+}
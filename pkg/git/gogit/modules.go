@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5/config"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+)
+
+// GitModulesFile is the name of the file defining the submodules of a Git
+// tree.
+const GitModulesFile = ".gitmodules"
+
+// Submodule is a submodule defined in a .gitmodules file.
+type Submodule struct {
+	// Name is the name of the submodule.
+	Name string
+	// Path is the path of the submodule, relative to the root of the tree.
+	Path string
+	// URL is the URL of the submodule, which may be relative to the URL of
+	// the parent repository.
+	URL string
+	// Branch is the branch of the submodule to track, if any.
+	Branch string
+}
+
+// ParseSubmodules parses the given content of a .gitmodules file into
+// Submodules, sorted by path. An error is returned if a submodule does not
+// have a path or URL, or has a path outside the tree.
+func ParseSubmodules(b []byte) ([]Submodule, error) {
+	// Decode the raw configuration, as config.Modules silently drops the
+	// submodules with a path outside the tree.
+	raw := format.New()
+	if err := format.NewDecoder(bytes.NewReader(b)).Decode(raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", GitModulesFile, err)
+	}
+
+	subsections := raw.Section("submodule").Subsections
+	submodules := make([]Submodule, 0, len(subsections))
+	for _, s := range subsections {
+		m := config.Submodule{
+			Name:   s.Name,
+			Path:   s.Option("path"),
+			URL:    s.Option("url"),
+			Branch: s.Option("branch"),
+		}
+		if err := m.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid submodule '%s': %w", m.Name, err)
+		}
+		submodules = append(submodules, Submodule{
+			Name:   m.Name,
+			Path:   m.Path,
+			URL:    m.URL,
+			Branch: m.Branch,
+		})
+	}
+	sort.Slice(submodules, func(i, j int) bool {
+		return submodules[i].Path < submodules[j].Path
+	})
+	return submodules, nil
+}
+
+// ReadSubmodules reads the .gitmodules file of the tree checked out at the
+// given path. It returns no Submodules if the file does not exist.
+func ReadSubmodules(root string) ([]Submodule, error) {
+	b, err := os.ReadFile(filepath.Join(root, GitModulesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", GitModulesFile, err)
+	}
+	return ParseSubmodules(b)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseSubmodules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Submodule
+		wantErr string
+	}{
+		{
+			name: "multiple submodules",
+			content: `[submodule "libs/foo"]
+	path = libs/foo
+	url = https://github.com/example/foo.git
+[submodule "bar"]
+	path = bar
+	url = ../bar.git
+	branch = main
+`,
+			want: []Submodule{
+				{Name: "bar", Path: "bar", URL: "../bar.git", Branch: "main"},
+				{Name: "libs/foo", Path: "libs/foo", URL: "https://github.com/example/foo.git"},
+			},
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    []Submodule{},
+		},
+		{
+			name:    "missing URL",
+			content: "[submodule \"foo\"]\n\tpath = foo\n",
+			wantErr: "invalid submodule 'foo'",
+		},
+		{
+			name:    "path outside tree",
+			content: "[submodule \"foo\"]\n\tpath = ../foo\n\turl = https://example.com/foo\n",
+			wantErr: "invalid submodule 'foo'",
+		},
+		{
+			name:    "invalid syntax",
+			content: "[submodule \"foo\"\n",
+			wantErr: "failed to parse .gitmodules",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := ParseSubmodules([]byte(tt.content))
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestReadSubmodules(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	got, err := ReadSubmodules(root)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(BeNil())
+
+	g.Expect(os.WriteFile(filepath.Join(root, GitModulesFile),
+		[]byte("[submodule \"foo\"]\n\tpath = foo\n\turl = https://example.com/foo\n"), 0o600)).To(Succeed())
+	got, err = ReadSubmodules(root)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal([]Submodule{{Name: "foo", Path: "foo", URL: "https://example.com/foo"}}))
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/fluxcd/pkg/gitutil"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// FetchNotes fetches the notes refs from the remote at url into the
+// repository at path, see git.FetchNotesFunc.
+func FetchNotes(ctx context.Context, path, url string, opts *git.AuthOptions) error {
+	repo, err := extgogit.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("failed to open Git repository: %w", err)
+	}
+	authMethod, err := transportAuth(opts)
+	if err != nil {
+		return fmt.Errorf("failed to construct auth method with options: %w", err)
+	}
+
+	remote := extgogit.NewRemote(repo.Storer, &config.RemoteConfig{
+		Name: git.DefaultOrigin,
+		URLs: []string{url},
+	})
+	err = remote.FetchContext(ctx, &extgogit.FetchOptions{
+		RemoteName: git.DefaultOrigin,
+		RefSpecs:   []config.RefSpec{git.NotesRefSpec},
+		Auth:       authMethod,
+		Depth:      1,
+		Tags:       extgogit.NoTags,
+		CABundle:   caBundle(opts),
+	})
+	if err != nil && !errors.Is(err, extgogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("unable to fetch notes from '%s': %w", url, classifyError(url, "", gitutil.GoGitError(err)))
+	}
+	return nil
+}
+
+// ReadNotes returns the note attached to the commit with the given SHA in
+// the notes ref of the repository at repoPath. The ref can be given by its
+// full name or relative to "refs/notes/", and defaults to git.DefaultNotesRef.
+// See git.ReadNotesFunc.
+func ReadNotes(repoPath, ref, commitSHA string) ([]byte, error) {
+	commitSHA = strings.ToLower(commitSHA)
+	if !plumbing.IsHash(commitSHA) {
+		return nil, fmt.Errorf("invalid commit SHA '%s'", commitSHA)
+	}
+	switch {
+	case ref == "":
+		ref = git.DefaultNotesRef
+	case !strings.HasPrefix(ref, "refs/"):
+		ref = "refs/notes/" + ref
+	}
+
+	repo, err := extgogit.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+	r, err := repo.Reference(plumbing.ReferenceName(ref), true)
+	if err != nil {
+		err = fmt.Errorf("failed to resolve notes ref '%s': %w", ref, err)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			err = &git.ReferenceNotFoundError{Reference: ref, Err: err}
+		}
+		return nil, err
+	}
+	commit, err := repo.CommitObject(r.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve commit of notes ref '%s': %w", ref, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tree of notes ref '%s': %w", ref, err)
+	}
+	return readNote(tree, commitSHA)
+}
+
+// readNote returns the content of the note for the given SHA in the tree of
+// a notes commit. Depending on the number of notes, git fans out the notes
+// into directories named after the leading characters of the SHA, e.g.
+// "ab/cdef...".
+func readNote(tree *object.Tree, sha string) ([]byte, error) {
+	for {
+		var next *object.Tree
+		for _, e := range tree.Entries {
+			if !strings.HasPrefix(sha, e.Name) {
+				continue
+			}
+			if e.Name == sha && e.Mode.IsFile() {
+				f, err := tree.TreeEntryFile(&e)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read note of commit '%s': %w", sha, err)
+				}
+				return readBlob(f)
+			}
+			if e.Mode == filemode.Dir && len(e.Name) < len(sha) {
+				t, err := tree.Tree(e.Name)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read notes tree '%s': %w", e.Name, err)
+				}
+				next, sha = t, sha[len(e.Name):]
+				break
+			}
+		}
+		if next == nil {
+			return nil, nil
+		}
+		tree = next
+	}
+}
+
+func readBlob(f *object.File) ([]byte, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// storeObject encodes the given object into the storage, and returns its
+// hash.
+func storeObject(s storage.Storer, o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// storeBlob stores a blob with the given content, and returns its hash.
+func storeBlob(s storage.Storer, content string) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err = w.Write([]byte(content)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err = w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// addNote commits a note with the given content for the commit to the
+// given notes ref. If fanout is set, the note is stored in a directory
+// named after the first two characters of the commit SHA, as git does for
+// large numbers of notes.
+func addNote(repo *extgogit.Repository, ref plumbing.ReferenceName, commit plumbing.Hash, content string, fanout bool) error {
+	blob, err := storeBlob(repo.Storer, content)
+	if err != nil {
+		return err
+	}
+	sha := commit.String()
+	tree := &object.Tree{Entries: []object.TreeEntry{{Name: sha, Mode: filemode.Regular, Hash: blob}}}
+	if fanout {
+		subtree, err := storeObject(repo.Storer, &object.Tree{Entries: []object.TreeEntry{
+			{Name: sha[2:], Mode: filemode.Regular, Hash: blob},
+		}})
+		if err != nil {
+			return err
+		}
+		tree = &object.Tree{Entries: []object.TreeEntry{{Name: sha[:2], Mode: filemode.Dir, Hash: subtree}}}
+	}
+	treeHash, err := storeObject(repo.Storer, tree)
+	if err != nil {
+		return err
+	}
+	sig := object.Signature{Name: "Jane Doe", Email: "jane@example.com", When: time.Now()}
+	notes, err := storeObject(repo.Storer, &object.Commit{
+		Author:    sig,
+		Committer: sig,
+		Message:   "Notes added by 'git notes add'",
+		TreeHash:  treeHash,
+	})
+	if err != nil {
+		return err
+	}
+	return repo.Storer.SetReference(plumbing.NewHashReference(ref, notes))
+}
+
+func TestReadNotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		noteRef plumbing.ReferenceName
+		fanout  bool
+		ref     string
+		sha     func(commit plumbing.Hash) string
+		want    []byte
+		wantErr string
+	}{
+		{
+			name:    "default notes ref",
+			noteRef: git.DefaultNotesRef,
+			want:    []byte("build: passed\n"),
+		},
+		{
+			name:    "fanout",
+			noteRef: git.DefaultNotesRef,
+			fanout:  true,
+			want:    []byte("build: passed\n"),
+		},
+		{
+			name:    "short notes ref",
+			noteRef: "refs/notes/provenance",
+			ref:     "provenance",
+			want:    []byte("build: passed\n"),
+		},
+		{
+			name:    "full notes ref",
+			noteRef: "refs/notes/provenance",
+			ref:     "refs/notes/provenance",
+			want:    []byte("build: passed\n"),
+		},
+		{
+			name:    "commit without note",
+			noteRef: git.DefaultNotesRef,
+			sha:     func(plumbing.Hash) string { return "0123456789012345678901234567890123456789" },
+		},
+		{
+			name:    "invalid commit SHA",
+			noteRef: git.DefaultNotesRef,
+			sha:     func(plumbing.Hash) string { return "main" },
+			wantErr: "invalid commit SHA 'main'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			repo, err := extgogit.PlainInit(dir, false)
+			g.Expect(err).ToNot(HaveOccurred())
+			wt, err := repo.Worktree()
+			g.Expect(err).ToNot(HaveOccurred())
+			commit, err := wt.Commit("Initial commit", &extgogit.CommitOptions{
+				Author: &object.Signature{Name: "Jane Doe", Email: "jane@example.com", When: time.Now()},
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(addNote(repo, tt.noteRef, commit, "build: passed\n", tt.fanout)).To(Succeed())
+
+			sha := commit.String()
+			if tt.sha != nil {
+				sha = tt.sha(commit)
+			}
+			got, err := ReadNotes(dir, tt.ref, sha)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestReadNotes_missingNotesRef(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	_, err := extgogit.PlainInit(dir, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = ReadNotes(dir, "", "0123456789012345678901234567890123456789")
+	g.Expect(errors.Is(err, plumbing.ErrReferenceNotFound)).To(BeTrue())
+	var refErr *git.ReferenceNotFoundError
+	g.Expect(errors.As(err, &refErr)).To(BeTrue())
+	g.Expect(refErr.Reference).To(Equal(git.DefaultNotesRef))
+
+	// The gogit package registers ReadNotes as git.DefaultReadNotes.
+	_, err = git.ReadNotes(dir, "", "0123456789012345678901234567890123456789")
+	g.Expect(errors.As(err, &refErr)).To(BeTrue())
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// ListRefs returns the branches and tags of the remote repository at url,
+// sorted by name, with annotated tags peeled to the tagged commit. The
+// references are obtained from a single advertisement of the remote,
+// without cloning the repository. See git.ListRefsFunc, it is used through
+// git.ListRefs to apply the host policy and limits.
+func ListRefs(ctx context.Context, url string, opts *git.AuthOptions) ([]git.Ref, error) {
+	authMethod, err := transportAuth(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct auth method with options: %w", err)
+	}
+
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL '%s': %w", url, err)
+	}
+	ep.CaBundle = caBundle(opts)
+	c, err := client.NewClient(ep)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.NewUploadPackSession(ep, authMethod)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list remote for '%s': %w", url, classifyError(url, "", err))
+	}
+	defer s.Close()
+	ar, err := s.AdvertisedReferencesContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list remote for '%s': %w", url, classifyError(url, "", err))
+	}
+
+	refs := make(map[string]string, len(ar.References))
+	for name, hash := range ar.References {
+		refs[name] = hash.String()
+	}
+	peeled := make(map[string]string, len(ar.Peeled))
+	for name, hash := range ar.Peeled {
+		peeled[name] = hash.String()
+	}
+	return git.NewRefs(refs, peeled), nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+func TestListRefs(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, path, err := initRepo(t)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	first, err := commitFile(repo, "file", "first", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	lightweight, err := tag(repo, first, false, "v1.0.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(createBranch(repo, "feature")).To(Succeed())
+	second, err := commitFile(repo, "file", "second", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	annotated, err := tag(repo, second, true, "v1.1.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	got, err := ListRefs(context.TODO(), path, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal([]git.Ref{
+		{Name: "refs/heads/feature", Type: git.RefTypeBranch, Hash: second.String()},
+		{Name: "refs/heads/master", Type: git.RefTypeBranch, Hash: first.String()},
+		{Name: "refs/tags/v1.0.0", Type: git.RefTypeTag, Hash: lightweight.Hash().String()},
+		{Name: "refs/tags/v1.1.0", Type: git.RefTypeTag, Hash: annotated.Hash().String(), Peeled: second.String()},
+	}))
+
+	// The annotated tag reference points to the tag object.
+	g.Expect(annotated.Hash()).ToNot(Equal(second))
+	_, err = repo.TagObject(annotated.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lightweight.Hash()).To(Equal(first))
+}
+
+func TestListRefs_notFound(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := ListRefs(context.TODO(), t.TempDir(), nil)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unable to list remote"))
+}
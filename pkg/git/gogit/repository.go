@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// AheadBehind returns the number of commits head is ahead and behind base in
+// the Git repository checked out at repoPath. The base and head revisions
+// can be anything understood by git rev-parse, e.g. a commit SHA, a branch
+// or a tag. See git.AheadBehindFunc.
+func AheadBehind(repoPath, base, head string) (ahead, behind int, err error) {
+	repo, err := extgogit.PlainOpen(repoPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open repository: %w", err)
+	}
+	baseCommit, err := resolveCommit(repo, base)
+	if err != nil {
+		return 0, 0, err
+	}
+	headCommit, err := resolveCommit(repo, head)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	baseHistory, err := reachableCommits(baseCommit)
+	if err != nil {
+		return 0, 0, err
+	}
+	headHistory, err := reachableCommits(headCommit)
+	if err != nil {
+		return 0, 0, err
+	}
+	for h := range headHistory {
+		if _, ok := baseHistory[h]; !ok {
+			ahead++
+		}
+	}
+	for h := range baseHistory {
+		if _, ok := headHistory[h]; !ok {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// resolveCommit resolves the given revision to a commit in the repository,
+// peeling annotated tags.
+func resolveCommit(repo *extgogit.Repository, rev string) (*object.Commit, error) {
+	h, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve revision '%s': %w", rev, err)
+	}
+	if t, err := repo.TagObject(*h); err == nil {
+		c, err := t.Commit()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve tag '%s' to commit: %w", rev, err)
+		}
+		return c, nil
+	}
+	c, err := repo.CommitObject(*h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve revision '%s' to commit: %w", rev, err)
+	}
+	return c, nil
+}
+
+// reachableCommits returns the hashes of all commits reachable from the given
+// commit, including the commit itself.
+func reachableCommits(c *object.Commit) (map[plumbing.Hash]struct{}, error) {
+	iter := object.NewCommitPreorderIter(c, nil, nil)
+	defer iter.Close()
+
+	history := make(map[plumbing.Hash]struct{})
+	err := iter.ForEach(func(c *object.Commit) error {
+		history[c.Hash] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk history of commit '%s': %w", c.Hash, err)
+	}
+	return history, nil
+}
+
+// ChangedFiles returns the files which changed between the fromSHA and toSHA
+// commits in the Git repository checked out at repoPath, sorted by path.
+// Renames are detected based on the similarity of the file contents, and
+// reported as a single git.FileRenamed change. If fromSHA is empty, all files in
+// toSHA are reported as added. See git.ChangedFilesFunc.
+func ChangedFiles(repoPath, fromSHA, toSHA string) ([]git.FileChange, error) {
+	repo, err := extgogit.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	var fromTree *object.Tree
+	if fromSHA != "" {
+		c, err := resolveCommit(repo, fromSHA)
+		if err != nil {
+			return nil, err
+		}
+		if fromTree, err = c.Tree(); err != nil {
+			return nil, fmt.Errorf("failed to get tree of commit '%s': %w", c.Hash, err)
+		}
+	}
+	c, err := resolveCommit(repo, toSHA)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree of commit '%s': %w", c.Hash, err)
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff commits '%s' and '%s': %w", fromSHA, toSHA, err)
+	}
+
+	result := make([]git.FileChange, 0, len(changes))
+	for _, change := range changes {
+		from, to := change.From.Name, change.To.Name
+		switch {
+		case from == "":
+			result = append(result, git.FileChange{Type: git.FileAdded, Path: to})
+		case to == "":
+			result = append(result, git.FileChange{Type: git.FileDeleted, Path: from})
+		case from != to:
+			result = append(result, git.FileChange{Type: git.FileRenamed, Path: to, OldPath: from})
+		default:
+			result = append(result, git.FileChange{Type: git.FileModified, Path: to})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+func TestAheadBehind(t *testing.T) {
+	g := NewWithT(t)
+
+	// Create a repository with the following history:
+	//
+	//   c1 - c2 - c3          (master)
+	//          \
+	//           f1 - f2 - f3  (feature, tag v1.0.0 on f1)
+	repoPath := t.TempDir()
+	repo, err := extgogit.PlainInit(repoPath, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var master []plumbing.Hash
+	for _, f := range []string{"c1", "c2"} {
+		h, err := commitRepoFile(repo, repoPath, f)
+		g.Expect(err).ToNot(HaveOccurred())
+		master = append(master, h)
+	}
+
+	wt, err := repo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(wt.Checkout(&extgogit.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName("feature"),
+		Create: true,
+	})).To(Succeed())
+	var feature []plumbing.Hash
+	for _, f := range []string{"f1", "f2", "f3"} {
+		h, err := commitRepoFile(repo, repoPath, f)
+		g.Expect(err).ToNot(HaveOccurred())
+		feature = append(feature, h)
+	}
+	_, err = repo.CreateTag("v1.0.0", feature[0], &extgogit.CreateTagOptions{
+		Tagger:  repoSignature(),
+		Message: "v1.0.0",
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(wt.Checkout(&extgogit.CheckoutOptions{
+		Branch: plumbing.Master,
+	})).To(Succeed())
+	h, err := commitRepoFile(repo, repoPath, "c3")
+	g.Expect(err).ToNot(HaveOccurred())
+	master = append(master, h)
+
+	tests := []struct {
+		name       string
+		base       string
+		head       string
+		wantAhead  int
+		wantBehind int
+		wantErr    string
+	}{
+		{
+			name:       "diverged branches",
+			base:       "master",
+			head:       "feature",
+			wantAhead:  3,
+			wantBehind: 1,
+		},
+		{
+			name:       "diverged branches reversed",
+			base:       "feature",
+			head:       "master",
+			wantAhead:  1,
+			wantBehind: 3,
+		},
+		{
+			name:      "commit SHAs",
+			base:      master[1].String(),
+			head:      feature[2].String(),
+			wantAhead: 3,
+		},
+		{
+			name:       "annotated tag",
+			base:       "v1.0.0",
+			head:       "master",
+			wantAhead:  1,
+			wantBehind: 1,
+		},
+		{
+			name: "same revision",
+			base: "master",
+			head: master[2].String(),
+		},
+		{
+			name:    "unknown revision",
+			base:    "master",
+			head:    "unknown",
+			wantErr: "failed to resolve revision 'unknown'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ahead, behind, err := AheadBehind(repoPath, tt.base, tt.head)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(ahead).To(Equal(tt.wantAhead))
+			g.Expect(behind).To(Equal(tt.wantBehind))
+		})
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	repoPath := t.TempDir()
+	repo, err := extgogit.PlainInit(repoPath, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	wt, err := repo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	content := "some content which is long enough to be detected as a rename\n"
+	commit := func(write map[string]string, remove ...string) plumbing.Hash {
+		for name, data := range write {
+			p := filepath.Join(repoPath, name)
+			g.Expect(os.MkdirAll(filepath.Dir(p), 0o755)).To(Succeed())
+			g.Expect(os.WriteFile(p, []byte(data), 0o644)).To(Succeed())
+			_, err := wt.Add(name)
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+		for _, name := range remove {
+			_, err := wt.Remove(name)
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+		h, err := wt.Commit("commit", &extgogit.CommitOptions{Author: repoSignature()})
+		g.Expect(err).ToNot(HaveOccurred())
+		return h
+	}
+
+	initial := commit(map[string]string{
+		"modify.txt": "foo",
+		"delete.txt": "bar",
+		"rename.txt": content,
+	})
+	added := commit(map[string]string{"dir/add.txt": "baz"})
+	modified := commit(map[string]string{"modify.txt": "foo bar"})
+	deleted := commit(nil, "delete.txt")
+	renamed := commit(map[string]string{"renamed.txt": content}, "rename.txt")
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		want    []git.FileChange
+		wantErr string
+	}{
+		{
+			name: "add",
+			from: initial.String(),
+			to:   added.String(),
+			want: []git.FileChange{{Type: git.FileAdded, Path: "dir/add.txt"}},
+		},
+		{
+			name: "modify",
+			from: added.String(),
+			to:   modified.String(),
+			want: []git.FileChange{{Type: git.FileModified, Path: "modify.txt"}},
+		},
+		{
+			name: "delete",
+			from: modified.String(),
+			to:   deleted.String(),
+			want: []git.FileChange{{Type: git.FileDeleted, Path: "delete.txt"}},
+		},
+		{
+			name: "rename",
+			from: deleted.String(),
+			to:   renamed.String(),
+			want: []git.FileChange{{Type: git.FileRenamed, Path: "renamed.txt", OldPath: "rename.txt"}},
+		},
+		{
+			name: "multiple commits",
+			from: initial.String(),
+			to:   renamed.String(),
+			want: []git.FileChange{
+				{Type: git.FileDeleted, Path: "delete.txt"},
+				{Type: git.FileAdded, Path: "dir/add.txt"},
+				{Type: git.FileModified, Path: "modify.txt"},
+				{Type: git.FileRenamed, Path: "renamed.txt", OldPath: "rename.txt"},
+			},
+		},
+		{
+			name: "without from",
+			to:   initial.String(),
+			want: []git.FileChange{
+				{Type: git.FileAdded, Path: "delete.txt"},
+				{Type: git.FileAdded, Path: "modify.txt"},
+				{Type: git.FileAdded, Path: "rename.txt"},
+			},
+		},
+		{
+			name: "same commit",
+			from: added.String(),
+			to:   added.String(),
+			want: []git.FileChange{},
+		},
+		{
+			name:    "unknown commit",
+			from:    initial.String(),
+			to:      "0000000000000000000000000000000000000001",
+			wantErr: "failed to resolve revision",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := ChangedFiles(repoPath, tt.from, tt.to)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func commitRepoFile(repo *extgogit.Repository, repoPath, name string) (plumbing.Hash, error) {
+	if err := os.WriteFile(filepath.Join(repoPath, name), []byte(name), 0o644); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err = wt.Add(name); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return wt.Commit("Adding: "+name, &extgogit.CommitOptions{
+		Author: repoSignature(),
+	})
+}
+
+func repoSignature() *object.Signature {
+	return &object.Signature{
+		Name:  "Jane Doe",
+		Email: "jane@example.com",
+		When:  time.Now(),
+	}
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// ListTags returns the tags of the remote repository at url matching the
+// given semver constraint, as documented for git.FilterTags. The tags are
+// obtained from the references advertised by the remote using git.ListRefs,
+// without cloning the repository.
+func ListTags(ctx context.Context, url, constraint string, opts *git.AuthOptions) ([]git.TagInfo, error) {
+	refs, err := git.ListRefs(ctx, url, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []git.TagInfo
+	for _, ref := range refs {
+		if ref.Type != git.RefTypeTag {
+			continue
+		}
+		tags = append(tags, git.TagInfo{Name: ref.ShortName(), Hash: ref.Target()})
+	}
+	return git.FilterTags(tags, constraint)
+}
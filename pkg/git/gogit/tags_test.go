@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+func TestListTags(t *testing.T) {
+	repo, path, err := initRepo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := map[string]bool{
+		"v1.0.0":  false,
+		"v1.1.0":  true,
+		"v2.0.0":  false,
+		"release": true,
+	}
+	want := map[string]string{}
+	for name, annotated := range tags {
+		h, err := commitFile(repo, "tag", name, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tag(repo, h, annotated, name, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		want[name] = h.String()
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		want       []string
+		wantErr    string
+	}{
+		{
+			name: "all tags",
+			want: []string{"release", "v1.0.0", "v1.1.0", "v2.0.0"},
+		},
+		{
+			name:       "semver range",
+			constraint: ">=1.0.0 <2.0.0",
+			want:       []string{"v1.0.0", "v1.1.0"},
+		},
+		{
+			name:       "invalid constraint",
+			constraint: "invalid",
+			wantErr:    "semver parse error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := ListTags(context.TODO(), path, tt.constraint, nil)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var wantTags []git.TagInfo
+			for _, name := range tt.want {
+				wantTags = append(wantTags, git.TagInfo{Name: name, Hash: want[name]})
+			}
+			g.Expect(got).To(Equal(wantTags))
+		})
+	}
+}
@@ -23,8 +23,6 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 
-	"github.com/fluxcd/pkg/ssh/knownhosts"
-
 	"github.com/fluxcd/source-controller/pkg/git"
 
 	gossh "golang.org/x/crypto/ssh"
@@ -38,6 +36,9 @@ func transportAuth(opts *git.AuthOptions) (transport.AuthMethod, error) {
 	}
 	switch opts.Transport {
 	case git.HTTPS, git.HTTP:
+		if len(opts.ClientCert) > 0 {
+			return nil, fmt.Errorf("client certificates are not supported by the '%s' Git implementation", Implementation)
+		}
 		// Some providers (i.e. GitLab) will reject empty credentials for
 		// public repositories.
 		if opts.Username != "" || opts.Password != "" {
@@ -48,13 +49,16 @@ func transportAuth(opts *git.AuthOptions) (transport.AuthMethod, error) {
 		}
 		return nil, nil
 	case git.SSH:
+		if len(opts.Identity) == 0 && opts.UseSSHAgent {
+			return nil, fmt.Errorf("SSH agent authentication is not supported by the '%s' Git implementation", Implementation)
+		}
 		if len(opts.Identity) > 0 {
 			pk, err := ssh.NewPublicKeys(opts.Username, opts.Identity, opts.Password)
 			if err != nil {
 				return nil, err
 			}
-			if len(opts.KnownHosts) > 0 {
-				callback, err := knownhosts.New(opts.KnownHosts)
+			if len(opts.KnownHosts) > 0 || opts.KnownHostsStrictness != "" {
+				callback, err := opts.HostKeyCallback()
 				if err != nil {
 					return nil, err
 				}
@@ -65,6 +69,8 @@ func transportAuth(opts *git.AuthOptions) (transport.AuthMethod, error) {
 			}
 			return customPK, nil
 		}
+	case git.File:
+		return nil, nil
 	case "":
 		return nil, fmt.Errorf("no transport type set")
 	default:
@@ -204,7 +204,26 @@ func Test_transportAuth(t *testing.T) {
 				Identity:   []byte(privateKeyFixture),
 				KnownHosts: []byte("invalid"),
 			},
-			wantErr: errors.New("knownhosts: knownhosts: missing host pattern"),
+			wantErr: errors.New("failed to parse known_hosts: invalid entry on line 1"),
+		},
+		{
+			name: "HTTPS client certificate",
+			opts: &git.AuthOptions{
+				Transport:  git.HTTPS,
+				ClientCert: []byte("cert"),
+				ClientKey:  []byte("key"),
+			},
+			wantErr: errors.New("client certificates are not supported by the 'go-git' Git implementation"),
+		},
+		{
+			name: "SSH agent",
+			opts: &git.AuthOptions{
+				Transport:      git.SSH,
+				Username:       "git",
+				UseSSHAgent:    true,
+				SSHAgentSocket: "/tmp/agent.sock",
+			},
+			wantErr: errors.New("SSH agent authentication is not supported by the 'go-git' Git implementation"),
 		},
 		{
 			name:    "Empty",
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"fmt"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// VerifyWorktree verifies the worktree of the repository at path against
+// the tree of the given commit, see git.VerifyWorktree.
+func VerifyWorktree(path string, commit git.Hash) error {
+	repo, err := extgogit.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("failed to open Git repository: %w", err)
+	}
+	cc, err := repo.CommitObject(plumbing.NewHash(commit.String()))
+	if err != nil {
+		return fmt.Errorf("failed to resolve commit object for '%s': %w", commit, err)
+	}
+	tree, err := cc.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to resolve tree of commit '%s': %w", commit, err)
+	}
+
+	var entries []git.WorktreeEntry
+	if err = tree.Files().ForEach(func(f *object.File) error {
+		entries = append(entries, git.WorktreeEntry{
+			Path:    f.Name,
+			Hash:    f.Hash.String(),
+			Symlink: f.Mode == filemode.Symlink,
+		})
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to walk tree of commit '%s': %w", commit, err)
+	}
+	return git.VerifyWorktree(path, commit.String(), entries)
+}
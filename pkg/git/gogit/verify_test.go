@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+func TestVerifyWorktree(t *testing.T) {
+	repo, path, err := initRepo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = commitFile(repo, "file", "content", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = commitFile(repo, "dir/nested", "nested", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		mutate    func(dir string) error
+		wantPaths []string
+	}{
+		{
+			name: "unmodified worktree",
+		},
+		{
+			name: "corrupted file",
+			mutate: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "dir", "nested"), []byte("corrupted"), 0o600)
+			},
+			wantPaths: []string{"dir/nested"},
+		},
+		{
+			name: "truncated file",
+			mutate: func(dir string) error {
+				return os.Truncate(filepath.Join(dir, "file"), 0)
+			},
+			wantPaths: []string{"file"},
+		},
+		{
+			name: "removed file",
+			mutate: func(dir string) error {
+				return os.Remove(filepath.Join(dir, "file"))
+			},
+			wantPaths: []string{"file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tmpDir := t.TempDir()
+			branch := CheckoutBranch{Branch: git.DefaultBranch}
+			cc, err := branch.Checkout(context.TODO(), tmpDir, path, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			if tt.mutate != nil {
+				g.Expect(tt.mutate(tmpDir)).To(Succeed())
+			}
+
+			err = VerifyWorktree(tmpDir, cc.Hash)
+			if tt.wantPaths == nil {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			var mismatchErr *git.WorktreeMismatchError
+			g.Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			g.Expect(mismatchErr.Commit).To(Equal(cc.Hash.String()))
+			g.Expect(mismatchErr.Paths).To(Equal(tt.wantPaths))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/pkg/ssh"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/utils/clock"
+)
+
+// ScanHostKeyFunc scans the SSH host keys of the given host:port, and returns
+// them in known_hosts format.
+type ScanHostKeyFunc func(host string, timeout time.Duration, clientHostKeyAlgos []string, hashHostNames bool) ([]byte, error)
+
+// HostKeyScanner memoizes the result of a ScanHostKeyFunc per host for a
+// TTL, so repeated SSH operations against the same host do not have to
+// rescan its host keys. It is safe for concurrent use, and concurrent scans
+// for the same host are deduplicated.
+type HostKeyScanner struct {
+	scan  ScanHostKeyFunc
+	ttl   time.Duration
+	clock clock.PassiveClock
+
+	mu      sync.Mutex
+	entries map[string]hostKeyEntry
+	group   singleflight.Group
+}
+
+type hostKeyEntry struct {
+	knownHosts []byte
+	expires    time.Time
+}
+
+// NewHostKeyScanner returns a HostKeyScanner which caches the host keys
+// scanned with scan for ttl. If scan is nil, ssh.ScanHostKey is used.
+func NewHostKeyScanner(scan ScanHostKeyFunc, ttl time.Duration) *HostKeyScanner {
+	if scan == nil {
+		scan = ssh.ScanHostKey
+	}
+	return &HostKeyScanner{
+		scan:    scan,
+		ttl:     ttl,
+		clock:   clock.RealClock{},
+		entries: make(map[string]hostKeyEntry),
+	}
+}
+
+// ScanHostKey returns the cached host keys for the given host:port, or scans
+// them if there is no unexpired entry for the host and the requested
+// algorithms. Failed scans are not cached. The host is normalized using
+// HostWithPort, which allows IPv6 literals with and without brackets and
+// port.
+func (s *HostKeyScanner) ScanHostKey(host string, timeout time.Duration, clientHostKeyAlgos []string, hashHostNames bool) ([]byte, error) {
+	host = HostWithPort(host)
+	key := fmt.Sprintf("%s|%s|%t", host, strings.Join(clientHostKeyAlgos, ","), hashHostNames)
+
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	s.mu.Unlock()
+	if ok && s.clock.Now().Before(entry.expires) {
+		return entry.knownHosts, nil
+	}
+
+	v, err, _ := s.group.Do(key, func() (interface{}, error) {
+		// Another scan may have completed since the entry was looked up.
+		s.mu.Lock()
+		entry, ok := s.entries[key]
+		s.mu.Unlock()
+		if ok && s.clock.Now().Before(entry.expires) {
+			return entry.knownHosts, nil
+		}
+
+		knownHosts, err := s.scan(host, timeout, clientHostKeyAlgos, hashHostNames)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.entries[key] = hostKeyEntry{
+			knownHosts: knownHosts,
+			expires:    s.clock.Now().Add(s.ttl),
+		}
+		s.mu.Unlock()
+		return knownHosts, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
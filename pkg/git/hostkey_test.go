@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestHostKeyScanner_ScanHostKey(t *testing.T) {
+	g := NewWithT(t)
+
+	var calls int32
+	scan := func(host string, _ time.Duration, algos []string, _ bool) ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		if host == "fail:22" {
+			return nil, errors.New("connection refused")
+		}
+		return []byte(host + " " + strings.Join(algos, ",")), nil
+	}
+	fakeClock := clocktesting.NewFakePassiveClock(time.Now())
+	s := NewHostKeyScanner(scan, time.Minute)
+	s.clock = fakeClock
+
+	// Concurrent and repeated calls within the TTL scan once.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			kh, err := s.ScanHostKey("example.com:22", time.Second, []string{"ssh-ed25519"}, false)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(kh)).To(Equal("example.com:22 ssh-ed25519"))
+		}()
+	}
+	wg.Wait()
+	_, err := s.ScanHostKey("example.com:22", time.Second, []string{"ssh-ed25519"}, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&calls)).To(Equal(int32(1)))
+
+	// Other algorithms are scanned separately.
+	kh, err := s.ScanHostKey("example.com:22", time.Second, []string{"ssh-rsa"}, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(kh)).To(Equal("example.com:22 ssh-rsa"))
+	g.Expect(atomic.LoadInt32(&calls)).To(Equal(int32(2)))
+
+	// Expired entries are rescanned.
+	fakeClock.SetTime(fakeClock.Now().Add(2 * time.Minute))
+	_, err = s.ScanHostKey("example.com:22", time.Second, []string{"ssh-ed25519"}, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&calls)).To(Equal(int32(3)))
+
+	// Errors are not cached.
+	_, err = s.ScanHostKey("fail:22", time.Second, nil, false)
+	g.Expect(err).To(MatchError("connection refused"))
+	_, err = s.ScanHostKey("fail:22", time.Second, nil, false)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&calls)).To(Equal(int32(5)))
+}
+
+func TestHostKeyScanner_ScanHostKey_IPv6(t *testing.T) {
+	g := NewWithT(t)
+
+	var hosts []string
+	scan := func(host string, _ time.Duration, _ []string, _ bool) ([]byte, error) {
+		hosts = append(hosts, host)
+		return []byte(host), nil
+	}
+	s := NewHostKeyScanner(scan, time.Minute)
+
+	// IPv6 literals with and without brackets and port are scanned once at
+	// the normalized address.
+	for _, host := range []string{"2001:db8::1", "[2001:db8::1]", "[2001:db8::1]:22"} {
+		kh, err := s.ScanHostKey(host, time.Second, nil, false)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(kh)).To(Equal("[2001:db8::1]:22"))
+	}
+	g.Expect(hosts).To(Equal([]string{"[2001:db8::1]:22"}))
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/fluxcd/source-controller/internal/transport"
+)
+
+// DefaultHostPolicy is the process-wide HostPolicy validating the URLs of
+// Git operations. It allows all hosts by default, and is expected to be
+// replaced with a configured HostPolicy during startup.
+var DefaultHostPolicy = &HostPolicy{}
+
+// HostResolver looks up the IP addresses of a host. It is implemented by
+// net.Resolver.
+type HostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// HostPolicy restricts the hosts Git operations are allowed to connect to.
+// The host name of a URL is matched against glob patterns as supported by
+// path.Match, e.g. "*.example.com", case-insensitively and without the port.
+type HostPolicy struct {
+	// Allow are the patterns of the allowed hosts. If empty, all hosts
+	// which are not denied are allowed.
+	Allow []string
+	// Deny are the patterns of the denied hosts, which take precedence
+	// over the Allow patterns.
+	Deny []string
+	// DenyPrivate denies hosts which are, or resolve to, a loopback,
+	// private, link-local or unspecified IP address. As the host is
+	// resolved again when connecting, this does not protect against DNS
+	// rebinding.
+	DenyPrivate bool
+	// Resolver looks up the addresses of host names when DenyPrivate is
+	// set. When nil, net.DefaultResolver is used.
+	Resolver HostResolver
+}
+
+// NewHostPolicy returns a HostPolicy with the given allow and deny patterns,
+// or an error if any of the patterns is malformed.
+func NewHostPolicy(allow, deny []string, denyPrivate bool) (*HostPolicy, error) {
+	for _, p := range append(append([]string{}, allow...), deny...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid host pattern '%s': %w", p, err)
+		}
+	}
+	return &HostPolicy{Allow: allow, Deny: deny, DenyPrivate: denyPrivate}, nil
+}
+
+// HostPolicyViolationError is returned when the host of a URL is not
+// allowed by the HostPolicy.
+type HostPolicyViolationError struct {
+	URL    string
+	Host   string
+	Reason string
+}
+
+// Error returns the error string.
+func (e *HostPolicyViolationError) Error() string {
+	return fmt.Sprintf("host '%s' of URL '%s' is not allowed: %s", e.Host, e.URL, e.Reason)
+}
+
+// Validate returns a HostPolicyViolationError if the host of the given URL,
+// which may be an SCP-like address, is not allowed by the HostPolicy.
+// Bundle URLs do not connect to a host, and are always allowed.
+func (p *HostPolicy) Validate(ctx context.Context, rawURL string) error {
+	if p == nil || (len(p.Allow) == 0 && len(p.Deny) == 0 && !p.DenyPrivate) || IsBundleURL(rawURL) {
+		return nil
+	}
+
+	host, err := hostnameFromURL(rawURL)
+	if err != nil {
+		return err
+	}
+	violation := func(reason string) error {
+		return &HostPolicyViolationError{URL: rawURL, Host: host, Reason: reason}
+	}
+
+	if pattern, ok := matchHostPattern(p.Deny, host); ok {
+		return violation(fmt.Sprintf("host matches denied pattern '%s'", pattern))
+	}
+	if len(p.Allow) > 0 {
+		if _, ok := matchHostPattern(p.Allow, host); !ok {
+			return violation("host does not match any allowed pattern")
+		}
+	}
+
+	if p.DenyPrivate {
+		if ip := net.ParseIP(host); ip != nil {
+			if transport.IsPrivateIP(ip) {
+				return violation("host is a private IP address")
+			}
+			return nil
+		}
+		resolver := p.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return fmt.Errorf("failed to resolve host '%s' to validate against host policy: %w", host, err)
+		}
+		for _, addr := range addrs {
+			if transport.IsPrivateIP(addr.IP) {
+				return violation(fmt.Sprintf("host resolves to private IP address '%s'", addr.IP))
+			}
+		}
+	}
+	return nil
+}
+
+// matchHostPattern returns the first of the given patterns matching the
+// host.
+func matchHostPattern(patterns []string, host string) (string, bool) {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), host); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// HostPolicyCheckoutStrategy returns a CheckoutStrategy which validates the
+// URL against the given HostPolicy before delegating the checkout to the
+// given CheckoutStrategy. The URLs of submodules are not validated.
+func HostPolicyCheckoutStrategy(s CheckoutStrategy, p *HostPolicy) CheckoutStrategy {
+	return &hostPolicyCheckoutStrategy{strategy: s, policy: p}
+}
+
+type hostPolicyCheckoutStrategy struct {
+	strategy CheckoutStrategy
+	policy   *HostPolicy
+}
+
+func (c *hostPolicyCheckoutStrategy) Checkout(ctx context.Context, path, url string, opts *AuthOptions) (*Commit, error) {
+	return CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+func (c *hostPolicyCheckoutStrategy) CheckoutWithResult(ctx context.Context, path, url string, opts *AuthOptions) (*CheckoutResult, error) {
+	if err := c.policy.Validate(ctx, url); err != nil {
+		return nil, err
+	}
+	return CheckoutWithResult(ctx, c.strategy, path, url, opts)
+}
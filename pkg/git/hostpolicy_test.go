@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+type fakeHostResolver map[string][]string
+
+func (r fakeHostResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func TestHostPolicy_Validate(t *testing.T) {
+	resolver := fakeHostResolver{
+		"github.com":           {"140.82.121.3"},
+		"git.example.com":      {"203.0.113.10", "2001:db8::10"},
+		"internal.example.com": {"203.0.113.11", "10.0.0.5"},
+	}
+
+	tests := []struct {
+		name       string
+		policy     *HostPolicy
+		url        string
+		wantReason string
+		wantErr    bool
+	}{
+		{
+			name: "nil policy",
+			url:  "https://127.0.0.1/org/repo",
+		},
+		{
+			name:   "empty policy",
+			policy: &HostPolicy{},
+			url:    "https://127.0.0.1/org/repo",
+		},
+		{
+			name:   "allowed by pattern",
+			policy: &HostPolicy{Allow: []string{"github.com", "*.example.com"}},
+			url:    "https://git.example.com:8443/org/repo",
+		},
+		{
+			name:   "allowed SCP-like address",
+			policy: &HostPolicy{Allow: []string{"github.com"}},
+			url:    "git@GitHub.com:org/repo.git",
+		},
+		{
+			name:   "bundle URL",
+			policy: &HostPolicy{Allow: []string{"github.com"}, DenyPrivate: true},
+			url:    "file:///bundles/repo.bundle",
+		},
+		{
+			name:       "not allowed",
+			policy:     &HostPolicy{Allow: []string{"*.example.com"}},
+			url:        "ssh://git@github.com/org/repo",
+			wantReason: "host does not match any allowed pattern",
+		},
+		{
+			name:       "denied",
+			policy:     &HostPolicy{Allow: []string{"*.example.com"}, Deny: []string{"internal.*"}},
+			url:        "https://internal.example.com/org/repo",
+			wantReason: "host matches denied pattern 'internal.*'",
+		},
+		{
+			name:       "private IPv4 address",
+			policy:     &HostPolicy{DenyPrivate: true},
+			url:        "http://192.168.1.10/org/repo",
+			wantReason: "host is a private IP address",
+		},
+		{
+			name:       "loopback IPv6 address",
+			policy:     &HostPolicy{DenyPrivate: true},
+			url:        "ssh://git@[::1]:2222/org/repo",
+			wantReason: "host is a private IP address",
+		},
+		{
+			name:       "link-local address",
+			policy:     &HostPolicy{DenyPrivate: true},
+			url:        "http://169.254.169.254/latest/meta-data",
+			wantReason: "host is a private IP address",
+		},
+		{
+			name:   "public IP address",
+			policy: &HostPolicy{DenyPrivate: true},
+			url:    "https://203.0.113.10/org/repo",
+		},
+		{
+			name:   "host resolving to public addresses",
+			policy: &HostPolicy{DenyPrivate: true},
+			url:    "https://git.example.com/org/repo",
+		},
+		{
+			name:       "host resolving to a private address",
+			policy:     &HostPolicy{DenyPrivate: true},
+			url:        "https://internal.example.com/org/repo",
+			wantReason: "host resolves to private IP address '10.0.0.5'",
+		},
+		{
+			name:    "unresolvable host",
+			policy:  &HostPolicy{DenyPrivate: true},
+			url:     "https://unknown.example.com/org/repo",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			if tt.policy != nil {
+				tt.policy.Resolver = resolver
+			}
+			err := tt.policy.Validate(context.TODO(), tt.url)
+			if tt.wantReason == "" && !tt.wantErr {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+
+			var violationErr *HostPolicyViolationError
+			g.Expect(errors.As(err, &violationErr)).To(Equal(tt.wantReason != ""))
+			if tt.wantReason != "" {
+				g.Expect(violationErr.URL).To(Equal(tt.url))
+				g.Expect(violationErr.Reason).To(Equal(tt.wantReason))
+			}
+		})
+	}
+}
+
+func TestNewHostPolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	p, err := NewHostPolicy([]string{"*.example.com"}, nil, true)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p).To(Equal(&HostPolicy{Allow: []string{"*.example.com"}, DenyPrivate: true}))
+
+	_, err = NewHostPolicy(nil, []string{"[example.com"}, false)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("invalid host pattern '[example.com'"))
+}
+
+func TestHostPolicyCheckoutStrategy(t *testing.T) {
+	g := NewWithT(t)
+
+	s := &authOptionsCheckoutStrategy{}
+	policy := &HostPolicy{Deny: []string{"*.internal"}}
+	_, err := HostPolicyCheckoutStrategy(s, policy).Checkout(context.TODO(), t.TempDir(), "https://git.internal/org/repo", &AuthOptions{})
+	var violationErr *HostPolicyViolationError
+	g.Expect(errors.As(err, &violationErr)).To(BeTrue())
+	g.Expect(s.opts).To(BeNil())
+
+	_, err = HostPolicyCheckoutStrategy(s, policy).Checkout(context.TODO(), t.TempDir(), "https://example.com/org/repo", &AuthOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(s.opts).ToNot(BeNil())
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultCheckoutLocks is the process-wide KeyedMutex used to serialize
+// checkouts of the same URL to the same path.
+var DefaultCheckoutLocks = NewKeyedMutex()
+
+// KeyedMutex is a set of mutual exclusion locks identified by a key. Locks
+// are created on demand, and removed once they are neither held nor waited
+// for.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewKeyedMutex returns an empty KeyedMutex.
+func NewKeyedMutex() *KeyedMutex {
+	return &KeyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// Lock blocks until the lock for the given key is acquired, or the context
+// is done. On success, it returns an unlock function which must be called
+// once the lock is no longer needed. Calling it more than once is a no-op.
+func (m *KeyedMutex) Lock(ctx context.Context, key string) (func(), error) {
+	l := m.ref(key)
+	select {
+	case l.ch <- struct{}{}:
+	case <-ctx.Done():
+		m.unref(key)
+		return nil, fmt.Errorf("waiting for lock: %w", ctx.Err())
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.ch
+			m.unref(key)
+		})
+	}, nil
+}
+
+// ref returns the keyedLock for the given key, creating it if it does not
+// exist yet, and increases its reference count.
+func (m *KeyedMutex) ref(key string) *keyedLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{ch: make(chan struct{}, 1)}
+		m.locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+// unref decreases the reference count of the keyedLock for the given key,
+// and removes it once it is no longer referenced.
+func (m *KeyedMutex) unref(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.locks[key]; ok {
+		if l.refs--; l.refs <= 0 {
+			delete(m.locks, key)
+		}
+	}
+}
+
+// len returns the number of locks which are held or waited for.
+func (m *KeyedMutex) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.locks)
+}
+
+// checkoutLockKey returns the key identifying a checkout of the given URL
+// to the given path. The URL is normalized by lower-casing the scheme and
+// host, and removing the user info and any trailing slash or ".git" suffix,
+// so that different spellings of the same repository result in the same
+// key. The path is made absolute and cleaned.
+func checkoutLockKey(rawURL, path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	u := rawURL
+	if parsed, err := url.Parse(rawURL); err == nil && parsed.Host != "" {
+		parsed.Scheme = strings.ToLower(parsed.Scheme)
+		parsed.Host = strings.ToLower(parsed.Host)
+		parsed.User = nil
+		parsed.Path = strings.TrimSuffix(strings.TrimSuffix(parsed.Path, "/"), ".git")
+		parsed.RawPath = ""
+		u = parsed.String()
+	}
+	return u + "\x00" + filepath.Clean(path)
+}
+
+// LockCheckoutStrategy returns a CheckoutStrategy which holds the lock of
+// the given KeyedMutex for the URL and path while delegating the checkout
+// to the given CheckoutStrategy, so that overlapping checkouts of the same
+// repository to the same path are serialized. The lock is released once
+// the checkout returns, including when it panics.
+func LockCheckoutStrategy(s CheckoutStrategy, m *KeyedMutex) CheckoutStrategy {
+	return &lockedCheckoutStrategy{strategy: s, locks: m}
+}
+
+type lockedCheckoutStrategy struct {
+	strategy CheckoutStrategy
+	locks    *KeyedMutex
+}
+
+func (c *lockedCheckoutStrategy) Checkout(ctx context.Context, path, url string, opts *AuthOptions) (*Commit, error) {
+	return CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+func (c *lockedCheckoutStrategy) CheckoutWithResult(ctx context.Context, path, url string, opts *AuthOptions) (*CheckoutResult, error) {
+	unlock, err := c.locks.Lock(ctx, checkoutLockKey(url, path))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return CheckoutWithResult(ctx, c.strategy, path, url, opts)
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	xknownhosts "golang.org/x/crypto/ssh/knownhosts"
+)
+
+// KnownHostsStrictness defines how the host key of an SSH server is verified
+// against the known_hosts.
+type KnownHostsStrictness string
+
+const (
+	// KnownHostsStrict only accepts host keys which are present in the
+	// known_hosts. This is the default.
+	KnownHostsStrict KnownHostsStrictness = "strict"
+	// KnownHostsAcceptNew accepts the host key of hosts which are not present
+	// in the known_hosts, and passes them on to AuthOptions.KnownHostsPersist.
+	// Changed host keys of known hosts are rejected.
+	KnownHostsAcceptNew KnownHostsStrictness = "accept-new"
+	// KnownHostsIgnore accepts any host key. This is insecure, and should only
+	// be used for testing.
+	KnownHostsIgnore KnownHostsStrictness = "ignore"
+)
+
+// HostKeyCallback returns an ssh.HostKeyCallback verifying the host key
+// against the KnownHosts, according to the KnownHostsStrictness.
+func (o AuthOptions) HostKeyCallback() (ssh.HostKeyCallback, error) {
+	switch o.KnownHostsStrictness {
+	case KnownHostsStrict, "":
+		entries, err := parseKnownHosts(o.KnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			for _, k := range knownHostKeys(entries, hostname) {
+				if k.Type() == key.Type() && bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil
+				}
+			}
+			return &HostKeyMismatchError{Host: hostname, Err: errors.New("hostkey could not be verified")}
+		}, nil
+	case KnownHostsAcceptNew:
+		entries, err := parseKnownHosts(o.KnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		return acceptNewHostKeyCallback(entries, o.KnownHostsPersist), nil
+	case KnownHostsIgnore:
+		return ssh.InsecureIgnoreHostKey(), nil
+	default:
+		return nil, fmt.Errorf("unknown known_hosts strictness '%s'", o.KnownHostsStrictness)
+	}
+}
+
+// acceptNewHostKeyCallback returns an ssh.HostKeyCallback which trusts the
+// host key of hosts without an entry in the known_hosts on first use, and
+// passes the known_hosts line for the key to persist.
+func acceptNewHostKeyCallback(entries []knownHostEntry, persist func(line string) error) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		keys := knownHostKeys(entries, hostname)
+		if len(keys) == 0 {
+			if persist != nil {
+				if err := persist(xknownhosts.Line([]string{hostname}, key)); err != nil {
+					return fmt.Errorf("failed to persist host key for '%s': %w", hostname, err)
+				}
+			}
+			return nil
+		}
+		for _, k := range keys {
+			if k.Type() == key.Type() && bytes.Equal(k.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+		return &HostKeyMismatchError{
+			Host: hostname,
+			Err: fmt.Errorf("host key for '%s' has changed: %s key with fingerprint '%s' does not match known_hosts",
+				xknownhosts.Normalize(hostname), key.Type(), ssh.FingerprintSHA256(key)),
+		}
+	}
+}
+
+// knownHostEntry is an unmarked entry of a known_hosts file.
+type knownHostEntry struct {
+	patterns []string
+	key      ssh.PublicKey
+}
+
+// parseKnownHosts parses the entries of the known_hosts. Plain and hashed
+// host entries are supported, marked entries are ignored.
+func parseKnownHosts(knownHosts []byte) ([]knownHostEntry, error) {
+	var entries []knownHostEntry
+	for i, line := range bytes.Split(knownHosts, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		// ParseKnownHosts silently skips lines consisting of a single
+		// field, which are rejected as invalid entries instead.
+		if !bytes.ContainsAny(line, " \t") {
+			return nil, fmt.Errorf("failed to parse known_hosts: invalid entry on line %d", i+1)
+		}
+		marker, hosts, key, _, _, err := ssh.ParseKnownHosts(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse known_hosts: %w", err)
+		}
+		if marker != "" {
+			continue
+		}
+		entries = append(entries, knownHostEntry{patterns: hosts, key: key})
+	}
+	return entries, nil
+}
+
+// knownHostKeys returns the public keys of the known_hosts entries for the
+// given host.
+func knownHostKeys(entries []knownHostEntry, host string) []ssh.PublicKey {
+	names := KnownHostsNames(host)
+
+	var keys []ssh.PublicKey
+	for _, e := range entries {
+		for _, pattern := range e.patterns {
+			if matchKnownHost(pattern, names) {
+				keys = append(keys, e.key)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// KnownHostsNames returns the names under which the given host, with an
+// optional port, can be recorded in known_hosts. These differ for IPv6
+// literals on the default port 22, which OpenSSH records without brackets
+// (e.g. "2001:db8::1"), while Go records them with brackets (e.g.
+// "[2001:db8::1]"). Hosts on other ports are recorded as "[host]:port" by
+// both. The first name is normalized as by knownhosts.Normalize.
+func KnownHostsNames(host string) []string {
+	normalized := xknownhosts.Normalize(host)
+	h, port := splitKnownHost(host)
+	if port == "22" && h != normalized {
+		return []string{normalized, h}
+	}
+	return []string{normalized}
+}
+
+// HostWithPort returns the given host in "host:port" format, with IPv6
+// literals enclosed in brackets, and defaulting to port 22 if none is
+// given. Both bracketed and bare IPv6 literals are accepted.
+func HostWithPort(host string) string {
+	h, port := splitKnownHost(host)
+	return net.JoinHostPort(h, port)
+}
+
+// splitKnownHost splits the given host into the host without brackets and
+// the port, defaulting to port 22 if none is given.
+func splitKnownHost(host string) (string, string) {
+	// A bare IPv6 literal can not contain a port, while its last group
+	// would be mistaken for one.
+	if ip := net.ParseIP(host); ip != nil {
+		return host, "22"
+	}
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		return h, port
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), "22"
+}
+
+// matchKnownHost returns if the known_hosts host pattern matches any of the
+// names of the host as returned by KnownHostsNames.
+func matchKnownHost(pattern string, names []string) bool {
+	if strings.HasPrefix(pattern, "|1|") {
+		parts := strings.Split(pattern[3:], "|")
+		if len(parts) != 2 {
+			return false
+		}
+		salt, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return false
+		}
+		hash, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return false
+		}
+		for _, name := range names {
+			mac := hmac.New(sha1.New, salt)
+			mac.Write([]byte(name))
+			if hmac.Equal(mac.Sum(nil), hash) {
+				return true
+			}
+		}
+		return false
+	}
+	return xknownhosts.Normalize(pattern) == names[0]
+}
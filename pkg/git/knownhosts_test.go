@@ -0,0 +1,299 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAuthOptions_HostKeyCallback(t *testing.T) {
+	knownKey := newHostKey(t)
+	changedKey := newHostKey(t)
+	knownHosts := []byte(knownhosts.Line([]string{"example.com"}, knownKey) + "\n" +
+		knownhosts.Line([]string{knownhosts.HashHostname("[hashed.example.com]:2222")}, knownKey) + "\n")
+	remote := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+
+	tests := []struct {
+		name         string
+		strictness   KnownHostsStrictness
+		hostname     string
+		key          ssh.PublicKey
+		persistErr   error
+		wantErr      bool
+		wantErrMsg   string
+		wantMismatch bool
+		wantPersist  string
+	}{
+		{
+			name:     "strict accepts known host key",
+			hostname: "example.com:22",
+			key:      knownKey,
+		},
+		{
+			name:         "strict rejects changed host key",
+			strictness:   KnownHostsStrict,
+			hostname:     "example.com:22",
+			key:          changedKey,
+			wantErr:      true,
+			wantMismatch: true,
+		},
+		{
+			name:         "strict rejects unknown host",
+			strictness:   KnownHostsStrict,
+			hostname:     "unknown.example.com:22",
+			key:          knownKey,
+			wantErr:      true,
+			wantMismatch: true,
+		},
+		{
+			name:       "accept-new accepts known host key",
+			strictness: KnownHostsAcceptNew,
+			hostname:   "example.com:22",
+			key:        knownKey,
+		},
+		{
+			name:       "accept-new accepts known hashed host key",
+			strictness: KnownHostsAcceptNew,
+			hostname:   "hashed.example.com:2222",
+			key:        knownKey,
+		},
+		{
+			name:         "accept-new rejects changed host key",
+			strictness:   KnownHostsAcceptNew,
+			hostname:     "hashed.example.com:2222",
+			key:          changedKey,
+			wantErr:      true,
+			wantMismatch: true,
+			wantErrMsg:   "host key for '[hashed.example.com]:2222' has changed",
+		},
+		{
+			name:        "accept-new persists unknown host key",
+			strictness:  KnownHostsAcceptNew,
+			hostname:    "unknown.example.com:2222",
+			key:         changedKey,
+			wantPersist: knownhosts.Line([]string{"[unknown.example.com]:2222"}, changedKey),
+		},
+		{
+			name:       "accept-new fails on persist error",
+			strictness: KnownHostsAcceptNew,
+			hostname:   "unknown.example.com:22",
+			key:        changedKey,
+			persistErr: errors.New("read-only"),
+			wantErr:    true,
+			wantErrMsg: "failed to persist host key for 'unknown.example.com:22': read-only",
+		},
+		{
+			name:       "ignore accepts changed host key",
+			strictness: KnownHostsIgnore,
+			hostname:   "example.com:22",
+			key:        changedKey,
+		},
+		{
+			name:       "ignore accepts unknown host",
+			strictness: KnownHostsIgnore,
+			hostname:   "unknown.example.com:22",
+			key:        changedKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var persisted string
+			opts := AuthOptions{
+				Transport:            SSH,
+				KnownHosts:           knownHosts,
+				KnownHostsStrictness: tt.strictness,
+				KnownHostsPersist: func(line string) error {
+					persisted = line
+					return tt.persistErr
+				},
+			}
+			callback, err := opts.HostKeyCallback()
+			g.Expect(err).ToNot(HaveOccurred())
+
+			err = callback(tt.hostname, remote, tt.key)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErrMsg))
+				var mismatchErr *HostKeyMismatchError
+				g.Expect(errors.As(err, &mismatchErr)).To(Equal(tt.wantMismatch))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(persisted).To(Equal(tt.wantPersist))
+		})
+	}
+}
+
+func TestAuthOptions_HostKeyCallback_unknownStrictness(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := AuthOptions{KnownHostsStrictness: "foo"}.HostKeyCallback()
+	g.Expect(err).To(MatchError("unknown known_hosts strictness 'foo'"))
+}
+
+func TestAuthOptions_HostKeyCallback_invalidKnownHosts(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := AuthOptions{KnownHosts: []byte("# comment\n\ninvalid\n")}.HostKeyCallback()
+	g.Expect(err).To(MatchError("failed to parse known_hosts: invalid entry on line 3"))
+}
+
+// hashHostname hashes the hostname as is, as OpenSSH does, while
+// knownhosts.HashHostname normalizes it first.
+func hashHostname(hostname string) string {
+	salt := make([]byte, sha1.Size)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(hostname))
+	return "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthOptions_HostKeyCallback_IPv6(t *testing.T) {
+	key := newHostKey(t)
+	otherKey := newHostKey(t)
+	knownHosts := []byte(
+		// OpenSSH records IPv6 literals on port 22 without brackets.
+		knownhosts.Line([]string{"2001:db8::1"}, key) + "\n" +
+			knownhosts.Line([]string{hashHostname("2001:db8::2")}, key) + "\n" +
+			// Go records them with brackets.
+			knownhosts.Line([]string{"[2001:db8::3]"}, key) + "\n" +
+			knownhosts.Line([]string{knownhosts.HashHostname("2001:db8::4")}, key) + "\n" +
+			knownhosts.Line([]string{"[2001:db8::5]:2222"}, key) + "\n" +
+			knownhosts.Line([]string{hashHostname("[2001:db8::6]:2222")}, key) + "\n")
+	remote := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 22}
+
+	tests := []struct {
+		name        string
+		hostname    string
+		key         ssh.PublicKey
+		wantUnknown bool
+		wantChanged bool
+	}{
+		{name: "bracketed with port", hostname: "[2001:db8::1]:22", key: key},
+		{name: "bracketed without port", hostname: "[2001:db8::1]", key: key},
+		{name: "bare", hostname: "2001:db8::1", key: key},
+		{name: "hashed bare", hostname: "[2001:db8::2]:22", key: key},
+		{name: "Go bracketed", hostname: "[2001:db8::3]:22", key: key},
+		{name: "Go hashed bracketed", hostname: "[2001:db8::4]:22", key: key},
+		{name: "non-default port", hostname: "[2001:db8::5]:2222", key: key},
+		{name: "hashed non-default port", hostname: "[2001:db8::6]:2222", key: key},
+		{name: "other port", hostname: "[2001:db8::5]:22", key: key, wantUnknown: true},
+		{name: "changed key", hostname: "[2001:db8::1]:22", key: otherKey, wantChanged: true},
+	}
+	for _, strictness := range []KnownHostsStrictness{KnownHostsStrict, KnownHostsAcceptNew} {
+		for _, tt := range tests {
+			t.Run(string(strictness)+"/"+tt.name, func(t *testing.T) {
+				g := NewWithT(t)
+
+				var persisted bool
+				opts := AuthOptions{
+					Transport:            SSH,
+					KnownHosts:           knownHosts,
+					KnownHostsStrictness: strictness,
+					KnownHostsPersist: func(string) error {
+						persisted = true
+						return nil
+					},
+				}
+				callback, err := opts.HostKeyCallback()
+				g.Expect(err).ToNot(HaveOccurred())
+
+				err = callback(tt.hostname, remote, tt.key)
+				if tt.wantChanged || (tt.wantUnknown && strictness == KnownHostsStrict) {
+					var mismatchErr *HostKeyMismatchError
+					g.Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+					g.Expect(persisted).To(BeFalse())
+					return
+				}
+				g.Expect(err).ToNot(HaveOccurred())
+				// Only unknown hosts are persisted.
+				g.Expect(persisted).To(Equal(tt.wantUnknown))
+			})
+		}
+	}
+}
+
+func TestKnownHostsNames(t *testing.T) {
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{host: "example.com", want: []string{"example.com"}},
+		{host: "example.com:22", want: []string{"example.com"}},
+		{host: "example.com:2222", want: []string{"[example.com]:2222"}},
+		{host: "2001:db8::1", want: []string{"[2001:db8::1]", "2001:db8::1"}},
+		{host: "[2001:db8::1]", want: []string{"[2001:db8::1]", "2001:db8::1"}},
+		{host: "[2001:db8::1]:22", want: []string{"[2001:db8::1]", "2001:db8::1"}},
+		{host: "[2001:db8::1]:2222", want: []string{"[2001:db8::1]:2222"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(KnownHostsNames(tt.host)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestHostWithPort(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "example.com", want: "example.com:22"},
+		{host: "example.com:2222", want: "example.com:2222"},
+		{host: "2001:db8::1", want: "[2001:db8::1]:22"},
+		{host: "[2001:db8::1]", want: "[2001:db8::1]:22"},
+		{host: "[2001:db8::1]:2222", want: "[2001:db8::1]:2222"},
+		{host: "::1", want: "[::1]:22"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(HostWithPort(tt.host)).To(Equal(tt.want))
+		})
+	}
+}
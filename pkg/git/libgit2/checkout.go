@@ -19,16 +19,14 @@ package libgit2
 import (
 	"context"
 	"fmt"
-	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
-	"github.com/go-logr/logr"
 	git2go "github.com/libgit2/git2go/v33"
 
 	"github.com/fluxcd/pkg/gitutil"
-	"github.com/fluxcd/pkg/version"
 
 	"github.com/fluxcd/source-controller/pkg/git"
 	"github.com/fluxcd/source-controller/pkg/git/libgit2/managed"
@@ -39,18 +37,29 @@ const defaultRemoteName = "origin"
 // CheckoutStrategyForOptions returns the git.CheckoutStrategy for the given
 // git.CheckoutOptions.
 func CheckoutStrategyForOptions(ctx context.Context, opt git.CheckoutOptions) git.CheckoutStrategy {
-	if opt.RecurseSubmodules {
-		logr.FromContextOrDiscard(ctx).Info(fmt.Sprintf("git submodule recursion not supported by implementation '%s'", Implementation))
-	}
 	switch {
 	case opt.Commit != "":
-		return &CheckoutCommit{Commit: opt.Commit}
+		return &CheckoutCommit{
+			Commit:            opt.Commit,
+			RecurseSubmodules: opt.RecurseSubmodules,
+			Branch:            opt.Branch,
+			RequireInBranch:   opt.RequireCommitInBranch,
+		}
 	case opt.SemVer != "":
-		return &CheckoutSemVer{SemVer: opt.SemVer}
+		return &CheckoutSemVer{
+			SemVer:            opt.SemVer,
+			TagFilter:         opt.TagFilter,
+			TagKind:           opt.TagKind,
+			RecurseSubmodules: opt.RecurseSubmodules,
+			TimeSource:        opt.TimeSource,
+		}
 	case opt.Tag != "":
 		return &CheckoutTag{
-			Tag:          opt.Tag,
-			LastRevision: opt.LastRevision,
+			Tag:               opt.Tag,
+			RecurseSubmodules: opt.RecurseSubmodules,
+			LastRevision:      opt.LastRevision,
+			KeyRings:          opt.TagKeyRings,
+			RequireSignature:  opt.RequireTagSignature,
 		}
 	default:
 		branch := opt.Branch
@@ -58,18 +67,31 @@ func CheckoutStrategyForOptions(ctx context.Context, opt git.CheckoutOptions) gi
 			branch = git.DefaultBranch
 		}
 		return &CheckoutBranch{
-			Branch:       branch,
-			LastRevision: opt.LastRevision,
+			Branch:            branch,
+			RecurseSubmodules: opt.RecurseSubmodules,
+			LastRevision:      opt.LastRevision,
 		}
 	}
 }
 
 type CheckoutBranch struct {
-	Branch       string
-	LastRevision string
+	Branch            string
+	RecurseSubmodules bool
+	LastRevision      string
+}
+
+func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutBranch) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(ctx, func(ctx context.Context) (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
 }
 
-func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
+func (c *CheckoutBranch) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
 	defer recoverPanic(&err)
 
 	// This branching is temporary, to address the transient panics observed when using unmanaged transport.
@@ -97,7 +119,7 @@ func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *g
 			Context:      ctx,
 		})
 		url = opts.TransportOptionsURL
-		remoteCallBacks := managed.RemoteCallbacks()
+		remoteCallBacks := managedRemoteCallbacks(ctx)
 		defer managed.RemoveTransportOptions(opts.TransportOptionsURL)
 
 		repo, remote, err := initializeRepoWithRemote(ctx, path, url, opts)
@@ -109,7 +131,7 @@ func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *g
 		if err != nil {
 			remote.Free()
 			repo.Free()
-			return nil, fmt.Errorf("unable to fetch-connect to remote '%s': %w", managed.EffectiveURL(url), gitutil.LibGit2Error(err))
+			return nil, fmt.Errorf("unable to fetch-connect to remote '%s': %w", managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), c.Branch, gitutil.LibGit2Error(err)))
 		}
 		defer func() {
 			remote.Disconnect()
@@ -117,16 +139,18 @@ func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *g
 			repo.Free()
 		}()
 
+		remoteHeads, err := lsRemote(remote, managed.EffectiveURL(url), c.Branch)
+		if err != nil {
+			return nil, err
+		}
+
 		// When the last observed revision is set, check whether it is still the
 		// same at the remote branch. If so, short-circuit the clone operation here.
 		if c.LastRevision != "" {
-			heads, err := remote.Ls(c.Branch)
-			if err != nil {
-				return nil, fmt.Errorf("unable to remote ls for '%s': %w", managed.EffectiveURL(url), gitutil.LibGit2Error(err))
-			}
+			heads := filterRemoteHeads(remoteHeads, c.Branch)
 			if len(heads) > 0 {
 				hash := heads[0].Id.String()
-				currentRevision := fmt.Sprintf("%s/%s", c.Branch, hash)
+				currentRevision := git.FormatRevision(c.Branch, hash)
 				if currentRevision == c.LastRevision {
 					// Construct a partial commit with the existing information.
 					c := &git.Commit{
@@ -147,11 +171,15 @@ func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *g
 			"")
 		if err != nil {
 			return nil, fmt.Errorf("unable to fetch remote '%s': %w",
-				managed.EffectiveURL(url), gitutil.LibGit2Error(err))
+				managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), c.Branch, gitutil.LibGit2Error(err)))
 		}
 
 		branch, err := repo.References.Lookup(fmt.Sprintf("refs/remotes/origin/%s", c.Branch))
 		if err != nil {
+			if git2go.IsErrorCode(err, git2go.ErrorCodeNotFound) {
+				return nil, fmt.Errorf("unable to lookup branch '%s' for '%s': %w",
+					c.Branch, managed.EffectiveURL(url), &git.ReferenceNotFoundError{Reference: c.Branch, Err: gitutil.LibGit2Error(err)})
+			}
 			return nil, fmt.Errorf("unable to lookup branch '%s' for '%s': %w",
 				c.Branch, managed.EffectiveURL(url), gitutil.LibGit2Error(err))
 		}
@@ -198,6 +226,12 @@ func (c *CheckoutBranch) Checkout(ctx context.Context, path, url string, opts *g
 			return nil, fmt.Errorf("unable to set HEAD to branch '%s':%w", c.Branch, err)
 		}
 
+		if c.RecurseSubmodules {
+			if err = updateSubmodules(ctx, repo, managed.EffectiveURL(url), opts); err != nil {
+				return nil, err
+			}
+		}
+
 		// Use the current worktree's head as reference for the commit to be returned.
 		head, err := repo.Head()
 		if err != nil {
@@ -230,9 +264,14 @@ func (c *CheckoutBranch) checkoutUnmanaged(ctx context.Context, path, url string
 		CheckoutBranch: c.Branch,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), gitutil.LibGit2Error(err))
+		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), c.Branch, gitutil.LibGit2Error(err)))
 	}
 	defer repo.Free()
+	if c.RecurseSubmodules {
+		if err = updateSubmodules(ctx, repo, url, opts); err != nil {
+			return nil, err
+		}
+	}
 	head, err := repo.Head()
 	if err != nil {
 		return nil, fmt.Errorf("git resolve HEAD error: %w", err)
@@ -247,11 +286,29 @@ func (c *CheckoutBranch) checkoutUnmanaged(ctx context.Context, path, url string
 }
 
 type CheckoutTag struct {
-	Tag          string
-	LastRevision string
+	Tag               string
+	RecurseSubmodules bool
+	LastRevision      string
+	// KeyRings are the armored PGP key rings and SSH public keys to verify
+	// the signature of the Tag with, see git.VerifyTag.
+	KeyRings []string
+	// RequireSignature fails the checkout if the signature of the Tag is
+	// missing or invalid.
+	RequireSignature bool
+}
+
+func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
 }
 
-func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutTag) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(ctx, func(ctx context.Context) (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutTag) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
 	defer recoverPanic(&err)
 
 	// This branching is temporary, to address the transient panics observed when using unmanaged transport.
@@ -269,7 +326,7 @@ func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.
 			Context:      ctx,
 		})
 		url = opts.TransportOptionsURL
-		remoteCallBacks := managed.RemoteCallbacks()
+		remoteCallBacks := managedRemoteCallbacks(ctx)
 		defer managed.RemoveTransportOptions(opts.TransportOptionsURL)
 
 		repo, remote, err := initializeRepoWithRemote(ctx, path, url, opts)
@@ -281,7 +338,7 @@ func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.
 		if err != nil {
 			remote.Free()
 			repo.Free()
-			return nil, fmt.Errorf("unable to fetch-connect to remote '%s': %w", managed.EffectiveURL(url), gitutil.LibGit2Error(err))
+			return nil, fmt.Errorf("unable to fetch-connect to remote '%s': %w", managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), c.Tag, gitutil.LibGit2Error(err)))
 		}
 		defer func() {
 			remote.Disconnect()
@@ -289,22 +346,24 @@ func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.
 			repo.Free()
 		}()
 
+		remoteHeads, err := lsRemote(remote, managed.EffectiveURL(url), c.Tag)
+		if err != nil {
+			return nil, err
+		}
+
 		// When the last observed revision is set, check whether it is still the
 		// same at the remote branch. If so, short-circuit the clone operation here.
 		if c.LastRevision != "" {
-			heads, err := remote.Ls(c.Tag)
-			if err != nil {
-				return nil, fmt.Errorf("unable to remote ls for '%s': %w", managed.EffectiveURL(url), gitutil.LibGit2Error(err))
-			}
+			heads := filterRemoteHeads(remoteHeads, c.Tag)
 			if len(heads) > 0 {
 				hash := heads[0].Id.String()
-				currentRevision := fmt.Sprintf("%s/%s", c.Tag, hash)
+				currentRevision := git.FormatRevision(c.Tag, hash)
 				var same bool
 				if currentRevision == c.LastRevision {
 					same = true
 				} else if len(heads) > 1 {
 					hash = heads[1].Id.String()
-					currentAnnotatedRevision := fmt.Sprintf("%s/%s", c.Tag, hash)
+					currentAnnotatedRevision := git.FormatRevision(c.Tag, hash)
 					if currentAnnotatedRevision == c.LastRevision {
 						same = true
 					}
@@ -329,7 +388,7 @@ func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.
 
 		if err != nil {
 			return nil, fmt.Errorf("unable to fetch remote '%s': %w",
-				managed.EffectiveURL(url), gitutil.LibGit2Error(err))
+				managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), c.Tag, gitutil.LibGit2Error(err)))
 		}
 
 		cc, err := checkoutDetachedDwim(repo, c.Tag)
@@ -337,7 +396,12 @@ func (c *CheckoutTag) Checkout(ctx context.Context, path, url string, opts *git.
 			return nil, err
 		}
 		defer cc.Free()
-		return buildCommit(cc, "refs/tags/"+c.Tag), nil
+		if c.RecurseSubmodules {
+			if err = updateSubmodules(ctx, repo, managed.EffectiveURL(url), opts); err != nil {
+				return nil, err
+			}
+		}
+		return c.verifyTag(repo, buildCommit(cc, "refs/tags/"+c.Tag))
 	} else {
 		return c.checkoutUnmanaged(ctx, path, url, opts)
 	}
@@ -352,7 +416,7 @@ func (c *CheckoutTag) checkoutUnmanaged(ctx context.Context, path, url string, o
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), gitutil.LibGit2Error(err))
+		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), c.Tag, gitutil.LibGit2Error(err)))
 	}
 	defer repo.Free()
 	cc, err := checkoutDetachedDwim(repo, c.Tag)
@@ -360,16 +424,83 @@ func (c *CheckoutTag) checkoutUnmanaged(ctx context.Context, path, url string, o
 		return nil, err
 	}
 	defer cc.Free()
-	return buildCommit(cc, "refs/tags/"+c.Tag), nil
+	if c.RecurseSubmodules {
+		if err = updateSubmodules(ctx, repo, url, opts); err != nil {
+			return nil, err
+		}
+	}
+	return c.verifyTag(repo, buildCommit(cc, "refs/tags/"+c.Tag))
+}
+
+// verifyTag records the verification of the signature of the Tag on the
+// given commit, if KeyRings are configured or a signature is required.
+func (c *CheckoutTag) verifyTag(repo *git2go.Repository, commit *git.Commit) (*git.Commit, error) {
+	if len(c.KeyRings) == 0 && !c.RequireSignature {
+		return commit, nil
+	}
+	raw, err := rawTagObject(repo, c.Tag)
+	if err != nil {
+		return nil, err
+	}
+	if commit.TagVerification, err = git.VerifyTag(c.Tag, raw, c.RequireSignature, c.KeyRings...); err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
+
+// rawTagObject returns the raw content of the tag object the given tag
+// points to, or nil for a lightweight tag.
+func rawTagObject(repo *git2go.Repository, tag string) ([]byte, error) {
+	ref, err := repo.References.Lookup("refs/tags/" + tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tag '%s': %w", tag, err)
+	}
+	defer ref.Free()
+	odb, err := repo.Odb()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object database: %w", err)
+	}
+	defer odb.Free()
+	obj, err := odb.Read(ref.Target())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object of tag '%s': %w", tag, err)
+	}
+	defer obj.Free()
+	if obj.Type() != git2go.ObjectTag {
+		return nil, nil
+	}
+	// Copy the data, as it is only valid until the object is freed.
+	return append([]byte{}, obj.Data()...), nil
 }
 
 type CheckoutCommit struct {
-	Commit string
+	Commit            string
+	RecurseSubmodules bool
+	// Branch the Commit must be reachable from if RequireInBranch is set.
+	Branch string
+	// RequireInBranch defines if the Commit must be reachable from the tip
+	// of the Branch.
+	RequireInBranch bool
+}
+
+func (c *CheckoutCommit) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
 }
 
-func (c *CheckoutCommit) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutCommit) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(ctx, func(ctx context.Context) (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutCommit) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
 	defer recoverPanic(&err)
 
+	if c.RequireInBranch && c.Branch == "" {
+		return nil, fmt.Errorf("a branch is required to verify commit '%s' is reachable from it", c.Commit)
+	}
+
 	remoteCallBacks := RemoteCallbacks(ctx, opts)
 
 	if managed.Enabled() {
@@ -383,7 +514,7 @@ func (c *CheckoutCommit) Checkout(ctx context.Context, path, url string, opts *g
 			Context:      ctx,
 		})
 		url = opts.TransportOptionsURL
-		remoteCallBacks = managed.RemoteCallbacks()
+		remoteCallBacks = managedRemoteCallbacks(ctx)
 		defer managed.RemoveTransportOptions(opts.TransportOptionsURL)
 	}
 
@@ -394,25 +525,243 @@ func (c *CheckoutCommit) Checkout(ctx context.Context, path, url string, opts *g
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), gitutil.LibGit2Error(err))
+		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), c.Commit, gitutil.LibGit2Error(err)))
 	}
 	defer repo.Free()
+	if err = repoNotEmpty(repo, managed.EffectiveURL(url)); err != nil {
+		return nil, err
+	}
 	oid, err := git2go.NewOid(c.Commit)
 	if err != nil {
 		return nil, fmt.Errorf("could not create oid for '%s': %w", c.Commit, err)
 	}
+	if c.RequireInBranch {
+		if err = commitInBranch(repo, oid, c.Branch); err != nil {
+			return nil, err
+		}
+	}
 	cc, err := checkoutDetachedHEAD(repo, oid)
 	if err != nil {
 		return nil, fmt.Errorf("git checkout error: %w", err)
 	}
+	defer cc.Free()
+	if c.RecurseSubmodules {
+		if err = updateSubmodules(ctx, repo, managed.EffectiveURL(url), opts); err != nil {
+			return nil, err
+		}
+	}
 	return buildCommit(cc, ""), nil
 }
 
+// commitInBranch returns a git.CommitNotInBranchError if the commit with
+// the given oid is not reachable from the tip of the remote branch.
+func commitInBranch(repo *git2go.Repository, oid *git2go.Oid, branch string) error {
+	ref, err := repo.References.Lookup(fmt.Sprintf("refs/remotes/%s/%s", git.DefaultOrigin, branch))
+	if err != nil {
+		return fmt.Errorf("unable to resolve branch '%s': %w", branch, err)
+	}
+	defer ref.Free()
+	tip := ref.Target()
+	if tip.Equal(oid) {
+		return nil
+	}
+	ok, err := repo.DescendantOf(tip, oid)
+	if err != nil {
+		return fmt.Errorf("unable to verify commit '%s' is reachable from branch '%s': %w", oid, branch, err)
+	}
+	if !ok {
+		return &git.CommitNotInBranchError{Commit: oid.String(), Branch: branch}
+	}
+	return nil
+}
+
+type CheckoutMergeBase struct {
+	BranchA string
+	BranchB string
+}
+
+func (c *CheckoutMergeBase) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutMergeBase) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(ctx, func(ctx context.Context) (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutMergeBase) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
+	defer recoverPanic(&err)
+
+	remoteCallBacks := RemoteCallbacks(ctx, opts)
+
+	if managed.Enabled() {
+		if opts.TransportOptionsURL == "" {
+			return nil, fmt.Errorf("can't use managed transport without a valid transport auth id.")
+		}
+		managed.AddTransportOptions(opts.TransportOptionsURL, managed.TransportOptions{
+			TargetURL:    url,
+			AuthOpts:     opts,
+			ProxyOptions: &git2go.ProxyOptions{Type: git2go.ProxyTypeAuto},
+			Context:      ctx,
+		})
+		url = opts.TransportOptionsURL
+		remoteCallBacks = managedRemoteCallbacks(ctx)
+		defer managed.RemoveTransportOptions(opts.TransportOptionsURL)
+	}
+
+	repo, err := git2go.Clone(url, path, &git2go.CloneOptions{
+		FetchOptions: git2go.FetchOptions{
+			DownloadTags:    git2go.DownloadTagsNone,
+			RemoteCallbacks: remoteCallBacks,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), c.BranchA, gitutil.LibGit2Error(err)))
+	}
+	defer repo.Free()
+
+	var heads []*git2go.Oid
+	for _, branch := range []string{c.BranchA, c.BranchB} {
+		ref, err := repo.References.Lookup(fmt.Sprintf("refs/remotes/%s/%s", git.DefaultOrigin, branch))
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve branch '%s': %w", branch, classifyError(managed.EffectiveURL(url), branch, gitutil.LibGit2Error(err)))
+		}
+		heads = append(heads, ref.Target())
+		ref.Free()
+	}
+
+	oid, err := repo.MergeBase(heads[0], heads[1])
+	if err != nil {
+		if git2go.IsErrorCode(err, git2go.ErrorCodeNotFound) {
+			return nil, fmt.Errorf("branches '%s' and '%s' share no history: no merge-base found", c.BranchA, c.BranchB)
+		}
+		return nil, fmt.Errorf("failed to compute merge-base of branches '%s' and '%s': %w", c.BranchA, c.BranchB, gitutil.LibGit2Error(err))
+	}
+	cc, err := checkoutDetachedHEAD(repo, oid)
+	if err != nil {
+		return nil, fmt.Errorf("git checkout error: %w", err)
+	}
+	defer cc.Free()
+	return buildCommit(cc, ""), nil
+}
+
+// CheckoutBranchAt checks out the last commit of the Branch at or before the
+// Time, similar to git's <branch>@{<date>} but based on the history of the
+// branch instead of the reflog. The first-parent history of the branch is
+// walked from its tip, up to the first commit of which the time selected by
+// the TimeSource is not after the Time.
+type CheckoutBranchAt struct {
+	Branch string
+	Time   time.Time
+	// TimeSource selects the timestamp of the commits compared to the Time,
+	// defaults to git.TimeSourceCommit.
+	TimeSource git.TimeSource
+}
+
+func (c *CheckoutBranchAt) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutBranchAt) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(ctx, func(ctx context.Context) (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
+}
+
+func (c *CheckoutBranchAt) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
+	defer recoverPanic(&err)
+
+	remoteCallBacks := RemoteCallbacks(ctx, opts)
+
+	if managed.Enabled() {
+		if opts.TransportOptionsURL == "" {
+			return nil, fmt.Errorf("can't use managed transport without a valid transport auth id.")
+		}
+		managed.AddTransportOptions(opts.TransportOptionsURL, managed.TransportOptions{
+			TargetURL:    url,
+			AuthOpts:     opts,
+			ProxyOptions: &git2go.ProxyOptions{Type: git2go.ProxyTypeAuto},
+			Context:      ctx,
+		})
+		url = opts.TransportOptionsURL
+		remoteCallBacks = managedRemoteCallbacks(ctx)
+		defer managed.RemoveTransportOptions(opts.TransportOptionsURL)
+	}
+
+	// The full history of the branch is cloned to be able to walk it.
+	repo, err := git2go.Clone(url, path, &git2go.CloneOptions{
+		FetchOptions: git2go.FetchOptions{
+			DownloadTags:    git2go.DownloadTagsNone,
+			RemoteCallbacks: remoteCallBacks,
+		},
+		CheckoutBranch: c.Branch,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), c.Branch, gitutil.LibGit2Error(err)))
+	}
+	defer repo.Free()
+
+	ref, err := repo.References.Lookup(fmt.Sprintf("refs/remotes/%s/%s", git.DefaultOrigin, c.Branch))
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve branch '%s': %w", c.Branch, classifyError(managed.EffectiveURL(url), c.Branch, gitutil.LibGit2Error(err)))
+	}
+	defer ref.Free()
+	cc, err := repo.LookupCommit(ref.Target())
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve commit object for branch '%s': %w", c.Branch, gitutil.LibGit2Error(err))
+	}
+	for commitTime(cc, c.TimeSource).After(c.Time) {
+		if cc.ParentCount() == 0 {
+			cc.Free()
+			return nil, fmt.Errorf("no commit on branch '%s' at or before %s", c.Branch, c.Time.UTC().Format(time.RFC3339))
+		}
+		parent := cc.Parent(0)
+		if parent == nil {
+			err = fmt.Errorf("failed to resolve parent of commit '%s'", cc.Id())
+			cc.Free()
+			return nil, err
+		}
+		cc.Free()
+		cc = parent
+	}
+	oid := cc.Id()
+	cc.Free()
+
+	cc, err = checkoutDetachedHEAD(repo, oid)
+	if err != nil {
+		return nil, fmt.Errorf("git checkout error: %w", err)
+	}
+	defer cc.Free()
+	return buildCommit(cc, "refs/heads/"+c.Branch), nil
+}
+
 type CheckoutSemVer struct {
-	SemVer string
+	SemVer    string
+	TagFilter string
+	// TagKind selects the kind of tags which are considered, defaults to
+	// git.TagKindAny.
+	TagKind           git.TagKind
+	RecurseSubmodules bool
+	// TimeSource selects the timestamp of the tagged commits which orders
+	// tags with an equal version, defaults to git.TimeSourceCommit.
+	TimeSource git.TimeSource
+}
+
+func (c *CheckoutSemVer) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.Commit, error) {
+	return git.CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+// CheckoutWithResult implements git.ResultCheckoutStrategy.
+func (c *CheckoutSemVer) CheckoutWithResult(ctx context.Context, path, url string, opts *git.AuthOptions) (*git.CheckoutResult, error) {
+	return checkoutWithResult(ctx, func(ctx context.Context) (*git.Commit, error) {
+		return c.checkout(ctx, path, url, opts)
+	})
 }
 
-func (c *CheckoutSemVer) Checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
+func (c *CheckoutSemVer) checkout(ctx context.Context, path, url string, opts *git.AuthOptions) (_ *git.Commit, err error) {
 	defer recoverPanic(&err)
 
 	remoteCallBacks := RemoteCallbacks(ctx, opts)
@@ -428,7 +777,7 @@ func (c *CheckoutSemVer) Checkout(ctx context.Context, path, url string, opts *g
 			Context:      ctx,
 		})
 		url = opts.TransportOptionsURL
-		remoteCallBacks = managed.RemoteCallbacks()
+		remoteCallBacks = managedRemoteCallbacks(ctx)
 		defer managed.RemoveTransportOptions(opts.TransportOptionsURL)
 	}
 
@@ -436,6 +785,14 @@ func (c *CheckoutSemVer) Checkout(ctx context.Context, path, url string, opts *g
 	if err != nil {
 		return nil, fmt.Errorf("semver parse error: %w", err)
 	}
+	tagFilter, err := git.CompileTagFilter(c.TagFilter)
+	if err != nil {
+		return nil, err
+	}
+	tagKind, err := git.ParseTagKind(string(c.TagKind))
+	if err != nil {
+		return nil, err
+	}
 
 	repo, err := git2go.Clone(url, path, &git2go.CloneOptions{
 		FetchOptions: git2go.FetchOptions{
@@ -444,92 +801,103 @@ func (c *CheckoutSemVer) Checkout(ctx context.Context, path, url string, opts *g
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), gitutil.LibGit2Error(err))
+		return nil, fmt.Errorf("unable to clone '%s': %w", managed.EffectiveURL(url), classifyError(managed.EffectiveURL(url), "", gitutil.LibGit2Error(err)))
 	}
 	defer repo.Free()
+	if err = repoNotEmpty(repo, managed.EffectiveURL(url)); err != nil {
+		return nil, err
+	}
 
-	tags := make(map[string]string)
-	tagTimestamps := make(map[string]time.Time)
+	var tags []string
 	if err := repo.Tags.Foreach(func(name string, id *git2go.Oid) error {
-		cleanName := strings.TrimPrefix(name, "refs/tags/")
-		// The given ID can refer to both a commit and a tag, as annotated tags contain additional metadata.
-		// Due to this, first attempt to resolve it as a simple tag (commit), but fallback to attempting to
-		// resolve it as an annotated tag in case this results in an error.
-		if c, err := repo.LookupCommit(id); err == nil {
-			defer c.Free()
-			// Use the commit metadata as the decisive timestamp.
-			tagTimestamps[cleanName] = c.Committer().When
-			tags[cleanName] = name
-			return nil
-		}
-		t, err := repo.LookupTag(id)
-		if err != nil {
-			return fmt.Errorf("could not lookup '%s' as simple or annotated tag: %w", cleanName, err)
-		}
-		defer t.Free()
-		commit, err := t.Peel(git2go.ObjectCommit)
+		// Annotated tags refer to a tag object, lightweight tags directly
+		// to a commit.
+		obj, err := repo.Lookup(id)
 		if err != nil {
-			return fmt.Errorf("could not get commit for tag '%s': %w", t.Name(), err)
+			return fmt.Errorf("unable to resolve tag '%s': %w", name, err)
 		}
-		defer commit.Free()
-		c, err := commit.AsCommit()
-		if err != nil {
-			return fmt.Errorf("could not get commit object for tag '%s': %w", t.Name(), err)
+		annotated := obj.Type() == git2go.ObjectTag
+		obj.Free()
+		if tagKind.Matches(annotated) {
+			tags = append(tags, strings.TrimPrefix(name, "refs/tags/"))
 		}
-		defer c.Free()
-		tagTimestamps[t.Name()] = c.Committer().When
-		tags[t.Name()] = name
 		return nil
 	}); err != nil {
 		return nil, err
 	}
 
-	var matchedVersions semver.Collection
-	for tag := range tags {
-		v, err := version.ParseVersion(tag)
+	// The repository is not safe for concurrent use.
+	var mu sync.Mutex
+	t, err := git.LatestSemVerTag(tags, verConstraint, tagFilter, func(name string) (time.Time, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The reference can refer to both a commit and a tag, as annotated
+		// tags contain additional metadata. Peeling it resolves both to the
+		// tagged commit.
+		ref, err := repo.References.Lookup("refs/tags/" + name)
 		if err != nil {
-			continue
+			return time.Time{}, fmt.Errorf("could not lookup tag '%s': %w", name, err)
 		}
-		if !verConstraint.Check(v) {
-			continue
+		defer ref.Free()
+		obj, err := ref.Peel(git2go.ObjectCommit)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("could not get commit for tag '%s': %w", name, err)
+		}
+		defer obj.Free()
+		cc, err := obj.AsCommit()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("could not get commit object for tag '%s': %w", name, err)
 		}
-		matchedVersions = append(matchedVersions, v)
+		defer cc.Free()
+		return commitTime(cc, c.TimeSource), nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	if len(matchedVersions) == 0 {
+	if t == "" {
+		if tagFilter != nil {
+			return nil, fmt.Errorf("no match found for semver: %s with tag filter: %s", c.SemVer, c.TagFilter)
+		}
 		return nil, fmt.Errorf("no match found for semver: %s", c.SemVer)
 	}
 
-	// Sort versions
-	sort.SliceStable(matchedVersions, func(i, j int) bool {
-		left := matchedVersions[i]
-		right := matchedVersions[j]
-
-		if !left.Equal(right) {
-			return left.LessThan(right)
-		}
-
-		// Having tag target timestamps at our disposal, we further try to sort
-		// versions into a chronological order. This is especially important for
-		// versions that differ only by build metadata, because it is not considered
-		// a part of the comparable version in Semver
-		return tagTimestamps[left.Original()].Before(tagTimestamps[right.Original()])
-	})
-	v := matchedVersions[len(matchedVersions)-1]
-	t := v.Original()
-
 	cc, err := checkoutDetachedDwim(repo, t)
 	if err != nil {
 		return nil, err
 	}
 	defer cc.Free()
+	if c.RecurseSubmodules {
+		if err = updateSubmodules(ctx, repo, managed.EffectiveURL(url), opts); err != nil {
+			return nil, err
+		}
+	}
 	return buildCommit(cc, "refs/tags/"+t), nil
 }
 
+// checkoutWithResult returns the git.CheckoutResult of the given checkout
+// function, of which the Stats contain the transfer progress reported by
+// libgit2 to the RemoteCallbacks.
+func checkoutWithResult(ctx context.Context, checkout func(ctx context.Context) (*git.Commit, error)) (*git.CheckoutResult, error) {
+	stats := &git.TransportMetrics{}
+	start := time.Now()
+	commit, err := checkout(context.WithValue(ctx, transportMetricsKey{}, stats))
+	if err != nil {
+		return nil, err
+	}
+	result := git.NewCheckoutResult(commit)
+	stats.Duration = time.Since(start)
+	result.Stats = *stats
+	return result, nil
+}
+
 // checkoutDetachedDwim attempts to perform a detached HEAD checkout by first DWIMing the short name
 // to get a concrete reference, and then calling checkoutDetachedHEAD.
 func checkoutDetachedDwim(repo *git2go.Repository, name string) (*git2go.Commit, error) {
 	ref, err := repo.References.Dwim(name)
 	if err != nil {
+		if git2go.IsErrorCode(err, git2go.ErrorCodeNotFound) {
+			err = &git.ReferenceNotFoundError{Reference: name, Err: err}
+		}
 		return nil, fmt.Errorf("unable to find '%s': %w", name, err)
 	}
 	defer ref.Free()
@@ -550,6 +918,9 @@ func checkoutDetachedDwim(repo *git2go.Repository, name string) (*git2go.Commit,
 func checkoutDetachedHEAD(repo *git2go.Repository, oid *git2go.Oid) (*git2go.Commit, error) {
 	cc, err := repo.LookupCommit(oid)
 	if err != nil {
+		if git2go.IsErrorCode(err, git2go.ErrorCodeNotFound) {
+			err = &git.ReferenceNotFoundError{Reference: oid.String(), Err: err}
+		}
 		return nil, fmt.Errorf("git commit '%s' not found: %w", oid.String(), err)
 	}
 	if err = repo.SetHeadDetached(cc.Id()); err != nil {
@@ -592,6 +963,12 @@ func buildCommit(c *git2go.Commit, ref string) *git.Commit {
 	}
 }
 
+// commitTime returns the time of the commit selected by the given
+// git.TimeSource.
+func commitTime(c *git2go.Commit, source git.TimeSource) time.Time {
+	return git.SignatureTime(source, buildSignature(c.Author()), buildSignature(c.Committer()))
+}
+
 func buildSignature(s *git2go.Signature) git.Signature {
 	return git.Signature{
 		Name:  s.Name,
@@ -630,6 +1007,44 @@ func initializeRepoWithRemote(ctx context.Context, path, url string, opts *git.A
 	return repo, remote, nil
 }
 
+// lsRemote lists the references advertised by the connected remote, and
+// returns a git.EmptyRepositoryError if there are none.
+func lsRemote(remote *git2go.Remote, url, ref string) ([]git2go.RemoteHead, error) {
+	heads, err := remote.Ls()
+	if err != nil {
+		return nil, fmt.Errorf("unable to remote ls for '%s': %w", url, classifyError(url, ref, gitutil.LibGit2Error(err)))
+	}
+	if len(heads) == 0 {
+		return nil, &git.EmptyRepositoryError{URL: url}
+	}
+	return heads, nil
+}
+
+// repoNotEmpty returns a git.EmptyRepositoryError if the repository cloned
+// from url does not have any references.
+func repoNotEmpty(repo *git2go.Repository, url string) error {
+	empty, err := repo.IsEmpty()
+	if err != nil {
+		return fmt.Errorf("unable to determine if '%s' is empty: %w", url, gitutil.LibGit2Error(err))
+	}
+	if empty {
+		return &git.EmptyRepositoryError{URL: url}
+	}
+	return nil
+}
+
+// filterRemoteHeads returns the heads with a name containing ref, matching
+// the filter semantics of git2go.Remote.Ls.
+func filterRemoteHeads(heads []git2go.RemoteHead, ref string) []git2go.RemoteHead {
+	var filtered []git2go.RemoteHead
+	for _, h := range heads {
+		if strings.Contains(h.Name, ref) {
+			filtered = append(filtered, h)
+		}
+	}
+	return filtered
+}
+
 func recoverPanic(err *error) {
 	if r := recover(); r != nil {
 		*err = fmt.Errorf("recovered from git2go panic: %v", r)
@@ -20,12 +20,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/fluxcd/gitkit"
 	"github.com/fluxcd/pkg/gittestserver"
+	"github.com/fluxcd/pkg/ssh"
 	git2go "github.com/libgit2/git2go/v33"
 	. "github.com/onsi/gomega"
 
@@ -378,6 +382,219 @@ func checkoutCommit(t *testing.T, managed bool) {
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(err.Error()).To(HavePrefix("git checkout error: git commit '4dc3185c5fc94eb75048376edeb44571cece25f4' not found:"))
 	g.Expect(cc).To(BeNil())
+
+	// Commit to a feature branch, which is not reachable from the default
+	// branch.
+	if err = createBranch(repo, "feature", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.SetHead("refs/heads/feature"); err != nil {
+		t.Fatal(err)
+	}
+	feature, err := commitFile(repo, "commit", "feature", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.SetHead("refs/heads/" + git.DefaultBranch); err != nil {
+		t.Fatal(err)
+	}
+
+	commit = CheckoutCommit{
+		Commit:          c.String(),
+		Branch:          git.DefaultBranch,
+		RequireInBranch: true,
+	}
+	cc, err = commit.Checkout(context.TODO(), t.TempDir(), repoURL, &authOpts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(c.String()))
+
+	commit = CheckoutCommit{
+		Commit:          feature.String(),
+		Branch:          git.DefaultBranch,
+		RequireInBranch: true,
+	}
+	cc, err = commit.Checkout(context.TODO(), t.TempDir(), repoURL, &authOpts)
+	g.Expect(err).To(Equal(&git.CommitNotInBranchError{Commit: feature.String(), Branch: git.DefaultBranch}))
+	g.Expect(cc).To(BeNil())
+}
+
+func TestCheckoutMergeBase_unmanaged(t *testing.T) {
+	checkoutMergeBase(t, false)
+}
+
+// checkoutMergeBase is a test helper function which runs the tests for
+// checking out via CheckoutMergeBase.
+func checkoutMergeBase(t *testing.T, managed bool) {
+	g := NewWithT(t)
+	g.Expect(mt.Enabled()).To(Equal(managed))
+
+	server, err := gittestserver.NewTempGitServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(server.Root())
+
+	err = server.StartHTTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.StopHTTP()
+
+	repoPath := "test.git"
+	err = server.InitRepo("../testdata/git/repo", git.DefaultBranch, repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git2go.OpenRepository(filepath.Join(server.Root(), repoPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Free()
+
+	base, err := commitFile(repo, "commit", "base", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = createBranch(repo, "feature", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = commitFile(repo, "commit", "master", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// Commit to the feature branch, and to an orphan branch which does
+	// not share any history with the others.
+	for _, branch := range []string{"feature", "orphan"} {
+		if err = repo.SetHead("refs/heads/" + branch); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = commitFile(repo, "commit", branch, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = repo.SetHead("refs/heads/" + git.DefaultBranch); err != nil {
+		t.Fatal(err)
+	}
+
+	authOpts := git.AuthOptions{
+		TransportOptionsURL: getTransportOptionsURL(git.HTTP),
+	}
+	repoURL := server.HTTPAddress() + "/" + repoPath
+
+	mergeBase := CheckoutMergeBase{
+		BranchA: git.DefaultBranch,
+		BranchB: "feature",
+	}
+	tmpDir := t.TempDir()
+
+	cc, err := mergeBase.Checkout(context.TODO(), tmpDir, repoURL, &authOpts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc).ToNot(BeNil())
+	g.Expect(cc.String()).To(Equal("HEAD/" + base.String()))
+	g.Expect(os.ReadFile(filepath.Join(tmpDir, "commit"))).To(BeEquivalentTo("base"))
+
+	mergeBase = CheckoutMergeBase{
+		BranchA: git.DefaultBranch,
+		BranchB: "orphan",
+	}
+	tmpDir2 := t.TempDir()
+
+	cc, err = mergeBase.Checkout(context.TODO(), tmpDir2, repoURL, &authOpts)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(Equal("branches 'master' and 'orphan' share no history: no merge-base found"))
+	g.Expect(cc).To(BeNil())
+
+	mergeBase = CheckoutMergeBase{
+		BranchA: git.DefaultBranch,
+		BranchB: "invalid",
+	}
+	tmpDir3 := t.TempDir()
+
+	cc, err = mergeBase.Checkout(context.TODO(), tmpDir3, repoURL, &authOpts)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(HavePrefix("unable to resolve branch 'invalid':"))
+	g.Expect(cc).To(BeNil())
+}
+
+func TestCheckoutBranchAt_unmanaged(t *testing.T) {
+	checkoutBranchAt(t, false)
+}
+
+// checkoutBranchAt is a test helper function which runs the tests for
+// checking out via CheckoutBranchAt.
+func checkoutBranchAt(t *testing.T, managed bool) {
+	g := NewWithT(t)
+	g.Expect(mt.Enabled()).To(Equal(managed))
+
+	server, err := gittestserver.NewTempGitServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(server.Root())
+
+	err = server.StartHTTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.StopHTTP()
+
+	repoPath := "test.git"
+	err = server.InitRepo("../testdata/git/repo", git.DefaultBranch, repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git2go.OpenRepository(filepath.Join(server.Root(), repoPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Free()
+
+	// Commit to a branch which does not share any history with the
+	// commits of the test data.
+	if err = repo.SetHead("refs/heads/history"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	var commits []*git2go.Oid
+	for i, content := range []string{"first", "second", "third"} {
+		cc, err := commitFile(repo, "commit", content, start.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, cc)
+	}
+	if err = repo.SetHead("refs/heads/" + git.DefaultBranch); err != nil {
+		t.Fatal(err)
+	}
+
+	authOpts := git.AuthOptions{
+		TransportOptionsURL: getTransportOptionsURL(git.HTTP),
+	}
+	repoURL := server.HTTPAddress() + "/" + repoPath
+
+	branchAt := CheckoutBranchAt{
+		Branch: "history",
+		Time:   start.Add(90 * time.Minute),
+	}
+	tmpDir := t.TempDir()
+
+	cc, err := branchAt.Checkout(context.TODO(), tmpDir, repoURL, &authOpts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc).ToNot(BeNil())
+	g.Expect(cc.String()).To(Equal("history/" + commits[1].String()))
+	g.Expect(os.ReadFile(filepath.Join(tmpDir, "commit"))).To(BeEquivalentTo("second"))
+
+	branchAt = CheckoutBranchAt{
+		Branch: "history",
+		Time:   start.Add(-time.Minute),
+	}
+	tmpDir2 := t.TempDir()
+
+	cc, err = branchAt.Checkout(context.TODO(), tmpDir2, repoURL, &authOpts)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(Equal("no commit on branch 'history' at or before 2022-06-01T11:59:00Z"))
+	g.Expect(cc).To(BeNil())
 }
 
 func TestCheckoutTagSemVer_unmanaged(t *testing.T) {
@@ -428,6 +645,7 @@ func checkoutSemVer(t *testing.T, managed bool) {
 	tests := []struct {
 		name       string
 		constraint string
+		tagFilter  string
 		expectErr  error
 		expectTag  string
 	}{
@@ -446,6 +664,30 @@ func checkoutSemVer(t *testing.T, managed bool) {
 			constraint: ">=1.0.0",
 			expectErr:  errors.New("no match found for semver: >=1.0.0"),
 		},
+		{
+			name:       "Only considers tags matching filter",
+			constraint: ">=0.0.1",
+			tagFilter:  "^v",
+			expectTag:  "v0.1.0+build-3",
+		},
+		{
+			name:       "Only considers tags matching filter with metadata",
+			constraint: "<0.2.0",
+			tagFilter:  `\+build-[12]$`,
+			expectTag:  "v0.1.0+build-2",
+		},
+		{
+			name:       "Errors without match for filter",
+			constraint: ">=0.2.0",
+			tagFilter:  "^v",
+			expectErr:  errors.New("no match found for semver: >=0.2.0 with tag filter: ^v"),
+		},
+		{
+			name:       "Errors on invalid filter",
+			constraint: ">=0.0.1",
+			tagFilter:  "(",
+			expectErr:  errors.New("invalid tag filter '(': error parsing regexp: missing closing ): `(`"),
+		},
 	}
 
 	server, err := gittestserver.NewTempGitServer()
@@ -501,7 +743,8 @@ func checkoutSemVer(t *testing.T, managed bool) {
 			g.Expect(mt.Enabled()).To(Equal(managed))
 
 			semVer := CheckoutSemVer{
-				SemVer: tt.constraint,
+				SemVer:    tt.constraint,
+				TagFilter: tt.tagFilter,
 			}
 
 			tmpDir := t.TempDir()
@@ -511,7 +754,7 @@ func checkoutSemVer(t *testing.T, managed bool) {
 
 			cc, err := semVer.Checkout(context.TODO(), tmpDir, repoURL, &authOpts)
 			if tt.expectErr != nil {
-				g.Expect(err).To(Equal(tt.expectErr))
+				g.Expect(err).To(MatchError(tt.expectErr.Error()))
 				g.Expect(cc).To(BeNil())
 				return
 			}
@@ -524,6 +767,200 @@ func checkoutSemVer(t *testing.T, managed bool) {
 	}
 }
 
+func TestCheckoutBranch_Submodules_unmanaged(t *testing.T) {
+	checkoutSubmodules(t, false)
+}
+
+// checkoutSubmodules is a test helper function which runs the tests for
+// checking out a repository with a (relative) submodule via CheckoutBranch.
+func checkoutSubmodules(t *testing.T, managed bool) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	subRepoPath := "sub.git"
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, subRepoPath)).To(Succeed())
+	subRepo, err := git2go.OpenRepository(filepath.Join(server.Root(), subRepoPath))
+	g.Expect(err).ToNot(HaveOccurred())
+	defer subRepo.Free()
+	subCommit, err := commitFile(subRepo, "sub-file", "submodule content", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	repoPath := "test.git"
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, repoPath)).To(Succeed())
+	repo, err := git2go.OpenRepository(filepath.Join(server.Root(), repoPath))
+	g.Expect(err).ToNot(HaveOccurred())
+	defer repo.Free()
+	_, err = commitSubmodule(repo, "sub", "../"+subRepoPath, subCommit, time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	repoURL := server.HTTPAddress() + "/" + repoPath
+
+	tests := []struct {
+		name              string
+		recurseSubmodules bool
+		expectSubmodule   bool
+	}{
+		{
+			name:              "with submodule recursion",
+			recurseSubmodules: true,
+			expectSubmodule:   true,
+		},
+		{
+			name:              "without submodule recursion",
+			recurseSubmodules: false,
+			expectSubmodule:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(mt.Enabled()).To(Equal(managed))
+
+			branch := CheckoutBranch{
+				Branch:            git.DefaultBranch,
+				RecurseSubmodules: tt.recurseSubmodules,
+			}
+
+			tmpDir := t.TempDir()
+			authOpts := git.AuthOptions{
+				TransportOptionsURL: getTransportOptionsURL(git.HTTP),
+			}
+
+			cc, err := branch.Checkout(context.TODO(), tmpDir, repoURL, &authOpts)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc).ToNot(BeNil())
+
+			subFile := filepath.Join(tmpDir, "sub", "sub-file")
+			if !tt.expectSubmodule {
+				g.Expect(subFile).ToNot(BeAnExistingFile())
+				return
+			}
+			g.Expect(subFile).To(BeARegularFile())
+			g.Expect(os.ReadFile(subFile)).To(BeEquivalentTo("submodule content"))
+		})
+	}
+}
+
+// checkoutSubmodulesSSH is a test helper function which runs the tests for
+// checking out a repository with a relative submodule via CheckoutBranch over
+// SSH, which requires managed transport.
+func checkoutSubmodulesSSH(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	kp, err := ssh.GenerateKeyPair(ssh.ED25519)
+	g.Expect(err).ToNot(HaveOccurred())
+	authorizedKey := strings.TrimSuffix(string(kp.PublicKey), "\n")
+	server.Auth("", "")
+	server.PublicKeyLookupFunc(func(content string) (*gitkit.PublicKey, error) {
+		if content == authorizedKey {
+			return &gitkit.PublicKey{Content: content}, nil
+		}
+		return nil, fmt.Errorf("pubkey provided '%s' does not match %s", content, authorizedKey)
+	})
+	server.KeyDir(filepath.Join(server.Root(), "keys"))
+	g.Expect(server.ListenSSH()).To(Succeed())
+	go func() {
+		server.StartSSH()
+	}()
+	defer server.StopSSH()
+
+	subRepoPath := "sub.git"
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, subRepoPath)).To(Succeed())
+	subRepo, err := git2go.OpenRepository(filepath.Join(server.Root(), subRepoPath))
+	g.Expect(err).ToNot(HaveOccurred())
+	defer subRepo.Free()
+	subCommit, err := commitFile(subRepo, "sub-file", "submodule content", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	repoPath := "test.git"
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, repoPath)).To(Succeed())
+	repo, err := git2go.OpenRepository(filepath.Join(server.Root(), repoPath))
+	g.Expect(err).ToNot(HaveOccurred())
+	defer repo.Free()
+	_, err = commitSubmodule(repo, "sub", "../"+subRepoPath, subCommit, time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	sshURL := server.SSHAddress()
+	u, err := url.Parse(sshURL)
+	g.Expect(err).ToNot(HaveOccurred())
+	knownHosts, err := ssh.ScanHostKey(u.Host, 5*time.Second, git.HostKeyAlgos, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(mt.Enabled()).To(BeTrue())
+	authOpts := &git.AuthOptions{
+		Identity:            kp.PrivateKey,
+		KnownHosts:          knownHosts,
+		TransportOptionsURL: getTransportOptionsURL(git.SSH),
+	}
+	branch := CheckoutBranch{
+		Branch:            git.DefaultBranch,
+		RecurseSubmodules: true,
+	}
+	tmpDir := t.TempDir()
+	cc, err := branch.Checkout(context.TODO(), tmpDir, sshURL+"/"+repoPath, authOpts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc).ToNot(BeNil())
+	g.Expect(os.ReadFile(filepath.Join(tmpDir, "sub", "sub-file"))).To(BeEquivalentTo("submodule content"))
+}
+
+// checkoutEmptyRepository is a test helper function which runs the tests for
+// checking out an empty repository via the managed transport.
+func checkoutEmptyRepository(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	repoPath := "empty.git"
+	repo, err := git2go.InitRepository(filepath.Join(server.Root(), repoPath), true)
+	g.Expect(err).ToNot(HaveOccurred())
+	repo.Free()
+	repoURL := server.HTTPAddress() + "/" + repoPath
+
+	tests := []struct {
+		name     string
+		strategy git.CheckoutStrategy
+	}{
+		{name: "branch", strategy: &CheckoutBranch{Branch: "master"}},
+		{name: "branch with last revision", strategy: &CheckoutBranch{Branch: "master", LastRevision: "master/0123456789012345678901234567890123456789"}},
+		{name: "tag", strategy: &CheckoutTag{Tag: "v1.0.0"}},
+		{name: "tag with last revision", strategy: &CheckoutTag{Tag: "v1.0.0", LastRevision: "v1.0.0/0123456789012345678901234567890123456789"}},
+		{name: "commit", strategy: &CheckoutCommit{Commit: "0123456789012345678901234567890123456789"}},
+		{name: "semver", strategy: &CheckoutSemVer{SemVer: "*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(mt.Enabled()).To(BeTrue())
+
+			authOpts := git.AuthOptions{
+				TransportOptionsURL: getTransportOptionsURL(git.HTTP),
+			}
+			_, err := tt.strategy.Checkout(context.TODO(), t.TempDir(), repoURL, &authOpts)
+			g.Expect(err).To(HaveOccurred())
+
+			var emptyErr *git.EmptyRepositoryError
+			g.Expect(errors.As(err, &emptyErr)).To(BeTrue(), "expected EmptyRepositoryError, got: %v", err)
+			g.Expect(emptyErr.URL).To(Equal(repoURL))
+		})
+	}
+}
+
 func initBareRepo(t *testing.T) (*git2go.Repository, error) {
 	tmpDir := t.TempDir()
 	repo, err := git2go.InitRepository(tmpDir, true)
@@ -596,6 +1033,60 @@ func commitFile(repo *git2go.Repository, path, content string, time time.Time) (
 	return c, nil
 }
 
+// commitSubmodule commits a submodule with the given name and URL at the
+// given commit to the repository, by adding both the gitlink and a
+// .gitmodules file entry.
+func commitSubmodule(repo *git2go.Repository, name, url string, commit *git2go.Oid, time time.Time) (*git2go.Oid, error) {
+	var parentC []*git2go.Commit
+	head, err := headCommit(repo)
+	if err == nil {
+		defer head.Free()
+		parentC = append(parentC, head)
+	}
+
+	index, err := repo.Index()
+	if err != nil {
+		return nil, err
+	}
+	defer index.Free()
+
+	gitmodules := fmt.Sprintf("[submodule \"%s\"]\n\tpath = %s\n\turl = %s\n", name, name, url)
+	blobOID, err := repo.CreateBlobFromBuffer([]byte(gitmodules))
+	if err != nil {
+		return nil, err
+	}
+	if err := index.Add(&git2go.IndexEntry{
+		Mode: git2go.FilemodeBlob,
+		Id:   blobOID,
+		Path: ".gitmodules",
+	}); err != nil {
+		return nil, err
+	}
+	if err := index.Add(&git2go.IndexEntry{
+		Mode: git2go.FilemodeCommit,
+		Id:   commit,
+		Path: name,
+	}); err != nil {
+		return nil, err
+	}
+	if err := index.Write(); err != nil {
+		return nil, err
+	}
+
+	treeID, err := index.WriteTree()
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := repo.LookupTree(treeID)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Free()
+
+	return repo.CreateCommit("HEAD", mockSignature(time), mockSignature(time), "Add submodule "+name, tree, parentC...)
+}
+
 func tag(repo *git2go.Repository, cId *git2go.Oid, annotated bool, tag string, time time.Time) (*git2go.Oid, error) {
 	commit, err := repo.LookupCommit(cId)
 	if err != nil {
@@ -697,6 +1188,17 @@ func TestCheckoutStrategyForOptions(t *testing.T) {
 				LastRevision: "rrgij20mkmrg",
 			},
 		},
+		{
+			name: "branch with submodules works",
+			opts: git.CheckoutOptions{
+				Branch:            "main",
+				RecurseSubmodules: true,
+			},
+			expectedStrat: &CheckoutBranch{
+				Branch:            "main",
+				RecurseSubmodules: true,
+			},
+		},
 		{
 			name: "empty branch falls back to default",
 			opts: git.CheckoutOptions{},
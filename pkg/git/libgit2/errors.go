@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libgit2
+
+import (
+	"errors"
+	"strings"
+
+	git2go "github.com/libgit2/git2go/v33"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// Messages of errors returned by libgit2, or by the managed transports
+// through libgit2, which can not be recognised by their type because
+// libgit2 only passes on their message.
+var (
+	authenticationErrMessages = []string{
+		"authentication required",
+		"unhandled HTTP error 401 ",
+		"unhandled HTTP error 403 ",
+		"unexpected http status code: 401",
+		"unexpected http status code: 403",
+	}
+	repositoryNotFoundErrMessages = []string{
+		"repository not found",
+		"unhandled HTTP error 404 ",
+		"unexpected http status code: 404",
+	}
+)
+
+// classifyError wraps the given libgit2 error returned by an operation on
+// the remote at url into the typed error of the git package it can be
+// recognised as, or returns git.ClassifyError for it. ref is the reference
+// the operation was performed for, and may be empty.
+func classifyError(url, ref string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if err := git.ClassifyError(url, ref, err); git.IsClassified(err) {
+		return err
+	}
+
+	var gitErr *git2go.GitError
+	switch {
+	case errors.As(err, &gitErr) && gitErr.Code == git2go.ErrorCodeAuth,
+		containsAny(err.Error(), authenticationErrMessages):
+		return &git.AuthenticationError{URL: url, Err: err}
+	case containsAny(err.Error(), repositoryNotFoundErrMessages):
+		return &git.RepositoryNotFoundError{URL: url, Err: err}
+	}
+	return err
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
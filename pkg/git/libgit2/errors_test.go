@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libgit2
+
+import (
+	"errors"
+	"testing"
+
+	git2go "github.com/libgit2/git2go/v33"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+func Test_classifyError(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		err     error
+		wantErr interface{}
+	}{
+		{
+			name:    "authentication error code",
+			url:     "https://example.com/org/repo",
+			err:     &git2go.GitError{Message: "too many redirects or authentication replays", Code: git2go.ErrorCodeAuth},
+			wantErr: &git.AuthenticationError{URL: "https://example.com/org/repo", Err: &git2go.GitError{Message: "too many redirects or authentication replays", Code: git2go.ErrorCodeAuth}},
+		},
+		{
+			name:    "managed transport unauthorized",
+			url:     "https://example.com/org/repo",
+			err:     errors.New("unhandled HTTP error 401 Unauthorized"),
+			wantErr: &git.AuthenticationError{URL: "https://example.com/org/repo", Err: errors.New("unhandled HTTP error 401 Unauthorized")},
+		},
+		{
+			name:    "managed transport not found",
+			url:     "https://example.com/org/repo",
+			err:     errors.New("unhandled HTTP error 404 Not Found"),
+			wantErr: &git.RepositoryNotFoundError{URL: "https://example.com/org/repo", Err: errors.New("unhandled HTTP error 404 Not Found")},
+		},
+		{
+			name:    "proxy status message",
+			url:     "https://example.com/org/repo",
+			err:     errors.New("proxyconnect tcp: 404 Not Found"),
+			wantErr: errors.New("proxyconnect tcp: 404 Not Found"),
+		},
+		{
+			name:    "host key message",
+			url:     "ssh://git@example.com/org/repo",
+			err:     errors.New("ssh: handshake failed: knownhosts: key mismatch"),
+			wantErr: &git.HostKeyMismatchError{Host: "example.com", Err: errors.New("ssh: handshake failed: knownhosts: key mismatch")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := classifyError(tt.url, "", tt.err)
+			g.Expect(err).To(Equal(tt.wantErr))
+			g.Expect(errors.Is(err, tt.err)).To(BeTrue())
+		})
+	}
+}
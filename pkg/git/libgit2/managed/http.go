@@ -55,6 +55,7 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fluxcd/pkg/runtime/logger"
 	pool "github.com/fluxcd/source-controller/internal/transport"
@@ -64,6 +65,14 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
+// defaultUserAgent is the User-Agent of the managed HTTP(S) transport, to
+// which git.AuthOptions.UserAgent is appended.
+const defaultUserAgent = "git/2.0 (flux-libgit2)"
+
+// httpProtocols are the protocols for which the managed HTTP(S) transport
+// is registered.
+var httpProtocols = []string{"http", "https"}
+
 var actionSuffixes = []string{
 	"/info/refs?service=git-upload-pack",
 	"/git-upload-pack",
@@ -75,11 +84,12 @@ var actionSuffixes = []string{
 // HTTP(S) transport that doesn't rely on any lower-level libraries
 // such as OpenSSL.
 func registerManagedHTTP() error {
-	for _, protocol := range []string{"http", "https"} {
+	for _, protocol := range httpProtocols {
 		_, err := git2go.NewRegisteredSmartTransport(protocol, true, httpSmartSubtransportFactory)
 		if err != nil {
 			return fmt.Errorf("failed to register transport for %q: %v", protocol, err)
 		}
+		markRegistered(protocol)
 	}
 	return nil
 }
@@ -113,6 +123,9 @@ type httpSmartSubtransport struct {
 	// reused across all log calls.
 	// If context is not set, this defaults to logr.Discard().
 	logger logr.Logger
+	// log records the requests of the subtransport, to log their result
+	// once it is freed.
+	log transportLog
 }
 
 func (t *httpSmartSubtransport) Action(transportOptionsURL string, action git2go.SmartServiceAction) (git2go.SmartSubtransportStream, error) {
@@ -131,6 +144,13 @@ func (t *httpSmartSubtransport) Action(transportOptionsURL string, action git2go
 		return nil, fmt.Errorf("URL exceeds the max length (%d)", URLMaxLength)
 	}
 
+	// Credentials embedded in the URL are moved to the AuthOptions, so that
+	// they never end up in the logged URLs.
+	targetURL, authOpts, err := urlCredentials(targetURL, opts.AuthOpts)
+	if err != nil {
+		return nil, err
+	}
+
 	var proxyFn func(*http.Request) (*url.URL, error)
 	proxyOpts := opts.ProxyOptions
 	if proxyOpts != nil {
@@ -158,24 +178,25 @@ func (t *httpSmartSubtransport) Action(transportOptionsURL string, action git2go
 			t.ctx = opts.Context
 			t.logger = ctrl.LoggerFrom(t.ctx,
 				"transportType", "http",
-				"url", opts.TargetURL)
+				"url", targetURL)
 		}
 	})
+	t.log.begin()
 
-	client, req, err := createClientRequest(targetURL, action, t.httpTransport, opts.AuthOpts)
+	client, req, err := createClientRequest(targetURL, action, t.httpTransport, authOpts)
 	if err != nil {
 		return nil, err
 	}
 
-	stream := newManagedHttpStream(t, req, client)
+	stream := newManagedHttpStream(t, req, client, authOpts)
 	if req.Method == "POST" {
 		stream.recvReply.Add(1)
 		stream.sendRequestBackground()
 	}
 
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		if len(via) >= 3 {
-			return fmt.Errorf("too many redirects")
+		if err := pool.DefaultRedirectPolicy.CheckCount(req.URL, len(via)-1); err != nil {
+			return err
 		}
 
 		// golang will change POST to GET in case of redirects.
@@ -190,6 +211,10 @@ func (t *httpSmartSubtransport) Action(transportOptionsURL string, action git2go
 			return http.ErrUseLastResponse
 		}
 
+		keepCredentials := applyRedirectCredentials(req, via[0].URL, stream.authOpts)
+		t.logger.V(logger.DebugLevel).Info("following redirect",
+			"host", via[0].URL.Host, "location", req.URL.Host, "keepCredentials", keepCredentials)
+
 		// Some Git servers (i.e. Gitlab) only support redirection on the GET operations.
 		// Therefore, on the initial GET operation we update the target URL to include the
 		// new target, so the subsequent actions include the correct target URL.
@@ -202,7 +227,10 @@ func (t *httpSmartSubtransport) Action(transportOptionsURL string, action git2go
 						opts = &TransportOptions{}
 					}
 
+					// The new target does not embed the credentials the
+					// original URL may have had.
 					opts.TargetURL = trimActionSuffix(newURL.String())
+					opts.AuthOpts = authOpts
 					AddTransportOptions(transportOptionsURL, *opts)
 
 					// show as info, as this should be visible regardless of the
@@ -277,29 +305,136 @@ func createClientRequest(targetURL string, action git2go.SmartServiceAction,
 		return nil, nil, err
 	}
 
+	// Offer HTTP/2 through ALPN, servers which do not support it
+	// negotiate HTTP/1.1 instead.
+	tlsConfig := &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
 	// Apply authentication and TLS settings to the HTTP transport.
 	if authOpts != nil {
-		if authOpts.Username != "" && authOpts.Password != "" {
-			req.SetBasicAuth(authOpts.Username, authOpts.Password)
-		}
+		setCredentials(req, authOpts)
 		if len(authOpts.CAFile) > 0 {
-			certPool := x509.NewCertPool()
-			if ok := certPool.AppendCertsFromPEM(authOpts.CAFile); !ok {
-				return nil, nil, fmt.Errorf("PEM CA bundle could not be appended to x509 certificate pool")
+			certPool, err := rootCAs(authOpts.CAFile)
+			if err != nil {
+				return nil, nil, err
 			}
-			t.TLSClientConfig = &tls.Config{
-				RootCAs: certPool,
+			tlsConfig.RootCAs = certPool
+		}
+		if len(authOpts.ClientCert) > 0 {
+			cert, err := tls.X509KeyPair(authOpts.ClientCert, authOpts.ClientKey)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load client certificate: %w", err)
 			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
 		}
 	}
+	t.TLSClientConfig = tlsConfig
 
-	req.Header.Set("User-Agent", "git/2.0 (flux-libgit2)")
+	userAgent := defaultUserAgent
+	if authOpts != nil && authOpts.UserAgent != "" {
+		userAgent += " " + authOpts.UserAgent
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
 	if t.Proxy != nil {
-		t.ProxyConnectHeader.Set("User-Agent", "git/2.0 (flux-libgit2)")
+		t.ProxyConnectHeader.Set("User-Agent", userAgent)
 	}
 	return client, req, nil
 }
 
+// systemCertPool returns the certificate pool of the system, it can be
+// replaced in tests.
+var systemCertPool = x509.SystemCertPool
+
+// rootCAs returns the certificate pool of the system with the certificates
+// of the PEM encoded CA bundle added to it, so that a custom CA does not
+// replace the CAs trusted by the system.
+func rootCAs(caBundle []byte) (*x509.CertPool, error) {
+	certPool, err := systemCertPool()
+	if err != nil || certPool == nil {
+		certPool = x509.NewCertPool()
+	}
+	if ok := certPool.AppendCertsFromPEM(caBundle); !ok {
+		return nil, fmt.Errorf("PEM CA bundle could not be appended to x509 certificate pool")
+	}
+	return certPool, nil
+}
+
+// urlCredentials returns the target URL without the user information it
+// may embed, and the AuthOptions to use for it. If the AuthOptions do not
+// provide a username or password, the credentials of the user information
+// are set on a copy of them. Credentials of the AuthOptions take precedence
+// over the ones embedded in the URL.
+func urlCredentials(targetURL string, authOpts *git.AuthOptions) (string, *git.AuthOptions, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		// The error of url.Parse contains the URL, including its
+		// credentials.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", nil, fmt.Errorf("invalid repository URL: %w", err)
+	}
+	if u.User == nil {
+		return targetURL, authOpts, nil
+	}
+
+	username := u.User.Username()
+	password, _ := u.User.Password()
+	u.User = nil
+	if authOpts != nil && (authOpts.Username != "" || authOpts.Password != "") {
+		return u.String(), authOpts, nil
+	}
+
+	var opts git.AuthOptions
+	if authOpts != nil {
+		opts = *authOpts
+	}
+	opts.Username, opts.Password = username, password
+	return u.String(), &opts, nil
+}
+
+// setCredentials sets the headers and basic auth credentials of the
+// AuthOptions on the request.
+func setCredentials(req *http.Request, authOpts *git.AuthOptions) {
+	for k, v := range authOpts.Headers {
+		// Headers required by the protocol take precedence.
+		if req.Header.Get(k) != "" {
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	if authOpts.Username != "" && authOpts.Password != "" {
+		req.SetBasicAuth(authOpts.Username, authOpts.Password)
+	}
+}
+
+// applyRedirectCredentials sets the credentials of the AuthOptions on the
+// request redirected from the given URL if git.AuthOptions.RedirectKeepsCredentials
+// allows it, and removes them otherwise. The http.Client only removes the
+// Authorization header on redirects to a different domain, which does not
+// cover the other AuthOptions.Headers nor the trusted hosts. It returns if
+// the credentials were kept.
+func applyRedirectCredentials(req *http.Request, from *url.URL, authOpts *git.AuthOptions) bool {
+	if authOpts == nil {
+		return false
+	}
+	if authOpts.RedirectKeepsCredentials(from, req.URL) {
+		setCredentials(req, authOpts)
+		return true
+	}
+	for k, v := range authOpts.Headers {
+		if req.Header.Get(k) == v {
+			req.Header.Del(k)
+		}
+	}
+	req.Header.Del("Authorization")
+	return false
+}
+
 func (t *httpSmartSubtransport) Close() error {
 	t.logger.V(logger.TraceLevel).Info("httpSmartSubtransport.Close()")
 	return nil
@@ -307,6 +442,7 @@ func (t *httpSmartSubtransport) Close() error {
 
 func (t *httpSmartSubtransport) Free() {
 	t.logger.V(logger.TraceLevel).Info("httpSmartSubtransport.Free()")
+	t.log.finish(t.logger)
 
 	if t.httpTransport != nil {
 		t.logger.V(logger.TraceLevel).Info("release http transport back to pool")
@@ -327,16 +463,19 @@ type httpSmartSubtransportStream struct {
 	recvReply   sync.WaitGroup
 	httpError   error
 	m           sync.RWMutex
+	authOpts    *git.AuthOptions
 }
 
-func newManagedHttpStream(owner *httpSmartSubtransport, req *http.Request, client *http.Client) *httpSmartSubtransportStream {
+func newManagedHttpStream(owner *httpSmartSubtransport, req *http.Request, client *http.Client,
+	authOpts *git.AuthOptions) *httpSmartSubtransportStream {
 	r, w := io.Pipe()
 	return &httpSmartSubtransportStream{
-		owner:  owner,
-		client: client,
-		req:    req,
-		reader: r,
-		writer: w,
+		owner:    owner,
+		client:   client,
+		req:      req,
+		reader:   r,
+		writer:   w,
+		authOpts: authOpts,
 	}
 }
 
@@ -405,13 +544,14 @@ func (self *httpSmartSubtransportStream) sendRequestBackground() {
 	self.sentRequest = true
 }
 
-func (self *httpSmartSubtransportStream) sendRequest() error {
+func (self *httpSmartSubtransportStream) sendRequest() (err error) {
 	defer self.recvReply.Done()
+	defer func() { self.owner.log.record(err) }()
 	self.resp = nil
 
 	var resp *http.Response
-	var err error
 	var content []byte
+	var redirects int
 
 	for {
 		req := &http.Request{
@@ -432,10 +572,14 @@ func (self *httpSmartSubtransportStream) sendRequest() error {
 		}
 
 		self.owner.logger.V(logger.TraceLevel).Info("new request", "method", req.Method, "postUrl", req.URL)
+		start := time.Now()
 		resp, err = self.client.Do(req)
 		if err != nil {
+			self.owner.logger.V(logger.DebugLevel).Info("request failed",
+				"method", req.Method, "host", req.URL.Host, "duration", time.Since(start), "error", err.Error())
 			return err
 		}
+		self.logResponse(req, resp, time.Since(start))
 
 		// GET requests will be automatically redirected.
 		// POST require the new destination, and also the body content.
@@ -448,11 +592,26 @@ func (self *httpSmartSubtransportStream) sendRequest() error {
 				return err
 			}
 
-			// The next try will go against the new destination
-			self.req.URL, err = resp.Location()
+			// The next try will go against the new destination, with the
+			// credentials only if they may be re-sent to it.
+			location, err := resp.Location()
 			if err != nil {
 				return err
 			}
+			// The redirect is followed manually, and is not subject to
+			// the redirect policy of the client.
+			if err := pool.DefaultRedirectPolicy.CheckCount(location, redirects); err != nil {
+				return err
+			}
+			redirects++
+			if err := pool.DefaultRedirectPolicy.Check(req.Context(), location); err != nil {
+				return err
+			}
+			redirect := &http.Request{URL: location, Header: self.req.Header.Clone()}
+			keepCredentials := applyRedirectCredentials(redirect, self.req.URL, self.authOpts)
+			self.owner.logger.V(logger.DebugLevel).Info("retrying request after redirect",
+				"method", req.Method, "host", self.req.URL.Host, "location", location.Host, "keepCredentials", keepCredentials)
+			self.req.URL, self.req.Header = redirect.URL, redirect.Header
 
 			continue
 		}
@@ -476,3 +635,21 @@ func (self *httpSmartSubtransportStream) sendRequest() error {
 	self.sentRequest = true
 	return nil
 }
+
+// logResponse logs the status and the negotiated protocols of the response
+// to the given request at debug level.
+func (self *httpSmartSubtransportStream) logResponse(req *http.Request, resp *http.Response, duration time.Duration) {
+	keysAndValues := []interface{}{
+		"method", req.Method,
+		"host", req.URL.Host,
+		"status", resp.StatusCode,
+		"proto", resp.Proto,
+		"duration", duration,
+	}
+	if resp.TLS != nil {
+		keysAndValues = append(keysAndValues,
+			"tlsVersion", tlsVersionName(resp.TLS.Version),
+			"cipherSuite", tls.CipherSuiteName(resp.TLS.CipherSuite))
+	}
+	self.owner.logger.V(logger.DebugLevel).Info("received response", keysAndValues...)
+}
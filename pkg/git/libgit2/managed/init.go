@@ -17,8 +17,12 @@ limitations under the License.
 package managed
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"time"
+
+	git2go "github.com/libgit2/git2go/v33"
 )
 
 var (
@@ -36,6 +40,11 @@ var (
 	fullHttpClientTimeOut time.Duration = 10 * time.Minute
 
 	enabled bool
+
+	// registered holds the protocols for which a managed smart subtransport
+	// has been registered.
+	registered   = map[string]bool{}
+	registeredMu sync.RWMutex
 )
 
 // Enabled defines whether the use of Managed Transport is enabled which
@@ -73,3 +82,73 @@ func InitManagedTransport() error {
 
 	return err
 }
+
+// Ready returns an error if the managed transports are not ready for use,
+// i.e. InitManagedTransport was not called successfully, not all HTTP(S)
+// and SSH smart subtransports are registered, or the linked libgit2 was
+// built without thread support. The error contains the version and the
+// features of the linked libgit2, to aid diagnostics.
+func Ready() error {
+	registeredMu.RLock()
+	var missing []string
+	for _, p := range append(append([]string{}, httpProtocols...), sshProtocols...) {
+		if !registered[p] {
+			missing = append(missing, p)
+		}
+	}
+	registeredMu.RUnlock()
+
+	switch {
+	case !Enabled():
+		return fmt.Errorf("managed transport is not initialized (%s)", BuildInfo())
+	case len(missing) > 0:
+		return fmt.Errorf("managed transport is not registered for protocol(s) %s (%s)",
+			strings.Join(missing, ", "), BuildInfo())
+	case !BuildInfo().Threads:
+		return fmt.Errorf("libgit2 is built without thread support (%s)", BuildInfo())
+	}
+	return nil
+}
+
+// markRegistered records a managed smart subtransport was registered for the
+// given protocol.
+func markRegistered(protocol string) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registered[protocol] = true
+}
+
+// LibGit2BuildInfo describes the version and the build features of the linked
+// libgit2.
+type LibGit2BuildInfo struct {
+	// Version of libgit2, e.g. "1.3.1".
+	Version string
+	// Threads reports if libgit2 is built with thread support.
+	Threads bool
+	// HTTPS reports if libgit2 is built with a TLS backend.
+	HTTPS bool
+	// SSH reports if libgit2 is built with an SSH backend.
+	SSH bool
+	// NSec reports if libgit2 is built with nanosecond precision file
+	// modification times.
+	NSec bool
+}
+
+// String returns a description of the LibGit2BuildInfo.
+func (i LibGit2BuildInfo) String() string {
+	return fmt.Sprintf("libgit2 %s, threads: %t, https: %t, ssh: %t", i.Version, i.Threads, i.HTTPS, i.SSH)
+}
+
+// BuildInfo returns the LibGit2BuildInfo of the linked libgit2, derived
+// from git_libgit2_version and git2go.Features.
+func BuildInfo() LibGit2BuildInfo {
+	major, minor, rev := libgit2Version()
+	features := git2go.Features()
+	return LibGit2BuildInfo{
+		Version: fmt.Sprintf("%d.%d.%d", major, minor, rev),
+		Threads: features&git2go.FeatureThreads != 0,
+		HTTPS:   features&git2go.FeatureHTTPS != 0,
+		SSH:     features&git2go.FeatureSSH != 0,
+		NSec:    features&git2go.FeatureNSec != 0,
+	}
+}
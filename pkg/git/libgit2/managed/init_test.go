@@ -0,0 +1,45 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestReady(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(InitManagedTransport()).To(Succeed())
+	g.Expect(Ready()).To(Succeed())
+
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	for _, p := range append(append([]string{}, httpProtocols...), sshProtocols...) {
+		g.Expect(registered).To(HaveKeyWithValue(p, true))
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	g := NewWithT(t)
+
+	info := BuildInfo()
+	g.Expect(info.Version).ToNot(BeEmpty())
+	g.Expect(info.Version).To(MatchRegexp(`^\d+\.\d+\.\d+$`))
+	g.Expect(info.String()).To(HavePrefix("libgit2 " + info.Version + ","))
+}
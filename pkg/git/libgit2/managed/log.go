@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// transportLog records the operations of a managed smart subtransport, so
+// that their result can be logged once the subtransport is freed.
+type transportLog struct {
+	mu         sync.Mutex
+	start      time.Time
+	operations int
+	err        error
+}
+
+// begin records the start of the first operation.
+func (l *transportLog) begin() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.start.IsZero() {
+		l.start = time.Now()
+	}
+}
+
+// record records the completion of an operation, with the error it
+// failed with, if any.
+func (l *transportLog) record(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.operations++
+	if err != nil {
+		l.err = err
+	}
+}
+
+// finish logs the result of the recorded operations to the given logger at
+// info level, and resets the record. It is a no-op if no operation was
+// started.
+func (l *transportLog) finish(log logr.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.start.IsZero() {
+		return
+	}
+
+	keysAndValues := []interface{}{"duration", time.Since(l.start), "operations", l.operations}
+	if l.err != nil {
+		log.Error(l.err, "managed transport finished with error", keysAndValues...)
+	} else {
+		log.Info("managed transport finished", keysAndValues...)
+	}
+	l.start, l.operations, l.err = time.Time{}, 0, nil
+}
+
+// tlsVersionName returns the name of the given TLS version, as
+// tls.VersionName is not available in the Go version this module targets.
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", version)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/fluxcd/pkg/runtime/logger"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+)
+
+// logEntry is a log message recorded by a recordingSink.
+type logEntry struct {
+	level         int
+	msg           string
+	keysAndValues map[string]interface{}
+}
+
+// recordingSink is a logr.LogSink recording the messages logged at all
+// levels.
+type recordingSink struct {
+	mu            *sync.Mutex
+	entries       *[]logEntry
+	keysAndValues []interface{}
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{mu: &sync.Mutex{}, entries: &[]logEntry{}}
+}
+
+func (s *recordingSink) Init(logr.RuntimeInfo) {}
+
+func (s *recordingSink) Enabled(int) bool { return true }
+
+func (s *recordingSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.record(level, msg, keysAndValues)
+}
+
+func (s *recordingSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.record(0, msg, append(keysAndValues, "error", err.Error()))
+}
+
+func (s *recordingSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &recordingSink{
+		mu:            s.mu,
+		entries:       s.entries,
+		keysAndValues: append(append([]interface{}{}, s.keysAndValues...), keysAndValues...),
+	}
+}
+
+func (s *recordingSink) WithName(string) logr.LogSink { return s }
+
+func (s *recordingSink) record(level int, msg string, keysAndValues []interface{}) {
+	kv := map[string]interface{}{}
+	all := append(append([]interface{}{}, s.keysAndValues...), keysAndValues...)
+	for i := 0; i+1 < len(all); i += 2 {
+		kv[fmt.Sprint(all[i])] = all[i+1]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.entries = append(*s.entries, logEntry{level: level, msg: msg, keysAndValues: kv})
+}
+
+// find returns the first recorded entry with the given message.
+func (s *recordingSink) find(msg string) *logEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range *s.entries {
+		if e.msg == msg {
+			return &e
+		}
+	}
+	return nil
+}
+
+func TestTransportLog(t *testing.T) {
+	g := NewWithT(t)
+
+	sink := newRecordingSink()
+	log := logr.New(sink).WithValues("transportType", "ssh")
+
+	var l transportLog
+	l.finish(log)
+	g.Expect(*sink.entries).To(BeEmpty())
+
+	l.begin()
+	l.record(nil)
+	l.record(nil)
+	l.finish(log)
+	result := sink.find("managed transport finished")
+	g.Expect(result).ToNot(BeNil())
+	g.Expect(result.level).To(Equal(logger.InfoLevel))
+	g.Expect(result.keysAndValues).To(HaveKeyWithValue("transportType", "ssh"))
+	g.Expect(result.keysAndValues).To(HaveKeyWithValue("operations", 2))
+	g.Expect(result.keysAndValues).To(HaveKey("duration"))
+
+	l.begin()
+	l.record(errors.New("connection refused"))
+	l.finish(log)
+	result = sink.find("managed transport finished with error")
+	g.Expect(result).ToNot(BeNil())
+	g.Expect(result.keysAndValues).To(HaveKeyWithValue("operations", 1))
+	g.Expect(result.keysAndValues).To(HaveKeyWithValue("error", "connection refused"))
+}
+
+func Test_tlsVersionName(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(tlsVersionName(0x0303)).To(Equal("TLS 1.2"))
+	g.Expect(tlsVersionName(0x0304)).To(Equal("TLS 1.3"))
+	g.Expect(tlsVersionName(0x1234)).To(Equal(fmt.Sprintf("0x%04X", 0x1234)))
+}
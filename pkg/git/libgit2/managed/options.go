@@ -31,6 +31,11 @@ type TransportOptions struct {
 	AuthOpts     *git.AuthOptions
 	ProxyOptions *git2go.ProxyOptions
 	Context      context.Context
+
+	// InsecureSkipHostKeyVerification disables the verification of the
+	// host key presented by SSH servers. It must only be set by tests, a
+	// warning is logged whenever it is used.
+	InsecureSkipHostKeyVerification bool
 }
 
 var (
@@ -73,7 +78,8 @@ func getTransportOptions(transportOptsURL string) (*TransportOptions, bool) {
 //
 // Given that TransportOptions can allow for the target URL to be overriden
 // this returns the same input if Managed Transport is disabled or if no TargetURL
-// is set on TransportOptions.
+// is set on TransportOptions. Credentials embedded in the TargetURL are
+// removed from the returned URL.
 func EffectiveURL(transporOptsURL string) string {
 	if !Enabled() {
 		return transporOptsURL
@@ -81,6 +87,9 @@ func EffectiveURL(transporOptsURL string) string {
 
 	if opts, found := getTransportOptions(transporOptsURL); found {
 		if opts.TargetURL != "" {
+			if u, _, err := urlCredentials(opts.TargetURL, nil); err == nil {
+				return u
+			}
 			return opts.TargetURL
 		}
 	}
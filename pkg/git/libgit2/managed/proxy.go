@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	git2go "github.com/libgit2/git2go/v33"
+	"golang.org/x/net/proxy"
+)
+
+// sshProxyDialer returns the dialer for the connections of the managed SSH
+// transport with the given ProxyOptions. Without ProxyOptions, or for
+// git2go.ProxyTypeAuto, the proxy is configured using the ALL_PROXY and
+// NO_PROXY environment variables. For git2go.ProxyTypeSpecified, the
+// connections are tunneled through the SOCKS5 ("socks5://" and "socks5h://")
+// or HTTP CONNECT ("http://" and "https://") proxy of the URL, with the
+// credentials of its user information.
+//
+// The proxy only relays the connection to the SSH server, the host key of
+// which is still verified against the known hosts.
+func sshProxyDialer(opts *git2go.ProxyOptions) (proxy.ContextDialer, error) {
+	if opts == nil {
+		return proxyFromEnvironment{}, nil
+	}
+
+	switch opts.Type {
+	case git2go.ProxyTypeNone:
+		return &net.Dialer{}, nil
+	case git2go.ProxyTypeAuto:
+		return proxyFromEnvironment{}, nil
+	case git2go.ProxyTypeSpecified:
+		u, err := url.Parse(opts.Url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch u.Scheme {
+		case "socks5", "socks5h":
+			var auth *proxy.Auth
+			if u.User != nil {
+				password, _ := u.User.Password()
+				auth = &proxy.Auth{User: u.User.Username(), Password: password}
+			}
+			d, err := proxy.SOCKS5("tcp", u.Host, auth, &net.Dialer{})
+			if err != nil {
+				return nil, err
+			}
+			return d.(proxy.ContextDialer), nil
+		case "http", "https":
+			return &connectDialer{proxyURL: u, forward: &net.Dialer{}}, nil
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme '%s'", u.Scheme)
+		}
+	default:
+		return nil, fmt.Errorf("unknown proxy type %d", opts.Type)
+	}
+}
+
+// proxyFromEnvironment dials connections through the proxy configured with
+// the ALL_PROXY and NO_PROXY environment variables.
+type proxyFromEnvironment struct{}
+
+func (proxyFromEnvironment) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return proxy.Dial(ctx, network, addr)
+}
+
+// connectDialer dials connections through an HTTP proxy, using the CONNECT
+// method to establish a tunnel to the address.
+type connectDialer struct {
+	proxyURL *url.URL
+	forward  proxy.ContextDialer
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, addr string) (_ net.Conn, err error) {
+	proxyAddr := d.proxyURL.Host
+	if d.proxyURL.Port() == "" {
+		port := "80"
+		if d.proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(d.proxyURL.Hostname(), port)
+	}
+
+	conn, err := d.forward.DialContext(ctx, network, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+	if d.proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := d.proxyURL.User; u != nil {
+		password, _ := u.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("failed to write CONNECT request to proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CONNECT response from proxy: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy responded to CONNECT with %s", resp.Status)
+	}
+
+	// The server may have sent data right after the tunnel was
+	// established, e.g. the SSH version banner, which has then already
+	// been read into the buffer.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn reading from a buffer before reading from the
+// connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/elazarl/goproxy"
+	git2go "github.com/libgit2/git2go/v33"
+	. "github.com/onsi/gomega"
+)
+
+func Test_sshProxyDialer(t *testing.T) {
+	// The target mimics an SSH server, which sends its version banner as
+	// soon as the connection is established.
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("SSH-2.0-stub\r\n"))
+			conn.Close()
+		}
+	}()
+
+	socksAddr, socksProxied := startSOCKS5Proxy(t)
+
+	var connectProxied int32
+	httpProxy := goproxy.NewProxyHttpServer()
+	httpProxy.OnRequest().HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+		atomic.AddInt32(&connectProxied, 1)
+		return goproxy.OkConnect, host
+	})
+	httpServer := httptest.NewServer(httpProxy)
+	defer httpServer.Close()
+
+	tests := []struct {
+		name    string
+		opts    *git2go.ProxyOptions
+		proxied *int32
+		wantErr string
+	}{
+		{
+			name: "no proxy",
+			opts: &git2go.ProxyOptions{Type: git2go.ProxyTypeNone},
+		},
+		{
+			name:    "SOCKS5 proxy",
+			opts:    &git2go.ProxyOptions{Type: git2go.ProxyTypeSpecified, Url: "socks5://" + socksAddr},
+			proxied: socksProxied,
+		},
+		{
+			name:    "HTTP CONNECT proxy",
+			opts:    &git2go.ProxyOptions{Type: git2go.ProxyTypeSpecified, Url: httpServer.URL},
+			proxied: &connectProxied,
+		},
+		{
+			name:    "unsupported proxy scheme",
+			opts:    &git2go.ProxyOptions{Type: git2go.ProxyTypeSpecified, Url: "ftp://" + socksAddr},
+			wantErr: "unsupported proxy scheme 'ftp'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dialer, err := sshProxyDialer(tt.opts)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var before int32
+			if tt.proxied != nil {
+				before = atomic.LoadInt32(tt.proxied)
+			}
+
+			conn, err := dialer.DialContext(context.TODO(), "tcp", target.Addr().String())
+			g.Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			banner, err := bufio.NewReader(conn).ReadString('\n')
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(banner).To(Equal("SSH-2.0-stub\r\n"))
+
+			if tt.proxied != nil {
+				g.Expect(atomic.LoadInt32(tt.proxied)).To(Equal(before + 1))
+			}
+		})
+	}
+}
+
+// startSOCKS5Proxy starts a SOCKS5 proxy supporting the CONNECT command
+// without authentication. It returns the address of the proxy, and the
+// counter of the connections it proxied.
+func startSOCKS5Proxy(t *testing.T) (string, *int32) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start SOCKS5 proxy: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var proxied int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn, &proxied)
+		}
+	}()
+	return l.Addr().String(), &proxied
+}
+
+func serveSOCKS5(conn net.Conn, proxied *int32) {
+	defer conn.Close()
+
+	// Greeting: version, number of methods and the methods.
+	buf := make([]byte, 256)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil || buf[0] != 5 {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+	// No authentication required.
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return
+	}
+
+	// Request: version, command, reserved and the address type.
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil || buf[1] != 1 {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:net.IPv4len]); err != nil {
+			return
+		}
+		host = net.IP(buf[:net.IPv4len]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return
+		}
+		host = string(buf[:n])
+	case 4:
+		if _, err := io.ReadFull(conn, buf[:net.IPv6len]); err != nil {
+			return
+		}
+		host = net.IP(buf[:net.IPv6len]).String()
+	default:
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		// Host unreachable.
+		_, _ = conn.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	atomic.AddInt32(proxied, 1)
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+
+	go func() {
+		_, _ = io.Copy(target, conn)
+		target.Close()
+	}()
+	_, _ = io.Copy(conn, target)
+}
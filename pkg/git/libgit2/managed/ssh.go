@@ -58,6 +58,7 @@ import (
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/net/proxy"
 	ctrl "sigs.k8s.io/controller-runtime"
 
@@ -67,15 +68,20 @@ import (
 	git2go "github.com/libgit2/git2go/v33"
 )
 
+// sshProtocols are the protocols for which the managed SSH transport is
+// registered.
+var sshProtocols = []string{"ssh", "ssh+git", "git+ssh"}
+
 // registerManagedSSH registers a Go-native implementation of
 // SSH transport that doesn't rely on any lower-level libraries
 // such as libssh2.
 func registerManagedSSH() error {
-	for _, protocol := range []string{"ssh", "ssh+git", "git+ssh"} {
+	for _, protocol := range sshProtocols {
 		_, err := git2go.NewRegisteredSmartTransport(protocol, false, sshSmartSubtransportFactory)
 		if err != nil {
 			return fmt.Errorf("failed to register transport for %q: %v", protocol, err)
 		}
+		markRegistered(protocol)
 	}
 	return nil
 }
@@ -107,6 +113,9 @@ type sshSmartSubtransport struct {
 	// reused across all log calls.
 	// If context is not set, this defaults to logr.Discard().
 	logger logr.Logger
+	// log records the actions of the subtransport, to log their result
+	// once it is freed.
+	log transportLog
 
 	lastAction git2go.SmartServiceAction
 	stdin      io.WriteCloser
@@ -120,10 +129,13 @@ type sshSmartSubtransport struct {
 	connected     bool
 }
 
-func (t *sshSmartSubtransport) Action(transportOptionsURL string, action git2go.SmartServiceAction) (git2go.SmartSubtransportStream, error) {
+func (t *sshSmartSubtransport) Action(transportOptionsURL string, action git2go.SmartServiceAction) (_ git2go.SmartSubtransportStream, err error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
+	t.log.begin()
+	defer func() { t.log.record(err) }()
+
 	opts, found := getTransportOptions(transportOptionsURL)
 	if !found {
 		return nil, fmt.Errorf("could not find transport options for object: %s", transportOptionsURL)
@@ -185,14 +197,18 @@ func (t *sshSmartSubtransport) Action(transportOptionsURL string, action git2go.
 		}
 	})
 
-	sshConfig, err := createClientConfig(opts.AuthOpts)
+	sshConfig, agentConn, err := createClientConfig(opts.AuthOpts)
 	if err != nil {
 		return nil, err
 	}
+	if agentConn != nil {
+		// The agent signs the authentication request during the handshake,
+		// after which the connection is no longer needed.
+		defer agentConn.Close()
+	}
 
-	sshConfig.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-		keyHash := sha256.Sum256(key.Marshal())
-		return CheckKnownHost(hostname, opts.AuthOpts.KnownHosts, keyHash[:])
+	if sshConfig.HostKeyCallback, err = hostKeyCallback(opts, t.logger); err != nil {
+		return nil, err
 	}
 
 	if t.connected {
@@ -201,7 +217,12 @@ func (t *sshSmartSubtransport) Action(transportOptionsURL string, action git2go.
 		_ = t.Close()
 	}
 
-	err = t.createConn(addr, sshConfig)
+	dialer, err := sshProxyDialer(opts.ProxyOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.createConn(addr, sshConfig, dialer)
 	if err != nil {
 		return nil, err
 	}
@@ -279,19 +300,59 @@ func (t *sshSmartSubtransport) Action(transportOptionsURL string, action git2go.
 	return t.currentStream, nil
 }
 
-func (t *sshSmartSubtransport) createConn(addr string, sshConfig *ssh.ClientConfig) error {
+// hostKeyCallback returns the ssh.HostKeyCallback verifying the host key
+// presented by the server against the known hosts of the given
+// TransportOptions. If InsecureSkipHostKeyVerification is set, it logs a
+// warning to the given logger and returns a callback accepting any host key.
+func hostKeyCallback(opts *TransportOptions, log logr.Logger) (ssh.HostKeyCallback, error) {
+	if opts.InsecureSkipHostKeyVerification {
+		log.Info("WARNING: SSH host key verification is disabled, this is insecure and must only be used in tests")
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	switch opts.AuthOpts.KnownHostsStrictness {
+	case git.KnownHostsStrict, "":
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			keyHash := sha256.Sum256(key.Marshal())
+			if err := CheckKnownHost(hostname, opts.AuthOpts.KnownHosts, keyHash[:]); err != nil {
+				return &git.HostKeyMismatchError{Host: hostname, Err: err}
+			}
+			return nil
+		}, nil
+	default:
+		return opts.AuthOpts.HostKeyCallback()
+	}
+}
+
+func (t *sshSmartSubtransport) createConn(addr string, sshConfig *ssh.ClientConfig, dialer proxy.ContextDialer) error {
 	ctx, cancel := context.WithTimeout(context.TODO(), sshConnectionTimeOut)
 	defer cancel()
 
+	// Record the algorithm of the host key presented by the server, as the
+	// negotiated algorithms are not exposed by the ssh.Conn.
+	var hostKeyAlgorithm string
+	if callback := sshConfig.HostKeyCallback; callback != nil {
+		sshConfig.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKeyAlgorithm = key.Type()
+			return callback(hostname, remote, key)
+		}
+	}
+
 	t.logger.V(logger.TraceLevel).Info("dial connection")
-	conn, err := proxy.Dial(ctx, "tcp", addr)
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
+		t.logger.V(logger.DebugLevel).Info("ssh connection failed", "duration", time.Since(start), "error", err.Error())
 		return err
 	}
 	c, chans, reqs, err := ssh.NewClientConn(conn, addr, sshConfig)
 	if err != nil {
+		t.logger.V(logger.DebugLevel).Info("ssh handshake failed",
+			"duration", time.Since(start), "hostKeyAlgorithm", hostKeyAlgorithm, "error", err.Error())
 		return err
 	}
+	t.logger.V(logger.DebugLevel).Info("ssh connection established",
+		"duration", time.Since(start), "serverVersion", string(c.ServerVersion()), "hostKeyAlgorithm", hostKeyAlgorithm)
 
 	t.connected = true
 	t.client = ssh.NewClient(c, chans, reqs)
@@ -336,6 +397,7 @@ func (t *sshSmartSubtransport) Close() error {
 }
 
 func (t *sshSmartSubtransport) Free() {
+	t.log.finish(t.logger)
 }
 
 type sshSmartSubtransportStream struct {
@@ -353,25 +415,41 @@ func (stream *sshSmartSubtransportStream) Write(buf []byte) (int, error) {
 func (stream *sshSmartSubtransportStream) Free() {
 }
 
-func createClientConfig(authOpts *git.AuthOptions) (*ssh.ClientConfig, error) {
+// createClientConfig returns the ssh.ClientConfig for the given
+// git.AuthOptions. If the keys of an SSH agent are used to authenticate, it
+// also returns the connection to the agent, which must be closed by the
+// caller once the SSH handshake has completed.
+func createClientConfig(authOpts *git.AuthOptions) (*ssh.ClientConfig, io.Closer, error) {
 	if authOpts == nil {
-		return nil, fmt.Errorf("cannot create ssh client config from nil ssh auth options")
+		return nil, nil, fmt.Errorf("cannot create ssh client config from nil ssh auth options")
 	}
 
-	var signer ssh.Signer
-	var err error
-	if authOpts.Password != "" {
-		signer, err = ssh.ParsePrivateKeyWithPassphrase(authOpts.Identity, []byte(authOpts.Password))
+	var auth ssh.AuthMethod
+	var agentConn io.Closer
+	if len(authOpts.Identity) == 0 && authOpts.UseSSHAgent {
+		conn, err := net.DialTimeout("unix", authOpts.SSHAgentSocketPath(), sshConnectionTimeOut)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to SSH agent: %w", err)
+		}
+		auth = ssh.PublicKeysCallback(agent.NewClient(conn).Signers)
+		agentConn = conn
 	} else {
-		signer, err = ssh.ParsePrivateKey(authOpts.Identity)
-	}
-	if err != nil {
-		return nil, err
+		var signer ssh.Signer
+		var err error
+		if authOpts.Password != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(authOpts.Identity, []byte(authOpts.Password))
+		} else {
+			signer, err = ssh.ParsePrivateKey(authOpts.Identity)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		auth = ssh.PublicKeys(signer)
 	}
 
 	cfg := &ssh.ClientConfig{
 		User:    authOpts.Username,
-		Auth:    []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		Auth:    []ssh.AuthMethod{auth},
 		Timeout: sshConnectionTimeOut,
 	}
 
@@ -382,5 +460,5 @@ func createClientConfig(authOpts *git.AuthOptions) (*ssh.ClientConfig, error) {
 		cfg.HostKeyAlgorithms = git.HostKeyAlgos
 	}
 
-	return cfg, nil
+	return cfg, agentConn, nil
 }
@@ -17,15 +17,24 @@ limitations under the License.
 package managed
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/fluxcd/pkg/ssh"
 	"github.com/fluxcd/source-controller/pkg/git"
+	"github.com/go-logr/logr"
 	. "github.com/onsi/gomega"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 
 	"github.com/fluxcd/pkg/gittestserver"
 	git2go "github.com/libgit2/git2go/v33"
@@ -62,7 +71,7 @@ func TestSSHAction_clientConfig(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			g := NewWithT(t)
-			cfg, err := createClientConfig(tt.authOpts)
+			cfg, _, err := createClientConfig(tt.authOpts)
 			if tt.expectErr != "" {
 				g.Expect(tt.expectErr).To(Equal(err.Error()))
 				return
@@ -74,6 +83,167 @@ func TestSSHAction_clientConfig(t *testing.T) {
 	}
 }
 
+func TestSSHAction_clientConfig_agent(t *testing.T) {
+	g := NewWithT(t)
+
+	// Serve an in-process SSH agent holding the authorized key.
+	_, authorizedKey, err := ed25519.GenerateKey(rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	keyring := agent.NewKeyring()
+	g.Expect(keyring.Add(agent.AddedKey{PrivateKey: authorizedKey})).To(Succeed())
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	agentListener, err := net.Listen("unix", socket)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer agentListener.Close()
+	go func() {
+		for {
+			conn, err := agentListener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	// Serve an SSH server only accepting the authorized key.
+	authorizedSigner, err := gossh.NewSignerFromKey(authorizedKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	hostSigner, err := gossh.NewSignerFromKey(hostKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	serverConfig := &gossh.ServerConfig{
+		PublicKeyCallback: func(_ gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if string(key.Marshal()) == string(authorizedSigner.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized key")
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+	sshListener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer sshListener.Close()
+	go func() {
+		for {
+			conn, err := sshListener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if sconn, _, _, err := gossh.NewServerConn(conn, serverConfig); err == nil {
+					sconn.Close()
+				}
+			}()
+		}
+	}()
+
+	cfg, agentConn, err := createClientConfig(&git.AuthOptions{
+		Username:       "git",
+		UseSSHAgent:    true,
+		SSHAgentSocket: socket,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(agentConn).ToNot(BeNil())
+	defer agentConn.Close()
+	g.Expect(cfg.User).To(Equal("git"))
+	g.Expect(cfg.Auth).To(HaveLen(1))
+	cfg.HostKeyCallback = gossh.FixedHostKey(hostSigner.PublicKey())
+
+	client, err := gossh.Dial("tcp", sshListener.Addr().String(), cfg)
+	g.Expect(err).ToNot(HaveOccurred())
+	client.Close()
+
+	t.Run("agent without authorized key", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(keyring.RemoveAll()).To(Succeed())
+		_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(keyring.Add(agent.AddedKey{PrivateKey: otherKey})).To(Succeed())
+
+		_, err = gossh.Dial("tcp", sshListener.Addr().String(), cfg)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unable to authenticate"))
+	})
+
+	t.Run("agent socket does not exist", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, _, err := createClientConfig(&git.AuthOptions{
+			UseSSHAgent:    true,
+			SSHAgentSocket: filepath.Join(t.TempDir(), "missing.sock"),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("failed to connect to SSH agent"))
+	})
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %s", err)
+	}
+	hostKey, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("could not create public key: %s", err)
+	}
+	remote := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+
+	tests := []struct {
+		name        string
+		opts        *TransportOptions
+		wantErr     bool
+		wantWarning bool
+	}{
+		{
+			name: "verifies the host key by default",
+			opts: &TransportOptions{
+				AuthOpts: &git.AuthOptions{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "skips the verification when explicitly set",
+			opts: &TransportOptions{
+				AuthOpts:                        &git.AuthOptions{},
+				InsecureSkipHostKeyVerification: true,
+			},
+			wantWarning: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			sink := newRecordingSink()
+			callback, err := hostKeyCallback(tt.opts, logr.New(sink))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			err = callback("127.0.0.1:22", remote, hostKey)
+			if tt.wantErr {
+				var mismatch *git.HostKeyMismatchError
+				g.Expect(errors.As(err, &mismatch)).To(BeTrue())
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+
+			var warned bool
+			for _, e := range *sink.entries {
+				if strings.Contains(e.msg, "host key verification is disabled") {
+					warned = true
+				}
+			}
+			g.Expect(warned).To(Equal(tt.wantWarning))
+		})
+	}
+}
+
 func TestSSHManagedTransport_E2E(t *testing.T) {
 	g := NewWithT(t)
 
@@ -132,3 +302,64 @@ func TestSSHManagedTransport_E2E(t *testing.T) {
 	g.Expect(err).ToNot(HaveOccurred())
 	repo.Free()
 }
+
+func TestSSHManagedTransport_SOCKS5Proxy(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	server.KeyDir(filepath.Join(server.Root(), "keys"))
+
+	err = server.ListenSSH()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	go func() {
+		server.StartSSH()
+	}()
+	defer server.StopSSH()
+	InitManagedTransport()
+
+	kp, err := ssh.NewEd25519Generator().Generate()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	repoPath := "test.git"
+	err = server.InitRepo("../../testdata/git/repo", git.DefaultBranch, repoPath)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	u, err := url.Parse(server.SSHAddress())
+	g.Expect(err).NotTo(HaveOccurred())
+	knownhosts, err := ssh.ScanHostKey(u.Host, 5*time.Second, git.HostKeyAlgos, false)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	proxyAddr, proxied := startSOCKS5Proxy(t)
+
+	transportOptsURL := "ssh://git@fake-url-socks5"
+	AddTransportOptions(transportOptsURL, TransportOptions{
+		TargetURL: server.SSHAddress() + "/" + repoPath,
+		AuthOpts: &git.AuthOptions{
+			Username:   "user",
+			Identity:   kp.PrivateKey,
+			KnownHosts: knownhosts,
+		},
+		ProxyOptions: &git2go.ProxyOptions{
+			Type: git2go.ProxyTypeSpecified,
+			Url:  "socks5://" + proxyAddr,
+		},
+	})
+	defer RemoveTransportOptions(transportOptsURL)
+
+	repo, err := git2go.Clone(transportOptsURL, t.TempDir(), &git2go.CloneOptions{
+		FetchOptions: git2go.FetchOptions{
+			RemoteCallbacks: RemoteCallbacks(),
+		},
+		CheckoutOptions: git2go.CheckoutOptions{
+			Strategy: git2go.CheckoutForce,
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	repo.Free()
+
+	g.Expect(atomic.LoadInt32(proxied)).To(BeNumerically(">", 0))
+}
@@ -4,10 +4,13 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net"
+	"strings"
 
 	pkgkh "github.com/fluxcd/pkg/ssh/knownhosts"
 	git2go "github.com/libgit2/git2go/v33"
 	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/fluxcd/source-controller/pkg/git"
 )
 
 // knownHostCallback returns a CertificateCheckCallback that verifies
@@ -31,6 +34,10 @@ func KnownHostsCallback(host string, knownHosts []byte) git2go.CertificateCheckC
 			hostnameWithoutPort = hostname
 		}
 
+		// IPv6 literals may be given with or without brackets.
+		hostWithoutPort = strings.TrimSuffix(strings.TrimPrefix(hostWithoutPort, "["), "]")
+		hostnameWithoutPort = strings.TrimSuffix(strings.TrimPrefix(hostnameWithoutPort, "["), "]")
+
 		if hostnameWithoutPort != hostWithoutPort {
 			return fmt.Errorf("host mismatch: %q %q", hostWithoutPort, hostnameWithoutPort)
 		}
@@ -65,10 +72,14 @@ func CheckKnownHost(host string, knownHosts []byte, fingerprint []byte) error {
 	// given to the callback match. Use the configured host (that
 	// includes the port), and normalize it, so we can check if there
 	// is an entry for the hostname _and_ port.
+	// IPv6 literals on port 22 may be recorded with or without brackets,
+	// check all the names the host may be recorded as.
 	h := knownhosts.Normalize(host)
 	for _, k := range kh {
-		if k.Matches(h, fingerprint) {
-			return nil
+		for _, name := range git.KnownHostsNames(host) {
+			if k.Matches(name, fingerprint) {
+				return nil
+			}
 		}
 	}
 	return fmt.Errorf("no entries in known_hosts match host '%s' with fingerprint '%s'",
@@ -0,0 +1,31 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+/*
+#cgo pkg-config: libgit2
+#include <git2.h>
+*/
+import "C"
+
+// libgit2Version returns the version of the linked libgit2. git2go does
+// not expose git_libgit2_version, so it is called directly.
+func libgit2Version() (major, minor, rev int) {
+	var cMajor, cMinor, cRev C.int
+	C.git_libgit2_version(&cMajor, &cMinor, &cRev)
+	return int(cMajor), int(cMinor), int(cRev)
+}
@@ -40,7 +40,32 @@ func TestCheckoutCommit_CheckoutManaged(t *testing.T) {
 	checkoutCommit(t, true)
 }
 
+func TestCheckoutMergeBase_CheckoutManaged(t *testing.T) {
+	enableManagedTransport()
+	checkoutMergeBase(t, true)
+}
+
+func TestCheckoutBranchAt_CheckoutManaged(t *testing.T) {
+	enableManagedTransport()
+	checkoutBranchAt(t, true)
+}
+
 func TestCheckoutTagSemVer_CheckoutManaged(t *testing.T) {
 	enableManagedTransport()
 	checkoutSemVer(t, true)
 }
+
+func TestCheckoutBranch_Submodules_CheckoutManaged(t *testing.T) {
+	enableManagedTransport()
+	checkoutSubmodules(t, true)
+}
+
+func TestCheckoutBranch_Submodules_SSH_CheckoutManaged(t *testing.T) {
+	enableManagedTransport()
+	checkoutSubmodulesSSH(t)
+}
+
+func TestCheckout_emptyRepository_CheckoutManaged(t *testing.T) {
+	enableManagedTransport()
+	checkoutEmptyRepository(t)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libgit2
+
+import (
+	"context"
+	"fmt"
+
+	git2go "github.com/libgit2/git2go/v33"
+
+	"github.com/fluxcd/pkg/gitutil"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+	"github.com/fluxcd/source-controller/pkg/git/libgit2/managed"
+)
+
+// FetchNotes fetches the notes refs from the remote at url into the
+// repository at path, see git.FetchNotesFunc.
+func FetchNotes(ctx context.Context, path, url string, opts *git.AuthOptions) (err error) {
+	defer recoverPanic(&err)
+
+	remoteCallBacks := RemoteCallbacks(ctx, opts)
+	if managed.Enabled() {
+		if opts == nil || opts.TransportOptionsURL == "" {
+			return fmt.Errorf("can't use managed transport without a valid transport auth id.")
+		}
+		managed.AddTransportOptions(opts.TransportOptionsURL, managed.TransportOptions{
+			TargetURL:    url,
+			AuthOpts:     opts,
+			ProxyOptions: &git2go.ProxyOptions{Type: git2go.ProxyTypeAuto},
+			Context:      ctx,
+		})
+		url = opts.TransportOptionsURL
+		remoteCallBacks = managed.RemoteCallbacks()
+		defer managed.RemoveTransportOptions(opts.TransportOptionsURL)
+	}
+
+	repo, err := git2go.OpenRepository(path)
+	if err != nil {
+		return fmt.Errorf("failed to open Git repository: %w", gitutil.LibGit2Error(err))
+	}
+	defer repo.Free()
+	remote, err := repo.Remotes.CreateAnonymous(url)
+	if err != nil {
+		return fmt.Errorf("unable to create remote for '%s': %w", managed.EffectiveURL(url), gitutil.LibGit2Error(err))
+	}
+	defer remote.Free()
+	if err = remote.Fetch([]string{git.NotesRefSpec}, &git2go.FetchOptions{
+		DownloadTags:    git2go.DownloadTagsNone,
+		RemoteCallbacks: remoteCallBacks,
+	}, ""); err != nil {
+		return fmt.Errorf("unable to fetch notes from '%s': %w", managed.EffectiveURL(url),
+			classifyError(managed.EffectiveURL(url), "", gitutil.LibGit2Error(err)))
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libgit2
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	git2go "github.com/libgit2/git2go/v33"
+
+	"github.com/fluxcd/pkg/gitutil"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+	"github.com/fluxcd/source-controller/pkg/git/libgit2/managed"
+)
+
+// updateSubmodules initializes and updates all the submodules of the given
+// repository, recursing into the submodules of the submodules. Relative
+// submodule URLs are resolved against the given parentURL, and the given
+// git.AuthOptions are used for any remote operation.
+func updateSubmodules(ctx context.Context, repo *git2go.Repository, parentURL string, opts *git.AuthOptions) error {
+	var names []string
+	if err := repo.Submodules.Foreach(func(_ *git2go.Submodule, name string) error {
+		names = append(names, name)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to list submodules: %w", gitutil.LibGit2Error(err))
+	}
+
+	for _, name := range names {
+		if err := updateSubmodule(ctx, repo, name, parentURL, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateSubmodule initializes and updates the submodule with the given name,
+// and then recursively updates its own submodules.
+//
+// When managed transport is enabled, the submodule URL in the repository
+// configuration is replaced with a unique transport options URL, for which
+// the resolved submodule URL and git.AuthOptions are registered for the
+// duration of the update.
+func updateSubmodule(ctx context.Context, repo *git2go.Repository, name, parentURL string, opts *git.AuthOptions) error {
+	sub, err := repo.Submodules.Lookup(name)
+	if err != nil {
+		return fmt.Errorf("unable to lookup submodule '%s': %w", name, gitutil.LibGit2Error(err))
+	}
+	targetURL, err := resolveSubmoduleURL(parentURL, sub.Url())
+	if err != nil {
+		sub.Free()
+		return fmt.Errorf("unable to resolve URL of submodule '%s': %w", name, err)
+	}
+	err = sub.Init(true)
+	sub.Free()
+	if err != nil {
+		return fmt.Errorf("unable to init submodule '%s': %w", name, gitutil.LibGit2Error(err))
+	}
+
+	fetchURL := targetURL
+	remoteCallbacks := RemoteCallbacks(ctx, opts)
+	if managed.Enabled() {
+		if opts == nil || opts.TransportOptionsURL == "" {
+			return fmt.Errorf("can't use managed transport without a valid transport auth id.")
+		}
+		fetchURL = submoduleTransportOptionsURL(opts.TransportOptionsURL, targetURL, name)
+		managed.AddTransportOptions(fetchURL, managed.TransportOptions{
+			TargetURL:    targetURL,
+			AuthOpts:     opts,
+			ProxyOptions: &git2go.ProxyOptions{Type: git2go.ProxyTypeAuto},
+			Context:      ctx,
+		})
+		defer managed.RemoveTransportOptions(fetchURL)
+		remoteCallbacks = managed.RemoteCallbacks()
+	}
+
+	// Overwrite the URL copied to the repository configuration by the init,
+	// as this is what is used to clone the submodule.
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("unable to open repository config: %w", gitutil.LibGit2Error(err))
+	}
+	err = cfg.SetString(fmt.Sprintf("submodule.%s.url", name), fetchURL)
+	cfg.Free()
+	if err != nil {
+		return fmt.Errorf("unable to configure URL of submodule '%s': %w", name, gitutil.LibGit2Error(err))
+	}
+
+	// Lookup the submodule again to ensure the configured URL is used.
+	sub, err = repo.Submodules.Lookup(name)
+	if err != nil {
+		return fmt.Errorf("unable to lookup submodule '%s': %w", name, gitutil.LibGit2Error(err))
+	}
+	defer sub.Free()
+	if err = sub.Update(false, &git2go.SubmoduleUpdateOptions{
+		CheckoutOptions: git2go.CheckoutOptions{
+			Strategy: git2go.CheckoutForce,
+		},
+		FetchOptions: git2go.FetchOptions{
+			DownloadTags:    git2go.DownloadTagsNone,
+			RemoteCallbacks: remoteCallbacks,
+		},
+	}); err != nil {
+		return fmt.Errorf("unable to update submodule '%s' from '%s': %w", name, targetURL, gitutil.LibGit2Error(err))
+	}
+
+	subRepo, err := sub.Open()
+	if err != nil {
+		return fmt.Errorf("unable to open submodule '%s': %w", name, gitutil.LibGit2Error(err))
+	}
+	defer subRepo.Free()
+	return updateSubmodules(ctx, subRepo, targetURL, opts)
+}
+
+// resolveSubmoduleURL resolves the given submodule URL against the URL of the
+// parent repository, which may be an SCP-like address, if it is relative
+// (i.e. starts with "./" or "../"). Absolute URLs are returned as is.
+func resolveSubmoduleURL(parentURL, submoduleURL string) (string, error) {
+	if !strings.HasPrefix(submoduleURL, "./") && !strings.HasPrefix(submoduleURL, "../") {
+		return submoduleURL, nil
+	}
+	if userHost, p, ok := git.SplitSCPURL(parentURL); ok {
+		return userHost + ":" + path.Join(p, submoduleURL), nil
+	}
+	u, err := url.Parse(parentURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(u.Path, submoduleURL)
+	return u.String(), nil
+}
+
+// submoduleTransportOptionsURL returns a unique transport options URL for the
+// submodule with the given name, derived from the transport options URL of
+// the parent repository. The scheme is based on the given submodule target
+// URL, to ensure the right managed transport is invoked.
+func submoduleTransportOptionsURL(parentTransportOptionsURL, targetURL, name string) string {
+	scheme := "http"
+	if strings.HasPrefix(targetURL, "ssh") {
+		scheme = "ssh"
+	}
+	id := parentTransportOptionsURL
+	if i := strings.Index(id, "://"); i >= 0 {
+		id = id[i+3:]
+	}
+	return fmt.Sprintf("%s://%s/submodules/%s", scheme, id, url.PathEscape(name))
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libgit2
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_resolveSubmoduleURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		parentURL string
+		subURL    string
+		want      string
+	}{
+		{
+			name:      "absolute URL",
+			parentURL: "https://example.com/org/repo.git",
+			subURL:    "https://example.com/other/sub.git",
+			want:      "https://example.com/other/sub.git",
+		},
+		{
+			name:      "sibling relative URL",
+			parentURL: "https://example.com/org/repo.git",
+			subURL:    "../sub.git",
+			want:      "https://example.com/org/sub.git",
+		},
+		{
+			name:      "parent relative URL",
+			parentURL: "ssh://git@example.com/org/repo",
+			subURL:    "../../other/sub",
+			want:      "ssh://git@example.com/other/sub",
+		},
+		{
+			name:      "SCP-like parent URL",
+			parentURL: "git@example.com:org/repo.git",
+			subURL:    "../sub.git",
+			want:      "git@example.com:org/sub.git",
+		},
+		{
+			name:      "SCP-like parent URL with absolute path",
+			parentURL: "git@example.com:/srv/git/repo.git",
+			subURL:    "./sub.git",
+			want:      "git@example.com:/srv/git/repo.git/sub.git",
+		},
+		{
+			name:      "nested relative URL",
+			parentURL: "http://example.com/repo",
+			subURL:    "./sub",
+			want:      "http://example.com/repo/sub",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := resolveSubmoduleURL(tt.parentURL, tt.subURL)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_submoduleTransportOptionsURL(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(submoduleTransportOptionsURL("http://repo/uid/1", "https://example.com/sub", "sub")).
+		To(Equal("http://repo/uid/1/submodules/sub"))
+	g.Expect(submoduleTransportOptionsURL("http://repo/uid/1", "ssh://git@example.com/sub", "libs/sub")).
+		To(Equal("ssh://repo/uid/1/submodules/libs%2Fsub"))
+}
@@ -48,11 +48,29 @@ func RemoteCallbacks(ctx context.Context, opts *git.AuthOptions) git2go.RemoteCa
 	return git2go.RemoteCallbacks{}
 }
 
+// transportMetricsKey is the context key of the git.TransportMetrics in
+// which transferProgressCallback records the transfer progress.
+type transportMetricsKey struct{}
+
+// managedRemoteCallbacks constructs the managed.RemoteCallbacks, with the
+// transferProgressCallback for the given context.
+func managedRemoteCallbacks(ctx context.Context) git2go.RemoteCallbacks {
+	callbacks := managed.RemoteCallbacks()
+	callbacks.TransferProgressCallback = transferProgressCallback(ctx)
+	return callbacks
+}
+
 // transferProgressCallback constructs TransferProgressCallbacks which signals
 // libgit2 it should stop the transfer when the given context is closed (due to
-// e.g. a timeout).
+// e.g. a timeout). The progress is recorded in the git.TransportMetrics of the
+// context, if any.
 func transferProgressCallback(ctx context.Context) git2go.TransferProgressCallback {
+	metrics, _ := ctx.Value(transportMetricsKey{}).(*git.TransportMetrics)
 	return func(p git2go.TransferProgress) error {
+		if metrics != nil {
+			metrics.ReceivedObjects = p.ReceivedObjects
+			metrics.ReceivedBytes = p.ReceivedBytes
+		}
 		// Early return if all the objects have been received.
 		if p.ReceivedObjects == p.TotalObjects {
 			return nil
@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libgit2
+
+import (
+	"fmt"
+
+	git2go "github.com/libgit2/git2go/v33"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+// VerifyWorktree verifies the worktree of the repository at path against
+// the tree of the given commit, see git.VerifyWorktree.
+func VerifyWorktree(path string, commit git.Hash) (err error) {
+	defer recoverPanic(&err)
+
+	repo, err := git2go.OpenRepository(path)
+	if err != nil {
+		return fmt.Errorf("failed to open Git repository: %w", err)
+	}
+	defer repo.Free()
+
+	oid, err := git2go.NewOid(commit.String())
+	if err != nil {
+		return fmt.Errorf("invalid commit '%s': %w", commit, err)
+	}
+	cc, err := repo.LookupCommit(oid)
+	if err != nil {
+		return fmt.Errorf("git commit '%s' not found: %w", commit, err)
+	}
+	defer cc.Free()
+	tree, err := cc.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to resolve tree of commit '%s': %w", commit, err)
+	}
+	defer tree.Free()
+
+	var entries []git.WorktreeEntry
+	if err = tree.Walk(func(dir string, entry *git2go.TreeEntry) error {
+		if entry.Type == git2go.ObjectBlob {
+			entries = append(entries, git.WorktreeEntry{
+				Path:    dir + entry.Name,
+				Hash:    entry.Id.String(),
+				Symlink: entry.Filemode == git2go.FilemodeLink,
+			})
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to walk tree of commit '%s': %w", commit, err)
+	}
+	return git.VerifyWorktree(path, commit.String(), entries)
+}
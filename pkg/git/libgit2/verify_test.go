@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libgit2
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git2go "github.com/libgit2/git2go/v33"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/source-controller/pkg/git"
+)
+
+func TestVerifyWorktree(t *testing.T) {
+	repo, err := initBareRepo(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Free()
+
+	if _, err = commitFile(repo, "file", "content", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = commitFile(repo, "nested", "nested", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		mutate    func(dir string) error
+		wantPaths []string
+	}{
+		{
+			name: "unmodified worktree",
+		},
+		{
+			name: "corrupted file",
+			mutate: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "nested"), []byte("corrupted"), 0o600)
+			},
+			wantPaths: []string{"nested"},
+		},
+		{
+			name: "removed file",
+			mutate: func(dir string) error {
+				return os.Remove(filepath.Join(dir, "file"))
+			},
+			wantPaths: []string{"file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tmpDir := t.TempDir()
+			clone, err := git2go.Clone(repo.Path(), tmpDir, &git2go.CloneOptions{
+				CheckoutOptions: git2go.CheckoutOptions{
+					Strategy: git2go.CheckoutForce,
+				},
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			defer clone.Free()
+			cc, err := headCommit(clone)
+			g.Expect(err).ToNot(HaveOccurred())
+			defer cc.Free()
+			hash := git.Hash(cc.Id().String())
+
+			if tt.mutate != nil {
+				g.Expect(tt.mutate(tmpDir)).To(Succeed())
+			}
+
+			err = VerifyWorktree(tmpDir, hash)
+			if tt.wantPaths == nil {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			var mismatchErr *git.WorktreeMismatchError
+			g.Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			g.Expect(mismatchErr.Commit).To(Equal(hash.String()))
+			g.Expect(mismatchErr.Paths).To(Equal(tt.wantPaths))
+		})
+	}
+}
+
+func TestVerifyWorktree_invalidCommit(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, err := git2go.InitRepository(t.TempDir(), false)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer repo.Free()
+
+	err = VerifyWorktree(repo.Workdir(), git.Hash("invalid"))
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultHostLimiter is the process-wide HostLimiter used to throttle
+// outbound Git operations. It does not impose any limits by default, and is
+// expected to be replaced with a configured HostLimiter during startup.
+var DefaultHostLimiter = NewHostLimiter(0, 0)
+
+// HostLimiter limits the number of concurrent operations and the rate at
+// which operations are started per remote host.
+type HostLimiter struct {
+	maxConcurrent int
+	qps           float64
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimit
+}
+
+type hostLimit struct {
+	sem     chan struct{}
+	limiter *rate.Limiter
+}
+
+// NewHostLimiter returns a HostLimiter which allows at most maxConcurrent
+// operations to run at the same time against a single host, and at most qps
+// operations to be started per second against a single host.
+// A value of zero or less disables the respective limit.
+func NewHostLimiter(maxConcurrent int, qps float64) *HostLimiter {
+	return &HostLimiter{
+		maxConcurrent: maxConcurrent,
+		qps:           qps,
+		hosts:         make(map[string]*hostLimit),
+	}
+}
+
+// Acquire blocks until an operation against the host of the given URL is
+// allowed to start, or the context is done. On success, it returns a release
+// function which must be called once the operation has finished. Bundle URLs
+// are not limited.
+func (l *HostLimiter) Acquire(ctx context.Context, u string) (func(), error) {
+	if l == nil || (l.maxConcurrent <= 0 && l.qps <= 0) || IsBundleURL(u) {
+		return func() {}, nil
+	}
+
+	host, err := hostnameFromURL(u)
+	if err != nil {
+		return nil, err
+	}
+	h := l.forHost(host)
+
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for operation slot for host '%s': %w", host, ctx.Err())
+		}
+	}
+	release := func() {
+		if h.sem != nil {
+			<-h.sem
+		}
+	}
+
+	if h.limiter != nil {
+		if err := h.limiter.Wait(ctx); err != nil {
+			release()
+			return nil, fmt.Errorf("waiting for rate limit of host '%s': %w", host, err)
+		}
+	}
+
+	var once sync.Once
+	return func() { once.Do(release) }, nil
+}
+
+// forHost returns the hostLimit for the given host, creating it if it does
+// not exist yet.
+func (l *HostLimiter) forHost(host string) *hostLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostLimit{}
+		if l.maxConcurrent > 0 {
+			h.sem = make(chan struct{}, l.maxConcurrent)
+		}
+		if l.qps > 0 {
+			h.limiter = rate.NewLimiter(rate.Limit(l.qps), 1)
+		}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// LimitCheckoutStrategy returns a CheckoutStrategy which acquires a slot
+// from the given HostLimiter for the URL before delegating the checkout to
+// the given CheckoutStrategy.
+func LimitCheckoutStrategy(s CheckoutStrategy, l *HostLimiter) CheckoutStrategy {
+	return &limitedCheckoutStrategy{strategy: s, limiter: l}
+}
+
+type limitedCheckoutStrategy struct {
+	strategy CheckoutStrategy
+	limiter  *HostLimiter
+}
+
+func (c *limitedCheckoutStrategy) Checkout(ctx context.Context, path, url string, opts *AuthOptions) (*Commit, error) {
+	return CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+func (c *limitedCheckoutStrategy) CheckoutWithResult(ctx context.Context, path, url string, opts *AuthOptions) (*CheckoutResult, error) {
+	release, err := c.limiter.Acquire(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return CheckoutWithResult(ctx, c.strategy, path, url, opts)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+type sleepCheckoutStrategy struct {
+	sleep   time.Duration
+	running int32
+	maxSeen int32
+}
+
+func (s *sleepCheckoutStrategy) Checkout(_ context.Context, _, _ string, _ *AuthOptions) (*Commit, error) {
+	n := atomic.AddInt32(&s.running, 1)
+	for {
+		max := atomic.LoadInt32(&s.maxSeen)
+		if n <= max || atomic.CompareAndSwapInt32(&s.maxSeen, max, n) {
+			break
+		}
+	}
+	time.Sleep(s.sleep)
+	atomic.AddInt32(&s.running, -1)
+	return &Commit{}, nil
+}
+
+func TestLimitCheckoutStrategy(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxConcurrent int
+		urls          []string
+		wantMax       int32
+	}{
+		{
+			name:          "limit of 1 serializes checkouts to the same host",
+			maxConcurrent: 1,
+			urls:          []string{"https://example.com/org/repo1", "ssh://git@example.com:22/org/repo2"},
+			wantMax:       1,
+		},
+		{
+			name:          "limit of 1 does not serialize checkouts to different hosts",
+			maxConcurrent: 1,
+			urls:          []string{"https://example.com/org/repo", "https://example.org/org/repo"},
+			wantMax:       2,
+		},
+		{
+			name:          "no limit",
+			maxConcurrent: 0,
+			urls:          []string{"https://example.com/org/repo1", "https://example.com/org/repo2"},
+			wantMax:       2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			s := &sleepCheckoutStrategy{sleep: 200 * time.Millisecond}
+			cs := LimitCheckoutStrategy(s, NewHostLimiter(tt.maxConcurrent, 0))
+
+			var wg sync.WaitGroup
+			for _, u := range tt.urls {
+				wg.Add(1)
+				go func(u string) {
+					defer wg.Done()
+					_, err := cs.Checkout(context.TODO(), t.TempDir(), u, nil)
+					g.Expect(err).ToNot(HaveOccurred())
+				}(u)
+			}
+			wg.Wait()
+
+			g.Expect(atomic.LoadInt32(&s.maxSeen)).To(Equal(tt.wantMax))
+		})
+	}
+}
+
+func TestHostLimiter_Acquire(t *testing.T) {
+	t.Run("respects context", func(t *testing.T) {
+		g := NewWithT(t)
+
+		l := NewHostLimiter(1, 0)
+		release, err := l.Acquire(context.TODO(), "https://example.com")
+		g.Expect(err).ToNot(HaveOccurred())
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+		defer cancel()
+		_, err = l.Acquire(ctx, "https://EXAMPLE.com:443/repo")
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("example.com"))
+	})
+
+	t.Run("limits rate", func(t *testing.T) {
+		g := NewWithT(t)
+
+		l := NewHostLimiter(0, 10)
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			release, err := l.Acquire(context.TODO(), "https://example.com")
+			g.Expect(err).ToNot(HaveOccurred())
+			release()
+		}
+		g.Expect(time.Since(start)).To(BeNumerically(">=", 150*time.Millisecond))
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		g := NewWithT(t)
+
+		l := NewHostLimiter(1, 0)
+		_, err := l.Acquire(context.TODO(), "not a url")
+		g.Expect(err).To(HaveOccurred())
+	})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// DefaultNotesRef is the ref git stores notes in by default.
+	DefaultNotesRef = "refs/notes/commits"
+	// NotesRefSpec is the refspec with which the notes refs of a remote
+	// are fetched.
+	NotesRefSpec = "+refs/notes/*:refs/notes/*"
+)
+
+// FetchNotesFunc fetches the refs matching NotesRefSpec from the remote at
+// url into the repository at path.
+type FetchNotesFunc func(ctx context.Context, path, url string, opts *AuthOptions) error
+
+// ReadNotesFunc returns the note attached to the commit with the given SHA
+// in the notes ref of the repository at repoPath. The ref can be given by
+// its full name or relative to "refs/notes/", and defaults to
+// DefaultNotesRef. It returns nil if the commit has no note, and a
+// ReferenceNotFoundError if the notes ref does not exist.
+type ReadNotesFunc func(repoPath, ref, commitSHA string) ([]byte, error)
+
+// DefaultReadNotes is the ReadNotesFunc used by ReadNotes. It is set to the
+// implementation of the gogit package when it is imported.
+var DefaultReadNotes ReadNotesFunc
+
+// ReadNotes returns the note attached to the commit with the given SHA in
+// the notes ref of the repository at repoPath, using DefaultReadNotes. The
+// notes refs must have been fetched during the checkout with
+// CheckoutOptions.FetchNotes, see ReadNotesFunc.
+func ReadNotes(repoPath, ref, commitSHA string) ([]byte, error) {
+	if DefaultReadNotes == nil {
+		return nil, errNoImplementation
+	}
+	return DefaultReadNotes(repoPath, ref, commitSHA)
+}
+
+// FetchNotesCheckoutStrategy returns a CheckoutStrategy which fetches the
+// notes refs using the given FetchNotesFunc after delegating the checkout
+// to the given CheckoutStrategy. Notes are not fetched for partial commits,
+// for which no checkout was performed.
+func FetchNotesCheckoutStrategy(s CheckoutStrategy, fetch FetchNotesFunc) CheckoutStrategy {
+	return &fetchNotesCheckoutStrategy{strategy: s, fetch: fetch}
+}
+
+type fetchNotesCheckoutStrategy struct {
+	strategy CheckoutStrategy
+	fetch    FetchNotesFunc
+}
+
+func (c *fetchNotesCheckoutStrategy) Checkout(ctx context.Context, path, url string, opts *AuthOptions) (*Commit, error) {
+	return CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+func (c *fetchNotesCheckoutStrategy) CheckoutWithResult(ctx context.Context, path, url string, opts *AuthOptions) (*CheckoutResult, error) {
+	result, err := CheckoutWithResult(ctx, c.strategy, path, url, opts)
+	if err != nil || result.Commit == nil || !IsConcreteCommit(*result.Commit) {
+		return result, err
+	}
+	if err = c.fetch(ctx, path, url, opts); err != nil {
+		return nil, fmt.Errorf("failed to fetch notes: %w", err)
+	}
+	return result, nil
+}
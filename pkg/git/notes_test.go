@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFetchNotesCheckoutStrategy(t *testing.T) {
+	concrete := &Commit{Hash: Hash("abc123"), Encoded: []byte("encoded")}
+	partial := &Commit{Hash: Hash("abc123")}
+
+	tests := []struct {
+		name        string
+		commit      *Commit
+		checkoutErr error
+		fetchErr    error
+		wantFetch   bool
+		wantErr     error
+	}{
+		{
+			name:      "fetches notes of concrete commit",
+			commit:    concrete,
+			wantFetch: true,
+		},
+		{
+			name:      "returns fetch error",
+			commit:    concrete,
+			fetchErr:  errors.New("connection refused"),
+			wantFetch: true,
+			wantErr:   errors.New("failed to fetch notes: connection refused"),
+		},
+		{
+			name:   "skips partial commit",
+			commit: partial,
+		},
+		{
+			name:        "skips failed checkout",
+			checkoutErr: errors.New("checkout failed"),
+			wantErr:     errors.New("checkout failed"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var fetched bool
+			s := FetchNotesCheckoutStrategy(&mockCheckoutStrategy{commit: tt.commit, err: tt.checkoutErr},
+				func(_ context.Context, path, url string, _ *AuthOptions) error {
+					fetched = true
+					g.Expect(path).To(Equal("/tmp/checkout"))
+					g.Expect(url).To(Equal("https://example.com"))
+					return tt.fetchErr
+				})
+
+			cc, err := s.Checkout(context.TODO(), "/tmp/checkout", "https://example.com", nil)
+			g.Expect(fetched).To(Equal(tt.wantFetch))
+			if tt.wantErr != nil {
+				g.Expect(err).To(MatchError(tt.wantErr.Error()))
+				g.Expect(cc).To(BeNil())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc).To(Equal(tt.commit))
+		})
+	}
+}
+
+func TestReadNotes(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(f ReadNotesFunc) { DefaultReadNotes = f }(DefaultReadNotes)
+
+	DefaultReadNotes = nil
+	_, err := ReadNotes("repo", "", "abc123")
+	g.Expect(err).To(MatchError(errNoImplementation))
+
+	DefaultReadNotes = func(repoPath, ref, commitSHA string) ([]byte, error) {
+		g.Expect(repoPath).To(Equal("repo"))
+		g.Expect(ref).To(Equal("review"))
+		g.Expect(commitSHA).To(Equal("abc123"))
+		return []byte("note"), nil
+	}
+	got, err := ReadNotes("repo", "review", "abc123")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal([]byte("note")))
+}
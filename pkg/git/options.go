@@ -17,8 +17,15 @@ limitations under the License.
 package git
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
 )
@@ -38,20 +45,98 @@ type CheckoutOptions struct {
 	// Tag to checkout, takes precedence over Branch.
 	Tag string
 
+	// TagKeyRings are the armored PGP key rings and SSH public keys to
+	// verify the signature of the annotated Tag with. If set, the result
+	// of the verification is recorded on the checked out commit.
+	TagKeyRings []string
+
+	// RequireTagSignature defines if the checkout of the Tag should fail
+	// when its signature is missing or can not be verified with any of the
+	// TagKeyRings.
+	RequireTagSignature bool
+
 	// SemVer tag expression to checkout, takes precedence over Tag.
 	SemVer string `json:"semver,omitempty"`
 
+	// TagFilter is a regular expression tags must match to be considered
+	// for SemVer, e.g. "^release-". Only used in combination with SemVer.
+	TagFilter string
+
+	// TagKind selects the kind of tags which are considered for SemVer,
+	// defaults to TagKindAny. Only used in combination with SemVer.
+	TagKind TagKind
+
 	// Commit SHA1 to checkout, takes precedence over Tag and SemVer,
 	// can be combined with Branch with some Implementations.
 	Commit string
 
+	// RequireCommitInBranch defines if the checkout of the Commit should
+	// fail when it is not reachable from the tip of the Branch.
+	RequireCommitInBranch bool
+
 	// RecurseSubmodules defines if submodules should be checked out,
 	// not supported by all Implementations.
 	RecurseSubmodules bool
 
+	// TimeSource selects the timestamp of commits used to order tags with
+	// an equal SemVer version, defaults to TimeSourceCommit.
+	TimeSource TimeSource
+
 	// LastRevision holds the last observed revision of the local repository.
 	// It is used to skip clone operations when no changes were detected.
 	LastRevision string
+
+	// VerifyWorktree defines if the worktree should be verified against the
+	// tree of the checked out commit, after the checkout.
+	VerifyWorktree bool
+
+	// FetchNotes defines if the notes refs should be fetched after the
+	// checkout, so that the notes of the checked out commit can be read
+	// with ReadNotes.
+	FetchNotes bool
+
+	// RefSpecs are the refspecs to fetch instead of the default refs of
+	// the checkout, e.g. "+refs/heads/release/*:refs/remotes/origin/release/*".
+	// The Branch or Tag to check out is always fetched in addition. Only
+	// supported by the go-git Implementation.
+	RefSpecs []string
+}
+
+// CompileTagFilter compiles the given CheckoutOptions.TagFilter expression.
+// It returns nil if the expression is empty, or a configuration error if it
+// is not a valid regular expression.
+func CompileTagFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag filter '%s': %w", expr, err)
+	}
+	return re, nil
+}
+
+// ValidateRefSpecs validates the syntax of the given CheckoutOptions.RefSpecs.
+// A refspec must have a source and a destination, separated by a colon, and
+// may contain a single wildcard in both. The syntax is validated without
+// depending on a Git implementation, as the refspecs are passed as is to the
+// one used for the checkout.
+func ValidateRefSpecs(specs []string) error {
+	for _, spec := range specs {
+		rs := strings.TrimPrefix(spec, "+")
+		sep := strings.Index(rs, ":")
+		if sep < 0 || strings.Count(rs, ":") != 1 || sep == len(rs)-1 {
+			return fmt.Errorf("invalid refspec '%s': malformed refspec, separators are wrong", spec)
+		}
+		src, dst := rs[:sep], rs[sep+1:]
+		if src == "" {
+			return fmt.Errorf("invalid refspec '%s': source is required", spec)
+		}
+		if ws, wd := strings.Count(src, "*"), strings.Count(dst, "*"); ws != wd || ws > 1 {
+			return fmt.Errorf("invalid refspec '%s': malformed refspec, mismatched number of wildcards", spec)
+		}
+	}
+	return nil
 }
 
 type TransportType string
@@ -60,6 +145,8 @@ const (
 	SSH   TransportType = "ssh"
 	HTTPS TransportType = "https"
 	HTTP  TransportType = "http"
+	// File is the transport of local Git bundles, see IsBundleURL.
+	File TransportType = "file"
 )
 
 // AuthOptions are the authentication options for the Transport of
@@ -72,6 +159,48 @@ type AuthOptions struct {
 	Identity   []byte
 	KnownHosts []byte
 	CAFile     []byte
+	// ClientCert is the PEM encoded certificate presented by the managed
+	// HTTPS transport for TLS client authentication, it must be set
+	// together with ClientKey.
+	ClientCert []byte
+	// ClientKey is the PEM encoded private key of the ClientCert.
+	ClientKey []byte
+	// KnownHostsStrictness defines how the host key of the SSH server is
+	// verified against the KnownHosts. Defaults to KnownHostsStrict.
+	KnownHostsStrictness KnownHostsStrictness
+	// KnownHostsPersist is called with the known_hosts line of a host key
+	// accepted on first use under KnownHostsAcceptNew, so that it can be
+	// persisted for later connections.
+	KnownHostsPersist func(line string) error
+	// Headers are additional HTTP headers set on every request made by the
+	// HTTP(S) transport, similar to git's http.extraHeader. Headers required
+	// by the Git protocol cannot be overridden, and an Authorization header
+	// can only be set if Username and Password are not.
+	Headers map[string]string
+	// UserAgent identifies the client to the remote, it is appended to the
+	// User-Agent of the managed HTTP(S) transport which has to start with
+	// "git/" for some Git hosts to use the smart protocol.
+	UserAgent string
+	// CredentialHelper is the absolute path to an executable implementing
+	// the git credential helper protocol. If set, it is invoked at checkout
+	// time to obtain the Username and Password for HTTP(S) remotes, unless
+	// a Password is already set.
+	CredentialHelper string
+	// RedirectTrustedHosts are the patterns of the hosts, as supported by
+	// path.Match, to which the managed HTTP(S) transport re-sends the
+	// credentials when it is redirected to a different host. Credentials are
+	// always re-sent on redirects to the same host, and never on a
+	// downgrade from https to http.
+	RedirectTrustedHosts []string
+	// UseSSHAgent enables authentication with the keys held by an SSH
+	// agent, instead of the Identity, for SSH remotes. The known hosts are
+	// verified as usual. It is only supported by the managed libgit2 SSH
+	// transport.
+	UseSSHAgent bool
+	// SSHAgentSocket is the path of the Unix socket of the SSH agent used
+	// when UseSSHAgent is set. Defaults to the value of the SSH_AUTH_SOCK
+	// environment variable.
+	SSHAgentSocket string
 	// TransportOptionsURL is a unique identifier for this set of authentication
 	// options. It's used by managed libgit2 transports to uniquely identify
 	// which credentials to use for a particular Git operation, and avoid misuse
@@ -84,6 +213,10 @@ type AuthOptions struct {
 	TransportOptionsURL string
 }
 
+// reservedHeaders are the HTTP headers required by the Git smart HTTP
+// protocol, which cannot be set using AuthOptions.Headers.
+var reservedHeaders = []string{"Content-Type", "Content-Length", "Host", "Transfer-Encoding"}
+
 // KexAlgos hosts the key exchange algorithms to be used for SSH connections.
 // If empty, Go's default is used instead.
 var KexAlgos []string
@@ -92,31 +225,127 @@ var KexAlgos []string
 // to the server. If empty, Go's default is used instead.
 var HostKeyAlgos []string
 
-// Validate the AuthOptions against the defined Transport.
-func (o AuthOptions) Validate() error {
-	switch o.Transport {
+// DefaultRedirectTrustedHosts are the RedirectTrustedHosts of the
+// AuthOptions constructed from a Secret or URL.
+var DefaultRedirectTrustedHosts []string
+
+// Validate the AuthOptions for the given transport. It returns an error
+// describing the missing or conflicting options if the AuthOptions are
+// incomplete for the transport, or if they were constructed for a
+// different Transport. HTTP(S) allows anonymous access, while SSH requires
+// an identity and the known hosts.
+func (o AuthOptions) Validate(transport TransportType) error {
+	if transport == "" {
+		return fmt.Errorf("no transport type set")
+	}
+	if o.Transport != "" && o.Transport != transport {
+		return fmt.Errorf("auth options for the '%s' transport cannot be used for the '%s' transport", o.Transport, transport)
+	}
+
+	switch transport {
 	case HTTPS, HTTP:
 		if o.Username == "" && o.Password != "" {
-			return fmt.Errorf("invalid '%s' auth option: 'password' requires 'username' to be set", o.Transport)
+			return fmt.Errorf("invalid '%s' auth option: 'password' requires 'username' to be set", transport)
+		}
+		if len(o.Identity) > 0 && o.Password == "" {
+			return fmt.Errorf("invalid '%s' auth option: 'identity' is only supported for SSH URLs, "+
+				"set 'username' and 'password' to authenticate over HTTP(S)", transport)
+		}
+		for k := range o.Headers {
+			for _, r := range reservedHeaders {
+				if strings.EqualFold(k, r) {
+					return fmt.Errorf("invalid '%s' auth option: header '%s' is reserved", transport, k)
+				}
+			}
+			if strings.EqualFold(k, "Authorization") && o.Username != "" && o.Password != "" {
+				return fmt.Errorf("invalid '%s' auth option: header '%s' conflicts with 'username' and 'password'", transport, k)
+			}
+		}
+		if o.CredentialHelper != "" && !filepath.IsAbs(o.CredentialHelper) {
+			return fmt.Errorf("invalid '%s' auth option: credential helper path '%s' must be absolute", transport, o.CredentialHelper)
+		}
+		for _, p := range o.RedirectTrustedHosts {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid '%s' auth option: redirect trusted host pattern '%s': %w", transport, p, err)
+			}
+		}
+		if len(o.ClientCert) > 0 || len(o.ClientKey) > 0 {
+			if transport != HTTPS {
+				return fmt.Errorf("invalid '%s' auth option: 'certFile' and 'keyFile' are only supported for HTTPS URLs", transport)
+			}
+			if len(o.ClientCert) == 0 || len(o.ClientKey) == 0 {
+				return fmt.Errorf("invalid '%s' auth option: 'certFile' and 'keyFile' must be set together", transport)
+			}
+			if _, err := tls.X509KeyPair(o.ClientCert, o.ClientKey); err != nil {
+				return fmt.Errorf("invalid '%s' auth option: client certificate: %w", transport, err)
+			}
 		}
 	case SSH:
 		if o.Host == "" {
-			return fmt.Errorf("invalid '%s' auth option: 'host' is required", o.Transport)
+			return fmt.Errorf("invalid '%s' auth option: 'host' is required", transport)
 		}
-		if len(o.Identity) == 0 {
-			return fmt.Errorf("invalid '%s' auth option: 'identity' is required", o.Transport)
+		switch {
+		case len(o.Identity) > 0:
+		case o.UseSSHAgent:
+			if o.SSHAgentSocketPath() == "" {
+				return fmt.Errorf("invalid '%s' auth option: no SSH agent socket configured, "+
+					"set the socket path or the %s environment variable", transport, SSHAuthSockEnv)
+			}
+		case o.Password != "":
+			return fmt.Errorf("invalid '%s' auth option: 'identity' is required, "+
+				"'username' and 'password' are only supported for HTTP(S) URLs", transport)
+		default:
+			return fmt.Errorf("invalid '%s' auth option: 'identity' is required, "+
+				"set it to the private key of an SSH key pair", transport)
 		}
-		if len(o.KnownHosts) == 0 {
-			return fmt.Errorf("invalid '%s' auth option: 'known_hosts' is required", o.Transport)
+		switch o.KnownHostsStrictness {
+		case KnownHostsStrict, "":
+			if len(o.KnownHosts) == 0 {
+				return fmt.Errorf("invalid '%s' auth option: 'known_hosts' is required, "+
+					"set it to the public host keys of the SSH server", transport)
+			}
+		case KnownHostsAcceptNew, KnownHostsIgnore:
+		default:
+			return fmt.Errorf("invalid '%s' auth option: unknown known_hosts strictness '%s'", transport, o.KnownHostsStrictness)
 		}
-	case "":
-		return fmt.Errorf("no transport type set")
+	case File:
+		// Local bundles are read without authentication.
 	default:
-		return fmt.Errorf("unknown transport '%s'", o.Transport)
+		return fmt.Errorf("unknown transport '%s'", transport)
 	}
 	return nil
 }
 
+// SSHAuthSockEnv is the environment variable holding the path of the Unix
+// socket of the SSH agent, used when AuthOptions.SSHAgentSocket is empty.
+const SSHAuthSockEnv = "SSH_AUTH_SOCK"
+
+// SSHAgentSocketPath returns the SSHAgentSocket, or the value of the
+// SSHAuthSockEnv environment variable if it is empty.
+func (o AuthOptions) SSHAgentSocketPath() string {
+	if o.SSHAgentSocket != "" {
+		return o.SSHAgentSocket
+	}
+	return os.Getenv(SSHAuthSockEnv)
+}
+
+// RedirectKeepsCredentials returns if the credentials of the AuthOptions
+// may be re-sent on a redirect from the URL from to the URL to. This is the
+// case for redirects to the same host, including an upgrade from http to
+// https, and to the RedirectTrustedHosts, unless it is a downgrade from
+// https to http.
+func (o AuthOptions) RedirectKeepsCredentials(from, to *url.URL) bool {
+	if strings.EqualFold(from.Scheme, "https") && strings.EqualFold(to.Scheme, "http") {
+		return false
+	}
+	host := strings.ToLower(to.Hostname())
+	if strings.EqualFold(from.Hostname(), host) {
+		return true
+	}
+	_, ok := matchHostPattern(o.RedirectTrustedHosts, host)
+	return ok
+}
+
 // AuthOptionsFromSecret constructs an AuthOptions object from the given Secret,
 // and then validates the result. It returns the AuthOptions, or an error.
 func AuthOptionsFromSecret(URL string, secret *v1.Secret) (*AuthOptions, error) {
@@ -130,13 +359,28 @@ func AuthOptionsFromSecret(URL string, secret *v1.Secret) (*AuthOptions, error)
 	}
 
 	opts := &AuthOptions{
-		Transport:  TransportType(u.Scheme),
-		Host:       u.Host,
-		Username:   string(secret.Data["username"]),
-		Password:   string(secret.Data["password"]),
-		CAFile:     secret.Data["caFile"],
-		Identity:   secret.Data["identity"],
-		KnownHosts: secret.Data["known_hosts"],
+		Transport:            TransportType(u.Scheme),
+		Host:                 u.Host,
+		Username:             string(secret.Data["username"]),
+		Password:             string(secret.Data["password"]),
+		CAFile:               secret.Data["caFile"],
+		ClientCert:           secret.Data["certFile"],
+		ClientKey:            secret.Data["keyFile"],
+		Identity:             secret.Data["identity"],
+		KnownHosts:           secret.Data["known_hosts"],
+		RedirectTrustedHosts: DefaultRedirectTrustedHosts,
+	}
+	if v, ok := secret.Data["credentialHelper"]; ok {
+		useHelper, err := strconv.ParseBool(string(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'credentialHelper' value '%s': %w", v, err)
+		}
+		if useHelper {
+			if DefaultCredentialHelper == "" {
+				return nil, fmt.Errorf("'credentialHelper' is enabled, but no git credential helper is configured")
+			}
+			opts.CredentialHelper = DefaultCredentialHelper
+		}
 	}
 	if opts.Username == "" {
 		opts.Username = u.User.Username()
@@ -145,7 +389,7 @@ func AuthOptionsFromSecret(URL string, secret *v1.Secret) (*AuthOptions, error)
 		opts.Username = DefaultPublicKeyAuthUser
 	}
 
-	if err = opts.Validate(); err != nil {
+	if err = opts.Validate(opts.Transport); err != nil {
 		return nil, err
 	}
 
@@ -162,11 +406,12 @@ func AuthOptionsWithoutSecret(URL string) (*AuthOptions, error) {
 	}
 
 	opts := &AuthOptions{
-		Transport: TransportType(u.Scheme),
-		Host:      u.Host,
+		Transport:            TransportType(u.Scheme),
+		Host:                 u.Host,
+		RedirectTrustedHosts: DefaultRedirectTrustedHosts,
 	}
 
-	if err = opts.Validate(); err != nil {
+	if err = opts.Validate(opts.Transport); err != nil {
 		return nil, err
 	}
 
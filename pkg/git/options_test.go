@@ -17,6 +17,8 @@ limitations under the License.
 package git
 
 import (
+	"net/url"
+	"os"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -63,10 +65,22 @@ MbcpIxCfl8oB09bWfY6tDQjyvwSYYo2Phdwm7kT92xc=
 )
 
 func TestAuthOptions_Validate(t *testing.T) {
+	clientCert, err := os.ReadFile("strategy/testdata/certs/server.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKey, err := os.ReadFile("strategy/testdata/certs/server-key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
-		name    string
-		opts    AuthOptions
-		wantErr string
+		name string
+		opts AuthOptions
+		// transport to validate the options for, defaults to the
+		// Transport of the options.
+		transport TransportType
+		wantErr   string
 	}{
 		{
 			name: "HTTP transport with password requires user",
@@ -84,6 +98,12 @@ func TestAuthOptions_Validate(t *testing.T) {
 				Password:  "foo",
 			},
 		},
+		{
+			name: "Valid file transport",
+			opts: AuthOptions{
+				Transport: File,
+			},
+		},
 		{
 			name: "HTTPS transport with password requires user",
 			opts: AuthOptions{
@@ -100,12 +120,67 @@ func TestAuthOptions_Validate(t *testing.T) {
 				Password:  "foo",
 			},
 		},
+		{
+			name: "HTTPS transport with absolute credential helper path",
+			opts: AuthOptions{
+				Transport:        HTTPS,
+				CredentialHelper: "/usr/local/bin/git-credential-store",
+			},
+		},
+		{
+			name: "HTTPS transport with relative credential helper path",
+			opts: AuthOptions{
+				Transport:        HTTPS,
+				CredentialHelper: "git-credential-store",
+			},
+			wantErr: "invalid 'https' auth option: credential helper path 'git-credential-store' must be absolute",
+		},
+		{
+			name: "Valid HTTPS transport with headers",
+			opts: AuthOptions{
+				Transport: HTTPS,
+				Headers: map[string]string{
+					"X-Custom-Header": "foo",
+					"Authorization":   "Bearer token",
+				},
+			},
+		},
+		{
+			name: "HTTPS transport with reserved header",
+			opts: AuthOptions{
+				Transport: HTTPS,
+				Headers: map[string]string{
+					"content-type": "text/plain",
+				},
+			},
+			wantErr: "invalid 'https' auth option: header 'content-type' is reserved",
+		},
+		{
+			name: "HTTPS transport with Authorization header and credentials",
+			opts: AuthOptions{
+				Transport: HTTPS,
+				Username:  "example",
+				Password:  "foo",
+				Headers: map[string]string{
+					"Authorization": "Bearer token",
+				},
+			},
+			wantErr: "invalid 'https' auth option: header 'Authorization' conflicts with 'username' and 'password'",
+		},
 		{
 			name: "Valid HTTPS without any config",
 			opts: AuthOptions{
 				Transport: HTTPS,
 			},
 		},
+		{
+			name: "HTTPS transport with invalid redirect trusted host pattern",
+			opts: AuthOptions{
+				Transport:            HTTPS,
+				RedirectTrustedHosts: []string{"[example.com"},
+			},
+			wantErr: "invalid 'https' auth option: redirect trusted host pattern '[example.com'",
+		},
 		{
 			name: "SSH transport requires host",
 			opts: AuthOptions{
@@ -121,6 +196,26 @@ func TestAuthOptions_Validate(t *testing.T) {
 			},
 			wantErr: "invalid 'ssh' auth option: 'identity' is required",
 		},
+		{
+			name: "SSH transport with SSH agent does not require identity",
+			opts: AuthOptions{
+				Transport:      SSH,
+				Host:           "github.com:22",
+				UseSSHAgent:    true,
+				SSHAgentSocket: "/run/ssh-agent.sock",
+				KnownHosts:     []byte(knownHostsFixture),
+			},
+		},
+		{
+			name: "SSH transport with SSH agent requires known_hosts",
+			opts: AuthOptions{
+				Transport:      SSH,
+				Host:           "github.com:22",
+				UseSSHAgent:    true,
+				SSHAgentSocket: "/run/ssh-agent.sock",
+			},
+			wantErr: "invalid 'ssh' auth option: 'known_hosts' is required",
+		},
 		{
 			name: "SSH transport requires known_hosts",
 			opts: AuthOptions{
@@ -130,6 +225,81 @@ func TestAuthOptions_Validate(t *testing.T) {
 			},
 			wantErr: "invalid 'ssh' auth option: 'known_hosts' is required",
 		},
+		{
+			name: "SSH transport with accept-new does not require known_hosts",
+			opts: AuthOptions{
+				Transport:            SSH,
+				Host:                 "github.com:22",
+				Identity:             []byte(privateKeyFixture),
+				KnownHostsStrictness: KnownHostsAcceptNew,
+			},
+		},
+		{
+			name: "SSH transport with unknown known_hosts strictness",
+			opts: AuthOptions{
+				Transport:            SSH,
+				Host:                 "github.com:22",
+				Identity:             []byte(privateKeyFixture),
+				KnownHosts:           []byte(knownHostsFixture),
+				KnownHostsStrictness: "foo",
+			},
+			wantErr: "invalid 'ssh' auth option: unknown known_hosts strictness 'foo'",
+		},
+		{
+			name: "SSH transport with username and password",
+			opts: AuthOptions{
+				Transport: SSH,
+				Host:      "github.com:22",
+				Username:  "example",
+				Password:  "foo",
+			},
+			wantErr: "invalid 'ssh' auth option: 'identity' is required, 'username' and 'password' are only supported for HTTP(S) URLs",
+		},
+		{
+			name: "HTTPS transport with identity only",
+			opts: AuthOptions{
+				Transport:  HTTPS,
+				Username:   DefaultPublicKeyAuthUser,
+				Identity:   []byte(privateKeyFixture),
+				KnownHosts: []byte(knownHostsFixture),
+			},
+			wantErr: "invalid 'https' auth option: 'identity' is only supported for SSH URLs",
+		},
+		{
+			name: "Valid HTTP transport for options without transport",
+			opts: AuthOptions{
+				Username: "example",
+				Password: "foo",
+			},
+			transport: HTTP,
+		},
+		{
+			name: "Valid SSH transport for options without transport",
+			opts: AuthOptions{
+				Host:       "github.com:22",
+				Identity:   []byte(privateKeyFixture),
+				KnownHosts: []byte(knownHostsFixture),
+			},
+			transport: SSH,
+		},
+		{
+			name: "SSH transport for options without transport requires identity",
+			opts: AuthOptions{
+				Host: "github.com:22",
+			},
+			transport: SSH,
+			wantErr:   "invalid 'ssh' auth option: 'identity' is required, set it to the private key of an SSH key pair",
+		},
+		{
+			name: "Options of other transport",
+			opts: AuthOptions{
+				Transport: HTTPS,
+				Username:  "example",
+				Password:  "foo",
+			},
+			transport: SSH,
+			wantErr:   "auth options for the 'https' transport cannot be used for the 'ssh' transport",
+		},
 		{
 			name:    "Requires transport",
 			opts:    AuthOptions{},
@@ -150,6 +320,40 @@ func TestAuthOptions_Validate(t *testing.T) {
 			opts:    AuthOptions{},
 			wantErr: "no transport type set",
 		},
+		{
+			name: "HTTPS transport with client certificate",
+			opts: AuthOptions{
+				Transport:  HTTPS,
+				ClientCert: clientCert,
+				ClientKey:  clientKey,
+			},
+		},
+		{
+			name: "HTTPS transport with client certificate without key",
+			opts: AuthOptions{
+				Transport:  HTTPS,
+				ClientCert: clientCert,
+			},
+			wantErr: "invalid 'https' auth option: 'certFile' and 'keyFile' must be set together",
+		},
+		{
+			name: "HTTPS transport with invalid client certificate",
+			opts: AuthOptions{
+				Transport:  HTTPS,
+				ClientCert: []byte("invalid"),
+				ClientKey:  clientKey,
+			},
+			wantErr: "invalid 'https' auth option: client certificate: tls: failed to find any PEM data in certificate input",
+		},
+		{
+			name: "HTTP transport with client certificate",
+			opts: AuthOptions{
+				Transport:  HTTP,
+				ClientCert: clientCert,
+				ClientKey:  clientKey,
+			},
+			wantErr: "invalid 'http' auth option: 'certFile' and 'keyFile' are only supported for HTTPS URLs",
+		},
 		{
 			name: "Unknown transport",
 			opts: AuthOptions{
@@ -162,7 +366,11 @@ func TestAuthOptions_Validate(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			g := NewWithT(t)
 
-			got := tt.opts.Validate()
+			transport := tt.transport
+			if transport == "" {
+				transport = tt.opts.Transport
+			}
+			got := tt.opts.Validate(transport)
 			if tt.wantErr != "" {
 				g.Expect(got.Error()).To(ContainSubstring(tt.wantErr))
 				return
@@ -172,7 +380,100 @@ func TestAuthOptions_Validate(t *testing.T) {
 	}
 }
 
+func TestAuthOptions_RedirectKeepsCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		trustedHosts []string
+		from         string
+		to           string
+		want         bool
+	}{
+		{
+			name: "same host",
+			from: "https://example.com/org/repo",
+			to:   "https://example.com/org/repo.git",
+			want: true,
+		},
+		{
+			name: "same host with different case",
+			from: "https://example.com/org/repo",
+			to:   "https://EXAMPLE.com/org/repo.git",
+			want: true,
+		},
+		{
+			name: "upgrade from http to https",
+			from: "http://example.com/org/repo",
+			to:   "https://example.com/org/repo",
+			want: true,
+		},
+		{
+			name: "downgrade from https to http",
+			from: "https://example.com/org/repo",
+			to:   "http://example.com/org/repo",
+		},
+		{
+			name: "cross host",
+			from: "https://example.com/org/repo",
+			to:   "https://mirror.example.com/org/repo",
+		},
+		{
+			name:         "cross host to trusted host",
+			trustedHosts: []string{"*.example.com"},
+			from:         "https://example.com/org/repo",
+			to:           "https://mirror.example.com:8443/org/repo",
+			want:         true,
+		},
+		{
+			name:         "downgrade to trusted host",
+			trustedHosts: []string{"*.example.com"},
+			from:         "https://example.com/org/repo",
+			to:           "http://mirror.example.com/org/repo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			from, err := url.Parse(tt.from)
+			g.Expect(err).ToNot(HaveOccurred())
+			to, err := url.Parse(tt.to)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			opts := AuthOptions{Transport: HTTPS, RedirectTrustedHosts: tt.trustedHosts}
+			g.Expect(opts.RedirectKeepsCredentials(from, to)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestAuthOptions_SSHAgentSocketPath(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Setenv(SSHAuthSockEnv, "/tmp/agent.sock")
+	g.Expect(AuthOptions{}.SSHAgentSocketPath()).To(Equal("/tmp/agent.sock"))
+	g.Expect(AuthOptions{SSHAgentSocket: "/run/agent.sock"}.SSHAgentSocketPath()).To(Equal("/run/agent.sock"))
+
+	t.Setenv(SSHAuthSockEnv, "")
+	opts := AuthOptions{
+		Transport:   SSH,
+		Host:        "github.com:22",
+		UseSSHAgent: true,
+		KnownHosts:  []byte(knownHostsFixture),
+	}
+	err := opts.Validate(SSH)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("no SSH agent socket configured"))
+}
+
 func TestAuthOptionsFromSecret(t *testing.T) {
+	clientCert, err := os.ReadFile("strategy/testdata/certs/server.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKey, err := os.ReadFile("strategy/testdata/certs/server-key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		name     string
 		URL      string
@@ -200,6 +501,20 @@ func TestAuthOptionsFromSecret(t *testing.T) {
 				g.Expect(opts.CAFile).To(BeEquivalentTo("mock"))
 			},
 		},
+		{
+			name: "Sets client certificate from Secret",
+			URL:  "https://example.com",
+			secret: &v1.Secret{
+				Data: map[string][]byte{
+					"certFile": clientCert,
+					"keyFile":  clientKey,
+				},
+			},
+			wantFunc: func(g *WithT, opts *AuthOptions, secret *v1.Secret) {
+				g.Expect(opts.ClientCert).To(Equal(clientCert))
+				g.Expect(opts.ClientKey).To(Equal(clientKey))
+			},
+		},
 		{
 			name:   "Sets default user",
 			URL:    "http://example.com",
@@ -270,3 +585,117 @@ func TestAuthOptionsFromSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthOptionsFromSecret_credentialHelper(t *testing.T) {
+	helper := DefaultCredentialHelper
+	DefaultCredentialHelper = "/usr/local/bin/git-credential-store"
+	defer func() {
+		DefaultCredentialHelper = helper
+	}()
+
+	tests := []struct {
+		name    string
+		value   []byte
+		want    string
+		wantErr string
+	}{
+		{name: "not set", want: ""},
+		{name: "enabled", value: []byte("true"), want: "/usr/local/bin/git-credential-store"},
+		{name: "disabled", value: []byte("false"), want: ""},
+		{name: "invalid", value: []byte("yes please"), wantErr: "invalid 'credentialHelper' value 'yes please'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			secret := &v1.Secret{Data: map[string][]byte{}}
+			if tt.value != nil {
+				secret.Data["credentialHelper"] = tt.value
+			}
+			got, err := AuthOptionsFromSecret("https://example.com/org/repo", secret)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got.CredentialHelper).To(Equal(tt.want))
+		})
+	}
+
+	t.Run("enabled without configured helper", func(t *testing.T) {
+		g := NewWithT(t)
+
+		DefaultCredentialHelper = ""
+		defer func() {
+			DefaultCredentialHelper = "/usr/local/bin/git-credential-store"
+		}()
+		_, err := AuthOptionsFromSecret("https://example.com/org/repo", &v1.Secret{
+			Data: map[string][]byte{"credentialHelper": []byte("true")},
+		})
+		g.Expect(err).To(MatchError(ContainSubstring("no git credential helper is configured")))
+	})
+
+	t.Run("without secret", func(t *testing.T) {
+		g := NewWithT(t)
+
+		got, err := AuthOptionsWithoutSecret("https://example.com/org/repo")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(got.CredentialHelper).To(BeEmpty())
+	})
+}
+
+func TestValidateRefSpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		wantErr string
+	}{
+		{
+			name: "no refspecs",
+		},
+		{
+			name: "valid refspecs",
+			specs: []string{
+				"+refs/heads/release/*:refs/remotes/origin/release/*",
+				"refs/tags/v1.0.0:refs/tags/v1.0.0",
+			},
+		},
+		{
+			name:    "missing destination",
+			specs:   []string{"refs/heads/main"},
+			wantErr: "invalid refspec 'refs/heads/main': malformed refspec, separators are wrong",
+		},
+		{
+			name:    "mismatched wildcards",
+			specs:   []string{"refs/heads/*:refs/remotes/origin/main"},
+			wantErr: "invalid refspec 'refs/heads/*:refs/remotes/origin/main': malformed refspec, mismatched number of wildcards",
+		},
+		{
+			name:    "missing source",
+			specs:   []string{":refs/heads/main"},
+			wantErr: "invalid refspec ':refs/heads/main': source is required",
+		},
+		{
+			name:    "missing destination after separator",
+			specs:   []string{"+refs/heads/main:"},
+			wantErr: "invalid refspec '+refs/heads/main:': malformed refspec, separators are wrong",
+		},
+		{
+			name:    "multiple wildcards",
+			specs:   []string{"refs/*/release/*:refs/remotes/*/release/*"},
+			wantErr: "invalid refspec 'refs/*/release/*:refs/remotes/*/release/*': malformed refspec, mismatched number of wildcards",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := ValidateRefSpecs(tt.specs)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+}
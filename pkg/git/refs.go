@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/fluxcd/source-controller/internal/tracing"
+)
+
+// RefType is the type of a Ref.
+type RefType string
+
+const (
+	// RefTypeBranch is the type of references in "refs/heads/".
+	RefTypeBranch RefType = "branch"
+	// RefTypeTag is the type of references in "refs/tags/".
+	RefTypeTag RefType = "tag"
+)
+
+// Ref is a branch or tag reference of a remote repository.
+type Ref struct {
+	// Name is the full name of the reference, e.g. "refs/heads/main".
+	Name string
+	// Type of the reference.
+	Type RefType
+	// Hash is the hash of the object the reference points to. For
+	// annotated tags, this is the hash of the tag object.
+	Hash string
+	// Peeled is the hash of the commit an annotated tag points to. It is
+	// empty for branches and lightweight tags.
+	Peeled string
+}
+
+// ShortName returns the name of the reference without the "refs/heads/" or
+// "refs/tags/" prefix.
+func (r Ref) ShortName() string {
+	return strings.TrimPrefix(strings.TrimPrefix(r.Name, "refs/heads/"), "refs/tags/")
+}
+
+// IsAnnotatedTag returns if the reference is an annotated tag.
+func (r Ref) IsAnnotatedTag() bool {
+	return r.Type == RefTypeTag && r.Peeled != ""
+}
+
+// Target returns the hash of the commit the reference points to, with
+// annotated tags peeled to the tagged commit.
+func (r Ref) Target() string {
+	if r.Peeled != "" {
+		return r.Peeled
+	}
+	return r.Hash
+}
+
+// NewRefs returns the branches and tags of the given advertised references,
+// sorted by name. The peeled map holds the hashes of the commits annotated
+// tags point to, by name of the tag reference. Other references, such as
+// HEAD, are ignored.
+func NewRefs(refs, peeled map[string]string) []Ref {
+	var result []Ref
+	for name, hash := range refs {
+		var t RefType
+		switch {
+		case strings.HasSuffix(name, "^{}"):
+			continue
+		case strings.HasPrefix(name, "refs/heads/"):
+			t = RefTypeBranch
+		case strings.HasPrefix(name, "refs/tags/"):
+			t = RefTypeTag
+		default:
+			continue
+		}
+		ref := Ref{Name: name, Type: t, Hash: hash}
+		if t == RefTypeTag {
+			if p, ok := peeled[name]; ok && p != hash {
+				ref.Peeled = p
+			}
+		}
+		result = append(result, ref)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// ListRefsFunc returns the branches and tags of the remote repository at
+// url, sorted by name, with annotated tags peeled to the tagged commit.
+type ListRefsFunc func(ctx context.Context, url string, opts *AuthOptions) ([]Ref, error)
+
+// DefaultListRefs is the ListRefsFunc used by ListRefs. It is set to the
+// implementation of the gogit package when it is imported.
+var DefaultListRefs ListRefsFunc
+
+// ListRefs returns the branches and tags of the remote repository at url
+// using DefaultListRefs. Like the CheckoutStrategy returned for an
+// Implementation, it only allows hosts permitted by DefaultHostPolicy, is
+// throttled per host by DefaultHostLimiter, obtains credentials from the
+// credential helper configured in the AuthOptions, and is recorded in a
+// tracing span.
+func ListRefs(ctx context.Context, url string, opts *AuthOptions) (refs []Ref, err error) {
+	if DefaultListRefs == nil {
+		return nil, errNoImplementation
+	}
+
+	attrs := []attribute.KeyValue{tracing.HostKey.String(hostFromURL(url))}
+	if opts != nil {
+		attrs = append(attrs, tracing.TransportKey.String(string(opts.Transport)))
+	}
+	ctx, span := tracing.Start(ctx, "git.list_refs", attrs...)
+	defer func() { tracing.End(span, err) }()
+
+	if err = DefaultHostPolicy.Validate(ctx, url); err != nil {
+		return nil, err
+	}
+	release, err := DefaultHostLimiter.Acquire(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if opts, err = credentialHelperAuthOptions(ctx, url, opts); err != nil {
+		return nil, err
+	}
+	return DefaultListRefs(ctx, url, opts)
+}
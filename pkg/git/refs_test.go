@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestNewRefs(t *testing.T) {
+	g := NewWithT(t)
+
+	refs := map[string]string{
+		"HEAD":                   "a1",
+		"refs/heads/main":        "a1",
+		"refs/heads/release/v1":  "b2",
+		"refs/tags/v1.0.0":       "c3",
+		"refs/tags/v1.1.0":       "d4",
+		"refs/tags/v1.1.0^{}":    "a1",
+		"refs/pull/1/head":       "e5",
+		"refs/remotes/origin/hi": "f6",
+	}
+	peeled := map[string]string{
+		"refs/tags/v1.1.0": "a1",
+	}
+
+	got := NewRefs(refs, peeled)
+	g.Expect(got).To(Equal([]Ref{
+		{Name: "refs/heads/main", Type: RefTypeBranch, Hash: "a1"},
+		{Name: "refs/heads/release/v1", Type: RefTypeBranch, Hash: "b2"},
+		{Name: "refs/tags/v1.0.0", Type: RefTypeTag, Hash: "c3"},
+		{Name: "refs/tags/v1.1.0", Type: RefTypeTag, Hash: "d4", Peeled: "a1"},
+	}))
+}
+
+func TestRef(t *testing.T) {
+	tests := []struct {
+		name          string
+		ref           Ref
+		wantShortName string
+		wantAnnotated bool
+		wantTarget    string
+	}{
+		{
+			name:          "branch",
+			ref:           Ref{Name: "refs/heads/release/v1", Type: RefTypeBranch, Hash: "b2"},
+			wantShortName: "release/v1",
+			wantTarget:    "b2",
+		},
+		{
+			name:          "lightweight tag",
+			ref:           Ref{Name: "refs/tags/v1.0.0", Type: RefTypeTag, Hash: "c3"},
+			wantShortName: "v1.0.0",
+			wantTarget:    "c3",
+		},
+		{
+			name:          "annotated tag",
+			ref:           Ref{Name: "refs/tags/v1.1.0", Type: RefTypeTag, Hash: "d4", Peeled: "a1"},
+			wantShortName: "v1.1.0",
+			wantAnnotated: true,
+			wantTarget:    "a1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(tt.ref.ShortName()).To(Equal(tt.wantShortName))
+			g.Expect(tt.ref.IsAnnotatedTag()).To(Equal(tt.wantAnnotated))
+			g.Expect(tt.ref.Target()).To(Equal(tt.wantTarget))
+		})
+	}
+}
+
+func TestListRefs(t *testing.T) {
+	defer func(f ListRefsFunc) { DefaultListRefs = f }(DefaultListRefs)
+
+	helper, _ := writeCredentialHelper(t, "username=jane\npassword=token\n", 0)
+	refs := []Ref{{Name: "refs/heads/main", Type: RefTypeBranch, Hash: "abc123"}}
+
+	tests := []struct {
+		name             string
+		url              string
+		opts             *AuthOptions
+		noImplementation bool
+		wantOpts         *AuthOptions
+		wantErr          bool
+	}{
+		{
+			name:     "lists refs",
+			url:      "https://example.com/org/repo",
+			opts:     &AuthOptions{Transport: HTTPS},
+			wantOpts: &AuthOptions{Transport: HTTPS},
+		},
+		{
+			name:     "obtains credentials from helper",
+			url:      "https://example.com/org/repo",
+			opts:     &AuthOptions{Transport: HTTPS, CredentialHelper: helper},
+			wantOpts: &AuthOptions{Transport: HTTPS, Username: "jane", Password: "token", CredentialHelper: helper},
+		},
+		{
+			name:             "no implementation",
+			url:              "https://example.com/org/repo",
+			noImplementation: true,
+			wantErr:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var called bool
+			DefaultListRefs = func(_ context.Context, url string, opts *AuthOptions) ([]Ref, error) {
+				called = true
+				g.Expect(url).To(Equal(tt.url))
+				g.Expect(opts).To(Equal(tt.wantOpts))
+				return refs, nil
+			}
+			if tt.noImplementation {
+				DefaultListRefs = nil
+			}
+
+			got, err := ListRefs(context.TODO(), tt.url, tt.opts)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(called).To(BeFalse())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(refs))
+		})
+	}
+}
+
+func TestListRefs_hostPolicyViolation(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(f ListRefsFunc) { DefaultListRefs = f }(DefaultListRefs)
+	defer func(p *HostPolicy) { DefaultHostPolicy = p }(DefaultHostPolicy)
+
+	DefaultListRefs = func(context.Context, string, *AuthOptions) ([]Ref, error) { return nil, nil }
+	DefaultHostPolicy = &HostPolicy{Allow: []string{"example.com"}}
+
+	_, err := ListRefs(context.TODO(), "git@other.example.com:org/repo.git", nil)
+	var violation *HostPolicyViolationError
+	g.Expect(errors.As(err, &violation)).To(BeTrue())
+	g.Expect(violation.Host).To(Equal("other.example.com"))
+}
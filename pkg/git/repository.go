@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import "errors"
+
+// AheadBehindFunc returns the number of commits head is ahead and behind
+// base in the Git repository checked out at repoPath.
+type AheadBehindFunc func(repoPath, base, head string) (ahead, behind int, err error)
+
+// DefaultAheadBehind is the AheadBehindFunc used by AheadBehind. It is set
+// to the implementation of the gogit package when it is imported.
+var DefaultAheadBehind AheadBehindFunc
+
+// AheadBehind returns the number of commits head is ahead and behind base in
+// the Git repository checked out at repoPath, using DefaultAheadBehind. The
+// base and head revisions can be anything understood by git rev-parse, e.g.
+// a commit SHA, a branch or a tag.
+func AheadBehind(repoPath, base, head string) (ahead, behind int, err error) {
+	if DefaultAheadBehind == nil {
+		return 0, 0, errNoImplementation
+	}
+	return DefaultAheadBehind(repoPath, base, head)
+}
+
+// errNoImplementation is returned by the helpers operating on a checked out
+// repository if no Git implementation registered itself for them.
+var errNoImplementation = errors.New("no Git implementation available, import the gogit package")
+
+// FileChangeType is the type of change of a file between two commits.
+type FileChangeType string
+
+const (
+	// FileAdded is a file which did not exist in the old commit.
+	FileAdded FileChangeType = "added"
+	// FileModified is a file of which the content or mode changed.
+	FileModified FileChangeType = "modified"
+	// FileDeleted is a file which does not exist in the new commit.
+	FileDeleted FileChangeType = "deleted"
+	// FileRenamed is a file which was moved to another path, optionally with
+	// changes to its content.
+	FileRenamed FileChangeType = "renamed"
+)
+
+// FileChange is a change to a file between two commits.
+type FileChange struct {
+	// Type of the change.
+	Type FileChangeType
+	// Path of the file in the new commit, or in the old commit for
+	// deleted files.
+	Path string
+	// OldPath of the file in the old commit, only set for renamed files.
+	OldPath string
+}
+
+// ChangedFilesFunc returns the files which changed between the fromSHA and
+// toSHA commits in the Git repository checked out at repoPath.
+type ChangedFilesFunc func(repoPath, fromSHA, toSHA string) ([]FileChange, error)
+
+// DefaultChangedFiles is the ChangedFilesFunc used by ChangedFiles. It is
+// set to the implementation of the gogit package when it is imported.
+var DefaultChangedFiles ChangedFilesFunc
+
+// ChangedFiles returns the files which changed between the fromSHA and toSHA
+// commits in the Git repository checked out at repoPath, sorted by path,
+// using DefaultChangedFiles. Renames are reported as a single FileRenamed
+// change. If fromSHA is empty, all files in toSHA are reported as added.
+func ChangedFiles(repoPath, fromSHA, toSHA string) ([]FileChange, error) {
+	if DefaultChangedFiles == nil {
+		return nil, errNoImplementation
+	}
+	return DefaultChangedFiles(repoPath, fromSHA, toSHA)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestAheadBehind(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(f AheadBehindFunc) { DefaultAheadBehind = f }(DefaultAheadBehind)
+
+	DefaultAheadBehind = nil
+	_, _, err := AheadBehind("repo", "main", "feature")
+	g.Expect(err).To(MatchError(errNoImplementation))
+
+	DefaultAheadBehind = func(repoPath, base, head string) (int, int, error) {
+		g.Expect(repoPath).To(Equal("repo"))
+		g.Expect(base).To(Equal("main"))
+		g.Expect(head).To(Equal("feature"))
+		return 3, 1, nil
+	}
+	ahead, behind, err := AheadBehind("repo", "main", "feature")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ahead).To(Equal(3))
+	g.Expect(behind).To(Equal(1))
+}
+
+func TestChangedFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(f ChangedFilesFunc) { DefaultChangedFiles = f }(DefaultChangedFiles)
+
+	DefaultChangedFiles = nil
+	_, err := ChangedFiles("repo", "from", "to")
+	g.Expect(err).To(MatchError(errNoImplementation))
+
+	want := []FileChange{{Type: FileRenamed, Path: "new", OldPath: "old"}}
+	DefaultChangedFiles = func(repoPath, fromSHA, toSHA string) ([]FileChange, error) {
+		g.Expect(repoPath).To(Equal("repo"))
+		g.Expect(fromSHA).To(Equal("from"))
+		g.Expect(toSHA).To(Equal("to"))
+		return want, nil
+	}
+	got, err := ChangedFiles("repo", "from", "to")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal(want))
+}
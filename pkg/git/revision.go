@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatRevision returns the revision for the given reference and hash, in
+// the '<ref>/<sha>' format of Git revisions. The ref is the short name of a
+// branch or tag, and may contain slashes. If ref is empty, "HEAD" is used.
+func FormatRevision(ref, sha string) string {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return ref + "/" + sha
+}
+
+// ParseRevision parses the reference and hash from the given revision in
+// the '<ref>/<sha>' format of Git revisions, or in the '<tag>@<digest>'
+// format of OCI revisions, in which case the digest is returned as the sha.
+// A revision consisting of only a hash or digest is returned with an empty
+// ref. It returns an error if the ref, hash or digest is missing, or if the
+// digest is not of the '<algorithm>:<encoded>' format. The hash itself is
+// not validated.
+func ParseRevision(revision string) (ref, sha string, err error) {
+	if revision == "" {
+		return "", "", fmt.Errorf("invalid revision: empty")
+	}
+	slash, at := strings.LastIndex(revision, "/"), strings.LastIndex(revision, "@")
+	if at > slash {
+		// OCI tags can not contain slashes, the digest follows the tag.
+		ref, sha = revision[:at], revision[at+1:]
+		if slash >= 0 || ref == "" {
+			return "", "", fmt.Errorf("invalid revision '%s': missing or invalid tag", revision)
+		}
+		if i := strings.Index(sha, ":"); i <= 0 || i == len(sha)-1 {
+			return "", "", fmt.Errorf("invalid revision '%s': digest must be of the format '<algorithm>:<encoded>'", revision)
+		}
+		return ref, sha, nil
+	}
+	if slash < 0 {
+		return "", revision, nil
+	}
+	ref, sha = revision[:slash], revision[slash+1:]
+	if ref == "" {
+		return "", "", fmt.Errorf("invalid revision '%s': missing reference", revision)
+	}
+	if sha == "" {
+		return "", "", fmt.Errorf("invalid revision '%s': missing hash", revision)
+	}
+	return ref, sha, nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFormatRevision(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(FormatRevision("main", "43d7eb9c49cdd49b2494efd481aea1166fc22b67")).To(Equal("main/43d7eb9c49cdd49b2494efd481aea1166fc22b67"))
+	g.Expect(FormatRevision("feature/branch", "commit")).To(Equal("feature/branch/commit"))
+	g.Expect(FormatRevision("", "commit")).To(Equal("HEAD/commit"))
+}
+
+func TestParseRevision(t *testing.T) {
+	tests := []struct {
+		name     string
+		revision string
+		wantRef  string
+		wantSHA  string
+		wantErr  string
+	}{
+		{
+			name:     "Git branch revision",
+			revision: "main/43d7eb9c49cdd49b2494efd481aea1166fc22b67",
+			wantRef:  "main",
+			wantSHA:  "43d7eb9c49cdd49b2494efd481aea1166fc22b67",
+		},
+		{
+			name:     "Git revision with slash in reference",
+			revision: "feature/branch/43d7eb9c49cdd49b2494efd481aea1166fc22b67",
+			wantRef:  "feature/branch",
+			wantSHA:  "43d7eb9c49cdd49b2494efd481aea1166fc22b67",
+		},
+		{
+			name:     "Git revision with @ in reference",
+			revision: "release@2022/43d7eb9c49cdd49b2494efd481aea1166fc22b67",
+			wantRef:  "release@2022",
+			wantSHA:  "43d7eb9c49cdd49b2494efd481aea1166fc22b67",
+		},
+		{
+			name:     "Hash only",
+			revision: "43d7eb9c49cdd49b2494efd481aea1166fc22b67",
+			wantSHA:  "43d7eb9c49cdd49b2494efd481aea1166fc22b67",
+		},
+		{
+			name:     "OCI revision",
+			revision: "6.1.4@sha256:3b6cdcc7adcc9a84d3214ee1c029543789d90b5ae69debe9efa3f66e982875de",
+			wantRef:  "6.1.4",
+			wantSHA:  "sha256:3b6cdcc7adcc9a84d3214ee1c029543789d90b5ae69debe9efa3f66e982875de",
+		},
+		{
+			name:     "Empty",
+			revision: "",
+			wantErr:  "invalid revision: empty",
+		},
+		{
+			name:     "Git revision without reference",
+			revision: "/43d7eb9c49cdd49b2494efd481aea1166fc22b67",
+			wantErr:  "invalid revision '/43d7eb9c49cdd49b2494efd481aea1166fc22b67': missing reference",
+		},
+		{
+			name:     "Git revision without hash",
+			revision: "main/",
+			wantErr:  "invalid revision 'main/': missing hash",
+		},
+		{
+			name:     "OCI revision without tag",
+			revision: "@sha256:3b6cdcc7",
+			wantErr:  "invalid revision '@sha256:3b6cdcc7': missing or invalid tag",
+		},
+		{
+			name:     "OCI revision with slash in tag",
+			revision: "org/6.1.4@sha256:3b6cdcc7",
+			wantErr:  "invalid revision 'org/6.1.4@sha256:3b6cdcc7': missing or invalid tag",
+		},
+		{
+			name:     "OCI revision without algorithm",
+			revision: "6.1.4@3b6cdcc7",
+			wantErr:  "invalid revision '6.1.4@3b6cdcc7': digest must be of the format '<algorithm>:<encoded>'",
+		},
+		{
+			name:     "OCI revision without encoded digest",
+			revision: "6.1.4@sha256:",
+			wantErr:  "invalid revision '6.1.4@sha256:': digest must be of the format '<algorithm>:<encoded>'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ref, sha, err := ParseRevision(tt.revision)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(ref).To(Equal(tt.wantRef))
+			g.Expect(sha).To(Equal(tt.wantSHA))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"regexp"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fluxcd/pkg/version"
+)
+
+// TagResolveConcurrency is the maximum number of goroutines LatestSemVerTag
+// uses to match and peel tags. Values lower than one resolve the tags
+// sequentially.
+var TagResolveConcurrency = runtime.NumCPU()
+
+// TagTimeFunc returns the timestamp of the commit the tag with the given
+// short name points to, which orders tags with an equal version. It may be
+// called concurrently.
+type TagTimeFunc func(name string) (time.Time, error)
+
+// LatestSemVerTag returns the short name of the tag with the highest
+// version matching the constraint and, if not nil, the filter. Tags which
+// are not a valid semver version are skipped. Tags with an equal version,
+// e.g. differing only by build metadata, are ordered by the timestamp
+// returned by tagTime, and by name if the timestamps are equal as well. It
+// returns an empty name if no tag matches.
+//
+// The tags are matched, and the tags with the highest version peeled, with
+// at most TagResolveConcurrency goroutines. The result does not depend on
+// the order of the names, and tagTime is only called for the tags with the
+// highest version.
+func LatestSemVerTag(names []string, constraint *semver.Constraints, filter *regexp.Regexp, tagTime TagTimeFunc) (string, error) {
+	versions := make([]*semver.Version, len(names))
+	_ = forEachConcurrently(len(names), TagResolveConcurrency, func(i int) error {
+		if filter != nil && !filter.MatchString(names[i]) {
+			return nil
+		}
+		v, err := version.ParseVersion(names[i])
+		if err != nil || !constraint.Check(v) {
+			return nil
+		}
+		versions[i] = v
+		return nil
+	})
+
+	var latest *semver.Version
+	var candidates []int
+	for i, v := range versions {
+		switch {
+		case v == nil:
+			continue
+		case latest == nil || v.GreaterThan(latest):
+			latest = v
+			candidates = []int{i}
+		case v.Equal(latest):
+			candidates = append(candidates, i)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return "", nil
+	case 1:
+		return names[candidates[0]], nil
+	}
+
+	times := make([]time.Time, len(candidates))
+	if err := forEachConcurrently(len(candidates), TagResolveConcurrency, func(i int) (err error) {
+		times[i], err = tagTime(names[candidates[i]])
+		return err
+	}); err != nil {
+		return "", err
+	}
+	best := 0
+	for i := 1; i < len(candidates); i++ {
+		if times[i].After(times[best]) ||
+			(times[i].Equal(times[best]) && names[candidates[i]] > names[candidates[best]]) {
+			best = i
+		}
+	}
+	return names[candidates[best]], nil
+}
+
+// forEachConcurrently calls fn for each index in [0, n) with at most the
+// given number of goroutines. It returns the error of the lowest index for
+// which fn failed, after all calls returned.
+func forEachConcurrently(n, concurrency int, fn func(i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+	errs := make([]error, n)
+	if concurrency <= 1 {
+		for i := 0; i < n; i++ {
+			errs[i] = fn(i)
+		}
+	} else {
+		var next int64 = -1
+		var wg sync.WaitGroup
+		wg.Add(concurrency)
+		for w := 0; w < concurrency; w++ {
+			go func() {
+				defer wg.Done()
+				for i := int(atomic.AddInt64(&next, 1)); i < n; i = int(atomic.AddInt64(&next, 1)) {
+					errs[i] = fn(i)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
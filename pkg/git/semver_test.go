@@ -0,0 +1,224 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fluxcd/pkg/version"
+	. "github.com/onsi/gomega"
+)
+
+// sequentialLatestSemVerTag is the sequential tag resolution of the
+// checkout strategies which LatestSemVerTag replaces.
+func sequentialLatestSemVerTag(names []string, constraint *semver.Constraints, filter *regexp.Regexp, times map[string]time.Time) string {
+	var matchedVersions semver.Collection
+	for _, tag := range names {
+		if filter != nil && !filter.MatchString(tag) {
+			continue
+		}
+		v, err := version.ParseVersion(tag)
+		if err != nil {
+			continue
+		}
+		if !constraint.Check(v) {
+			continue
+		}
+		matchedVersions = append(matchedVersions, v)
+	}
+	if len(matchedVersions) == 0 {
+		return ""
+	}
+	sort.SliceStable(matchedVersions, func(i, j int) bool {
+		left := matchedVersions[i]
+		right := matchedVersions[j]
+		if !left.Equal(right) {
+			return left.LessThan(right)
+		}
+		return times[left.Original()].Before(times[right.Original()])
+	})
+	return matchedVersions[len(matchedVersions)-1].Original()
+}
+
+// randomTags returns n random tag names with unique timestamps, including
+// versions differing only by build metadata and names which are not a
+// semver version.
+func randomTags(r *rand.Rand, n int) ([]string, map[string]time.Time) {
+	base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	names := make([]string, 0, n)
+	times := make(map[string]time.Time, n)
+	for len(names) < n {
+		var name string
+		switch r.Intn(10) {
+		case 0:
+			name = fmt.Sprintf("release-%d", r.Intn(1000))
+		case 1:
+			name = fmt.Sprintf("v%d.%d.%d+build.%d", r.Intn(5), r.Intn(5), r.Intn(5), r.Intn(100))
+		case 2:
+			name = fmt.Sprintf("v%d.%d.%d-rc.%d", r.Intn(5), r.Intn(5), r.Intn(5), r.Intn(5))
+		default:
+			name = fmt.Sprintf("v%d.%d.%d", r.Intn(5), r.Intn(5), r.Intn(5))
+		}
+		if _, ok := times[name]; ok {
+			continue
+		}
+		names = append(names, name)
+		times[name] = base.Add(time.Duration(r.Intn(1<<20)) * time.Second).Add(time.Duration(len(names)))
+	}
+	return names, times
+}
+
+func TestLatestSemVerTag(t *testing.T) {
+	times := map[string]time.Time{
+		"v1.0.0":         time.Unix(100, 0),
+		"v1.1.0+build.1": time.Unix(300, 0),
+		"v1.1.0+build.2": time.Unix(200, 0),
+		"v1.1.0+build.3": time.Unix(300, 0),
+		"app/v2.0.0":     time.Unix(100, 0),
+		"latest":         time.Unix(400, 0),
+	}
+	names := make([]string, 0, len(times))
+	for name := range times {
+		names = append(names, name)
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		filter     string
+		want       string
+	}{
+		{
+			name:       "equal versions ordered by time and name",
+			constraint: "1.x",
+			want:       "v1.1.0+build.3",
+		},
+		{
+			name:       "single match",
+			constraint: "<1.1.0",
+			want:       "v1.0.0",
+		},
+		{
+			name:       "no match",
+			constraint: ">=2.0.0",
+		},
+		{
+			name:       "filtered",
+			constraint: ">=2.0.0",
+			filter:     "^app/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c, err := semver.NewConstraint(tt.constraint)
+			g.Expect(err).ToNot(HaveOccurred())
+			filter, err := CompileTagFilter(tt.filter)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			got, err := LatestSemVerTag(names, c, filter, func(name string) (time.Time, error) {
+				return times[name], nil
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestLatestSemVerTag_error(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := semver.NewConstraint("1.x")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = LatestSemVerTag([]string{"v1.0.0+a", "v1.0.0+b", "v1.0.0+c"}, c, nil, func(name string) (time.Time, error) {
+		if name != "v1.0.0+a" {
+			return time.Time{}, fmt.Errorf("failed to peel '%s'", name)
+		}
+		return time.Time{}, nil
+	})
+	g.Expect(err).To(MatchError("failed to peel 'v1.0.0+b'"))
+}
+
+func TestLatestSemVerTag_sequential(t *testing.T) {
+	defer func(c int) { TagResolveConcurrency = c }(TagResolveConcurrency)
+
+	r := rand.New(rand.NewSource(1))
+	constraints := []string{"*", ">=1.0.0 <3.0.0", "~2.1", ">=1.0.0-0", ">=10.0.0"}
+	for i := 0; i < 10; i++ {
+		names, times := randomTags(r, 300)
+		tagTime := func(name string) (time.Time, error) {
+			t, ok := times[name]
+			if !ok {
+				return time.Time{}, errors.New("unknown tag")
+			}
+			return t, nil
+		}
+		for _, constraint := range constraints {
+			c, err := semver.NewConstraint(constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := sequentialLatestSemVerTag(names, c, nil, times)
+			for _, concurrency := range []int{0, 1, 4, 16} {
+				TagResolveConcurrency = concurrency
+				got, err := LatestSemVerTag(names, c, nil, tagTime)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != want {
+					t.Errorf("constraint '%s' with concurrency %d: got tag '%s', want '%s'", constraint, concurrency, got, want)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkLatestSemVerTag(b *testing.B) {
+	names, times := randomTags(rand.New(rand.NewSource(1)), 5000)
+	c, err := semver.NewConstraint(">=1.0.0-0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	tagTime := func(name string) (time.Time, error) {
+		return times[name], nil
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sequentialLatestSemVerTag(names, c, nil, times)
+		}
+	})
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			defer func(c int) { TagResolveConcurrency = c }(TagResolveConcurrency)
+			TagResolveConcurrency = concurrency
+			for i := 0; i < b.N; i++ {
+				if _, err := LatestSemVerTag(names, c, nil, tagTime); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -386,6 +386,17 @@ func TestCheckoutStrategyForImplementation_Proxied(t *testing.T) {
 
 			g.Expect(atomic.LoadInt32(&proxiedRequests) > 0).To(Equal(tt.wantUsedProxy))
 
+			// Listing the refs of the remote is proxied in the same way.
+			if tt.gitImpl == gogit.Implementation {
+				atomic.StoreInt32(&proxiedRequests, 0)
+				_, err = git.ListRefs(checkoutCtx, tt.url, authOpts)
+				if tt.wantError {
+					g.Expect(err).To(HaveOccurred())
+				} else {
+					g.Expect(err).ToNot(HaveOccurred())
+				}
+				g.Expect(atomic.LoadInt32(&proxiedRequests) > 0).To(Equal(tt.wantUsedProxy))
+			}
 		})
 	}
 }
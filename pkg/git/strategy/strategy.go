@@ -26,14 +26,44 @@ import (
 )
 
 // CheckoutStrategyForImplementation returns the CheckoutStrategy for the given
-// git.Implementation and git.CheckoutOptions.
+// git.Implementation and git.CheckoutOptions. The returned CheckoutStrategy
+// only allows hosts permitted by git.DefaultHostPolicy, serializes
+// checkouts of the same URL to the same path with git.DefaultCheckoutLocks,
+// is throttled per host by git.DefaultHostLimiter, obtains credentials
+// from the credential helper configured in the git.AuthOptions, and is
+// recorded in a tracing span. If
+// opts.VerifyWorktree is set, the worktree is verified after the checkout,
+// and if opts.FetchNotes is set, the notes refs are fetched. opts.RefSpecs
+// are only supported by the gogit.Implementation.
 func CheckoutStrategyForImplementation(ctx context.Context, impl git.Implementation, opts git.CheckoutOptions) (git.CheckoutStrategy, error) {
+	var (
+		s          git.CheckoutStrategy
+		verify     git.VerifyWorktreeFunc
+		fetchNotes git.FetchNotesFunc
+	)
+	if err := git.ValidateRefSpecs(opts.RefSpecs); err != nil {
+		return nil, err
+	}
 	switch impl {
 	case gogit.Implementation:
-		return gogit.CheckoutStrategyForOptions(ctx, opts), nil
+		s, verify, fetchNotes = gogit.CheckoutStrategyForOptions(ctx, opts), gogit.VerifyWorktree, gogit.FetchNotes
 	case libgit2.Implementation:
-		return libgit2.CheckoutStrategyForOptions(ctx, opts), nil
+		if len(opts.RefSpecs) > 0 {
+			return nil, fmt.Errorf("refspecs are not supported by the '%s' Git implementation", impl)
+		}
+		s, verify, fetchNotes = libgit2.CheckoutStrategyForOptions(ctx, opts), libgit2.VerifyWorktree, libgit2.FetchNotes
 	default:
 		return nil, fmt.Errorf("unsupported Git implementation '%s'", impl)
 	}
+	if opts.VerifyWorktree {
+		s = git.VerifyCheckoutStrategy(s, verify)
+	}
+	if opts.FetchNotes {
+		s = git.FetchNotesCheckoutStrategy(s, fetchNotes)
+	}
+	s = git.CredentialHelperCheckoutStrategy(s)
+	s = git.LimitCheckoutStrategy(s, git.DefaultHostLimiter)
+	s = git.LockCheckoutStrategy(s, git.DefaultCheckoutLocks)
+	s = git.HostPolicyCheckoutStrategy(s, git.DefaultHostPolicy)
+	return git.TraceCheckoutStrategy(s), nil
 }
@@ -0,0 +1,273 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	pgpSignatureHeader = "-----BEGIN PGP SIGNATURE-----"
+	sshSignatureHeader = "-----BEGIN SSH SIGNATURE-----"
+
+	// sshSignatureMagic is the preamble of SSH signatures, see
+	// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+	sshSignatureMagic = "SSHSIG"
+	// sshSignatureNamespace is the namespace of SSH signatures made by Git.
+	sshSignatureNamespace = "git"
+)
+
+// TagVerificationStatus is the outcome of the verification of the signature
+// of a tag.
+type TagVerificationStatus string
+
+const (
+	// TagSignatureValid indicates the signature of the tag was verified
+	// with one of the given keys.
+	TagSignatureValid TagVerificationStatus = "Valid"
+	// TagSignatureInvalid indicates the signature of the tag could not be
+	// verified with any of the given keys.
+	TagSignatureInvalid TagVerificationStatus = "Invalid"
+	// TagSignatureMissing indicates the tag is not signed, which includes
+	// lightweight tags as they do not have a tag object.
+	TagSignatureMissing TagVerificationStatus = "NoSignature"
+)
+
+// TagVerification is the result of the verification of the signature of a
+// tag.
+type TagVerification struct {
+	// Status is the outcome of the verification.
+	Status TagVerificationStatus
+	// KeyID identifies the key the signature was verified with. For PGP
+	// keys, this is the key ID. For SSH keys, the SHA256 fingerprint.
+	KeyID string
+	// Message describes why the signature could not be verified.
+	Message string
+}
+
+// TagVerificationError is returned when the signature of a tag is required
+// but missing or invalid.
+type TagVerificationError struct {
+	// Tag is the name of the tag.
+	Tag string
+	// Verification is the result of the verification.
+	Verification TagVerification
+}
+
+func (e *TagVerificationError) Error() string {
+	if e.Verification.Status == TagSignatureMissing {
+		return fmt.Sprintf("tag '%s' does not have a signature", e.Tag)
+	}
+	return fmt.Sprintf("signature verification of tag '%s' failed: %s", e.Tag, e.Verification.Message)
+}
+
+// VerifyTag verifies the signature of the tag object with the given raw
+// content against the key rings, which can either be armored PGP key rings
+// or SSH public keys in authorized_keys or allowed_signers format. A nil
+// raw content is treated as a lightweight tag.
+//
+// It returns the TagVerification, and a TagVerificationError if required
+// is true and the signature could not be verified.
+func VerifyTag(tag string, raw []byte, required bool, keyRings ...string) (*TagVerification, error) {
+	v := &TagVerification{Status: TagSignatureMissing}
+	if raw == nil {
+		v.Message = "lightweight tag does not have a tag object"
+	} else if payload, signature := SplitTagSignature(raw); signature == "" {
+		v.Message = "tag object does not have a signature"
+	} else {
+		keyID, err := verifySignature(payload, signature, keyRings...)
+		if err != nil {
+			v.Status = TagSignatureInvalid
+			v.Message = err.Error()
+		} else {
+			v.Status = TagSignatureValid
+			v.KeyID = keyID
+		}
+	}
+	if required && v.Status != TagSignatureValid {
+		return v, &TagVerificationError{Tag: tag, Verification: *v}
+	}
+	return v, nil
+}
+
+// SplitTagSignature splits the raw content of a tag object into the signed
+// payload and the signature, which starts at the last line of the message
+// with a PGP or SSH signature header. It returns an empty signature if the
+// tag is not signed.
+func SplitTagSignature(raw []byte) ([]byte, string) {
+	start := -1
+	for i := 0; i < len(raw); {
+		if bytes.HasPrefix(raw[i:], []byte(pgpSignatureHeader)) || bytes.HasPrefix(raw[i:], []byte(sshSignatureHeader)) {
+			start = i
+		}
+		eol := bytes.IndexByte(raw[i:], '\n')
+		if eol < 0 {
+			break
+		}
+		i += eol + 1
+	}
+	if start < 0 {
+		return raw, ""
+	}
+	return raw[:start], string(raw[start:])
+}
+
+// verifySignature verifies the armored signature over the payload with the
+// given key rings. It returns the ID of the key the signature was verified
+// with, or an error.
+func verifySignature(payload []byte, signature string, keyRings ...string) (string, error) {
+	switch {
+	case strings.HasPrefix(signature, pgpSignatureHeader):
+		for _, r := range keyRings {
+			if !strings.Contains(r, "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+				continue
+			}
+			keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(r))
+			if err != nil {
+				return "", fmt.Errorf("failed to read armored key ring: %w", err)
+			}
+			signer, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(payload), strings.NewReader(signature), nil)
+			if err == nil {
+				return fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint[12:20]), nil
+			}
+		}
+		return "", fmt.Errorf("failed to verify PGP signature with any of the given key rings")
+	case strings.HasPrefix(signature, sshSignatureHeader):
+		var keys []ssh.PublicKey
+		for _, r := range keyRings {
+			if strings.Contains(r, "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+				continue
+			}
+			keys = append(keys, parseSSHPublicKeys([]byte(r))...)
+		}
+		key, err := verifySSHSignature(payload, signature, keys)
+		if err != nil {
+			return "", err
+		}
+		return ssh.FingerprintSHA256(key), nil
+	default:
+		return "", fmt.Errorf("unsupported signature format")
+	}
+}
+
+// parseSSHPublicKeys parses the SSH public keys in authorized_keys or
+// allowed_signers format, of which the latter prefixes the keys with
+// principals. Invalid lines are ignored.
+func parseSSHPublicKeys(in []byte) []ssh.PublicKey {
+	var keys []ssh.PublicKey
+	for _, line := range bytes.Split(in, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey(line)
+		if err != nil {
+			// Retry without the principals of allowed_signers.
+			if i := bytes.IndexAny(line, " \t"); i > 0 {
+				key, _, _, _, err = ssh.ParseAuthorizedKey(line[i+1:])
+			}
+		}
+		if err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// verifySSHSignature verifies the armored SSH signature over the payload,
+// and returns the key of the signature if it is one of the given keys.
+func verifySSHSignature(payload []byte, signature string, keys []ssh.PublicKey) (ssh.PublicKey, error) {
+	block, _ := pem.Decode([]byte(signature))
+	if block == nil || block.Type != "SSH SIGNATURE" {
+		return nil, fmt.Errorf("failed to decode armored SSH signature")
+	}
+	if !bytes.HasPrefix(block.Bytes, []byte(sshSignatureMagic)) {
+		return nil, fmt.Errorf("invalid SSH signature preamble")
+	}
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(block.Bytes[len(sshSignatureMagic):], &sig); err != nil {
+		return nil, fmt.Errorf("failed to parse SSH signature: %w", err)
+	}
+	if sig.Version != 1 {
+		return nil, fmt.Errorf("unsupported SSH signature version %d", sig.Version)
+	}
+	if sig.Namespace != sshSignatureNamespace {
+		return nil, fmt.Errorf("SSH signature namespace '%s' does not match '%s'", sig.Namespace, sshSignatureNamespace)
+	}
+
+	pub, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH signature public key: %w", err)
+	}
+	trusted := false
+	for _, k := range keys {
+		if bytes.Equal(k.Marshal(), pub.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return nil, fmt.Errorf("SSH signature key '%s' is not one of the given keys", ssh.FingerprintSHA256(pub))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported SSH signature hash algorithm '%s'", sig.HashAlgorithm)
+	}
+	h.Write(payload)
+
+	var s ssh.Signature
+	if err = ssh.Unmarshal(sig.Signature, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse SSH signature blob: %w", err)
+	}
+	if err = pub.Verify(sshSignedData(sig.Namespace, sig.HashAlgorithm, h.Sum(nil)), &s); err != nil {
+		return nil, fmt.Errorf("failed to verify SSH signature: %w", err)
+	}
+	return pub, nil
+}
+
+// sshSignedData returns the data signed by an SSH signature over a message
+// with the given digest.
+func sshSignedData(namespace, hashAlgorithm string, digest []byte) []byte {
+	return append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{namespace, "", hashAlgorithm, digest})...)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+const testTagPayload = `object 1b6a1aac8a03fbd8a0b1c5d1ab2e1ac2a5e2d1a0
+type commit
+tag v1.0.0
+tagger Jane Doe <jane@example.com> 1654083600 +0000
+
+Release v1.0.0
+`
+
+// newPGPEntity returns a new PGP entity and its armored public key.
+func newPGPEntity(t *testing.T) (*openpgp.Entity, string) {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("Jane Doe", "", "jane@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return entity, b.String()
+}
+
+// signPGP returns the armored detached PGP signature over the payload.
+func signPGP(t *testing.T, entity *openpgp.Entity, payload string) string {
+	t.Helper()
+
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, entity, strings.NewReader(payload), nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.String() + "\n"
+}
+
+// newSSHSigner returns a new SSH signer and its public key in
+// authorized_keys format.
+func newSSHSigner(t *testing.T) (ssh.Signer, string) {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+}
+
+// signSSH returns the armored SSH signature over the payload, as created
+// by "ssh-keygen -Y sign -n <namespace>".
+func signSSH(t *testing.T, signer ssh.Signer, namespace, payload string) string {
+	t.Helper()
+
+	digest := sha512.Sum512([]byte(payload))
+	sig, err := signer.Sign(rand.Reader, sshSignedData(namespace, "sha512", digest[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, signer.PublicKey().Marshal(), namespace, "", "sha512", ssh.Marshal(sig)})...)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "SSH SIGNATURE", Bytes: blob}))
+}
+
+func TestSplitTagSignature(t *testing.T) {
+	g := NewWithT(t)
+
+	payload, signature := SplitTagSignature([]byte(testTagPayload))
+	g.Expect(string(payload)).To(Equal(testTagPayload))
+	g.Expect(signature).To(BeEmpty())
+
+	sig := "-----BEGIN SSH SIGNATURE-----\nU1NIU0lH\n-----END SSH SIGNATURE-----\n"
+	payload, signature = SplitTagSignature([]byte(testTagPayload + sig))
+	g.Expect(string(payload)).To(Equal(testTagPayload))
+	g.Expect(signature).To(Equal(sig))
+
+	// Only the last signature header starts the signature.
+	quoted := testTagPayload + "-----BEGIN PGP SIGNATURE-----\nquoted\n"
+	payload, signature = SplitTagSignature([]byte(quoted + sig))
+	g.Expect(string(payload)).To(Equal(quoted))
+	g.Expect(signature).To(Equal(sig))
+}
+
+func TestVerifyTag(t *testing.T) {
+	entity, pgpKey := newPGPEntity(t)
+	_, otherPGPKey := newPGPEntity(t)
+	signer, sshKey := newSSHSigner(t)
+	_, otherSSHKey := newSSHSigner(t)
+
+	pgpKeyID := strings.ToUpper(entity.PrimaryKey.KeyIdString())
+	sshKeyID := ssh.FingerprintSHA256(signer.PublicKey())
+
+	tests := []struct {
+		name      string
+		raw       []byte
+		keyRings  []string
+		required  bool
+		want      *TagVerification
+		wantError bool
+	}{
+		{
+			name:     "valid PGP signature",
+			raw:      []byte(testTagPayload + signPGP(t, entity, testTagPayload)),
+			keyRings: []string{otherPGPKey, sshKey, pgpKey},
+			required: true,
+			want:     &TagVerification{Status: TagSignatureValid, KeyID: pgpKeyID},
+		},
+		{
+			name:      "PGP signature with mismatched key",
+			raw:       []byte(testTagPayload + signPGP(t, entity, testTagPayload)),
+			keyRings:  []string{otherPGPKey},
+			required:  true,
+			want:      &TagVerification{Status: TagSignatureInvalid, Message: "failed to verify PGP signature with any of the given key rings"},
+			wantError: true,
+		},
+		{
+			name:     "PGP signature over other payload",
+			raw:      []byte(strings.Replace(testTagPayload, "v1.0.0", "v1.0.1", -1) + signPGP(t, entity, testTagPayload)),
+			keyRings: []string{pgpKey},
+			want:     &TagVerification{Status: TagSignatureInvalid, Message: "failed to verify PGP signature with any of the given key rings"},
+		},
+		{
+			name:     "valid SSH signature",
+			raw:      []byte(testTagPayload + signSSH(t, signer, "git", testTagPayload)),
+			keyRings: []string{pgpKey, otherSSHKey + sshKey},
+			required: true,
+			want:     &TagVerification{Status: TagSignatureValid, KeyID: sshKeyID},
+		},
+		{
+			name:     "valid SSH signature with allowed signers",
+			raw:      []byte(testTagPayload + signSSH(t, signer, "git", testTagPayload)),
+			keyRings: []string{"# allowed signers\njane@example.com " + sshKey},
+			required: true,
+			want:     &TagVerification{Status: TagSignatureValid, KeyID: sshKeyID},
+		},
+		{
+			name:      "SSH signature with mismatched key",
+			raw:       []byte(testTagPayload + signSSH(t, signer, "git", testTagPayload)),
+			keyRings:  []string{otherSSHKey},
+			required:  true,
+			want:      &TagVerification{Status: TagSignatureInvalid, Message: "SSH signature key '" + sshKeyID + "' is not one of the given keys"},
+			wantError: true,
+		},
+		{
+			name:     "SSH signature with other namespace",
+			raw:      []byte(testTagPayload + signSSH(t, signer, "file", testTagPayload)),
+			keyRings: []string{sshKey},
+			want:     &TagVerification{Status: TagSignatureInvalid, Message: "SSH signature namespace 'file' does not match 'git'"},
+		},
+		{
+			name:     "SSH signature over other payload",
+			raw:      []byte(strings.Replace(testTagPayload, "v1.0.0", "v1.0.1", -1) + signSSH(t, signer, "git", testTagPayload)),
+			keyRings: []string{sshKey},
+			want:     &TagVerification{Status: TagSignatureInvalid, Message: "failed to verify SSH signature: ssh: signature did not verify"},
+		},
+		{
+			name:     "unsigned tag object",
+			raw:      []byte(testTagPayload),
+			keyRings: []string{pgpKey},
+			want:     &TagVerification{Status: TagSignatureMissing, Message: "tag object does not have a signature"},
+		},
+		{
+			name:      "required signature of lightweight tag",
+			keyRings:  []string{pgpKey},
+			required:  true,
+			want:      &TagVerification{Status: TagSignatureMissing, Message: "lightweight tag does not have a tag object"},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := VerifyTag("v1.0.0", tt.raw, tt.required, tt.keyRings...)
+			g.Expect(got).To(Equal(tt.want))
+			if !tt.wantError {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			var verr *TagVerificationError
+			g.Expect(errors.As(err, &verr)).To(BeTrue())
+			g.Expect(verr.Tag).To(Equal("v1.0.0"))
+			g.Expect(verr.Verification).To(Equal(*tt.want))
+		})
+	}
+}
+
+func TestTagVerificationError_Error(t *testing.T) {
+	g := NewWithT(t)
+
+	err := &TagVerificationError{Tag: "v1.0.0", Verification: TagVerification{Status: TagSignatureMissing}}
+	g.Expect(err.Error()).To(Equal("tag 'v1.0.0' does not have a signature"))
+
+	err = &TagVerificationError{Tag: "v1.0.0", Verification: TagVerification{Status: TagSignatureInvalid, Message: "key mismatch"}}
+	g.Expect(err.Error()).To(Equal("signature verification of tag 'v1.0.0' failed: key mismatch"))
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fluxcd/pkg/version"
+)
+
+// TagInfo is a tag of a remote repository.
+type TagInfo struct {
+	// Name of the tag, e.g. "v1.0.0".
+	Name string
+	// Hash of the commit the tag points to, with annotated tags peeled to
+	// the tagged commit.
+	Hash string
+}
+
+// FilterTags returns the tags matching the given semver constraint, sorted
+// by version. Tags which are not a valid semver version are skipped. If the
+// constraint is empty, all tags are returned sorted by name.
+func FilterTags(tags []TagInfo, constraint string) ([]TagInfo, error) {
+	if constraint == "" {
+		result := append([]TagInfo{}, tags...)
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Name < result[j].Name
+		})
+		return result, nil
+	}
+
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("semver parse error: %w", err)
+	}
+	type match struct {
+		tag     TagInfo
+		version *semver.Version
+	}
+	var matches []match
+	for _, t := range tags {
+		v, err := version.ParseVersion(t.Name)
+		if err != nil {
+			continue
+		}
+		if c.Check(v) {
+			matches = append(matches, match{tag: t, version: v})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if !matches[i].version.Equal(matches[j].version) {
+			return matches[i].version.LessThan(matches[j].version)
+		}
+		return matches[i].tag.Name < matches[j].tag.Name
+	})
+
+	result := make([]TagInfo, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, m.tag)
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFilterTags(t *testing.T) {
+	tags := []TagInfo{
+		{Name: "v2.0.0", Hash: "c"},
+		{Name: "v1.1.0", Hash: "b"},
+		{Name: "latest", Hash: "d"},
+		{Name: "1.0.0", Hash: "a"},
+		{Name: "v1.1.0-rc.1", Hash: "e"},
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		want       []string
+		wantErr    string
+	}{
+		{
+			name: "no constraint",
+			want: []string{"1.0.0", "latest", "v1.1.0", "v1.1.0-rc.1", "v2.0.0"},
+		},
+		{
+			name:       "range",
+			constraint: ">=1.0.0 <2.0.0",
+			want:       []string{"1.0.0", "v1.1.0"},
+		},
+		{
+			name:       "range with pre-releases",
+			constraint: ">=1.0.0-0",
+			want:       []string{"1.0.0", "v1.1.0-rc.1", "v1.1.0", "v2.0.0"},
+		},
+		{
+			name:       "no match",
+			constraint: ">=3.0.0",
+			want:       []string{},
+		},
+		{
+			name:       "invalid constraint",
+			constraint: "invalid",
+			wantErr:    "semver parse error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := FilterTags(tags, tt.constraint)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			names := []string{}
+			for _, tag := range got {
+				names = append(names, tag.Name)
+			}
+			g.Expect(names).To(Equal(tt.want))
+		})
+	}
+}
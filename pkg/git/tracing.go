@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/fluxcd/source-controller/internal/tracing"
+)
+
+// NoOpKey is the span attribute which tells if a checkout was skipped, as
+// the remote revision matched the last revision.
+const NoOpKey = attribute.Key("git.noop")
+
+// TraceCheckoutStrategy returns a CheckoutStrategy which records the
+// checkout of the given CheckoutStrategy in a "git.checkout" tracing span.
+func TraceCheckoutStrategy(s CheckoutStrategy) CheckoutStrategy {
+	return &tracedCheckoutStrategy{strategy: s}
+}
+
+type tracedCheckoutStrategy struct {
+	strategy CheckoutStrategy
+}
+
+func (c *tracedCheckoutStrategy) Checkout(ctx context.Context, path, url string, opts *AuthOptions) (*Commit, error) {
+	return CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+func (c *tracedCheckoutStrategy) CheckoutWithResult(ctx context.Context, path, url string, opts *AuthOptions) (result *CheckoutResult, err error) {
+	attrs := []attribute.KeyValue{tracing.HostKey.String(hostFromURL(url))}
+	if opts != nil {
+		attrs = append(attrs, tracing.TransportKey.String(string(opts.Transport)))
+	}
+	ctx, span := tracing.Start(ctx, "git.checkout", attrs...)
+	defer func() {
+		if result != nil {
+			span.SetAttributes(tracing.BytesKey.Int64(int64(result.Stats.ReceivedBytes)), NoOpKey.Bool(result.NoOp))
+		}
+		tracing.End(span, err)
+	}()
+	return CheckoutWithResult(ctx, c.strategy, path, url, opts)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/fluxcd/source-controller/internal/tracing"
+)
+
+func TestTraceCheckoutStrategy(t *testing.T) {
+	concrete := &Commit{Hash: Hash("abc123"), Reference: "refs/heads/main", Encoded: []byte("encoded")}
+
+	tests := []struct {
+		name        string
+		strategy    CheckoutStrategy
+		wantAttrs   []attribute.KeyValue
+		wantOutcome string
+		wantStatus  codes.Code
+	}{
+		{
+			name: "successful checkout",
+			strategy: &resultCheckoutStrategy{result: &CheckoutResult{
+				Commit: concrete, Stats: TransportMetrics{ReceivedBytes: 1024},
+			}},
+			wantAttrs: []attribute.KeyValue{
+				tracing.HostKey.String("example.com:8443"),
+				tracing.TransportKey.String("https"),
+				tracing.BytesKey.Int64(1024),
+				NoOpKey.Bool(false),
+			},
+			wantOutcome: tracing.OutcomeSuccess,
+			wantStatus:  codes.Unset,
+		},
+		{
+			name:     "failed checkout",
+			strategy: &mockCheckoutStrategy{err: errors.New("checkout failed")},
+			wantAttrs: []attribute.KeyValue{
+				tracing.HostKey.String("example.com:8443"),
+				tracing.TransportKey.String("https"),
+			},
+			wantOutcome: tracing.OutcomeError,
+			wantStatus:  codes.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			prev := otel.GetTracerProvider()
+			otel.SetTracerProvider(tp)
+			defer otel.SetTracerProvider(prev)
+
+			s := TraceCheckoutStrategy(tt.strategy)
+			_, _ = s.Checkout(context.TODO(), "/tmp/checkout", "https://example.com:8443/org/repo", &AuthOptions{Transport: HTTPS})
+
+			spans := exporter.GetSpans()
+			g.Expect(spans).To(HaveLen(1))
+			span := spans[0]
+			g.Expect(span.Name).To(Equal("git.checkout"))
+			g.Expect(span.Status.Code).To(Equal(tt.wantStatus))
+			for _, want := range tt.wantAttrs {
+				g.Expect(span.Attributes).To(ContainElement(want))
+			}
+			g.Expect(span.Attributes).To(ContainElement(tracing.OutcomeKey.String(tt.wantOutcome)))
+		})
+	}
+}
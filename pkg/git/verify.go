@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fluxcd/source-controller/internal/tracing"
+)
+
+// WorktreeEntry is a file in the tree of a commit.
+type WorktreeEntry struct {
+	// Path of the file relative to the root of the worktree, separated by
+	// slashes.
+	Path string
+	// Hash is the hex encoded object hash of the blob of the file.
+	Hash string
+	// Symlink indicates the blob contains the target of a symbolic link.
+	Symlink bool
+}
+
+// WorktreeMismatchError is returned when files in a worktree are missing or
+// do not match the blobs in the tree of the checked out commit.
+type WorktreeMismatchError struct {
+	// Commit the worktree was verified against.
+	Commit string
+	// Paths of the mismatching files, sorted.
+	Paths []string
+}
+
+func (e *WorktreeMismatchError) Error() string {
+	return fmt.Sprintf("worktree does not match tree of commit '%s', %d mismatching file(s): %s",
+		e.Commit, len(e.Paths), strings.Join(e.Paths, ", "))
+}
+
+// VerifyWorktree confirms the content of each of the given entries of the
+// tree of commit matches the file at its path in the worktree at dir. It
+// returns a WorktreeMismatchError listing the files which are missing or of
+// which the blob hash does not match.
+func VerifyWorktree(dir, commit string, entries []WorktreeEntry) error {
+	var mismatches []string
+	for _, e := range entries {
+		hash, err := worktreeBlobHash(filepath.Join(dir, filepath.FromSlash(e.Path)), e.Symlink)
+		if err != nil {
+			if os.IsNotExist(err) {
+				mismatches = append(mismatches, e.Path)
+				continue
+			}
+			return fmt.Errorf("failed to hash '%s': %w", e.Path, err)
+		}
+		if hash != e.Hash {
+			mismatches = append(mismatches, e.Path)
+		}
+	}
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		return &WorktreeMismatchError{Commit: commit, Paths: mismatches}
+	}
+	return nil
+}
+
+// worktreeBlobHash returns the hex encoded object hash of the file at path
+// as a blob. For symbolic links, the blob contains the link target.
+func worktreeBlobHash(path string, symlink bool) (string, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if symlink {
+		if fi.Mode()&os.ModeSymlink == 0 {
+			// A file replacing the link can not be a match, hash an
+			// empty string instead of following it.
+			return "", nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		h := newBlobHasher(int64(len(target)))
+		h.Write([]byte(filepath.ToSlash(target)))
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	if !fi.Mode().IsRegular() {
+		return "", nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := newBlobHasher(fi.Size())
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newBlobHasher returns a hash.Hash computing the object hash of a blob of
+// the given size, after its content has been written to it.
+func newBlobHasher(size int64) hash.Hash {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", size)
+	return h
+}
+
+// VerifyWorktreeFunc verifies the worktree at path against the tree of the
+// given commit.
+type VerifyWorktreeFunc func(path string, commit Hash) error
+
+// VerifyCheckoutStrategy returns a CheckoutStrategy which verifies the
+// worktree using the given VerifyWorktreeFunc after delegating the checkout
+// to the given CheckoutStrategy. Partial commits, for which no checkout was
+// performed, are not verified.
+func VerifyCheckoutStrategy(s CheckoutStrategy, verify VerifyWorktreeFunc) CheckoutStrategy {
+	return &verifiedCheckoutStrategy{strategy: s, verify: verify}
+}
+
+type verifiedCheckoutStrategy struct {
+	strategy CheckoutStrategy
+	verify   VerifyWorktreeFunc
+}
+
+func (c *verifiedCheckoutStrategy) Checkout(ctx context.Context, path, url string, opts *AuthOptions) (*Commit, error) {
+	return CheckoutResultCommit(c.CheckoutWithResult(ctx, path, url, opts))
+}
+
+func (c *verifiedCheckoutStrategy) CheckoutWithResult(ctx context.Context, path, url string, opts *AuthOptions) (*CheckoutResult, error) {
+	result, err := CheckoutWithResult(ctx, c.strategy, path, url, opts)
+	if err != nil || result.Commit == nil || !IsConcreteCommit(*result.Commit) {
+		return result, err
+	}
+	_, span := tracing.Start(ctx, "git.verify", tracing.HostKey.String(hostFromURL(url)))
+	err = c.verify(path, result.Commit.Hash)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify worktree: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/onsi/gomega"
+)
+
+func TestVerifyWorktree(t *testing.T) {
+	blobHash := func(content string) string {
+		return plumbing.ComputeHash(plumbing.BlobObject, []byte(content)).String()
+	}
+	entries := []WorktreeEntry{
+		{Path: "file", Hash: blobHash("content")},
+		{Path: "dir/nested", Hash: blobHash("nested")},
+		{Path: "link", Hash: blobHash("file"), Symlink: true},
+	}
+
+	tests := []struct {
+		name      string
+		mutate    func(dir string) error
+		wantPaths []string
+	}{
+		{
+			name: "matching worktree",
+		},
+		{
+			name: "untracked file",
+			mutate: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "untracked"), []byte("untracked"), 0o600)
+			},
+		},
+		{
+			name: "modified file",
+			mutate: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "dir", "nested"), []byte("corrupted"), 0o600)
+			},
+			wantPaths: []string{"dir/nested"},
+		},
+		{
+			name: "missing file",
+			mutate: func(dir string) error {
+				return os.Remove(filepath.Join(dir, "file"))
+			},
+			wantPaths: []string{"file"},
+		},
+		{
+			name: "symlink replaced by file",
+			mutate: func(dir string) error {
+				if err := os.Remove(filepath.Join(dir, "link")); err != nil {
+					return err
+				}
+				return os.WriteFile(filepath.Join(dir, "link"), []byte("file"), 0o600)
+			},
+			wantPaths: []string{"link"},
+		},
+		{
+			name: "multiple mismatches",
+			mutate: func(dir string) error {
+				if err := os.WriteFile(filepath.Join(dir, "file"), []byte("corrupted"), 0o600); err != nil {
+					return err
+				}
+				return os.RemoveAll(filepath.Join(dir, "dir"))
+			},
+			wantPaths: []string{"dir/nested", "file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" {
+				t.Skip("symlinks are not supported")
+			}
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			g.Expect(os.WriteFile(filepath.Join(dir, "file"), []byte("content"), 0o600)).To(Succeed())
+			g.Expect(os.Mkdir(filepath.Join(dir, "dir"), 0o700)).To(Succeed())
+			g.Expect(os.WriteFile(filepath.Join(dir, "dir", "nested"), []byte("nested"), 0o600)).To(Succeed())
+			g.Expect(os.Symlink("file", filepath.Join(dir, "link"))).To(Succeed())
+
+			if tt.mutate != nil {
+				g.Expect(tt.mutate(dir)).To(Succeed())
+			}
+
+			err := VerifyWorktree(dir, "abc123", entries)
+			if tt.wantPaths == nil {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			var mismatchErr *WorktreeMismatchError
+			g.Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			g.Expect(mismatchErr.Commit).To(Equal("abc123"))
+			g.Expect(mismatchErr.Paths).To(Equal(tt.wantPaths))
+		})
+	}
+}
+
+type mockCheckoutStrategy struct {
+	commit *Commit
+	err    error
+}
+
+func (m *mockCheckoutStrategy) Checkout(_ context.Context, _, _ string, _ *AuthOptions) (*Commit, error) {
+	return m.commit, m.err
+}
+
+func TestVerifyCheckoutStrategy(t *testing.T) {
+	concrete := &Commit{Hash: Hash("abc123"), Encoded: []byte("encoded")}
+	partial := &Commit{Hash: Hash("abc123")}
+	verifyErr := &WorktreeMismatchError{Commit: "abc123", Paths: []string{"file"}}
+
+	tests := []struct {
+		name        string
+		commit      *Commit
+		checkoutErr error
+		verifyErr   error
+		wantVerify  bool
+		wantErr     error
+	}{
+		{
+			name:       "verifies concrete commit",
+			commit:     concrete,
+			wantVerify: true,
+		},
+		{
+			name:       "returns verification error",
+			commit:     concrete,
+			verifyErr:  verifyErr,
+			wantVerify: true,
+			wantErr:    verifyErr,
+		},
+		{
+			name:   "skips partial commit",
+			commit: partial,
+		},
+		{
+			name:        "skips failed checkout",
+			checkoutErr: errors.New("checkout failed"),
+			wantErr:     errors.New("checkout failed"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var verified bool
+			s := VerifyCheckoutStrategy(&mockCheckoutStrategy{commit: tt.commit, err: tt.checkoutErr}, func(path string, commit Hash) error {
+				verified = true
+				g.Expect(path).To(Equal("/tmp/checkout"))
+				g.Expect(commit).To(Equal(tt.commit.Hash))
+				return tt.verifyErr
+			})
+
+			cc, err := s.Checkout(context.TODO(), "/tmp/checkout", "https://example.com", nil)
+			g.Expect(verified).To(Equal(tt.wantVerify))
+			if tt.wantErr != nil {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr.Error()))
+				g.Expect(cc).To(BeNil())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc).To(Equal(tt.commit))
+		})
+	}
+}
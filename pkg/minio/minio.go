@@ -17,9 +17,14 @@ limitations under the License.
 package minio
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -29,6 +34,20 @@ import (
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 )
 
+var (
+	// ChunkThreshold is the size in bytes above which objects are
+	// downloaded in chunks using HTTP Range requests, of which each chunk
+	// is retried on failure. Zero disables chunked downloads.
+	ChunkThreshold int64
+	// ChunkSize is the size in bytes of the chunks of a chunked download.
+	ChunkSize int64 = 16 << 20
+	// ChunkMaxRetries is the maximum number of retries of a failed chunk.
+	ChunkMaxRetries = 3
+	// ChunkRetryDelay is the duration to wait before retrying a failed
+	// chunk.
+	ChunkRetryDelay = time.Second
+)
+
 // MinioClient is a minimal Minio client for fetching files from S3 compatible
 // storage APIs.
 type MinioClient struct {
@@ -82,13 +101,20 @@ func ValidateSecret(secret *corev1.Secret) error {
 }
 
 // FGetObject gets the object from the provided object storage bucket, and
-// writes it to targetPath.
+// writes it to targetPath. Objects larger than the ChunkThreshold are
+// downloaded in chunks of ChunkSize.
 // It returns the etag of the successfully fetched file, or any error.
 func (c *MinioClient) FGetObject(ctx context.Context, bucketName, objectName, localPath string) (string, error) {
 	stat, err := c.Client.StatObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
 	if err != nil {
 		return "", err
 	}
+	if ChunkThreshold > 0 && ChunkSize > 0 && stat.Size > ChunkThreshold {
+		if err = c.fGetObjectChunked(ctx, bucketName, objectName, localPath, stat); err != nil {
+			return "", err
+		}
+		return stat.ETag, nil
+	}
 	opts := minio.GetObjectOptions{}
 	if err = opts.SetMatchETag(stat.ETag); err != nil {
 		return "", err
@@ -99,13 +125,95 @@ func (c *MinioClient) FGetObject(ctx context.Context, bucketName, objectName, lo
 	return stat.ETag, nil
 }
 
+// fGetObjectChunked downloads the object in chunks of ChunkSize using
+// range requests, and writes them to localPath. Each chunk is retried up to
+// ChunkMaxRetries times. The ETag of the object must match the given
+// ObjectInfo for every chunk, to ensure the chunks belong to the same
+// version of the object. The chunks are written to a temporary file next to
+// localPath, which is only renamed to localPath once all chunks have been
+// written.
+func (c *MinioClient) fGetObjectChunked(ctx context.Context, bucketName, objectName, localPath string, stat minio.ObjectInfo) (err error) {
+	dir := filepath.Dir(localPath)
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(localPath)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	var chunk bytes.Buffer
+	for start := int64(0); start < stat.Size; start += ChunkSize {
+		end := start + ChunkSize - 1
+		if end >= stat.Size {
+			end = stat.Size - 1
+		}
+		for retries := 0; ; retries++ {
+			chunk.Reset()
+			err = c.getObjectRange(ctx, bucketName, objectName, stat.ETag, start, end, &chunk)
+			if err == nil || retries >= ChunkMaxRetries || c.ObjectIsNotFound(err) {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(ChunkRetryDelay):
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get bytes %d-%d of object '%s': %w", start, end, objectName, err)
+		}
+		if _, err = f.Write(chunk.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), localPath)
+}
+
+// getObjectRange writes the bytes from start to end (inclusive) of the
+// object with the given ETag to w.
+func (c *MinioClient) getObjectRange(ctx context.Context, bucketName, objectName, etag string, start, end int64, w io.Writer) error {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetMatchETag(etag); err != nil {
+		return err
+	}
+	if err := opts.SetRange(start, end); err != nil {
+		return err
+	}
+	obj, err := c.Client.GetObject(ctx, bucketName, objectName, opts)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	n, err := io.Copy(w, obj)
+	if err != nil {
+		return err
+	}
+	if want := end - start + 1; n != want {
+		return fmt.Errorf("received %d bytes instead of %d", n, want)
+	}
+	return nil
+}
+
 // VisitObjects iterates over the items in the provided object storage
-// bucket, calling visit for every item.
+// bucket, calling visit for every item with the given prefix, with its size
+// in bytes.
 // If the underlying client or the visit callback returns an error,
 // it returns early.
-func (c *MinioClient) VisitObjects(ctx context.Context, bucketName string, visit func(key, etag string) error) error {
+func (c *MinioClient) VisitObjects(ctx context.Context, bucketName string, prefix string, visit func(key, etag string, size int64) error) error {
 	for object := range c.Client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
 		Recursive: true,
+		Prefix:    prefix,
 		UseV1:     s3utils.IsGoogleEndpoint(*c.Client.EndpointURL()),
 	}) {
 		if object.Err != nil {
@@ -113,7 +221,7 @@ func (c *MinioClient) VisitObjects(ctx context.Context, bucketName string, visit
 			return err
 		}
 
-		if err := visit(object.Key, object.ETag); err != nil {
+		if err := visit(object.Key, object.ETag, object.Size); err != nil {
 			return err
 		}
 	}
@@ -17,16 +17,23 @@ limitations under the License.
 package minio
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/rand"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/fluxcd/pkg/apis/meta"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	s3mock "github.com/fluxcd/source-controller/internal/mock/s3"
 	"github.com/fluxcd/source-controller/pkg/sourceignore"
 
 	"github.com/google/uuid"
@@ -157,10 +164,160 @@ func TestFGetObjectNotExists(t *testing.T) {
 	assert.Check(t, minioClient.ObjectIsNotFound(err))
 }
 
+func TestFGetObjectChunked(t *testing.T) {
+	content := make([]byte, 10*1024+100)
+	_, err := rand.Read(content)
+	assert.NilError(t, err)
+
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+	server := s3mock.NewServer("chunked")
+	server.Objects = []*s3mock.Object{
+		{Key: "large.bin", ContentType: "application/octet-stream", Content: content, LastModified: time.Now()},
+	}
+	server.TruncateResponse = func(r *http.Request) bool {
+		if r.Method != http.MethodGet || r.Header.Get("Range") == "" {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		// Fail the first attempt of the second chunk.
+		return len(ranges) == 2
+	}
+	server.Start()
+	defer server.Stop()
+
+	threshold, size, delay := ChunkThreshold, ChunkSize, ChunkRetryDelay
+	ChunkThreshold, ChunkSize, ChunkRetryDelay = 1024, 4096, 0
+	defer func() {
+		ChunkThreshold, ChunkSize, ChunkRetryDelay = threshold, size, delay
+	}()
+
+	client, err := NewClient(&sourcev1.Bucket{
+		Spec: sourcev1.BucketSpec{
+			BucketName: "chunked",
+			Endpoint:   strings.TrimPrefix(server.HTTPAddress(), "http://"),
+			Insecure:   true,
+		},
+	}, nil)
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "large.bin")
+	etag, err := client.FGetObject(context.TODO(), "chunked", "large.bin", path)
+	assert.NilError(t, err)
+	assert.Equal(t, etag, fmt.Sprintf("%x", md5.Sum(content)))
+
+	got, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Assert(t, bytes.Equal(got, content))
+	assert.DeepEqual(t, ranges, []string{
+		"bytes=0-4095",
+		"bytes=4096-8191",
+		"bytes=4096-8191",
+		"bytes=8192-10339",
+	})
+}
+
+func TestFGetObjectChunked_failure(t *testing.T) {
+	content := make([]byte, 10*1024+100)
+	_, err := rand.Read(content)
+	assert.NilError(t, err)
+
+	server := s3mock.NewServer("chunked")
+	server.Objects = []*s3mock.Object{
+		{Key: "large.bin", ContentType: "application/octet-stream", Content: content, LastModified: time.Now()},
+	}
+	server.TruncateResponse = func(r *http.Request) bool {
+		// Fail all attempts of the second chunk.
+		return r.Method == http.MethodGet && r.Header.Get("Range") == "bytes=4096-8191"
+	}
+	server.Start()
+	defer server.Stop()
+
+	threshold, size, delay := ChunkThreshold, ChunkSize, ChunkRetryDelay
+	ChunkThreshold, ChunkSize, ChunkRetryDelay = 1024, 4096, 0
+	defer func() {
+		ChunkThreshold, ChunkSize, ChunkRetryDelay = threshold, size, delay
+	}()
+
+	client, err := NewClient(&sourcev1.Bucket{
+		Spec: sourcev1.BucketSpec{
+			BucketName: "chunked",
+			Endpoint:   strings.TrimPrefix(server.HTTPAddress(), "http://"),
+			Insecure:   true,
+		},
+	}, nil)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	_, err = client.FGetObject(context.TODO(), "chunked", "large.bin", filepath.Join(dir, "large.bin"))
+	assert.ErrorContains(t, err, "failed to get bytes 4096-8191")
+
+	entries, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 0)
+}
+
+func TestNewClientGCPHMAC(t *testing.T) {
+	content := []byte("key: value")
+	server := s3mock.NewServer("gcs-hmac")
+	server.AccessKey = "GOOG1EXAMPLEACCESSKEY"
+	server.Objects = []*s3mock.Object{
+		{Key: objectName, ContentType: "text/x-yaml", Content: content, LastModified: time.Now()},
+	}
+	server.Start()
+	defer server.Stop()
+
+	obj := &sourcev1.Bucket{
+		Spec: sourcev1.BucketSpec{
+			BucketName: "gcs-hmac",
+			Endpoint:   strings.TrimPrefix(server.HTTPAddress(), "http://"),
+			Provider:   sourcev1.GoogleBucketProvider,
+			Insecure:   true,
+		},
+	}
+	hmacSecret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "gcp-hmac-secret"},
+		Data: map[string][]byte{
+			"accesskey": []byte(server.AccessKey),
+			"secretkey": []byte("example-secret-key"),
+		},
+	}
+
+	client, err := NewClient(obj, hmacSecret)
+	assert.NilError(t, err)
+
+	var keys []string
+	err = client.VisitObjects(context.TODO(), "gcs-hmac", "", func(key, etag string, _ int64) error {
+		keys = append(keys, key)
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, keys, []string{objectName})
+
+	path := filepath.Join(t.TempDir(), objectName)
+	etag, err := client.FGetObject(context.TODO(), "gcs-hmac", objectName, path)
+	assert.NilError(t, err)
+	assert.Equal(t, etag, fmt.Sprintf("%x", md5.Sum(content)))
+	got, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Assert(t, bytes.Equal(got, content))
+
+	// Requests signed with other credentials are rejected.
+	hmacSecret.Data["accesskey"] = []byte("GOOG1OTHERACCESSKEY")
+	client, err = NewClient(obj, hmacSecret)
+	assert.NilError(t, err)
+	_, err = client.FGetObject(context.TODO(), "gcs-hmac", objectName, path)
+	assert.ErrorContains(t, err, "Access Denied")
+}
+
 func TestVisitObjects(t *testing.T) {
 	keys := []string{}
 	etags := []string{}
-	err := minioClient.VisitObjects(context.TODO(), bucketName, func(key, etag string) error {
+	err := minioClient.VisitObjects(context.TODO(), bucketName, "", func(key, etag string, _ int64) error {
 		keys = append(keys, key)
 		etags = append(etags, etag)
 		return nil
@@ -173,7 +330,7 @@ func TestVisitObjects(t *testing.T) {
 func TestVisitObjectsErr(t *testing.T) {
 	ctx := context.Background()
 	badBucketName := "bad-bucket"
-	err := minioClient.VisitObjects(ctx, badBucketName, func(string, string) error {
+	err := minioClient.VisitObjects(ctx, badBucketName, "", func(string, string, int64) error {
 		return nil
 	})
 	assert.Error(t, err, fmt.Sprintf("listing objects from bucket '%s' failed: The specified bucket does not exist", badBucketName))
@@ -181,7 +338,7 @@ func TestVisitObjectsErr(t *testing.T) {
 
 func TestVisitObjectsCallbackErr(t *testing.T) {
 	mockErr := fmt.Errorf("mock")
-	err := minioClient.VisitObjects(context.TODO(), bucketName, func(key, etag string) error {
+	err := minioClient.VisitObjects(context.TODO(), bucketName, "", func(key, etag string, _ int64) error {
 		return mockErr
 	})
 	assert.Error(t, err, mockErr.Error())
@@ -21,19 +21,38 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 )
 
 const (
-	IgnoreFile   = ".sourceignore"
-	ExcludeVCS   = ".git/,.gitignore,.gitmodules,.gitattributes"
-	ExcludeExt   = "*.jpg,*.jpeg,*.gif,*.png,*.wmv,*.flv,*.tar.gz,*.zip"
-	ExcludeCI    = ".github/,.circleci/,.travis.yml,.gitlab-ci.yml,appveyor.yml,.drone.yml,cloudbuild.yaml,codeship-services.yml,codeship-steps.yml"
-	ExcludeExtra = "**/.goreleaser.yml,**/.sops.yaml,**/.flux.yaml"
+	IgnoreFile     = ".sourceignore"
+	FluxIgnoreFile = ".flux-ignore"
+	ExcludeVCS     = ".git/,.gitignore,.gitmodules,.gitattributes"
+	ExcludeExt     = "*.jpg,*.jpeg,*.gif,*.png,*.wmv,*.flv,*.tar.gz,*.zip"
+	ExcludeCI      = ".github/,.circleci/,.travis.yml,.gitlab-ci.yml,appveyor.yml,.drone.yml,cloudbuild.yaml,codeship-services.yml,codeship-steps.yml"
+	ExcludeExtra   = "**/.goreleaser.yml,**/.sops.yaml,**/.flux.yaml"
 )
 
+// IgnoreFiles are the names of the ignore files which are read from a
+// directory, in order of increasing precedence. The patterns of a file are
+// merged after those of the files listed before it, and may thus overrule
+// them. By default, the IgnoreFile takes precedence over the
+// FluxIgnoreFile.
+var IgnoreFiles = []string{FluxIgnoreFile, IgnoreFile}
+
+// IsIgnoreFile returns if the given file name is one of the IgnoreFiles.
+func IsIgnoreFile(name string) bool {
+	for _, n := range IgnoreFiles {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 // NewMatcher returns a gitignore.Matcher for the given gitignore.Pattern
 // slice. It mainly exists to compliment the API.
 func NewMatcher(ps []gitignore.Pattern) gitignore.Matcher {
@@ -100,21 +119,75 @@ func ReadIgnoreFile(path string, domain []string) ([]gitignore.Pattern, error) {
 	return ps, nil
 }
 
-// LoadIgnorePatterns recursively loads the IgnoreFile patterns found
-// in the directory.
+// ReadIgnoreFiles attempts to read the IgnoreFiles in the given directory,
+// and returns the read patterns merged in the order of the IgnoreFiles.
+func ReadIgnoreFiles(dir string, domain []string) ([]gitignore.Pattern, error) {
+	var ps []gitignore.Pattern
+	for _, name := range IgnoreFiles {
+		fps, err := ReadIgnoreFile(filepath.Join(dir, name), domain)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, fps...)
+	}
+	return ps, nil
+}
+
+// DedupePatterns returns the given patterns without duplicates. Because
+// later patterns take precedence over earlier ones, only the last occurrence
+// of identical patterns (with an equal domain) is kept, which does not change
+// the outcome of matching.
+func DedupePatterns(ps []gitignore.Pattern) []gitignore.Pattern {
+	var deduped []gitignore.Pattern
+	for i, p := range ps {
+		duplicate := false
+		for _, later := range ps[i+1:] {
+			if reflect.DeepEqual(p, later) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			deduped = append(deduped, p)
+		}
+	}
+	return deduped
+}
+
+// LoadIgnorePatterns recursively loads the IgnoreFiles patterns found
+// in the directory. The patterns are returned in a deterministic order:
+// the patterns of the IgnoreFiles in dir come first, followed by those of
+// nested directories in lexical order. This allows nested IgnoreFiles
+// patterns to override the patterns of their parents, e.g. to re-include a
+// file with a negated pattern. Identical patterns are de-duplicated.
 func LoadIgnorePatterns(dir string, domain []string) ([]gitignore.Pattern, error) {
-	ps, err := ReadIgnoreFile(filepath.Join(dir, IgnoreFile), domain)
+	ps, err := loadIgnorePatterns(dir, domain)
 	if err != nil {
 		return nil, err
 	}
+	return DedupePatterns(ps), nil
+}
+
+func loadIgnorePatterns(dir string, domain []string) ([]gitignore.Pattern, error) {
+	ps, err := ReadIgnoreFiles(dir, domain)
+	if err != nil {
+		return nil, err
+	}
+	// os.ReadDir returns the entries sorted by filename
 	fis, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
 	for _, fi := range fis {
 		if fi.IsDir() && fi.Name() != ".git" {
+			// Copy the domain to prevent subdirectories from sharing the
+			// backing array of their parent's domain
+			subdomain := make([]string, len(domain), len(domain)+1)
+			copy(subdomain, domain)
+			subdomain = append(subdomain, fi.Name())
+
 			var subps []gitignore.Pattern
-			if subps, err = LoadIgnorePatterns(filepath.Join(dir, fi.Name()), append(domain, fi.Name())); err != nil {
+			if subps, err = loadIgnorePatterns(filepath.Join(dir, fi.Name()), subdomain); err != nil {
 				return nil, err
 			}
 			if len(subps) > 0 {
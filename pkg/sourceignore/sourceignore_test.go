@@ -254,3 +254,217 @@ func TestLoadExcludePatterns(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadIgnorePatterns_nestedOverride(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		".sourceignore":        "*.txt\n*.txt\nsecret/",
+		"a/.sourceignore":      "!keep.txt",
+		"a/b/.sourceignore":    "*.txt",
+		"c/.sourceignore":      "!*.txt\n*.yaml",
+		"secret/.sourceignore": "!secret.txt",
+	}
+	for n, c := range files {
+		if err := os.MkdirAll(filepath.Join(tmpDir, filepath.Dir(n)), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, n), []byte(c), 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ps, err := LoadIgnorePatterns(tmpDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gitignore.Pattern{
+		gitignore.ParsePattern("*.txt", nil),
+		gitignore.ParsePattern("secret/", nil),
+		gitignore.ParsePattern("!keep.txt", []string{"a"}),
+		gitignore.ParsePattern("*.txt", []string{"a", "b"}),
+		gitignore.ParsePattern("!*.txt", []string{"c"}),
+		gitignore.ParsePattern("*.yaml", []string{"c"}),
+		gitignore.ParsePattern("!secret.txt", []string{"secret"}),
+	}
+	if !reflect.DeepEqual(ps, want) {
+		t.Errorf("LoadIgnorePatterns() got = %#v, want %#v", ps, want)
+	}
+
+	matcher := NewMatcher(ps)
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "root.txt", want: true},
+		{path: "a/keep.txt", want: false},
+		{path: "a/other.txt", want: true},
+		{path: "a/b/keep.txt", want: true},
+		{path: "c/file.txt", want: false},
+		{path: "c/file.yaml", want: true},
+		{path: "file.yaml", want: false},
+		{path: "secret", isDir: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := matcher.Match(strings.Split(tt.path, "/"), tt.isDir); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadIgnorePatterns_ignoreFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		".flux-ignore":    "*.txt\nshared/\n!keep.txt",
+		".sourceignore":   "shared/\n*.txt\n*.yaml",
+		"a/.flux-ignore":  "!a.yaml",
+		"a/.sourceignore": "*.yaml",
+		"b/.flux-ignore":  "b.txt",
+	}
+	for n, c := range files {
+		if err := os.MkdirAll(filepath.Join(tmpDir, filepath.Dir(n)), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, n), []byte(c), 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		ignoreFiles []string
+		want        []gitignore.Pattern
+		matches     map[string]bool
+	}{
+		{
+			name:        "merges and de-duplicates the default ignore files",
+			ignoreFiles: []string{FluxIgnoreFile, IgnoreFile},
+			want: []gitignore.Pattern{
+				gitignore.ParsePattern("!keep.txt", nil),
+				gitignore.ParsePattern("shared/", nil),
+				gitignore.ParsePattern("*.txt", nil),
+				gitignore.ParsePattern("*.yaml", nil),
+				gitignore.ParsePattern("!a.yaml", []string{"a"}),
+				gitignore.ParsePattern("*.yaml", []string{"a"}),
+				gitignore.ParsePattern("b.txt", []string{"b"}),
+			},
+			matches: map[string]bool{
+				"keep.txt": true,
+				"a/a.yaml": true,
+				"b/b.txt":  true,
+			},
+		},
+		{
+			name:        "later ignore file takes precedence",
+			ignoreFiles: []string{IgnoreFile, FluxIgnoreFile},
+			want: []gitignore.Pattern{
+				gitignore.ParsePattern("*.yaml", nil),
+				gitignore.ParsePattern("*.txt", nil),
+				gitignore.ParsePattern("shared/", nil),
+				gitignore.ParsePattern("!keep.txt", nil),
+				gitignore.ParsePattern("*.yaml", []string{"a"}),
+				gitignore.ParsePattern("!a.yaml", []string{"a"}),
+				gitignore.ParsePattern("b.txt", []string{"b"}),
+			},
+			matches: map[string]bool{
+				"keep.txt": false,
+				"a/a.yaml": false,
+				"b/b.txt":  true,
+			},
+		},
+		{
+			name:        "single ignore file",
+			ignoreFiles: []string{IgnoreFile},
+			want: []gitignore.Pattern{
+				gitignore.ParsePattern("shared/", nil),
+				gitignore.ParsePattern("*.txt", nil),
+				gitignore.ParsePattern("*.yaml", nil),
+				gitignore.ParsePattern("*.yaml", []string{"a"}),
+			},
+			matches: map[string]bool{
+				"keep.txt": true,
+				"a/a.yaml": true,
+				"b/b.txt":  true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ignoreFiles := IgnoreFiles
+			IgnoreFiles = tt.ignoreFiles
+			defer func() { IgnoreFiles = ignoreFiles }()
+
+			ps, err := LoadIgnorePatterns(tmpDir, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ps, tt.want) {
+				t.Errorf("LoadIgnorePatterns() got = %#v, want %#v", ps, tt.want)
+			}
+
+			matcher := NewMatcher(ps)
+			for path, want := range tt.matches {
+				if got := matcher.Match(strings.Split(path, "/"), false); got != want {
+					t.Errorf("Match(%q) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsIgnoreFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		IgnoreFile:     true,
+		FluxIgnoreFile: true,
+		".gitignore":   false,
+		"":             false,
+	} {
+		if got := IsIgnoreFile(name); got != want {
+			t.Errorf("IsIgnoreFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDedupePatterns(t *testing.T) {
+	tests := []struct {
+		name string
+		ps   []gitignore.Pattern
+		want []gitignore.Pattern
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "keeps last occurrence",
+			ps: []gitignore.Pattern{
+				gitignore.ParsePattern("*.txt", nil),
+				gitignore.ParsePattern("!keep.txt", nil),
+				gitignore.ParsePattern("*.txt", nil),
+			},
+			want: []gitignore.Pattern{
+				gitignore.ParsePattern("!keep.txt", nil),
+				gitignore.ParsePattern("*.txt", nil),
+			},
+		},
+		{
+			name: "different domains are not duplicates",
+			ps: []gitignore.Pattern{
+				gitignore.ParsePattern("*.txt", nil),
+				gitignore.ParsePattern("*.txt", []string{"a"}),
+			},
+			want: []gitignore.Pattern{
+				gitignore.ParsePattern("*.txt", nil),
+				gitignore.ParsePattern("*.txt", []string{"a"}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DedupePatterns(tt.ps); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DedupePatterns() got = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -102,6 +102,7 @@ function go_compile(){
 
 go_compile FuzzRandomGitFiles fuzz_gitrepository_fuzzer
 go_compile FuzzGitResourceObject fuzz_git_resource_object
+go_compile FuzzUntar fuzz_untar
 
 # By now testdata is embedded in the binaries and no longer needed.
 # Remove the dir given that it will be owned by root otherwise.
@@ -0,0 +1,93 @@
+//go:build gofuzz
+// +build gofuzz
+
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fluxcd/source-controller/internal/tar"
+)
+
+// FuzzUntar implements a fuzzer that targets the extraction of untrusted
+// tar files, and panics when a file is written outside of the target
+// directory, or when a symlink points outside of it.
+func FuzzUntar(data []byte) int {
+	root, err := os.MkdirTemp("", "fuzz-untar-")
+	if err != nil {
+		return 0
+	}
+	defer os.RemoveAll(root)
+
+	dir, err := os.MkdirTemp(root, "dir-")
+	if err != nil {
+		return 0
+	}
+	err = tar.Untar(bytes.NewReader(data), dir, tar.WithMaxUntarSize(1<<20), tar.WithMaxEntries(100))
+
+	entries, rerr := os.ReadDir(root)
+	if rerr != nil {
+		panic(rerr)
+	}
+	if len(entries) != 1 {
+		panic("tar extraction wrote outside of the target directory")
+	}
+	if werr := checkSymlinks(dir); werr != nil {
+		panic(werr)
+	}
+	if err != nil {
+		return 0
+	}
+	return 1
+}
+
+// checkSymlinks returns an error if a symlink in dir has a target outside
+// of dir, taking the symlinks in the path of the symlink into account.
+func checkSymlinks(dir string) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(realDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.Type()&fs.ModeSymlink == 0 {
+			return err
+		}
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(target) {
+			return fmt.Errorf("symlink '%s' has an absolute target '%s'", p, target)
+		}
+		parent, err := filepath.EvalSymlinks(filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		resolved := filepath.Join(parent, target)
+		if resolved != realDir && !strings.HasPrefix(resolved, realDir+string(filepath.Separator)) {
+			return fmt.Errorf("symlink '%s' has a target '%s' outside of the target directory", p, target)
+		}
+		return nil
+	})
+}